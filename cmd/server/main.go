@@ -8,11 +8,17 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/corvino/claudetalk/internal/cron"
+	"github.com/corvino/claudetalk/internal/limits"
+	"github.com/corvino/claudetalk/internal/prompt"
+	"github.com/corvino/claudetalk/internal/protocol"
 	"github.com/corvino/claudetalk/internal/runner"
 	"github.com/corvino/claudetalk/internal/server"
+	"github.com/corvino/claudetalk/internal/synopsis"
 )
 
 func main() {
@@ -21,51 +27,290 @@ func main() {
 	fileDir := flag.String("file-dir", "claudetalk-files", "directory for file storage")
 	maxFileSize := flag.Int64("max-file-size", 50*1024*1024, "max file size in bytes (default 50MB)")
 	claudeBin := flag.String("claude-bin", "claude", "path to claude CLI binary")
+	agent := flag.String("agent", "claude", "agent backend for spawns: "+strings.Join(runner.Backends(), ", "))
+	agentCmd := flag.String("agent-cmd", "", "shell command for -agent command (prompt on stdin, reply on stdout)")
+	sandbox := flag.String("sandbox", "none", "where spawned agents run: none (on this machine) or docker")
+	sandboxImage := flag.String("sandbox-image", runner.DefaultSandboxImage, "image for -sandbox docker (build it from Dockerfile.sandbox)")
+	spawnLog := flag.String("spawn-log", "claudetalk-spawns.jsonl", "append-only audit log of every spawn (empty = don't keep one)")
 	noClaude := flag.Bool("no-claude", false, "disable Claude spawning")
+	claudeTimeout := flag.Duration("claude-timeout", 15*time.Minute, "kill a spawned Claude after this long (0 = no limit)")
+	claudeModel := flag.String("claude-model", "", "default model for spawned Claudes (e.g. haiku, sonnet, opus)")
+	claudeMaxTurns := flag.Int("claude-max-turns", 0, "default max agentic turns per spawn (0 = CLI default)")
+	claudeTools := flag.String("claude-allowed-tools", "", "default comma-separated tools spawned Claudes may use (e.g. \"Read,Bash(git:*)\")")
+	claudePermMode := flag.String("claude-permission-mode", "", "default permission mode for spawns: "+strings.Join(runner.PermissionModes, ", ")+" (default skips all prompts)")
+	claudeModels := flag.String("claude-models", strings.Join(runner.DefaultModels, ","), "comma-separated models the web UI offers for spawns")
+	spawnPresets := flag.String("spawn-presets", "", "YAML file of prompt presets the web UI offers for spawns (empty = built-in presets)")
+	claudeDirs := flag.String("claude-allowed-dirs", "", "comma-separated base directories rooms and spawns may choose a work_dir under")
+	maxClaudes := flag.Int("max-concurrent-claudes", 4, "max Claude processes running at once; more spawns queue (0 = unlimited)")
+	claudeNice := flag.Int("claude-nice", 0, "run spawned Claudes at this niceness (0-19)")
+	claudeMemory := flag.Int("claude-memory-mb", 0, "memory cap per spawned Claude in MiB (Linux with systemd; 0 = none)")
+	claudeCPU := flag.Int("claude-cpu-percent", 0, "CPU quota per spawned Claude, 100 = one core (Linux with systemd; 0 = none)")
+	claudeCPUTime := flag.Duration("claude-cpu-time", 0, "kill a spawned Claude after this much CPU time (0 = none)")
+	moderate := flag.Bool("moderate", false, "enforce round-robin turn order in group conversation threads")
+	offlineTTL := flag.Duration("offline-queue-ttl", server.DefaultOfflineTTL, "queue directed messages to an offline daemon and spawn for them if it reconnects within this long (0 = don't queue)")
+	roomCreation := flag.String("room-creation", server.RoomCreationAuto, "how rooms come to exist: auto (sending to or joining one creates it) or explicit (only POST /api/rooms does)")
+	digestCron := flag.String("digest-cron", "", "cron schedule for automatic per-room digests (e.g. \"0 18 * * *\")")
+	digestFormat := flag.String("digest-format", "markdown", "format for scheduled digests: markdown, json, html, slack")
+	digestWebhook := flag.String("digest-webhook", "", "POST scheduled digests to this URL instead of the room")
+	replyAlertAfter := flag.Duration("reply-alert-after", 0, "post a system message when a directed message has waited this long for its reply (0 = never)")
+	replyAlertWebhook := flag.String("reply-alert-webhook", "", "POST reply alerts to this URL as JSON instead of the room")
+	smtpHost := flag.String("smtp-host", "", "SMTP server for unanswered-message emails (disabled if empty)")
+	smtpPort := flag.Int("smtp-port", 587, "SMTP server port")
+	smtpUser := flag.String("smtp-user", "", "SMTP username (password is read from CLAUDETALK_SMTP_PASSWORD)")
+	smtpFrom := flag.String("smtp-from", "", "From address for notification emails")
+	notifyEmails := flag.String("notify-emails", "", "participant email addresses, e.g. \"alice=alice@example.com,bob=bob@example.com\"")
+	notifyAfter := flag.Duration("notify-after", 10*time.Minute, "email a recipient after a directed message goes unanswered this long")
+	publicURL := flag.String("public-url", "", "public base URL of the web UI, used for links in notifications")
+	redisURL := flag.String("redis", "", "redis:// URL through which instances behind a load balancer share rooms (empty = single instance)")
+	redisPrefix := flag.String("redis-prefix", "claudetalk", "key prefix in -redis; instances with the same prefix share rooms")
+	workspacesFile := flag.String("workspaces", "", "YAML file of token-protected workspaces served under /api/workspaces/{name}/ (empty = none)")
+	promptFile := flag.String("prompt-templates", "", "text/template file overriding sections of spawn prompts in every room, e.g. {{define \"style\"}}...{{end}}; rooms can add their own with the prompt setting")
+	drainTimeout := flag.Duration("drain-timeout", 30*time.Second, "on shutdown, how long running Claudes get to finish before they are stopped")
+	reconnectSpread := flag.Duration("reconnect-spread", 10*time.Second, "on shutdown, disconnect WebSocket clients evenly over this long so they don't all reconnect to the next server at once")
+	reusePort := flag.Bool("reuseport", false, "listen with SO_REUSEPORT, so a new server can start on the port while this one drains (share rooms with -redis)")
+	stateFile := flag.String("state", "", "JSON file to save rooms to on shutdown and restore them from at startup (not with -redis)")
+	journalDir := flag.String("journal", "", "directory for an append-only journal of every room, synced each second and replayed at startup so a crash loses little (not with -redis)")
+	workspaceDir := flag.String("workspace-dir", "claudetalk-workspaces", "directory for workspace file storage, one subdirectory per workspace")
 	flag.Parse()
+	if err := synopsis.CheckFormat(*digestFormat); err != nil {
+		log.Fatalf("digest-format: %v", err)
+	}
 
 	hub := server.NewHub(*maxHistory)
+	if *moderate {
+		hub.SetModerated(true)
+		log.Println("moderator mode enabled (round-robin turns in group threads)")
+	}
+	if err := hub.SetRoomCreation(*roomCreation); err != nil {
+		log.Fatalf("room-creation: %v", err)
+	}
+	hub.SetOfflineTTL(*offlineTTL)
+	// Share links are signed with a random key, and so stop working on
+	// restart, unless a secret is set; instances behind -redis need the same.
+	if secret := os.Getenv("CLAUDETALK_SHARE_SECRET"); secret != "" {
+		hub.SetShareSecret(secret)
+	} else if *redisURL != "" {
+		log.Println("CLAUDETALK_SHARE_SECRET is unset; share links will only work on the instance that made them")
+	}
 
 	fileStore, err := server.NewFileStore(*fileDir, *maxFileSize)
 	if err != nil {
 		log.Fatalf("create file store: %v", err)
 	}
 
+	// Before rooms are restored, so they get the overrides too.
+	var promptTemplate string
+	if *promptFile != "" {
+		if promptTemplate, err = prompt.Load(*promptFile); err != nil {
+			log.Fatalf("prompt-templates: %v", err)
+		}
+		hub.SetPromptTemplate(promptTemplate)
+	}
+
+	if *stateFile != "" {
+		if *redisURL != "" {
+			log.Fatal("-state and -redis don't mix: redis keeps the rooms")
+		}
+		n, err := hub.LoadState(*stateFile, fileStore)
+		if err != nil {
+			log.Fatalf("state: %v", err)
+		}
+		if n > 0 {
+			log.Printf("restored %d rooms from %s", n, *stateFile)
+		}
+	}
+	var journal *server.Journal
+	if *journalDir != "" {
+		if *redisURL != "" {
+			log.Fatal("-journal and -redis don't mix: redis keeps the rooms")
+		}
+		var n int
+		journal, n, err = server.OpenJournal(*journalDir, hub, fileStore)
+		if err != nil {
+			log.Fatalf("journal: %v", err)
+		}
+		if n > 0 {
+			log.Printf("replayed the journal of %d rooms from %s", n, *journalDir)
+		}
+	}
+
+	clusterCtx, stopCluster := context.WithCancel(context.Background())
+	defer stopCluster()
+	if *redisURL != "" {
+		cluster, err := server.NewRedisCluster(*redisURL, *redisPrefix, hub, fileStore)
+		if err != nil {
+			log.Fatalf("redis: %v", err)
+		}
+		go cluster.Run(clusterCtx)
+		log.Printf("sharing rooms through redis (prefix %q); use a shared -file-dir for files", *redisPrefix)
+	}
+
 	addr := fmt.Sprintf(":%d", *port)
 	serverURL := fmt.Sprintf("http://localhost:%d", *port)
 
 	var r *runner.Runner
 	if !*noClaude {
+		defaults := runner.Options{
+			Model:          *claudeModel,
+			MaxTurns:       *claudeMaxTurns,
+			PermissionMode: *claudePermMode,
+		}
+		if *claudeTools != "" {
+			defaults.AllowedTools = strings.Split(*claudeTools, ",")
+		}
+		if err := defaults.Validate(); err != nil {
+			log.Fatalf("claude options: %v", err)
+		}
+		lim := limits.Limits{
+			Nice:       *claudeNice,
+			MemoryMB:   *claudeMemory,
+			CPUPercent: *claudeCPU,
+			CPUTime:    *claudeCPUTime,
+		}
+		if err := lim.Validate(); err != nil {
+			log.Fatalf("claude limits: %v", err)
+		}
+		backend, err := runner.NewBackend(*agent, runner.BackendConfig{
+			ClaudeBin:    *claudeBin,
+			Command:      *agentCmd,
+			Limits:       lim,
+			Sandbox:      *sandbox,
+			SandboxImage: *sandboxImage,
+		})
+		if err != nil {
+			log.Fatalf("agent: %v", err)
+		}
+		var audit *runner.AuditLog
+		if *spawnLog != "" {
+			if audit, err = runner.OpenAuditLog(*spawnLog, 1000); err != nil {
+				log.Fatalf("spawn-log: %v", err)
+			}
+			defer audit.Close()
+		}
+		var allowedDirs []string
+		if *claudeDirs != "" {
+			allowedDirs = strings.Split(*claudeDirs, ",")
+		}
+		var models []string
+		if *claudeModels != "" {
+			models = strings.Split(*claudeModels, ",")
+		}
+		var presets []protocol.SpawnPreset
+		if *spawnPresets != "" {
+			if presets, err = runner.LoadPresets(*spawnPresets); err != nil {
+				log.Fatalf("spawn-presets: %v", err)
+			}
+		}
 		r = runner.New(runner.Config{
-			ClaudeBin: *claudeBin,
-			ServerURL: serverURL,
+			ServerURL:     serverURL,
+			Timeout:       *claudeTimeout,
+			Defaults:      defaults,
+			AllowedDirs:   allowedDirs,
+			Models:        models,
+			Presets:       presets,
+			MaxConcurrent: *maxClaudes,
+			Backend:       backend,
+			Audit:         audit,
+			RoomToken:     hub.RoomToken,
 		})
-		log.Println("Claude runner enabled (local subprocess)")
+		log.Printf("Claude runner enabled (%s backend, sandbox: %s, limits: %s)", backend.Name(), *sandbox, lim)
 	} else {
 		log.Println("Claude runner disabled")
 	}
 
-	srv := server.New(hub, addr, fileStore, r)
+	if *smtpHost != "" {
+		addresses, err := server.ParseAddresses(*notifyEmails)
+		if err != nil {
+			log.Fatalf("notify-emails: %v", err)
+		}
+		notifier := server.NewEmailNotifier(server.SMTPConfig{
+			Host:     *smtpHost,
+			Port:     *smtpPort,
+			Username: *smtpUser,
+			Password: os.Getenv("CLAUDETALK_SMTP_PASSWORD"),
+			From:     *smtpFrom,
+		}, addresses, *notifyAfter, *publicURL)
+		hub.Observe(notifier.Observe)
+		log.Printf("email notifications enabled for %d participants (after %s)", len(addresses), *notifyAfter)
+	}
+
+	var workspaces []*server.Workspace
+	if *workspacesFile != "" {
+		if workspaces, err = server.LoadWorkspaces(*workspacesFile, *workspaceDir, *maxHistory, *maxFileSize); err != nil {
+			log.Fatalf("workspaces: %v", err)
+		}
+		for _, ws := range workspaces {
+			ws.Hub.SetModerated(*moderate)
+			ws.Hub.SetRoomCreation(*roomCreation)
+			ws.Hub.SetOfflineTTL(*offlineTTL)
+			ws.Hub.SetPromptTemplate(promptTemplate)
+		}
+		log.Printf("serving %d workspaces under /api/workspaces/ (not shared through -redis)", len(workspaces))
+	}
+
+	srv := server.New(hub, addr, fileStore, r, workspaces...)
+
+	digestCtx, stopDigests := context.WithCancel(context.Background())
+	defer stopDigests()
+	if *digestCron != "" {
+		sched, err := cron.Parse(*digestCron)
+		if err != nil {
+			log.Fatalf("digest-cron: %v", err)
+		}
+		ds := server.NewDigestScheduler(hub, server.DigestConfig{
+			Schedule: sched,
+			Format:   *digestFormat,
+			Webhook:  *digestWebhook,
+		})
+		go ds.Run(digestCtx)
+		log.Printf("scheduled digests enabled (%s)", *digestCron)
+	}
+	alertCtx, stopAlerts := context.WithCancel(context.Background())
+	defer stopAlerts()
+	if *replyAlertAfter > 0 {
+		ra := server.NewReplyAlerter(hub, server.ReplyAlertConfig{
+			After:   *replyAlertAfter,
+			Webhook: *replyAlertWebhook,
+		})
+		go ra.Run(alertCtx)
+		log.Printf("reply alerts enabled (after %s)", *replyAlertAfter)
+	}
 
 	// Graceful shutdown on SIGINT/SIGTERM.
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 
+	// Under systemd socket activation the socket comes from systemd; see
+	// server.Listen.
+	ln, err := server.Listen(addr, *reusePort)
+	if err != nil {
+		log.Fatalf("listen: %v", err)
+	}
 	go func() {
-		log.Printf("claudetalk-server listening on %s", addr)
+		log.Printf("claudetalk-server listening on %s", ln.Addr())
 		log.Printf("open http://localhost:%d in your browser", *port)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("listen: %v", err)
 		}
 	}()
 
 	<-stop
 	log.Println("shutting down...")
+	stopDigests()
+	stopAlerts()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("shutdown: %v", err)
+	if err := server.Shutdown(srv, hub, r, *drainTimeout, *reconnectSpread, workspaces...); err != nil {
+		log.Printf("shutdown: %v", err)
+	}
+	if *stateFile != "" {
+		if err := hub.SaveState(*stateFile, fileStore); err != nil {
+			log.Fatalf("save state: %v", err)
+		}
+	}
+	if journal != nil {
+		if err := journal.Close(); err != nil {
+			log.Printf("journal: %v", err)
+		}
 	}
 	log.Println("server stopped")
 }
@@ -0,0 +1,45 @@
+// Package buildinfo describes the running binary: the release it was built
+// as, set at build time, and the commit it was built from, which Go records
+// itself when building from a git checkout.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// Version is the release the binary was built as, set with
+//
+//	go build -ldflags "-X github.com/corvino/claudetalk/internal/buildinfo.Version=v1.4.0"
+//
+// as "make" does. Other builds are "dev".
+var Version = "dev"
+
+// Get describes this binary.
+func Get() protocol.VersionInfo {
+	info := protocol.VersionInfo{
+		Version:     Version,
+		Protocol:    protocol.ProtocolVersion,
+		MinProtocol: protocol.MinProtocol,
+		GoVersion:   runtime.Version(),
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Commit = s.Value
+		case "vcs.time":
+			info.CommitTime = s.Value
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	return info
+}
@@ -0,0 +1,262 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/corvino/claudetalk/internal/runner"
+	"github.com/spf13/cobra"
+)
+
+// askPollInterval is how often ask checks whether a server-run Claude's
+// session has ended.
+const askPollInterval = time.Second
+
+// askDrain is how long ask keeps printing after the session ends, for the
+// Claude's last messages still on their way.
+const askDrain = 500 * time.Millisecond
+
+func newAskCmd() *cobra.Command {
+	var (
+		local   bool
+		workDir string
+		claude  string
+		noColor bool
+		rf      runnerFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "ask <prompt>",
+		Short: "Ask your Claude something and follow it until it's done",
+		Long: `Spawns your Claude with a prompt, like the web UI's "Ask Claude" button, then
+streams the room into the terminal until its session ends. What the Claude
+whispers to you, its live progress and any answer it printed rather than
+posted, is set apart from the public messages everyone in the room sees.
+Ctrl-C stops the Claude; a second Ctrl-C leaves without waiting.
+
+By default the server runs the Claude, so it must have a Claude runner
+(claudetalk host). With --local it runs on this machine, as with claudetalk
+web, and the --claude-* flags configure it; --claude-model and the other
+spawn options apply to server-run Claudes too.
+
+Examples:
+  claudetalk ask "Summarize what the room decided about the cache"
+  claudetalk ask --local --work-dir ~/src/api "Fix the failing test in store_test.go"`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flagRoom == "" {
+				return fmt.Errorf("room is required (use -r or CLAUDETALK_ROOM)")
+			}
+			if flagSender == "" {
+				return fmt.Errorf("sender name is required (use -n or CLAUDETALK_SENDER)")
+			}
+			if claude != "" {
+				if err := protocol.ValidateClaudeName(claude); err != nil {
+					return err
+				}
+			}
+			if err := rf.defaults.Validate(); err != nil {
+				return err
+			}
+			prompt := strings.Join(args, " ")
+
+			// Follow the room first, so nothing the Claude says is missed. A
+			// local Claude's picked name is registered by this connection, as
+			// the server's spawn API does for its own.
+			wsURL := buildWSURL(flagServer, flagRoom, flagSender)
+			if local && claude != "" {
+				wsURL += "&" + url.Values{"claude_name": {claude}}.Encode()
+			}
+			conn, resp, err := dialWS(wsURL)
+			if err != nil {
+				return fmt.Errorf("connect: %w", protocol.HandshakeError(err, resp))
+			}
+			defer conn.Close()
+			messages := make(chan protocol.Envelope, 64)
+			go func() {
+				defer close(messages)
+				for {
+					var env protocol.Envelope
+					if err := conn.ReadJSON(&env); err != nil {
+						return
+					}
+					if env.ID == "" {
+						continue // a server event such as message_expired, not a message
+					}
+					roomKeys().Open(&env)
+					messages <- env
+				}
+			}()
+
+			var s *askSession
+			if local {
+				rcfg, err := rf.config(flagServer)
+				if err != nil {
+					return err
+				}
+				log.SetOutput(io.Discard) // the runner's log repeats what the room shows
+				s, err = askLocal(runner.New(rcfg), prompt, claude, workDir, rf.defaults)
+				if err != nil {
+					return err
+				}
+			} else {
+				s, err = askServer(prompt, claude, workDir, rf.defaults)
+				if err != nil {
+					return err
+				}
+			}
+			fmt.Fprintf(os.Stderr, "asked %s; following room %q (Ctrl-C stops it)\n", s.claude, flagRoom)
+			return s.follow(messages, !noColor)
+		},
+	}
+
+	cmd.Flags().BoolVar(&local, "local", false, "run the Claude on this machine instead of on the server")
+	cmd.Flags().StringVar(&workDir, "work-dir", "", "directory the Claude works in (default: the room's work_dir setting, or the runner's)")
+	cmd.Flags().StringVar(&claude, "claude-name", configClaudeName, "name your Claude speaks as (default \"{name}'s Claude\", or from claudetalk join)")
+	cmd.Flags().BoolVar(&noColor, "no-color", false, "disable colored output")
+	rf.register(cmd, "with --local, base directories --work-dir may be under")
+	return cmd
+}
+
+// askSession is a Claude that ask started and follows until done is closed.
+type askSession struct {
+	claude string
+	done   chan struct{}
+	stop   func()
+}
+
+// askServer spawns the Claude through the server's spawn API, and watches
+// the server's sessions until the owner's direct session ends.
+func askServer(prompt, name, workDir string, opts runner.Options) (*askSession, error) {
+	req := struct {
+		Sender  string `json:"sender"`
+		Name    string `json:"name,omitempty"`
+		Prompt  string `json:"prompt"`
+		WorkDir string `json:"work_dir,omitempty"`
+		runner.Options
+	}{flagSender, name, prompt, workDir, opts}
+	var resp struct {
+		Claude string `json:"claude"`
+	}
+	base := fmt.Sprintf("/api/rooms/%s/", url.PathEscape(flagRoom))
+	if err := postJSON(apiURL(flagServer, base+"spawn"), req, &resp); err != nil {
+		return nil, fmt.Errorf("spawn: %w", err)
+	}
+
+	s := &askSession{claude: resp.Claude, done: make(chan struct{})}
+	s.stop = func() {
+		if err := postJSON(apiURL(flagServer, base+"stop"), map[string]string{"sender": flagSender}, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "stop: %v\n", err)
+		}
+	}
+	go func() {
+		defer close(s.done)
+		for range time.Tick(askPollInterval) {
+			var list protocol.SessionList
+			if err := getJSON(apiURL(flagServer, base+"sessions"), &list); err != nil {
+				fmt.Fprintf(os.Stderr, "lost track of %s: %v\n", s.claude, err)
+				return
+			}
+			running := false
+			for _, sess := range list.Sessions {
+				running = running || (sess.Sender == flagSender && sess.ConvID == "")
+			}
+			if !running {
+				return
+			}
+		}
+	}()
+	return s, nil
+}
+
+// askLocal runs the Claude on this machine with rnr, as claudetalk web does.
+func askLocal(rnr *runner.Runner, prompt, name, workDir string, opts runner.Options) (*askSession, error) {
+	if _, err := rnr.ResolveWorkDir(workDir); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &askSession{
+		claude: protocol.ClaudeName(flagSender, name),
+		done:   make(chan struct{}),
+		stop:   cancel,
+	}
+	go func() {
+		defer close(s.done)
+		defer cancel()
+		err := rnr.Spawn(runner.SpawnParams{
+			Room:    flagRoom,
+			Sender:  flagSender,
+			Name:    s.claude,
+			Prompt:  prompt,
+			Ctx:     ctx,
+			Options: opts,
+			WorkDir: workDir,
+		})
+		if err != nil && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", s.claude, err)
+		}
+	}()
+	return s, nil
+}
+
+// follow prints messages until the session ends, then briefly longer for
+// its last messages, or until a second interrupt.
+func (s *askSession) follow(messages <-chan protocol.Envelope, color bool) error {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	done := s.done
+	var drained <-chan time.Time
+	stopping := false
+	for {
+		select {
+		case env, ok := <-messages:
+			if !ok {
+				return fmt.Errorf("lost the connection to the room")
+			}
+			fmt.Println(formatAsk(env, color))
+		case <-done:
+			done = nil
+			drained = time.After(askDrain)
+		case <-drained:
+			fmt.Fprintf(os.Stderr, "%s is done\n", s.claude)
+			return nil
+		case <-interrupt:
+			if stopping {
+				return nil
+			}
+			stopping = true
+			fmt.Fprintf(os.Stderr, "stopping %s... (Ctrl-C again to leave now)\n", s.claude)
+			s.stop()
+		}
+	}
+}
+
+// formatAsk formats a message for ask: the Claude's progress dimmed and its
+// whispers to you marked off, public messages as tail shows them.
+func formatAsk(env protocol.Envelope, color bool) string {
+	format := formatPlain
+	if color {
+		format = formatColor
+	}
+	switch {
+	case env.Metadata["progress"] == "true":
+		line := "    ⋯ " + strings.TrimPrefix(env.Payload.Text, env.Sender+": ")
+		if color {
+			line = "\033[2m" + line + ansiReset
+		}
+		return line
+	case env.Metadata["private"] == "true" && env.Metadata["to"] == flagSender:
+		return "  🔒 whisper │ " + strings.ReplaceAll(format(env), "\n", "\n            │ ")
+	}
+	return format(env)
+}
@@ -0,0 +1,298 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/gorilla/websocket"
+	"github.com/spf13/cobra"
+)
+
+// Metadata the bench stamps on its messages so receivers can recognise them
+// and time their delivery.
+const (
+	benchRunMeta  = "bench_run"
+	benchSentMeta = "bench_sent" // send time, Unix nanoseconds
+)
+
+func newBenchCmd() *cobra.Command {
+	var (
+		rooms     int
+		clients   int
+		rate      string
+		duration  time.Duration
+		drain     time.Duration
+		transport string
+		size      int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "bench",
+		Short: "Load-test a server with synthetic senders and report latency and drops",
+		Long: `Connects --clients synthetic participants over WebSocket, spread evenly over
+--rooms new rooms, then sends messages at --rate for --duration, alternating
+between REST posts and WebSocket frames. Every client receives every message
+in its room, so the run reports how long broadcasts took to arrive and how
+many never did.
+
+The bench rooms are named bench-<run>-<n> and stay on the server like any
+other room. Don't point this at a server people are using.
+
+Examples:
+  claudetalk bench -s http://localhost:8080
+  claudetalk bench --rooms 10 --clients 200 --rate 50/s --duration 1m
+  claudetalk bench --transport ws --rate 500/s`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			perSec, err := parseRate(rate)
+			if err != nil {
+				return err
+			}
+			if rooms < 1 || clients < rooms {
+				return fmt.Errorf("need at least one room and one client per room")
+			}
+			switch transport {
+			case "both", "rest", "ws":
+			default:
+				return fmt.Errorf("transport must be both, rest or ws")
+			}
+
+			b := newBench(fmt.Sprintf("bench-%d", time.Now().Unix()%1000000), rooms)
+			fmt.Fprintf(os.Stderr, "bench %s: connecting %d clients to %d rooms on %s ...\n", b.run, clients, rooms, flagServer)
+			for i := 0; i < clients; i++ {
+				if err := b.connect(i%rooms, fmt.Sprintf("%s-c%d", b.run, i)); err != nil {
+					b.connectErrors++
+					if b.connectErrors == 1 {
+						fmt.Fprintf(os.Stderr, "connect: %v\n", err)
+					}
+				}
+			}
+			if len(b.clients) == 0 {
+				return fmt.Errorf("no clients could connect")
+			}
+
+			fmt.Fprintf(os.Stderr, "sending %.1f msg/s for %s ...\n", perSec, duration)
+			start := time.Now()
+			b.send(perSec, duration, transport, size)
+			elapsed := time.Since(start)
+			time.Sleep(drain)
+			b.close()
+
+			b.report(os.Stdout, elapsed)
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&rooms, "rooms", 1, "number of rooms to spread clients over")
+	cmd.Flags().IntVar(&clients, "clients", 20, "number of WebSocket clients, in total")
+	cmd.Flags().StringVar(&rate, "rate", "10/s", "messages to send, in total, e.g. 50/s or 600/m")
+	cmd.Flags().DurationVar(&duration, "duration", 30*time.Second, "how long to send for")
+	cmd.Flags().DurationVar(&drain, "drain", 3*time.Second, "how long to wait for late deliveries after sending stops")
+	cmd.Flags().StringVar(&transport, "transport", "both", "how to send: both (alternating), rest or ws")
+	cmd.Flags().IntVar(&size, "size", 100, "message text size in bytes")
+
+	return cmd
+}
+
+// parseRate parses a rate such as "50/s", "600/m" or "50" into messages per
+// second.
+func parseRate(s string) (float64, error) {
+	num, unit, _ := strings.Cut(s, "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err == nil && n > 0 {
+		switch unit {
+		case "", "s":
+			return n, nil
+		case "m":
+			return n / 60, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid rate %q (want e.g. 50/s or 600/m)", s)
+}
+
+// bench is one load-test run.
+type bench struct {
+	run   string
+	rooms []string
+
+	clients       []*benchClient
+	inRoom        []int // clients per room
+	connectErrors int
+
+	sentREST, sentWS, sendErrors atomic.Int64
+	expected, received           atomic.Int64
+
+	mu       sync.Mutex
+	delivery []time.Duration // send → receipt, per delivery
+	post     []time.Duration // REST round trips
+	wg       sync.WaitGroup
+}
+
+type benchClient struct {
+	name string
+	room int
+	conn *websocket.Conn
+}
+
+func newBench(run string, rooms int) *bench {
+	b := &bench{run: run, inRoom: make([]int, rooms)}
+	for i := 0; i < rooms; i++ {
+		b.rooms = append(b.rooms, fmt.Sprintf("%s-%d", run, i))
+	}
+	return b
+}
+
+// connect joins a client to room and starts counting what it receives.
+func (b *bench) connect(room int, name string) error {
+	conn, _, err := dialWS(buildWSURL(flagServer, url.PathEscape(b.rooms[room]), name))
+	if err != nil {
+		return err
+	}
+	c := &benchClient{name: name, room: room, conn: conn}
+	b.clients = append(b.clients, c)
+	b.inRoom[room]++
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		for {
+			var env protocol.Envelope
+			if err := conn.ReadJSON(&env); err != nil {
+				return
+			}
+			if env.Metadata[benchRunMeta] != b.run {
+				continue
+			}
+			sent, err := strconv.ParseInt(env.Metadata[benchSentMeta], 10, 64)
+			if err != nil {
+				continue
+			}
+			b.received.Add(1)
+			b.record(&b.delivery, time.Since(time.Unix(0, sent)))
+		}
+	}()
+	return nil
+}
+
+// send posts messages at perSec for d, from clients in turn.
+func (b *bench) send(perSec float64, d time.Duration, transport string, size int) {
+	text := strings.Repeat("x", size)
+	tick := time.NewTicker(time.Duration(float64(time.Second) / perSec))
+	defer tick.Stop()
+	stop := time.After(d)
+	var posts sync.WaitGroup
+	for n := 0; ; n++ {
+		select {
+		case <-stop:
+			posts.Wait()
+			return
+		case <-tick.C:
+		}
+		c := b.clients[n%len(b.clients)]
+		req := protocol.SendRequest{
+			Sender:  c.name,
+			Type:    protocol.TypeText,
+			Payload: protocol.Payload{Text: text},
+			Metadata: map[string]string{
+				benchRunMeta:  b.run,
+				benchSentMeta: strconv.FormatInt(time.Now().UnixNano(), 10),
+			},
+		}
+		if transport == "ws" || (transport == "both" && n%2 == 1) {
+			if err := c.conn.WriteJSON(req); err != nil {
+				b.sendErrors.Add(1)
+				continue
+			}
+			b.sentWS.Add(1)
+			b.expected.Add(int64(b.inRoom[c.room]))
+			continue
+		}
+		posts.Add(1)
+		go func() {
+			defer posts.Done()
+			if err := b.postREST(b.rooms[c.room], req); err != nil {
+				b.sendErrors.Add(1)
+				return
+			}
+			b.sentREST.Add(1)
+			b.expected.Add(int64(b.inRoom[c.room]))
+		}()
+	}
+}
+
+func (b *bench) postREST(room string, req protocol.SendRequest) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	resp, err := httpClient.Post(apiURL(flagServer, "/api/rooms/"+url.PathEscape(room)+"/messages"), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("server returned %d", resp.StatusCode)
+	}
+	b.record(&b.post, time.Since(start))
+	return nil
+}
+
+func (b *bench) record(into *[]time.Duration, d time.Duration) {
+	b.mu.Lock()
+	*into = append(*into, d)
+	b.mu.Unlock()
+}
+
+func (b *bench) close() {
+	for _, c := range b.clients {
+		c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		c.conn.Close()
+	}
+	b.wg.Wait()
+}
+
+func (b *bench) report(w *os.File, elapsed time.Duration) {
+	rest, ws := b.sentREST.Load(), b.sentWS.Load()
+	fmt.Fprintf(w, "run        %s: %d rooms, %d clients connected", b.run, len(b.rooms), len(b.clients))
+	if b.connectErrors > 0 {
+		fmt.Fprintf(w, " (%d failed to connect)", b.connectErrors)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "sent       %d messages (%d REST, %d WS), %d errors, %.1f msg/s\n",
+		rest+ws, rest, ws, b.sendErrors.Load(), float64(rest+ws)/elapsed.Seconds())
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.post) > 0 {
+		fmt.Fprintf(w, "REST post  %s\n", percentiles(b.post))
+	}
+	if len(b.delivery) > 0 {
+		fmt.Fprintf(w, "delivery   %s\n", percentiles(b.delivery))
+	}
+	expected, received := b.expected.Load(), b.received.Load()
+	dropped := expected - received
+	pct := 0.0
+	if expected > 0 {
+		pct = 100 * float64(dropped) / float64(expected)
+	}
+	fmt.Fprintf(w, "broadcast  %d deliveries expected, %d received, %d dropped (%.2f%%)\n", expected, received, dropped, pct)
+}
+
+// percentiles summarises ds as p50/p90/p99/max. It sorts ds.
+func percentiles(ds []time.Duration) string {
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+	at := func(p float64) time.Duration {
+		return ds[int(p*float64(len(ds)-1))].Round(10 * time.Microsecond)
+	}
+	return fmt.Sprintf("p50 %s  p90 %s  p99 %s  max %s", at(0.50), at(0.90), at(0.99), ds[len(ds)-1].Round(10*time.Microsecond))
+}
@@ -6,19 +6,64 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/gorilla/websocket"
 )
 
-var httpClient = &http.Client{Timeout: 10 * time.Second}
+var httpClient = &http.Client{Timeout: 10 * time.Second, Transport: versionTransport{protocol.AuthTransport{}}}
+
+// versionTransport checks the protocol version of every server response;
+// see checkServerVersion.
+type versionTransport struct {
+	base http.RoundTripper
+}
+
+func (t versionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	// A proxy's or tunnel's error page says nothing about the server.
+	if err == nil && (resp.StatusCode < 300 || resp.Header.Get(protocol.ProtocolHeader) != "") {
+		checkServerVersion(resp.Header)
+	}
+	return resp, err
+}
+
+// dialWS dials a server WebSocket, sending this CLI's protocol version and
+// checking the server's; see checkServerVersion.
+func dialWS(wsURL string) (*websocket.Conn, *http.Response, error) {
+	conn, resp, err := websocket.DefaultDialer.Dial(wsURL, protocol.VersionHeader())
+	if err == nil {
+		checkServerVersion(resp.Header)
+	}
+	return conn, resp, err
+}
+
+// versionWarned is set once a version warning was printed, so it is
+// printed once per run.
+var versionWarned atomic.Bool
+
+// checkServerVersion warns on stderr, once, when the server's protocol
+// differs from this CLI's; see protocol.CheckServer. A server that no
+// longer serves this CLI refuses its requests with the reason itself.
+func checkServerVersion(h http.Header) {
+	warning, _ := protocol.CheckServer(h)
+	if warning != "" && versionWarned.CompareAndSwap(false, true) {
+		fmt.Fprintln(os.Stderr, "warning:", warning)
+	}
+}
 
 func apiURL(base, path string) string {
 	return strings.TrimRight(base, "/") + path
 }
 
 func postMessage(server, room string, req protocol.SendRequest) (*protocol.Envelope, error) {
+	if err := roomKeys().Seal(room, &req); err != nil {
+		return nil, err
+	}
 	body, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("marshal: %w", err)
@@ -40,6 +85,7 @@ func postMessage(server, room string, req protocol.SendRequest) (*protocol.Envel
 	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
 		return nil, fmt.Errorf("decode response: %w", err)
 	}
+	roomKeys().Open(&env)
 	return &env, nil
 }
 
@@ -60,6 +106,7 @@ func getMessages(server, room string, after int64, limit int) (*protocol.Message
 	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
 		return nil, fmt.Errorf("decode response: %w", err)
 	}
+	roomKeys().OpenAll(list.Messages)
 	return &list, nil
 }
 
@@ -80,6 +127,7 @@ func getLatestMessages(server, room string, n int) (*protocol.MessageList, error
 	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
 		return nil, fmt.Errorf("decode response: %w", err)
 	}
+	roomKeys().OpenAll(list.Messages)
 	return &list, nil
 }
 
@@ -113,6 +161,83 @@ func getHealth(server string) (*protocol.HealthResponse, error) {
 	return &health, nil
 }
 
+// postJSON posts body as JSON to url and decodes a 2xx response into v,
+// unless v is nil.
+func postJSON(url string, body, v any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+	resp, err := httpClient.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("POST %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return fmt.Errorf("server returned %d: %s", resp.StatusCode, e.Error)
+	}
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// putJSON puts body as JSON to url and decodes a 200 OK response into v.
+func putJSON(url string, body, v any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("PUT %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return fmt.Errorf("server returned %d: %s", resp.StatusCode, e.Error)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// getJSON fetches url and decodes a JSON response into v.
+func getJSON(url string, v any) error {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return fmt.Errorf("GET %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return fmt.Errorf("server returned %d: %s", resp.StatusCode, e.Error)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
 // formatPlain formats an envelope for human-readable output.
 func formatPlain(env protocol.Envelope) string {
 	var b strings.Builder
@@ -123,6 +248,9 @@ func formatPlain(env protocol.Envelope) string {
 	if to := env.Metadata["to"]; to != "" {
 		fmt.Fprintf(&b, " → %s", to)
 	}
+	if protocol.MessagePriority(env.Metadata) == protocol.PriorityUrgent {
+		b.WriteString(" " + urgentTag)
+	}
 
 	switch env.Type {
 	case protocol.TypeText:
@@ -142,6 +270,12 @@ func formatPlain(env protocol.Envelope) string {
 			fmt.Fprintf(&b, " (%s)", env.Payload.FilePath)
 		}
 		fmt.Fprintf(&b, ":\n%s", env.Payload.Diff)
+	case protocol.TypeJSON:
+		fmt.Fprintf(&b, " shared data")
+		if env.Payload.Text != "" {
+			fmt.Fprintf(&b, " (%s)", env.Payload.Text)
+		}
+		fmt.Fprintf(&b, ": %s", env.Payload.Data)
 	case protocol.TypeSystem:
 		fmt.Fprintf(&b, " --- %s", env.Payload.Text)
 	default:
@@ -154,6 +288,11 @@ func formatPlain(env protocol.Envelope) string {
 	} else if env.Metadata["expecting_reply"] == "false" {
 		fmt.Fprintf(&b, " (conversation complete)")
 	}
+	if env.Metadata[protocol.MetaRespondedAt] != "" {
+		b.WriteString(" [replied]")
+	} else if env.Metadata[protocol.MetaDeliveredAt] != "" {
+		b.WriteString(" [delivered]")
+	}
 	if convID := env.Metadata["conv_id"]; convID != "" {
 		fmt.Fprintf(&b, " conv:%s", convID[:8])
 	}
@@ -175,6 +314,9 @@ var senderColors = []string{
 
 const ansiReset = "\033[0m"
 
+// urgentTag marks urgent messages; formatColor shows it in bold red.
+const urgentTag = "[URGENT]"
+
 // senderColor returns a deterministic ANSI color for a sender name.
 func senderColor(name string) string {
 	var h uint32
@@ -190,5 +332,8 @@ func formatColor(env protocol.Envelope) string {
 	color := senderColor(env.Sender)
 	// Replace first occurrence of sender name with colored version.
 	colored := strings.Replace(plain, "] "+env.Sender, "] "+color+env.Sender+ansiReset, 1)
+	if protocol.MessagePriority(env.Metadata) == protocol.PriorityUrgent {
+		colored = strings.Replace(colored, " "+urgentTag, " \033[1;31m"+urgentTag+ansiReset, 1)
+	}
 	return colored
 }
@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/spf13/cobra"
+)
+
+// completionClient asks the server for names to complete. A shell waits on
+// it at every Tab, so it gives up quickly.
+var completionClient = &http.Client{Timeout: 2 * time.Second, Transport: protocol.AuthTransport{}}
+
+func newCompletionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "completion bash|zsh|fish|powershell",
+		Short: "Print the shell completion script",
+		Long: `Prints a completion script for your shell. Besides commands and flags, it
+completes room names (-r) and participant names (converse --to, dm,
+participants) by asking the server, so the server and room must be known,
+from .claudetalk or flags typed earlier on the line.
+
+  bash:        source <(claudetalk completion bash)
+               # or: claudetalk completion bash > /etc/bash_completion.d/claudetalk
+  zsh:         claudetalk completion zsh > "${fpath[1]}/_claudetalk"
+  fish:        claudetalk completion fish > ~/.config/fish/completions/claudetalk.fish
+  powershell:  claudetalk completion powershell | Out-String | Invoke-Expression`,
+		ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+		Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := cmd.Root()
+			switch args[0] {
+			case "bash":
+				return root.GenBashCompletionV2(os.Stdout, true)
+			case "zsh":
+				return root.GenZshCompletion(os.Stdout)
+			case "fish":
+				return root.GenFishCompletion(os.Stdout, true)
+			default:
+				return root.GenPowerShellCompletionWithDesc(os.Stdout)
+			}
+		},
+	}
+}
+
+// completeRooms completes room names from the server's room list.
+func completeRooms(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var list protocol.RoomList
+	if err := completionGet("/api/rooms", &list); err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	names := make([]string, 0, len(list.Rooms))
+	for _, r := range list.Rooms {
+		names = append(names, fmt.Sprintf("%s\t%d connected, %d messages", r.Name, r.Clients, r.MessageCount))
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeParticipants completes the names of the room's participants and
+// the Claudes and agents their daemons answer as.
+func completeParticipants(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if flagRoom == "" {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var list protocol.ParticipantList
+	if err := completionGet(fmt.Sprintf("/api/rooms/%s/participants", url.PathEscape(flagRoom)), &list); err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	sortParticipants(list.Participants)
+	var names []string
+	for _, p := range list.Participants {
+		if p.Name == flagSender {
+			continue
+		}
+		names = append(names, p.Name+"\t"+participantStatus(p))
+		for _, name := range p.SpeaksFor {
+			names = append(names, fmt.Sprintf("%s\tanswered by %s", name, p.Name))
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeFirstParticipant completes a participant name as a command's
+// first argument, and nothing after it.
+func completeFirstParticipant(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeParticipants(cmd, args, toComplete)
+}
+
+// completionGet fetches path from the server with completionClient and
+// decodes the JSON response into v.
+func completionGet(path string, v any) error {
+	resp, err := completionClient.Get(apiURL(flagServer, path))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -12,16 +12,19 @@ import (
 
 func newConverseCmd() *cobra.Command {
 	var (
-		to     string
-		convID string
-		done   bool
+		to       string
+		convID   string
+		done     bool
+		priority string
+		out      outputFlags
 	)
 
 	cmd := &cobra.Command{
 		Use:   `converse [message]`,
 		Short: "Send a direct conversation message to another Claude",
 		Long: `Sends a message directed at a specific recipient with conversation tracking.
-Sets metadata for routing: to, conv_id, expecting_reply.
+Sets metadata for routing: to, conv_id, expecting_reply. Prints the conv_id,
+or with --format json the message as the server stored it.
 
 Examples:
   claudetalk converse --to kruz-claude "What is sessionStore used for?"
@@ -57,6 +60,9 @@ Examples:
 				"conv_id":         convID,
 				"expecting_reply": expectingReply,
 			}
+			if priority != "" {
+				metadata["priority"] = priority
+			}
 
 			req := protocol.SendRequest{
 				Sender:   flagSender,
@@ -70,15 +76,20 @@ Examples:
 				return err
 			}
 
-			// Print conv_id to stdout for Claude to capture.
-			fmt.Println(convID)
-
 			// Status to stderr.
-			fmt.Fprintf(os.Stderr, "sent conversation message #%d to %s in room %q\n", env.SeqNum, to, env.Room)
+			out.status("sent conversation message #%d to %s in room %q", env.SeqNum, to, env.Room)
 			if done {
-				fmt.Fprintf(os.Stderr, "conversation marked as complete\n")
+				out.status("conversation marked as complete")
+			}
+			if env.Warning != "" {
+				fmt.Fprintf(os.Stderr, "warning: %s\n", env.Warning)
 			}
 
+			if out.json() {
+				return printJSON(env)
+			}
+			// Print conv_id to stdout for Claude to capture.
+			fmt.Println(convID)
 			return nil
 		},
 	}
@@ -86,6 +97,9 @@ Examples:
 	cmd.Flags().StringVar(&to, "to", "", "recipient name (required)")
 	cmd.Flags().StringVar(&convID, "conv", "", "conversation ID (auto-generated if omitted)")
 	cmd.Flags().BoolVar(&done, "done", false, "mark conversation as complete (no reply expected)")
+	cmd.Flags().StringVar(&priority, "priority", "", "message priority: low, normal or urgent (urgent jumps the recipient's spawn queue)")
+	out.register(cmd, "print only the conv_id")
+	cmd.RegisterFlagCompletionFunc("to", completeParticipants)
 
 	return cmd
 }
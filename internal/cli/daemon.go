@@ -2,8 +2,12 @@ package cli
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/corvino/claudetalk/internal/daemon"
+	"github.com/corvino/claudetalk/internal/limits"
+	"github.com/corvino/claudetalk/internal/protocol"
 	"github.com/spf13/cobra"
 )
 
@@ -12,6 +16,26 @@ func newDaemonCmd() *cobra.Command {
 		claudeBin     string
 		workDir       string
 		maxConcurrent int
+		rooms         []string
+		claudeTimeout time.Duration
+		claudeLimits  limits.Limits
+		promptTmpl    string
+		filter        daemon.Filter
+		approve       bool
+		approver      string
+		mentions      bool
+		aliases       []string
+		configFile    string
+		priority      int
+		takeover      bool
+		helpRequests  string
+		model         string
+		description   string
+		availability  string
+		promptBudget  int
+		trimContext   bool
+		claudeName    string
+		agents        []string
 	)
 
 	cmd := &cobra.Command{
@@ -19,10 +43,27 @@ func newDaemonCmd() *cobra.Command {
 		Short: "Run as a background daemon that auto-spawns Claude when messages arrive",
 		Long: `Connects to the ClaudeTalk server via WebSocket in daemon mode. When a directed
 message arrives (converse --to <your-name>), the daemon automatically spawns a Claude Code
-instance with MCP tools to read and respond to messages.
+instance with MCP tools to read and respond to messages. With --mentions, a broadcast
+message that @-mentions your name (or an --alias) summons it too.
 
-Before running daemon, use "claudetalk join" to configure your .claudetalk file.`,
+Before running daemon, use "claudetalk join" to configure your .claudetalk file.
+To keep it running across reboots, use "claudetalk daemon install".
+
+With --config daemons.yaml, one process runs a daemon for every (room, name) entry in
+the file, each with its own work dir and limits. Other daemon flags are then ignored.
+
+With --agent, one daemon also runs named agents, e.g. "alice-reviewer" and
+"alice-builder": a message to an agent spawns a Claude that speaks as it, in its
+own work dir and, in the YAML file, with its own prompt template.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if configFile != "" {
+				cfgs, err := daemon.LoadConfigFile(configFile, flagServer, flagPassphrase)
+				if err != nil {
+					return err
+				}
+				return daemon.RunAll(cfgs)
+			}
+
 			if flagServer == "" {
 				return fmt.Errorf("server URL is required (use -s or .claudetalk config)")
 			}
@@ -33,20 +74,76 @@ Before running daemon, use "claudetalk join" to configure your .claudetalk file.
 				return fmt.Errorf("name is required (use -n or .claudetalk config)")
 			}
 
+			if err := claudeLimits.Validate(); err != nil {
+				return err
+			}
+			var named []daemon.Agent
+			for _, a := range agents {
+				name, dir, _ := strings.Cut(a, "=")
+				named = append(named, daemon.Agent{Name: name, WorkDir: dir})
+			}
+
 			return daemon.Run(daemon.Config{
-				ServerURL:     flagServer,
-				Room:          flagRoom,
-				Name:          flagSender,
-				ClaudeBin:     claudeBin,
-				WorkDir:       workDir,
-				MaxConcurrent: maxConcurrent,
+				ServerURL:      flagServer,
+				Room:           flagRoom,
+				Rooms:          rooms,
+				Name:           flagSender,
+				ClaudeBin:      claudeBin,
+				WorkDir:        workDir,
+				MaxConcurrent:  maxConcurrent,
+				Timeout:        claudeTimeout,
+				Limits:         claudeLimits,
+				PromptTemplate: promptTmpl,
+				Filter:         filter,
+				Approve:        approve || approver != "",
+				Approver:       approver,
+				Mentions:       mentions || len(aliases) > 0,
+				Aliases:        aliases,
+				Priority:       priority,
+				Takeover:       takeover,
+				HelpRequests:   helpRequests,
+				Passphrase:     flagPassphrase,
+				Model:          model,
+				Description:    description,
+				Availability:   availability,
+				PromptBudget:   promptBudget,
+				TrimContext:    trimContext,
+				ClaudeName:     claudeName,
+				Agents:         named,
 			})
 		},
 	}
 
-	cmd.Flags().StringVar(&claudeBin, "claude-bin", "claude", "path to claude binary")
+	cmd.Flags().StringVar(&configFile, "config", "", "YAML file listing several daemons to run in this process")
+	cmd.Flags().StringVar(&claudeBin, "claude-bin", "claude", "path to claude binary (\"fake\" for the built-in test stand-in; see fake-claude)")
 	cmd.Flags().StringVar(&workDir, "work-dir", "", "working directory for spawned Claude instances (default: current dir)")
 	cmd.Flags().IntVar(&maxConcurrent, "max-concurrent", 1, "max concurrent Claude instances")
+	cmd.Flags().StringSliceVar(&rooms, "rooms", nil, "additional rooms to watch over the same connection (comma-separated)")
+	cmd.Flags().DurationVar(&claudeTimeout, "claude-timeout", 0, "kill a spawned Claude after this long (0 = no limit)")
+	addLimitFlags(cmd, &claudeLimits)
+	cmd.Flags().IntVar(&promptBudget, "prompt-budget", protocol.DefaultPromptBudget, "bytes of recent messages in a spawn prompt; long code and diffs are cut and the oldest messages summarized to fit")
+	cmd.Flags().BoolVar(&trimContext, "trim-context", false, "have the server cut context payloads to what the prompt shows of them, saving bandwidth; Claude fetches the rest with get_messages")
+	cmd.Flags().StringVar(&promptTmpl, "prompt-template", "", "text/template file overriding sections of spawn prompts, e.g. {{define \"style\"}}...{{end}}, or replacing them (fields: .Name .Room .Charter .Reason .Trigger .ReplyTo .ConvID .Context .Summary .Participants .Group .Profiles .Urgent .Budget .Default)")
+
+	cmd.Flags().StringSliceVar(&filter.OnlyFrom, "only-from", nil, "only spawn for these senders (globs, e.g. \"alice,bob*\")")
+	cmd.Flags().StringSliceVar(&filter.IgnoreFrom, "ignore-from", nil, "never spawn for these senders (globs, e.g. \"*'s Claude\")")
+	cmd.Flags().StringSliceVar(&filter.ConvIDs, "only-conv", nil, "only spawn for these conversation IDs")
+	cmd.Flags().StringSliceVar(&filter.Keywords, "keyword", nil, "only spawn when the message contains one of these words (case-insensitive)")
+	cmd.Flags().StringSliceVar(&filter.Types, "only-type", nil, "only spawn for these message types (e.g. text,code)")
+	cmd.Flags().StringVar(&filter.Quiet, "quiet-hours", "", "do-not-disturb window in local time, e.g. 22:00-07:00")
+	cmd.Flags().BoolVar(&approve, "approve", false, "show each spawn request and prompt and wait for \"y <id>\" on stdin before launching Claude")
+	cmd.Flags().StringVar(&approver, "approver", "", "participant who may approve spawns remotely with a directed \"approve <id>\" or \"deny <id>\" (implies --approve)")
+	cmd.Flags().BoolVar(&mentions, "mentions", false, "also spawn when a broadcast message @-mentions your name")
+	cmd.Flags().StringSliceVar(&aliases, "alias", nil, "extra @handles that summon this daemon from broadcasts (implies --mentions)")
+	cmd.Flags().IntVar(&priority, "priority", daemon.DefaultPriority, "when another watcher (e.g. claudetalk web) uses the same name, the higher priority gets spawn events")
+	cmd.Flags().BoolVar(&takeover, "takeover", false, "take spawn events for your name from any other watcher")
+	cmd.Flags().StringVar(&helpRequests, "help-requests", "allow", "what to do when another Claude delegates a task with request_help: allow, approve or deny")
+	cmd.Flags().StringVar(&model, "model", "", "model for spawned Claudes (claude --model), also shown in your profile")
+	cmd.Flags().StringVar(&description, "description", configProfile.Description, "what you and your Claude work on, shown to the room (default from claudetalk join)")
+	cmd.Flags().StringVar(&availability, "availability", configProfile.Availability, "available, busy or away, shown to the room")
+	cmd.Flags().StringSliceVar(&agents, "agent", nil, "also run a named agent, as name or name=work-dir; messages to it spawn Claudes that speak as it (repeatable; prompt templates per agent via --config)")
+	cmd.Flags().StringVar(&claudeName, "claude-name", configClaudeName, "name spawned Claudes speak as instead of yours; messages to it spawn them too (default from claudetalk join)")
 
+	addDaemonServiceCmds(cmd)
 	return cmd
 }
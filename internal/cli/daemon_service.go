@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/corvino/claudetalk/internal/service"
+	"github.com/spf13/cobra"
+)
+
+// addDaemonServiceCmds adds install/start/stop/status/uninstall to the daemon command.
+func addDaemonServiceCmds(daemonCmd *cobra.Command) {
+	var name string
+
+	// serviceName picks the service to manage: --service-name, or one per room.
+	serviceName := func() (string, error) {
+		if name != "" {
+			return name, nil
+		}
+		if flagRoom == "" {
+			return "", fmt.Errorf("room is required (use -r or .claudetalk config)")
+		}
+		return service.Name(flagRoom), nil
+	}
+
+	install := &cobra.Command{
+		Use:   "install [-- daemon flags]",
+		Short: "Install the daemon as a service that starts at login",
+		Long: `Registers "claudetalk daemon" with the system service manager (systemd on Linux,
+launchd on macOS, Task Scheduler on Windows) and starts it. The current server,
+room, name and directory are baked in; pass extra daemon flags after "--", e.g.
+
+  claudetalk daemon install -- --mentions --quiet-hours 22:00-07:00`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flagServer == "" {
+				return fmt.Errorf("server URL is required (use -s or .claudetalk config)")
+			}
+			if flagSender == "" {
+				return fmt.Errorf("name is required (use -n or .claudetalk config)")
+			}
+			svc, err := serviceName()
+			if err != nil {
+				return err
+			}
+			exe, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("find claudetalk binary: %w", err)
+			}
+			if exe, err = filepath.EvalSymlinks(exe); err != nil {
+				return fmt.Errorf("find claudetalk binary: %w", err)
+			}
+			wd, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+
+			spec := service.Spec{
+				Name: svc,
+				Exec: exe,
+				Args: append([]string{
+					"daemon",
+					"--server", flagServer,
+					"--room", flagRoom,
+					"--name", flagSender,
+					"--work-dir", wd,
+				}, args...),
+				WorkDir: wd,
+			}
+			path, err := service.Install(spec)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("installed %s (%s)\n", svc, path)
+			if notes := service.Notes(svc); notes != "" {
+				fmt.Println(notes)
+			}
+			return nil
+		},
+	}
+
+	simple := func(use, short string, fn func(string) error) *cobra.Command {
+		return &cobra.Command{
+			Use:   use,
+			Short: short,
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				svc, err := serviceName()
+				if err != nil {
+					return err
+				}
+				return fn(svc)
+			},
+		}
+	}
+
+	cmds := []*cobra.Command{
+		install,
+		simple("start", "Start the installed daemon service", service.Start),
+		simple("stop", "Stop the daemon service (it starts again at next login)", service.Stop),
+		simple("status", "Show whether the daemon service is running", service.Status),
+		simple("uninstall", "Stop the daemon service and remove it", func(svc string) error {
+			if err := service.Uninstall(svc); err != nil {
+				return err
+			}
+			fmt.Printf("uninstalled %s\n", svc)
+			return nil
+		}),
+	}
+	for _, c := range cmds {
+		c.Flags().StringVar(&name, "service-name", "", "service to manage (default: claudetalk-daemon-<room>)")
+		daemonCmd.AddCommand(c)
+	}
+}
@@ -14,6 +14,8 @@ func newDigestCmd() *cobra.Command {
 		outputFile string
 		latest     int
 		after      int64
+		format     string
+		tmplPath   string
 	)
 
 	cmd := &cobra.Command{
@@ -27,7 +29,9 @@ Examples:
   claudetalk digest                          # Save latest 50 messages to claudetalk-digest.md
   claudetalk digest -o session-notes.md      # Custom output file
   claudetalk digest --latest 100             # Save latest 100 messages
-  claudetalk digest --after 25               # Save messages after seq #25`,
+  claudetalk digest --after 25               # Save messages after seq #25
+  claudetalk digest --format html            # Built-in formats: markdown, json, html, slack
+  claudetalk digest --template report.tmpl   # Render with your own Go template`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if flagRoom == "" {
 				return fmt.Errorf("room is required (use -r or CLAUDETALK_ROOM)")
@@ -50,11 +54,25 @@ Examples:
 				return nil
 			}
 
-			// Build markdown content.
-			content := synopsis.Build(list.Room, list.Messages)
+			// Build content from a user template or a built-in format.
+			var content string
+			if tmplPath != "" {
+				content, err = synopsis.RenderTemplateFile(tmplPath, list.Room, list.Messages)
+			} else {
+				content, err = synopsis.Render(format, list.Room, list.Messages)
+			}
+			if err != nil {
+				return err
+			}
+
+			if !cmd.Flags().Changed("output") && tmplPath == "" {
+				outputFile = "claudetalk-digest" + synopsis.Extension(format)
+			}
 
-			// Write or append to file.
-			if err := writeDigestFile(outputFile, content); err != nil {
+			// Markdown digests accumulate in one file; other formats are overwritten
+			// since concatenating them would produce an invalid document.
+			appendMode := tmplPath == "" && synopsis.Extension(format) == ".md"
+			if err := writeDigestFile(outputFile, content, appendMode); err != nil {
 				return fmt.Errorf("write %s: %w", outputFile, err)
 			}
 
@@ -66,13 +84,15 @@ Examples:
 	cmd.Flags().StringVarP(&outputFile, "output", "o", "claudetalk-digest.md", "output file path")
 	cmd.Flags().IntVar(&latest, "latest", 50, "number of latest messages to include")
 	cmd.Flags().Int64Var(&after, "after", 0, "include messages after this sequence number (overrides --latest)")
+	cmd.Flags().StringVar(&format, "format", "markdown", "output format: markdown, json, html, slack")
+	cmd.Flags().StringVar(&tmplPath, "template", "", "Go text/template file to render instead of a built-in format")
 
 	return cmd
 }
 
-func writeDigestFile(path, content string) error {
+func writeDigestFile(path, content string, appendMode bool) error {
 	// If file exists, append with a separator.
-	if existing, err := os.ReadFile(path); err == nil {
+	if existing, err := os.ReadFile(path); err == nil && appendMode {
 		content = string(existing) + "\n\n---\n\n" + content
 	}
 	return os.WriteFile(path, []byte(content), 0644)
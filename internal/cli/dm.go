@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/spf13/cobra"
+)
+
+func newDMCmd() *cobra.Command {
+	var (
+		noReply bool
+		latest  int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "dm [name] [message]",
+		Short: "Send or read direct messages",
+		Long: `Direct messages go to a room only you and the other person (and your
+Claudes) use, named dm:<a>:<b> and kept out of room listings. The server
+creates it on first use.
+
+  claudetalk dm alice "can you look at the flaky test?"   send
+  claudetalk dm alice                                      read the latest
+  claudetalk dm                                            list your DMs
+
+A message expects a reply unless --no-reply is given, so alice's daemon
+spawns her Claude for it, whichever room that daemon watches.`,
+		Args:              cobra.ArbitraryArgs,
+		ValidArgsFunction: completeFirstParticipant,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flagSender == "" {
+				return fmt.Errorf("sender name is required (use -n or CLAUDETALK_SENDER)")
+			}
+			if len(args) == 0 {
+				return listDMs()
+			}
+			room, err := protocol.DMRoom(flagSender, args[0])
+			if err != nil {
+				return err
+			}
+			if len(args) == 1 {
+				list, err := getLatestMessages(flagServer, room, latest)
+				if err != nil {
+					return err
+				}
+				if len(list.Messages) == 0 {
+					fmt.Printf("no messages with %s yet\n", args[0])
+				}
+				for _, env := range list.Messages {
+					fmt.Println(formatPlain(env))
+				}
+				return nil
+			}
+
+			req := protocol.SendRequest{
+				Sender:   flagSender,
+				Type:     protocol.TypeText,
+				Payload:  protocol.NewTextPayload(strings.Join(args[1:], " ")),
+				Metadata: map[string]string{"to": args[0], "expecting_reply": fmt.Sprint(!noReply)},
+			}
+			env, err := postMessage(flagServer, room, req)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "sent message #%d to %s\n", env.SeqNum, args[0])
+			if env.Warning != "" {
+				fmt.Fprintf(os.Stderr, "warning: %s\n", env.Warning)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&noReply, "no-reply", false, "don't ask for a reply, so no Claude is spawned for it")
+	cmd.Flags().IntVar(&latest, "latest", 20, "how many messages to show when reading")
+	return cmd
+}
+
+// listDMs prints the direct message rooms flagSender is in.
+func listDMs() error {
+	var list protocol.RoomList
+	if err := getJSON(apiURL(flagServer, "/api/dms?sender="+url.QueryEscape(flagSender)), &list); err != nil {
+		return err
+	}
+	if len(list.Rooms) == 0 {
+		fmt.Println("no direct messages")
+		return nil
+	}
+	fmt.Printf("%-20s %8s %8s\n", "WITH", "MSGS", "LAST SEQ")
+	for _, r := range list.Rooms {
+		peer, _ := protocol.DMPeer(r.Name, flagSender)
+		fmt.Printf("%-20s %8d %8d\n", peer, r.MessageCount, r.LastSeq)
+	}
+	return nil
+}
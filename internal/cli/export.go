@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/spf13/cobra"
+)
+
+// transferClient moves whole rooms, files included, so it allows far longer
+// than httpClient.
+var transferClient = &http.Client{Timeout: 10 * time.Minute, Transport: protocol.AuthTransport{}}
+
+func newExportCmd() *cobra.Command {
+	var outputFile string
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Save a room's full history and shared files to an NDJSON file",
+		Long: `Downloads everything the server holds for a room: every message in seq
+order with its metadata, and every shared file. Restore it on this or another
+server with "claudetalk import".
+
+Encrypted rooms export as ciphertext; the passphrase is not included.
+
+Examples:
+  claudetalk export                      # Write <room>.ndjson
+  claudetalk export -r backend -o b.ndjson
+  claudetalk export -o - | gzip > backup.ndjson.gz`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flagRoom == "" {
+				return fmt.Errorf("room is required (use -r or CLAUDETALK_ROOM)")
+			}
+			if outputFile == "" {
+				outputFile = flagRoom + ".ndjson"
+			}
+
+			u := apiURL(flagServer, fmt.Sprintf("/api/rooms/%s/export", url.PathEscape(flagRoom)))
+			resp, err := transferClient.Get(u)
+			if err != nil {
+				return fmt.Errorf("GET %s: %w", u, err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				b, _ := io.ReadAll(resp.Body)
+				return fmt.Errorf("server returned %d: %s", resp.StatusCode, string(b))
+			}
+
+			out := os.Stdout
+			if outputFile != "-" {
+				f, err := os.Create(outputFile)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				out = f
+			}
+			n, err := io.Copy(out, resp.Body)
+			if err != nil {
+				return fmt.Errorf("write %s: %w", outputFile, err)
+			}
+			if outputFile != "-" {
+				fmt.Fprintf(os.Stderr, "exported room %q to %s (%d bytes)\n", flagRoom, outputFile, n)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "", `output file path, or "-" for stdout (default: <room>.ndjson)`)
+
+	return cmd
+}
+
+func newImportCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "import <file>",
+		Short: "Load a room export into a new room",
+		Long: `Uploads a file written by "claudetalk export" into the room given with
+--room, which must not have any messages yet. Messages keep their seq numbers,
+IDs, timestamps and metadata, so replies and file references still resolve.
+Use "-" to read the export from stdin.
+
+Examples:
+  claudetalk import backend.ndjson --room backend-archive
+  gunzip -c backup.ndjson.gz | claudetalk import - -s https://new.example.com -r backend`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flagRoom == "" {
+				return fmt.Errorf("room is required (use -r or CLAUDETALK_ROOM)")
+			}
+
+			in := os.Stdin
+			if args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				in = f
+			}
+
+			u := apiURL(flagServer, fmt.Sprintf("/api/rooms/%s/import", url.PathEscape(flagRoom)))
+			resp, err := transferClient.Post(u, "application/x-ndjson", in)
+			if err != nil {
+				return fmt.Errorf("POST %s: %w", u, err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusCreated {
+				b, _ := io.ReadAll(resp.Body)
+				return fmt.Errorf("server returned %d: %s", resp.StatusCode, string(b))
+			}
+
+			var res protocol.ImportResult
+			if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+				return fmt.Errorf("decode response: %w", err)
+			}
+			fmt.Printf("imported %d messages and %d files into room %q (last seq #%d)\n", res.Messages, res.Files, res.Room, res.LastSeq)
+			return nil
+		},
+	}
+}
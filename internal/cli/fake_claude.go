@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/fakeclaude"
+	"github.com/spf13/cobra"
+)
+
+func newFakeClaudeCmd() *cobra.Command {
+	var (
+		opts    fakeclaude.Options
+		version bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "fake-claude [prompt]",
+		Short: "Stand-in for the claude CLI in tests; answers spawns without calling a model",
+		Long: `Takes the claude CLI's arguments, reads the prompt (from the arguments or
+stdin) and answers through the MCP tools in --mcp-config with a canned reply:
+converse on directed messages and help requests, a broadcast for @-mentions,
+and a whisper to the owner otherwise.
+
+Daemons, "claudetalk host" and "claudetalk web" run it in place of claude when
+given --claude-bin fake, so the spawn pipeline can be tested in CI without
+Anthropic credentials. Spawned fakes are configured through the environment:
+
+  CLAUDETALK_FAKE_CLAUDE   behavior: reply (default), chat, echo, silent, fail, hang
+  CLAUDETALK_FAKE_DELAY    pause before answering, e.g. 2s
+
+reply ends the conversation with done=true; chat leaves it open, so two fakes
+keep answering each other, as a runaway Claude-to-Claude thread would.`,
+		Args: cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if version {
+				fmt.Println(fakeclaude.Version)
+				return nil
+			}
+			if opts.Behavior == "" {
+				opts.Behavior = os.Getenv(fakeclaude.BehaviorEnv)
+			}
+			if d := os.Getenv(fakeclaude.DelayEnv); d != "" && opts.Delay == 0 {
+				var err error
+				if opts.Delay, err = time.ParseDuration(d); err != nil {
+					return fmt.Errorf("%s: %w", fakeclaude.DelayEnv, err)
+				}
+			}
+
+			prompt := strings.Join(args, " ")
+			if prompt == "" {
+				data, err := io.ReadAll(os.Stdin)
+				if err != nil {
+					return fmt.Errorf("read prompt: %w", err)
+				}
+				prompt = string(data)
+			}
+			return fakeclaude.Run(cmd.Context(), opts, prompt, os.Stdout)
+		},
+	}
+	// Accept whatever else a newer spawner passes, as claude would.
+	cmd.FParseErrWhitelist.UnknownFlags = true
+
+	f := cmd.Flags()
+	f.StringVar(&opts.MCPConfig, "mcp-config", "", "MCP server config, as for claude")
+	f.StringVar(&opts.OutputFormat, "output-format", "text", "text or stream-json")
+	f.StringVar(&opts.ResumeID, "resume", "", "session to continue; reused as the session ID")
+	f.StringVar(&opts.Behavior, "behavior", "", "canned behavior (default $"+fakeclaude.BehaviorEnv+" or reply)")
+	f.DurationVar(&opts.Delay, "delay", 0, "pause before answering (default $"+fakeclaude.DelayEnv+")")
+	f.BoolVar(&version, "version", false, "print the fake's version")
+	// Flags the claude CLI takes that the fake ignores.
+	f.BoolP("print", "p", false, "ignored")
+	f.Bool("verbose", false, "ignored")
+	f.Bool("dangerously-skip-permissions", false, "ignored")
+	f.String("model", "", "ignored")
+	f.Int("max-turns", 0, "ignored")
+	f.String("allowedTools", "", "ignored")
+	f.String("permission-mode", "", "ignored")
+
+	return cmd
+}
@@ -0,0 +1,474 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/corvino/claudetalk/internal/sharedir"
+	"github.com/spf13/cobra"
+)
+
+func newFilesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "files",
+		Short: "List, download, upload and remove the room's shared files",
+		Long: `Works with the files shared in a room, as the MCP file tools and the web UI
+do. get and rm take a file's ID, a unique prefix of it, or its filename.
+
+Examples:
+  claudetalk files list
+  claudetalk files put -d "failing run" logs/*.log
+  claudetalk files get 3f2a -o run.log
+  claudetalk files get --dir ./shared     # every file in the room
+  claudetalk files rm run.log`,
+	}
+	cmd.AddCommand(newFilesListCmd(), newFilesGetCmd(), newFilesPutCmd(), newFilesRmCmd())
+	return cmd
+}
+
+func newFilesListCmd() *cobra.Command {
+	var out outputFlags
+
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List the room's shared files",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flagRoom == "" {
+				return fmt.Errorf("room is required (use -r or CLAUDETALK_ROOM)")
+			}
+			list, err := listFiles()
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case out.json():
+				return printJSON(list)
+			case out.quiet:
+				for _, f := range list.Files {
+					fmt.Println(f.ID)
+				}
+				return nil
+			case list.Count == 0:
+				fmt.Println("no files")
+				return nil
+			}
+
+			fmt.Printf("%-8s %8s %5s  %-16s %-32s %s\n", "ID", "SIZE", "AGE", "SENDER", "NAME", "DESCRIPTION")
+			for _, f := range list.Files {
+				fmt.Printf("%-8s %8s %5s  %-16s %-32s %s\n",
+					f.ID[:8],
+					byteSize(f.Size),
+					age(time.Since(f.Timestamp)),
+					truncate(f.Sender, 16),
+					truncate(f.Filename, 32),
+					truncate(f.Description, 40))
+			}
+			return nil
+		},
+	}
+
+	out.register(cmd, "print only file IDs")
+	return cmd
+}
+
+func newFilesGetCmd() *cobra.Command {
+	var (
+		output string
+		dir    string
+		quiet  bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "get [file...]",
+		Short: "Download shared files",
+		Long: `Downloads files by ID, ID prefix or filename into the current directory under
+their own names. -o saves a single file elsewhere, or writes it to stdout with
+"-o -". --dir saves into that directory instead, and with no files named,
+downloads every file in the room; files that share a name get their ID added.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flagRoom == "" {
+				return fmt.Errorf("room is required (use -r or CLAUDETALK_ROOM)")
+			}
+			if len(args) == 0 && dir == "" {
+				return fmt.Errorf("name a file to download, or use --dir for all of them")
+			}
+			if output != "" && (len(args) != 1 || dir != "") {
+				return fmt.Errorf("-o takes exactly one file, and not --dir")
+			}
+			list, err := listFiles()
+			if err != nil {
+				return err
+			}
+
+			files := list.Files
+			if len(args) > 0 {
+				if files, err = resolveFiles(list.Files, args); err != nil {
+					return err
+				}
+			}
+			if output != "" {
+				return downloadFile(files[0], output)
+			}
+			if len(files) == 0 {
+				if !quiet {
+					fmt.Fprintf(os.Stderr, "no files in room %q\n", flagRoom)
+				}
+				return nil
+			}
+			if dir != "" {
+				if err := os.MkdirAll(dir, 0755); err != nil {
+					return err
+				}
+			}
+
+			names := savedNames(files)
+			for _, f := range files {
+				path := filepath.Join(dir, names[f.ID])
+				if err := downloadFile(f, path); err != nil {
+					return err
+				}
+				if !quiet {
+					fmt.Fprintf(os.Stderr, "saved %s (%s)\n", path, byteSize(f.Size))
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "where to save a single file (- for stdout)")
+	cmd.Flags().StringVar(&dir, "dir", "", "directory to save into; with no files named, download them all")
+	cmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "don't print the files saved")
+	return cmd
+}
+
+func newFilesPutCmd() *cobra.Command {
+	var (
+		description string
+		out         outputFlags
+		dir         string
+		share       sharedir.Options
+		maxMB       float64
+		dryRun      bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "put <file|glob>... | --dir <dir>",
+		Short: "Upload files to share with the room",
+		Long: `Uploads files, announcing each in the room as the MCP send_file tool does.
+Patterns are expanded here too, so a quoted glob like "logs/*.log" works in
+any shell; directories are skipped. Prints each file's ID, name and size, or
+with --format json the list of files uploaded.
+
+--dir uploads the files of a directory instead, as the MCP send_directory
+tool does: with -R those in its subdirectories too, skipping what .gitignore
+ignores, .git, node_modules and likely secrets (.env, keys, .claudetalk).
+--include and --exclude take .gitignore-style patterns, and may be repeated.
+It refuses more than --max-files files or --max-mb megabytes at once;
+--dry-run lists what would be uploaded.
+
+  claudetalk files put --dir src -R --include '*.go' --exclude '*_test.go'`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if dir != "" && len(args) > 0 {
+				return fmt.Errorf("give files or --dir, not both")
+			}
+			if dir == "" && len(args) == 0 {
+				return fmt.Errorf("give files to upload, or --dir")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var paths []string
+			if dir != "" {
+				share.MaxBytes = int64(maxMB * (1 << 20))
+				files, err := sharedir.Select(dir, share)
+				if err != nil {
+					return err
+				}
+				if len(files) == 0 {
+					return fmt.Errorf("no files to upload in %s: it is empty, or everything in it is ignored or excluded", dir)
+				}
+				if dryRun {
+					fmt.Print(sharedir.Describe(files))
+					return sharedir.Check(files, share)
+				}
+				if err := sharedir.Check(files, share); err != nil {
+					return err
+				}
+				for _, f := range files {
+					paths = append(paths, f.Path)
+				}
+			}
+			if flagRoom == "" {
+				return fmt.Errorf("room is required (use -r or CLAUDETALK_ROOM)")
+			}
+			if flagSender == "" {
+				return fmt.Errorf("sender name is required (use -n or CLAUDETALK_SENDER)")
+			}
+
+			for _, arg := range args {
+				matches, err := filepath.Glob(arg)
+				if err != nil {
+					return fmt.Errorf("bad pattern %q: %w", arg, err)
+				}
+				if len(matches) == 0 {
+					return fmt.Errorf("no such file: %s", arg)
+				}
+				paths = append(paths, matches...)
+			}
+
+			uploaded := protocol.FileList{Room: flagRoom, Files: []protocol.FileInfo{}}
+			for _, path := range paths {
+				if st, err := os.Stat(path); err == nil && st.IsDir() {
+					out.status("skipping directory %s", path)
+					continue
+				}
+				info, err := uploadFile(path, description)
+				if err != nil {
+					return fmt.Errorf("upload %s: %w", path, err)
+				}
+				uploaded.Files = append(uploaded.Files, *info)
+				uploaded.Count++
+				switch {
+				case out.json():
+				case out.quiet:
+					fmt.Println(info.ID)
+				default:
+					fmt.Printf("%s %s (%s)\n", info.ID, info.Filename, byteSize(info.Size))
+				}
+			}
+			if out.json() {
+				return printJSON(uploaded)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&description, "description", "d", "", "what the files are, shown with them")
+	cmd.Flags().StringVar(&dir, "dir", "", "upload the files of this directory")
+	cmd.Flags().BoolVarP(&share.Recursive, "recursive", "R", false, "with --dir, include subdirectories")
+	cmd.Flags().StringArrayVar(&share.Include, "include", nil, "with --dir, only files matching this pattern")
+	cmd.Flags().StringArrayVar(&share.Exclude, "exclude", nil, "with --dir, skip files matching this pattern")
+	cmd.Flags().BoolVar(&share.NoIgnore, "no-ignore", false, "with --dir, don't skip what .gitignore ignores or likely secrets")
+	cmd.Flags().IntVar(&share.MaxFiles, "max-files", sharedir.DefaultMaxFiles, "with --dir, refuse more files than this")
+	cmd.Flags().Float64Var(&maxMB, "max-mb", sharedir.DefaultMaxBytes>>20, "with --dir, refuse more megabytes than this in all")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "with --dir, list what would be uploaded without uploading")
+	out.register(cmd, "print only file IDs")
+	return cmd
+}
+
+func newFilesRmCmd() *cobra.Command {
+	var out outputFlags
+
+	cmd := &cobra.Command{
+		Use:   "rm <file>...",
+		Short: "Remove shared files from the room",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flagRoom == "" {
+				return fmt.Errorf("room is required (use -r or CLAUDETALK_ROOM)")
+			}
+			list, err := listFiles()
+			if err != nil {
+				return err
+			}
+			files, err := resolveFiles(list.Files, args)
+			if err != nil {
+				return err
+			}
+
+			removed := protocol.FileList{Room: flagRoom, Files: []protocol.FileInfo{}}
+			for _, f := range files {
+				u := apiURL(flagServer, fmt.Sprintf("/api/rooms/%s/files/%s", url.PathEscape(flagRoom), url.PathEscape(f.ID)))
+				req, err := http.NewRequest(http.MethodDelete, u, nil)
+				if err != nil {
+					return err
+				}
+				resp, err := httpClient.Do(req)
+				if err != nil {
+					return fmt.Errorf("DELETE %s: %w", u, err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("remove %s: server returned %d", f.Filename, resp.StatusCode)
+				}
+				removed.Files = append(removed.Files, f)
+				removed.Count++
+				out.status("removed %s (%s)", f.Filename, f.ID[:8])
+			}
+			if out.json() {
+				return printJSON(removed)
+			}
+			return nil
+		},
+	}
+	out.register(cmd, "don't print the files removed")
+	return cmd
+}
+
+func listFiles() (*protocol.FileList, error) {
+	var list protocol.FileList
+	if err := getJSON(apiURL(flagServer, fmt.Sprintf("/api/rooms/%s/files", url.PathEscape(flagRoom))), &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// resolveFiles finds the file each arg names: by ID, unique ID prefix, or
+// filename, the newest upload if several share it.
+func resolveFiles(files []protocol.FileInfo, args []string) ([]protocol.FileInfo, error) {
+	var out []protocol.FileInfo
+	for _, arg := range args {
+		var byPrefix, byName []protocol.FileInfo
+		for _, f := range files {
+			if strings.HasPrefix(f.ID, arg) {
+				byPrefix = append(byPrefix, f)
+			}
+			if f.Filename == arg {
+				byName = append(byName, f)
+			}
+		}
+		switch {
+		case len(byPrefix) == 1:
+			out = append(out, byPrefix[0])
+		case len(byName) > 0:
+			out = append(out, byName[len(byName)-1]) // listed oldest first
+		case len(byPrefix) > 1:
+			return nil, fmt.Errorf("%q matches %d file IDs; give more of the ID", arg, len(byPrefix))
+		default:
+			return nil, fmt.Errorf("no file %q in room %q", arg, flagRoom)
+		}
+	}
+	return out, nil
+}
+
+// savedNames picks the name each file is saved under: its filename, with a
+// short ID added when several files share it.
+func savedNames(files []protocol.FileInfo) map[string]string {
+	count := make(map[string]int)
+	for _, f := range files {
+		count[filepath.Base(f.Filename)]++
+	}
+	names := make(map[string]string, len(files))
+	for _, f := range files {
+		name := filepath.Base(f.Filename)
+		if count[name] > 1 {
+			ext := filepath.Ext(name)
+			name = strings.TrimSuffix(name, ext) + "-" + f.ID[:8] + ext
+		}
+		names[f.ID] = name
+	}
+	return names
+}
+
+// downloadFile saves f's contents to path, or writes them to stdout for "-".
+func downloadFile(f protocol.FileInfo, path string) error {
+	u := apiURL(flagServer, fmt.Sprintf("/api/rooms/%s/files/%s", url.PathEscape(flagRoom), url.PathEscape(f.ID)))
+	resp, err := transferClient.Get(u)
+	if err != nil {
+		return fmt.Errorf("GET %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("download %s: server returned %d: %s", f.Filename, resp.StatusCode, string(b))
+	}
+
+	if path == "-" {
+		_, err := io.Copy(os.Stdout, resp.Body)
+		return err
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		out.Close()
+		return fmt.Errorf("save %s: %w", f.Filename, err)
+	}
+	return out.Close()
+}
+
+// uploadFile shares the file at path, streaming it rather than buffering
+// the whole form.
+func uploadFile(path, description string) (*protocol.FileInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	pr, pw := io.Pipe()
+	w := multipart.NewWriter(pw)
+	go func() {
+		w.WriteField("sender", flagSender)
+		if description != "" {
+			w.WriteField("description", description)
+		}
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%q`, filepath.Base(path)))
+		contentType := mime.TypeByExtension(filepath.Ext(path))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		h.Set("Content-Type", contentType)
+		fw, err := w.CreatePart(h)
+		if err == nil {
+			_, err = io.Copy(fw, f)
+		}
+		if err == nil {
+			err = w.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	u := apiURL(flagServer, fmt.Sprintf("/api/rooms/%s/files", url.PathEscape(flagRoom)))
+	resp, err := transferClient.Post(u, w.FormDataContentType(), pr)
+	if err != nil {
+		return nil, fmt.Errorf("POST %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		var e struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return nil, fmt.Errorf("server returned %d: %s", resp.StatusCode, e.Error)
+	}
+
+	var info protocol.FileInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	return &info, nil
+}
+
+// byteSize renders n bytes in the largest unit that keeps it at least 1,
+// e.g. "512B", "4.2K" or "1.3M".
+func byteSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	v, suffix := float64(n)/unit, "K"
+	for _, s := range []string{"M", "G"} {
+		if v < unit {
+			break
+		}
+		v, suffix = v/unit, s
+	}
+	return fmt.Sprintf("%.1f%s", v, suffix)
+}
@@ -0,0 +1,261 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// pollHookEvents are the Claude Code hook events install-hooks runs
+// "claudetalk poll" on. Whatever a SessionStart or UserPromptSubmit hook
+// prints is added to Claude's context, so new messages reach Claude at the
+// start of a session and before every prompt, whether or not it follows
+// CLAUDE.md.
+var pollHookEvents = []string{"SessionStart", "UserPromptSubmit"}
+
+// hookSettingsPath is the Claude Code settings file for scope: local (this
+// project, just for you), project (this project, checked in) or user (every
+// project).
+func hookSettingsPath(scope string) (string, error) {
+	switch scope {
+	case "local":
+		return filepath.Join(".claude", "settings.local.json"), nil
+	case "project":
+		return filepath.Join(".claude", "settings.json"), nil
+	case "user":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, ".claude", "settings.json"), nil
+	}
+	return "", fmt.Errorf("unknown --scope %q (use local, project or user)", scope)
+}
+
+func newInstallHooksCmd() *cobra.Command {
+	var scope string
+
+	cmd := &cobra.Command{
+		Use:   "install-hooks",
+		Short: "Make Claude Code poll for messages through hooks",
+		Long: `Adds hooks to Claude Code's settings that run "claudetalk poll" when a
+session starts and whenever you submit a prompt. What poll prints goes into
+Claude's context, so Claude sees new messages without having to remember
+the instruction in CLAUDE.md.
+
+--scope picks the settings file:
+  local    .claude/settings.local.json, this project, just for you (default)
+  project  .claude/settings.json, this project, for everyone who checks it out
+  user     ~/.claude/settings.json, every project
+
+Other settings and hooks in the file are kept. Running it again changes
+nothing; "claudetalk uninstall-hooks" removes the hooks.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := hookSettingsPath(scope)
+			if err != nil {
+				return err
+			}
+			command, err := pollHookCommand(scope)
+			if err != nil {
+				return err
+			}
+			settings, err := readHookSettings(path)
+			if err != nil {
+				return err
+			}
+			hooks, _ := settings["hooks"].(map[string]any)
+			if hooks == nil {
+				hooks = make(map[string]any)
+			}
+			var added []string
+			for _, event := range pollHookEvents {
+				groups, _ := hooks[event].([]any)
+				if hasPollHook(groups, command) {
+					continue
+				}
+				hooks[event] = append(groups, map[string]any{
+					"hooks": []any{map[string]any{"type": "command", "command": command}},
+				})
+				added = append(added, event)
+			}
+			if len(added) == 0 {
+				fmt.Printf("%s already polls claudetalk\n", path)
+				return nil
+			}
+			settings["hooks"] = hooks
+			if err := writeHookSettings(path, settings); err != nil {
+				return err
+			}
+			fmt.Printf("added %s hooks running %q to %s\n", strings.Join(added, " and "), command, path)
+			if scope == "user" {
+				fmt.Println("note: poll needs a room; in projects without a .claudetalk, set a current profile (claudetalk profile use)")
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&scope, "scope", "local", "settings to change: local, project, user")
+	return cmd
+}
+
+func newUninstallHooksCmd() *cobra.Command {
+	var scope string
+
+	cmd := &cobra.Command{
+		Use:   "uninstall-hooks",
+		Short: "Remove the hooks install-hooks added",
+		Long: `Removes the "claudetalk poll" hooks from Claude Code's settings, leaving
+everything else in the file. --scope picks the file, as for install-hooks.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := hookSettingsPath(scope)
+			if err != nil {
+				return err
+			}
+			command, err := pollHookCommand(scope)
+			if err != nil {
+				return err
+			}
+			settings, err := readHookSettings(path)
+			if err != nil {
+				return err
+			}
+			hooks, _ := settings["hooks"].(map[string]any)
+			removed := 0
+			for event, v := range hooks {
+				groups, _ := v.([]any)
+				kept := groups[:0:0]
+				for _, g := range groups {
+					if n := removePollHooks(g, command); n > 0 {
+						removed += n
+						if group, _ := g.(map[string]any); len(anySlice(group["hooks"])) == 0 {
+							continue
+						}
+					}
+					kept = append(kept, g)
+				}
+				if len(kept) == 0 {
+					delete(hooks, event)
+				} else {
+					hooks[event] = kept
+				}
+			}
+			if removed == 0 {
+				fmt.Printf("no claudetalk hooks in %s\n", path)
+				return nil
+			}
+			if len(hooks) == 0 {
+				delete(settings, "hooks")
+			}
+			if err := writeHookSettings(path, settings); err != nil {
+				return err
+			}
+			fmt.Printf("removed %d claudetalk hook(s) from %s\n", removed, path)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&scope, "scope", "local", "settings to change: local, project, user")
+	return cmd
+}
+
+// pollHookCommand is the command the hooks run. Settings only you use name
+// this binary by its full path, as hooks may not run with your PATH; checked
+// in settings name it "claudetalk", for the binary on each user's PATH.
+func pollHookCommand(scope string) (string, error) {
+	if scope == "project" {
+		return "claudetalk poll", nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("find claudetalk binary: %w", err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return "", fmt.Errorf("find claudetalk binary: %w", err)
+	}
+	if strings.ContainsAny(exe, " \t'\"") {
+		exe = `"` + exe + `"`
+	}
+	return exe + " poll", nil
+}
+
+// isPollHook reports whether hook is one install-hooks added: it runs
+// command, or another claudetalk binary's poll.
+func isPollHook(hook any, command string) bool {
+	h, _ := hook.(map[string]any)
+	c, _ := h["command"].(string)
+	return c == command || strings.Contains(c, "claudetalk") && strings.HasSuffix(c, " poll")
+}
+
+// hasPollHook reports whether any of an event's hook groups runs poll.
+func hasPollHook(groups []any, command string) bool {
+	for _, g := range groups {
+		group, _ := g.(map[string]any)
+		for _, h := range anySlice(group["hooks"]) {
+			if isPollHook(h, command) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// removePollHooks drops the poll hooks from a hook group, returning how
+// many it dropped.
+func removePollHooks(g any, command string) int {
+	group, _ := g.(map[string]any)
+	hooks := anySlice(group["hooks"])
+	kept := hooks[:0:0]
+	for _, h := range hooks {
+		if !isPollHook(h, command) {
+			kept = append(kept, h)
+		}
+	}
+	if n := len(hooks) - len(kept); n > 0 {
+		group["hooks"] = kept
+		return n
+	}
+	return 0
+}
+
+func anySlice(v any) []any {
+	s, _ := v.([]any)
+	return s
+}
+
+// readHookSettings reads a Claude Code settings file as generic JSON, so
+// settings claudetalk doesn't know survive a rewrite. A missing file is
+// empty settings.
+func readHookSettings(path string) (map[string]any, error) {
+	settings := make(map[string]any)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return settings, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if settings == nil {
+		settings = make(map[string]any)
+	}
+	return settings, nil
+}
+
+func writeHookSettings(path string, settings map[string]any) error {
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -1,55 +1,163 @@
 package cli
 
 import (
-	"bufio"
+	"cmp"
 	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"os/exec"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/corvino/claudetalk/internal/protocol"
 	"github.com/corvino/claudetalk/internal/runner"
 	"github.com/corvino/claudetalk/internal/server"
+	"github.com/corvino/claudetalk/internal/tunnel"
 	"github.com/spf13/cobra"
 )
 
 func newHostCmd() *cobra.Command {
-	var port int
+	var (
+		port       int
+		tunnelName string
+		noTunnel   bool
+		rf         runnerFlags
+		configFile string
+		rooms      []string
+		invite     []string
+		dataDir    string
+		subdomain  string
+		drain      time.Duration
+	)
 
 	cmd := &cobra.Command{
 		Use:   "host",
 		Short: "Start server and public tunnel — share the URL with friends",
-		Long: `Starts the ClaudeTalk server locally and opens a public tunnel via localtunnel.
-Share the printed URL with friends so they can run "claudetalk join <url>".`,
+		Long: `Starts the ClaudeTalk server locally and opens a public tunnel.
+Share the printed URL with friends so they can run "claudetalk join <url>".
+
+Tunnel providers: ` + strings.Join(tunnel.Names(), ", ") + `.
+Use --no-tunnel to skip the tunnel and share your LAN address instead.
+
+--subdomain asks for a fixed URL, such as https://myteam.loca.lt, so the
+one you share survives restarts; whoever holds it first gets it. With
+ngrok it is a domain reserved in your ngrok account (myteam.ngrok.app).
+localtunnel, localtunnel-npx and ngrok support it; cloudflared quick
+tunnels always get a random URL, and tailscale's is your machine's name.
+
+--rooms creates rooms at startup, and --invite prints, for each friend
+named, the command that joins each of them. --config reads rooms from a
+YAML file instead, with visibility, charter and work_dir for each:
+
+  rooms:
+    - name: backend
+      visibility: private
+      charter_file: CHARTER.md
+      invite: [bob, carol]
+    - name: lobby
+
+With rooms and your name (-n or CLAUDETALK_SENDER), host also writes a
+.claudetalk here for the first room, so your own CLI and Claude need no
+join. A .claudetalk for another server is left alone.
+
+--data-dir keeps everything across restarts: shared files, rooms with their
+history, settings and charters, the key share links are signed with, and
+the tunnel's subdomain, asked for again so the URL you shared keeps working
+while nobody else took it. Without it, files go to a throwaway
+claudetalk-files-<port> directory and the rest is lost on exit.
+
+  claudetalk host -n alice --rooms backend,frontend --invite bob,carol
+  claudetalk host --data-dir ~/.claudetalk-host`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runHost(port)
+			if noTunnel {
+				tunnelName = ""
+			}
+			if subdomain != "" {
+				if tunnelName == "" {
+					return fmt.Errorf("--subdomain needs a tunnel")
+				}
+				p, err := tunnel.Get(tunnelName)
+				if err != nil {
+					return err
+				}
+				if _, ok := p.(tunnel.Subdomainer); !ok {
+					return fmt.Errorf("the %s tunnel can't ask for a subdomain", tunnelName)
+				}
+			}
+			hc := &hostConfig{}
+			if configFile != "" {
+				var err error
+				if hc, err = loadHostConfig(configFile); err != nil {
+					return err
+				}
+			}
+			for _, name := range rooms {
+				hc.Rooms = append(hc.Rooms, hostRoom{Name: name})
+			}
+			for i := range hc.Rooms {
+				hc.Rooms[i].Invite = append(hc.Rooms[i].Invite, invite...)
+			}
+			if len(invite) > 0 && len(hc.Rooms) == 0 {
+				return fmt.Errorf("--invite needs rooms to invite to (--rooms or --config)")
+			}
+			rcfg, err := rf.config(fmt.Sprintf("http://localhost:%d", port))
+			if err != nil {
+				return err
+			}
+			return runHost(port, tunnelName, subdomain, rcfg, hc, hostData{dir: dataDir}, drain)
 		},
 	}
 
 	cmd.Flags().IntVarP(&port, "port", "p", 8080, "local server port")
+	cmd.Flags().StringVar(&tunnelName, "tunnel", "localtunnel", "tunnel provider: "+strings.Join(tunnel.Names(), ", "))
+	cmd.Flags().BoolVar(&noTunnel, "no-tunnel", false, "don't open a tunnel; print the LAN address instead")
+	cmd.Flags().StringVar(&configFile, "config", "", "YAML file of rooms to create at startup")
+	cmd.Flags().StringSliceVar(&rooms, "rooms", nil, "comma-separated rooms to create at startup")
+	cmd.Flags().StringSliceVar(&invite, "invite", nil, "comma-separated friends to print join commands for, for every room")
+	cmd.Flags().StringVar(&subdomain, "subdomain", "", "tunnel subdomain to ask for, for a URL that stays the same (ngrok: a reserved domain)")
+	cmd.Flags().StringVar(&dataDir, "data-dir", "", "directory to keep files, rooms and the tunnel subdomain in across restarts")
+	cmd.Flags().DurationVar(&drain, "drain-timeout", 30*time.Second, "on shutdown, how long running Claudes get to finish before they are stopped")
+	rf.register(cmd, "base directories rooms and spawns may choose a work_dir under")
 	return cmd
 }
 
-func runHost(port int) error {
+// runHost runs the server and tunnel, first restoring what data kept and
+// creating hc's rooms. The tunnel asks for subdomain, or else the one data
+// kept. On shutdown running Claudes get drain to finish.
+func runHost(port int, tunnelName, subdomain string, rcfg runner.Config, hc *hostConfig, data hostData, drain time.Duration) error {
 	// 1. Start the embedded server.
 	hub := server.NewHub(1000)
 	addr := fmt.Sprintf(":%d", port)
 
-	// Create file store in a temp directory for the host command.
-	fileStore, err := server.NewFileStore(fmt.Sprintf("claudetalk-files-%d", port), 50*1024*1024)
+	if err := data.open(); err != nil {
+		return err
+	}
+	fileStore, err := server.NewFileStore(data.filesDir(port), 50*1024*1024)
 	if err != nil {
 		return fmt.Errorf("create file store: %w", err)
 	}
+	journal, err := data.restore(hub, fileStore)
+	if err != nil {
+		return err
+	}
+
+	rcfg.RoomToken = hub.RoomToken
+	r := runner.New(rcfg)
 
-	serverURL := fmt.Sprintf("http://localhost:%d", port)
-	r := runner.New(runner.Config{
-		ServerURL: serverURL,
-	})
+	invites, err := hc.bootstrap(hub, r, cmp.Or(flagSender, "host"))
+	if err != nil {
+		return err
+	}
+	if len(hc.Rooms) > 0 && flagSender != "" {
+		first := hc.Rooms[0].Name
+		if err := writeHostConfig(port, first, flagSender, hub.RoomToken(first)); err != nil {
+			return err
+		}
+	}
 
 	srv := server.New(hub, addr, fileStore, r)
 
@@ -71,60 +179,30 @@ func runHost(port int) error {
 	resp.Body.Close()
 	fmt.Println("Server is running.")
 
-	// 2. Check that npx exists.
-	npxPath, err := exec.LookPath("npx")
-	if err != nil {
-		return fmt.Errorf("npx not found — install Node.js from https://nodejs.org")
-	}
-	_ = npxPath
-
-	// 3. Launch localtunnel.
-	fmt.Println("Starting public tunnel...")
-
-	ltCmd := exec.Command("npx", "localtunnel", "--port", fmt.Sprintf("%d", port))
-	ltCmd.Stderr = os.Stderr
-
-	stdout, err := ltCmd.StdoutPipe()
-	if err != nil {
-		return fmt.Errorf("pipe stdout: %w", err)
-	}
-	if err := ltCmd.Start(); err != nil {
-		return fmt.Errorf("start localtunnel: %w", err)
-	}
-
-	// Read lines from localtunnel stdout until we find the URL.
-	tunnelURL := ""
-	scanner := bufio.NewScanner(stdout)
-	urlCh := make(chan string, 1)
-
-	go func() {
-		for scanner.Scan() {
-			line := scanner.Text()
-			// localtunnel prints: "your url is: https://xxx.loca.lt"
-			if strings.Contains(line, "https://") {
-				for _, word := range strings.Fields(line) {
-					if strings.HasPrefix(word, "https://") {
-						urlCh <- word
-						return
-					}
-				}
-			}
+	// 2. Open the public tunnel (or just print the LAN address).
+	var (
+		provider tunnel.Provider
+		tun      tunnel.Tunnel
+	)
+	tunnelURL := tunnel.LANAddress(port)
+	if tunnelName != "" {
+		provider, err = tunnel.Get(tunnelName)
+		if err != nil {
+			return err
 		}
-		close(urlCh)
-	}()
-
-	// Wait up to 30s for the URL.
-	select {
-	case u, ok := <-urlCh:
-		if !ok || u == "" {
-			return fmt.Errorf("localtunnel exited without providing a URL")
+		fmt.Printf("Starting public tunnel (%s)...\n", provider.Name())
+		tun, err = tunnel.StartStable(context.Background(), provider, port, cmp.Or(subdomain, data.subdomain()))
+		if err != nil {
+			return err
 		}
-		tunnelURL = u
-	case <-time.After(30 * time.Second):
-		return fmt.Errorf("timed out waiting for localtunnel URL")
+		tunnelURL = tun.URL()
+		if got := tunnel.SubdomainOf(provider, tunnelURL); subdomain != "" && got != subdomain {
+			fmt.Printf("WARNING: subdomain %q is taken; for now the URL is %s\n", subdomain, tunnelURL)
+		}
+		data.saveSubdomain(tunnel.SubdomainOf(provider, tunnelURL))
 	}
 
-	// 4. Print the banner.
+	// 3. Print the banner.
 	fmt.Println()
 	fmt.Println("============================================================")
 	fmt.Println()
@@ -136,6 +214,7 @@ func runHost(port int) error {
 	fmt.Println()
 	fmt.Println("  They run:  claudetalk join " + tunnelURL)
 	fmt.Println()
+	printHostInvites(tunnelURL, invites)
 	fmt.Println("============================================================")
 	fmt.Println()
 	fmt.Printf("Local server:  http://localhost:%d\n", port)
@@ -144,23 +223,154 @@ func runHost(port int) error {
 	fmt.Println("Press Ctrl+C to shut down.")
 	fmt.Println()
 
-	// 5. Wait for Ctrl+C, then graceful shutdown.
+	// 4. Wait for Ctrl+C, then graceful shutdown. Meanwhile keep the tunnel alive.
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	monitorCtx, stopMonitor := context.WithCancel(context.Background())
+	tunCh := make(chan tunnel.Tunnel, 1)
+	if tun != nil {
+		health := &tunnelHealth{}
+		hub.AddHealthCheck("tunnel", health.check)
+		go superviseTunnel(monitorCtx, provider, tun, port, hub, invites, data, health, tunCh)
+	}
+	if data.dir != "" {
+		go data.saveEvery(monitorCtx, hub, fileStore)
+	}
 	<-stop
+	stopMonitor()
 
 	fmt.Println("\nShutting down...")
 
-	// Kill localtunnel.
-	if ltCmd.Process != nil {
-		ltCmd.Process.Kill()
+	// Let running Claudes finish, then shut down the HTTP server. The tunnel
+	// stays up meanwhile, so friends see the announcements.
+	if err := server.Shutdown(srv, hub, r, drain, 0); err != nil {
+		log.Printf("shutdown: %v", err)
+	}
+
+	// Tear down the tunnel (the supervisor reports the latest one it started).
+	select {
+	case tun = <-tunCh:
+	case <-time.After(time.Second):
+	}
+	if tun != nil {
+		tun.Close()
 	}
 
-	// Shutdown HTTP server.
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	srv.Shutdown(ctx)
+	if err := data.save(hub, fileStore); err != nil {
+		log.Printf("save state: %v", err)
+	}
+	if journal != nil {
+		if err := journal.Close(); err != nil {
+			log.Printf("journal: %v", err)
+		}
+	}
 
 	fmt.Println("Stopped.")
 	return nil
 }
+
+// Tunnel health checking: probe every tunnelProbeInterval and restart after
+// tunnelMaxFailedProbes consecutive failures (or when the tunnel reports Done).
+const (
+	tunnelProbeInterval   = 30 * time.Second
+	tunnelMaxFailedProbes = 3
+)
+
+// tunnelHealth is the tunnel's state for the server's readiness check.
+type tunnelHealth struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (th *tunnelHealth) set(err error) {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	th.err = err
+}
+
+func (th *tunnelHealth) check() error {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	return th.err
+}
+
+// superviseTunnel watches tun, replacing it when it dies, and keeps health
+// up to date. Each new URL is printed, with the invites under it, and
+// announced in every room; each restart asks for the old subdomain first.
+// The tunnel current at cancellation is sent on current.
+func superviseTunnel(ctx context.Context, provider tunnel.Provider, tun tunnel.Tunnel, port int, hub *server.Hub, invites []hostInvite, data hostData, health *tunnelHealth, current chan<- tunnel.Tunnel) {
+	defer func() { current <- tun }()
+
+	ticker := time.NewTicker(tunnelProbeInterval)
+	defer ticker.Stop()
+	failed := 0
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := tunnel.Probe(tun.URL()); err != nil {
+				health.set(fmt.Errorf("%s: %w", tun.URL(), err))
+				failed++
+				log.Printf("tunnel health check failed (%d/%d): %v", failed, tunnelMaxFailedProbes, err)
+				if failed < tunnelMaxFailedProbes {
+					continue
+				}
+			} else {
+				health.set(nil)
+				failed = 0
+				continue
+			}
+		case <-tun.Done():
+			log.Printf("tunnel %s disconnected", tun.URL())
+		}
+
+		// The tunnel is dead: tear it down and start a new one.
+		oldURL := tun.URL()
+		subdomain := tunnel.SubdomainOf(provider, oldURL)
+		health.set(fmt.Errorf("%s is down; restarting the tunnel", oldURL))
+		tun.Close()
+		failed = 0
+		backoff := time.Second
+		for {
+			next, err := tunnel.StartStable(ctx, provider, port, subdomain)
+			if err == nil {
+				tun = next
+				break
+			}
+			log.Printf("tunnel restart failed: %v (retrying in %s)", err, backoff)
+			select {
+			case <-ctx.Done():
+				tun = nil
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > time.Minute {
+				backoff = time.Minute
+			}
+		}
+
+		health.set(nil)
+		data.saveSubdomain(tunnel.SubdomainOf(provider, tun.URL()))
+		if tun.URL() == oldURL {
+			log.Printf("tunnel re-established at %s", tun.URL())
+			continue
+		}
+		fmt.Println()
+		fmt.Println("Tunnel re-established. NEW PUBLIC URL:")
+		fmt.Printf("  %s\n", tun.URL())
+		fmt.Println("  They run:  claudetalk join " + tun.URL())
+		fmt.Println()
+		printHostInvites(tun.URL(), invites)
+		for _, snap := range hub.ListRooms() {
+			if room := hub.GetRoom(snap.Name); room != nil {
+				room.AddMessage("system", protocol.TypeSystem, protocol.Payload{
+					Text: "The host's tunnel was restarted. New server URL: " + tun.URL(),
+				}, map[string]string{"server_url": tun.URL()})
+			}
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/corvino/claudetalk/internal/runner"
+	"github.com/corvino/claudetalk/internal/server"
+	"gopkg.in/yaml.v3"
+)
+
+// hostConfig is the layout of the file "claudetalk host --config" reads:
+// rooms to create at startup, and who to print invites for.
+//
+//	rooms:
+//	  - name: backend
+//	    visibility: private
+//	    charter_file: CHARTER.md
+//	    work_dir: api
+//	    invite: [bob, carol]
+//	  - name: lobby
+//	    charter: Be kind. Ask before pushing to main.
+//	invite: [dana]
+//
+// Top-level invite names are invited to every room. charter_file is
+// relative to the config file.
+type hostConfig struct {
+	Rooms  []hostRoom `yaml:"rooms"`
+	Invite []string   `yaml:"invite"`
+}
+
+// hostRoom is one room a host creates at startup.
+type hostRoom struct {
+	Name        string   `yaml:"name"`
+	Visibility  string   `yaml:"visibility"` // public (default), unlisted or private
+	Charter     string   `yaml:"charter"`
+	CharterFile string   `yaml:"charter_file"`
+	WorkDir     string   `yaml:"work_dir"`
+	Invite      []string `yaml:"invite"` // friends to print join commands for
+}
+
+// hostInvite is a friend's invite to a bootstrapped room.
+type hostInvite struct {
+	Friend string
+	Room   string
+	Code   string
+}
+
+// loadHostConfig reads a host config file.
+func loadHostConfig(path string) (*hostConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg hostConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i, room := range cfg.Rooms {
+		if room.Name == "" {
+			return nil, fmt.Errorf("%s: room %d has no name", path, i+1)
+		}
+		if room.CharterFile != "" {
+			if room.Charter != "" {
+				return nil, fmt.Errorf("%s: room %q has both charter and charter_file", path, room.Name)
+			}
+			file := room.CharterFile
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(filepath.Dir(path), file)
+			}
+			text, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("%s: room %q: %w", path, room.Name, err)
+			}
+			cfg.Rooms[i].Charter = string(text)
+		}
+		cfg.Rooms[i].Invite = append(cfg.Rooms[i].Invite, cfg.Invite...)
+	}
+	return &cfg, nil
+}
+
+// bootstrap creates the config's rooms on hub, sets their charters and
+// makes an invite per friend, in order. A room that exists already, kept in
+// --data-dir, stays as it is; its friends are still invited.
+func (cfg *hostConfig) bootstrap(hub *server.Hub, rnr *runner.Runner, host string) ([]hostInvite, error) {
+	var invites []hostInvite
+	for _, room := range cfg.Rooms {
+		switch room.Visibility {
+		case "", server.VisibilityPublic, server.VisibilityUnlisted, server.VisibilityPrivate:
+		default:
+			return nil, fmt.Errorf("room %q: visibility must be %s, %s or %s", room.Name, server.VisibilityPublic, server.VisibilityUnlisted, server.VisibilityPrivate)
+		}
+		if room.WorkDir != "" {
+			if _, err := rnr.ResolveWorkDir(room.WorkDir); err != nil {
+				return nil, fmt.Errorf("room %q: %w", room.Name, err)
+			}
+		}
+		r, err := hub.CreateRoom(room.Name, server.RoomSettings{Visibility: room.Visibility, WorkDir: room.WorkDir})
+		switch {
+		case errors.Is(err, server.ErrRoomExists):
+		case err != nil:
+			return nil, err
+		case room.Charter != "":
+			r.SetCharter(protocol.Charter{Text: room.Charter, UpdatedBy: host, UpdatedAt: time.Now().UTC()})
+		}
+		seen := make(map[string]bool)
+		for _, friend := range room.Invite {
+			if seen[friend] {
+				continue
+			}
+			seen[friend] = true
+			inv, err := hub.NewInvite(room.Name, host, server.DefaultInviteTTL)
+			if err != nil {
+				return nil, err
+			}
+			invites = append(invites, hostInvite{Friend: friend, Room: room.Name, Code: inv.Code})
+		}
+	}
+	return invites, nil
+}
+
+// printHostInvites prints the command each friend joins their room with.
+func printHostInvites(serverURL string, invites []hostInvite) {
+	if len(invites) == 0 {
+		return
+	}
+	fmt.Println("  Invites (valid for a week):")
+	for _, inv := range invites {
+		fmt.Printf("    %-10s #%-12s claudetalk join %s -n %s\n", inv.Friend, inv.Room, inviteLink(serverURL, inv.Code), inv.Friend)
+	}
+	fmt.Println()
+}
+
+// writeHostConfig points the .claudetalk here at the host's own server and
+// room, so the host's CLI and Claude work without a join. A .claudetalk for
+// another server is left alone.
+func writeHostConfig(port int, room, sender, roomToken string) error {
+	local := fmt.Sprintf("http://localhost:%d", port)
+	cfg := Config{}
+	data, err := os.ReadFile(configFileName)
+	switch {
+	case err == nil:
+		if json.Unmarshal(data, &cfg) != nil || cfg.Server != local {
+			fmt.Printf("Left %s alone: it isn't for this server.\n", configFileName)
+			return nil
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+	cfg.Server = local
+	cfg.Room = room
+	cfg.Sender = sender
+	cfg.RoomToken = roomToken
+	return saveConfig(cfg, err != nil)
+}
@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/server"
+)
+
+// hostSaveInterval is how often host saves its rooms to --data-dir, besides
+// on exit, so a crash loses little.
+const hostSaveInterval = time.Minute
+
+// hostData is the --data-dir of "claudetalk host", what it keeps across
+// restarts:
+//
+//	files/      shared files
+//	journal/    what happened in each room since, replayed after a crash
+//	rooms.json  rooms with their history, settings and charters
+//	share.key   the secret share links are signed with
+//	subdomain   the tunnel subdomain last used
+//
+// With no dir it keeps nothing, and files go to a throwaway directory.
+type hostData struct {
+	dir string
+}
+
+// open creates the directory.
+func (d hostData) open() error {
+	if d.dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(d.dir, 0700); err != nil {
+		return fmt.Errorf("data dir: %w", err)
+	}
+	return nil
+}
+
+func (d hostData) filesDir(port int) string {
+	if d.dir == "" {
+		return fmt.Sprintf("claudetalk-files-%d", port)
+	}
+	return filepath.Join(d.dir, "files")
+}
+
+// restore loads the saved rooms into hub, replays the journal on top and
+// keeps share links signed before the restart working. It returns the
+// journal, which goes on recording until closed, or nil with no dir.
+func (d hostData) restore(hub *server.Hub, files *server.FileStore) (*server.Journal, error) {
+	if d.dir == "" {
+		return nil, nil
+	}
+	secret, err := d.shareSecret()
+	if err != nil {
+		return nil, err
+	}
+	hub.SetShareSecret(secret)
+	n, err := hub.LoadState(filepath.Join(d.dir, "rooms.json"), files)
+	if err != nil {
+		return nil, fmt.Errorf("restore rooms: %w", err)
+	}
+	if n > 0 {
+		log.Printf("restored %d rooms from %s", n, d.dir)
+	}
+	journal, _, err := server.OpenJournal(filepath.Join(d.dir, "journal"), hub, files)
+	if err != nil {
+		return nil, fmt.Errorf("restore rooms: %w", err)
+	}
+	return journal, nil
+}
+
+// shareSecret reads share.key, making one the first time.
+func (d hostData) shareSecret() (string, error) {
+	path := filepath.Join(d.dir, "share.key")
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return strings.TrimSpace(string(data)), nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	secret := hex.EncodeToString(b)
+	return secret, os.WriteFile(path, []byte(secret+"\n"), 0600)
+}
+
+// save writes hub's rooms.
+func (d hostData) save(hub *server.Hub, files *server.FileStore) error {
+	if d.dir == "" {
+		return nil
+	}
+	return hub.SaveState(filepath.Join(d.dir, "rooms.json"), files)
+}
+
+// saveEvery saves hub's rooms every hostSaveInterval until ctx is done.
+func (d hostData) saveEvery(ctx context.Context, hub *server.Hub, files *server.FileStore) {
+	ticker := time.NewTicker(hostSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.save(hub, files); err != nil {
+				log.Printf("save state: %v", err)
+			}
+		}
+	}
+}
+
+// subdomain is the tunnel subdomain last used, or "".
+func (d hostData) subdomain() string {
+	if d.dir == "" {
+		return ""
+	}
+	data, _ := os.ReadFile(filepath.Join(d.dir, "subdomain"))
+	return strings.TrimSpace(string(data))
+}
+
+// saveSubdomain remembers the tunnel subdomain to ask for next time.
+func (d hostData) saveSubdomain(subdomain string) {
+	if d.dir == "" || subdomain == "" {
+		return
+	}
+	if err := os.WriteFile(filepath.Join(d.dir, "subdomain"), []byte(subdomain+"\n"), 0600); err != nil {
+		log.Printf("save tunnel subdomain: %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/spf13/cobra"
+)
+
+func newInitCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "init",
+		Short: "Set up ClaudeTalk in this directory, step by step",
+		Long: `Asks who you are and what your Claude is called, then either starts a
+server here for others to join (as "claudetalk host" does) or joins one
+someone shared (as "claudetalk join" does). Either way it writes .claudetalk
+and CLAUDE.md, so Claude Code started in this directory is ready to talk.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runInit()
+		},
+	}
+}
+
+func runInit() error {
+	fmt.Println("Setting up ClaudeTalk in this directory.")
+	fmt.Println()
+
+	var prev Config
+	if cfg := loadConfig(); cfg != nil {
+		prev = *cfg
+	}
+	if _, err := os.Stat(configFileName); err == nil {
+		if !confirm(fmt.Sprintf("%s already exists here. Replace it?", configFileName), false) {
+			return fmt.Errorf("left %s as it is", configFileName)
+		}
+	}
+
+	// 1. Who you are.
+	sender := ask("Your name", cmp.Or(prev.Sender, os.Getenv("USER"), os.Getenv("USERNAME")))
+	if sender == "" {
+		return fmt.Errorf("name is required")
+	}
+	claudeName := ""
+	for {
+		claudeName = ask(fmt.Sprintf("What your Claude speaks as (blank for %q)", protocol.ClaudeName(sender, "")), prev.ClaudeName)
+		err := protocol.ValidateClaudeName(claudeName)
+		if claudeName == "" || err == nil {
+			break
+		}
+		fmt.Println(err)
+	}
+	var profile protocol.Profile
+	if prev.Profile != nil {
+		profile = *prev.Profile
+	}
+	profile.Description = ask("What you work on, for the room (optional)", profile.Description)
+	fmt.Println()
+
+	// 2. Host or join.
+	for {
+		switch strings.ToLower(ask("Host a server here, or join one someone shared? (host/join)", "join")) {
+		case "j", "join":
+			url := ask("URL or invite link they shared", "")
+			fmt.Println()
+			return runJoin(url, "", sender, "", "", claudeName, profile, joinOptions{})
+		case "h", "host":
+			return initHost(sender, claudeName, profile)
+		}
+	}
+}
+
+// initHost writes the config for a server hosted here, then runs it.
+func initHost(sender, claudeName string, profile protocol.Profile) error {
+	wd, _ := os.Getwd()
+	room := ask("Room name", filepath.Base(wd))
+	if room == "" {
+		return fmt.Errorf("room name is required")
+	}
+	port, err := strconv.Atoi(ask("Port", "8080"))
+	if err != nil || port <= 0 || port > 65535 {
+		return fmt.Errorf("port must be a number from 1 to 65535")
+	}
+	tunnel := confirm("Open a public tunnel, so friends outside your network can join?", true)
+	fmt.Println()
+
+	cfg := Config{
+		Server:     fmt.Sprintf("http://localhost:%d", port),
+		Room:       room,
+		Sender:     sender,
+		ClaudeName: claudeName,
+	}
+	if !profile.IsZero() {
+		cfg.Profile = &profile
+	}
+	if err := saveConfig(cfg, true); err != nil {
+		return err
+	}
+	fmt.Println()
+	fmt.Printf("Starting the server. Others join your room with the URL it prints:\n")
+	fmt.Printf("  claudetalk join <url> %s\n", room)
+	fmt.Println()
+
+	hostArgs := []string{"--port", strconv.Itoa(port)}
+	if !tunnel {
+		hostArgs = append(hostArgs, "--no-tunnel")
+	}
+	host := newHostCmd()
+	host.SetArgs(hostArgs)
+	return host.Execute()
+}
+
+// ask prompts for a line of input, returning def if none is given.
+func ask(question, def string) string {
+	if def != "" {
+		fmt.Printf("%s [%s]: ", question, def)
+	} else {
+		fmt.Printf("%s: ", question)
+	}
+	line, err := stdin.ReadString('\n')
+	if line = strings.TrimSpace(line); line != "" {
+		return line
+	}
+	if err != nil {
+		fmt.Println()
+	}
+	return def
+}
+
+// confirm asks a yes/no question, returning def if it isn't answered.
+func confirm(question string, def bool) bool {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	switch strings.ToLower(ask(question+" ("+hint+")", "")) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	}
+	return def
+}
@@ -2,31 +2,82 @@ package cli
 
 import (
 	"bufio"
+	"cmp"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/corvino/claudetalk/internal/e2e"
+	"github.com/corvino/claudetalk/internal/protocol"
 	"github.com/spf13/cobra"
 )
 
 // Config is the .claudetalk project config written by "join".
 type Config struct {
-	Server string `json:"server"`
-	Room   string `json:"room"`
-	Sender string `json:"sender"`
+	Server     string            `json:"server"`
+	Room       string            `json:"room"`
+	Sender     string            `json:"sender"`
+	Passphrase string            `json:"passphrase,omitempty"`  // end-to-end encryption; see internal/e2e
+	Token      string            `json:"token,omitempty"`       // workspace access token; see protocol.TokenEnv
+	RoomToken  string            `json:"room_token,omitempty"`  // private room access token; see protocol.RoomTokenEnv
+	Profile    *protocol.Profile `json:"profile,omitempty"`     // advertised by watch and daemon; see protocol.Profile
+	ClaudeName string            `json:"claude_name,omitempty"` // what your Claude speaks as; "" = "{sender}'s Claude"
 }
 
 const configFileName = ".claudetalk"
 
 func newJoinCmd() *cobra.Command {
+	var (
+		passphrase, token string
+		claudeName        string
+		profile           protocol.Profile
+		opts              joinOptions
+	)
+
 	cmd := &cobra.Command{
 		Use:   "join <url> [room] [name]",
 		Short: "Connect to a friend's ClaudeTalk server",
 		Long: `Connects to a ClaudeTalk server, verifies it's reachable, and writes
 a .claudetalk config file in the current directory so all future commands
-just work. Also writes CLAUDE.md so Claude Code knows how to use claudetalk.`,
+just work. Also writes CLAUDE.md so Claude Code knows how to use claudetalk.
+
+With --passphrase, messages are end-to-end encrypted: the passphrase is saved
+in .claudetalk and everyone in the room must join with the same one. The
+server, and any tunnel in front of it, then only sees ciphertext, so features
+that read text on the server (@-mention spawns, --match filters, server-side
+digests) skip encrypted messages. Shared files are not encrypted.
+
+The URL may be an invite link from "claudetalk rooms invite", which names
+the room and, for a private room, carries its access token, saved in
+.claudetalk. On servers run with --room-creation=explicit the room must already
+exist; create it with "claudetalk rooms create".
+
+For a workspace on a hosted server, the URL is the workspace's
+(https://host/api/workspaces/acme) and --token its access token, which is
+also saved in .claudetalk.
+
+--description and --availability make up the profile "claudetalk watch" and
+"claudetalk daemon" advertise to the room, so others' Claudes know what you
+work on and whether to bring you in.
+
+--claude-name picks what your Claude speaks as in the room, instead of
+"{name}'s Claude". The server keeps names unique, so others can still
+converse with it by either name.
+
+For provisioning scripts and devcontainers, every answer can come from
+flags or the environment: the URL from -s or CLAUDETALK_SERVER, the room from -r
+or CLAUDETALK_ROOM, the name from -n or CLAUDETALK_SENDER, and the
+passphrase, token and Claude name from CLAUDETALK_PASSPHRASE,
+CLAUDETALK_TOKEN and CLAUDETALK_CLAUDE_NAME. With --yes join never prompts,
+and fails naming whatever is missing. --no-claude-md leaves CLAUDE.md alone.
+--global saves the settings as a profile in the user config (see claudetalk
+profile) and makes it current, instead of writing anything here.
+
+  CLAUDETALK_SERVER=https://chat.example.com claudetalk join --yes -r backend -n ci`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			serverURL := ""
 			room := ""
@@ -42,27 +93,79 @@ just work. Also writes CLAUDE.md so Claude Code knows how to use claudetalk.`,
 				sender = args[2]
 			}
 
-			return runJoin(serverURL, room, sender)
+			// -s, -r and -n count only when given: their defaults come from
+			// the .claudetalk or profile this join replaces.
+			if serverURL == "" && cmd.Flags().Changed("server") {
+				serverURL = flagServer
+			}
+			if room == "" && cmd.Flags().Changed("room") {
+				room = flagRoom
+			}
+			if sender == "" && cmd.Flags().Changed("name") {
+				sender = flagSender
+			}
+			serverURL = cmp.Or(serverURL, os.Getenv("CLAUDETALK_SERVER"))
+			room = cmp.Or(room, os.Getenv("CLAUDETALK_ROOM"))
+			sender = cmp.Or(sender, os.Getenv("CLAUDETALK_SENDER"))
+			passphrase = cmp.Or(passphrase, os.Getenv(e2e.EnvVar))
+			token = cmp.Or(token, joinEnvToken)
+			claudeName = cmp.Or(claudeName, os.Getenv("CLAUDETALK_CLAUDE_NAME"))
+
+			return runJoin(serverURL, room, sender, passphrase, token, claudeName, profile, opts)
 		},
 	}
+	cmd.Flags().StringVar(&passphrase, "passphrase", "", "shared passphrase for end-to-end encrypted messages")
+	cmd.Flags().StringVar(&token, "token", "", "access token for a workspace on a hosted server")
+	cmd.Flags().StringVar(&profile.Description, "description", "", "what you work on, shown to the room (e.g. \"backend specialist\")")
+	cmd.Flags().StringVar(&profile.Availability, "availability", "", "available, busy or away")
+	cmd.Flags().StringVar(&claudeName, "claude-name", "", "what your Claude speaks as (default \"{name}'s Claude\")")
+	cmd.Flags().BoolVarP(&opts.yes, "yes", "y", false, "never prompt; fail if the URL, room or name is missing")
+	cmd.Flags().BoolVar(&opts.noClaudeMD, "no-claude-md", false, "don't write CLAUDE.md")
+	cmd.Flags().BoolVar(&opts.global, "global", false, "save as the current profile in the user config instead of .claudetalk here")
 	return cmd
 }
 
-func runJoin(serverURL, room, sender string) error {
-	reader := bufio.NewReader(os.Stdin)
+// joinOptions are join's switches for running without a terminal.
+type joinOptions struct {
+	yes        bool // never prompt
+	noClaudeMD bool // leave CLAUDE.md alone
+	global     bool // save a profile in config.yaml rather than .claudetalk
+}
 
+// joinEnvToken is CLAUDETALK_TOKEN as the user set it, read before the
+// root command fills it in from the .claudetalk this join may replace.
+var joinEnvToken = os.Getenv(protocol.TokenEnv)
+
+// stdin reads the answers to join's and init's prompts, shared so that
+// neither buffers away input meant for the other.
+var stdin = bufio.NewReader(os.Stdin)
+
+func runJoin(serverURL, room, sender, passphrase, token, claudeName string, profile protocol.Profile, opts joinOptions) error {
+	if err := profile.Validate(); err != nil {
+		return err
+	}
+	if claudeName != "" {
+		if err := protocol.ValidateClaudeName(claudeName); err != nil {
+			return err
+		}
+	}
 	// 1. Get the server URL.
-	if serverURL == "" {
+	if serverURL == "" && !opts.yes {
 		fmt.Print("Paste the URL your friend shared: ")
-		line, _ := reader.ReadString('\n')
+		line, _ := stdin.ReadString('\n')
 		serverURL = strings.TrimSpace(line)
 	}
 	if serverURL == "" {
-		return fmt.Errorf("server URL is required")
+		return fmt.Errorf("server URL is required (pass it, -s or CLAUDETALK_SERVER)")
 	}
+	serverURL, invite := splitInviteLink(serverURL)
+	roomToken := ""
 	serverURL = strings.TrimRight(serverURL, "/")
 
 	// 2. Health check.
+	if token != "" {
+		os.Setenv(protocol.TokenEnv, token)
+	}
 	fmt.Printf("Connecting to %s ...\n", serverURL)
 	health, err := getHealth(serverURL)
 	if err != nil {
@@ -70,47 +173,61 @@ func runJoin(serverURL, room, sender string) error {
 	}
 	fmt.Printf("Connected! Server is %s (uptime: %s, %d rooms)\n", health.Status, health.Uptime, health.Rooms)
 
+	if invite != "" && room == "" {
+		inv, err := getInvite(serverURL, invite)
+		if err != nil {
+			return fmt.Errorf("invite: %w", err)
+		}
+		room = inv.Room
+		roomToken = inv.Token
+		protocol.AddRoomToken(roomToken)
+		fmt.Printf("Invite is for room %q\n", room)
+	}
+
 	// 3. Prompt for room and sender if needed.
-	if room == "" {
+	if room == "" && !opts.yes {
 		fmt.Print("Room name (e.g. myproject): ")
-		line, _ := reader.ReadString('\n')
+		line, _ := stdin.ReadString('\n')
 		room = strings.TrimSpace(line)
 	}
 	if room == "" {
-		return fmt.Errorf("room name is required")
+		return fmt.Errorf("room name is required (pass it, -r or CLAUDETALK_ROOM)")
+	}
+	if err := checkRoom(serverURL, room, health.RoomCreation == "explicit"); err != nil {
+		return err
 	}
 
-	if sender == "" {
+	if sender == "" && !opts.yes {
 		fmt.Print("Your name (e.g. alice): ")
-		line, _ := reader.ReadString('\n')
+		line, _ := stdin.ReadString('\n')
 		sender = strings.TrimSpace(line)
 	}
 	if sender == "" {
-		return fmt.Errorf("name is required")
+		return fmt.Errorf("name is required (pass it, -n or CLAUDETALK_SENDER)")
 	}
 
-	// 4. Write .claudetalk config.
+	// 4. Write .claudetalk config and CLAUDE.md, or the profile.
 	cfg := Config{
-		Server: serverURL,
-		Room:   room,
-		Sender: sender,
+		Server:     serverURL,
+		Room:       room,
+		Sender:     sender,
+		Passphrase: passphrase,
+		Token:      token,
+		RoomToken:  roomToken,
+		ClaudeName: claudeName,
 	}
-	cfgBytes, _ := json.MarshalIndent(cfg, "", "  ")
-
-	if err := os.WriteFile(configFileName, cfgBytes, 0644); err != nil {
-		return fmt.Errorf("write %s: %w", configFileName, err)
+	if !profile.IsZero() {
+		cfg.Profile = &profile
 	}
-	fmt.Printf("Wrote %s\n", configFileName)
-
-	// 5. Write CLAUDE.md (or append to existing one).
-	claudeMDPath := "CLAUDE.md"
-	if err := writeClaudeMD(claudeMDPath, cfg); err != nil {
-		fmt.Fprintf(os.Stderr, "warning: could not write %s: %v\n", claudeMDPath, err)
-	} else {
-		fmt.Printf("Wrote %s\n", claudeMDPath)
+	if opts.global {
+		if err := saveGlobalConfig(cfg); err != nil {
+			return err
+		}
+	} else if err := saveConfig(cfg, !opts.noClaudeMD); err != nil {
+		return err
 	}
 
-	// 6. Print success.
+	// 5. Print success.
 	fmt.Println()
 	fmt.Println("============================================================")
 	fmt.Println()
@@ -119,14 +236,31 @@ func runJoin(serverURL, room, sender string) error {
 	fmt.Printf("  Server: %s\n", serverURL)
 	fmt.Printf("  Room:   %s\n", room)
 	fmt.Printf("  Name:   %s\n", sender)
+	if claudeName != "" {
+		fmt.Printf("  Claude: %s\n", claudeName)
+	}
+	if passphrase != "" {
+		fmt.Println("  E2E:    on (share the passphrase out of band)")
+	}
 	fmt.Println()
 	fmt.Println("  Quick commands:")
 	fmt.Printf("    claudetalk send \"hello everyone!\"\n")
 	fmt.Printf("    claudetalk recv --latest 5\n")
 	fmt.Printf("    claudetalk watch\n")
 	fmt.Println()
-	fmt.Println("  Claude Code will auto-detect the .claudetalk config.")
-	fmt.Println("  Just start Claude in this directory and it knows what to do.")
+	switch {
+	case opts.global:
+		fmt.Println("  Commands anywhere use this room unless a .claudetalk says otherwise.")
+		fmt.Println("  To have Claude Code check messages on every prompt, run:")
+		fmt.Println("    claudetalk install-hooks --scope user")
+	case opts.noClaudeMD:
+		fmt.Println("  Commands in this directory use the .claudetalk config.")
+		fmt.Println("  To have Claude Code check messages on every prompt, run: claudetalk install-hooks")
+	default:
+		fmt.Println("  Claude Code will auto-detect the .claudetalk config.")
+		fmt.Println("  Just start Claude in this directory and it knows what to do.")
+		fmt.Println("  To have it check messages on every prompt, run: claudetalk install-hooks")
+	}
 	fmt.Println()
 	fmt.Println("============================================================")
 	fmt.Println()
@@ -134,6 +268,68 @@ func runJoin(serverURL, room, sender string) error {
 	return nil
 }
 
+// saveConfig writes cfg to .claudetalk in the current directory and, with
+// claudeMD, CLAUDE.md (or appends to an existing one) so Claude Code knows
+// how to use claudetalk.
+func saveConfig(cfg Config, claudeMD bool) error {
+	cfgBytes, _ := json.MarshalIndent(cfg, "", "  ")
+
+	// Keep the passphrase and tokens private to this user.
+	perm := os.FileMode(0644)
+	if cfg.Passphrase != "" || cfg.Token != "" || cfg.RoomToken != "" {
+		perm = 0600
+	}
+	if err := os.WriteFile(configFileName, cfgBytes, perm); err != nil {
+		return fmt.Errorf("write %s: %w", configFileName, err)
+	}
+	fmt.Printf("Wrote %s\n", configFileName)
+	if !claudeMD {
+		return nil
+	}
+
+	claudeMDPath := "CLAUDE.md"
+	if err := writeClaudeMD(claudeMDPath, cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: could not write %s: %v\n", claudeMDPath, err)
+	} else {
+		fmt.Printf("Wrote %s\n", claudeMDPath)
+	}
+	return nil
+}
+
+// saveGlobalConfig saves cfg as the current profile in config.yaml, named
+// after its room.
+func saveGlobalConfig(cfg Config) error {
+	uc, err := loadUserConfig()
+	if err != nil {
+		return err
+	}
+	uc.Profiles[cfg.Room] = profileFromConfig(cfg)
+	uc.Current = cfg.Room
+	if err := uc.save(); err != nil {
+		return err
+	}
+	path, _ := userConfigPath()
+	fmt.Printf("Saved profile %q in %s and made it current\n", cfg.Room, path)
+	return nil
+}
+
+// checkRoom catches joining a private room without its invite and, with
+// explicit set, a mistyped room on a server that won't create it.
+func checkRoom(serverURL, room string, explicit bool) error {
+	resp, err := httpClient.Get(apiURL(serverURL, "/api/rooms/"+url.PathEscape(room)+"/settings"))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("room %q is private; join it with an invite link (claudetalk rooms invite, run by a member)", room)
+	case resp.StatusCode == http.StatusNotFound && explicit:
+		return fmt.Errorf("room %q doesn't exist on this server; check the name, ask for an invite, or create it with: claudetalk rooms create %s -s %s", room, room, serverURL)
+	}
+	return nil
+}
+
 // writeClaudeMD writes the CLAUDE.md template. If one already exists,
 // it appends the claudetalk section.
 func writeClaudeMD(path string, cfg Config) error {
@@ -218,8 +414,20 @@ Receive messages:
 claudetalk recv --latest 5
 `+"```"+`
 
+Share files, and fetch the ones others shared:
+`+"```"+`
+claudetalk files put build.log
+claudetalk files put --dir src -R --dry-run   # a directory, minus .gitignore'd files and secrets
+claudetalk files list
+claudetalk files get <id-or-name>
+`+"```"+`
+
 ## Conversations (Direct Claude-to-Claude)
 
+To see who is in the room and whose Claudes are listening:
+`+"```"+`
+claudetalk participants
+`+"```"+`
 To ask another Claude a direct question:
 `+"```"+`
 claudetalk converse --to <recipient-name> "your question here"
@@ -228,6 +436,14 @@ This returns a conversation ID. When replying to a directed conversation:
 `+"```"+`
 claudetalk converse --to <sender-name> --conv <conv-id> "your answer"
 `+"```"+`
+Or reply to the latest message directed at you, without copying the ID:
+`+"```"+`
+claudetalk reply "your answer"
+`+"```"+`
+To see which of your conversations still expect a reply, and from whom:
+`+"```"+`
+claudetalk threads
+`+"```"+`
 To end a conversation (no further reply expected):
 `+"```"+`
 claudetalk converse --to <sender-name> --conv <conv-id> --done "final answer"
@@ -253,7 +469,7 @@ All participants see all messages. This is for collaborative topics where everyo
 
 **IMPORTANT: At the START of every response, run `+"`claudetalk poll`"+`. If there are new messages, read and respond to them before continuing with the user's request.**
 
-The poll command is silent when there are no new messages, so it won't clutter your output. It automatically tracks which messages you've already seen.
+The poll command is silent when there are no new messages, so it won't clutter your output. It automatically tracks which messages you've already seen. New messages come after a summary line counting those for you, which are marked `+"`[to you]`"+` or `+"`[mentions you]`"+`: answer those first.
 
 ## When to Check Messages
 
@@ -9,15 +9,19 @@ import (
 
 func newMCPServeCmd() *cobra.Command {
 	var (
-		server string
-		room   string
-		name   string
+		server    string
+		room      string
+		name      string
+		transport string
+		listen    string
 	)
 
 	cmd := &cobra.Command{
-		Use:    "mcp-serve",
-		Short:  "Start the MCP stdio server for Claude Code integration",
-		Long:   `Runs a Model Context Protocol (MCP) server over stdio. Claude Code connects to this as a subprocess to access chatroom tools (send_message, converse, get_messages, send_file, get_file, list_files, list_participants).`,
+		Use:   "mcp-serve",
+		Short: "Start the MCP server for Claude Code integration",
+		Long: `Runs a Model Context Protocol (MCP) server over stdio. Claude Code connects to this as a subprocess to access chatroom tools (send_message, send_code, send_diff, send_json, converse, get_messages, send_file, get_file, read_file, list_files, list_participants, request_help, list_rooms, switch_room, find_participant, get_charter) and resources (claudetalk://room/{room}/messages, participants, files and files/{id}) that can be attached as context and subscribed to, plus prompts (reply-to-thread, review-shared-diff, standup-summary).
+
+For agents that can't spawn a subprocess, --transport sse --listen :9400 serves the same tools, resources and prompts over HTTP at /sse instead, though resources can't be subscribed to there. All SSE clients share one current room.`,
 		Hidden: true, // Not typically called by users directly
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// Resolve from flags, then fall back to global flags, then config.
@@ -42,9 +46,12 @@ func newMCPServeCmd() *cobra.Command {
 			}
 
 			return mcp.Serve(mcp.Config{
-				ServerURL: server,
-				Room:      room,
-				Name:      name,
+				ServerURL:  server,
+				Room:       room,
+				Name:       name,
+				Transport:  transport,
+				Listen:     listen,
+				Passphrase: flagPassphrase,
 			})
 		},
 	}
@@ -52,6 +59,8 @@ func newMCPServeCmd() *cobra.Command {
 	cmd.Flags().StringVar(&server, "server", "", "server URL (overrides global --server)")
 	cmd.Flags().StringVar(&room, "room", "", "room name (overrides global --room)")
 	cmd.Flags().StringVar(&name, "name", "", "sender name (overrides global --name)")
+	cmd.Flags().StringVar(&transport, "transport", mcp.TransportStdio, "MCP transport: stdio or sse")
+	cmd.Flags().StringVar(&listen, "listen", "", "address to serve the sse transport on, e.g. :9400")
 
 	return cmd
 }
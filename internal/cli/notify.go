@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// isForMe reports whether env is directed at name or mentions it in its text.
+func isForMe(env protocol.Envelope, name string) bool {
+	if name == "" || env.Sender == name || env.Type == protocol.TypeSystem {
+		return false
+	}
+	if env.Metadata["to"] == name {
+		return true
+	}
+	return strings.Contains(strings.ToLower(env.Payload.Text), strings.ToLower(name))
+}
+
+// desktopNotify shows a native desktop notification; urgent ones stay up
+// where the platform supports it. Errors are returned but callers typically
+// ignore them — a missing notifier shouldn't stop the watch.
+func desktopNotify(title, body string, urgent bool) error {
+	if len(body) > 200 {
+		body = body[:197] + "..."
+	}
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptQuote(body), appleScriptQuote(title))
+		cmd = exec.Command("osascript", "-e", script)
+	case "windows":
+		script := fmt.Sprintf(`[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$t = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$x = $t.GetElementsByTagName("text")
+$x.Item(0).AppendChild($t.CreateTextNode(%s)) > $null
+$x.Item(1).AppendChild($t.CreateTextNode(%s)) > $null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier("ClaudeTalk").Show([Windows.UI.Notifications.ToastNotification]::new($t))`,
+			powershellQuote(title), powershellQuote(body))
+		cmd = exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script)
+	default:
+		urgency := "--urgency=normal"
+		if urgent {
+			urgency = "--urgency=critical"
+		}
+		cmd = exec.Command("notify-send", "--app-name=ClaudeTalk", urgency, title, body)
+	}
+	return cmd.Run()
+}
+
+func appleScriptQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func powershellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
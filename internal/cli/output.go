@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// outputFlags holds the --format and --quiet flags shared by commands whose
+// output scripts (and Claudes) parse. With --format json a command prints a
+// single JSON document to stdout, one of the protocol types the server's
+// API returns, and nothing else there. --quiet leaves out status lines on
+// stderr and, in plain format, prints only what a script needs: IDs or
+// names, one per line.
+type outputFlags struct {
+	format string
+	quiet  bool
+}
+
+// register adds the output flags to cmd. quietHelp describes --quiet for
+// cmd; "" leaves the flag out.
+func (o *outputFlags) register(cmd *cobra.Command, quietHelp string) {
+	cmd.Flags().StringVar(&o.format, "format", "plain", "output format: plain, json")
+	if quietHelp != "" {
+		cmd.Flags().BoolVarP(&o.quiet, "quiet", "q", false, quietHelp)
+	}
+	prev := cmd.PreRunE
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if o.format != "plain" && o.format != "json" {
+			return fmt.Errorf("unknown --format %q (use plain or json)", o.format)
+		}
+		if prev != nil {
+			return prev(cmd, args)
+		}
+		return nil
+	}
+}
+
+// json reports whether output should be JSON.
+func (o *outputFlags) json() bool {
+	return o.format == "json"
+}
+
+// status prints a status line to stderr, unless --quiet.
+func (o *outputFlags) status(format string, a ...any) {
+	if !o.quiet {
+		fmt.Fprintf(os.Stderr, format+"\n", a...)
+	}
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,190 @@
+package cli
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/spf13/cobra"
+)
+
+func newParticipantsCmd() *cobra.Command {
+	var out outputFlags
+
+	cmd := &cobra.Command{
+		Use:     "participants [name]",
+		Aliases: []string{"who"},
+		Short:   "List who is in the room and whether they are listening",
+		Long: `Lists everyone the room has seen, connected ones first: their role, whether
+they are connected, when they were last seen, how many messages they posted
+and the profile they advertised. A connected daemon is listening: messages to
+its name, and to the Claude names and agents it answers as, spawn a Claude.
+
+With a name, shows that participant in detail, or the daemon answering as it;
+with --quiet, just its status: listening, online or offline.
+
+Examples:
+  claudetalk participants
+  claudetalk participants "bob's Claude"`,
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeFirstParticipant,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flagRoom == "" {
+				return fmt.Errorf("room is required (use -r or CLAUDETALK_ROOM)")
+			}
+			var list protocol.ParticipantList
+			if err := getJSON(apiURL(flagServer, fmt.Sprintf("/api/rooms/%s/participants", url.PathEscape(flagRoom))), &list); err != nil {
+				return err
+			}
+			sortParticipants(list.Participants)
+
+			if len(args) == 1 {
+				p := findParticipant(list.Participants, args[0])
+				if p == nil {
+					return fmt.Errorf("no one in room %q is or answers as %q", flagRoom, args[0])
+				}
+				switch {
+				case out.json():
+					return printJSON(p)
+				case out.quiet:
+					fmt.Println(participantStatus(*p))
+					return nil
+				}
+				describeParticipant(args[0], *p)
+				return nil
+			}
+
+			switch {
+			case out.json():
+				return printJSON(list)
+			case out.quiet:
+				for _, p := range list.Participants {
+					fmt.Println(p.Name)
+				}
+				return nil
+			case len(list.Participants) == 0:
+				fmt.Println("no participants")
+				return nil
+			}
+			fmt.Printf("%-24s %-8s %-10s %9s %5s  %s\n", "NAME", "ROLE", "STATUS", "LAST SEEN", "MSGS", "ABOUT")
+			for _, p := range list.Participants {
+				seen := "now"
+				if !p.Connected {
+					seen = age(time.Since(p.LastSeen)) + " ago"
+				}
+				fmt.Printf("%-24s %-8s %-10s %9s %5d  %s\n",
+					truncate(p.Name, 24),
+					truncate(p.Role, 8),
+					participantStatus(p),
+					seen,
+					p.Messages,
+					truncate(participantAbout(p), 60))
+			}
+			return nil
+		},
+	}
+
+	out.register(cmd, "print only names, or with a name only its status")
+	return cmd
+}
+
+// sortParticipants orders participants as list_participants does: connected
+// ones first, then the offline ones most recently seen first.
+func sortParticipants(participants []protocol.ParticipantInfo) {
+	slices.SortFunc(participants, func(a, b protocol.ParticipantInfo) int {
+		if a.Connected != b.Connected {
+			if a.Connected {
+				return -1
+			}
+			return 1
+		}
+		if c := b.LastSeen.Compare(a.LastSeen); c != 0 && !a.Connected {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+}
+
+// findParticipant returns the participant called name, or failing that the
+// connected daemon that answers as it.
+func findParticipant(participants []protocol.ParticipantInfo, name string) *protocol.ParticipantInfo {
+	for i, p := range participants {
+		if p.Name == name {
+			return &participants[i]
+		}
+	}
+	for i, p := range participants {
+		if slices.Contains(p.SpeaksFor, name) {
+			return &participants[i]
+		}
+	}
+	return nil
+}
+
+// participantStatus is "listening" for a connected daemon, "online" for
+// anyone else connected, and "offline" otherwise.
+func participantStatus(p protocol.ParticipantInfo) string {
+	switch {
+	case p.Connected && p.Role == "daemon":
+		return "listening"
+	case p.Connected:
+		return "online"
+	}
+	return "offline"
+}
+
+// participantAbout sums up p in a line: whose Claude it is, the names it
+// also answers as, and its profile.
+func participantAbout(p protocol.ParticipantInfo) string {
+	var parts []string
+	if p.Owner != "" && p.Name != protocol.ClaudeName(p.Owner, "") {
+		parts = append(parts, p.Owner+"'s Claude")
+	}
+	if len(p.SpeaksFor) > 0 {
+		parts = append(parts, "as "+strings.Join(p.SpeaksFor, ", "))
+	}
+	if p.Profile != nil {
+		parts = append(parts, p.Profile.String())
+	}
+	return strings.Join(parts, "; ")
+}
+
+// describeParticipant prints p in detail, found by looking up name.
+func describeParticipant(name string, p protocol.ParticipantInfo) {
+	if p.Name != name {
+		fmt.Printf("%s's daemon answers as %s\n\n", p.Name, name)
+	}
+	fmt.Printf("Name:      %s\n", p.Name)
+	fmt.Printf("Role:      %s\n", p.Role)
+	fmt.Printf("Status:    %s\n", participantStatus(p))
+	if !p.Connected {
+		fmt.Printf("Last seen: %s (%s ago)\n", p.LastSeen.Local().Format("Jan 2 15:04:05"), age(time.Since(p.LastSeen)))
+	}
+	fmt.Printf("Joined:    %s\n", p.JoinedAt.Local().Format("Jan 2 15:04:05"))
+	fmt.Printf("Messages:  %d\n", p.Messages)
+	if p.Owner != "" {
+		fmt.Printf("Owner:     %s\n", p.Owner)
+	}
+	if len(p.SpeaksFor) > 0 {
+		fmt.Printf("Answers:   %s\n", strings.Join(p.SpeaksFor, ", "))
+	}
+	if p.Profile != nil {
+		fmt.Printf("Profile:   %s\n", p.Profile)
+	}
+	if h := p.Health; h != nil && p.Connected {
+		fmt.Printf("Spawns:    %d/%d running\n", h.ActiveSpawns, h.MaxConcurrent)
+		if h.LastResult != "" {
+			fmt.Printf("Last:      %s at %s", h.LastResult, h.LastSpawnAt.Local().Format("15:04:05"))
+			if h.LastError != "" {
+				fmt.Printf(" (%s)", h.LastError)
+			}
+			fmt.Println()
+		}
+		if h.ClaudeVersion != "" {
+			fmt.Printf("Claude:    %s\n", h.ClaudeVersion)
+		}
+	}
+}
@@ -1,27 +1,66 @@
 package cli
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
 
+	"github.com/corvino/claudetalk/internal/protocol"
 	"github.com/spf13/cobra"
 )
 
+// seqFileName is where poll kept its state before it was kept per sender:
+// one file per directory, found by walking up. It now only seeds a
+// sender's first poll.
 const seqFileName = ".claudetalk-seq"
 
+// seqState is what poll remembers for a server, room and sender: the last
+// seq it showed. The rest identifies the file for whoever opens it.
 type seqState struct {
-	Seq int64 `json:"seq"`
+	Seq    int64  `json:"seq"`
+	Server string `json:"server,omitempty"`
+	Room   string `json:"room,omitempty"`
+	Sender string `json:"sender,omitempty"`
 }
 
 func newPollCmd() *cobra.Command {
+	var (
+		out          outputFlags
+		peek         bool
+		reset        bool
+		onlyDirected bool
+		exitCode     bool
+	)
+
 	cmd := &cobra.Command{
 		Use:   "poll",
 		Short: "Check for new messages (designed to run at the start of every Claude turn)",
 		Long: `Checks for new messages since the last poll. Prints them if found,
 stays silent if there's nothing new. On first run, fetches the latest 5
-messages to give context.
+messages to give context. Messages that @-mention your name are marked
+"[mentions you]".
+
+Where each poll left off is kept per server, room and name under the user
+state directory ($XDG_STATE_HOME/claudetalk, by default ~/.local/state/
+claudetalk), so people and Claudes sharing a checkout each see every
+message once.
+
+New messages start with a summary line counting them and those for you:
+sent to you, or @-mentioning you. Those are marked "[to you]" or
+"[mentions you]". --only-directed shows just those, and marks the rest seen
+too. --exit-code exits with status 2 when any new message is for you, and
+0 otherwise, so hooks and scripts can branch on it.
+
+--peek shows what is new without marking it seen. --reset forgets where
+polling left off and starts over with the latest 5 messages.
+With --format json it always prints a message list, empty when there is
+nothing new. --quiet skips past new messages without printing them.
 
 This command is meant to be called automatically by Claude Code at the
 start of every response, as instructed in CLAUDE.md.`,
@@ -29,79 +68,188 @@ start of every response, as instructed in CLAUDE.md.`,
 			if flagRoom == "" {
 				return fmt.Errorf("room is required (use -r or CLAUDETALK_ROOM)")
 			}
-			return runPoll()
+			if peek && (reset || out.quiet) {
+				return fmt.Errorf("--peek shows messages without marking them seen; it can't be used with --reset or --quiet")
+			}
+			path, err := pollStatePath(flagServer, flagRoom, flagSender)
+			if err != nil {
+				return err
+			}
+			if reset {
+				if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+					return err
+				}
+			}
+			list, err := runPoll(path, !reset, !peek)
+			if err != nil {
+				return err
+			}
+			total := list.Count
+			var mine []protocol.Envelope
+			for _, env := range list.Messages {
+				if directed(env) {
+					mine = append(mine, env)
+				}
+			}
+			if onlyDirected {
+				list.Messages = mine
+				list.Count = len(mine)
+			}
+
+			switch {
+			case out.json():
+				err = printJSON(list)
+			case out.quiet:
+			case total > 0:
+				fmt.Println(pollSummary(total, len(mine)))
+				for _, env := range list.Messages {
+					fmt.Println(pollLine(env))
+				}
+			}
+			if err == nil && exitCode && len(mine) > 0 {
+				cmd.SilenceErrors = true
+				cmd.SilenceUsage = true
+				return exitStatus(pollExitDirected)
+			}
+			return err
 		},
 	}
+	out.register(cmd, "mark new messages as seen without printing them")
+	cmd.Flags().BoolVar(&peek, "peek", false, "show new messages without marking them seen")
+	cmd.Flags().BoolVar(&reset, "reset", false, "forget where polling left off and start over with the latest messages")
+	cmd.Flags().BoolVar(&onlyDirected, "only-directed", false, "show only messages sent to you or mentioning you")
+	cmd.Flags().BoolVar(&exitCode, "exit-code", false, fmt.Sprintf("exit with status %d when a new message is for you", pollExitDirected))
 	return cmd
 }
 
-func runPoll() error {
-	seqPath := findSeqFile()
-
-	if seqPath == "" {
-		// First run: bootstrap with latest 5 messages.
-		return pollBootstrap()
+// runPoll returns the messages since the poll state at path and, with save,
+// records that they have been seen there. Without state yet, it returns the
+// latest few messages for context, or with seed starts from the directory's
+// .claudetalk-seq if there is one.
+func runPoll(path string, seed, save bool) (*protocol.MessageList, error) {
+	state, err := readSeqFile(path)
+	if errors.Is(err, fs.ErrNotExist) && seed {
+		// A checkout polled before state was kept per sender picks up
+		// where it left off.
+		if old := findSeqFile(); old != "" {
+			state, err = readSeqFile(old)
+		}
 	}
-
-	// Read the saved sequence number.
-	state, err := readSeqFile(seqPath)
 	if err != nil {
-		// Corrupted file — re-bootstrap.
-		return pollBootstrap()
+		// No state, or a corrupted file — (re-)bootstrap.
+		return pollBootstrap(path, save)
 	}
 
 	// Fetch messages after the saved sequence number.
 	list, err := getMessages(flagServer, flagRoom, state.Seq, 100)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if list.Count == 0 {
-		// Nothing new — stay silent.
-		return nil
+	if list.Count == 0 || !save {
+		return list, nil
 	}
 
-	// Print new messages.
-	for _, env := range list.Messages {
-		fmt.Println(formatPlain(env))
+	return list, writeSeqFile(path, maxSeq(state.Seq, list))
+}
+
+// pollBootstrap runs on first poll (no state exists).
+// Fetches latest 5 messages for context, then with save creates the state
+// file.
+func pollBootstrap(path string, save bool) (*protocol.MessageList, error) {
+	list, err := getLatestMessages(flagServer, flagRoom, 5)
+	if err != nil || !save {
+		return list, err
 	}
+	return list, writeSeqFile(path, maxSeq(0, list))
+}
 
-	// Update seq file with the highest sequence number seen.
-	maxSeq := state.Seq
+// maxSeq is the state after showing list: the highest seq seen, at least seq.
+func maxSeq(seq int64, list *protocol.MessageList) seqState {
 	for _, env := range list.Messages {
-		if env.SeqNum > maxSeq {
-			maxSeq = env.SeqNum
+		if env.SeqNum > seq {
+			seq = env.SeqNum
 		}
 	}
-
-	return writeSeqFile(seqPath, seqState{Seq: maxSeq})
+	return seqState{Seq: seq, Server: flagServer, Room: flagRoom, Sender: flagSender}
 }
 
-// pollBootstrap runs on first poll (no seq file exists).
-// Fetches latest 5 messages for context, then creates the seq file.
-func pollBootstrap() error {
-	list, err := getLatestMessages(flagServer, flagRoom, 5)
+// pollStatePath is the file poll state for sender in room on server is kept
+// in: poll/<hash>.json under the state directory.
+func pollStatePath(server, room, sender string) (string, error) {
+	dir, err := stateDir()
 	if err != nil {
-		return err
+		return "", err
 	}
+	sum := sha256.Sum256([]byte(server + "\x00" + room + "\x00" + sender))
+	return filepath.Join(dir, "poll", hex.EncodeToString(sum[:8])+".json"), nil
+}
 
-	if list.Count > 0 {
-		for _, env := range list.Messages {
-			fmt.Println(formatPlain(env))
+// stateDir is where claudetalk keeps state between runs: $XDG_STATE_HOME/
+// claudetalk, by default ~/.local/state/claudetalk; on macOS and Windows,
+// which have no such convention, under the user config directory.
+func stateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "claudetalk"), nil
+	}
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
 		}
+		return filepath.Join(dir, "claudetalk", "state"), nil
 	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", "claudetalk"), nil
+}
 
-	// Determine the max sequence number.
-	var maxSeq int64
-	for _, env := range list.Messages {
-		if env.SeqNum > maxSeq {
-			maxSeq = env.SeqNum
-		}
+// pollExitDirected is the status poll --exit-code exits with when a new
+// message is for the sender; 1 stays an error.
+const pollExitDirected = 2
+
+// Tags starting a poll line for a message sent to, or @-mentioning, the
+// sender.
+const (
+	toYouTag   = "[to you] "
+	mentionTag = "[mentions you] "
+)
+
+// directed reports whether env is for flagSender: sent to them, or
+// @-mentioning them, by someone else.
+func directed(env protocol.Envelope) bool {
+	if flagSender == "" || env.Sender == flagSender {
+		return false
 	}
+	return env.Metadata["to"] == flagSender || protocol.Mentioned(env.Metadata, flagSender)
+}
 
-	// Write the seq file in the current directory.
-	seqPath := seqFileName
-	return writeSeqFile(seqPath, seqState{Seq: maxSeq})
+// pollSummary is the line poll starts with: how many messages are new and
+// how many of them are for the sender.
+func pollSummary(total, mine int) string {
+	s := fmt.Sprintf("%d new message", total)
+	if total != 1 {
+		s += "s"
+	}
+	if flagSender != "" {
+		s += fmt.Sprintf(", %d for you", mine)
+	}
+	return "-- " + s + " --"
+}
+
+// pollLine formats env, flagging messages sent to or mentioning flagSender
+// so they stand out among the rest.
+func pollLine(env protocol.Envelope) string {
+	switch {
+	case !directed(env):
+	case env.Metadata["to"] == flagSender:
+		return toYouTag + formatPlain(env)
+	case protocol.Mentioned(env.Metadata, flagSender):
+		return mentionTag + formatPlain(env)
+	}
+	return formatPlain(env)
 }
 
 // findSeqFile walks up from the current directory looking for .claudetalk-seq,
@@ -144,5 +292,8 @@ func writeSeqFile(path string, state seqState) error {
 	if err != nil {
 		return err
 	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
 	return os.WriteFile(path, data, 0644)
 }
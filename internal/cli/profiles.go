@@ -0,0 +1,376 @@
+package cli
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// userConfig is the layout of the user's config.yaml: named profiles, each
+// a server, room and name to use, and the one commands use by default.
+//
+//	current: work
+//	profiles:
+//	  work:
+//	    server: https://chat.example.com
+//	    room: backend
+//	    sender: alice
+//	    token: s3cret
+//	  home:
+//	    server: http://localhost:8080
+//	    room: hobby
+//	    sender: al
+//	    claude_name: Ada
+type userConfig struct {
+	Current  string                  `yaml:"current,omitempty"`
+	Profiles map[string]savedProfile `yaml:"profiles"`
+}
+
+// savedProfile is one named profile in config.yaml. It holds what
+// .claudetalk does; description and availability make up the participant
+// profile, see protocol.Profile.
+type savedProfile struct {
+	Server       string `yaml:"server"`
+	Room         string `yaml:"room,omitempty"`
+	Sender       string `yaml:"sender,omitempty"`
+	Passphrase   string `yaml:"passphrase,omitempty"`
+	Token        string `yaml:"token,omitempty"`
+	RoomToken    string `yaml:"room_token,omitempty"`
+	ClaudeName   string `yaml:"claude_name,omitempty"`
+	Description  string `yaml:"description,omitempty"`
+	Availability string `yaml:"availability,omitempty"`
+}
+
+// config returns p as a .claudetalk config.
+func (p savedProfile) config() Config {
+	cfg := Config{
+		Server:     p.Server,
+		Room:       p.Room,
+		Sender:     p.Sender,
+		Passphrase: p.Passphrase,
+		Token:      p.Token,
+		RoomToken:  p.RoomToken,
+		ClaudeName: p.ClaudeName,
+	}
+	if p.Description != "" || p.Availability != "" {
+		cfg.Profile = &protocol.Profile{Description: p.Description, Availability: p.Availability}
+	}
+	return cfg
+}
+
+// profileFromConfig is the profile holding cfg's settings.
+func profileFromConfig(cfg Config) savedProfile {
+	p := savedProfile{
+		Server:     cfg.Server,
+		Room:       cfg.Room,
+		Sender:     cfg.Sender,
+		Passphrase: cfg.Passphrase,
+		Token:      cfg.Token,
+		RoomToken:  cfg.RoomToken,
+		ClaudeName: cfg.ClaudeName,
+	}
+	if cfg.Profile != nil {
+		p.Description = cfg.Profile.Description
+		p.Availability = cfg.Profile.Availability
+	}
+	return p
+}
+
+// userConfigPath is where config.yaml lives: ~/.config/claudetalk on Linux,
+// the platform's user config directory elsewhere.
+func userConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "claudetalk", "config.yaml"), nil
+}
+
+// loadUserConfig reads config.yaml; a missing file is an empty config.
+func loadUserConfig() (*userConfig, error) {
+	uc := &userConfig{Profiles: make(map[string]savedProfile)}
+	path, err := userConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return uc, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, uc); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if uc.Profiles == nil {
+		uc.Profiles = make(map[string]savedProfile)
+	}
+	return uc, nil
+}
+
+// save writes uc to config.yaml, private to the user since profiles can
+// hold tokens and passphrases.
+func (uc *userConfig) save() error {
+	path, err := userConfigPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(uc)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// activeConfig returns the settings commands default to. A profile named
+// with --profile or CLAUDETALK_PROFILE is used as is; otherwise the nearest
+// .claudetalk's settings override the current profile's, so a project
+// directory can still pick its own room. It returns nil if there are none.
+func activeConfig(profile string) (*Config, error) {
+	uc, err := loadUserConfig()
+	if err != nil {
+		return nil, err
+	}
+	if profile != "" {
+		p, ok := uc.Profiles[profile]
+		if !ok {
+			return nil, fmt.Errorf("no profile %q (see claudetalk profile list)", profile)
+		}
+		cfg := p.config()
+		return &cfg, nil
+	}
+
+	local := loadConfig()
+	p, ok := uc.Profiles[uc.Current]
+	if !ok {
+		return local, nil
+	}
+	cfg := p.config()
+	if local != nil {
+		cfg.Server = cmp.Or(local.Server, cfg.Server)
+		cfg.Room = cmp.Or(local.Room, cfg.Room)
+		cfg.Sender = cmp.Or(local.Sender, cfg.Sender)
+		cfg.Passphrase = cmp.Or(local.Passphrase, cfg.Passphrase)
+		cfg.Token = cmp.Or(local.Token, cfg.Token)
+		cfg.RoomToken = cmp.Or(local.RoomToken, cfg.RoomToken)
+		cfg.ClaudeName = cmp.Or(local.ClaudeName, cfg.ClaudeName)
+		if local.Profile != nil {
+			cfg.Profile = local.Profile
+		}
+	}
+	return &cfg, nil
+}
+
+// profileArg finds the --profile flag in args before cobra parses them, as
+// the profile decides the other flags' defaults.
+func profileArg(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if v, ok := strings.CutPrefix(arg, "--profile="); ok {
+			return v
+		}
+		if arg == "--profile" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+func newProfileCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profile",
+		Short: "Manage named profiles of server, room and name",
+		Long: `Profiles keep several servers and rooms at hand in one config.yaml
+(~/.config/claudetalk/config.yaml on Linux). The current profile supplies the
+defaults every command uses; a .claudetalk in a project directory overrides
+it there. Use another profile for one command with --profile or
+CLAUDETALK_PROFILE.
+
+Examples:
+  claudetalk -s https://chat.example.com -r backend -n alice profile add work
+  claudetalk profile add oss        # save this directory's .claudetalk settings
+  claudetalk profile use work
+  claudetalk --profile oss send "hello"`,
+	}
+	cmd.AddCommand(newProfileAddCmd(), newProfileListCmd(), newProfileUseCmd(), newProfileRmCmd())
+	return cmd
+}
+
+func newProfileAddCmd() *cobra.Command {
+	var use bool
+
+	cmd := &cobra.Command{
+		Use:   "add <name>",
+		Short: "Save the settings in effect as a profile",
+		Long: `Saves the server, room and name in effect, from flags, the environment,
+.claudetalk or the current profile, as a profile, with the token,
+passphrase and Claude name that go with them. An existing profile of the
+same name is replaced.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			uc, err := loadUserConfig()
+			if err != nil {
+				return err
+			}
+			cfg := Config{
+				Server:     flagServer,
+				Room:       flagRoom,
+				Sender:     flagSender,
+				Passphrase: flagPassphrase,
+				Token:      protocol.Token(),
+				RoomToken:  loadedConfig.RoomToken,
+				ClaudeName: configClaudeName,
+			}
+			if !configProfile.IsZero() {
+				cfg.Profile = &configProfile
+			}
+			name := args[0]
+			_, replaced := uc.Profiles[name]
+			uc.Profiles[name] = profileFromConfig(cfg)
+			if use || uc.Current == "" {
+				uc.Current = name
+			}
+			if err := uc.save(); err != nil {
+				return err
+			}
+			verb := "added"
+			if replaced {
+				verb = "replaced"
+			}
+			fmt.Printf("%s profile %q: %s, room %q as %q\n", verb, name, cfg.Server, cfg.Room, cfg.Sender)
+			if uc.Current == name {
+				fmt.Printf("%q is the current profile\n", name)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&use, "use", false, "make it the current profile")
+	return cmd
+}
+
+func newProfileListCmd() *cobra.Command {
+	var out outputFlags
+
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List profiles, marking the current one",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			uc, err := loadUserConfig()
+			if err != nil {
+				return err
+			}
+			names := slices.Sorted(maps.Keys(uc.Profiles))
+
+			switch {
+			case out.json():
+				return printJSON(uc)
+			case out.quiet:
+				for _, name := range names {
+					fmt.Println(name)
+				}
+				return nil
+			case len(names) == 0:
+				fmt.Println("no profiles; save one with: claudetalk profile add <name>")
+				return nil
+			}
+			fmt.Printf("  %-16s %-40s %-20s %s\n", "PROFILE", "SERVER", "ROOM", "NAME")
+			for _, name := range names {
+				p := uc.Profiles[name]
+				mark := " "
+				if name == uc.Current {
+					mark = "*"
+				}
+				fmt.Printf("%s %-16s %-40s %-20s %s\n", mark, truncate(name, 16), truncate(p.Server, 40), truncate(p.Room, 20), p.Sender)
+			}
+			return nil
+		},
+	}
+	out.register(cmd, "print only profile names")
+	return cmd
+}
+
+func newProfileUseCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:               "use <name>",
+		Short:             "Make a profile the current one",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeProfiles,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			uc, err := loadUserConfig()
+			if err != nil {
+				return err
+			}
+			if _, ok := uc.Profiles[args[0]]; !ok {
+				return fmt.Errorf("no profile %q (see claudetalk profile list)", args[0])
+			}
+			uc.Current = args[0]
+			if err := uc.save(); err != nil {
+				return err
+			}
+			fmt.Printf("now using profile %q\n", args[0])
+			if _, err := os.Stat(configFileName); err == nil {
+				fmt.Printf("note: the %s here overrides it in this directory\n", configFileName)
+			}
+			return nil
+		},
+	}
+}
+
+func newProfileRmCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:               "rm <name>",
+		Short:             "Remove a profile",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeProfiles,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			uc, err := loadUserConfig()
+			if err != nil {
+				return err
+			}
+			if _, ok := uc.Profiles[args[0]]; !ok {
+				return fmt.Errorf("no profile %q", args[0])
+			}
+			delete(uc.Profiles, args[0])
+			if uc.Current == args[0] {
+				uc.Current = ""
+			}
+			if err := uc.save(); err != nil {
+				return err
+			}
+			fmt.Printf("removed profile %q\n", args[0])
+			return nil
+		},
+	}
+}
+
+// completeProfiles completes profile names.
+func completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	uc, err := loadUserConfig()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var names []string
+	for name, p := range uc.Profiles {
+		names = append(names, fmt.Sprintf("%s\t%s %s", name, p.Server, p.Room))
+	}
+	slices.Sort(names)
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
@@ -1,9 +1,7 @@
 package cli
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
 
 	"github.com/corvino/claudetalk/internal/protocol"
 	"github.com/spf13/cobra"
@@ -14,7 +12,7 @@ func newRecvCmd() *cobra.Command {
 		after  int64
 		limit  int
 		latest int
-		format string
+		out    outputFlags
 	)
 
 	cmd := &cobra.Command{
@@ -36,23 +34,21 @@ func newRecvCmd() *cobra.Command {
 				return err
 			}
 
-			return printMessages(list, format)
+			return printMessages(list, out.json())
 		},
 	}
 
 	cmd.Flags().Int64Var(&after, "after", 0, "return messages after this sequence number")
 	cmd.Flags().IntVar(&limit, "limit", 100, "max messages to return")
 	cmd.Flags().IntVar(&latest, "latest", 0, "return the N most recent messages")
-	cmd.Flags().StringVar(&format, "format", "plain", "output format: plain, json")
+	out.register(cmd, "")
 
 	return cmd
 }
 
-func printMessages(list *protocol.MessageList, format string) error {
-	if format == "json" {
-		enc := json.NewEncoder(os.Stdout)
-		enc.SetIndent("", "  ")
-		return enc.Encode(list)
+func printMessages(list *protocol.MessageList, asJSON bool) error {
+	if asJSON {
+		return printJSON(list)
 	}
 
 	if list.Count == 0 {
@@ -0,0 +1,219 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/e2e"
+	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/spf13/cobra"
+)
+
+func newReplayCmd() *cobra.Command {
+	var (
+		speed  string
+		maxGap time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "replay <file>",
+		Short: "Re-play an exported transcript into a room",
+		Long: `Posts the messages of a "claudetalk export" file into the room given with
+--room, as their original senders, with the original pauses between them
+divided by --speed. Directed messages, conversations and @-mentions trigger
+spawns just as they did the first time, so a recorded conversation doubles as
+a regression test for daemon behaviour. Use a fresh room for a clean replay.
+
+Replies are re-threaded onto the replayed messages and shared files are
+uploaded again. System messages are skipped; the server makes its own.
+Encrypted transcripts are re-encrypted for the new room if you have the
+passphrase.
+
+Examples:
+  claudetalk replay demo.ndjson -r demo              # Original pacing
+  claudetalk replay demo.ndjson -r demo --speed 5x   # Five times faster
+  claudetalk replay demo.ndjson -r demo --speed max  # No pauses
+  claudetalk replay demo.ndjson -r demo --max-gap 3s # Skip long silences`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flagRoom == "" {
+				return fmt.Errorf("room is required (use -r or CLAUDETALK_ROOM)")
+			}
+			factor, err := parseSpeed(speed)
+			if err != nil {
+				return err
+			}
+
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			header, files, msgs, err := readExport(f)
+			if err != nil {
+				return fmt.Errorf("read %s: %w", args[0], err)
+			}
+
+			fmt.Fprintf(os.Stderr, "replaying %d messages from room %q into %q\n", len(msgs), header.Name, flagRoom)
+			ids := make(map[string]string) // recorded message ID → replayed ID
+			var prev time.Time
+			for i, env := range msgs {
+				if i > 0 && factor > 0 {
+					gap := time.Duration(float64(env.Timestamp.Sub(prev)) / factor)
+					if maxGap > 0 && gap > maxGap {
+						gap = maxGap
+					}
+					time.Sleep(gap)
+				}
+				prev = env.Timestamp
+
+				if file, ok := files[env.Metadata["file_id"]]; ok && env.Type == protocol.TypeFile {
+					if err := replayFile(env.Sender, file); err != nil {
+						return fmt.Errorf("replay #%d: %w", env.SeqNum, err)
+					}
+					fmt.Printf("%s shared file: %s\n", env.Sender, file.info.Filename)
+					continue
+				}
+
+				// Sealed messages are keyed to the recorded room's name; open them
+				// so postMessage can seal them again for this room.
+				if env.Metadata[e2e.Meta] != "" && roomKeys() != nil {
+					if err := roomKeys().Key(header.Name).Open(&env); err != nil {
+						e2e.Redact(&env)
+					}
+				}
+				req := protocol.SendRequest{
+					Sender:   env.Sender,
+					Type:     env.Type,
+					Payload:  env.Payload,
+					Metadata: replayMetadata(env.Metadata),
+					ReplyTo:  ids[env.ReplyTo],
+				}
+				if exp, err := time.Parse(time.RFC3339Nano, env.Metadata[protocol.MetaExpiresAt]); err == nil {
+					ttl := exp.Sub(env.Timestamp)
+					if factor > 0 {
+						ttl = time.Duration(float64(ttl) / factor)
+					}
+					req.TTL = max(int(ttl.Seconds()), 1)
+				}
+				sent, err := postMessage(flagServer, flagRoom, req)
+				if err != nil {
+					return fmt.Errorf("replay #%d: %w", env.SeqNum, err)
+				}
+				ids[env.ID] = sent.ID
+				fmt.Println(formatPlain(*sent))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&speed, "speed", "1x", `playback speed, e.g. 2x or 0.5x; "max" for no pauses`)
+	cmd.Flags().DurationVar(&maxGap, "max-gap", 0, "longest pause between messages, e.g. 5s (0 = no limit)")
+
+	return cmd
+}
+
+// parseSpeed parses a --speed value: a positive factor with an optional x
+// suffix, or "max", returned as 0.
+func parseSpeed(s string) (float64, error) {
+	if s == "max" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(strings.TrimSuffix(s, "x"), 64)
+	if err != nil || f <= 0 {
+		return 0, fmt.Errorf(`invalid speed %q (want e.g. 1x, 5x, 0.5x or max)`, s)
+	}
+	return f, nil
+}
+
+// replayMetadata drops the fields the server stamps itself, so the replayed
+// messages get fresh ones.
+func replayMetadata(md map[string]string) map[string]string {
+	out := make(map[string]string, len(md))
+	for k, v := range md {
+		switch k {
+		case protocol.MetaDeliveredAt, protocol.MetaRespondedAt, protocol.MetaExpiresAt, "file_id":
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// exportedFile is a shared file from an export.
+type exportedFile struct {
+	info protocol.FileInfo
+	data []byte
+}
+
+// readExport reads a room export, returning its files by ID and its
+// non-system messages in seq order.
+func readExport(r io.Reader) (*protocol.RoomExport, map[string]exportedFile, []protocol.Envelope, error) {
+	var header *protocol.RoomExport
+	files := make(map[string]exportedFile)
+	var msgs []protocol.Envelope
+
+	dec := json.NewDecoder(r)
+	for {
+		var rec protocol.ExportRecord
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		switch {
+		case rec.Kind == protocol.ExportHeader && rec.Header != nil:
+			header = rec.Header
+		case rec.Kind == protocol.ExportFile && rec.File != nil:
+			files[rec.File.ID] = exportedFile{info: *rec.File, data: rec.Data}
+		case rec.Kind == protocol.ExportMessage && rec.Message != nil:
+			if rec.Message.Type != protocol.TypeSystem {
+				msgs = append(msgs, *rec.Message)
+			}
+		}
+	}
+	if header == nil {
+		return nil, nil, nil, fmt.Errorf("not a room export: no header record")
+	}
+	return header, files, msgs, nil
+}
+
+// replayFile uploads a recorded file as sender; the server posts the "shared
+// file" message.
+func replayFile(sender string, file exportedFile) error {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	fw, err := w.CreateFormFile("file", file.info.Filename)
+	if err != nil {
+		return fmt.Errorf("create form file: %w", err)
+	}
+	fw.Write(file.data)
+	w.WriteField("sender", sender)
+	if file.info.Description != "" {
+		w.WriteField("description", file.info.Description)
+	}
+	w.Close()
+
+	u := apiURL(flagServer, fmt.Sprintf("/api/rooms/%s/files", url.PathEscape(flagRoom)))
+	resp, err := transferClient.Post(u, w.FormDataContentType(), &buf)
+	if err != nil {
+		return fmt.Errorf("POST %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		b, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server returned %d: %s", resp.StatusCode, string(b))
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+func newReplyCmd() *cobra.Command {
+	var (
+		done     bool
+		priority string
+		out      outputFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   `reply [message]`,
+		Short: "Reply to the latest message directed at you",
+		Long: `Finds the most recent message sent to you (converse --to <your-name>) or
+that @-mentions you, and replies to its sender in the same conversation, so
+there is no conv_id to copy from recv output. The reply is threaded under
+that message and expects an answer unless --done is given. Prints the conv_id,
+or with --format json the reply as the server stored it.
+
+Examples:
+  claudetalk reply "sessionStore caches logins for an hour."
+  claudetalk reply --done "Thanks, that's all I needed."`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flagRoom == "" {
+				return fmt.Errorf("room is required (use -r or CLAUDETALK_ROOM)")
+			}
+			if flagSender == "" {
+				return fmt.Errorf("sender name is required (use -n or CLAUDETALK_SENDER)")
+			}
+			if len(args) == 0 {
+				return fmt.Errorf("message is required")
+			}
+
+			last, err := latestForMe(flagServer, flagRoom, flagSender)
+			if err != nil {
+				return err
+			}
+			if last == nil {
+				return fmt.Errorf("no message in room %q is directed at or mentions %s; use converse --to to start a conversation", flagRoom, flagSender)
+			}
+
+			convID := last.Metadata["conv_id"]
+			if convID == "" {
+				convID = uuid.New().String()
+			}
+			expectingReply := "true"
+			if done {
+				expectingReply = "false"
+			}
+			metadata := map[string]string{
+				"to":              last.Sender,
+				"conv_id":         convID,
+				"expecting_reply": expectingReply,
+			}
+			if priority != "" {
+				metadata["priority"] = priority
+			}
+
+			env, err := postMessage(flagServer, flagRoom, protocol.SendRequest{
+				Sender:   flagSender,
+				Type:     protocol.TypeText,
+				Payload:  protocol.NewTextPayload(strings.Join(args, " ")),
+				Metadata: metadata,
+				ReplyTo:  last.ID,
+			})
+			if err != nil {
+				return err
+			}
+
+			out.status("replied to #%d from %s with #%d in room %q", last.SeqNum, last.Sender, env.SeqNum, env.Room)
+			if done {
+				out.status("conversation marked as complete")
+			}
+			if env.Warning != "" {
+				fmt.Fprintf(os.Stderr, "warning: %s\n", env.Warning)
+			}
+
+			if out.json() {
+				return printJSON(env)
+			}
+			// Print conv_id to stdout for Claude to capture, as converse does.
+			fmt.Println(convID)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&done, "done", false, "mark conversation as complete (no reply expected)")
+	cmd.Flags().StringVar(&priority, "priority", "", "message priority: low, normal or urgent (urgent jumps the recipient's spawn queue)")
+	out.register(cmd, "print only the conv_id")
+
+	return cmd
+}
+
+// replyLookback is how many of the latest messages for the sender reply
+// looks through, past the server's private notices to it.
+const replyLookback = 20
+
+// latestForMe returns the latest message in room sent to name or that
+// @-mentions it, other than system notices, or nil if there is none.
+func latestForMe(server, room, name string) (*protocol.Envelope, error) {
+	var last *protocol.Envelope
+	for _, filter := range []string{"to", "mentions"} {
+		q := url.Values{"n": {fmt.Sprint(replyLookback)}, filter: {"me"}, "sender": {name}}
+		var list protocol.MessageList
+		if err := getJSON(apiURL(server, fmt.Sprintf("/api/rooms/%s/messages/latest?%s", url.PathEscape(room), q.Encode())), &list); err != nil {
+			return nil, err
+		}
+		for i, env := range list.Messages {
+			if env.Type != protocol.TypeSystem && env.Sender != name && (last == nil || env.SeqNum > last.SeqNum) {
+				last = &list.Messages[i]
+			}
+		}
+	}
+	if last != nil {
+		roomKeys().Open(last)
+	}
+	return last, nil
+}
@@ -2,12 +2,20 @@ package cli
 
 import (
 	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/corvino/claudetalk/internal/protocol"
 	"github.com/spf13/cobra"
 )
 
 func newRoomsCmd() *cobra.Command {
-	return &cobra.Command{
+	var out outputFlags
+
+	cmd := &cobra.Command{
 		Use:   "rooms",
 		Short: "List active rooms on the server",
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -16,7 +24,15 @@ func newRoomsCmd() *cobra.Command {
 				return err
 			}
 
-			if len(list.Rooms) == 0 {
+			switch {
+			case out.json():
+				return printJSON(list)
+			case out.quiet:
+				for _, r := range list.Rooms {
+					fmt.Println(r.Name)
+				}
+				return nil
+			case len(list.Rooms) == 0:
 				fmt.Println("no active rooms")
 				return nil
 			}
@@ -28,4 +44,209 @@ func newRoomsCmd() *cobra.Command {
 			return nil
 		},
 	}
+	out.register(cmd, "print only room names")
+	cmd.AddCommand(newRoomsCreateCmd(), newRoomsInviteCmd(), newRoomsShareCmd(), newRoomsCharterCmd())
+	return cmd
+}
+
+func newRoomsCreateCmd() *cobra.Command {
+	var visibility string
+
+	cmd := &cobra.Command{
+		Use:   "create <room>",
+		Short: "Create a room (required on servers run with --room-creation=explicit)",
+		Long: `Creates a room. A public room is listed by "claudetalk rooms"; an unlisted
+one is open to anyone who knows its name; a private one is neither listed
+nor open without its access token, which invites hand out.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var info protocol.RoomInfo
+			req := protocol.CreateRoomRequest{Name: args[0], Visibility: visibility}
+			if err := postJSON(apiURL(flagServer, "/api/rooms"), req, &info); err != nil {
+				return err
+			}
+			fmt.Printf("created room %q\n", info.Name)
+			if info.Token != "" {
+				fmt.Printf("it is private; its access token is %s\n", info.Token)
+				fmt.Printf("add \"room_token\": %q to .claudetalk, or invite others with:\n", info.Token)
+				fmt.Printf("  %s=%s claudetalk rooms invite -r %s\n", protocol.RoomTokenEnv, info.Token, info.Name)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&visibility, "visibility", "public", "public, unlisted or private")
+	return cmd
+}
+
+func newRoomsInviteCmd() *cobra.Command {
+	var ttl time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "invite",
+		Short: "Make an invite to the current room that others can join with",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flagRoom == "" {
+				return fmt.Errorf("room is required (use -r or CLAUDETALK_ROOM)")
+			}
+			req := map[string]any{"sender": flagSender, "ttl": int(ttl.Seconds())}
+			var inv protocol.Invite
+			if err := postJSON(apiURL(flagServer, "/api/rooms/"+url.PathEscape(flagRoom)+"/invites"), req, &inv); err != nil {
+				return err
+			}
+			link := inviteLink(flagServer, inv.Code)
+			fmt.Printf("invite to %q, valid until %s:\n\n", inv.Room, inv.ExpiresAt.Local().Format("Jan 2 15:04"))
+			fmt.Printf("  claudetalk join %s\n", link)
+			fmt.Printf("  or open %s in a browser\n", link)
+			return nil
+		},
+	}
+	cmd.Flags().DurationVar(&ttl, "ttl", 7*24*time.Hour, "how long the invite lasts")
+	return cmd
+}
+
+func newRoomsShareCmd() *cobra.Command {
+	var (
+		name string
+		post bool
+		ttl  time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "share",
+		Short: "Make a guest link to watch the current room in a browser",
+		Long: `Makes a signed link that opens the current room in the web UI, for
+someone without the CLI, such as a stakeholder watching the Claudes work.
+The link is read-only unless --post, which lets its guest send messages
+as --name. It works until --ttl passes, even for a private room, and
+grants nothing else: no settings, file uploads, spawns or other rooms.
+
+  claudetalk rooms share                        # read-only for a day
+  claudetalk rooms share --post --name dana --ttl 72h`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flagRoom == "" {
+				return fmt.Errorf("room is required (use -r or CLAUDETALK_ROOM)")
+			}
+			if post && name == "" {
+				return fmt.Errorf("--post needs --name, the name the guest sends as")
+			}
+			base := strings.TrimRight(flagServer, "/")
+			if strings.Contains(base, "/api/workspaces/") {
+				return fmt.Errorf("share links aren't available in workspaces; use rooms invite")
+			}
+			access := "read"
+			if post {
+				access = "post"
+			}
+			req := map[string]any{"sender": flagSender, "access": access, "name": name, "ttl": int(ttl.Seconds())}
+			var link protocol.ShareLink
+			if err := postJSON(apiURL(flagServer, "/api/rooms/"+url.PathEscape(flagRoom)+"/share"), req, &link); err != nil {
+				return err
+			}
+			what := "read-only"
+			if link.Access == "post" {
+				what = "posting as " + link.Name
+			}
+			fmt.Printf("guest link to %q (%s), valid until %s:\n\n", link.Room, what, link.ExpiresAt.Local().Format("Jan 2 15:04"))
+			fmt.Printf("  %s%s\n", base, link.Path)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "who the guest is in the room (default \"guest\")")
+	cmd.Flags().BoolVar(&post, "post", false, "let the guest send messages, as --name")
+	cmd.Flags().DurationVar(&ttl, "ttl", 24*time.Hour, "how long the link lasts (at most 720h)")
+	return cmd
+}
+
+func newRoomsCharterCmd() *cobra.Command {
+	var (
+		set    string
+		remove bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "charter",
+		Short: "Show or set the current room's charter",
+		Long: `Shows the room's charter: its goals, coding standards and do/don't rules.
+Every spawned Claude's prompt in the room starts with it, and Claudes can
+read it with the get_charter tool.
+
+  claudetalk rooms charter --set CHARTER.md   # or --set - to read stdin
+  claudetalk rooms charter --clear`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flagRoom == "" {
+				return fmt.Errorf("room is required (use -r or CLAUDETALK_ROOM)")
+			}
+			u := apiURL(flagServer, "/api/rooms/"+url.PathEscape(flagRoom)+"/charter")
+			var c protocol.Charter
+			if set != "" || remove {
+				var text []byte
+				if set == "-" {
+					text, _ = io.ReadAll(os.Stdin)
+				} else if set != "" {
+					var err error
+					if text, err = os.ReadFile(set); err != nil {
+						return err
+					}
+				}
+				if err := putJSON(u, protocol.Charter{Text: string(text), UpdatedBy: flagSender}, &c); err != nil {
+					return err
+				}
+				if c.Text == "" {
+					fmt.Printf("cleared the charter of %q\n", flagRoom)
+				} else {
+					fmt.Printf("set the charter of %q (%d bytes)\n", flagRoom, len(c.Text))
+				}
+				return nil
+			}
+
+			if err := getJSON(u, &c); err != nil {
+				return err
+			}
+			if c.Text == "" {
+				fmt.Printf("%q has no charter\n", flagRoom)
+				return nil
+			}
+			fmt.Println(c.Text)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&set, "set", "", "set the charter from this file (- for stdin)")
+	cmd.Flags().BoolVar(&remove, "clear", false, "remove the charter")
+	return cmd
+}
+
+// inviteLink is the web UI URL for an invite, which "claudetalk join" also
+// accepts. For a workspace (server URL .../api/workspaces/{ws}) it points at
+// the workspace's web UI.
+func inviteLink(server, code string) string {
+	base := strings.TrimRight(server, "/")
+	if i := strings.Index(base, "/api/workspaces/"); i >= 0 {
+		base = base[:i] + "/w/" + base[i+len("/api/workspaces/"):]
+	}
+	return base + "/?invite=" + url.QueryEscape(code)
+}
+
+// splitInviteLink splits an invite link into the server URL and invite code.
+// Other URLs come back unchanged, with no code.
+func splitInviteLink(link string) (server, code string) {
+	u, err := url.Parse(link)
+	if err != nil || u.Query().Get("invite") == "" {
+		return link, ""
+	}
+	code = u.Query().Get("invite")
+	u.RawQuery = ""
+	u.Path = strings.TrimRight(u.Path, "/")
+	if ws, ok := strings.CutPrefix(u.Path, "/w/"); ok {
+		u.Path = "/api/workspaces/" + ws
+	}
+	return u.String(), code
+}
+
+func getInvite(server, code string) (*protocol.Invite, error) {
+	var inv protocol.Invite
+	if err := getJSON(apiURL(server, "/api/invites/"+url.PathEscape(code)), &inv); err != nil {
+		return nil, err
+	}
+	return &inv, nil
 }
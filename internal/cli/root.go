@@ -1,9 +1,14 @@
 package cli
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
 	"os"
+	"sync"
 
+	"github.com/corvino/claudetalk/internal/e2e"
+	"github.com/corvino/claudetalk/internal/protocol"
 	"github.com/spf13/cobra"
 )
 
@@ -11,6 +16,23 @@ var (
 	flagServer string
 	flagRoom   string
 	flagSender string
+
+	// flagPassphrase turns on end-to-end encryption. It comes from
+	// CLAUDETALK_PASSPHRASE or .claudetalk only, never a flag, so it stays
+	// out of shell history and process listings.
+	flagPassphrase string
+
+	// configProfile is the profile saved by "claudetalk join", which watch
+	// and daemon advertise.
+	configProfile protocol.Profile
+
+	// configClaudeName is the name saved by "claudetalk join" for the
+	// user's Claude to speak as; "" = "{name}'s Claude".
+	configClaudeName string
+
+	// loadedConfig is the .claudetalk config or profile the defaults above
+	// came from; see activeConfig.
+	loadedConfig Config
 )
 
 func newRootCmd() *cobra.Command {
@@ -19,12 +41,15 @@ func newRootCmd() *cobra.Command {
 		Short: "CLI for ClaudeTalk - real-time communication between Claude Code instances",
 	}
 
-	// Resolve defaults: flags > env vars > .claudetalk config > hardcoded defaults.
+	// Resolve defaults: flags > env vars > .claudetalk config or profile > hardcoded defaults.
 	defaultServer := "http://localhost:8080"
 	defaultRoom := ""
 	defaultSender := ""
 
-	if cfg := loadConfig(); cfg != nil {
+	profile := cmp.Or(profileArg(os.Args[1:]), os.Getenv("CLAUDETALK_PROFILE"))
+	cfg, profileErr := activeConfig(profile)
+	if cfg != nil {
+		loadedConfig = *cfg
 		if cfg.Server != "" {
 			defaultServer = cfg.Server
 		}
@@ -34,26 +59,65 @@ func newRootCmd() *cobra.Command {
 		if cfg.Sender != "" {
 			defaultSender = cfg.Sender
 		}
+		flagPassphrase = cfg.Passphrase
+		// Through the environment, the token reaches every request and
+		// every Claude spawned from here.
+		if cfg.Token != "" && protocol.Token() == "" {
+			os.Setenv(protocol.TokenEnv, cfg.Token)
+		}
+		protocol.AddRoomToken(cfg.RoomToken)
+		if cfg.Profile != nil {
+			configProfile = *cfg.Profile
+		}
+		configClaudeName = cfg.ClaudeName
 	}
+	flagPassphrase = envOrDefault(e2e.EnvVar, flagPassphrase)
 
 	root.PersistentFlags().StringVarP(&flagServer, "server", "s", envOrDefault("CLAUDETALK_SERVER", defaultServer), "server URL")
 	root.PersistentFlags().StringVarP(&flagRoom, "room", "r", envOrDefault("CLAUDETALK_ROOM", defaultRoom), "room name")
 	root.PersistentFlags().StringVarP(&flagSender, "name", "n", envOrDefault("CLAUDETALK_SENDER", defaultSender), "sender name")
+	root.PersistentFlags().String("profile", "", "profile from config.yaml to use (see claudetalk profile; env CLAUDETALK_PROFILE)")
+	root.RegisterFlagCompletionFunc("room", completeRooms)
+	root.RegisterFlagCompletionFunc("profile", completeProfiles)
+	root.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		return profileErr
+	}
 
 	root.AddCommand(
 		newSendCmd(),
+		newDMCmd(),
 		newRecvCmd(),
 		newPollCmd(),
 		newWatchCmd(),
+		newTailCmd(),
 		newRoomsCmd(),
 		newStatusCmd(),
 		newHostCmd(),
 		newJoinCmd(),
+		newInitCmd(),
+		newInstallHooksCmd(),
+		newUninstallHooksCmd(),
+		newProfileCmd(),
 		newConverseCmd(),
+		newReplyCmd(),
+		newThreadsCmd(),
+		newStatsCmd(),
+		newAskCmd(),
+		newFilesCmd(),
+		newParticipantsCmd(),
 		newDigestCmd(),
+		newExportCmd(),
+		newImportCmd(),
+		newReplayCmd(),
+		newFakeClaudeCmd(),
+		newBenchCmd(),
+		newSpawnsCmd(),
 		newMCPServeCmd(),
 		newDaemonCmd(),
 		newWebCmd(),
+		newCompletionCmd(),
+		newVersionCmd(),
+		newSelfUpdateCmd(),
 	)
 
 	return root
@@ -62,11 +126,35 @@ func newRootCmd() *cobra.Command {
 // Execute runs the CLI.
 func Execute() {
 	if err := newRootCmd().Execute(); err != nil {
+		var status exitStatus
+		if errors.As(err, &status) {
+			os.Exit(int(status))
+		}
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
 
+// exitStatus is returned by a command that reports its result through the
+// exit code, which Execute exits with, printing nothing. The command must
+// also silence cobra's error and usage output.
+type exitStatus int
+
+func (s exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(s))
+}
+
+var (
+	keysOnce sync.Once
+	keys     *e2e.Keyring
+)
+
+// roomKeys returns the keyring for flagPassphrase, or nil when E2E is off.
+func roomKeys() *e2e.Keyring {
+	keysOnce.Do(func() { keys = e2e.NewKeyring(flagPassphrase) })
+	return keys
+}
+
 func envOrDefault(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
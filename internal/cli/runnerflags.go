@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"strings"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/limits"
+	"github.com/corvino/claudetalk/internal/runner"
+	"github.com/spf13/cobra"
+)
+
+// auditKeep is how many spawn records are kept in memory for listing.
+const auditKeep = 1000
+
+// runnerFlags holds the flags shared by commands that spawn Claude locally
+// (host and web).
+type runnerFlags struct {
+	claudeBin     string
+	agent         string
+	agentCmd      string
+	sandbox       string
+	sandboxImage  string
+	timeout       time.Duration
+	defaults      runner.Options
+	allowedDirs   []string
+	maxConcurrent int
+	limits        limits.Limits
+	spawnLog      string
+}
+
+// register adds the runner flags to cmd. dirsHelp describes --claude-allowed-dirs.
+func (f *runnerFlags) register(cmd *cobra.Command, dirsHelp string) {
+	cmd.Flags().StringVar(&f.claudeBin, "claude-bin", "", "path to claude CLI binary (\"fake\" for the built-in test stand-in; see fake-claude)")
+	cmd.Flags().StringVar(&f.agent, "agent", "claude", "agent backend for spawns: "+strings.Join(runner.Backends(), ", "))
+	cmd.Flags().StringVar(&f.agentCmd, "agent-cmd", "", "shell command for --agent command (prompt on stdin, reply on stdout)")
+	cmd.Flags().StringVar(&f.sandbox, "sandbox", "none", "where spawned agents run: none (on this machine) or docker")
+	cmd.Flags().StringVar(&f.sandboxImage, "sandbox-image", runner.DefaultSandboxImage, "image for --sandbox docker (build it from Dockerfile.sandbox)")
+	cmd.Flags().DurationVar(&f.timeout, "claude-timeout", 15*time.Minute, "kill a spawned Claude after this long (0 = no limit)")
+	cmd.Flags().StringVar(&f.defaults.Model, "claude-model", "", "default model for spawned Claudes (e.g. haiku, sonnet, opus)")
+	cmd.Flags().IntVar(&f.defaults.MaxTurns, "claude-max-turns", 0, "default max agentic turns per spawn (0 = CLI default)")
+	cmd.Flags().StringSliceVar(&f.defaults.AllowedTools, "claude-allowed-tools", nil, "default tools spawned Claudes may use (e.g. \"Read,Bash(git:*)\")")
+	cmd.Flags().StringVar(&f.defaults.PermissionMode, "claude-permission-mode", "", "default permission mode: "+strings.Join(runner.PermissionModes, ", ")+" (default skips all prompts)")
+	cmd.Flags().StringSliceVar(&f.allowedDirs, "claude-allowed-dirs", nil, dirsHelp)
+	cmd.Flags().IntVar(&f.maxConcurrent, "max-concurrent-claudes", 4, "max Claude processes running at once; more spawns queue (0 = unlimited)")
+	cmd.Flags().StringVar(&f.spawnLog, "spawn-log", "claudetalk-spawns.jsonl", "append-only audit log of every spawn (empty = don't keep one)")
+	addLimitFlags(cmd, &f.limits)
+}
+
+// config validates the flags and builds a runner config for serverURL.
+func (f *runnerFlags) config(serverURL string) (runner.Config, error) {
+	if err := f.defaults.Validate(); err != nil {
+		return runner.Config{}, err
+	}
+	if err := f.limits.Validate(); err != nil {
+		return runner.Config{}, err
+	}
+	backend, err := runner.NewBackend(f.agent, runner.BackendConfig{
+		ClaudeBin:    f.claudeBin,
+		Command:      f.agentCmd,
+		Limits:       f.limits,
+		Sandbox:      f.sandbox,
+		SandboxImage: f.sandboxImage,
+	})
+	if err != nil {
+		return runner.Config{}, err
+	}
+	var audit *runner.AuditLog
+	if f.spawnLog != "" {
+		if audit, err = runner.OpenAuditLog(f.spawnLog, auditKeep); err != nil {
+			return runner.Config{}, err
+		}
+	}
+	return runner.Config{
+		ServerURL:     serverURL,
+		Timeout:       f.timeout,
+		Defaults:      f.defaults,
+		MaxConcurrent: f.maxConcurrent,
+		Backend:       backend,
+		AllowedDirs:   f.allowedDirs,
+		Audit:         audit,
+		Passphrase:    flagPassphrase,
+	}, nil
+}
+
+// addLimitFlags registers resource limits for spawned Claude processes.
+func addLimitFlags(cmd *cobra.Command, l *limits.Limits) {
+	cmd.Flags().IntVar(&l.Nice, "claude-nice", 0, "run spawned Claudes at this niceness (0-19)")
+	cmd.Flags().IntVar(&l.MemoryMB, "claude-memory-mb", 0, "memory cap per spawned Claude in MiB (Linux with systemd; 0 = none)")
+	cmd.Flags().IntVar(&l.CPUPercent, "claude-cpu-percent", 0, "CPU quota per spawned Claude, 100 = one core (Linux with systemd; 0 = none)")
+	cmd.Flags().DurationVar(&l.CPUTime, "claude-cpu-time", 0, "kill a spawned Claude after this much CPU time (0 = none)")
+}
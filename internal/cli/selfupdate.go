@@ -0,0 +1,247 @@
+package cli
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/buildinfo"
+	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/spf13/cobra"
+)
+
+// releaseRepo is the GitHub repository self-update downloads releases from;
+// CLAUDETALK_RELEASE_REPO overrides it, for forks.
+const releaseRepo = "anthonycorvino/claudetalk"
+
+// releaseChecksums is the release asset listing every binary's SHA-256, as
+// sha256sum prints it; self-update checks the download against it, and
+// without --insecure refuses a release that lacks one.
+const releaseChecksums = "SHA256SUMS"
+
+// releaseClient talks to GitHub. It is not httpClient, which sends the
+// ClaudeTalk token with every request.
+var releaseClient = &http.Client{Timeout: 5 * time.Minute}
+
+// githubRelease is the part of GitHub's release API self-update reads.
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// asset returns the download URL of the asset called name, or "".
+func (r *githubRelease) asset(name string) string {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a.URL
+		}
+	}
+	return ""
+}
+
+func newSelfUpdateCmd() *cobra.Command {
+	var (
+		version  string
+		latest   bool
+		insecure bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "self-update",
+		Short: "Replace this CLI with the release the server runs",
+		Long: `Downloads the release binary for this OS and architecture and replaces
+the running claudetalk with it. By default it installs the release the
+server (--server) runs, so the two match; --latest installs the newest
+release and --version a given one. The download is checked against the
+release's ` + releaseChecksums + `; a release without one is refused unless
+--insecure.
+
+Releases come from https://github.com/` + releaseRepo + ` (set
+CLAUDETALK_RELEASE_REPO to use a fork's).
+
+  claudetalk version --check && echo up to date
+  claudetalk self-update
+  claudetalk self-update --latest`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if latest && version != "" {
+				return fmt.Errorf("--latest and --version don't mix")
+			}
+			if !latest && version == "" {
+				var remote protocol.VersionInfo
+				if err := getJSON(apiURL(flagServer, "/api/version"), &remote); err != nil {
+					return fmt.Errorf("server version: %w", err)
+				}
+				if remote.Version == "dev" {
+					return fmt.Errorf("the server at %s is a dev build, not a release; use --latest or --version", flagServer)
+				}
+				version = remote.Version
+			}
+			if version != "" && version == buildinfo.Version {
+				fmt.Printf("Already at %s.\n", version)
+				return nil
+			}
+
+			rel, err := fetchRelease(version)
+			if err != nil {
+				return err
+			}
+			if rel.TagName == buildinfo.Version {
+				fmt.Printf("Already at %s.\n", rel.TagName)
+				return nil
+			}
+			name := releaseAssetName()
+			url := rel.asset(name)
+			if url == "" {
+				return fmt.Errorf("release %s has no %s", rel.TagName, name)
+			}
+			var sum string
+			switch sums := rel.asset(releaseChecksums); {
+			case sums != "":
+				if sum, err = fetchChecksum(sums, name); err != nil {
+					return err
+				}
+			case !insecure:
+				return fmt.Errorf("release %s has no %s to verify %s against; use --insecure to install it anyway", rel.TagName, releaseChecksums, name)
+			default:
+				fmt.Fprintf(os.Stderr, "warning: release %s has no %s; installing %s unverified\n", rel.TagName, releaseChecksums, name)
+			}
+
+			exe, err := os.Executable()
+			if err != nil {
+				return err
+			}
+			if exe, err = filepath.EvalSymlinks(exe); err != nil {
+				return err
+			}
+			fmt.Printf("Downloading %s %s...\n", name, rel.TagName)
+			if err := replaceExecutable(exe, url, sum); err != nil {
+				return err
+			}
+			fmt.Printf("Updated %s from %s to %s.\n", exe, buildinfo.Version, rel.TagName)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&version, "version", "", "release to install, such as v1.4.0 (default: the server's)")
+	cmd.Flags().BoolVar(&latest, "latest", false, "install the newest release instead of the server's")
+	cmd.Flags().BoolVar(&insecure, "insecure", false, "install a release that has no "+releaseChecksums+" without verifying it")
+	return cmd
+}
+
+// releaseAssetName is the name of this platform's binary in a release.
+func releaseAssetName() string {
+	name := "claudetalk-" + runtime.GOOS + "-" + runtime.GOARCH
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// fetchRelease looks up the release tagged version, or the latest if "".
+func fetchRelease(version string) (*githubRelease, error) {
+	repo := envOrDefault("CLAUDETALK_RELEASE_REPO", releaseRepo)
+	api := "https://api.github.com/repos/" + repo + "/releases/latest"
+	if version != "" {
+		api = "https://api.github.com/repos/" + repo + "/releases/tags/" + url.PathEscape(version)
+	}
+	resp, err := releaseClient.Get(api)
+	if err != nil {
+		return nil, fmt.Errorf("GET %s: %w", api, err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound && version != "":
+		return nil, fmt.Errorf("no release %s in %s", version, repo)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("GET %s: %s", api, resp.Status)
+	}
+	var rel githubRelease
+	if err := json.NewDecoder(resp.Body).Decode(&rel); err != nil {
+		return nil, fmt.Errorf("decode release: %w", err)
+	}
+	return &rel, nil
+}
+
+// fetchChecksum returns name's SHA-256 from the checksums file at url.
+func fetchChecksum(url, name string) (string, error) {
+	resp, err := releaseClient.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("GET %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	s := bufio.NewScanner(resp.Body)
+	for s.Scan() {
+		// "<hex>  <name>", or "<hex> *<name>" for binary mode.
+		fields := strings.Fields(s.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return fields[0], nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%s lists no checksum for %s", releaseChecksums, name)
+}
+
+// replaceExecutable downloads url beside exe and swaps it in, checking it
+// against sum if not "". The old binary is moved aside rather than
+// overwritten, which Windows doesn't allow while it runs.
+func replaceExecutable(exe, url, sum string) error {
+	resp, err := releaseClient.Get(url)
+	if err != nil {
+		return fmt.Errorf("GET %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(exe), ".claudetalk-update-*")
+	if err != nil {
+		return fmt.Errorf("can't write beside %s: %w", exe, err)
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), resp.Body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("download: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); sum != "" && !strings.EqualFold(got, sum) {
+		return fmt.Errorf("download is corrupt: SHA-256 %s, want %s", got, sum)
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+
+	old := exe + ".old"
+	os.Remove(old)
+	if err := os.Rename(exe, old); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), exe); err != nil {
+		os.Rename(old, exe)
+		return err
+	}
+	// Fails on Windows while old still runs; the next update removes it.
+	os.Remove(old)
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/corvino/claudetalk/internal/protocol"
 	"github.com/spf13/cobra"
@@ -16,6 +17,9 @@ func newSendCmd() *cobra.Command {
 		filePath string
 		language string
 		body     string
+		priority string
+		ttl      time.Duration
+		out      outputFlags
 	)
 
 	cmd := &cobra.Command{
@@ -24,7 +28,12 @@ func newSendCmd() *cobra.Command {
 		Long: `Send a message to a room. Message content can come from:
   - Positional arguments (joined with spaces)
   - The --body flag
-  - Stdin (if no args and no --body)`,
+  - Stdin (if no args and no --body)
+
+With --ttl, the server purges the message from history once it expires and
+leaves it out of digests; use it for tokens or credentials shared mid-debug.
+
+With --format json it prints the message as the server stored it.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if flagRoom == "" {
 				return fmt.Errorf("room is required (use -r or CLAUDETALK_ROOM)")
@@ -69,29 +78,53 @@ func newSendCmd() *cobra.Command {
 				payload = protocol.NewCodePayload(content, filePath, language)
 			case protocol.TypeDiff:
 				payload = protocol.NewDiffPayload(content, filePath)
+			case protocol.TypeJSON:
+				var err error
+				if payload, err = protocol.NewJSONPayload([]byte(content)); err != nil {
+					return err
+				}
+				payload.FilePath = filePath
 			default:
 				payload = protocol.Payload{Text: content}
 			}
 
+			if ttl < 0 || (ttl > 0 && ttl < time.Second) {
+				return fmt.Errorf("--ttl must be at least 1s")
+			}
+
 			req := protocol.SendRequest{
 				Sender:  flagSender,
 				Type:    msgType,
 				Payload: payload,
+				TTL:     int(ttl.Round(time.Second) / time.Second),
+			}
+			if priority != "" {
+				req.Metadata = map[string]string{"priority": priority}
 			}
 
 			env, err := postMessage(flagServer, flagRoom, req)
 			if err != nil {
 				return err
 			}
-			fmt.Fprintf(os.Stderr, "sent message #%d to room %q\n", env.SeqNum, env.Room)
+			expiry := ""
+			if ttl > 0 {
+				expiry = fmt.Sprintf(" (expires in %s)", ttl.Round(time.Second))
+			}
+			out.status("sent message #%d to room %q%s", env.SeqNum, env.Room, expiry)
+			if out.json() {
+				return printJSON(env)
+			}
 			return nil
 		},
 	}
 
-	cmd.Flags().StringVarP(&msgType, "type", "t", "", "message type: text, code, diff (default: text)")
+	cmd.Flags().StringVarP(&msgType, "type", "t", "", "message type: text, code, diff, json (default: text)")
 	cmd.Flags().StringVarP(&filePath, "file", "f", "", "file path (for code/diff types)")
 	cmd.Flags().StringVarP(&language, "lang", "l", "", "language (for code type; auto-detected from file if omitted)")
 	cmd.Flags().StringVar(&body, "body", "", "message body (alternative to args/stdin)")
+	cmd.Flags().StringVar(&priority, "priority", "", "message priority: low, normal or urgent")
+	cmd.Flags().DurationVar(&ttl, "ttl", 0, "purge the message from history after this long, e.g. 10m (0 = keep)")
+	out.register(cmd, "don't print the status line")
 
 	return cmd
 }
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/spf13/cobra"
+)
+
+func newSpawnsCmd() *cobra.Command {
+	var (
+		limit   int
+		verbose bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "spawns",
+		Short: "Show the spawn audit log for a room",
+		Long: `Lists recent Claude spawns in the room from the server's audit log: who
+triggered each one, how long it ran, how it ended and how much it wrote.
+Use -v to include prompts and full error messages.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flagRoom == "" {
+				return fmt.Errorf("room is required (use -r or .claudetalk config)")
+			}
+
+			var list protocol.SpawnList
+			u := apiURL(flagServer, fmt.Sprintf("/api/rooms/%s/spawns?limit=%d", url.PathEscape(flagRoom), limit))
+			if err := getJSON(u, &list); err != nil {
+				return err
+			}
+			if len(list.Spawns) == 0 {
+				fmt.Println("no spawns recorded")
+				return nil
+			}
+
+			fmt.Printf("%-19s %-16s %-16s %-8s %9s %8s  %s\n", "STARTED", "OWNER", "TRIGGERED BY", "STATUS", "DURATION", "OUTPUT", "TRIGGER")
+			for _, s := range list.Spawns {
+				detail := s.Trigger
+				if s.Error != "" {
+					detail = s.Error
+				}
+				if !verbose {
+					detail = truncate(strings.ReplaceAll(detail, "\n", " "), 60)
+				}
+				fmt.Printf("%-19s %-16s %-16s %-8s %9s %7dB  %s\n",
+					s.StartedAt.Local().Format("2006-01-02 15:04:05"),
+					truncate(s.Sender, 16),
+					truncate(s.TriggeredBy, 16),
+					s.Status,
+					(time.Duration(s.DurationSec * float64(time.Second))).Round(time.Second),
+					s.OutputBytes,
+					detail)
+				if verbose {
+					fmt.Printf("    id=%s backend=%s dir=%s conv=%s\n", s.ID, s.Backend, s.WorkDir, s.ConvID)
+					for _, line := range strings.Split(strings.TrimSpace(s.Prompt), "\n") {
+						fmt.Printf("    | %s\n", line)
+					}
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVarP(&limit, "limit", "l", 20, "number of spawns to show")
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "include prompts and full errors")
+	return cmd
+}
+
+// truncate shortens s to at most n runes, marking the cut with "…".
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/spf13/cobra"
+)
+
+func newStatsCmd() *cobra.Command {
+	var out outputFlags
+
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show how quickly participants answer directed messages",
+		Long: `Shows, for everyone in the room who has been asked for a reply in a
+conversation (a message with --to that expects one), how many replies they
+gave, the median (p50) and 95th-percentile (p95) time they took, and how many
+messages still wait on them and for how long the oldest has.
+
+The server can alert when a reply is overdue; see its -reply-alert-after flag.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flagRoom == "" {
+				return fmt.Errorf("room is required (use -r or CLAUDETALK_ROOM)")
+			}
+			var stats protocol.RoomStats
+			if err := getJSON(apiURL(flagServer, fmt.Sprintf("/api/rooms/%s/stats", url.PathEscape(flagRoom))), &stats); err != nil {
+				return err
+			}
+
+			switch {
+			case out.json():
+				return printJSON(stats)
+			case out.quiet:
+				for _, s := range stats.Replies {
+					fmt.Println(s.Name)
+				}
+				return nil
+			}
+			if len(stats.Replies) == 0 {
+				fmt.Println("no one has been asked for a reply")
+				return nil
+			}
+
+			seconds := func(sec float64) string { return age(time.Duration(sec * float64(time.Second))) }
+			fmt.Printf("%-24s %8s %5s %5s %8s %7s\n", "NAME", "ANSWERED", "P50", "P95", "WAITING", "LONGEST")
+			for _, s := range stats.Replies {
+				p50, p95, longest := "-", "-", "-"
+				if s.Answered > 0 {
+					p50, p95 = seconds(s.P50Sec), seconds(s.P95Sec)
+				}
+				if s.Waiting > 0 {
+					longest = seconds(s.LongestWaitSec)
+				}
+				fmt.Printf("%-24s %8d %5s %5s %8d %7s\n", truncate(s.Name, 24), s.Answered, p50, p95, s.Waiting, longest)
+			}
+			return nil
+		},
+	}
+
+	out.register(cmd, "print only participant names")
+	return cmd
+}
@@ -7,7 +7,9 @@ import (
 )
 
 func newStatusCmd() *cobra.Command {
-	return &cobra.Command{
+	var out outputFlags
+
+	cmd := &cobra.Command{
 		Use:   "status",
 		Short: "Check server health",
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -16,10 +18,18 @@ func newStatusCmd() *cobra.Command {
 				return fmt.Errorf("server unreachable: %w", err)
 			}
 
+			switch {
+			case out.json():
+				return printJSON(health)
+			case out.quiet:
+				return nil
+			}
 			fmt.Printf("Status:  %s\n", health.Status)
 			fmt.Printf("Uptime:  %s\n", health.Uptime)
 			fmt.Printf("Rooms:   %d\n", health.Rooms)
 			return nil
 		},
 	}
+	out.register(cmd, "print nothing; only the exit status tells whether the server is up")
+	return cmd
 }
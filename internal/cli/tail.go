@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/signal"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/gorilla/websocket"
+	"github.com/spf13/cobra"
+)
+
+func newTailCmd() *cobra.Command {
+	var (
+		from    string
+		msgType string
+		convID  string
+		match   string
+		latest  int
+		noColor bool
+		mention bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "tail",
+		Short: "Follow messages matching a filter (filtered server-side)",
+		Long: `Prints recent matching messages, then follows the room live. Filtering
+happens on the server, so only matching messages are sent over the wire.
+
+Examples:
+  claudetalk tail --sender "kruz's Claude" --type diff
+  claudetalk tail --conv 3f2a9c1e-...
+  claudetalk tail --match "(?i)deploy|rollback"`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flagRoom == "" {
+				return fmt.Errorf("room is required (use -r or CLAUDETALK_ROOM)")
+			}
+
+			filter := url.Values{}
+			if from != "" {
+				filter.Set("from", from)
+			}
+			if msgType != "" {
+				filter.Set("type", msgType)
+			}
+			if convID != "" {
+				filter.Set("conv_id", convID)
+			}
+			if match != "" {
+				filter.Set("match", match)
+			}
+			if mention {
+				if flagSender == "" {
+					return fmt.Errorf("--mentions-me needs your name (use -n or CLAUDETALK_SENDER)")
+				}
+				filter.Set("mentions", flagSender)
+			}
+
+			format := formatColor
+			if noColor {
+				format = formatPlain
+			}
+
+			if latest > 0 {
+				q := url.Values{}
+				for k, v := range filter {
+					q[k] = v
+				}
+				q.Set("n", fmt.Sprint(latest))
+				var list protocol.MessageList
+				if err := getJSON(apiURL(flagServer, fmt.Sprintf("/api/rooms/%s/messages/latest?%s", url.PathEscape(flagRoom), q.Encode())), &list); err != nil {
+					return err
+				}
+				roomKeys().OpenAll(list.Messages)
+				for _, env := range list.Messages {
+					fmt.Println(format(env))
+				}
+			}
+
+			sender := flagSender
+			if sender == "" {
+				sender = "tail"
+			}
+			wsURL := buildWSURL(flagServer, flagRoom, sender)
+			if len(filter) > 0 {
+				wsURL += "&" + filter.Encode()
+			}
+
+			conn, resp, err := dialWS(wsURL)
+			if err != nil {
+				return fmt.Errorf("connect: %w", protocol.HandshakeError(err, resp))
+			}
+			defer conn.Close()
+
+			interrupt := make(chan os.Signal, 1)
+			signal.Notify(interrupt, os.Interrupt)
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for {
+					var env protocol.Envelope
+					if err := conn.ReadJSON(&env); err != nil {
+						if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+							log.Printf("read error: %v", err)
+						}
+						return
+					}
+					if env.ID == "" {
+						continue // a server event such as message_expired, not a message
+					}
+					roomKeys().Open(&env)
+					fmt.Println(format(env))
+				}
+			}()
+
+			select {
+			case <-done:
+				return nil
+			case <-interrupt:
+				return conn.WriteMessage(
+					websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+				)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&from, "sender", "", "only messages from this sender")
+	cmd.Flags().StringVarP(&msgType, "type", "t", "", "only messages of this type: text, code, diff, file, system")
+	cmd.Flags().StringVar(&convID, "conv", "", "only messages in this conversation ID")
+	cmd.Flags().StringVar(&match, "match", "", "only messages whose content matches this regex")
+	cmd.Flags().BoolVar(&mention, "mentions-me", false, "only messages that @-mention your name")
+	cmd.Flags().IntVar(&latest, "latest", 10, "print the N most recent matching messages before following")
+	cmd.Flags().BoolVar(&noColor, "no-color", false, "disable colored output")
+
+	return cmd
+}
@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/spf13/cobra"
+)
+
+func newThreadsCmd() *cobra.Command {
+	var (
+		all   bool
+		limit int
+		out   outputFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "threads",
+		Short: "List your open conversations",
+		Long: `Lists the conversations (conv_ids) you are in that still expect a reply,
+most recently active first: who else is in each, its latest message, how long
+ago that was and whom it is waiting on. Answer one with
+"claudetalk converse --to <name> --conv <conv-id>", or the latest with
+"claudetalk reply". --all includes conversations marked done.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flagRoom == "" {
+				return fmt.Errorf("room is required (use -r or CLAUDETALK_ROOM)")
+			}
+			if flagSender == "" {
+				return fmt.Errorf("sender name is required (use -n or CLAUDETALK_SENDER)")
+			}
+
+			q := url.Values{"participant": {flagSender}, "limit": {fmt.Sprint(limit)}}
+			if !all {
+				q.Set("open", "true")
+			}
+			var list protocol.ConversationList
+			if err := getJSON(apiURL(flagServer, fmt.Sprintf("/api/rooms/%s/conversations?%s", url.PathEscape(flagRoom), q.Encode())), &list); err != nil {
+				return err
+			}
+			for i := range list.Conversations {
+				roomKeys().Open(&list.Conversations[i].Last)
+			}
+
+			switch {
+			case out.json():
+				return printJSON(list)
+			case out.quiet:
+				for _, c := range list.Conversations {
+					fmt.Println(c.ConvID)
+				}
+				return nil
+			}
+			if list.Count == 0 {
+				if all {
+					fmt.Println("no conversations")
+				} else {
+					fmt.Println("no open conversations")
+				}
+				return nil
+			}
+
+			fmt.Printf("%-36s %5s  %-16s %-24s %s\n", "CONV", "AGE", "WAITING ON", "WITH", "LAST")
+			for _, c := range list.Conversations {
+				waiting := c.WaitingOn
+				switch {
+				case !c.Open:
+					waiting = "(done)"
+				case waiting == flagSender:
+					waiting = "you"
+				}
+				others := slices.DeleteFunc(slices.Clone(c.Participants), func(name string) bool { return name == flagSender })
+				text := strings.Join(strings.Fields(c.Last.Payload.Text), " ")
+				if text == "" {
+					text = "(" + c.Last.Type + ")"
+				}
+				last := c.Last.Sender + ": " + text
+				fmt.Printf("%-36s %5s  %-16s %-24s %s\n",
+					c.ConvID,
+					age(time.Since(c.Last.Timestamp)),
+					truncate(waiting, 16),
+					truncate(strings.Join(others, ", "), 24),
+					truncate(last, 60))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&all, "all", false, "include conversations that are done")
+	cmd.Flags().IntVarP(&limit, "limit", "l", 20, "number of conversations to show")
+	out.register(cmd, "print only conv_ids")
+	return cmd
+}
+
+// age renders d in its largest whole unit, e.g. "45s", "12m", "3h" or "2d".
+func age(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/corvino/claudetalk/internal/buildinfo"
+	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/spf13/cobra"
+)
+
+func newVersionCmd() *cobra.Command {
+	var (
+		check bool
+		out   outputFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Print the CLI's version, and with --check compare it with the server's",
+		Long: `Prints the release and commit this CLI was built from.
+
+--check also asks the server for its version and fails if the two differ;
+"claudetalk self-update" then installs the release the server runs.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			local := buildinfo.Get()
+			if !check {
+				switch {
+				case out.json():
+					return printJSON(local)
+				case out.quiet:
+					return nil
+				}
+				fmt.Printf("claudetalk %s %s/%s, protocol %d\n", local, local.OS, local.Arch, local.Protocol)
+				return nil
+			}
+
+			var remote protocol.VersionInfo
+			if err := getJSON(apiURL(flagServer, "/api/version"), &remote); err != nil {
+				return fmt.Errorf("server version: %w", err)
+			}
+			same, known := local.SameBuild(remote)
+			switch {
+			case out.json():
+				if err := printJSON(map[string]any{"cli": local, "server": remote, "same": same, "known": known}); err != nil {
+					return err
+				}
+			case !out.quiet:
+				fmt.Printf("claudetalk %s %s/%s, protocol %d\n", local, local.OS, local.Arch, local.Protocol)
+				fmt.Printf("server     %s at %s, protocol %d\n", remote, flagServer, remote.Protocol)
+				if !known {
+					fmt.Println("Can't tell whether they match: dev builds without commits, or with uncommitted changes.")
+				}
+			}
+			if known && !same {
+				if out.quiet {
+					cmd.SilenceErrors = true
+					cmd.SilenceUsage = true
+					return exitStatus(1)
+				}
+				return fmt.Errorf("the CLI and server versions differ; run \"claudetalk self-update\" to match the server")
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&check, "check", false, "compare with the server's version; fail if they differ")
+	out.register(cmd, "print nothing; with --check the exit status tells whether the versions match")
+	return cmd
+}
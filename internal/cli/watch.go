@@ -14,7 +14,11 @@ import (
 )
 
 func newWatchCmd() *cobra.Command {
-	var noColor bool
+	var (
+		noColor bool
+		notify  bool
+		rooms   []string
+	)
 
 	cmd := &cobra.Command{
 		Use:   "watch",
@@ -30,14 +34,23 @@ func newWatchCmd() *cobra.Command {
 
 			// Build WebSocket URL from HTTP server URL.
 			wsURL := buildWSURL(flagServer, flagRoom, sender)
+			multi := len(rooms) > 0
+			if multi {
+				wsURL = buildMultiWSURL(flagServer, append([]string{flagRoom}, rooms...), sender)
+			}
+			if !configProfile.IsZero() {
+				q := url.Values{}
+				configProfile.AddQuery(q)
+				wsURL += "&" + q.Encode()
+			}
 
-			fmt.Fprintf(os.Stderr, "connecting to %s ...\n", wsURL)
-			conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+			fmt.Fprintf(os.Stderr, "connecting to %s ...\n", protocol.RedactToken(wsURL))
+			conn, resp, err := dialWS(wsURL)
 			if err != nil {
-				return fmt.Errorf("connect: %w", err)
+				return fmt.Errorf("connect: %w", protocol.HandshakeError(err, resp))
 			}
 			defer conn.Close()
-			fmt.Fprintf(os.Stderr, "connected to room %q as %q\n", flagRoom, sender)
+			fmt.Fprintf(os.Stderr, "connected to room(s) %s as %q\n", strings.Join(append([]string{flagRoom}, rooms...), ","), sender)
 
 			// Handle Ctrl+C.
 			interrupt := make(chan os.Signal, 1)
@@ -55,11 +68,29 @@ func newWatchCmd() *cobra.Command {
 						}
 						return
 					}
+					if env.ID == "" {
+						continue // a server event such as message_expired, not a message
+					}
+					roomKeys().Open(&env)
+					prefix := ""
+					if multi {
+						prefix = "#" + env.Room + " "
+					}
 					if noColor {
-					fmt.Println(formatPlain(env))
-				} else {
-					fmt.Println(formatColor(env))
-				}
+						fmt.Println(prefix + formatPlain(env))
+					} else {
+						fmt.Println(prefix + formatColor(env))
+					}
+					if notify && isForMe(env, flagSender) {
+						title := fmt.Sprintf("%s in #%s", env.Sender, env.Room)
+						urgent := protocol.MessagePriority(env.Metadata) == protocol.PriorityUrgent
+						if urgent {
+							title = "URGENT: " + title
+						}
+						if err := desktopNotify(title, env.Payload.Text, urgent); err != nil {
+							log.Printf("notify: %v", err)
+						}
+					}
 				}
 			}()
 
@@ -81,6 +112,8 @@ func newWatchCmd() *cobra.Command {
 	}
 
 	cmd.Flags().BoolVar(&noColor, "no-color", false, "disable colored output (useful for piping/logging)")
+	cmd.Flags().StringSliceVar(&rooms, "rooms", nil, "additional rooms to watch over the same connection (comma-separated)")
+	cmd.Flags().BoolVar(&notify, "notify", false, "show a desktop notification when a message is directed at or mentions your name")
 
 	return cmd
 }
@@ -90,5 +123,25 @@ func buildWSURL(server, room, sender string) string {
 	u := strings.TrimRight(server, "/")
 	u = strings.Replace(u, "https://", "wss://", 1)
 	u = strings.Replace(u, "http://", "ws://", 1)
-	return fmt.Sprintf("%s/ws/%s?sender=%s", u, room, url.QueryEscape(sender))
+	return fmt.Sprintf("%s/ws/%s?sender=%s", u, room, url.QueryEscape(sender)) + tokenQuery()
+}
+
+// buildMultiWSURL builds a URL for the multiplexed /ws?rooms= endpoint.
+func buildMultiWSURL(server string, rooms []string, sender string) string {
+	u := strings.TrimRight(server, "/")
+	u = strings.Replace(u, "https://", "wss://", 1)
+	u = strings.Replace(u, "http://", "ws://", 1)
+	q := url.Values{}
+	q.Set("rooms", strings.Join(rooms, ","))
+	q.Set("sender", sender)
+	return u + "/ws?" + q.Encode() + tokenQuery()
+}
+
+// tokenQuery returns the workspace and private room tokens as extra
+// WebSocket URL query parameters, or "" without any.
+func tokenQuery() string {
+	if q := protocol.TokenQuery(); len(q) > 0 {
+		return "&" + q.Encode()
+	}
+	return ""
 }
@@ -16,6 +16,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/corvino/claudetalk/internal/prompt"
 	"github.com/corvino/claudetalk/internal/protocol"
 	"github.com/corvino/claudetalk/internal/runner"
 	"github.com/corvino/claudetalk/internal/web"
@@ -25,8 +26,10 @@ import (
 
 func newWebCmd() *cobra.Command {
 	var (
-		port     int
-		claudeBin string
+		port   int
+		rf     runnerFlags
+		claude string
+		rooms  []string
 	)
 
 	cmd := &cobra.Command{
@@ -38,20 +41,42 @@ a Claude Code instance locally on your machine.
 
 Your friends just need this binary — no Go or other dependencies required.
 
+Messages directed at your Claude spawn it locally too, through a watcher
+per room. Joining a room in a tab starts its watcher, and it keeps running
+after the tab closes, for as long as this command does; more tabs share it.
+--rooms starts watchers for rooms up front, without opening any tab.
+
+Recent messages are cached locally, so tabs keep working through brief
+outages of the server or tunnel: history loads from the cache, open tabs
+stay connected, and what was missed is filled in once the server is back.
+
 Example:
   claudetalk web --server https://claudetalk.fly.dev
-  claudetalk web -s http://localhost:8080 -p 3000`,
+  claudetalk web -s http://localhost:8080 -p 3000
+  claudetalk web -n alice --rooms backend,frontend`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runWeb(flagServer, port, claudeBin)
+			if len(rooms) > 0 && flagSender == "" {
+				return fmt.Errorf("--rooms needs your name (use -n or CLAUDETALK_SENDER)")
+			}
+			// Claude's MCP tools talk to the REMOTE server.
+			rcfg, err := rf.config(flagServer)
+			if err != nil {
+				return err
+			}
+			return runWeb(flagServer, port, rcfg, claude, rooms)
 		},
 	}
 
 	cmd.Flags().IntVarP(&port, "port", "p", 3000, "local web UI port")
-	cmd.Flags().StringVar(&claudeBin, "claude-bin", "", "path to claude CLI binary")
+	cmd.Flags().StringSliceVar(&rooms, "rooms", nil, "comma-separated rooms to watch for messages to your Claude from the start")
+	cmd.Flags().StringVar(&claude, "claude-name", configClaudeName, "name your Claude speaks as (default \"{name}'s Claude\", or from claudetalk join)")
+	rf.register(cmd, "base directories spawns may choose a work_dir under")
 	return cmd
 }
 
-func runWeb(remoteServer string, port int, claudeBin string) error {
+// runWeb serves the web UI. claudeName is the name local Claudes speak as;
+// "" = "{sender}'s Claude". rooms are watched from the start, as flagSender.
+func runWeb(remoteServer string, port int, rcfg runner.Config, claudeName string, rooms []string) error {
 	remote, err := url.Parse(remoteServer)
 	if err != nil {
 		return fmt.Errorf("invalid server URL: %w", err)
@@ -60,24 +85,41 @@ func runWeb(remoteServer string, port int, claudeBin string) error {
 	localAddr := fmt.Sprintf("http://localhost:%d", port)
 
 	// Create runner for local Claude spawning.
-	r := runner.New(runner.Config{
-		ClaudeBin: claudeBin,
-		ServerURL: remoteServer, // Claude's MCP tools talk to the REMOTE server.
-	})
+	r := runner.New(rcfg)
+
+	cache := newMessageCache()
+	w := newWatchers(remote, r, claudeName)
+	defer w.stopAll()
+	for _, room := range rooms {
+		w.ensure(room, flagSender, nil)
+	}
 
 	mux := http.NewServeMux()
 
 	// Intercept spawn/stop — handle locally.
 	mux.HandleFunc("POST /api/rooms/{room}/spawn", func(w http.ResponseWriter, req *http.Request) {
-		handleLocalSpawn(w, req, r)
+		handleLocalSpawn(w, req, r, claudeName)
 	})
 	mux.HandleFunc("POST /api/rooms/{room}/stop", func(w http.ResponseWriter, req *http.Request) {
 		handleLocalStop(w, req, r)
 	})
+	mux.HandleFunc("GET /api/rooms/{room}/spawn/options", func(w http.ResponseWriter, req *http.Request) {
+		writeJSONWeb(w, http.StatusOK, r.Choices(""))
+	})
+	mux.HandleFunc("GET /api/rooms/{room}/sessions", func(w http.ResponseWriter, req *http.Request) {
+		room := req.PathValue("room")
+		writeJSONWeb(w, http.StatusOK, protocol.SessionList{Room: room, Sessions: r.Sessions().List(room)})
+	})
+	mux.HandleFunc("GET /api/rooms/{room}/spawns", func(w http.ResponseWriter, req *http.Request) {
+		room := req.PathValue("room")
+		spawns := r.Audit().List(room, 50)
+		writeJSONWeb(w, http.StatusOK, protocol.SpawnList{Room: room, Spawns: spawns, Count: len(spawns)})
+	})
 
-	// Proxy WebSocket connections to remote server.
-	mux.HandleFunc("GET /ws/{room}", func(w http.ResponseWriter, req *http.Request) {
-		proxyWebSocket(w, req, remote, r)
+	// Proxy room WebSocket connections to remote server, watching and caching
+	// the room on the way.
+	mux.HandleFunc("GET /ws/{room}", func(rw http.ResponseWriter, req *http.Request) {
+		proxyWebSocket(rw, req, remote, w, cache)
 	})
 
 	// Serve embedded web UI.
@@ -87,38 +129,44 @@ func runWeb(remoteServer string, port int, claudeBin string) error {
 	}
 	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServer(http.FS(staticFS))))
 
-	// Proxy all other requests — API calls go to remote, root serves index.html.
-	proxy := httputil.NewSingleHostReverseProxy(remote)
-	proxy.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
-		log.Printf("proxy error: %v", err)
-		http.Error(w, fmt.Sprintf("proxy error: %v", err), http.StatusBadGateway)
-	}
-	proxyHandler := func(w http.ResponseWriter, req *http.Request) {
-		req.Host = remote.Host
-		proxy.ServeHTTP(w, req)
+	// Pages come from the embedded UI too, so they match its static files.
+	mux.HandleFunc("GET /{$}", servePageWeb("index.html"))
+	mux.HandleFunc("GET /join/{token}", servePageWeb("index.html"))
+	mux.HandleFunc("GET /m/", servePageWeb("mobile.html"))
+
+	// Everything else goes to the remote as is: any method and path, with
+	// WebSocket upgrades and event streams passed through.
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(remote)
+			pr.SetXForwarded()
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			// corsMiddlewareWeb answers for CORS; two sets of headers fail.
+			for k := range resp.Header {
+				if strings.HasPrefix(k, "Access-Control-") {
+					resp.Header.Del(k)
+				}
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, req *http.Request, err error) {
+			log.Printf("proxy error: %v", err)
+			http.Error(w, fmt.Sprintf("proxy error: %v", err), http.StatusBadGateway)
+		},
 	}
-	mux.HandleFunc("GET /api/", proxyHandler)
-	mux.HandleFunc("POST /api/", proxyHandler)
-
-	// Serve index.html at root, 404 everything else.
-	mux.HandleFunc("GET /", func(w http.ResponseWriter, req *http.Request) {
-		if req.URL.Path != "/" {
-			http.NotFound(w, req)
-			return
-		}
-		data, _ := web.StaticFS.ReadFile("static/index.html")
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		w.Write(data)
-	})
+	cacheReads(proxy, cache)
+	mux.Handle("/", proxy)
 
 	handler := corsMiddlewareWeb(mux)
 
+	// No read or write timeout: uploads, downloads and event streams through
+	// the proxy take as long as they take.
 	srv := &http.Server{
-		Addr:         fmt.Sprintf(":%d", port),
-		Handler:      handler,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  120 * time.Second,
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           handler,
+		ReadHeaderTimeout: 15 * time.Second,
+		IdleTimeout:       120 * time.Second,
 	}
 
 	stop := make(chan os.Signal, 1)
@@ -133,6 +181,9 @@ func runWeb(remoteServer string, port int, claudeBin string) error {
 		fmt.Printf("  Local UI:      %s\n", localAddr)
 		fmt.Printf("  Chat server:   %s\n", remoteServer)
 		fmt.Println("  Claude:        spawns locally on your machine")
+		if len(rooms) > 0 {
+			fmt.Printf("  Watching:      %s\n", strings.Join(rooms, ", "))
+		}
 		fmt.Println()
 		fmt.Println("============================================================")
 		fmt.Println()
@@ -153,7 +204,7 @@ func runWeb(remoteServer string, port int, claudeBin string) error {
 	return nil
 }
 
-func handleLocalSpawn(w http.ResponseWriter, r *http.Request, rnr *runner.Runner) {
+func handleLocalSpawn(w http.ResponseWriter, r *http.Request, rnr *runner.Runner, claudeName string) {
 	roomName := r.PathValue("room")
 	if roomName == "" {
 		writeJSONWeb(w, http.StatusBadRequest, map[string]string{"error": "room name required"})
@@ -161,34 +212,70 @@ func handleLocalSpawn(w http.ResponseWriter, r *http.Request, rnr *runner.Runner
 	}
 
 	var req struct {
-		Sender string `json:"sender"`
-		Prompt string `json:"prompt"`
+		Sender  string `json:"sender"`
+		Name    string `json:"name"`
+		Prompt  string `json:"prompt"`
+		Preset  string `json:"preset"`
+		WorkDir string `json:"work_dir"`
+		runner.Options
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeJSONWeb(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid JSON: %v", err)})
 		return
 	}
+	if req.Preset != "" {
+		preset, ok := rnr.Preset(req.Preset)
+		if !ok {
+			writeJSONWeb(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unknown preset %q", req.Preset)})
+			return
+		}
+		req.Prompt = runner.ExpandPreset(preset, req.Prompt)
+		if req.Model == "" {
+			req.Model = preset.Model
+		}
+	}
 	if req.Sender == "" || req.Prompt == "" {
 		writeJSONWeb(w, http.StatusBadRequest, map[string]string{"error": "sender and prompt required"})
 		return
 	}
+	if err := req.Options.Validate(); err != nil {
+		writeJSONWeb(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if _, err := rnr.ResolveWorkDir(req.WorkDir); err != nil {
+		writeJSONWeb(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if req.Name != "" {
+		claudeName = req.Name
+	}
+	if claudeName != "" {
+		if err := protocol.ValidateClaudeName(claudeName); err != nil {
+			writeJSONWeb(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	claudeName = protocol.ClaudeName(req.Sender, claudeName)
 
-	_, cancel, err := rnr.Sessions().Start(roomName, req.Sender, "")
+	ctx, cancel, err := rnr.Sessions().Start(roomName, req.Sender, "")
 	if err != nil {
 		writeJSONWeb(w, http.StatusConflict, map[string]string{"error": err.Error()})
 		return
 	}
 
-	claudeName := req.Sender + "'s Claude"
-
 	go func() {
 		defer cancel()
 		defer rnr.Sessions().End(roomName, req.Sender, "")
 
 		params := runner.SpawnParams{
-			Room:   roomName,
-			Sender: req.Sender,
-			Prompt: req.Prompt,
+			Room:    roomName,
+			Sender:  req.Sender,
+			Name:    claudeName,
+			Prompt:  req.Prompt,
+			Ctx:     ctx,
+			Options: req.Options,
+			WorkDir: req.WorkDir,
 		}
 
 		if err := rnr.Spawn(params); err != nil {
@@ -203,14 +290,19 @@ func handleLocalStop(w http.ResponseWriter, r *http.Request, rnr *runner.Runner)
 	roomName := r.PathValue("room")
 
 	var req struct {
-		Sender string `json:"sender"`
+		Sender string  `json:"sender"`
+		ConvID *string `json:"conv_id"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeJSONWeb(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
 		return
 	}
 
-	if err := rnr.Sessions().Stop(roomName, req.Sender); err != nil {
+	stop := func() error { return rnr.Sessions().Stop(roomName, req.Sender) }
+	if req.ConvID != nil {
+		stop = func() error { return rnr.Sessions().StopConv(roomName, req.Sender, *req.ConvID) }
+	}
+	if err := stop(); err != nil {
 		writeJSONWeb(w, http.StatusNotFound, map[string]string{"error": err.Error()})
 		return
 	}
@@ -219,9 +311,12 @@ func handleLocalStop(w http.ResponseWriter, r *http.Request, rnr *runner.Runner)
 }
 
 // proxyWebSocket proxies a WebSocket connection to the remote server.
-// It also starts a daemon-mode watcher for the user's Claude so that directed
-// messages trigger automatic local spawns.
-func proxyWebSocket(w http.ResponseWriter, r *http.Request, remote *url.URL, rnr *runner.Runner) {
+// It also makes sure a watcher runs for the user's Claude in the room, so
+// that directed messages trigger local spawns, with or without the tab.
+// When the remote connection drops, the browser's stays open while it is
+// redialed; then the messages missed meanwhile are backfilled to both the
+// browser and the cache.
+func proxyWebSocket(w http.ResponseWriter, r *http.Request, remote *url.URL, watchers *watchers, cache *messageCache) {
 	room := r.PathValue("room")
 	sender := r.URL.Query().Get("sender")
 
@@ -233,13 +328,12 @@ func proxyWebSocket(w http.ResponseWriter, r *http.Request, remote *url.URL, rnr
 	remoteURL := wsScheme + "://" + remote.Host + r.URL.Path + "?" + r.URL.RawQuery
 
 	// Connect to remote.
-	remoteConn, _, err := websocket.DefaultDialer.Dial(remoteURL, nil)
+	remoteConn, _, err := dialWS(remoteURL)
 	if err != nil {
 		log.Printf("ws proxy: failed to connect to remote: %v", err)
 		http.Error(w, "failed to connect to remote server", http.StatusBadGateway)
 		return
 	}
-	defer remoteConn.Close()
 
 	// Upgrade local connection.
 	upgrader := websocket.Upgrader{
@@ -248,58 +342,151 @@ func proxyWebSocket(w http.ResponseWriter, r *http.Request, remote *url.URL, rnr
 	localConn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("ws proxy: upgrade failed: %v", err)
+		remoteConn.Close()
 		return
 	}
 	defer localConn.Close()
 
-	// Start daemon watcher so directed messages trigger local Claude spawns.
-	// The watcher's lifetime is tied to this browser connection.
-	watcherDone := make(chan struct{})
-	if rnr != nil && room != "" && sender != "" {
-		go startWatcher(remote, room, sender, rnr, watcherDone)
+	if room != "" && sender != "" {
+		watchers.ensure(room, sender, r.URL.Query())
 	}
 
-	// Bidirectional relay.
-	done := make(chan struct{}, 2)
+	// The remote connection, nil while it is being redialed.
+	var mu sync.Mutex
+	current := remoteConn
+	setRemote := func(c *websocket.Conn) {
+		mu.Lock()
+		defer mu.Unlock()
+		current = c
+	}
+	defer func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if current != nil {
+			current.Close()
+		}
+	}()
 
-	// Remote → Local
+	// Local → Remote. What the browser sends while the remote is away is
+	// dropped; messages themselves are POSTed, not sent here.
+	localGone := make(chan struct{})
 	go func() {
-		defer func() { done <- struct{}{} }()
+		defer close(localGone)
 		for {
-			msgType, data, err := remoteConn.ReadMessage()
+			msgType, data, err := localConn.ReadMessage()
 			if err != nil {
 				return
 			}
-			if err := localConn.WriteMessage(msgType, data); err != nil {
-				return
+			mu.Lock()
+			c := current
+			mu.Unlock()
+			if c != nil {
+				c.WriteMessage(msgType, data)
 			}
 		}
 	}()
 
-	// Local → Remote
-	go func() {
-		defer func() { done <- struct{}{} }()
+	// Remote → Local, redialing the remote until the browser goes. After a
+	// backfill, messages it already delivered are skipped.
+	var backfilled int64
+	for {
 		for {
-			msgType, data, err := localConn.ReadMessage()
+			msgType, data, err := remoteConn.ReadMessage()
 			if err != nil {
+				break
+			}
+			if seq := cache.record(room, data); seq > 0 && seq <= backfilled {
+				continue
+			}
+			if err := localConn.WriteMessage(msgType, data); err != nil {
 				return
 			}
-			if err := remoteConn.WriteMessage(msgType, data); err != nil {
+		}
+		setRemote(nil)
+		remoteConn.Close()
+
+		backoff := time.Second
+		for remoteConn = nil; remoteConn == nil; {
+			select {
+			case <-localGone:
 				return
+			case <-time.After(backoff):
+			}
+			remoteConn, _, err = dialWS(remoteURL)
+			if err != nil {
+				log.Printf("ws proxy: reconnecting to remote: %v", err)
+				backoff = min(backoff*2, 30*time.Second)
 			}
 		}
-	}()
+		setRemote(remoteConn)
 
-	<-done
-	close(watcherDone) // Stop the watcher when the browser disconnects.
+		missed, err := cache.backfill(remote, room, r.URL.Query())
+		if err != nil {
+			log.Printf("ws proxy: %v", err)
+		}
+		for _, env := range missed {
+			if err := localConn.WriteJSON(env); err != nil {
+				return
+			}
+			backfilled = env.SeqNum
+		}
+	}
 }
 
-// startWatcher opens a daemon-mode WebSocket connection to the remote server as
-// "{sender}'s Claude" and listens for spawn events. When a spawn event arrives,
-// it launches a local Claude process to respond. Runs until done is closed.
-func startWatcher(remote *url.URL, room, sender string, rnr *runner.Runner, done <-chan struct{}) {
-	claudeName := sender + "'s Claude"
+// watchers keeps one watcher per room and sender for as long as the web
+// command runs: more tabs don't start more, and closing them doesn't stop
+// it.
+type watchers struct {
+	remote     *url.URL
+	rnr        *runner.Runner
+	claudeName string
+	done       chan struct{}
+
+	mu      sync.Mutex
+	running map[[2]string]bool // room, sender
+}
+
+func newWatchers(remote *url.URL, rnr *runner.Runner, claudeName string) *watchers {
+	return &watchers{
+		remote:     remote,
+		rnr:        rnr,
+		claudeName: claudeName,
+		done:       make(chan struct{}),
+		running:    make(map[[2]string]bool),
+	}
+}
+
+// ensure starts the watcher for sender's Claude in room unless it runs
+// already. query holds the tokens the room needs, as a browser sends them;
+// the ones this machine is configured with are added.
+func (ws *watchers) ensure(room, sender string, query url.Values) {
+	key := [2]string{room, sender}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.running[key] {
+		return
+	}
+	ws.running[key] = true
+	tokens := protocol.TokenQuery()
+	for _, k := range []string{protocol.TokenParam, protocol.RoomTokenParam} {
+		for _, v := range query[k] {
+			tokens.Add(k, v)
+		}
+	}
+	go startWatcher(ws.remote, room, sender, protocol.ClaudeName(sender, ws.claudeName), tokens, ws.rnr, ws.done)
+}
+
+// stopAll stops every watcher.
+func (ws *watchers) stopAll() {
+	close(ws.done)
+}
 
+// startWatcher opens a daemon-mode WebSocket connection to the remote server as
+// claudeName, sender's Claude, and listens for spawn events. When a spawn event
+// arrives, it launches a local Claude process to respond. A dropped connection
+// is redialed, backing off while the server stays unreachable. Runs until done
+// is closed.
+func startWatcher(remote *url.URL, room, sender, claudeName string, tokens url.Values, rnr *runner.Runner, done <-chan struct{}) {
 	// Build daemon WebSocket URL.
 	wsScheme := "ws"
 	if remote.Scheme == "https" {
@@ -308,10 +495,13 @@ func startWatcher(remote *url.URL, room, sender string, rnr *runner.Runner, done
 	u := *remote
 	u.Scheme = wsScheme
 	u.Path = "/ws/" + url.PathEscape(room)
-	q := url.Values{}
+	q := tokens
 	q.Set("sender", claudeName)
+	q.Set("owner", sender)
 	q.Set("mode", "daemon")
 	q.Set("role", "daemon")
+	// Priority 0 yields to a "claudetalk daemon" running under the same name.
+	q.Set("priority", "0")
 	u.RawQuery = q.Encode()
 	wsURL := u.String()
 
@@ -325,9 +515,13 @@ func startWatcher(remote *url.URL, room, sender string, rnr *runner.Runner, done
 		default:
 		}
 
-		if err := runWatcherConn(wsURL, room, sender, claudeName, rnr, done); err != nil {
+		connected, err := runWatcherConn(wsURL, room, sender, claudeName, rnr, done)
+		if err != nil {
 			log.Printf("watcher(%s): %v", claudeName, err)
 		}
+		if connected {
+			backoff = time.Second
+		}
 
 		select {
 		case <-done:
@@ -341,13 +535,14 @@ func startWatcher(remote *url.URL, room, sender string, rnr *runner.Runner, done
 	}
 }
 
-// runWatcherConn runs a single WebSocket connection for the watcher.
+// runWatcherConn runs a single WebSocket connection for the watcher, reporting
+// whether it got as far as connecting.
 // When a spawn event arrives while a session is already active for that conv_id,
 // the latest spawn request is queued and replayed once the active session ends.
-func runWatcherConn(wsURL, room, sender, claudeName string, rnr *runner.Runner, done <-chan struct{}) error {
-	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+func runWatcherConn(wsURL, room, sender, claudeName string, rnr *runner.Runner, done <-chan struct{}) (bool, error) {
+	conn, resp, err := dialWS(wsURL)
 	if err != nil {
-		return fmt.Errorf("dial: %w", err)
+		return false, fmt.Errorf("dial: %w", protocol.HandshakeError(err, resp))
 	}
 	defer conn.Close()
 
@@ -362,7 +557,7 @@ func runWatcherConn(wsURL, room, sender, claudeName string, rnr *runner.Runner,
 	// automatically when the active session ends.
 	var trySpawn func(convID string, req *protocol.SpawnReq)
 	trySpawn = func(convID string, req *protocol.SpawnReq) {
-		_, cancel, err := rnr.Sessions().Start(room, sender, convID)
+		ctx, cancel, err := rnr.Sessions().Start(room, sender, convID)
 		if err != nil {
 			// Session already active — queue this spawn for after it ends.
 			pendingMu.Lock()
@@ -388,10 +583,13 @@ func runWatcherConn(wsURL, room, sender, claudeName string, rnr *runner.Runner,
 			}()
 
 			params := runner.SpawnParams{
-				Room:   room,
-				Sender: sender,
-				ConvID: convID,
-				Prompt: buildWatcherPrompt(claudeName, room, req),
+				Room:    room,
+				Sender:  sender,
+				Name:    claudeName,
+				ConvID:  convID,
+				Prompt:  prompt.Build(prompt.NewData(claudeName, room, req, 0), req.PromptTemplates...),
+				Ctx:     ctx,
+				Trigger: req.Trigger,
 			}
 			if err := rnr.Spawn(params); err != nil {
 				log.Printf("watcher: spawn error for %s: %v", claudeName, err)
@@ -420,14 +618,18 @@ func runWatcherConn(wsURL, room, sender, claudeName string, rnr *runner.Runner,
 		select {
 		case <-done:
 			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
-			return nil
+			return true, nil
 		case err := <-readErr:
-			return fmt.Errorf("read: %w", err)
+			return true, fmt.Errorf("read: %w", err)
 		case data := <-msgs:
 			var event protocol.ServerEvent
 			if err := json.Unmarshal(data, &event); err != nil {
 				continue
 			}
+			if event.Event == "claim" && event.Claim != nil {
+				log.Printf("watcher(%s): claim %s %s", claudeName, event.Claim.State, event.Claim.Reason)
+				continue
+			}
 			if event.Event == "spawn" && event.Spawn != nil {
 				convID := ""
 				if event.Spawn.Trigger != nil {
@@ -439,56 +641,13 @@ func runWatcherConn(wsURL, room, sender, claudeName string, rnr *runner.Runner,
 	}
 }
 
-// buildWatcherPrompt builds a prompt for a watcher-triggered Claude spawn.
-func buildWatcherPrompt(claudeName, room string, req *protocol.SpawnReq) string {
-	var sb strings.Builder
-
-	sb.WriteString(fmt.Sprintf("You are %q in the ClaudeTalk room %q.\n\n", claudeName, room))
-
-	// Group thread context.
-	isGroup := len(req.Participants) > 1
-	if isGroup {
-		sb.WriteString("This is a GROUP conversation thread. All participants:\n")
-		for _, p := range req.Participants {
-			sb.WriteString("  • " + p + "\n")
-		}
-		sb.WriteString("When you reply, ALL participants in this thread are automatically notified and may respond.\n\n")
-	}
-
-	if len(req.Context) > 0 {
-		sb.WriteString("Recent conversation context (newest at bottom):\n")
-		for _, env := range req.Context {
-			ts := env.Timestamp.Format("15:04:05")
-			fmt.Fprintf(&sb, "[%s] %s", ts, env.Sender)
-			if to := env.Metadata["to"]; to != "" {
-				fmt.Fprintf(&sb, " → %s", to)
-			}
-			fmt.Fprintf(&sb, ": %s\n", env.Payload.Text)
-		}
-		sb.WriteString("\n")
-	}
-
-	if req.Trigger != nil {
-		replyTo := req.Trigger.Sender
-		convID := req.Trigger.Metadata["conv_id"]
-		sb.WriteString("━━━ INCOMING MESSAGE ━━━\n")
-		fmt.Fprintf(&sb, "From:            %s\n", replyTo)
-		fmt.Fprintf(&sb, "Conversation ID: %s\n", convID)
-		fmt.Fprintf(&sb, "Message:         %s\n", req.Trigger.Payload.Text)
-		sb.WriteString("\n━━━ REPLY INSTRUCTIONS ━━━\n")
-		sb.WriteString("1. You MUST reply using the `converse` tool — NEVER `send_message` for directed replies.\n")
-		fmt.Fprintf(&sb, "2. Use: converse(to=%q, conv_id=%q, message=\"your reply\")\n", replyTo, convID)
-		if isGroup {
-			sb.WriteString("   In a group thread you may also change `to` to address a specific participant.\n")
-			sb.WriteString("   All other thread participants will be notified regardless.\n")
-		}
-		sb.WriteString("3. The context above is current — no need to call get_messages first.\n")
-		sb.WriteString("4. To CONTINUE: omit `done`. All participants are notified automatically.\n")
-		sb.WriteString("5. To END: set done=true only when the topic is genuinely exhausted.\n")
-		sb.WriteString("6. Be concise and substantive.\n")
+// servePageWeb serves a page of the embedded web UI.
+func servePageWeb(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, _ := web.StaticFS.ReadFile("static/" + name)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(data)
 	}
-
-	return sb.String()
 }
 
 func writeJSONWeb(w http.ResponseWriter, status int, v any) {
@@ -500,8 +659,8 @@ func writeJSONWeb(w http.ResponseWriter, status int, v any) {
 func corsMiddlewareWeb(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+protocol.RoomTokenHeader+", "+protocol.ShareHeader)
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
 			return
@@ -0,0 +1,240 @@
+package cli
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// webCacheSize is how many recent messages the web command keeps per room.
+const webCacheSize = 500
+
+// messageCache keeps the recent messages of the rooms the web command
+// relays, so the UI still loads while the remote server is briefly out of
+// reach, and so a reconnect knows where to backfill from.
+type messageCache struct {
+	mu    sync.Mutex
+	rooms map[string][]protocol.Envelope // ascending by seq
+}
+
+func newMessageCache() *messageCache {
+	return &messageCache{rooms: make(map[string][]protocol.Envelope)}
+}
+
+// add stores msgs, replacing any with the same seq, and drops the oldest
+// past webCacheSize.
+func (c *messageCache) add(room string, msgs ...protocol.Envelope) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached := c.rooms[room]
+	for _, env := range msgs {
+		if env.SeqNum <= 0 {
+			continue
+		}
+		i, found := slices.BinarySearchFunc(cached, env.SeqNum, func(e protocol.Envelope, seq int64) int {
+			return int(e.SeqNum - seq)
+		})
+		if found {
+			cached[i] = env
+		} else {
+			cached = slices.Insert(cached, i, env)
+		}
+	}
+	if len(cached) > webCacheSize {
+		cached = slices.Clone(cached[len(cached)-webCacheSize:])
+	}
+	c.rooms[room] = cached
+}
+
+// remove drops an expired message.
+func (c *messageCache) remove(room, id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rooms[room] = slices.DeleteFunc(c.rooms[room], func(e protocol.Envelope) bool {
+		return e.ID == id
+	})
+}
+
+// lastSeq is the seq of the newest cached message in room, or 0.
+func (c *messageCache) lastSeq(room string) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached := c.rooms[room]
+	if len(cached) == 0 {
+		return 0
+	}
+	return cached[len(cached)-1].SeqNum
+}
+
+// after returns up to limit cached messages newer than seq.
+func (c *messageCache) after(room string, seq int64, limit int) []protocol.Envelope {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var msgs []protocol.Envelope
+	for _, env := range c.rooms[room] {
+		if env.SeqNum > seq && len(msgs) < limit {
+			msgs = append(msgs, env)
+		}
+	}
+	return msgs
+}
+
+// latest returns the n newest cached messages.
+func (c *messageCache) latest(room string, n int) []protocol.Envelope {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached := c.rooms[room]
+	return slices.Clone(cached[max(0, len(cached)-n):])
+}
+
+// record caches a frame the remote sent a browser: a message, or the
+// expiry of one. It returns the message's seq, or 0 for other frames.
+func (c *messageCache) record(room string, frame []byte) int64 {
+	var ev protocol.ServerEvent
+	if json.Unmarshal(frame, &ev) == nil && ev.Event != "" {
+		if ev.Event == "message_expired" && ev.Message != nil {
+			c.remove(room, ev.Message.ID)
+		}
+		return 0
+	}
+	var env protocol.Envelope
+	if json.Unmarshal(frame, &env) != nil || env.ID == "" {
+		return 0
+	}
+	c.add(room, env)
+	return env.SeqNum
+}
+
+// backfill fetches what room got on the remote after the newest cached
+// message, caches it and returns it. query carries the browser's tokens.
+func (c *messageCache) backfill(remote *url.URL, room string, query url.Values) ([]protocol.Envelope, error) {
+	const page = 100
+	var all []protocol.Envelope
+	for {
+		q := url.Values{}
+		for _, k := range []string{protocol.TokenParam, protocol.RoomTokenParam, protocol.ShareParam} {
+			for _, v := range query[k] {
+				q.Add(k, v)
+			}
+		}
+		q.Set("after", strconv.FormatInt(c.lastSeq(room), 10))
+		q.Set("limit", strconv.Itoa(page))
+		u := remote.JoinPath("api", "rooms", room, "messages")
+		u.RawQuery = q.Encode()
+
+		resp, err := http.Get(u.String())
+		if err != nil {
+			return all, err
+		}
+		var list protocol.MessageList
+		err = json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return all, fmt.Errorf("backfill: %s", resp.Status)
+		}
+		if err != nil {
+			return all, fmt.Errorf("backfill: %w", err)
+		}
+		c.add(room, list.Messages...)
+		all = append(all, list.Messages...)
+		if len(list.Messages) < page {
+			return all, nil
+		}
+	}
+}
+
+// messageRead reports whether r reads a room's unfiltered history, the
+// reads the cache can answer: GET /api/rooms/{room}/messages or
+// .../messages/latest.
+func messageRead(r *http.Request) (room string, latest bool, ok bool) {
+	if r.Method != http.MethodGet {
+		return "", false, false
+	}
+	rest, found := strings.CutPrefix(r.URL.Path, "/api/rooms/")
+	if !found {
+		return "", false, false
+	}
+	room, what, _ := strings.Cut(rest, "/")
+	if room == "" || (what != "messages" && what != "messages/latest") {
+		return "", false, false
+	}
+	q := r.URL.Query()
+	for _, k := range []string{"from", "type", "conv_id", "mentions", "to", "match"} {
+		if q.Has(k) {
+			return "", false, false
+		}
+	}
+	return room, what == "messages/latest", true
+}
+
+// cacheReads has proxy cache the message lists the remote serves, and
+// answer from the cache when the remote can't.
+func cacheReads(proxy *httputil.ReverseProxy, cache *messageCache) {
+	modify := proxy.ModifyResponse
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		if modify != nil {
+			if err := modify(resp); err != nil {
+				return err
+			}
+		}
+		room, _, ok := messageRead(resp.Request)
+		if !ok {
+			return nil
+		}
+		if resp.StatusCode >= http.StatusInternalServerError {
+			// A tunnel answering for a server that is away.
+			return fmt.Errorf("remote: %s", resp.Status)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		var list protocol.MessageList
+		if json.Unmarshal(body, &list) == nil {
+			cache.add(room, list.Messages...)
+		}
+		return nil
+	}
+
+	fallback := proxy.ErrorHandler
+	proxy.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
+		room, latest, ok := messageRead(req)
+		if !ok {
+			fallback(w, req, err)
+			return
+		}
+		q := req.URL.Query()
+		var msgs []protocol.Envelope
+		if latest {
+			n, _ := strconv.Atoi(q.Get("n"))
+			msgs = cache.latest(room, cmp.Or(max(n, 0), 10))
+		} else {
+			after, _ := strconv.ParseInt(q.Get("after"), 10, 64)
+			limit, _ := strconv.Atoi(q.Get("limit"))
+			msgs = cache.after(room, after, cmp.Or(max(limit, 0), 100))
+		}
+		log.Printf("proxy error: %v (serving %d cached messages)", err, len(msgs))
+		if msgs == nil {
+			msgs = []protocol.Envelope{}
+		}
+		w.Header().Set("X-Claudetalk-Cached", "true")
+		writeJSONWeb(w, http.StatusOK, protocol.MessageList{Room: room, Messages: msgs, Count: len(msgs)})
+	}
+}
@@ -0,0 +1,166 @@
+package daemon
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// approvalSeq numbers spawns awaiting approval. IDs are unique across all
+// daemons in the process, so one terminal can answer for all of them.
+var approvalSeq atomic.Int32
+
+// Approvals holds spawn requests waiting for the daemon's owner to approve
+// them, either on the terminal or with a directed "approve <id>" message.
+type Approvals struct {
+	owner string // participant allowed to approve remotely; empty = terminal only
+	name  string // this daemon's participant name
+
+	mu      sync.Mutex
+	pending map[int]chan bool
+	order   []int // pending IDs, oldest first
+}
+
+// NewApprovals creates an approval queue. owner may approve by sending
+// "approve <id>" or "deny <id>" to name.
+func NewApprovals(owner, name string) *Approvals {
+	return &Approvals{
+		owner:   owner,
+		name:    name,
+		pending: make(map[int]chan bool),
+	}
+}
+
+// Ask shows req and its prompt and blocks until it is approved or denied.
+func (a *Approvals) Ask(req *protocol.SpawnReq, prompt string) bool {
+	ch := make(chan bool, 1)
+	id := int(approvalSeq.Add(1))
+	a.mu.Lock()
+	a.pending[id] = ch
+	a.order = append(a.order, id)
+	a.mu.Unlock()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n━━━ SPAWN #%d AWAITING APPROVAL ━━━\n", id)
+	fmt.Fprintf(&sb, "Reason: %s\n", req.Reason)
+	if req.Trigger != nil {
+		fmt.Fprintf(&sb, "From:   %s (room %s, conv %s)\n", req.Trigger.Sender, req.Trigger.Room, req.Trigger.Metadata["conv_id"])
+		fmt.Fprintf(&sb, "Text:   %s\n", req.Trigger.Payload.Text)
+	}
+	sb.WriteString("━━━ PROMPT ━━━\n")
+	sb.WriteString(prompt)
+	sb.WriteString("\n━━━━━━━━━━━━━━\n")
+	fmt.Fprintf(&sb, "Approve spawn #%d? Type \"y %d\" or \"n %d\"", id, id, id)
+	if a.owner != "" {
+		fmt.Fprintf(&sb, ", or have %s send \"approve %d\" to %s", a.owner, id, a.name)
+	}
+	sb.WriteString(".\n")
+	fmt.Fprint(os.Stderr, sb.String())
+
+	return <-ch
+}
+
+// resolve answers pending request id, or the oldest one when id is 0.
+func (a *Approvals) resolve(id int, ok bool) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if id == 0 {
+		if len(a.order) == 0 {
+			return false
+		}
+		id = a.order[0]
+	}
+	ch, found := a.pending[id]
+	if !found {
+		return false
+	}
+	delete(a.pending, id)
+	for i, v := range a.order {
+		if v == id {
+			a.order = append(a.order[:i], a.order[i+1:]...)
+			break
+		}
+	}
+	ch <- ok
+	if ok {
+		log.Printf("spawn #%d approved", id)
+	} else {
+		log.Printf("spawn #%d denied", id)
+	}
+	return true
+}
+
+// readTerminal reads "y [id]" / "n [id]" answers for any of queues from r
+// until EOF. An answer without an ID goes to the oldest request of the first
+// queue with one pending.
+func readTerminal(r io.Reader, queues []*Approvals) {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		id, ok := parseAnswer(line, "y", "yes")
+		if !ok {
+			var deny bool
+			if id, deny = parseAnswer(line, "n", "no"); !deny {
+				continue
+			}
+		}
+		found := false
+		for _, a := range queues {
+			if found = a.resolve(id, ok); found {
+				break
+			}
+		}
+		if !found {
+			fmt.Fprintln(os.Stderr, "no such pending spawn")
+		}
+	}
+}
+
+// HandleMessage resolves a request if env is an "approve <id>" or
+// "deny <id>" message from the owner to this daemon. It reports whether env
+// was an approval command, so the caller can skip spawning for it.
+func (a *Approvals) HandleMessage(env *protocol.Envelope) bool {
+	if !a.IsCommand(env) {
+		return false
+	}
+	id, ok := parseAnswer(env.Payload.Text, "approve")
+	if !ok {
+		id, _ = parseAnswer(env.Payload.Text, "deny")
+	}
+	a.resolve(id, ok)
+	return true
+}
+
+// IsCommand reports whether env is an approval command from the owner.
+func (a *Approvals) IsCommand(env *protocol.Envelope) bool {
+	if a.owner == "" || env == nil || env.Sender != a.owner || env.Metadata["to"] != a.name {
+		return false
+	}
+	_, approve := parseAnswer(env.Payload.Text, "approve")
+	_, deny := parseAnswer(env.Payload.Text, "deny")
+	return approve || deny
+}
+
+// parseAnswer matches "<word> [id]" for any of words. A missing id is 0.
+func parseAnswer(s string, words ...string) (id int, ok bool) {
+	fields := strings.Fields(strings.ToLower(s))
+	if len(fields) == 0 || len(fields) > 2 || !contains(words, fields[0]) {
+		return 0, false
+	}
+	if len(fields) == 2 {
+		n, err := strconv.Atoi(strings.TrimPrefix(fields[1], "#"))
+		if err != nil || n <= 0 {
+			return 0, false
+		}
+		id = n
+	}
+	return id, true
+}
@@ -0,0 +1,206 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/limits"
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig is the layout of a daemons.yaml file. Top-level settings are
+// defaults for every entry under daemons:
+//
+//	server: https://chat.example.com
+//	claude_bin: claude
+//	daemons:
+//	  - room: backend
+//	    name: bob's Claude
+//	    work_dir: ~/src/api
+//	    description: backend specialist
+//	    max_concurrent: 2
+//	    limits: {nice: 10, memory_mb: 4096}
+//	  - room: docs
+//	    name: bob's docs Claude
+//	    work_dir: ~/src/docs
+//	    quiet_hours: 22:00-07:00
+//	  - room: backend
+//	    name: alice
+//	    agents:
+//	      - {name: alice-reviewer, work_dir: ~/src/api, prompt_template: review.tmpl}
+//	      - {name: alice-builder, work_dir: ~/src/api-wt}
+//
+// agents are named agents run by the entry's daemon; see Agent.
+// passphrase, top-level or per entry, turns on end-to-end encryption.
+// description, model and availability make up the profile other
+// participants see; see protocol.Profile.
+type fileConfig struct {
+	Server     string      `yaml:"server"`
+	ClaudeBin  string      `yaml:"claude_bin"`
+	Passphrase string      `yaml:"passphrase"`
+	Daemons    []fileEntry `yaml:"daemons"`
+}
+
+// fileEntry is one (room, name) daemon in a daemons.yaml file.
+type fileEntry struct {
+	Server         string        `yaml:"server"`
+	Room           string        `yaml:"room"`
+	Rooms          []string      `yaml:"rooms"`
+	Name           string        `yaml:"name"`
+	ClaudeBin      string        `yaml:"claude_bin"`
+	WorkDir        string        `yaml:"work_dir"`
+	MaxConcurrent  int           `yaml:"max_concurrent"`
+	Timeout        time.Duration `yaml:"claude_timeout"`
+	Limits         fileLimits    `yaml:"limits"`
+	PromptTemplate string        `yaml:"prompt_template"`
+	OnlyFrom       []string      `yaml:"only_from"`
+	IgnoreFrom     []string      `yaml:"ignore_from"`
+	OnlyConv       []string      `yaml:"only_conv"`
+	Keywords       []string      `yaml:"keywords"`
+	OnlyType       []string      `yaml:"only_type"`
+	QuietHours     string        `yaml:"quiet_hours"`
+	Approve        bool          `yaml:"approve"`
+	Approver       string        `yaml:"approver"`
+	Mentions       bool          `yaml:"mentions"`
+	Aliases        []string      `yaml:"aliases"`
+	Priority       *int          `yaml:"priority"`
+	Takeover       bool          `yaml:"takeover"`
+	HelpRequests   string        `yaml:"help_requests"`
+	Passphrase     string        `yaml:"passphrase"`
+	Model          string        `yaml:"model"`
+	Description    string        `yaml:"description"`
+	Availability   string        `yaml:"availability"`
+	PromptBudget   int           `yaml:"prompt_budget"`
+	TrimContext    bool          `yaml:"trim_context"`
+	ClaudeName     string        `yaml:"claude_name"`
+	Agents         []fileAgent   `yaml:"agents"`
+}
+
+type fileAgent struct {
+	Name           string `yaml:"name"`
+	WorkDir        string `yaml:"work_dir"`
+	PromptTemplate string `yaml:"prompt_template"`
+}
+
+type fileLimits struct {
+	Nice       int           `yaml:"nice"`
+	MemoryMB   int           `yaml:"memory_mb"`
+	CPUPercent int           `yaml:"cpu_percent"`
+	CPUTime    time.Duration `yaml:"cpu_time"`
+}
+
+// LoadConfigFile reads a daemons.yaml file. server and passphrase are the
+// defaults for entries that don't set them. Relative paths are resolved
+// against the file's directory.
+func LoadConfigFile(path, server, passphrase string) ([]Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read daemon config: %w", err)
+	}
+	var fc fileConfig
+	if err := yaml.Unmarshal(data, &fc); err != nil {
+		return nil, fmt.Errorf("parse daemon config: %w", err)
+	}
+	if len(fc.Daemons) == 0 {
+		return nil, fmt.Errorf("daemon config %s lists no daemons", path)
+	}
+
+	base := filepath.Dir(path)
+	if fc.Server != "" {
+		server = fc.Server
+	}
+
+	cfgs := make([]Config, 0, len(fc.Daemons))
+	seen := make(map[string]bool)
+	for i, e := range fc.Daemons {
+		if e.Room == "" || e.Name == "" {
+			return nil, fmt.Errorf("daemon config: entry %d needs room and name", i+1)
+		}
+		key := e.Room + "\x00" + e.Name
+		if seen[key] {
+			return nil, fmt.Errorf("daemon config: %q in %q is listed twice", e.Name, e.Room)
+		}
+		seen[key] = true
+
+		cfg := Config{
+			ServerURL:      firstNonEmpty(e.Server, server),
+			Room:           e.Room,
+			Rooms:          e.Rooms,
+			Name:           e.Name,
+			ClaudeBin:      firstNonEmpty(e.ClaudeBin, fc.ClaudeBin),
+			WorkDir:        resolvePath(base, e.WorkDir),
+			MaxConcurrent:  e.MaxConcurrent,
+			Timeout:        e.Timeout,
+			Limits:         limits.Limits(e.Limits),
+			PromptTemplate: resolvePath(base, e.PromptTemplate),
+			Filter: Filter{
+				OnlyFrom:   e.OnlyFrom,
+				IgnoreFrom: e.IgnoreFrom,
+				ConvIDs:    e.OnlyConv,
+				Keywords:   e.Keywords,
+				Types:      e.OnlyType,
+				Quiet:      e.QuietHours,
+			},
+			Approve:      e.Approve || e.Approver != "",
+			Approver:     e.Approver,
+			Mentions:     e.Mentions || len(e.Aliases) > 0,
+			Aliases:      e.Aliases,
+			Priority:     DefaultPriority,
+			Takeover:     e.Takeover,
+			HelpRequests: e.HelpRequests,
+			Passphrase:   firstNonEmpty(e.Passphrase, fc.Passphrase, passphrase),
+			Model:        e.Model,
+			Description:  e.Description,
+			Availability: e.Availability,
+			PromptBudget: e.PromptBudget,
+			TrimContext:  e.TrimContext,
+			ClaudeName:   e.ClaudeName,
+		}
+		for _, a := range e.Agents {
+			cfg.Agents = append(cfg.Agents, Agent{
+				Name:           a.Name,
+				WorkDir:        resolvePath(base, a.WorkDir),
+				PromptTemplate: resolvePath(base, a.PromptTemplate),
+			})
+		}
+		if e.Priority != nil {
+			cfg.Priority = *e.Priority
+		}
+		if cfg.ServerURL == "" {
+			return nil, fmt.Errorf("daemon config: no server for %q in %q", e.Name, e.Room)
+		}
+		if err := cfg.Limits.Validate(); err != nil {
+			return nil, fmt.Errorf("daemon config: %q in %q: %w", e.Name, e.Room, err)
+		}
+		cfgs = append(cfgs, cfg)
+	}
+	return cfgs, nil
+}
+
+// resolvePath expands a leading ~ and makes p relative to base. Empty stays empty.
+func resolvePath(base, p string) string {
+	if p == "" {
+		return ""
+	}
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = filepath.Join(home, p[1:])
+		}
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(base, p)
+	}
+	return p
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
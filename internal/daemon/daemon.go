@@ -1,43 +1,252 @@
 package daemon
 
 import (
+	"cmp"
+	"fmt"
 	"log"
+	"maps"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/e2e"
+	"github.com/corvino/claudetalk/internal/limits"
+	"github.com/corvino/claudetalk/internal/prompt"
+	"github.com/corvino/claudetalk/internal/protocol"
 )
 
 // Config holds daemon configuration.
 type Config struct {
-	ServerURL     string
-	Room          string
-	Name          string
-	ClaudeBin     string
-	WorkDir       string
-	MaxConcurrent int
+	ServerURL      string
+	Room           string
+	Rooms          []string // additional rooms to watch over the same connection
+	Name           string
+	ClaudeBin      string
+	WorkDir        string
+	MaxConcurrent  int
+	Timeout        time.Duration // kill a spawned Claude after this long; 0 = no limit
+	Limits         limits.Limits // CPU/memory/priority limits for spawned Claudes
+	PromptTemplate string        // path to a text/template overriding spawn prompts; see package prompt
+	Filter         Filter        // which triggers to spawn for
+	Approve        bool          // ask before each spawn instead of spawning automatically
+	Approver       string        // participant who may approve spawns with a directed "approve <id>"
+	Mentions       bool          // also spawn when a broadcast @-mentions Name or an alias
+	Aliases        []string      // extra @handles for Mentions
+	Priority       int           // claim priority when another watcher uses the same name; see DefaultPriority
+	Takeover       bool          // take over spawn events for Name from any other watcher
+	HelpRequests   string        // policy for request_help spawns: allow (default), approve or deny
+	Passphrase     string        // end-to-end encryption passphrase for the rooms; empty = off
+	Model          string        // claude --model for spawned Claudes; advertised in the profile
+	Description    string        // what Name works on, advertised in the profile, e.g. "backend specialist"
+	Availability   string        // advertised availability: available, busy or away
+	PromptBudget   int           // bytes of context messages in spawn prompts; 0 = protocol.DefaultPromptBudget
+	TrimContext    bool          // have the server cut context payloads to what the prompt shows of them
+	ClaudeName     string        // what spawned Claudes speak as; "" = Name. Messages to it spawn this daemon too
+	Agents         []Agent       // named agents run alongside, each spawned for messages to its name
+}
+
+// Agent is one of several named agents a daemon runs for its owner, e.g.
+// "alice-reviewer" next to "alice-builder". The server routes messages to
+// Name to the daemon, which spawns Claudes that speak as Name.
+type Agent struct {
+	Name           string
+	WorkDir        string // "" = the daemon's
+	PromptTemplate string // path to a text/template overriding its spawn prompts; "" = the daemon's
 }
 
+// DefaultPriority is a daemon's claim priority. It beats the web watcher's 0,
+// so when both watch as the same name, only the daemon spawns.
+const DefaultPriority = 1
+
 // Run starts the daemon event loop. Blocks until interrupted.
 func Run(cfg Config) error {
-	if cfg.WorkDir == "" {
-		var err error
-		cfg.WorkDir, err = os.Getwd()
+	return RunAll([]Config{cfg})
+}
+
+// RunAll runs one daemon per config in this process, each with its own
+// connection, work dir and spawner. Blocks until interrupted.
+func RunAll(cfgs []Config) error {
+	daemons := make([]*instance, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		d, err := newInstance(cfg)
 		if err != nil {
+			if len(cfgs) > 1 {
+				return fmt.Errorf("daemon %q in %q: %w", cfg.Name, cfg.Room, err)
+			}
 			return err
 		}
+		daemons = append(daemons, d)
 	}
 
-	ws := NewWSConn(cfg.ServerURL, cfg.Room, cfg.Name)
-	spawner := NewSpawner(cfg.ClaudeBin, cfg.WorkDir, cfg.ServerURL, cfg.Room, cfg.Name, cfg.MaxConcurrent)
+	// One terminal answers approvals for every daemon; spawn IDs are unique.
+	var queues []*Approvals
+	for _, d := range daemons {
+		if d.approvals != nil {
+			queues = append(queues, d.approvals)
+		}
+	}
+	if len(queues) > 0 {
+		go readTerminal(os.Stdin, queues)
+	}
 
 	// Handle signals for graceful shutdown.
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
 
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, d := range daemons {
+		wg.Add(1)
+		go func(d *instance) {
+			defer wg.Done()
+			d.run(done)
+		}(d)
+	}
+
+	<-sigCh
+	log.Println("shutting down daemon...")
+	close(done)
+	wg.Wait()
+	return nil
+}
+
+// instance is one (room, name) daemon: a connection plus its spawner.
+type instance struct {
+	cfg       Config
+	rooms     []string
+	ws        *WSConn
+	spawner   *Spawner
+	approvals *Approvals // nil unless cfg.Approve
+}
+
+func newInstance(cfg Config) (*instance, error) {
+	if err := cfg.Filter.Validate(); err != nil {
+		return nil, err
+	}
+	switch cfg.HelpRequests {
+	case "":
+		cfg.HelpRequests = protocol.HelpAllow
+	case protocol.HelpAllow, protocol.HelpApprove, protocol.HelpDeny:
+	default:
+		return nil, fmt.Errorf("help requests policy must be allow, approve or deny")
+	}
+	if cfg.WorkDir == "" {
+		var err error
+		cfg.WorkDir, err = os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rooms := []string{cfg.Room}
+	for _, r := range cfg.Rooms {
+		if r != "" && r != cfg.Room {
+			rooms = append(rooms, r)
+		}
+	}
+
+	ws := NewWSConn(cfg.ServerURL, rooms, cfg.Name)
+	ws.priority = cfg.Priority
+	ws.takeover = cfg.Takeover
+	ws.helpPolicy = cfg.HelpRequests
+	ws.profile = protocol.Profile{
+		Description:  cfg.Description,
+		WorkDir:      cfg.WorkDir,
+		Model:        cfg.Model,
+		Availability: cfg.Availability,
+	}
+	if err := ws.profile.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.ClaudeName != "" {
+		if err := protocol.ValidateClaudeName(cfg.ClaudeName); err != nil {
+			return nil, err
+		}
+		ws.claudeName = cfg.ClaudeName
+	}
+	agents, err := loadAgents(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ws.agents = slices.Sorted(maps.Keys(agents))
+	ws.keys = e2e.NewKeyring(cfg.Passphrase)
+	if cfg.Mentions {
+		ws.mentions = append([]string{cfg.Name}, cfg.Aliases...)
+	}
+	if cfg.TrimContext {
+		// The prompt shows an eighth of its budget of each payload; see
+		// protocol.FormatContext.
+		ws.payloads.Max = cmp.Or(cfg.PromptBudget, protocol.DefaultPromptBudget) / 8
+	}
+	spawner := NewSpawner(cfg.ClaudeBin, cfg.WorkDir, cfg.ServerURL, cfg.Room, cfg.Name, cfg.MaxConcurrent)
+	spawner.timeout = cfg.Timeout
+	spawner.limits = cfg.Limits
+	spawner.passphrase = cfg.Passphrase
+	spawner.model = cfg.Model
+	spawner.promptBudget = cfg.PromptBudget
+	spawner.claudeName = cfg.ClaudeName
+	spawner.agents = agents
+	if cfg.PromptTemplate != "" {
+		tmpl, err := prompt.Load(cfg.PromptTemplate)
+		if err != nil {
+			return nil, err
+		}
+		spawner.promptTmpl = tmpl
+	}
+
+	spawner.DetectVersion()
+	ws.health = spawner.Health
+	spawner.onStart = func(req *protocol.SpawnReq) {
+		if req.Trigger != nil && req.Trigger.Metadata["to"] != "" {
+			ws.Ack(req.Trigger)
+		}
+	}
+
+	d := &instance{cfg: cfg, rooms: rooms, ws: ws, spawner: spawner}
+	if cfg.Approve || cfg.HelpRequests == protocol.HelpApprove {
+		d.approvals = NewApprovals(cfg.Approver, cfg.Name)
+	}
+	return d, nil
+}
+
+// loadAgents checks cfg's named agents and returns how to spawn each, by name.
+func loadAgents(cfg Config) (map[string]spawnAs, error) {
+	agents := make(map[string]spawnAs, len(cfg.Agents))
+	for _, a := range cfg.Agents {
+		if err := protocol.ValidateClaudeName(a.Name); err != nil {
+			return nil, fmt.Errorf("agent: %w", err)
+		}
+		if _, dup := agents[a.Name]; dup || a.Name == cfg.Name || a.Name == cfg.ClaudeName {
+			return nil, fmt.Errorf("agent %q is named twice", a.Name)
+		}
+		as := spawnAs{name: a.Name, workDir: a.WorkDir}
+		if as.workDir == "" {
+			as.workDir = cfg.WorkDir
+		}
+		if a.PromptTemplate != "" {
+			tmpl, err := prompt.Load(a.PromptTemplate)
+			if err != nil {
+				return nil, fmt.Errorf("agent %q: %w", a.Name, err)
+			}
+			as.promptTmpl = tmpl
+		}
+		agents[a.Name] = as
+	}
+	return agents, nil
+}
+
+// run handles events for one daemon until done is closed.
+func (d *instance) run(done <-chan struct{}) {
+	cfg, ws, spawner, approvals := d.cfg, d.ws, d.spawner, d.approvals
+
 	// Start WebSocket connection in background.
 	go ws.Run()
 
-	log.Printf("daemon started: room=%s name=%s", cfg.Room, cfg.Name)
+	log.Printf("daemon started: rooms=%s name=%s", strings.Join(d.rooms, ","), cfg.Name)
 	log.Printf("waiting for events...")
 
 	for {
@@ -46,8 +255,28 @@ func Run(cfg Config) error {
 			switch event.Event {
 			case "spawn":
 				if event.Spawn != nil {
+					if approvals != nil && approvals.IsCommand(event.Spawn.Trigger) {
+						continue
+					}
 					log.Printf("spawn event: reason=%s", event.Spawn.Reason)
+					if a := event.Spawn.Agent; a != "" && !spawner.HasAgent(a) {
+						log.Printf("spawn skipped: no agent named %q", a)
+						continue
+					}
+					if ok, why := cfg.Filter.Allow(event.Spawn, time.Now()); !ok {
+						log.Printf("spawn skipped: %s", why)
+						continue
+					}
+					isHelp := event.Spawn.Reason == "help_request"
+					if isHelp && cfg.HelpRequests == protocol.HelpDeny {
+						log.Printf("spawn skipped: help requests are denied")
+						continue
+					}
+					ask := cfg.Approve || (isHelp && cfg.HelpRequests == protocol.HelpApprove)
 					go func() {
+						if ask && !approvals.Ask(event.Spawn, spawner.Prompt(event.Spawn)) {
+							return
+						}
 						if err := spawner.Spawn(event.Spawn); err != nil {
 							log.Printf("spawn error: %v", err)
 						}
@@ -55,10 +284,26 @@ func Run(cfg Config) error {
 				}
 			case "message":
 				if event.Message != nil {
-					log.Printf("message: [#%d] %s: %s",
+					log.Printf("message: [%s #%d] %s: %s",
+						event.Message.Room,
 						event.Message.SeqNum,
 						event.Message.Sender,
 						truncate(event.Message.Payload.Text, 80))
+					if approvals != nil {
+						approvals.HandleMessage(event.Message)
+					}
+				}
+			case "claim":
+				if c := event.Claim; c != nil {
+					if c.State == protocol.ClaimActive {
+						log.Printf("answering spawns as %s in %s", cfg.Name, c.Room)
+					} else {
+						log.Printf("on standby in %s: %s", c.Room, c.Reason)
+					}
+				}
+			case "message_expired":
+				if event.Message != nil {
+					log.Printf("message expired: [%s #%d]", event.Message.Room, event.Message.SeqNum)
 				}
 			case "file_shared":
 				if event.File != nil {
@@ -68,13 +313,13 @@ func Run(cfg Config) error {
 						event.File.Size)
 				}
 			default:
-				log.Printf("unknown event: %s", event.Event)
+				// Newer servers send events older daemons don't know.
+				log.Printf("unknown event %q; if the server is newer, run \"claudetalk self-update\"", event.Event)
 			}
 
-		case <-sigCh:
-			log.Println("shutting down daemon...")
+		case <-done:
 			ws.Close()
-			return nil
+			return
 		}
 	}
 }
@@ -0,0 +1,128 @@
+package daemon
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// Filter decides which spawn requests the daemon acts on. Empty lists match
+// everything. Sender patterns use shell globs, e.g. "*'s Claude".
+type Filter struct {
+	OnlyFrom   []string // spawn only for senders matching one of these
+	IgnoreFrom []string // never spawn for senders matching one of these
+	ConvIDs    []string // spawn only for these conversation IDs
+	Keywords   []string // spawn only if the message contains one of these (case-insensitive)
+	Types      []string // spawn only for these message types
+	Quiet      string   // do-not-disturb window in local time, e.g. "22:00-07:00"
+}
+
+// Validate reports malformed patterns and quiet hours.
+func (f Filter) Validate() error {
+	for _, p := range append(append([]string{}, f.OnlyFrom...), f.IgnoreFrom...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("bad sender pattern %q: %w", p, err)
+		}
+	}
+	if f.Quiet != "" {
+		if _, _, err := parseQuiet(f.Quiet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Allow reports whether req should spawn at time now. When it doesn't, the
+// returned string says why, for logs.
+func (f Filter) Allow(req *protocol.SpawnReq, now time.Time) (bool, string) {
+	if f.Quiet != "" {
+		if start, end, err := parseQuiet(f.Quiet); err == nil && inQuiet(now, start, end) {
+			return false, "quiet hours " + f.Quiet
+		}
+	}
+
+	var env protocol.Envelope
+	if req.Trigger != nil {
+		env = *req.Trigger
+	}
+	if len(f.OnlyFrom) > 0 && !matchAny(f.OnlyFrom, env.Sender) {
+		return false, fmt.Sprintf("sender %q not in --only-from", env.Sender)
+	}
+	if matchAny(f.IgnoreFrom, env.Sender) {
+		return false, fmt.Sprintf("sender %q matches --ignore-from", env.Sender)
+	}
+	if len(f.ConvIDs) > 0 && !contains(f.ConvIDs, env.Metadata["conv_id"]) {
+		return false, fmt.Sprintf("conv_id %q not in --only-conv", env.Metadata["conv_id"])
+	}
+	if len(f.Types) > 0 && !contains(f.Types, env.Type) {
+		return false, fmt.Sprintf("message type %q not in --only-type", env.Type)
+	}
+	if len(f.Keywords) > 0 {
+		text := strings.ToLower(env.Payload.Text)
+		found := false
+		for _, kw := range f.Keywords {
+			if strings.Contains(text, strings.ToLower(kw)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, "no --keyword in message"
+		}
+	}
+	return true, ""
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// parseQuiet parses "HH:MM-HH:MM" into minutes after midnight.
+func parseQuiet(s string) (start, end int, err error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("quiet hours %q: want HH:MM-HH:MM", s)
+	}
+	if start, err = parseClock(from); err != nil {
+		return 0, 0, fmt.Errorf("quiet hours %q: %w", s, err)
+	}
+	if end, err = parseClock(to); err != nil {
+		return 0, 0, fmt.Errorf("quiet hours %q: %w", s, err)
+	}
+	return start, end, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("bad time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// inQuiet reports whether now falls in [start, end), wrapping past midnight
+// when end is before start.
+func inQuiet(now time.Time, start, end int) bool {
+	m := now.Hour()*60 + now.Minute()
+	if start <= end {
+		return m >= start && m < end
+	}
+	return m >= start || m < end
+}
@@ -1,15 +1,23 @@
 package daemon
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/corvino/claudetalk/internal/e2e"
+	"github.com/corvino/claudetalk/internal/fakeclaude"
+	"github.com/corvino/claudetalk/internal/limits"
+	"github.com/corvino/claudetalk/internal/prompt"
 	"github.com/corvino/claudetalk/internal/protocol"
 	"github.com/google/uuid"
 )
@@ -20,21 +28,38 @@ type mcpConfig struct {
 }
 
 type mcpServerConfig struct {
-	Command string   `json:"command"`
-	Args    []string `json:"args"`
+	Command string            `json:"command"`
+	Args    []string          `json:"args"`
+	Env     map[string]string `json:"env,omitempty"`
 }
 
 // Spawner manages launching Claude Code instances.
 type Spawner struct {
-	claudeBin    string
-	workDir      string
-	serverURL    string
-	room         string
-	name         string
+	claudeBin     string
+	workDir       string
+	serverURL     string
+	room          string
+	name          string
 	maxConcurrent int
+	timeout       time.Duration // 0 = no limit
+	limits        limits.Limits
+	promptTmpl    string                   // prompt overrides, applied after the server's and the room's
+	onStart       func(*protocol.SpawnReq) // called once Claude is running, e.g. to ack delivery
+	passphrase    string                   // room passphrase handed to Claude's MCP server; empty = E2E off
+	model         string                   // claude --model; empty = claude's default
+	promptBudget  int                      // bytes of context in the built-in prompt; see protocol.FormatContext
+	claudeName    string                   // what spawned Claudes speak as; "" = name
+	agents        map[string]spawnAs       // named agents, by name; see Agent
 
-	sem chan struct{} // Semaphore for concurrency control
-	mu  sync.Mutex
+	slots *spawnSlots // concurrency control; urgent triggers are served first
+	mu    sync.Mutex
+
+	// Guarded by mu; reported to the server by Health.
+	active        int
+	lastSpawnAt   time.Time
+	lastResult    string
+	lastError     string
+	claudeVersion string
 }
 
 // NewSpawner creates a new Claude Code spawner.
@@ -52,28 +77,74 @@ func NewSpawner(claudeBin, workDir, serverURL, room, name string, maxConcurrent
 		room:          room,
 		name:          name,
 		maxConcurrent: maxConcurrent,
-		sem:           make(chan struct{}, maxConcurrent),
+		slots:         &spawnSlots{free: maxConcurrent},
 	}
 }
 
 // Spawn launches a Claude Code instance with the given spawn request.
 // This runs synchronously and blocks until Claude exits.
 func (s *Spawner) Spawn(req *protocol.SpawnReq) error {
-	// Acquire semaphore.
-	s.sem <- struct{}{}
-	defer func() { <-s.sem }()
+	priority := protocol.PriorityNormal
+	if req.Trigger != nil {
+		priority = protocol.MessagePriority(req.Trigger.Metadata)
+	}
+	s.slots.acquire(priority)
+	defer s.slots.release()
+
+	s.mu.Lock()
+	s.active++
+	s.lastSpawnAt = time.Now().UTC()
+	s.mu.Unlock()
+	err := s.spawn(req)
+	s.finished(err)
+	return err
+}
+
+// spawnAs is who a spawned Claude is: the daemon's own, or one of its
+// named agents.
+type spawnAs struct {
+	name       string // what it speaks as
+	workDir    string
+	promptTmpl string // prompt overrides, applied after the server's and the room's
+}
+
+// as returns who req's Claude is.
+func (s *Spawner) as(req *protocol.SpawnReq) spawnAs {
+	a, ok := s.agents[req.Agent]
+	if !ok {
+		a = spawnAs{name: s.claudeName, workDir: s.workDir}
+		if a.name == "" {
+			a.name = s.name
+		}
+	}
+	if a.promptTmpl == "" {
+		a.promptTmpl = s.promptTmpl
+	}
+	return a
+}
+
+// HasAgent reports whether name is one of the spawner's named agents.
+func (s *Spawner) HasAgent(name string) bool {
+	_, ok := s.agents[name]
+	return ok
+}
+
+// spawn runs Claude for req. The caller holds a slot.
+func (s *Spawner) spawn(req *protocol.SpawnReq) error {
+	room := s.roomFor(req)
+	as := s.as(req)
 
 	// Generate temp MCP config.
-	configPath, err := s.writeMCPConfig()
+	configPath, err := s.writeMCPConfig(room, as.name)
 	if err != nil {
 		return fmt.Errorf("write mcp config: %w", err)
 	}
 	defer os.Remove(configPath)
 
 	// Build the prompt.
-	prompt := s.buildPrompt(req)
+	prompt := s.buildPrompt(room, req, as)
 
-	log.Printf("spawning claude for: %s", req.Reason)
+	log.Printf("spawning claude as %s for: %s", as.name, req.Reason)
 
 	// Build command.
 	args := []string{
@@ -81,13 +152,34 @@ func (s *Spawner) Spawn(req *protocol.SpawnReq) error {
 		"--print",
 		"-p", prompt,
 	}
+	if s.model != "" {
+		args = append(args, "--model", s.model)
+	}
 
-	cmd := exec.Command(s.claudeBin, args...)
-	cmd.Dir = s.workDir
+	ctx := context.Background()
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	bin, args := fakeclaude.Command(s.claudeBin, args)
+	bin, args = limits.Wrap(s.limits, bin, args)
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Dir = as.workDir
 	cmd.Stdout = os.Stderr // Claude's output goes to daemon's stderr for visibility
 	cmd.Stderr = os.Stderr
 
-	if err := cmd.Run(); err != nil {
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start claude: %w", err)
+	}
+	if s.onStart != nil {
+		s.onStart(req)
+	}
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("claude %w after %s", errTimeout, s.timeout)
+		}
 		return fmt.Errorf("claude exited with error: %w", err)
 	}
 
@@ -95,7 +187,69 @@ func (s *Spawner) Spawn(req *protocol.SpawnReq) error {
 	return nil
 }
 
-func (s *Spawner) writeMCPConfig() (string, error) {
+// errTimeout marks a spawn killed by the timeout, for health reports.
+var errTimeout = errors.New("timed out")
+
+// finished records the outcome of a spawn.
+func (s *Spawner) finished(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active--
+	switch {
+	case err == nil:
+		s.lastResult, s.lastError = "ok", ""
+	case errors.Is(err, errTimeout):
+		s.lastResult, s.lastError = "timeout", err.Error()
+	default:
+		s.lastResult, s.lastError = "error", err.Error()
+	}
+}
+
+// DetectVersion records the first line of "claude --version" for health reports.
+func (s *Spawner) DetectVersion() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	bin, args := fakeclaude.Command(s.claudeBin, []string{"--version"})
+	out, err := exec.CommandContext(ctx, bin, args...).Output()
+	if err != nil {
+		log.Printf("could not get claude version: %v", err)
+		return
+	}
+	version, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	s.mu.Lock()
+	s.claudeVersion = version
+	s.mu.Unlock()
+}
+
+// Health reports the spawner's current state.
+func (s *Spawner) Health() protocol.DaemonHealth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return protocol.DaemonHealth{
+		ActiveSpawns:  s.active,
+		MaxConcurrent: s.maxConcurrent,
+		LastSpawnAt:   s.lastSpawnAt,
+		LastResult:    s.lastResult,
+		LastError:     s.lastError,
+		ClaudeVersion: s.claudeVersion,
+	}
+}
+
+// Prompt returns the prompt a spawn for req would be given.
+func (s *Spawner) Prompt(req *protocol.SpawnReq) string {
+	return s.buildPrompt(s.roomFor(req), req, s.as(req))
+}
+
+// roomFor picks the room to answer in. Multi-room daemons answer in the room
+// the trigger came from.
+func (s *Spawner) roomFor(req *protocol.SpawnReq) string {
+	if req.Trigger != nil && req.Trigger.Room != "" {
+		return req.Trigger.Room
+	}
+	return s.room
+}
+
+func (s *Spawner) writeMCPConfig(room, name string) (string, error) {
 	// Find the claudetalk binary path.
 	claudetalkBin, err := os.Executable()
 	if err != nil {
@@ -109,9 +263,10 @@ func (s *Spawner) writeMCPConfig() (string, error) {
 				Args: []string{
 					"mcp-serve",
 					"--server", s.serverURL,
-					"--room", s.room,
-					"--name", s.name,
+					"--room", room,
+					"--name", name,
 				},
+				Env: e2e.Env(s.passphrase),
 			},
 		},
 	}
@@ -129,41 +284,65 @@ func (s *Spawner) writeMCPConfig() (string, error) {
 	return tmpFile, nil
 }
 
-func (s *Spawner) buildPrompt(req *protocol.SpawnReq) string {
-	var sb strings.Builder
+func (s *Spawner) buildPrompt(room string, req *protocol.SpawnReq, as spawnAs) string {
+	overrides := slices.Clip(req.PromptTemplates)
+	if as.promptTmpl != "" {
+		overrides = append(overrides, as.promptTmpl)
+	}
+	return prompt.Build(prompt.NewData(as.name, room, req, s.promptBudget), overrides...)
+}
 
-	sb.WriteString(fmt.Sprintf("You are %q in the ClaudeTalk room %q.\n\n", s.name, s.room))
+// spawnSlots hands out up to free concurrent spawn slots. When all are taken,
+// waiters are served urgent first, then normal, then low, and in arrival order
+// within a priority.
+type spawnSlots struct {
+	mu      sync.Mutex
+	free    int
+	waiting [3][]chan struct{} // by priorityRank
+}
 
-	// Add context messages.
-	if len(req.Context) > 0 {
-		sb.WriteString("Recent conversation context (newest at bottom):\n")
-		for _, env := range req.Context {
-			ts := env.Timestamp.Format("15:04:05")
-			fmt.Fprintf(&sb, "[%s] %s", ts, env.Sender)
-			if to := env.Metadata["to"]; to != "" {
-				fmt.Fprintf(&sb, " → %s", to)
-			}
-			fmt.Fprintf(&sb, ": %s\n", env.Payload.Text)
-		}
-		sb.WriteString("\n")
+func priorityRank(p string) int {
+	switch p {
+	case protocol.PriorityUrgent:
+		return 0
+	case protocol.PriorityLow:
+		return 2
 	}
+	return 1
+}
 
-	// Add the trigger message and instructions.
-	if req.Trigger != nil {
-		replyTo := req.Trigger.Sender
-		convID := req.Trigger.Metadata["conv_id"]
-		sb.WriteString("━━━ INCOMING DIRECT MESSAGE ━━━\n")
-		fmt.Fprintf(&sb, "From:            %s\n", replyTo)
-		fmt.Fprintf(&sb, "Conversation ID: %s\n", convID)
-		fmt.Fprintf(&sb, "Message:         %s\n", req.Trigger.Payload.Text)
-		sb.WriteString("\n━━━ REPLY INSTRUCTIONS ━━━\n")
-		sb.WriteString("1. You MUST reply using the `converse` tool — NEVER `send_message` for directed replies.\n")
-		fmt.Fprintf(&sb, "2. Use exactly: converse(to=%q, conv_id=%q, message=\"your reply\")\n", replyTo, convID)
-		sb.WriteString("3. Do NOT call get_messages first — the context above is already current.\n")
-		sb.WriteString("4. To CONTINUE the conversation: omit `done` (defaults to false). The other Claude will be automatically notified and will reply.\n")
-		sb.WriteString("5. To END the conversation: set done=true only when the topic is genuinely exhausted and neither side has anything left to add.\n")
-		sb.WriteString("6. Be concise and substantive. This is a Claude-to-Claude conversation.\n")
-	}
-
-	return sb.String()
+// acquire blocks until a slot is free for a spawn of priority p.
+func (s *spawnSlots) acquire(p string) {
+	s.mu.Lock()
+	if s.free > 0 {
+		s.free--
+		s.mu.Unlock()
+		return
+	}
+	ch := make(chan struct{})
+	r := priorityRank(p)
+	s.waiting[r] = append(s.waiting[r], ch)
+	jumped := 0
+	for _, w := range s.waiting[r+1:] {
+		jumped += len(w)
+	}
+	s.mu.Unlock()
+	if jumped > 0 && p == protocol.PriorityUrgent {
+		log.Printf("urgent spawn queued ahead of %d waiting spawn(s)", jumped)
+	}
+	<-ch
+}
+
+// release hands the slot to the highest-priority waiter, or frees it.
+func (s *spawnSlots) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for r := range s.waiting {
+		if len(s.waiting[r]) > 0 {
+			close(s.waiting[r][0])
+			s.waiting[r] = s.waiting[r][1:]
+			return
+		}
+	}
+	s.free++
 }
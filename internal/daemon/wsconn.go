@@ -2,33 +2,82 @@ package daemon
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math/rand/v2"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/corvino/claudetalk/internal/e2e"
 	"github.com/corvino/claudetalk/internal/protocol"
 	"github.com/gorilla/websocket"
 )
 
 // WSConn is a persistent WebSocket connection with automatic reconnect.
 type WSConn struct {
-	serverURL string
-	room      string
-	name      string
+	serverURL  string
+	rooms      []string
+	name       string
+	claudeName string   // name picked for name's Claude, registered with the server; "" = none
+	agents     []string // named agents this daemon also answers for; see Agent
+	mentions   []string // @handles that summon this daemon from broadcasts
+	priority   int      // claim priority against other connections under the same name
+	takeover   bool     // take the name's claim even from a higher-priority connection
+	helpPolicy string   // request_help policy advertised to the server
 
-	events chan protocol.ServerEvent
-	done   chan struct{}
-	once   sync.Once
+	// profile is advertised to the server at every (re)connect.
+	profile protocol.Profile
+
+	// payloads asks the server to trim spawn contexts' payloads.
+	payloads protocol.PayloadOptions
+
+	// keys decrypts end-to-end encrypted messages in events; nil = E2E off.
+	keys *e2e.Keyring
+
+	// health, if set, is reported to the server every healthInterval.
+	health func() protocol.DaemonHealth
+
+	// versionWarning is the protocol warning last logged, so a reconnect
+	// to the same server doesn't repeat it; see protocol.CheckServer.
+	versionWarning string
+
+	writeMu sync.Mutex      // gorilla connections allow one concurrent writer
+	conn    *websocket.Conn // current connection, nil while reconnecting; guarded by writeMu
+	events  chan protocol.ServerEvent
+	done    chan struct{}
+	once    sync.Once
 }
 
-// NewWSConn creates a new persistent WebSocket connection.
-func NewWSConn(serverURL, room, name string) *WSConn {
+// open decrypts the messages an event carries.
+func (ws *WSConn) open(event *protocol.ServerEvent) {
+	if event.Message != nil {
+		ws.keys.Open(event.Message)
+	}
+	if s := event.Spawn; s != nil {
+		if s.Trigger != nil {
+			ws.keys.Open(s.Trigger)
+		}
+		ws.keys.OpenAll(s.Context)
+	}
+}
+
+// restartJitter bounds the random wait before reconnecting to a server
+// that is restarting.
+const restartJitter = 2 * time.Second
+
+// healthInterval is how often a daemon reports its status to the server.
+const healthInterval = 30 * time.Second
+
+// NewWSConn creates a new persistent WebSocket connection. With more than one
+// room, a single multiplexed connection carries events for all of them.
+func NewWSConn(serverURL string, rooms []string, name string) *WSConn {
 	return &WSConn{
 		serverURL: serverURL,
-		room:      room,
+		rooms:     rooms,
 		name:      name,
 		events:    make(chan protocol.ServerEvent, 64),
 		done:      make(chan struct{}),
@@ -72,9 +121,17 @@ func (ws *WSConn) Run() {
 		default:
 		}
 
-		log.Printf("reconnecting in %s...", backoff)
+		wait := backoff
+		var closeErr *websocket.CloseError
+		if errors.As(err, &closeErr) && closeErr.Code == websocket.CloseServiceRestart {
+			// Another server is taking over: reconnect to it soon, at a
+			// random moment so daemons disconnected together spread out.
+			backoff = time.Second
+			wait = rand.N(restartJitter)
+		}
+		log.Printf("reconnecting in %s...", wait.Round(time.Millisecond))
 		select {
-		case <-time.After(backoff):
+		case <-time.After(wait):
 		case <-ws.done:
 			return
 		}
@@ -93,20 +150,47 @@ func (ws *WSConn) connect() error {
 		return err
 	}
 
-	log.Printf("connecting to %s", wsURL)
-	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	log.Printf("connecting to %s", protocol.RedactToken(wsURL))
+	conn, resp, err := websocket.DefaultDialer.Dial(wsURL, protocol.VersionHeader())
 	if err != nil {
-		return fmt.Errorf("dial: %w", err)
+		return fmt.Errorf("dial: %w", protocol.HandshakeError(err, resp))
 	}
 	defer conn.Close()
+	warning, err := protocol.CheckServer(resp.Header)
+	if err != nil {
+		return err
+	}
+	if warning != ws.versionWarning {
+		ws.versionWarning = warning
+		if warning != "" {
+			log.Printf("WARNING: %s", warning)
+		}
+	}
+
+	ws.writeMu.Lock()
+	ws.conn = conn
+	ws.writeMu.Unlock()
+	defer func() {
+		ws.writeMu.Lock()
+		ws.conn = nil
+		ws.writeMu.Unlock()
+	}()
 
-	log.Printf("connected to room %q as %q (daemon mode)", ws.room, ws.name)
+	log.Printf("connected to room(s) %s as %q (daemon mode)", strings.Join(ws.rooms, ","), ws.name)
+
+	if ws.health != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+		go ws.reportHealth(conn, stop)
+	}
 
 	// Reset backoff on successful connect (handled by caller).
 	for {
 		select {
 		case <-ws.done:
+			ws.writeMu.Lock()
 			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			ws.writeMu.Unlock()
 			return nil
 		default:
 		}
@@ -121,6 +205,7 @@ func (ws *WSConn) connect() error {
 			log.Printf("failed to unmarshal server event: %v", err)
 			continue
 		}
+		ws.open(&event)
 
 		select {
 		case ws.events <- event:
@@ -130,6 +215,40 @@ func (ws *WSConn) connect() error {
 	}
 }
 
+// Ack tells the server a Claude was spawned for env, so it stamps
+// delivered_at on it. The ack is dropped if the connection is down.
+func (ws *WSConn) Ack(env *protocol.Envelope) {
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+	if ws.conn == nil {
+		return
+	}
+	if err := ws.conn.WriteJSON(protocol.SendRequest{Room: env.Room, Ack: &protocol.DeliveryAck{MessageID: env.ID}}); err != nil {
+		log.Printf("ack #%d: %v", env.SeqNum, err)
+	}
+}
+
+// reportHealth sends a status report now and then every healthInterval
+// until stop is closed.
+func (ws *WSConn) reportHealth(conn *websocket.Conn, stop <-chan struct{}) {
+	ticker := time.NewTicker(healthInterval)
+	defer ticker.Stop()
+	for {
+		h := ws.health()
+		ws.writeMu.Lock()
+		err := conn.WriteJSON(protocol.SendRequest{Health: &h})
+		ws.writeMu.Unlock()
+		if err != nil {
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
 func (ws *WSConn) buildWSURL() (string, error) {
 	u, err := url.Parse(ws.serverURL)
 	if err != nil {
@@ -144,11 +263,39 @@ func (ws *WSConn) buildWSURL() (string, error) {
 		u.Scheme = "ws"
 	}
 
-	u.Path = fmt.Sprintf("/ws/%s", ws.room)
+	// Keep the server URL's path, which a workspace's has.
+	base := strings.TrimRight(u.Path, "/")
 	q := u.Query()
+	if len(ws.rooms) == 1 {
+		u.Path = fmt.Sprintf("%s/ws/%s", base, ws.rooms[0])
+	} else {
+		u.Path = base + "/ws"
+		q.Set("rooms", strings.Join(ws.rooms, ","))
+	}
 	q.Set("sender", ws.name)
+	for k, v := range protocol.TokenQuery() {
+		q[k] = v
+	}
 	q.Set("mode", "daemon")
 	q.Set("role", "daemon")
+	q.Set("priority", strconv.Itoa(ws.priority))
+	if ws.takeover {
+		q.Set("takeover", "1")
+	}
+	if ws.helpPolicy != "" {
+		q.Set("help", ws.helpPolicy)
+	}
+	if ws.claudeName != "" {
+		q.Set("claude_name", ws.claudeName)
+	}
+	for _, a := range ws.agents {
+		q.Add("agent", a)
+	}
+	for _, h := range ws.mentions {
+		q.Add("mention", h)
+	}
+	ws.profile.AddQuery(q)
+	ws.payloads.AddQuery(q)
 	u.RawQuery = q.Encode()
 
 	return u.String(), nil
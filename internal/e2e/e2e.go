@@ -0,0 +1,219 @@
+// Package e2e encrypts message payloads with a room key derived from a shared
+// passphrase, so the server (and any tunnel in front of it) only ever sees
+// ciphertext.
+//
+// A room key is PBKDF2-HMAC-SHA256 of the passphrase, salted with the room
+// name. A sealed payload is the JSON payload encrypted with AES-256-GCM,
+// stored base64-encoded as nonce||ciphertext in Payload.Text, with Meta set
+// to Version. The web UI derives the same key with WebCrypto.
+package e2e
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// Meta is the metadata key marking a message whose payload is still sealed.
+const Meta = protocol.MetaSealed
+
+// Version is the only sealing scheme so far.
+const Version = "v1"
+
+// iterations is the PBKDF2 work factor. The web UI must use the same value.
+const iterations = 100000
+
+// EnvVar is the environment variable a passphrase is read from, and how one
+// is handed to spawned MCP servers.
+const EnvVar = "CLAUDETALK_PASSPHRASE"
+
+// Placeholder replaces the text of a message that couldn't be opened.
+const Placeholder = "[encrypted message — set the room passphrase to read it]"
+
+// ErrWrongKey means a sealed payload didn't authenticate: the passphrase
+// differs from the sender's.
+var ErrWrongKey = errors.New("e2e: wrong passphrase")
+
+// Key encrypts and decrypts payloads for one room.
+type Key struct {
+	aead cipher.AEAD
+}
+
+// DeriveKey derives room's key from passphrase.
+func DeriveKey(passphrase, room string) *Key {
+	raw := pbkdf2([]byte(passphrase), []byte("claudetalk:"+room), iterations, 32)
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		panic(err) // unreachable: the key is always 32 bytes
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return &Key{aead: aead}
+}
+
+// Seal replaces req's payload with its ciphertext and marks it with Meta.
+func (k *Key) Seal(req *protocol.SendRequest) error {
+	plain, err := json.Marshal(req.Payload)
+	if err != nil {
+		return fmt.Errorf("e2e: marshal payload: %w", err)
+	}
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("e2e: nonce: %w", err)
+	}
+	sealed := k.aead.Seal(nonce, nonce, plain, nil)
+
+	md := make(map[string]string, len(req.Metadata)+1)
+	for key, v := range req.Metadata {
+		md[key] = v
+	}
+	md[Meta] = Version
+	req.Metadata = md
+	req.Payload = protocol.Payload{Text: base64.StdEncoding.EncodeToString(sealed)}
+	return nil
+}
+
+// Open decrypts a sealed envelope in place and clears its Meta mark.
+// Envelopes that aren't sealed are left alone.
+func (k *Key) Open(env *protocol.Envelope) error {
+	if env.Metadata[Meta] == "" {
+		return nil
+	}
+	if env.Metadata[Meta] != Version {
+		return fmt.Errorf("e2e: unknown scheme %q", env.Metadata[Meta])
+	}
+	sealed, err := base64.StdEncoding.DecodeString(env.Payload.Text)
+	if err != nil || len(sealed) < k.aead.NonceSize() {
+		return fmt.Errorf("e2e: malformed ciphertext")
+	}
+	n := k.aead.NonceSize()
+	plain, err := k.aead.Open(nil, sealed[:n], sealed[n:], nil)
+	if err != nil {
+		return ErrWrongKey
+	}
+	var p protocol.Payload
+	if err := json.Unmarshal(plain, &p); err != nil {
+		return fmt.Errorf("e2e: decode payload: %w", err)
+	}
+
+	md := make(map[string]string, len(env.Metadata))
+	for key, v := range env.Metadata {
+		if key != Meta {
+			md[key] = v
+		}
+	}
+	env.Metadata = md
+	env.Payload = p
+	return nil
+}
+
+// Keyring holds one passphrase and the keys derived from it per room.
+// A nil *Keyring is valid and means E2E is off.
+type Keyring struct {
+	passphrase string
+
+	mu   sync.Mutex
+	keys map[string]*Key
+}
+
+// NewKeyring returns a keyring for passphrase, or nil if it is empty.
+func NewKeyring(passphrase string) *Keyring {
+	if passphrase == "" {
+		return nil
+	}
+	return &Keyring{passphrase: passphrase, keys: make(map[string]*Key)}
+}
+
+// Key returns room's key, deriving it on first use.
+func (kr *Keyring) Key(room string) *Key {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	k, ok := kr.keys[room]
+	if !ok {
+		k = DeriveKey(kr.passphrase, room)
+		kr.keys[room] = k
+	}
+	return k
+}
+
+// Seal encrypts req for room. With a nil keyring it does nothing.
+func (kr *Keyring) Seal(room string, req *protocol.SendRequest) error {
+	if kr == nil {
+		return nil
+	}
+	return kr.Key(room).Seal(req)
+}
+
+// Open decrypts env if it is sealed. When that isn't possible, because
+// there is no keyring or the passphrase is wrong, env is redacted so callers
+// never show ciphertext.
+func (kr *Keyring) Open(env *protocol.Envelope) {
+	if env.Metadata[Meta] == "" {
+		return
+	}
+	if kr != nil && kr.Key(env.Room).Open(env) == nil {
+		return
+	}
+	Redact(env)
+}
+
+// Redact turns a sealed envelope into a text message reading Placeholder,
+// for code that has no key, such as the server's digests.
+func Redact(env *protocol.Envelope) {
+	if env.Metadata[Meta] == "" {
+		return
+	}
+	env.Type = protocol.TypeText
+	env.Payload = protocol.Payload{Text: Placeholder}
+}
+
+// OpenAll decrypts each envelope in envs; see Open.
+func (kr *Keyring) OpenAll(envs []protocol.Envelope) {
+	for i := range envs {
+		kr.Open(&envs[i])
+	}
+}
+
+// Env returns the environment for an MCP server config that passes
+// passphrase on, or nil if it is empty.
+func Env(passphrase string) map[string]string {
+	if passphrase == "" {
+		return nil
+	}
+	return map[string]string{EnvVar: passphrase}
+}
+
+// pbkdf2 implements PBKDF2 (RFC 8018) with HMAC-SHA256.
+func pbkdf2(password, salt []byte, iter, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var out []byte
+	for block := uint32(1); len(out) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.Write(prf, binary.BigEndian, block)
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+		for i := 1; i < iter; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		out = append(out, t...)
+	}
+	return out[:keyLen]
+}
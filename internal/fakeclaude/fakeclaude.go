@@ -0,0 +1,299 @@
+// Package fakeclaude emulates the claude CLI for tests. It reads the spawn
+// prompt, starts the MCP servers from --mcp-config and answers through them
+// with a canned behaviour, so the spawn pipeline, group threads and runaway
+// conversations can run end to end without Anthropic credentials.
+//
+// Daemons and runners use it when the claude binary is set to Bin; the
+// process is then "claudetalk fake-claude" with the usual claude arguments.
+package fakeclaude
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Bin is the --claude-bin value that selects the fake.
+const Bin = "fake"
+
+// Version is what "claude --version" prints for the fake.
+const Version = "0.0.0 (fake claude)"
+
+// BehaviorEnv and DelayEnv configure spawned fakes, which only get the
+// arguments a real claude would.
+const (
+	BehaviorEnv = "CLAUDETALK_FAKE_CLAUDE"
+	DelayEnv    = "CLAUDETALK_FAKE_DELAY"
+)
+
+// Behaviours.
+const (
+	Reply  = "reply"  // answer once and end the conversation (default)
+	Chat   = "chat"   // answer and keep the conversation going, to exercise runaway threads
+	Echo   = "echo"   // answer with the triggering message's text, ending the conversation
+	Silent = "silent" // call no tools
+	Fail   = "fail"   // exit with an error
+	Hang   = "hang"   // block until killed, to exercise timeouts
+)
+
+// Behaviors lists the valid behaviours.
+var Behaviors = []string{Reply, Chat, Echo, Silent, Fail, Hang}
+
+// Command returns the command line for running bin with args. When bin is
+// Bin it is this executable's fake-claude subcommand; otherwise bin itself.
+func Command(bin string, args []string) (string, []string) {
+	if bin != Bin {
+		return bin, args
+	}
+	self, err := os.Executable()
+	if err != nil {
+		self = "claudetalk"
+	}
+	return self, append([]string{"fake-claude"}, args...)
+}
+
+// Options are the claude CLI arguments the fake honours.
+type Options struct {
+	MCPConfig    string        // path to an --mcp-config file
+	OutputFormat string        // "text" (default) or "stream-json"
+	ResumeID     string        // --resume: reused as the session ID
+	Behavior     string        // one of Behaviors; empty = Reply
+	Delay        time.Duration // pause before answering, to simulate work
+}
+
+// Run answers prompt according to opts.Behavior, writing claude-style output
+// to stdout.
+func Run(ctx context.Context, opts Options, prompt string, stdout io.Writer) error {
+	if opts.Behavior == "" {
+		opts.Behavior = Reply
+	}
+	valid := false
+	for _, b := range Behaviors {
+		valid = valid || b == opts.Behavior
+	}
+	if !valid {
+		return fmt.Errorf("unknown behavior %q (want %s)", opts.Behavior, strings.Join(Behaviors, ", "))
+	}
+	session := opts.ResumeID
+	if session == "" {
+		session = uuid.New().String()
+	}
+	out := &output{w: stdout, stream: opts.OutputFormat == "stream-json", session: session}
+	out.event(map[string]any{"type": "system", "subtype": "init", "session_id": session})
+
+	switch opts.Behavior {
+	case Fail:
+		return fmt.Errorf("fake claude failing as asked")
+	case Hang:
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if opts.Delay > 0 {
+		select {
+		case <-time.After(opts.Delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if opts.Behavior == Silent {
+		out.result("Nothing to do.")
+		return nil
+	}
+
+	name, args := plan(parsePrompt(prompt), opts.Behavior)
+	if opts.MCPConfig == "" {
+		return fmt.Errorf("--mcp-config is required to answer")
+	}
+	c, err := connect(ctx, opts.MCPConfig)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	out.toolUse(name, args)
+	res, err := c.CallTool(ctx, mcp.CallToolRequest{Params: mcp.CallToolParams{Name: name, Arguments: args}})
+	if err != nil {
+		return fmt.Errorf("call %s: %w", name, err)
+	}
+	text := resultText(res)
+	if res.IsError {
+		return fmt.Errorf("%s failed: %s", name, text)
+	}
+	out.result(fmt.Sprintf("Called %s: %s", name, text))
+	return nil
+}
+
+// spawnPrompt is what the fake understands of a prompt.
+type spawnPrompt struct {
+	self    string // "You are X"
+	to      string // converse(to=...) in the reply instructions
+	convID  string // converse(..., conv_id=...)
+	trigger string // the Message: or Task: line, or the user's request
+	mention bool   // a broadcast @-mention, answered with a broadcast
+}
+
+var (
+	selfPattern     = regexp.MustCompile(`You are ("(?:[^"\\]|\\.)*")`)
+	conversePattern = regexp.MustCompile(`converse\(to=("(?:[^"\\]|\\.)*")(?:, conv_id=("(?:[^"\\]|\\.)*"))?`)
+	triggerPattern  = regexp.MustCompile(`(?m)^(?:Message|Task):\s+(.*)$|^Your user's request:\n(.*)$`)
+)
+
+func parsePrompt(prompt string) spawnPrompt {
+	var p spawnPrompt
+	if m := selfPattern.FindStringSubmatch(prompt); m != nil {
+		p.self, _ = strconv.Unquote(m[1])
+	}
+	if m := conversePattern.FindStringSubmatch(prompt); m != nil {
+		p.to, _ = strconv.Unquote(m[1])
+		if m[2] != "" {
+			p.convID, _ = strconv.Unquote(m[2])
+		}
+	}
+	if m := triggerPattern.FindStringSubmatch(prompt); m != nil {
+		p.trigger = strings.TrimSpace(m[1] + m[2])
+	}
+	p.mention = strings.Contains(prompt, "YOU WERE MENTIONED")
+	return p
+}
+
+// plan picks the tool call that answers p: converse on a directed message or
+// help request, a broadcast for a mention, and otherwise a whisper to the
+// owner, as a user-started runner spawn would.
+func plan(p spawnPrompt, behavior string) (string, map[string]any) {
+	text := fmt.Sprintf("[fake] %s got: %s", p.self, shorten(p.trigger, 60))
+	if p.trigger == "" {
+		text = fmt.Sprintf("[fake] %s is here", p.self)
+	}
+	if behavior == Echo && p.trigger != "" {
+		text = p.trigger
+	}
+
+	switch {
+	case p.mention:
+		return "send_message", map[string]any{"text": text, "broadcast": true}
+	case p.to != "":
+		args := map[string]any{"to": p.to, "message": text}
+		if p.convID != "" {
+			args["conv_id"] = p.convID
+		}
+		if behavior != Chat {
+			args["done"] = true
+		}
+		return "converse", args
+	default:
+		return "send_message", map[string]any{"text": text}
+	}
+}
+
+// shorten cuts s to at most n runes, so chatting fakes don't quote each
+// other without end.
+func shorten(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "…"
+	}
+	return s
+}
+
+// mcpConfig is the part of an --mcp-config file the fake reads.
+type mcpConfig struct {
+	MCPServers map[string]struct {
+		Command string            `json:"command"`
+		Args    []string          `json:"args"`
+		Env     map[string]string `json:"env"`
+	} `json:"mcpServers"`
+}
+
+// connect starts the claudetalk MCP server from the config at path, or the
+// only server if it has another name, and initializes a session with it.
+func connect(ctx context.Context, path string) (*mcpclient.Client, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read mcp config: %w", err)
+	}
+	var cfg mcpConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse mcp config: %w", err)
+	}
+	srv, ok := cfg.MCPServers["claudetalk"]
+	if !ok && len(cfg.MCPServers) == 1 {
+		for _, s := range cfg.MCPServers {
+			srv, ok = s, true
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("mcp config %s has no claudetalk server", path)
+	}
+
+	env := make([]string, 0, len(srv.Env))
+	for k, v := range srv.Env {
+		env = append(env, k+"="+v)
+	}
+	c, err := mcpclient.NewStdioMCPClient(srv.Command, env, srv.Args...)
+	if err != nil {
+		return nil, fmt.Errorf("start mcp server: %w", err)
+	}
+	_, err = c.Initialize(ctx, mcp.InitializeRequest{Params: mcp.InitializeParams{
+		ProtocolVersion: mcp.LATEST_PROTOCOL_VERSION,
+		ClientInfo:      mcp.Implementation{Name: "fake-claude", Version: Version},
+	}})
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("initialize mcp server: %w", err)
+	}
+	return c, nil
+}
+
+func resultText(res *mcp.CallToolResult) string {
+	var parts []string
+	for _, c := range res.Content {
+		if t, ok := c.(mcp.TextContent); ok {
+			parts = append(parts, t.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// output writes what the claude CLI would: the final result as plain text,
+// or stream-json events with tool calls as they happen.
+type output struct {
+	w       io.Writer
+	stream  bool
+	session string
+}
+
+func (o *output) event(ev map[string]any) {
+	if o.stream {
+		json.NewEncoder(o.w).Encode(ev)
+	}
+}
+
+func (o *output) toolUse(name string, args map[string]any) {
+	input, _ := json.Marshal(args)
+	o.event(map[string]any{
+		"type":       "assistant",
+		"session_id": o.session,
+		"message": map[string]any{"content": []map[string]any{{
+			"type":  "tool_use",
+			"name":  "mcp__claudetalk__" + name,
+			"input": json.RawMessage(input),
+		}}},
+	})
+}
+
+func (o *output) result(text string) {
+	if !o.stream {
+		fmt.Fprintln(o.w, text)
+		return
+	}
+	o.event(map[string]any{"type": "result", "subtype": "success", "result": text, "session_id": o.session})
+}
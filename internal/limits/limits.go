@@ -0,0 +1,59 @@
+// Package limits applies optional CPU, memory and priority limits to spawned
+// Claude processes by wrapping their command line.
+package limits
+
+import (
+	"fmt"
+	"time"
+)
+
+// Limits constrains a spawned process. Zero fields mean no limit.
+type Limits struct {
+	Nice       int           // scheduling niceness added to the process (1-19 lowers priority)
+	MemoryMB   int           // memory cap in MiB (cgroup MemoryMax; Linux with systemd only)
+	CPUPercent int           // CPU quota, 100 = one full core (cgroup CPUQuota; Linux with systemd only)
+	CPUTime    time.Duration // total CPU time before the process is killed (RLIMIT_CPU)
+}
+
+// IsZero reports whether no limits are set.
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
+
+// Validate reports out-of-range values.
+func (l Limits) Validate() error {
+	if l.Nice < 0 || l.Nice > 19 {
+		return fmt.Errorf("nice must be between 0 and 19")
+	}
+	if l.MemoryMB < 0 || l.CPUPercent < 0 || l.CPUTime < 0 {
+		return fmt.Errorf("resource limits must not be negative")
+	}
+	return nil
+}
+
+// String summarizes the limits for logs.
+func (l Limits) String() string {
+	if l.IsZero() {
+		return "none"
+	}
+	s := ""
+	add := func(part string) {
+		if s != "" {
+			s += ", "
+		}
+		s += part
+	}
+	if l.Nice > 0 {
+		add(fmt.Sprintf("nice %d", l.Nice))
+	}
+	if l.MemoryMB > 0 {
+		add(fmt.Sprintf("memory %dMiB", l.MemoryMB))
+	}
+	if l.CPUPercent > 0 {
+		add(fmt.Sprintf("cpu %d%%", l.CPUPercent))
+	}
+	if l.CPUTime > 0 {
+		add(fmt.Sprintf("cpu time %s", l.CPUTime))
+	}
+	return s
+}
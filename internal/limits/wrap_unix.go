@@ -0,0 +1,77 @@
+//go:build !windows
+
+package limits
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+var (
+	warnOnce sync.Once
+
+	scopeOnce sync.Once
+	scopeOK   bool
+)
+
+// canScope reports whether transient systemd scopes work here. systemd-run
+// may be installed without a usable user bus (containers, SSH sessions
+// without lingering), so it is tried once rather than just looked up.
+func canScope() bool {
+	scopeOnce.Do(func() {
+		if runtime.GOOS != "linux" {
+			return
+		}
+		if _, err := exec.LookPath("systemd-run"); err != nil {
+			return
+		}
+		scopeOK = exec.Command("systemd-run", "--user", "--scope", "--quiet", "true").Run() == nil
+	})
+	return scopeOK
+}
+
+// Wrap returns the command line that runs bin with args under l. Limits the
+// platform can't enforce are logged once and skipped.
+//
+// The wrappers all exec their target, so the returned process is still the
+// one that receives signals:
+//
+//	systemd-run --user --scope -p MemoryMax=… -p CPUQuota=… -- nice -n N sh -c 'ulimit -t S; exec "$@"' sh bin args…
+func Wrap(l Limits, bin string, args []string) (string, []string) {
+	if l.IsZero() {
+		return bin, args
+	}
+	cmd := append([]string{bin}, args...)
+
+	if l.CPUTime > 0 {
+		secs := int(l.CPUTime.Seconds())
+		if secs < 1 {
+			secs = 1
+		}
+		cmd = append([]string{"sh", "-c", fmt.Sprintf(`ulimit -t %d; exec "$@"`, secs), "sh"}, cmd...)
+	}
+	if l.Nice > 0 {
+		cmd = append([]string{"nice", "-n", strconv.Itoa(l.Nice)}, cmd...)
+	}
+	if l.MemoryMB > 0 || l.CPUPercent > 0 {
+		if canScope() {
+			scope := []string{"systemd-run", "--user", "--scope", "--quiet"}
+			if l.MemoryMB > 0 {
+				scope = append(scope, "-p", fmt.Sprintf("MemoryMax=%dM", l.MemoryMB))
+			}
+			if l.CPUPercent > 0 {
+				scope = append(scope, "-p", fmt.Sprintf("CPUQuota=%d%%", l.CPUPercent))
+			}
+			cmd = append(append(scope, "--"), cmd...)
+		} else {
+			warnOnce.Do(func() {
+				log.Printf("limits: memory and CPU quotas need a working systemd user session on Linux; not enforcing them")
+			})
+		}
+	}
+	return cmd[0], cmd[1:]
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+package limits
+
+import (
+	"log"
+	"sync"
+)
+
+var warnOnce sync.Once
+
+// Wrap returns bin and args unchanged: resource limits aren't supported on
+// Windows yet. A warning is logged once if any are configured.
+func Wrap(l Limits, bin string, args []string) (string, []string) {
+	if !l.IsZero() {
+		warnOnce.Do(func() {
+			log.Printf("limits: resource limits are not supported on Windows; ignoring %s", l)
+		})
+	}
+	return bin, args
+}
@@ -0,0 +1,143 @@
+package mcp
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// nameCandidate is a name find_participant can suggest, with what we know of it.
+type nameCandidate struct {
+	Name      string
+	Role      string // "" for names only seen as recent senders
+	Connected bool
+	Score     float64
+}
+
+// minNameScore is the lowest score find_participant reports as a match.
+const minNameScore = 0.6
+
+// nameWords splits a name or query into lowercase words, dropping
+// possessives and filler so "the backend Claude" and "backend's Claude" compare
+// equal.
+func nameWords(s string) []string {
+	s = strings.NewReplacer("’s", "", "'s", "").Replace(strings.ToLower(s))
+	var words []string
+	for _, w := range strings.FieldsFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		switch w {
+		case "the", "a", "an", "s":
+			continue
+		}
+		words = append(words, w)
+	}
+	return words
+}
+
+// similarity is 1 minus the edit distance between a and b over the longer
+// length. Swapping two adjacent letters counts as one edit.
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return 1 - float64(d[len(ra)][len(rb)])/float64(max(len(ra), len(rb)))
+}
+
+// splitClaude removes the word "claude" from words and reports whether it
+// was there. Half the room is someone's Claude, so the word says little about
+// who; it only separates "bob" from "bob's Claude".
+func splitClaude(words []string) ([]string, bool) {
+	var rest []string
+	found := false
+	for _, w := range words {
+		if w == "claude" {
+			found = true
+		} else {
+			rest = append(rest, w)
+		}
+	}
+	return rest, found
+}
+
+// nameScore rates how well query describes name, from 0 to 1. It takes the
+// better of comparing the whole names and matching each query word to its
+// closest word in name, so both typos ("kruz") and descriptions ("the
+// backend claude") resolve.
+func nameScore(query, name string) float64 {
+	if strings.EqualFold(strings.TrimSpace(query), name) {
+		return 1
+	}
+	qw, qClaude := splitClaude(nameWords(query))
+	nw, nClaude := splitClaude(nameWords(name))
+	if len(qw) == 0 || len(nw) == 0 {
+		// "claude" alone, or a name with nothing else in it.
+		if qClaude && nClaude && len(qw) == len(nw) {
+			return minNameScore
+		}
+		return 0
+	}
+	whole := similarity(strings.Join(qw, " "), strings.Join(nw, " "))
+
+	var total float64
+	for _, q := range qw {
+		best := 0.0
+		for _, n := range nw {
+			s := similarity(q, n)
+			if len(q) >= 3 && strings.HasPrefix(n, q) {
+				s = max(s, 0.9)
+			}
+			best = max(best, s)
+		}
+		total += best
+	}
+	words := total / float64(len(qw))
+	// A name with words the query never mentions is a weaker match.
+	if extra := len(nw) - len(qw); extra > 0 {
+		words -= 0.1 * float64(extra)
+	}
+
+	score := max(whole, words)
+	if qClaude != nClaude {
+		score -= 0.1
+	}
+	return min(score, 0.99)
+}
+
+// rankNames scores every candidate against query and returns those at or
+// above minNameScore, best first.
+func rankNames(query string, candidates []nameCandidate) []nameCandidate {
+	var out []nameCandidate
+	for _, c := range candidates {
+		c.Score = nameScore(query, c.Name)
+		if c.Score >= minNameScore {
+			out = append(out, c)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].Connected && !out[j].Connected
+	})
+	return out
+}
@@ -10,26 +10,36 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/corvino/claudetalk/internal/e2e"
 	"github.com/corvino/claudetalk/internal/protocol"
 )
 
 // HTTPClient talks to the ClaudeTalk central server REST API.
 type HTTPClient struct {
 	BaseURL string
-	Room    string
 	Sender  string
 	client  *http.Client
+
+	mu   sync.RWMutex
+	room string // current room; switch_room changes it for the session
+
+	outbox  *outbox    // sends that failed; see FlushOutbox
+	flushMu sync.Mutex // one flush at a time keeps the outbox in order
+
+	keys *e2e.Keyring // seals sent and opens received messages; nil = E2E off
 }
 
 // NewHTTPClient creates a new HTTP client for the MCP tools.
 func NewHTTPClient(baseURL, room, sender string) *HTTPClient {
 	return &HTTPClient{
 		BaseURL: strings.TrimRight(baseURL, "/"),
-		Room:    room,
+		room:    room,
 		Sender:  sender,
-		client:  &http.Client{Timeout: 30 * time.Second},
+		client:  &http.Client{Timeout: 30 * time.Second, Transport: protocol.AuthTransport{}},
+		outbox:  newOutbox(strings.TrimRight(baseURL, "/"), sender),
 	}
 }
 
@@ -37,47 +47,158 @@ func (c *HTTPClient) url(path string) string {
 	return c.BaseURL + path
 }
 
-// SendMessage posts a message to the room.
+// Room returns the room tools currently act on.
+func (c *HTTPClient) Room() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.room
+}
+
+// SetRoom points later tool calls at room.
+func (c *HTTPClient) SetRoom(room string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.room = room
+}
+
+// InRoom returns a client for room that shares c's connection pool, for
+// reading another room without switching the session's current one.
+func (c *HTTPClient) InRoom(room string) *HTTPClient {
+	return &HTTPClient{BaseURL: c.BaseURL, Sender: c.Sender, client: c.client, room: room, outbox: c.outbox, keys: c.keys}
+}
+
+// ListRooms lists the server's active rooms.
+func (c *HTTPClient) ListRooms() (*protocol.RoomList, error) {
+	resp, err := c.client.Get(c.url("/api/rooms"))
+	if err != nil {
+		return nil, fmt.Errorf("GET: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("server returned %d: %s", resp.StatusCode, string(b))
+	}
+
+	var list protocol.RoomList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	return &list, nil
+}
+
+// SendMessage posts a text message to the room.
 func (c *HTTPClient) SendMessage(text, msgType string, metadata map[string]string) (*protocol.Envelope, error) {
 	if msgType == "" {
 		msgType = protocol.TypeText
 	}
-	req := protocol.SendRequest{
-		Sender:   c.Sender,
-		Type:     msgType,
-		Payload:  protocol.NewTextPayload(text),
-		Metadata: metadata,
+	return c.SendPayload(msgType, protocol.NewTextPayload(text), metadata)
+}
+
+// SendPayload posts a message with a structured payload (code, diff) to the
+// room. See Send.
+func (c *HTTPClient) SendPayload(msgType string, payload protocol.Payload, metadata map[string]string) (*protocol.Envelope, error) {
+	return c.Send(protocol.SendRequest{Type: msgType, Payload: payload, Metadata: metadata})
+}
+
+// Send posts req to the room as this client's sender. Transient failures are
+// retried with backoff; if the server still can't be reached the message
+// goes to the outbox and the error wraps ErrQueued. While older messages are
+// queued, new ones queue behind them so they arrive in order.
+func (c *HTTPClient) Send(req protocol.SendRequest) (*protocol.Envelope, error) {
+	req.Sender = c.Sender
+	room := c.Room()
+	if err := c.keys.Seal(room, &req); err != nil {
+		return nil, err
+	}
+
+	if c.outbox.Len() > 0 {
+		c.FlushOutbox()
+		if c.outbox.Len() > 0 {
+			return nil, queuedError(c.outbox.Add(room, req), fmt.Errorf("earlier messages are still queued"))
+		}
 	}
+
+	var (
+		env   *protocol.Envelope
+		retry bool
+		err   error
+	)
+	delay := sendBackoff
+	for attempt := 1; ; attempt++ {
+		env, retry, err = c.post(room, req)
+		if err == nil || !retry || attempt == sendAttempts {
+			break
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+	if err != nil && retry {
+		return nil, queuedError(c.outbox.Add(room, req), err)
+	}
+	return env, err
+}
+
+// post sends req to room once. retry reports whether the failure was the
+// connection or the server rather than the request itself.
+func (c *HTTPClient) post(room string, req protocol.SendRequest) (env *protocol.Envelope, retry bool, err error) {
 	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("marshal: %w", err)
+	}
+
+	resp, err := c.client.Post(c.url(fmt.Sprintf("/api/rooms/%s/messages", room)), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, true, fmt.Errorf("POST: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		b, _ := io.ReadAll(resp.Body)
+		retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return nil, retry, fmt.Errorf("server returned %d: %s", resp.StatusCode, string(b))
+	}
+
+	env = new(protocol.Envelope)
+	if err := json.NewDecoder(resp.Body).Decode(env); err != nil {
+		return nil, false, fmt.Errorf("decode: %w", err)
+	}
+	c.keys.Open(env)
+	return env, false, nil
+}
+
+// RequestHelp asks the server to spawn to's Claude with task.
+func (c *HTTPClient) RequestHelp(to, task, convID string) (*protocol.HelpResponse, error) {
+	body, err := json.Marshal(protocol.HelpRequest{Sender: c.Sender, To: to, Task: task, ConvID: convID})
 	if err != nil {
 		return nil, fmt.Errorf("marshal: %w", err)
 	}
 
-	resp, err := c.client.Post(c.url(fmt.Sprintf("/api/rooms/%s/messages", c.Room)), "application/json", bytes.NewReader(body))
+	resp, err := c.client.Post(c.url(fmt.Sprintf("/api/rooms/%s/help", c.Room())), "application/json", bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("POST: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusCreated {
+	if resp.StatusCode != http.StatusAccepted {
 		b, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("server returned %d: %s", resp.StatusCode, string(b))
 	}
 
-	var env protocol.Envelope
-	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+	var hr protocol.HelpResponse
+	if err := json.NewDecoder(resp.Body).Decode(&hr); err != nil {
 		return nil, fmt.Errorf("decode: %w", err)
 	}
-	return &env, nil
+	return &hr, nil
 }
 
 // GetMessages fetches messages from the room.
 func (c *HTTPClient) GetMessages(latest int, after int64) (*protocol.MessageList, error) {
 	var u string
 	if latest > 0 {
-		u = c.url(fmt.Sprintf("/api/rooms/%s/messages/latest?n=%d", c.Room, latest))
+		u = c.url(fmt.Sprintf("/api/rooms/%s/messages/latest?n=%d", c.Room(), latest))
 	} else {
-		u = c.url(fmt.Sprintf("/api/rooms/%s/messages?after=%d&limit=100", c.Room, after))
+		u = c.url(fmt.Sprintf("/api/rooms/%s/messages?after=%d&limit=100", c.Room(), after))
 	}
 
 	resp, err := c.client.Get(u)
@@ -95,9 +216,32 @@ func (c *HTTPClient) GetMessages(latest int, after int64) (*protocol.MessageList
 	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
 		return nil, fmt.Errorf("decode: %w", err)
 	}
+	c.keys.OpenAll(list.Messages)
 	return &list, nil
 }
 
+// GetMessage fetches the message with seq number seq, with its whole
+// payload.
+func (c *HTTPClient) GetMessage(seq int64) (*protocol.Envelope, error) {
+	resp, err := c.client.Get(c.url(fmt.Sprintf("/api/rooms/%s/messages/%d", c.Room(), seq)))
+	if err != nil {
+		return nil, fmt.Errorf("GET: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("server returned %d: %s", resp.StatusCode, string(b))
+	}
+
+	var env protocol.Envelope
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	c.keys.Open(&env)
+	return &env, nil
+}
+
 // UploadFile uploads a file to the room.
 func (c *HTTPClient) UploadFile(filePath, description string) (*protocol.FileInfo, error) {
 	f, err := os.Open(filePath)
@@ -123,7 +267,7 @@ func (c *HTTPClient) UploadFile(filePath, description string) (*protocol.FileInf
 	}
 	w.Close()
 
-	resp, err := c.client.Post(c.url(fmt.Sprintf("/api/rooms/%s/files", c.Room)), w.FormDataContentType(), &buf)
+	resp, err := c.client.Post(c.url(fmt.Sprintf("/api/rooms/%s/files", c.Room())), w.FormDataContentType(), &buf)
 	if err != nil {
 		return nil, fmt.Errorf("POST: %w", err)
 	}
@@ -143,7 +287,7 @@ func (c *HTTPClient) UploadFile(filePath, description string) (*protocol.FileInf
 
 // DownloadFile downloads a file from the room and saves it to savePath.
 func (c *HTTPClient) DownloadFile(fileID, savePath string) error {
-	resp, err := c.client.Get(c.url(fmt.Sprintf("/api/rooms/%s/files/%s", c.Room, fileID)))
+	resp, err := c.client.Get(c.url(fmt.Sprintf("/api/rooms/%s/files/%s", c.Room(), fileID)))
 	if err != nil {
 		return fmt.Errorf("GET: %w", err)
 	}
@@ -166,9 +310,33 @@ func (c *HTTPClient) DownloadFile(fileID, savePath string) error {
 	return nil
 }
 
+// ReadFile fetches up to maxBytes of a shared file. truncated reports whether
+// the file is longer.
+func (c *HTTPClient) ReadFile(fileID string, maxBytes int) (data []byte, contentType string, truncated bool, err error) {
+	resp, err := c.client.Get(c.url(fmt.Sprintf("/api/rooms/%s/files/%s", c.Room(), fileID)))
+	if err != nil {
+		return nil, "", false, fmt.Errorf("GET: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return nil, "", false, fmt.Errorf("server returned %d: %s", resp.StatusCode, string(b))
+	}
+
+	data, err = io.ReadAll(io.LimitReader(resp.Body, int64(maxBytes)+1))
+	if err != nil {
+		return nil, "", false, fmt.Errorf("read file: %w", err)
+	}
+	if len(data) > maxBytes {
+		data, truncated = data[:maxBytes], true
+	}
+	return data, resp.Header.Get("Content-Type"), truncated, nil
+}
+
 // ListFiles lists all files in the room.
 func (c *HTTPClient) ListFiles() (*protocol.FileList, error) {
-	resp, err := c.client.Get(c.url(fmt.Sprintf("/api/rooms/%s/files", c.Room)))
+	resp, err := c.client.Get(c.url(fmt.Sprintf("/api/rooms/%s/files", c.Room())))
 	if err != nil {
 		return nil, fmt.Errorf("GET: %w", err)
 	}
@@ -188,7 +356,7 @@ func (c *HTTPClient) ListFiles() (*protocol.FileList, error) {
 
 // ListParticipants lists all participants in the room.
 func (c *HTTPClient) ListParticipants() (*protocol.ParticipantList, error) {
-	resp, err := c.client.Get(c.url(fmt.Sprintf("/api/rooms/%s/participants", c.Room)))
+	resp, err := c.client.Get(c.url(fmt.Sprintf("/api/rooms/%s/participants", c.Room())))
 	if err != nil {
 		return nil, fmt.Errorf("GET: %w", err)
 	}
@@ -205,3 +373,23 @@ func (c *HTTPClient) ListParticipants() (*protocol.ParticipantList, error) {
 	}
 	return &list, nil
 }
+
+// GetCharter fetches the room's charter.
+func (c *HTTPClient) GetCharter() (*protocol.Charter, error) {
+	resp, err := c.client.Get(c.url(fmt.Sprintf("/api/rooms/%s/charter", c.Room())))
+	if err != nil {
+		return nil, fmt.Errorf("GET: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("server returned %d: %s", resp.StatusCode, string(b))
+	}
+
+	var charter protocol.Charter
+	if err := json.NewDecoder(resp.Body).Decode(&charter); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	return &charter, nil
+}
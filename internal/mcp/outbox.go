@@ -0,0 +1,168 @@
+package mcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// ErrQueued is wrapped by send errors when the message could not be
+// delivered but was saved to the outbox instead of being lost.
+var ErrQueued = errors.New("queued for delivery when the server is reachable")
+
+const (
+	sendAttempts   = 3
+	sendBackoff    = 500 * time.Millisecond // doubles after each failed attempt
+	outboxInterval = 15 * time.Second
+)
+
+// queuedSend is one undelivered message.
+type queuedSend struct {
+	Room     string               `json:"room"`
+	Request  protocol.SendRequest `json:"request"`
+	QueuedAt time.Time            `json:"queued_at"`
+}
+
+// outbox holds messages that failed to send, in order. It is saved to disk
+// so a Claude session that ends while the server is unreachable still gets
+// its replies delivered by the next mcp-serve for the same name.
+type outbox struct {
+	mu    sync.Mutex
+	path  string // "" = memory only
+	items []queuedSend
+}
+
+// newOutbox loads the outbox for sender on baseURL, if one was left behind.
+func newOutbox(baseURL, sender string) *outbox {
+	o := &outbox{}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return o
+	}
+	sum := sha256.Sum256([]byte(baseURL + "\x00" + sender))
+	o.path = filepath.Join(dir, "claudetalk", "outbox", hex.EncodeToString(sum[:8])+".json")
+	if data, err := os.ReadFile(o.path); err == nil {
+		if err := json.Unmarshal(data, &o.items); err != nil {
+			log.Printf("outbox: ignoring unreadable %s: %v", o.path, err)
+			o.items = nil
+		}
+	}
+	return o
+}
+
+// Len returns the number of queued messages.
+func (o *outbox) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.items)
+}
+
+// Add queues a message and returns how many are now pending.
+func (o *outbox) Add(room string, req protocol.SendRequest) int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.items = append(o.items, queuedSend{Room: room, Request: req, QueuedAt: time.Now()})
+	o.save()
+	return len(o.items)
+}
+
+// Peek returns the oldest queued message.
+func (o *outbox) Peek() (queuedSend, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.items) == 0 {
+		return queuedSend{}, false
+	}
+	return o.items[0], true
+}
+
+// Pop removes the oldest queued message.
+func (o *outbox) Pop() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.items) > 0 {
+		o.items = o.items[1:]
+		o.save()
+	}
+}
+
+// save writes the queue to disk, or removes the file once it is empty.
+// Callers hold o.mu.
+func (o *outbox) save() {
+	if o.path == "" {
+		return
+	}
+	if len(o.items) == 0 {
+		os.Remove(o.path)
+		return
+	}
+	data, err := json.Marshal(o.items)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(o.path), 0o700)
+	}
+	if err == nil {
+		err = os.WriteFile(o.path, data, 0o600)
+	}
+	if err != nil {
+		log.Printf("outbox: save: %v", err)
+	}
+}
+
+// FlushOutbox sends queued messages in order, stopping at the first one the
+// server still can't take. Messages the server rejects outright are dropped.
+// It returns how many were delivered.
+func (c *HTTPClient) FlushOutbox() int {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	sent := 0
+	for {
+		item, ok := c.outbox.Peek()
+		if !ok {
+			return sent
+		}
+		_, retry, err := c.post(item.Room, item.Request)
+		if err != nil && retry {
+			return sent
+		}
+		if err != nil {
+			log.Printf("outbox: dropping message queued at %s: %v", item.QueuedAt.Format(time.RFC3339), err)
+		} else {
+			sent++
+		}
+		c.outbox.Pop()
+	}
+}
+
+// RunOutbox retries queued messages until ctx is done.
+func (c *HTTPClient) RunOutbox(ctx context.Context) {
+	ticker := time.NewTicker(outboxInterval)
+	defer ticker.Stop()
+	for {
+		if c.outbox.Len() > 0 {
+			if n := c.FlushOutbox(); n > 0 {
+				log.Printf("outbox: delivered %d queued message(s)", n)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// queuedError reports that a send failed with cause and was queued.
+func queuedError(pending int, cause error) error {
+	return fmt.Errorf("%w (%d pending): %v", ErrQueued, pending, cause)
+}
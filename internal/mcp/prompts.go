@@ -0,0 +1,185 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+	mcplib "github.com/mark3labs/mcp-go/mcp"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+)
+
+// promptHistory is how many recent messages prompts search for a thread, a
+// diff or a standup window.
+const promptHistory = 500
+
+// RegisterPrompts adds canned multi-agent workflows to the MCP server. Each
+// prompt fetches what it needs from the current room and embeds it, so the
+// user picks a prompt instead of asking Claude to go look.
+func RegisterPrompts(srv *mcpserver.MCPServer, client *HTTPClient) {
+	srv.AddPrompt(mcplib.NewPrompt("reply-to-thread",
+		mcplib.WithPromptDescription("Read a conversation thread and reply in it"),
+		mcplib.WithArgument("conv_id", mcplib.RequiredArgument(), mcplib.ArgumentDescription("Conversation ID, or the short form get_messages shows")),
+	), makeReplyToThreadPrompt(client))
+
+	srv.AddPrompt(mcplib.NewPrompt("review-shared-diff",
+		mcplib.WithPromptDescription("Review a diff someone shared and post the review"),
+		mcplib.WithArgument("file_id", mcplib.ArgumentDescription("ID of a shared .diff/.patch file")),
+		mcplib.WithArgument("seq", mcplib.ArgumentDescription("Sequence number of a diff message (default: the latest diff)")),
+	), makeReviewDiffPrompt(client))
+
+	srv.AddPrompt(mcplib.NewPrompt("standup-summary",
+		mcplib.WithPromptDescription("Summarize what everyone in the room has been doing"),
+		mcplib.WithArgument("hours", mcplib.ArgumentDescription("How far back to look (default: 24)")),
+		mcplib.WithArgument("post", mcplib.ArgumentDescription("Set to \"yes\" to broadcast the summary to the room")),
+	), makeStandupPrompt(client))
+}
+
+func promptResult(desc, text string) *mcplib.GetPromptResult {
+	return mcplib.NewGetPromptResult(desc, []mcplib.PromptMessage{
+		mcplib.NewPromptMessage(mcplib.RoleUser, mcplib.NewTextContent(text)),
+	})
+}
+
+func makeReplyToThreadPrompt(client *HTTPClient) mcpserver.PromptHandlerFunc {
+	return func(ctx context.Context, request mcplib.GetPromptRequest) (*mcplib.GetPromptResult, error) {
+		convID := strings.TrimSpace(request.Params.Arguments["conv_id"])
+		if convID == "" {
+			return nil, fmt.Errorf("conv_id is required")
+		}
+
+		list, err := client.GetMessages(promptHistory, 0)
+		if err != nil {
+			return nil, fmt.Errorf("get messages: %w", err)
+		}
+		var thread []protocol.Envelope
+		fullID := ""
+		for _, env := range list.Messages {
+			if id := env.Metadata["conv_id"]; id != "" && strings.HasPrefix(id, convID) {
+				thread = append(thread, env)
+				fullID = id
+			}
+		}
+		if len(thread) == 0 {
+			return nil, fmt.Errorf("no messages in conversation %s in the last %d", convID, promptHistory)
+		}
+
+		// Reply to whoever spoke last that isn't us.
+		to := ""
+		for i := len(thread) - 1; i >= 0 && to == ""; i-- {
+			if thread[i].Sender != client.Sender {
+				to = thread[i].Sender
+			}
+		}
+
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "Here is conversation %s from room %s:\n\n%s\n", fullID, client.Room(), formatMessages(thread))
+		if to != "" {
+			fmt.Fprintf(&sb, "Reply to %s with the converse tool, passing conv_id %q so the reply stays in this thread.", to, fullID)
+		} else {
+			fmt.Fprintf(&sb, "Continue the thread with the converse tool, passing conv_id %q.", fullID)
+		}
+		sb.WriteString(" Read any files or code it refers to first. Set done=true if the thread needs no further reply.")
+		return promptResult("Reply to conversation "+fullID, sb.String()), nil
+	}
+}
+
+func makeReviewDiffPrompt(client *HTTPClient) mcpserver.PromptHandlerFunc {
+	return func(ctx context.Context, request mcplib.GetPromptRequest) (*mcplib.GetPromptResult, error) {
+		fileID := strings.TrimSpace(request.Params.Arguments["file_id"])
+		seqArg := strings.TrimSpace(request.Params.Arguments["seq"])
+
+		var diff, source, author string
+		if fileID != "" {
+			data, _, truncated, err := client.ReadFile(fileID, defaultReadBytes)
+			if err != nil {
+				return nil, fmt.Errorf("read file %s: %w", fileID, err)
+			}
+			text, ok := asText(data, truncated)
+			if !ok {
+				return nil, fmt.Errorf("file %s is not a text diff", fileID)
+			}
+			if truncated {
+				text += "\n[... truncated; use read_file with a larger max_bytes for the rest]"
+			}
+			diff, source = text, "shared file "+fileID
+		} else {
+			var seq int64
+			if seqArg != "" {
+				n, err := strconv.ParseInt(seqArg, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("seq must be a number")
+				}
+				seq = n
+			}
+			list, err := client.GetMessages(promptHistory, 0)
+			if err != nil {
+				return nil, fmt.Errorf("get messages: %w", err)
+			}
+			for i := len(list.Messages) - 1; i >= 0; i-- {
+				env := list.Messages[i]
+				if env.Type == protocol.TypeDiff && (seq == 0 || env.SeqNum == seq) {
+					diff, author = env.Payload.Diff, env.Sender
+					source = fmt.Sprintf("message #%d from %s%s", env.SeqNum, env.Sender, payloadLabel(env.Payload))
+					break
+				}
+			}
+			if diff == "" {
+				if seq != 0 {
+					return nil, fmt.Errorf("message #%d is not a recent diff", seq)
+				}
+				return nil, fmt.Errorf("no diff shared in the last %d messages", promptHistory)
+			}
+		}
+
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "Review this diff (%s in room %s):\n\n```diff\n%s\n```\n\n", source, client.Room(), strings.TrimRight(diff, "\n"))
+		sb.WriteString("Look for bugs, missing error handling, and anything inconsistent with the surrounding code; read the files it touches in this repo if you have them. ")
+		if author != "" {
+			fmt.Fprintf(&sb, "Send the review to %s with the converse tool, leading with anything that blocks merging.", author)
+		} else {
+			sb.WriteString("Post the review with send_message (broadcast=true), leading with anything that blocks merging.")
+		}
+		return promptResult("Review "+source, sb.String()), nil
+	}
+}
+
+func makeStandupPrompt(client *HTTPClient) mcpserver.PromptHandlerFunc {
+	return func(ctx context.Context, request mcplib.GetPromptRequest) (*mcplib.GetPromptResult, error) {
+		hours := 24.0
+		if v := strings.TrimSpace(request.Params.Arguments["hours"]); v != "" {
+			h, err := strconv.ParseFloat(v, 64)
+			if err != nil || h <= 0 {
+				return nil, fmt.Errorf("hours must be a positive number")
+			}
+			hours = h
+		}
+		since := time.Now().Add(-time.Duration(hours * float64(time.Hour)))
+
+		list, err := client.GetMessages(promptHistory, 0)
+		if err != nil {
+			return nil, fmt.Errorf("get messages: %w", err)
+		}
+		var recent []protocol.Envelope
+		for _, env := range list.Messages {
+			if env.Timestamp.After(since) {
+				recent = append(recent, env)
+			}
+		}
+
+		var sb strings.Builder
+		if len(recent) == 0 {
+			fmt.Fprintf(&sb, "Nothing was said in room %s in the last %g hours. Say so briefly.", client.Room(), hours)
+			return promptResult("Standup summary", sb.String()), nil
+		}
+		fmt.Fprintf(&sb, "Here is room %s over the last %g hours:\n\n%s\n", client.Room(), hours, formatMessages(recent))
+		sb.WriteString("Write a standup summary: for each participant, what they did, what they are doing next, and anything they are blocked on. Then list open questions nobody answered. Keep it short.")
+		if strings.EqualFold(strings.TrimSpace(request.Params.Arguments["post"]), "yes") {
+			sb.WriteString(" Broadcast it to the room with send_message (broadcast=true).")
+		}
+		return promptResult("Standup summary", sb.String()), nil
+	}
+}
@@ -0,0 +1,290 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	mcplib "github.com/mark3labs/mcp-go/mcp"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+)
+
+// Resource URIs. Every resource names its room, so a client can attach
+// context from any room regardless of switch_room.
+const (
+	resourcePrefix       = "claudetalk://room/"
+	messagesTemplate     = resourcePrefix + "{room}/messages"
+	participantsTemplate = resourcePrefix + "{room}/participants"
+	filesTemplate        = resourcePrefix + "{room}/files"
+	fileTemplate         = resourcePrefix + "{room}/files/{id}"
+)
+
+const (
+	resourceMessages  = 50      // messages in a room's messages resource
+	maxResourceBytes  = 1 << 20 // largest shared file served as a resource
+	subscriptionPoll  = 3 * time.Second
+	resourceTextMIME  = "text/plain"
+	resourceNotExists = "no such resource"
+)
+
+// RegisterResources exposes room history, participants and shared files as
+// MCP resources. The session's starting room is listed concretely; templates
+// cover every other room and each shared file.
+func RegisterResources(srv *mcpserver.MCPServer, client *HTTPClient) {
+	read := makeResourceHandler(client)
+	tmplRead := mcpserver.ResourceTemplateHandlerFunc(read)
+	room := client.Room()
+
+	for _, r := range []struct{ kind, desc string }{
+		{"messages", fmt.Sprintf("The last %d messages in %s", resourceMessages, room)},
+		{"participants", fmt.Sprintf("Who is in %s, with daemon health", room)},
+		{"files", fmt.Sprintf("Files shared in %s", room)},
+	} {
+		srv.AddResource(mcplib.NewResource(
+			resourcePrefix+room+"/"+r.kind,
+			fmt.Sprintf("%s %s", room, r.kind),
+			mcplib.WithResourceDescription(r.desc),
+			mcplib.WithMIMEType(resourceTextMIME),
+		), read)
+	}
+
+	srv.AddResourceTemplate(mcplib.NewResourceTemplate(messagesTemplate, "Room messages",
+		mcplib.WithTemplateDescription(fmt.Sprintf("The last %d messages in a room", resourceMessages)),
+		mcplib.WithTemplateMIMEType(resourceTextMIME),
+	), tmplRead)
+	srv.AddResourceTemplate(mcplib.NewResourceTemplate(participantsTemplate, "Room participants",
+		mcplib.WithTemplateDescription("Who is in a room, with daemon health"),
+		mcplib.WithTemplateMIMEType(resourceTextMIME),
+	), tmplRead)
+	srv.AddResourceTemplate(mcplib.NewResourceTemplate(filesTemplate, "Room files",
+		mcplib.WithTemplateDescription("Files shared in a room"),
+		mcplib.WithTemplateMIMEType(resourceTextMIME),
+	), tmplRead)
+	srv.AddResourceTemplate(mcplib.NewResourceTemplate(fileTemplate, "Shared file",
+		mcplib.WithTemplateDescription("The contents of a shared file (see the files resource for IDs)"),
+	), tmplRead)
+}
+
+// parseResourceURI splits claudetalk://room/{room}/{kind}[/{id}].
+func parseResourceURI(uri string) (room, kind, id string, err error) {
+	rest, ok := strings.CutPrefix(uri, resourcePrefix)
+	if !ok {
+		return "", "", "", fmt.Errorf("%s: %s", resourceNotExists, uri)
+	}
+	parts := strings.Split(rest, "/")
+	switch {
+	case len(parts) == 2 && parts[0] != "":
+		room, kind = parts[0], parts[1]
+	case len(parts) == 3 && parts[0] != "" && parts[1] == "files" && parts[2] != "":
+		room, kind, id = parts[0], "file", parts[2]
+	default:
+		return "", "", "", fmt.Errorf("%s: %s", resourceNotExists, uri)
+	}
+	switch kind {
+	case "messages", "participants", "files", "file":
+		return room, kind, id, nil
+	}
+	return "", "", "", fmt.Errorf("%s: %s", resourceNotExists, uri)
+}
+
+func makeResourceHandler(client *HTTPClient) mcpserver.ResourceHandlerFunc {
+	return func(ctx context.Context, request mcplib.ReadResourceRequest) ([]mcplib.ResourceContents, error) {
+		uri := request.Params.URI
+		if kind, text, err := readListing(client, uri); kind != "file" {
+			if err != nil {
+				return nil, err
+			}
+			return []mcplib.ResourceContents{mcplib.TextResourceContents{URI: uri, MIMEType: resourceTextMIME, Text: text}}, nil
+		}
+
+		room, _, id, _ := parseResourceURI(uri)
+		data, contentType, truncated, err := client.InRoom(room).ReadFile(id, maxResourceBytes)
+		if err != nil {
+			return nil, fmt.Errorf("read file %s: %w", id, err)
+		}
+		if truncated {
+			return nil, fmt.Errorf("file %s is over %d bytes; use get_file to save it", id, maxResourceBytes)
+		}
+		if text, ok := asText(data, false); ok {
+			return []mcplib.ResourceContents{mcplib.TextResourceContents{URI: uri, MIMEType: contentType, Text: text}}, nil
+		}
+		return []mcplib.ResourceContents{mcplib.BlobResourceContents{URI: uri, MIMEType: contentType, Blob: base64.StdEncoding.EncodeToString(data)}}, nil
+	}
+}
+
+// readListing renders a messages, participants or files resource. For a
+// single shared file it only reports kind "file"; those are read separately
+// because they may be binary and never change.
+func readListing(client *HTTPClient, uri string) (kind, text string, err error) {
+	room, kind, _, err := parseResourceURI(uri)
+	if err != nil || kind == "file" {
+		return kind, "", err
+	}
+	rc := client.InRoom(room)
+
+	switch kind {
+	case "messages":
+		list, err := rc.GetMessages(resourceMessages, 0)
+		if err != nil {
+			return kind, "", fmt.Errorf("get messages: %w", err)
+		}
+		if len(list.Messages) == 0 {
+			return kind, "No messages found.", nil
+		}
+		return kind, formatMessages(list.Messages), nil
+	case "participants":
+		list, err := rc.ListParticipants()
+		if err != nil {
+			return kind, "", fmt.Errorf("list participants: %w", err)
+		}
+		if len(list.Participants) == 0 {
+			return kind, "No participants in this room.", nil
+		}
+		return kind, formatParticipants(list.Participants), nil
+	default:
+		list, err := rc.ListFiles()
+		if err != nil {
+			return kind, "", fmt.Errorf("list files: %w", err)
+		}
+		if len(list.Files) == 0 {
+			return kind, "No files shared in this room.", nil
+		}
+		return kind, formatFiles(list.Files), nil
+	}
+}
+
+// subscriptions implements resources/subscribe on the stdio transport. mcp-go
+// advertises the capability but leaves the methods to the server, so Filter
+// answers them before the stdio server sees them, and Poll re-reads
+// subscribed resources and sends notifications/resources/updated when one
+// changes. SSE requests don't pass through here, so Serve advertises
+// subscriptions on stdio only.
+type subscriptions struct {
+	srv    *mcpserver.MCPServer
+	client *HTTPClient
+
+	mu   sync.Mutex
+	last map[string]string // subscribed URI -> contents when last checked
+}
+
+func newSubscriptions(srv *mcpserver.MCPServer, client *HTTPClient) *subscriptions {
+	return &subscriptions{srv: srv, client: client, last: make(map[string]string)}
+}
+
+// Filter returns stdin minus subscribe/unsubscribe requests, which it answers
+// on out itself. out must be shared with the stdio server so lines don't
+// interleave.
+func (s *subscriptions) Filter(in io.Reader, out io.Writer) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		r := bufio.NewReader(in)
+		for {
+			line, err := r.ReadBytes('\n')
+			if len(line) > 0 && !s.handle(line, out) {
+				if _, werr := pw.Write(line); werr != nil {
+					return
+				}
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+	return pr
+}
+
+// handle answers line if it is a subscription request.
+func (s *subscriptions) handle(line []byte, out io.Writer) bool {
+	var req struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+		Params struct {
+			URI string `json:"uri"`
+		} `json:"params"`
+	}
+	if json.Unmarshal(line, &req) != nil {
+		return false
+	}
+	if req.Method != "resources/subscribe" && req.Method != "resources/unsubscribe" {
+		return false
+	}
+
+	resp := map[string]any{"jsonrpc": mcplib.JSONRPC_VERSION, "id": req.ID, "result": map[string]any{}}
+	if _, _, _, err := parseResourceURI(req.Params.URI); err != nil {
+		delete(resp, "result")
+		resp["error"] = map[string]any{"code": mcplib.INVALID_PARAMS, "message": err.Error()}
+	} else if req.Method == "resources/subscribe" {
+		_, text, _ := readListing(s.client, req.Params.URI)
+		s.mu.Lock()
+		s.last[req.Params.URI] = text
+		s.mu.Unlock()
+	} else {
+		s.mu.Lock()
+		delete(s.last, req.Params.URI)
+		s.mu.Unlock()
+	}
+
+	b, _ := json.Marshal(resp)
+	out.Write(append(b, '\n'))
+	return true
+}
+
+// Poll checks subscribed resources until ctx is done.
+func (s *subscriptions) Poll(ctx context.Context) {
+	ticker := time.NewTicker(subscriptionPoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.check()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *subscriptions) check() {
+	s.mu.Lock()
+	uris := make([]string, 0, len(s.last))
+	for uri := range s.last {
+		uris = append(uris, uri)
+	}
+	s.mu.Unlock()
+
+	for _, uri := range uris {
+		kind, text, err := readListing(s.client, uri)
+		if err != nil || kind == "file" {
+			continue // shared files never change
+		}
+		s.mu.Lock()
+		prev, ok := s.last[uri]
+		changed := ok && prev != text
+		if ok {
+			s.last[uri] = text
+		}
+		s.mu.Unlock()
+		if changed {
+			s.srv.SendNotificationToAllClients(mcplib.MethodNotificationResourceUpdated, map[string]any{"uri": uri})
+		}
+	}
+}
+
+// syncWriter serializes writes so the stdio server's responses and ours
+// stay whole lines.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
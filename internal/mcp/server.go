@@ -2,31 +2,65 @@ package mcp
 
 import (
 	"context"
+	"fmt"
+	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/corvino/claudetalk/internal/e2e"
 	mcpserver "github.com/mark3labs/mcp-go/server"
 )
 
+// Transports for Serve.
+const (
+	TransportStdio = "stdio"
+	TransportSSE   = "sse"
+)
+
 // Config holds the configuration for the MCP server.
 type Config struct {
 	ServerURL string
 	Room      string
 	Name      string
+	Transport string // TransportStdio (default) or TransportSSE
+	Listen    string // address for TransportSSE, e.g. ":9400"
+
+	// Passphrase turns on end-to-end encryption: messages are sealed before
+	// they are sent and opened as they are read. Empty = off.
+	Passphrase string
 }
 
-// Serve starts the MCP stdio server. It blocks until stdin is closed or a signal is received.
+// Serve starts the MCP server on cfg.Transport. It blocks until stdin is
+// closed (stdio) or a signal is received.
 func Serve(cfg Config) error {
+	if cfg.Transport == "" {
+		cfg.Transport = TransportStdio
+	}
+	if cfg.Transport != TransportStdio && cfg.Transport != TransportSSE {
+		return fmt.Errorf("unknown transport %q (want stdio or sse)", cfg.Transport)
+	}
+	if cfg.Transport == TransportSSE && cfg.Listen == "" {
+		return fmt.Errorf("--listen is required for the sse transport")
+	}
+
 	client := NewHTTPClient(cfg.ServerURL, cfg.Room, cfg.Name)
+	client.keys = e2e.NewKeyring(cfg.Passphrase)
 
+	// resources/subscribe is answered in front of the stdio server (see
+	// subscriptions), so only stdio advertises it.
 	srv := mcpserver.NewMCPServer(
 		"claudetalk",
 		"2.0.0",
 		mcpserver.WithToolCapabilities(true),
+		mcpserver.WithResourceCapabilities(cfg.Transport == TransportStdio, false),
+		mcpserver.WithPromptCapabilities(false),
 	)
 
 	RegisterTools(srv, client)
+	RegisterResources(srv, client)
+	RegisterPrompts(srv, client)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -39,6 +73,37 @@ func Serve(cfg Config) error {
 		cancel()
 	}()
 
+	go client.RunOutbox(ctx)
+
+	if cfg.Transport == TransportSSE {
+		return serveSSE(ctx, srv, cfg.Listen)
+	}
+
+	subs := newSubscriptions(srv, client)
+	go subs.Poll(ctx)
+
+	out := &syncWriter{w: os.Stdout}
 	stdioSrv := mcpserver.NewStdioServer(srv)
-	return stdioSrv.Listen(ctx, os.Stdin, os.Stdout)
+	return stdioSrv.Listen(ctx, subs.Filter(os.Stdin, out), out)
+}
+
+// serveSSE serves MCP over HTTP with server-sent events until ctx is done.
+// Every connected client shares one HTTPClient, and so one current room.
+func serveSSE(ctx context.Context, srv *mcpserver.MCPServer, addr string) error {
+	sse := mcpserver.NewSSEServer(srv)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- sse.Start(addr)
+	}()
+	log.Printf("MCP SSE server listening on %s (connect to /sse)", addr)
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return sse.Shutdown(shutdownCtx)
+	}
 }
@@ -1,16 +1,28 @@
 package mcp
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/corvino/claudetalk/internal/sharedir"
 	"github.com/google/uuid"
 	mcplib "github.com/mark3labs/mcp-go/mcp"
 	mcpserver "github.com/mark3labs/mcp-go/server"
 )
 
+// priorityProp is the optional priority argument of the sending tools.
+var priorityProp = propEnum("string", "Optional: low, normal (default) or urgent. Urgent messages jump the recipient's spawn queue and notify them right away; use it sparingly", []string{protocol.PriorityLow, protocol.PriorityNormal, protocol.PriorityUrgent})
+
 // prop is a shorthand for building a JSON Schema property.
 func prop(typ, desc string) any {
 	return map[string]any{
@@ -31,6 +43,15 @@ func propEnum(typ, desc string, enum []string) any {
 	}
 }
 
+// stringArrayProp builds a JSON Schema property for a list of strings.
+func stringArrayProp(desc string) any {
+	return map[string]any{
+		"type":        "array",
+		"description": desc,
+		"items":       map[string]any{"type": "string"},
+	}
+}
+
 // RegisterTools adds all ClaudeTalk tools to the MCP server.
 func RegisterTools(srv *mcpserver.MCPServer, client *HTTPClient) {
 	// 1. send_message
@@ -41,9 +62,11 @@ func RegisterTools(srv *mcpserver.MCPServer, client *HTTPClient) {
 			Type: "object",
 			Properties: map[string]any{
 				"text":      prop("string", "The message text to send"),
-				"type":      propEnum("string", "Message type: text (default), code, or diff", []string{"text", "code", "diff"}),
+				"type":      propEnum("string", "Message type: text (default), code, or diff. Prefer send_code and send_diff, which keep file names and languages", []string{"text", "code", "diff"}),
 				"to":        prop("string", "Optional: specific recipient name for a private whisper. Leave unset to whisper to your owner."),
 				"broadcast": prop("boolean", "Set true to send a public message visible to all room participants"),
+				"priority":  priorityProp,
+				"ttl":       prop("number", "Optional: seconds until the message is purged from history and redacted in UIs. Use it when sharing a token, password or other secret"),
 			},
 			Required: []string{"text"},
 		},
@@ -56,10 +79,11 @@ func RegisterTools(srv *mcpserver.MCPServer, client *HTTPClient) {
 		InputSchema: mcplib.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]any{
-				"to":      prop("string", "Recipient name"),
-				"message": prop("string", "The message text"),
-				"conv_id": prop("string", "Conversation ID (auto-generated if omitted)"),
-				"done":    prop("boolean", "Set true to mark conversation as complete (no reply expected)"),
+				"to":       prop("string", "Recipient name"),
+				"message":  prop("string", "The message text"),
+				"conv_id":  prop("string", "Conversation ID (auto-generated if omitted)"),
+				"done":     prop("boolean", "Set true to mark conversation as complete (no reply expected)"),
+				"priority": priorityProp,
 			},
 			Required: []string{"to", "message"},
 		},
@@ -74,6 +98,7 @@ func RegisterTools(srv *mcpserver.MCPServer, client *HTTPClient) {
 			Properties: map[string]any{
 				"latest": prop("number", "Get the last N messages (default: 20)"),
 				"after":  prop("number", "Get messages after this sequence number"),
+				"seq":    prop("number", "Get just the message with this sequence number, in full, e.g. one your prompt shows truncated"),
 			},
 		},
 	}, makeGetMessagesHandler(client))
@@ -95,13 +120,19 @@ func RegisterTools(srv *mcpserver.MCPServer, client *HTTPClient) {
 	// 5. send_directory
 	srv.AddTool(mcplib.Tool{
 		Name:        "send_directory",
-		Description: "Upload all files in a directory to share with participants. Use this instead of calling send_file repeatedly.",
+		Description: "Upload the files in a directory to share with participants. Use this instead of calling send_file repeatedly. Skips what .gitignore ignores, .git, node_modules and likely secrets (.env, keys, .claudetalk); refuses more than 100 files or 100 MB unless the limits are raised. Try dry_run first to see what would go.",
 		InputSchema: mcplib.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]any{
 				"path":        prop("string", "Local directory path to upload files from"),
 				"recursive":   prop("boolean", "If true, include files in subdirectories (default: false)"),
 				"description": prop("string", "Optional description prefix for each uploaded file"),
+				"include":     stringArrayProp("Only upload files matching one of these .gitignore-style patterns, e.g. [\"*.go\", \"docs/**\"]"),
+				"exclude":     stringArrayProp("Skip files matching these .gitignore-style patterns, on top of .gitignore"),
+				"no_ignore":   prop("boolean", "If true, don't skip what .gitignore ignores or the built-in excludes (.git is always skipped)"),
+				"max_files":   prop("number", "Refuse to upload more files than this (default: 100)"),
+				"max_mb":      prop("number", "Refuse to upload more than this many megabytes in all (default: 100)"),
+				"dry_run":     prop("boolean", "If true, list what would be uploaded without uploading"),
 			},
 			Required: []string{"path"},
 		},
@@ -134,13 +165,139 @@ func RegisterTools(srv *mcpserver.MCPServer, client *HTTPClient) {
 	// 8. list_participants
 	srv.AddTool(mcplib.Tool{
 		Name:        "list_participants",
-		Description: "List all participants connected to the room.",
+		Description: "List everyone who has been in the room: who is connected, when the others were last seen, how many messages each has posted, and their profiles.",
 		InputSchema: mcplib.ToolInputSchema{
 			Type:       "object",
 			Properties: map[string]any{},
 		},
 	}, makeListParticipantsHandler(client))
 
+	// 9. request_help
+	srv.AddTool(mcplib.Tool{
+		Name:        "request_help",
+		Description: "Delegate a task to another participant's Claude. The server spawns their Claude with your task (if their owner's policy allows) and it reports back to you with converse on the returned conv_id. Use this instead of converse when you want work done, not a chat.",
+		InputSchema: mcplib.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"to":      prop("string", "Whose Claude to ask, e.g. \"bob's Claude\" (see list_participants)"),
+				"task":    prop("string", "What you need done, with enough context to do it without asking back"),
+				"conv_id": prop("string", "Optional: continue an existing conversation"),
+			},
+			Required: []string{"to", "task"},
+		},
+	}, makeRequestHelpHandler(client))
+
+	// 10. send_code
+	srv.AddTool(mcplib.Tool{
+		Name:        "send_code",
+		Description: "Share a code snippet, rendered as code in the web UI and digests. Give a local file path or the code itself. Same privacy rules as send_message.",
+		InputSchema: mcplib.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"path":      prop("string", "Local file to send"),
+				"content":   prop("string", "The code, if not sending a file"),
+				"file_path": prop("string", "File name to show (defaults to path)"),
+				"language":  prop("string", "Language, e.g. go (detected from the file name if omitted)"),
+				"text":      prop("string", "Optional note to go with the code"),
+				"to":        prop("string", "Optional: specific recipient name for a private whisper. Leave unset to whisper to your owner."),
+				"broadcast": prop("boolean", "Set true to send a public message visible to all room participants"),
+			},
+		},
+	}, makeSendCodeHandler(client))
+
+	// 11. send_diff
+	srv.AddTool(mcplib.Tool{
+		Name:        "send_diff",
+		Description: "Share a unified diff, rendered as a diff in the web UI and digests. Same privacy rules as send_message.",
+		InputSchema: mcplib.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"diff":      prop("string", "The unified diff, e.g. output of git diff"),
+				"file_path": prop("string", "Optional: the file the diff applies to"),
+				"text":      prop("string", "Optional note to go with the diff"),
+				"to":        prop("string", "Optional: specific recipient name for a private whisper. Leave unset to whisper to your owner."),
+				"broadcast": prop("boolean", "Set true to send a public message visible to all room participants"),
+			},
+			Required: []string{"diff"},
+		},
+	}, makeSendDiffHandler(client))
+
+	// 12. read_file
+	srv.AddTool(mcplib.Tool{
+		Name:        "read_file",
+		Description: "Read a shared file's contents directly, without saving it. Text comes back inline; binary files come back as a base64 snippet. Use get_file to save large or binary files to disk.",
+		InputSchema: mcplib.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"file_id":   prop("string", "The file ID to read (see list_files)"),
+				"max_bytes": prop("number", fmt.Sprintf("Read at most this many bytes (default: %d)", defaultReadBytes)),
+			},
+			Required: []string{"file_id"},
+		},
+	}, makeReadFileHandler(client))
+
+	// 13. list_rooms
+	srv.AddTool(mcplib.Tool{
+		Name:        "list_rooms",
+		Description: "List the server's active rooms and which one your tools currently use.",
+		InputSchema: mcplib.ToolInputSchema{
+			Type:       "object",
+			Properties: map[string]any{},
+		},
+	}, makeListRoomsHandler(client))
+
+	// 14. switch_room
+	srv.AddTool(mcplib.Tool{
+		Name:        "switch_room",
+		Description: "Point all other tools at a different room for the rest of this session, e.g. to coordinate between a frontend and a backend room. Switch back the same way.",
+		InputSchema: mcplib.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"room":   prop("string", "Room to switch to"),
+				"create": prop("boolean", "Allow switching to a room that doesn't exist yet; it is created by your first message"),
+			},
+			Required: []string{"room"},
+		},
+	}, makeSwitchRoomHandler(client))
+
+	// 15. find_participant
+	srv.AddTool(mcplib.Tool{
+		Name:        "find_participant",
+		Description: "Resolve a fuzzy or misspelled name (\"kruz\", \"the backend claude\") to the exact participant name to pass to converse or request_help. Use it whenever you're not sure of a recipient's exact name; messages to a wrong name are never delivered.",
+		InputSchema: mcplib.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"pattern": prop("string", "Name or description of who you're looking for"),
+			},
+			Required: []string{"pattern"},
+		},
+	}, makeFindParticipantHandler(client))
+
+	// 16. send_json
+	srv.AddTool(mcplib.Tool{
+		Name:        "send_json",
+		Description: "Share structured, machine-readable data (a schema, test results, benchmark numbers) for another Claude to parse, instead of pasting JSON into a text message. Same privacy rules as send_message.",
+		InputSchema: mcplib.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"data":      map[string]any{"description": "The data: any JSON value, or a string holding a JSON document"},
+				"text":      prop("string", "Optional short label saying what the data is, e.g. \"go test -json summary\""),
+				"to":        prop("string", "Optional: specific recipient name for a private whisper. Leave unset to whisper to your owner."),
+				"broadcast": prop("boolean", "Set true to send a public message visible to all room participants"),
+			},
+			Required: []string{"data"},
+		},
+	}, makeSendJSONHandler(client))
+
+	// 17. get_charter
+	srv.AddTool(mcplib.Tool{
+		Name:        "get_charter",
+		Description: "Read the room's charter: the goals, coding standards and do/don't rules everyone in the room follows. Check it before starting work in a room you're new to.",
+		InputSchema: mcplib.ToolInputSchema{
+			Type:       "object",
+			Properties: map[string]any{},
+		},
+	}, makeGetCharterHandler(client))
 }
 
 func makeSendMessageHandler(client *HTTPClient) mcpserver.ToolHandlerFunc {
@@ -153,18 +310,19 @@ func makeSendMessageHandler(client *HTTPClient) mcpserver.ToolHandlerFunc {
 			return mcplib.NewToolResultError("text is required"), nil
 		}
 
-		var metadata map[string]string
-		if !broadcast {
-			// Private whisper: explicit recipient or server will auto-infer owner.
-			metadata = map[string]string{"private": "true"}
-			if to != "" {
-				metadata["to"] = to
-			}
+		ttl := request.GetInt("ttl", 0)
+		if ttl < 0 {
+			return mcplib.NewToolResultError("ttl must be a positive number of seconds"), nil
 		}
 
-		env, err := client.SendMessage(text, msgType, metadata)
+		env, err := client.Send(protocol.SendRequest{
+			Type:     msgType,
+			Payload:  protocol.NewTextPayload(text),
+			Metadata: withPriority(whisperMetadata(to, broadcast), request),
+			TTL:      ttl,
+		})
 		if err != nil {
-			return mcplib.NewToolResultError(fmt.Sprintf("failed to send: %v", err)), nil
+			return sendError(err), nil
 		}
 
 		if broadcast {
@@ -178,6 +336,126 @@ func makeSendMessageHandler(client *HTTPClient) mcpserver.ToolHandlerFunc {
 	}
 }
 
+// withPriority adds the request's priority argument, if any, to metadata.
+func withPriority(metadata map[string]string, request mcplib.CallToolRequest) map[string]string {
+	p := request.GetString("priority", "")
+	if p == "" || p == protocol.PriorityNormal {
+		return metadata
+	}
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	metadata["priority"] = p
+	return metadata
+}
+
+// sendError reports a failed send, or that the message was kept in the
+// outbox and will be delivered later.
+func sendError(err error) *mcplib.CallToolResult {
+	if errors.Is(err, ErrQueued) {
+		return mcplib.NewToolResultText(fmt.Sprintf("Not sent yet: %v", err))
+	}
+	return mcplib.NewToolResultError(fmt.Sprintf("failed to send: %v", err))
+}
+
+// whisperMetadata builds message metadata for the send_* tools: a private
+// whisper to `to` (or, when empty, the server infers the owner), unless broadcast.
+func whisperMetadata(to string, broadcast bool) map[string]string {
+	if broadcast {
+		return nil
+	}
+	metadata := map[string]string{"private": "true"}
+	if to != "" {
+		metadata["to"] = to
+	}
+	return metadata
+}
+
+func makeSendCodeHandler(client *HTTPClient) mcpserver.ToolHandlerFunc {
+	return func(ctx context.Context, request mcplib.CallToolRequest) (*mcplib.CallToolResult, error) {
+		path := request.GetString("path", "")
+		content := request.GetString("content", "")
+		filePath := request.GetString("file_path", path)
+		language := request.GetString("language", "")
+
+		if content == "" {
+			if path == "" {
+				return mcplib.NewToolResultError("path or content is required"), nil
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return mcplib.NewToolResultError(fmt.Sprintf("failed to read %s: %v", path, err)), nil
+			}
+			content = string(data)
+		}
+
+		payload := protocol.NewCodePayload(strings.TrimRight(content, "\n"), filePath, language)
+		payload.Text = request.GetString("text", "")
+		env, err := client.SendPayload(protocol.TypeCode, payload, whisperMetadata(request.GetString("to", ""), request.GetBool("broadcast", false)))
+		if err != nil {
+			return sendError(err), nil
+		}
+		return mcplib.NewToolResultText(fmt.Sprintf("Code sent (seq #%d, %d bytes, language: %s)", env.SeqNum, len(payload.Code), payload.Language)), nil
+	}
+}
+
+func makeSendDiffHandler(client *HTTPClient) mcpserver.ToolHandlerFunc {
+	return func(ctx context.Context, request mcplib.CallToolRequest) (*mcplib.CallToolResult, error) {
+		diff := request.GetString("diff", "")
+		if diff == "" {
+			return mcplib.NewToolResultError("diff is required"), nil
+		}
+
+		payload := protocol.NewDiffPayload(strings.TrimRight(diff, "\n"), request.GetString("file_path", ""))
+		payload.Text = request.GetString("text", "")
+		env, err := client.SendPayload(protocol.TypeDiff, payload, whisperMetadata(request.GetString("to", ""), request.GetBool("broadcast", false)))
+		if err != nil {
+			return sendError(err), nil
+		}
+		return mcplib.NewToolResultText(fmt.Sprintf("Diff sent (seq #%d, %d bytes)", env.SeqNum, len(payload.Diff))), nil
+	}
+}
+
+// payloadLabel describes a code or diff payload's file and note for get_messages.
+func makeSendJSONHandler(client *HTTPClient) mcpserver.ToolHandlerFunc {
+	return func(ctx context.Context, request mcplib.CallToolRequest) (*mcplib.CallToolResult, error) {
+		arg, ok := request.GetArguments()["data"]
+		if !ok || arg == nil {
+			return mcplib.NewToolResultError("data is required"), nil
+		}
+		raw, err := json.Marshal(arg)
+		if err != nil {
+			return mcplib.NewToolResultError(fmt.Sprintf("invalid data: %v", err)), nil
+		}
+		// A string that is itself a JSON document is sent as that document.
+		if s, isString := arg.(string); isString && json.Valid([]byte(s)) {
+			raw = []byte(s)
+		}
+
+		payload, err := protocol.NewJSONPayload(raw)
+		if err != nil {
+			return mcplib.NewToolResultError(err.Error()), nil
+		}
+		payload.Text = request.GetString("text", "")
+		env, err := client.SendPayload(protocol.TypeJSON, payload, whisperMetadata(request.GetString("to", ""), request.GetBool("broadcast", false)))
+		if err != nil {
+			return sendError(err), nil
+		}
+		return mcplib.NewToolResultText(fmt.Sprintf("Data sent (seq #%d, %d bytes)", env.SeqNum, len(payload.Data))), nil
+	}
+}
+
+func payloadLabel(p protocol.Payload) string {
+	label := ""
+	if p.FilePath != "" {
+		label += " (" + p.FilePath + ")"
+	}
+	if p.Text != "" {
+		label += " — " + p.Text
+	}
+	return label
+}
+
 func makeConverseHandler(client *HTTPClient) mcpserver.ToolHandlerFunc {
 	return func(ctx context.Context, request mcplib.CallToolRequest) (*mcplib.CallToolResult, error) {
 		to := request.GetString("to", "")
@@ -203,16 +481,36 @@ func makeConverseHandler(client *HTTPClient) mcpserver.ToolHandlerFunc {
 			"expecting_reply": expectingReply,
 		}
 
-		env, err := client.SendMessage(message, "text", metadata)
+		env, err := client.SendMessage(message, "text", withPriority(metadata, request))
 		if err != nil {
-			return mcplib.NewToolResultError(fmt.Sprintf("failed to send: %v", err)), nil
+			return sendError(err), nil
 		}
 
 		status := "sent"
 		if done {
 			status = "sent (conversation complete)"
 		}
-		return mcplib.NewToolResultText(fmt.Sprintf("Conversation message %s to %s (seq #%d, conv_id: %s)", status, to, env.SeqNum, convID)), nil
+		result := fmt.Sprintf("Conversation message %s to %s (seq #%d, conv_id: %s)", status, to, env.SeqNum, convID)
+		if env.Warning != "" {
+			result += fmt.Sprintf("\nWarning: %s. Use find_participant or list_participants to pick a reachable recipient.", env.Warning)
+		}
+		return mcplib.NewToolResultText(result), nil
+	}
+}
+
+func makeRequestHelpHandler(client *HTTPClient) mcpserver.ToolHandlerFunc {
+	return func(ctx context.Context, request mcplib.CallToolRequest) (*mcplib.CallToolResult, error) {
+		to := request.GetString("to", "")
+		task := request.GetString("task", "")
+		if to == "" || task == "" {
+			return mcplib.NewToolResultError("to and task are required"), nil
+		}
+
+		resp, err := client.RequestHelp(to, task, request.GetString("conv_id", ""))
+		if err != nil {
+			return mcplib.NewToolResultError(fmt.Sprintf("help request failed: %v", err)), nil
+		}
+		return mcplib.NewToolResultText(fmt.Sprintf("Help request sent to %s (seq #%d, conv_id: %s). They will reply with converse on this conv_id.", to, resp.Seq, resp.ConvID)), nil
 	}
 }
 
@@ -220,6 +518,13 @@ func makeGetMessagesHandler(client *HTTPClient) mcpserver.ToolHandlerFunc {
 	return func(ctx context.Context, request mcplib.CallToolRequest) (*mcplib.CallToolResult, error) {
 		latest := request.GetInt("latest", 20)
 		after := int64(request.GetFloat("after", 0))
+		if seq := int64(request.GetFloat("seq", 0)); seq > 0 {
+			env, err := client.GetMessage(seq)
+			if err != nil {
+				return mcplib.NewToolResultError(fmt.Sprintf("failed to get message #%d: %v", seq, err)), nil
+			}
+			return mcplib.NewToolResultText(formatMessages([]protocol.Envelope{*env})), nil
+		}
 		if after > 0 {
 			latest = 0
 		}
@@ -233,42 +538,53 @@ func makeGetMessagesHandler(client *HTTPClient) mcpserver.ToolHandlerFunc {
 			return mcplib.NewToolResultText("No messages found."), nil
 		}
 
-		var sb strings.Builder
-		for _, env := range list.Messages {
-			ts := env.Timestamp.Local().Format("15:04:05")
-			fmt.Fprintf(&sb, "[#%d %s] %s", env.SeqNum, ts, env.Sender)
-			if to := env.Metadata["to"]; to != "" {
-				fmt.Fprintf(&sb, " → %s", to)
-			}
-			switch env.Type {
-			case "text":
-				fmt.Fprintf(&sb, ": %s", env.Payload.Text)
-			case "code":
-				fmt.Fprintf(&sb, " shared code:\n```%s\n%s\n```", env.Payload.Language, env.Payload.Code)
-			case "diff":
-				fmt.Fprintf(&sb, " shared diff:\n%s", env.Payload.Diff)
-			case "file":
-				fmt.Fprintf(&sb, ": %s", env.Payload.Text)
-			case "system":
-				fmt.Fprintf(&sb, " --- %s", env.Payload.Text)
-			default:
-				fmt.Fprintf(&sb, ": %s", env.Payload.Text)
-			}
-			if env.Metadata["expecting_reply"] == "true" {
-				fmt.Fprintf(&sb, " (reply expected)")
-			}
-			if convID := env.Metadata["conv_id"]; convID != "" {
-				short := convID
-				if len(short) > 8 {
-					short = short[:8]
-				}
-				fmt.Fprintf(&sb, " conv:%s", short)
+		return mcplib.NewToolResultText(formatMessages(list.Messages)), nil
+	}
+}
+
+// formatMessages renders messages one per line, as get_messages shows them.
+func formatMessages(msgs []protocol.Envelope) string {
+	var sb strings.Builder
+	for _, env := range msgs {
+		ts := env.Timestamp.Local().Format("15:04:05")
+		fmt.Fprintf(&sb, "[#%d %s] %s", env.SeqNum, ts, env.Sender)
+		if to := env.Metadata["to"]; to != "" {
+			fmt.Fprintf(&sb, " → %s", to)
+		}
+		switch env.Type {
+		case "text":
+			fmt.Fprintf(&sb, ": %s", env.Payload.Text)
+		case "code":
+			fmt.Fprintf(&sb, " shared code%s:\n```%s\n%s\n```", payloadLabel(env.Payload), env.Payload.Language, env.Payload.Code)
+		case "diff":
+			fmt.Fprintf(&sb, " shared diff%s:\n%s", payloadLabel(env.Payload), env.Payload.Diff)
+		case protocol.TypeJSON:
+			fmt.Fprintf(&sb, " shared data%s:\n```json\n%s\n```", payloadLabel(env.Payload), env.Payload.IndentData())
+		case "file":
+			fmt.Fprintf(&sb, ": %s", env.Payload.Text)
+		case "system":
+			fmt.Fprintf(&sb, " --- %s", env.Payload.Text)
+		default:
+			fmt.Fprintf(&sb, ": %s", env.Payload.Text)
+		}
+		if env.Metadata["expecting_reply"] == "true" {
+			fmt.Fprintf(&sb, " (reply expected)")
+		}
+		if env.Metadata[protocol.MetaRespondedAt] != "" {
+			sb.WriteString(" [replied]")
+		} else if env.Metadata[protocol.MetaDeliveredAt] != "" {
+			sb.WriteString(" [delivered]")
+		}
+		if convID := env.Metadata["conv_id"]; convID != "" {
+			short := convID
+			if len(short) > 8 {
+				short = short[:8]
 			}
-			sb.WriteString("\n")
+			fmt.Fprintf(&sb, " conv:%s", short)
 		}
-
-		return mcplib.NewToolResultText(sb.String()), nil
+		sb.WriteString("\n")
 	}
+	return sb.String()
 }
 
 func makeSendFileHandler(client *HTTPClient) mcpserver.ToolHandlerFunc {
@@ -304,52 +620,105 @@ func makeGetFileHandler(client *HTTPClient) mcpserver.ToolHandlerFunc {
 	}
 }
 
+const (
+	defaultReadBytes   = 64 * 1024 // how much of a text file read_file returns by default
+	binarySnippetBytes = 4 * 1024  // how much of a binary file read_file returns, base64-encoded
+)
+
+func makeReadFileHandler(client *HTTPClient) mcpserver.ToolHandlerFunc {
+	return func(ctx context.Context, request mcplib.CallToolRequest) (*mcplib.CallToolResult, error) {
+		fileID := request.GetString("file_id", "")
+		if fileID == "" {
+			return mcplib.NewToolResultError("file_id is required"), nil
+		}
+		maxBytes := request.GetInt("max_bytes", defaultReadBytes)
+		if maxBytes <= 0 {
+			maxBytes = defaultReadBytes
+		}
+
+		data, contentType, truncated, err := client.ReadFile(fileID, maxBytes)
+		if err != nil {
+			return mcplib.NewToolResultError(fmt.Sprintf("failed to read: %v", err)), nil
+		}
+
+		if text, ok := asText(data, truncated); ok {
+			more := ""
+			if truncated {
+				more = fmt.Sprintf(", truncated at %d bytes — raise max_bytes or use get_file for the rest", maxBytes)
+			}
+			return mcplib.NewToolResultText(fmt.Sprintf("[%s, %d bytes%s]\n%s", contentType, len(text), more, text)), nil
+		}
+
+		// Binary: a short base64 snippet is enough to identify it.
+		if len(data) > binarySnippetBytes {
+			data, truncated = data[:binarySnippetBytes], true
+		}
+		more := ""
+		if truncated {
+			more = ", first bytes only — use get_file to save it"
+		}
+		return mcplib.NewToolResultText(fmt.Sprintf("[binary %s, %d bytes%s, base64]\n%s", contentType, len(data), more, base64.StdEncoding.EncodeToString(data))), nil
+	}
+}
+
+// asText returns data as a string if it looks like text. A truncated read may
+// end mid-character, so a partial trailing rune is dropped first.
+func asText(data []byte, truncated bool) (string, bool) {
+	if truncated {
+		for i := 0; i < utf8.UTFMax && len(data) > 0 && !utf8.Valid(data); i++ {
+			data = data[:len(data)-1]
+		}
+	}
+	if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+		return "", false
+	}
+	return string(data), true
+}
+
 func makeSendDirectoryHandler(client *HTTPClient) mcpserver.ToolHandlerFunc {
 	return func(ctx context.Context, request mcplib.CallToolRequest) (*mcplib.CallToolResult, error) {
 		dir := request.GetString("path", "")
-		recursive := request.GetBool("recursive", false)
 		description := request.GetString("description", "")
 		if dir == "" {
 			return mcplib.NewToolResultError("path is required"), nil
 		}
-
-		var files []string
-		var walkFn func(string) error
-		walkFn = func(p string) error {
-			entries, err := os.ReadDir(p)
-			if err != nil {
-				return err
-			}
-			for _, e := range entries {
-				full := p + "/" + e.Name()
-				if e.IsDir() {
-					if recursive {
-						walkFn(full)
-					}
-				} else {
-					files = append(files, full)
-				}
-			}
-			return nil
+		opts := sharedir.Options{
+			Recursive: request.GetBool("recursive", false),
+			Include:   request.GetStringSlice("include", nil),
+			Exclude:   request.GetStringSlice("exclude", nil),
+			NoIgnore:  request.GetBool("no_ignore", false),
+			MaxFiles:  request.GetInt("max_files", 0),
+			MaxBytes:  int64(request.GetFloat("max_mb", 0) * (1 << 20)),
 		}
-		if err := walkFn(dir); err != nil {
+
+		files, err := sharedir.Select(dir, opts)
+		if err != nil {
 			return mcplib.NewToolResultError(fmt.Sprintf("failed to read directory: %v", err)), nil
 		}
 		if len(files) == 0 {
-			return mcplib.NewToolResultText("No files found in directory."), nil
+			return mcplib.NewToolResultText("No files to upload: the directory is empty, or everything in it is ignored or excluded."), nil
+		}
+		if request.GetBool("dry_run", false) {
+			text := "Would upload:\n" + sharedir.Describe(files)
+			if err := sharedir.Check(files, opts); err != nil {
+				text += "\nBut: " + err.Error()
+			}
+			return mcplib.NewToolResultText(text), nil
+		}
+		if err := sharedir.Check(files, opts); err != nil {
+			return mcplib.NewToolResultError(err.Error()), nil
 		}
 
 		var sb strings.Builder
-		uploaded, failed := 0, 0
+		uploaded := 0
 		for _, f := range files {
 			desc := description
 			if desc == "" {
-				desc = f
+				desc = f.Path
 			}
-			info, err := client.UploadFile(f, desc)
+			info, err := client.UploadFile(f.Path, desc)
 			if err != nil {
-				fmt.Fprintf(&sb, "FAILED %s: %v\n", f, err)
-				failed++
+				fmt.Fprintf(&sb, "FAILED %s: %v\n", f.Rel, err)
 			} else {
 				fmt.Fprintf(&sb, "OK %s (id: %s, %d bytes)\n", info.Filename, info.ID, info.Size)
 				uploaded++
@@ -371,16 +740,130 @@ func makeListFilesHandler(client *HTTPClient) mcpserver.ToolHandlerFunc {
 			return mcplib.NewToolResultText("No files shared in this room."), nil
 		}
 
+		return mcplib.NewToolResultText(formatFiles(list.Files)), nil
+	}
+}
+
+// formatFiles renders shared files one per line, as list_files shows them.
+func formatFiles(files []protocol.FileInfo) string {
+	var sb strings.Builder
+	for _, f := range files {
+		ts := f.Timestamp.Local().Format("15:04:05")
+		fmt.Fprintf(&sb, "[%s] %s: %s (%d bytes) id:%s", ts, f.Sender, f.Filename, f.Size, f.ID)
+		if f.Description != "" {
+			fmt.Fprintf(&sb, " — %s", f.Description)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func makeListRoomsHandler(client *HTTPClient) mcpserver.ToolHandlerFunc {
+	return func(ctx context.Context, request mcplib.CallToolRequest) (*mcplib.CallToolResult, error) {
+		list, err := client.ListRooms()
+		if err != nil {
+			return mcplib.NewToolResultError(fmt.Sprintf("failed to list rooms: %v", err)), nil
+		}
+
+		current := client.Room()
 		var sb strings.Builder
-		for _, f := range list.Files {
-			ts := f.Timestamp.Local().Format("15:04:05")
-			fmt.Fprintf(&sb, "[%s] %s: %s (%d bytes) id:%s", ts, f.Sender, f.Filename, f.Size, f.ID)
-			if f.Description != "" {
-				fmt.Fprintf(&sb, " — %s", f.Description)
+		fmt.Fprintf(&sb, "Current room: %s\n", current)
+		for _, r := range list.Rooms {
+			marker := " "
+			if r.Name == current {
+				marker = "*"
 			}
-			sb.WriteString("\n")
+			fmt.Fprintf(&sb, "%s %s (%d connected, %d messages)\n", marker, r.Name, r.Clients, r.MessageCount)
+		}
+		return mcplib.NewToolResultText(sb.String()), nil
+	}
+}
+
+func makeSwitchRoomHandler(client *HTTPClient) mcpserver.ToolHandlerFunc {
+	return func(ctx context.Context, request mcplib.CallToolRequest) (*mcplib.CallToolResult, error) {
+		room := strings.TrimSpace(request.GetString("room", ""))
+		if room == "" {
+			return mcplib.NewToolResultError("room is required"), nil
+		}
+
+		if !request.GetBool("create", false) {
+			list, err := client.ListRooms()
+			if err != nil {
+				return mcplib.NewToolResultError(fmt.Sprintf("failed to list rooms: %v", err)), nil
+			}
+			found := false
+			for _, r := range list.Rooms {
+				if r.Name == room {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return mcplib.NewToolResultError(fmt.Sprintf("room %q does not exist (set create=true to start it, or see list_rooms)", room)), nil
+			}
+		}
+
+		prev := client.Room()
+		client.SetRoom(room)
+		return mcplib.NewToolResultText(fmt.Sprintf("Switched from %s to %s. All tools now use %s until you switch again.", prev, room, room)), nil
+	}
+}
+
+func makeFindParticipantHandler(client *HTTPClient) mcpserver.ToolHandlerFunc {
+	return func(ctx context.Context, request mcplib.CallToolRequest) (*mcplib.CallToolResult, error) {
+		pattern := strings.TrimSpace(request.GetString("pattern", ""))
+		if pattern == "" {
+			return mcplib.NewToolResultError("pattern is required"), nil
+		}
+
+		list, err := client.ListParticipants()
+		if err != nil {
+			return mcplib.NewToolResultError(fmt.Sprintf("failed to list participants: %v", err)), nil
+		}
+		var candidates []nameCandidate
+		seen := make(map[string]bool)
+		for _, p := range list.Participants {
+			candidates = append(candidates, nameCandidate{Name: p.Name, Role: p.Role, Connected: p.Connected})
+			seen[p.Name] = true
+		}
+		// Spawned Claudes post over HTTP and never join, so they only show
+		// up as senders.
+		if msgs, err := client.GetMessages(200, 0); err == nil {
+			for _, env := range msgs.Messages {
+				if env.Sender != "" && !seen[env.Sender] && env.Type != protocol.TypeSystem {
+					candidates = append(candidates, nameCandidate{Name: env.Sender})
+					seen[env.Sender] = true
+				}
+			}
+		}
+
+		matches := rankNames(pattern, candidates)
+		if len(matches) == 0 {
+			names := make([]string, 0, len(candidates))
+			for _, c := range candidates {
+				names = append(names, fmt.Sprintf("%q", c.Name))
+			}
+			return mcplib.NewToolResultText(fmt.Sprintf("No one in %s matches %q. Known names: %s", client.Room(), pattern, strings.Join(names, ", "))), nil
 		}
 
+		var sb strings.Builder
+		describe := func(c nameCandidate) string {
+			switch {
+			case c.Role == "":
+				return "recent sender"
+			case c.Connected:
+				return c.Role + ", connected"
+			default:
+				return c.Role + ", disconnected"
+			}
+		}
+		fmt.Fprintf(&sb, "Best match: %q (%s). Pass this exact name as `to`.\n", matches[0].Name, describe(matches[0]))
+		if len(matches) > 1 {
+			sb.WriteString("Other candidates:\n")
+			for _, c := range matches[1:min(len(matches), 5)] {
+				fmt.Fprintf(&sb, "  %q (%s, score %.2f)\n", c.Name, describe(c), c.Score)
+			}
+		}
 		return mcplib.NewToolResultText(sb.String()), nil
 	}
 }
@@ -396,15 +879,80 @@ func makeListParticipantsHandler(client *HTTPClient) mcpserver.ToolHandlerFunc {
 			return mcplib.NewToolResultText("No participants in this room."), nil
 		}
 
-		var sb strings.Builder
-		for _, p := range list.Participants {
-			status := "disconnected"
-			if p.Connected {
-				status = "connected"
+		return mcplib.NewToolResultText(formatParticipants(list.Participants)), nil
+	}
+}
+
+// formatParticipants renders participants with their profiles and daemon
+// health, as list_participants shows them: connected ones first, then the
+// offline ones most recently seen first.
+func formatParticipants(participants []protocol.ParticipantInfo) string {
+	participants = slices.Clone(participants)
+	slices.SortFunc(participants, func(a, b protocol.ParticipantInfo) int {
+		if a.Connected != b.Connected {
+			if a.Connected {
+				return -1
+			}
+			return 1
+		}
+		if c := b.LastSeen.Compare(a.LastSeen); c != 0 && !a.Connected {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	var sb strings.Builder
+	for _, p := range participants {
+		status := "connected"
+		if !p.Connected {
+			status = "offline, last seen " + seenAt(p.LastSeen)
+		}
+		fmt.Fprintf(&sb, "%s (role: %s, %s, %d messages, joined: %s)\n", p.Name, p.Role, status, p.Messages, seenAt(p.JoinedAt))
+		if p.Owner != "" && p.Name != protocol.ClaudeName(p.Owner, "") {
+			fmt.Fprintf(&sb, "  %s's Claude\n", p.Owner)
+		}
+		if len(p.SpeaksFor) > 0 {
+			fmt.Fprintf(&sb, "  also answers as: %s\n", strings.Join(p.SpeaksFor, ", "))
+		}
+		if p.Profile != nil {
+			fmt.Fprintf(&sb, "  profile: %s\n", p.Profile)
+		}
+		if h := p.Health; h != nil && p.Connected {
+			fmt.Fprintf(&sb, "  daemon: %d/%d spawns running", h.ActiveSpawns, h.MaxConcurrent)
+			if h.LastResult != "" {
+				fmt.Fprintf(&sb, ", last spawn %s at %s", h.LastResult, h.LastSpawnAt.Local().Format("15:04:05"))
+			}
+			if h.ClaudeVersion != "" {
+				fmt.Fprintf(&sb, ", claude %s", h.ClaudeVersion)
 			}
-			fmt.Fprintf(&sb, "%s (role: %s, %s, joined: %s)\n", p.Name, p.Role, status, p.JoinedAt.Local().Format("15:04:05"))
+			sb.WriteString("\n")
 		}
+	}
+	return sb.String()
+}
 
-		return mcplib.NewToolResultText(sb.String()), nil
+// seenAt formats a participant timestamp: the time of day for today, the
+// date and time before that.
+func seenAt(t time.Time) string {
+	t = t.Local()
+	if t.Format(time.DateOnly) == time.Now().Format(time.DateOnly) {
+		return t.Format("15:04:05")
+	}
+	return t.Format("Jan 2 15:04")
+}
+
+func makeGetCharterHandler(client *HTTPClient) mcpserver.ToolHandlerFunc {
+	return func(ctx context.Context, request mcplib.CallToolRequest) (*mcplib.CallToolResult, error) {
+		c, err := client.GetCharter()
+		if err != nil {
+			return mcplib.NewToolResultError(fmt.Sprintf("failed to get charter: %v", err)), nil
+		}
+		if c.Text == "" {
+			return mcplib.NewToolResultText("This room has no charter."), nil
+		}
+		by := ""
+		if c.UpdatedBy != "" {
+			by = " by " + c.UpdatedBy
+		}
+		return mcplib.NewToolResultText(fmt.Sprintf("Charter of %s (updated%s %s):\n\n%s", client.Room(), by, seenAt(c.UpdatedAt), c.Text)), nil
 	}
 }
@@ -0,0 +1,163 @@
+// Package prompt builds the prompt a spawned Claude is given, for the daemon,
+// the web watcher and host-mode spawns alike.
+//
+// The prompt is a text/template (default.tmpl) made of named sections:
+//
+//	charter       the room's charter, its ground rules
+//	intro         who the Claude is and where
+//	group         the participants of a group thread
+//	profiles      the room's participants' profiles
+//	summary       what else is going on in the room
+//	context       the messages leading up to the trigger
+//	direct        a directed message and how to reply to it
+//	mention       an @-mention and how to reply to it
+//	help_request  a request_help task and how to report back
+//	done          when to end a conversation, in direct
+//	style         the tone to reply in, in all three
+//
+// Operators tune them without forking by redefining sections in an
+// override, e.g. {{define "style"}}Reply in one short paragraph.{{end}}.
+// An override with text outside any define replaces the whole prompt; it
+// can still include the built-in one with {{.Default}}.
+package prompt
+
+import (
+	_ "embed"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+//go:embed default.tmpl
+var defaultSource string
+
+// Data is what prompt templates are executed with.
+//
+//	{{.Name}} {{.Room}}           the spawned Claude's participant name and the room
+//	{{.Charter}}                  the room's charter, or ""
+//	{{.Reason}}                   why the spawn happened
+//	{{.Trigger}}                  the message that triggered it (may be nil)
+//	{{.ReplyTo}} {{.ConvID}}      trigger sender and conversation ID
+//	{{.Context}}                  the trigger's thread, or recent messages; oldest first
+//	{{.Summary}}                  what else is going on in the room, when Context is a thread
+//	{{.Participants}} {{.Group}}  everyone in a group thread, and whether there is one
+//	{{.Profiles}}                 the room's participants' profiles, by name
+//	{{.Urgent}}                   whether the trigger is urgent
+//	{{.Budget}}                   bytes of Context to render; see protocol.FormatContext
+//	{{.Default}}                  the built-in prompt, to extend rather than replace
+type Data struct {
+	Name         string
+	Room         string
+	Charter      string
+	Reason       string
+	Trigger      *protocol.Envelope
+	ReplyTo      string
+	ConvID       string
+	Context      []protocol.Envelope
+	Summary      string
+	Participants []string
+	Group        bool
+	Profiles     map[string]protocol.Profile
+	Urgent       bool
+	Budget       int
+	Default      string
+}
+
+// NewData returns the data for a spawn for req, answered by name in room
+// with budget bytes of context (0 = protocol.DefaultPromptBudget).
+func NewData(name, room string, req *protocol.SpawnReq, budget int) Data {
+	d := Data{
+		Name:         name,
+		Room:         room,
+		Charter:      req.Charter,
+		Reason:       req.Reason,
+		Trigger:      req.Trigger,
+		Context:      req.Context,
+		Summary:      req.Summary,
+		Participants: req.Participants,
+		Group:        len(req.Participants) > 1,
+		Profiles:     req.Profiles,
+		Budget:       budget,
+	}
+	if req.Trigger != nil {
+		d.ReplyTo = req.Trigger.Sender
+		d.ConvID = req.Trigger.Metadata["conv_id"]
+		d.Urgent = protocol.MessagePriority(req.Trigger.Metadata) == protocol.PriorityUrgent
+	}
+	return d
+}
+
+// funcs are helpers available in prompt templates.
+var funcs = template.FuncMap{
+	"clock":    func(t time.Time) string { return t.Format("15:04:05") },
+	"join":     strings.Join,
+	"to":       func(env protocol.Envelope) string { return env.Metadata["to"] },
+	"context":  protocol.FormatContext,
+	"profiles": protocol.DescribeProfiles,
+}
+
+// builtin is default.tmpl parsed; Parse clones it rather than execute it.
+var builtin = sync.OnceValue(func() *template.Template {
+	return template.Must(template.New("prompt").Funcs(funcs).Parse(defaultSource))
+})
+
+// Parse returns the built-in templates with each override applied in turn,
+// later ones winning.
+func Parse(overrides ...string) (*template.Template, error) {
+	tmpl := template.Must(builtin().Clone())
+	for _, src := range overrides {
+		if src == "" {
+			continue
+		}
+		if _, err := tmpl.Parse(src); err != nil {
+			return nil, fmt.Errorf("parse prompt template: %w", err)
+		}
+	}
+	return tmpl, nil
+}
+
+// Load reads an override file and checks that it parses.
+func Load(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read prompt template: %w", err)
+	}
+	if _, err := Parse(string(data)); err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Build renders the prompt for d with overrides applied. If they don't parse
+// or execute, it logs why and renders the built-in prompt instead.
+func Build(d Data, overrides ...string) string {
+	s, err := render(d, overrides)
+	if err != nil {
+		log.Printf("prompt template failed, using the default prompt: %v", err)
+		s, _ = render(d, nil)
+	}
+	return s
+}
+
+func render(d Data, overrides []string) (string, error) {
+	tmpl, err := Parse(overrides...)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := tmpl.ExecuteTemplate(&sb, "default", d); err != nil {
+		return "", err
+	}
+	d.Default = sb.String()
+	sb.Reset()
+	if err := tmpl.Execute(&sb, d); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,130 @@
+package protocol
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// TokenEnv holds the access token for a workspace on a hosted server. The
+// CLI, daemons and MCP servers send it with every request, and spawned
+// Claudes inherit it.
+const TokenEnv = "CLAUDETALK_TOKEN"
+
+// TokenParam is the query parameter that carries the token on WebSocket
+// URLs, where browsers can't set headers.
+const TokenParam = "token"
+
+// Token returns the access token from TokenEnv, or "".
+func Token() string {
+	return os.Getenv(TokenEnv)
+}
+
+// RoomTokenEnv holds the access tokens of private rooms, comma-separated.
+// Like TokenEnv, it goes with every request and to spawned Claudes.
+const RoomTokenEnv = "CLAUDETALK_ROOM_TOKEN"
+
+// RoomTokenHeader carries RoomTokenEnv's tokens on requests, and
+// RoomTokenParam each of them on WebSocket and download URLs. A private room
+// admits a request carrying its token among others.
+const (
+	RoomTokenHeader = "X-Claudetalk-Room-Token"
+	RoomTokenParam  = "room_token"
+)
+
+// ShareHeader carries a guest's share link token on requests, and ShareParam
+// on WebSocket and download URLs; see ShareLink.
+const (
+	ShareHeader = "X-Claudetalk-Share"
+	ShareParam  = "share"
+)
+
+// RoomTokens returns the private room tokens from RoomTokenEnv.
+func RoomTokens() []string {
+	var tokens []string
+	for _, t := range strings.Split(os.Getenv(RoomTokenEnv), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+// AddRoomToken adds token to RoomTokenEnv for this process and the Claudes
+// it spawns.
+func AddRoomToken(token string) {
+	tokens := RoomTokens()
+	if token == "" || slices.Contains(tokens, token) {
+		return
+	}
+	os.Setenv(RoomTokenEnv, strings.Join(append(tokens, token), ","))
+}
+
+// AuthTransport sends the TokenEnv token, when there is one, as a bearer
+// token on every request, the RoomTokenEnv tokens in RoomTokenHeader, and
+// ProtocolVersion in ProtocolHeader. Base nil means http.DefaultTransport.
+type AuthTransport struct {
+	Base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t AuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	token, rooms := Token(), RoomTokens()
+	req = req.Clone(req.Context())
+	req.Header.Set(ProtocolHeader, strconv.Itoa(ProtocolVersion))
+	if token != "" && req.Header.Get("Authorization") == "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if len(rooms) > 0 {
+		req.Header.Set(RoomTokenHeader, strings.Join(rooms, ","))
+	}
+	return base.RoundTrip(req)
+}
+
+// RedactToken hides the TokenEnv and RoomTokenEnv tokens in s, a URL about
+// to be logged.
+func RedactToken(s string) string {
+	for _, token := range append(RoomTokens(), Token()) {
+		if token != "" {
+			s = strings.ReplaceAll(s, url.QueryEscape(token), "REDACTED")
+		}
+	}
+	return s
+}
+
+// TokenQuery returns the TokenEnv and RoomTokenEnv tokens as WebSocket URL
+// query parameters, or nil without any.
+func TokenQuery() url.Values {
+	q := url.Values{}
+	if token := Token(); token != "" {
+		q.Set(TokenParam, token)
+	}
+	for _, t := range RoomTokens() {
+		q.Add(RoomTokenParam, t)
+	}
+	return q
+}
+
+// HandshakeError adds the server's reason to a failed WebSocket dial, which
+// otherwise reads only "bad handshake" whether the room doesn't exist or the
+// token is wrong. resp is the handshake response, if there was one.
+func HandshakeError(err error, resp *http.Response) error {
+	if resp == nil || resp.Body == nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if msg := strings.TrimSpace(string(body)); msg != "" {
+		return fmt.Errorf("%w: %s: %s", err, resp.Status, msg)
+	}
+	return fmt.Errorf("%w: %s", err, resp.Status)
+}
@@ -0,0 +1,35 @@
+package protocol
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ClaudeSuffix ends the name a participant's Claude speaks as by default.
+const ClaudeSuffix = "'s Claude"
+
+// MaxClaudeName is the longest Claude name the server accepts.
+const MaxClaudeName = 64
+
+// ClaudeName returns the name owner's Claude speaks as: name, if the owner
+// picked one, or "owner's Claude".
+func ClaudeName(owner, name string) string {
+	if name != "" {
+		return name
+	}
+	return owner + ClaudeSuffix
+}
+
+// ValidateClaudeName checks a name picked for a Claude. Colons are out, as
+// they separate the members in direct message room names.
+func ValidateClaudeName(name string) error {
+	switch {
+	case name == "" || strings.TrimSpace(name) != name:
+		return fmt.Errorf("claude name can't be empty or start or end with spaces")
+	case len(name) > MaxClaudeName:
+		return fmt.Errorf("claude name is longer than %d bytes", MaxClaudeName)
+	case strings.ContainsAny(name, ":\r\n\t"):
+		return fmt.Errorf("claude name can't contain colons or control characters")
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package protocol
+
+import "time"
+
+// Conversation summarizes a conv_id thread, for GET
+// /api/rooms/{room}/conversations.
+type Conversation struct {
+	ConvID       string    `json:"conv_id"`
+	Topic        string    `json:"topic,omitempty"` // the start of its first message's text, unless encrypted
+	Participants []string  `json:"participants"`    // in order of first appearance
+	Messages     int       `json:"messages"`
+	Started      time.Time `json:"started"`              // when its first message was sent
+	Last         Envelope  `json:"last"`                 // the latest message in it
+	Open         bool      `json:"open"`                 // Last expects a reply
+	WaitingOn    string    `json:"waiting_on,omitempty"` // who owes that reply, while Open
+}
+
+// ConversationList is the response of GET /api/rooms/{room}/conversations,
+// most recently active first.
+type ConversationList struct {
+	Room          string         `json:"room"`
+	Conversations []Conversation `json:"conversations"`
+	Count         int            `json:"count"`
+}
+
+// ReplyLatency is how quickly a participant answers the directed messages
+// that expect a reply from it in conv_id threads, over the room's history.
+type ReplyLatency struct {
+	Name           string  `json:"name"`
+	Answered       int     `json:"answered"`
+	P50Sec         float64 `json:"p50_seconds"`
+	P95Sec         float64 `json:"p95_seconds"`
+	Waiting        int     `json:"waiting"`                        // messages still unanswered
+	LongestWaitSec float64 `json:"longest_wait_seconds,omitempty"` // how long the oldest of those has waited
+}
+
+// RoomStats is the response of GET /api/rooms/{room}/stats.
+type RoomStats struct {
+	Room    string         `json:"room"`
+	Replies []ReplyLatency `json:"replies"` // by name
+}
+
+// ReplyAlert is what the server POSTs to its reply alert webhook when a
+// directed message has waited too long for its reply.
+type ReplyAlert struct {
+	Room       string  `json:"room"`
+	ConvID     string  `json:"conv_id"`
+	From       string  `json:"from"`
+	To         string  `json:"to"` // who owes the reply
+	Seq        int64   `json:"seq"`
+	WaitingSec float64 `json:"waiting_seconds"`
+	Text       string  `json:"text"`
+}
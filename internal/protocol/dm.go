@@ -0,0 +1,56 @@
+package protocol
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DMPrefix starts the name of every direct message room.
+const DMPrefix = "dm:"
+
+// DMRoom returns the direct message room between a and b, the same whichever
+// of them asks. Names may not contain ':', which separates them.
+func DMRoom(a, b string) (string, error) {
+	if a == "" || b == "" || strings.Contains(a, ":") || strings.Contains(b, ":") {
+		return "", fmt.Errorf("direct messages need two names without ':', got %q and %q", a, b)
+	}
+	if a == b {
+		return "", fmt.Errorf("can't direct message yourself")
+	}
+	if b < a {
+		a, b = b, a
+	}
+	return DMPrefix + a + ":" + b, nil
+}
+
+// DMMembers returns the two members of a direct message room, or ok false
+// if room isn't one.
+func DMMembers(room string) (a, b string, ok bool) {
+	rest, ok := strings.CutPrefix(room, DMPrefix)
+	if !ok {
+		return "", "", false
+	}
+	a, b, ok = strings.Cut(rest, ":")
+	if !ok || a == "" || b == "" || strings.Contains(b, ":") {
+		return "", "", false
+	}
+	return a, b, true
+}
+
+// DMPeer returns the member of a direct message room sender is talking to.
+// A member's Claude ("alice's Claude") speaks for the member. ok is false if
+// room isn't a direct message room or sender isn't in it.
+func DMPeer(room, sender string) (peer string, ok bool) {
+	a, b, ok := DMMembers(room)
+	if !ok {
+		return "", false
+	}
+	sender = strings.TrimSuffix(sender, "'s Claude")
+	switch sender {
+	case a:
+		return b, true
+	case b:
+		return a, true
+	}
+	return "", false
+}
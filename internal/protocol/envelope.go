@@ -12,14 +12,50 @@ type Envelope struct {
 	Payload   Payload           `json:"payload"`
 	SeqNum    int64             `json:"seq"`
 	Metadata  map[string]string `json:"metadata,omitempty"`
+	ReplyTo   string            `json:"reply_to,omitempty"`       // ID of the message this replies to, if any
+	Warning   string            `json:"warning,omitempty"`        // only in send responses, e.g. an undeliverable directed message
+	Digest    *PayloadDigest    `json:"payload_digest,omitempty"` // only when PayloadOptions cut the payload
 }
 
 // SendRequest is the JSON body for POST /api/rooms/{room}/messages.
+// Room is only used on multi-room WebSocket connections to pick the target room.
+// On daemon WebSocket connections, a frame with Health or Ack set is a status
+// report rather than a message.
 type SendRequest struct {
+	Room     string            `json:"room,omitempty"`
 	Sender   string            `json:"sender"`
 	Type     string            `json:"type"`
 	Payload  Payload           `json:"payload"`
 	Metadata map[string]string `json:"metadata,omitempty"`
+	Health   *DaemonHealth     `json:"health,omitempty"`
+	Ack      *DeliveryAck      `json:"ack,omitempty"`
+	TTL      int               `json:"ttl,omitempty"`      // seconds until the server purges the message; 0 = keep
+	ReplyTo  string            `json:"reply_to,omitempty"` // ID of a message in the room to reply to
+}
+
+// DeliveryAck is sent by a daemon once it has spawned a Claude for a directed
+// message. The server then stamps MetaDeliveredAt on that message.
+type DeliveryAck struct {
+	MessageID string `json:"message_id"`
+}
+
+// Delivery metadata the server stamps on directed messages, as RFC 3339 UTC
+// times. A message with neither was never picked up; one with only
+// MetaDeliveredAt reached the recipient's Claude but got no reply.
+const (
+	MetaDeliveredAt = "delivered_at" // recipient's daemon spawned a Claude for it
+	MetaRespondedAt = "responded_at" // recipient posted in the same conversation
+)
+
+// MetaExpiresAt is stamped, as an RFC 3339 UTC time, on messages sent with a
+// TTL. At that time the server drops the message from history and sends a
+// "message_expired" event carrying its ID, room and seq so UIs can redact it.
+const MetaExpiresAt = "expires_at"
+
+// Ephemeral reports whether metadata marks a message that will expire.
+// Ephemeral messages are left out of digests.
+func Ephemeral(metadata map[string]string) bool {
+	return metadata[MetaExpiresAt] != ""
 }
 
 // MessageList is the response for message list endpoints.
@@ -31,10 +67,12 @@ type MessageList struct {
 
 // RoomInfo describes an active room.
 type RoomInfo struct {
-	Name        string `json:"name"`
-	Clients     int    `json:"clients"`
-	MessageCount int   `json:"message_count"`
-	LastSeq     int64  `json:"last_seq"`
+	Name         string `json:"name"`
+	Clients      int    `json:"clients"`
+	MessageCount int    `json:"message_count"`
+	LastSeq      int64  `json:"last_seq"`
+	Visibility   string `json:"visibility,omitempty"`
+	Token        string `json:"token,omitempty"` // a private room's access token, when creating it
 }
 
 // RoomList is the response for GET /api/rooms.
@@ -42,12 +80,135 @@ type RoomList struct {
 	Rooms []RoomInfo `json:"rooms"`
 }
 
+// CreateRoomRequest is the body of POST /api/rooms. The response is the
+// new room's RoomInfo, with Token set for a private room.
+type CreateRoomRequest struct {
+	Name       string `json:"name"`
+	Visibility string `json:"visibility,omitempty"` // public (default), unlisted or private
+}
+
+// Invite is a code that stands for a room, from POST
+// /api/rooms/{room}/invites. GET /api/invites/{code} resolves it, so a room
+// can be shared without anyone retyping its name, and a private room without
+// handing out its token: resolving an invite to one returns the token.
+type Invite struct {
+	Code      string    `json:"code"`
+	Room      string    `json:"room"`
+	CreatedBy string    `json:"created_by,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Token     string    `json:"token,omitempty"` // the private room's access token
+}
+
+// ShareLink is a signed, expiring guest link to one room, from POST
+// /api/rooms/{room}/share. Whoever opens /join/{token} in a browser can read
+// the room, and with post access send messages as Name, without the CLI or
+// the room's token. GET /api/share/{token} checks one.
+type ShareLink struct {
+	Token     string    `json:"token"`
+	Path      string    `json:"path"` // /join/{token}, on the server's web UI
+	Room      string    `json:"room"`
+	Access    string    `json:"access"`         // read or post
+	Name      string    `json:"name,omitempty"` // who a guest is in the room
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
 // HealthResponse is the response for GET /api/health.
 type HealthResponse struct {
-	Status    string  `json:"status"`
-	Uptime    string  `json:"uptime"`
-	UptimeSec float64 `json:"uptime_seconds"`
-	Rooms     int     `json:"rooms"`
+	Status       string  `json:"status"`
+	Uptime       string  `json:"uptime"`
+	UptimeSec    float64 `json:"uptime_seconds"`
+	Rooms        int     `json:"rooms"`
+	RoomCreation string  `json:"room_creation,omitempty"` // "auto" or "explicit"
+}
+
+// VersionInfo is the response for GET /api/version, and what "claudetalk
+// version" prints: the release a binary was built as and the commit it was
+// built from.
+type VersionInfo struct {
+	Version     string `json:"version"`               // release tag such as v1.4.0, or "dev"
+	Commit      string `json:"commit,omitempty"`      // git SHA, if built from a checkout
+	CommitTime  string `json:"commit_time,omitempty"` // RFC 3339
+	Modified    bool   `json:"modified,omitempty"`    // built with uncommitted changes
+	Protocol    int    `json:"protocol"`              // ProtocolVersion
+	MinProtocol int    `json:"min_protocol"`          // MinProtocol
+	GoVersion   string `json:"go_version"`
+	OS          string `json:"os"`
+	Arch        string `json:"arch"`
+}
+
+// String is the version with a short commit, such as "v1.4.0 (3f2a9c1)".
+func (v VersionInfo) String() string {
+	s := v.Version
+	if v.Commit != "" {
+		s += " (" + v.Commit[:min(len(v.Commit), 7)]
+		if v.Modified {
+			s += ", modified"
+		}
+		s += ")"
+	}
+	return s
+}
+
+// SameBuild reports whether v and o are the same release, or without
+// releases on both sides, the same commit. known is false when neither
+// tells, as for uncommitted changes to the same commit.
+func (v VersionInfo) SameBuild(o VersionInfo) (same, known bool) {
+	switch {
+	case v.Version != "dev" && o.Version != "dev":
+		return v.Version == o.Version, true
+	case v.Commit == "" || o.Commit == "":
+		return false, false
+	case v.Commit != o.Commit:
+		return false, true
+	case v.Modified || o.Modified:
+		return false, false
+	}
+	return true, true
+}
+
+// Readiness statuses in ReadinessResponse.
+const (
+	ReadinessReady    = "ready"     // serving, every check passes
+	ReadinessDegraded = "degraded"  // serving, but a check such as the agent or tunnel fails
+	ReadinessNotReady = "not_ready" // storage fails or the server is shutting down; answered with 503
+)
+
+// ReadinessResponse is the response for GET /api/health/ready.
+type ReadinessResponse struct {
+	Status    string                 `json:"status"` // see ReadinessReady
+	Uptime    string                 `json:"uptime"`
+	UptimeSec float64                `json:"uptime_seconds"`
+	Draining  bool                   `json:"draining,omitempty"`
+	Checks    map[string]HealthCheck `json:"checks"` // storage, agent, tunnel, ...
+	Rooms     int                    `json:"rooms"`
+	Sessions  int                    `json:"sessions"` // active Claude sessions, running or queued
+	Running   int                    `json:"running"`  // Claude processes running now
+	Queued    int                    `json:"queued"`
+	Window    string                 `json:"error_window"`
+	Requests  ErrorRate              `json:"requests"` // HTTP requests; failures are 5xx responses
+	Spawns    ErrorRate              `json:"spawns"`   // finished spawns; failures errored or timed out
+}
+
+// HealthCheck is a dependency's state in a ReadinessResponse.
+type HealthCheck struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// ErrorRate counts recent outcomes and how many failed.
+type ErrorRate struct {
+	Total  int     `json:"total"`
+	Failed int     `json:"failed"`
+	Rate   float64 `json:"rate"` // Failed / Total; 0 with no outcomes
+}
+
+// NewErrorRate returns the rate of failed out of total.
+func NewErrorRate(total, failed int) ErrorRate {
+	e := ErrorRate{Total: total, Failed: failed}
+	if total > 0 {
+		e.Rate = float64(failed) / float64(total)
+	}
+	return e
 }
 
 // FileInfo describes a file shared in a room.
@@ -72,26 +233,160 @@ type FileList struct {
 
 // ServerEvent is the discriminated union sent to daemon WebSocket clients.
 type ServerEvent struct {
-	Event   string    `json:"event"`
-	Message *Envelope `json:"message,omitempty"`
-	File    *FileInfo `json:"file,omitempty"`
-	Spawn   *SpawnReq `json:"spawn,omitempty"`
+	Event   string       `json:"event"`
+	Message *Envelope    `json:"message,omitempty"`
+	File    *FileInfo    `json:"file,omitempty"`
+	Spawn   *SpawnReq    `json:"spawn,omitempty"`
+	Claim   *ClaimStatus `json:"claim,omitempty"`
+}
+
+// Claim states for a daemon connection's name.
+const (
+	ClaimActive  = "active"  // this connection receives spawn events
+	ClaimStandby = "standby" // another connection does; this one takes over if it leaves
+)
+
+// ClaimStatus tells a daemon connection whether it answers for its name.
+// Only one daemon connection per name receives spawn events at a time.
+type ClaimStatus struct {
+	Room   string `json:"room"`
+	State  string `json:"state"`
+	Reason string `json:"reason,omitempty"`
 }
 
 // SpawnReq tells a daemon to spawn a Claude Code instance.
 type SpawnReq struct {
-	Reason       string     `json:"reason"`
-	Trigger      *Envelope  `json:"trigger"`
-	Context      []Envelope `json:"context"`
-	Participants []string   `json:"participants,omitempty"` // all members of this conv thread (group convos)
+	Reason          string             `json:"reason"`
+	Trigger         *Envelope          `json:"trigger"`
+	Context         []Envelope         `json:"context"`                    // the trigger's thread, or the room's latest messages
+	Summary         string             `json:"summary,omitempty"`          // the rest of the room, when Context is a thread
+	Participants    []string           `json:"participants,omitempty"`     // all members of this conv thread (group convos)
+	Profiles        map[string]Profile `json:"profiles,omitempty"`         // the room's participants that advertise a profile
+	PromptTemplates []string           `json:"prompt_templates,omitempty"` // prompt overrides, the server's then the room's; see package prompt
+	Charter         string             `json:"charter,omitempty"`          // the room's charter, which the prompt starts with
+	Agent           string             `json:"agent,omitempty"`            // which of the daemon's named agents to spawn; "" = the daemon itself
+}
+
+// MaxCharter is the longest room charter the server accepts.
+const MaxCharter = 8 * 1024
+
+// Charter is a room's ground rules: its goals, coding standards and do and
+// don't rules, which every spawn prompt in the room starts with. It is the
+// response of GET /api/rooms/{room}/charter and the body of PUT, where an
+// empty Text clears it.
+type Charter struct {
+	Text      string    `json:"text"`
+	UpdatedBy string    `json:"updated_by,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// HelpRequest is the body for POST /api/rooms/{room}/help: Sender asks the
+// server to spawn To's Claude with a task.
+type HelpRequest struct {
+	Sender string `json:"sender"`
+	To     string `json:"to"`
+	Task   string `json:"task"`
+	ConvID string `json:"conv_id,omitempty"` // generated if empty
+}
+
+// HelpResponse reports a delivered help request.
+type HelpResponse struct {
+	ConvID string `json:"conv_id"`
+	Seq    int64  `json:"seq"`
+}
+
+// Help request policies a watcher can advertise for its owner.
+const (
+	HelpAllow   = "allow"   // spawn for help requests like any directed message
+	HelpApprove = "approve" // ask the owner first
+	HelpDeny    = "deny"    // refuse help requests
+)
+
+// SpawnRecord is one entry in the spawn audit log.
+type SpawnRecord struct {
+	ID          string    `json:"id"`
+	Room        string    `json:"room"`
+	Sender      string    `json:"sender"` // owner of the spawned agent
+	ConvID      string    `json:"conv_id,omitempty"`
+	Backend     string    `json:"backend"`
+	TriggeredBy string    `json:"triggered_by"` // who caused the spawn
+	TriggerSeq  int64     `json:"trigger_seq,omitempty"`
+	Trigger     string    `json:"trigger,omitempty"` // trigger message text
+	Prompt      string    `json:"prompt"`
+	WorkDir     string    `json:"work_dir"`
+	StartedAt   time.Time `json:"started_at"`
+	DurationSec float64   `json:"duration_seconds"`
+	Status      string    `json:"status"` // ok, error, timeout, stopped
+	Error       string    `json:"error,omitempty"`
+	OutputBytes int       `json:"output_bytes"`
+}
+
+// SpawnList is the response for GET /api/rooms/{room}/spawns.
+type SpawnList struct {
+	Room   string        `json:"room"`
+	Spawns []SpawnRecord `json:"spawns"`
+	Count  int           `json:"count"`
+}
+
+// SpawnPreset is a canned prompt offered when asking a Claude. {input} in
+// Prompt is replaced by what the user typed; without it, the input follows
+// the prompt.
+type SpawnPreset struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description"`
+	Prompt      string `json:"prompt" yaml:"prompt"`
+	Model       string `json:"model,omitempty" yaml:"model"` // used unless the spawn picks one
+}
+
+// SpawnChoices is the response for GET /api/rooms/{room}/spawn/options:
+// what a spawn request may pick from.
+type SpawnChoices struct {
+	DefaultModel string        `json:"default_model,omitempty"` // "" = the agent's own default
+	Models       []string      `json:"models"`
+	Presets      []SpawnPreset `json:"presets"`
+	WorkDir      string        `json:"work_dir"`               // where a spawn runs unless it says otherwise
+	AllowedDirs  []string      `json:"allowed_dirs,omitempty"` // bases a work_dir may be under
+}
+
+// SessionInfo describes an active Claude session.
+type SessionInfo struct {
+	Sender    string    `json:"sender"`
+	ConvID    string    `json:"conv_id"` // "" for the owner's directly started session
+	StartedAt time.Time `json:"started_at"`
+	PID       int       `json:"pid,omitempty"`
+	Status    string    `json:"status"` // queued or running
+}
+
+// SessionList is the response for GET /api/rooms/{room}/sessions.
+type SessionList struct {
+	Room     string        `json:"room"`
+	Sessions []SessionInfo `json:"sessions"`
+}
+
+// DaemonHealth is a daemon's periodic status report.
+type DaemonHealth struct {
+	ReportedAt    time.Time `json:"reported_at"`
+	ActiveSpawns  int       `json:"active_spawns"`
+	MaxConcurrent int       `json:"max_concurrent"`
+	LastSpawnAt   time.Time `json:"last_spawn_at"`
+	LastResult    string    `json:"last_result,omitempty"` // ok, error, timeout
+	LastError     string    `json:"last_error,omitempty"`
+	ClaudeVersion string    `json:"claude_version,omitempty"`
 }
 
-// ParticipantInfo describes a connected participant.
+// ParticipantInfo describes a participant a room knows: one that has
+// connected or posted, whether or not it is still connected.
 type ParticipantInfo struct {
-	Name      string    `json:"name"`
-	Role      string    `json:"role"`
-	JoinedAt  time.Time `json:"joined_at"`
-	Connected bool      `json:"connected"`
+	Name      string        `json:"name"`
+	Role      string        `json:"role"`
+	JoinedAt  time.Time     `json:"joined_at"` // when the room first saw it
+	LastSeen  time.Time     `json:"last_seen"` // when it last connected, posted or left; now while connected
+	Messages  int           `json:"messages"`  // how many messages it has posted in the room
+	Connected bool          `json:"connected"`
+	Health    *DaemonHealth `json:"health,omitempty"`     // last report from a daemon
+	Profile   *Profile      `json:"profile,omitempty"`    // what it advertised when it connected
+	Owner     string        `json:"owner,omitempty"`      // whose Claude it is, for a Claude
+	SpeaksFor []string      `json:"speaks_for,omitempty"` // for a connected daemon, its picked Claude name and agents
 }
 
 // ParticipantList is the response for participant listing endpoints.
@@ -0,0 +1,42 @@
+package protocol
+
+import "time"
+
+// ExportVersion is the version of the room export format.
+const ExportVersion = 1
+
+// Kinds of ExportRecord.
+const (
+	ExportHeader  = "room"
+	ExportFile    = "file"
+	ExportMessage = "message"
+)
+
+// ExportRecord is one line of a room export: newline-delimited JSON served by
+// GET /api/rooms/{room}/export and accepted by POST /api/rooms/{room}/import.
+// An export is a header record, then a record per shared file, then a record
+// per message in seq order. Messages keep their IDs, seqs, timestamps and
+// metadata, so reply_to and file_id references survive the round trip.
+type ExportRecord struct {
+	Kind    string      `json:"kind"`
+	Header  *RoomExport `json:"room,omitempty"`
+	File    *FileInfo   `json:"file,omitempty"`
+	Data    []byte      `json:"data,omitempty"` // file contents, base64 in JSON
+	Message *Envelope   `json:"message,omitempty"`
+}
+
+// RoomExport describes the room an export was taken from.
+type RoomExport struct {
+	Version  int       `json:"version"`
+	Name     string    `json:"name"`
+	Exported time.Time `json:"exported"`
+	LastSeq  int64     `json:"last_seq"`
+}
+
+// ImportResult is the response for POST /api/rooms/{room}/import.
+type ImportResult struct {
+	Room     string `json:"room"`
+	Messages int    `json:"messages"`
+	Files    int    `json:"files"`
+	LastSeq  int64  `json:"last_seq"`
+}
@@ -1,14 +1,21 @@
 package protocol
 
-import "path/filepath"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
 
 // Payload carries the content of a message.
 type Payload struct {
-	Text     string `json:"text,omitempty"`
-	Code     string `json:"code,omitempty"`
-	Diff     string `json:"diff,omitempty"`
-	FilePath string `json:"file_path,omitempty"`
-	Language string `json:"language,omitempty"`
+	Text     string          `json:"text,omitempty"`
+	Code     string          `json:"code,omitempty"`
+	Diff     string          `json:"diff,omitempty"`
+	Data     json.RawMessage `json:"data,omitempty"` // structured data for TypeJSON
+	FilePath string          `json:"file_path,omitempty"`
+	Language string          `json:"language,omitempty"`
 }
 
 // Message types.
@@ -16,11 +23,55 @@ const (
 	TypeText   = "text"
 	TypeCode   = "code"
 	TypeDiff   = "diff"
+	TypeJSON   = "json" // machine-readable data in Payload.Data, e.g. test results
 	TypeSystem = "system"
 	TypeFile   = "file"
 	TypeSpawn  = "spawn"
 )
 
+// Message priorities, carried in the "priority" metadata field.
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal" // also what a message without one is
+	PriorityUrgent = "urgent"
+)
+
+// MessagePriority returns the priority in metadata, or PriorityNormal.
+func MessagePriority(metadata map[string]string) string {
+	switch p := metadata["priority"]; p {
+	case PriorityLow, PriorityUrgent:
+		return p
+	}
+	return PriorityNormal
+}
+
+// ValidPriority reports whether p is empty or a known priority.
+func ValidPriority(p string) bool {
+	return p == "" || p == PriorityLow || p == PriorityNormal || p == PriorityUrgent
+}
+
+// MetaMentions is the metadata field listing the names a message @-mentions,
+// comma-separated. The server fills it in from the text; see Mentions.
+const MetaMentions = "mentions"
+
+// Mentions returns the names listed in metadata's MetaMentions field.
+func Mentions(metadata map[string]string) []string {
+	if metadata[MetaMentions] == "" {
+		return nil
+	}
+	return strings.Split(metadata[MetaMentions], ",")
+}
+
+// Mentioned reports whether metadata lists name as mentioned, ignoring case.
+func Mentioned(metadata map[string]string, name string) bool {
+	for _, m := range Mentions(metadata) {
+		if strings.EqualFold(m, name) {
+			return true
+		}
+	}
+	return false
+}
+
 // NewTextPayload creates a payload for a plain text message.
 func NewTextPayload(text string) Payload {
 	return Payload{Text: text}
@@ -39,6 +90,25 @@ func NewDiffPayload(diff, filePath string) Payload {
 	return Payload{Diff: diff, FilePath: filePath}
 }
 
+// NewJSONPayload creates a payload for structured data. data must be valid
+// JSON; it is stored compacted.
+func NewJSONPayload(data []byte) (Payload, error) {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return Payload{}, fmt.Errorf("invalid JSON data: %w", err)
+	}
+	return Payload{Data: buf.Bytes()}, nil
+}
+
+// IndentData returns p.Data pretty-printed, or as-is if it won't indent.
+func (p Payload) IndentData() string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, p.Data, "", "  "); err != nil {
+		return string(p.Data)
+	}
+	return buf.String()
+}
+
 // DetectLanguage guesses a language from a file extension.
 func DetectLanguage(path string) string {
 	ext := filepath.Ext(path)
@@ -0,0 +1,140 @@
+package protocol
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MetaSealed marks a message whose payload is end-to-end encrypted; see
+// package e2e.
+const MetaSealed = "e2e"
+
+// Payload fields, as the fields query parameter names them.
+const (
+	FieldText = "text"
+	FieldCode = "code"
+	FieldDiff = "diff"
+	FieldData = "data"
+)
+
+// PayloadDigest stands in for the parts of a payload a response left out:
+// the SHA-256 and size of the whole payload's JSON, and the fields cut
+// short or dropped. GET /api/rooms/{room}/messages/{id} returns the whole
+// message.
+type PayloadDigest struct {
+	SHA256 string   `json:"sha256"`
+	Size   int      `json:"size"`
+	Cut    []string `json:"cut"`
+}
+
+// Cuts reports whether field was cut short or dropped. A nil digest cut
+// nothing.
+func (d *PayloadDigest) Cuts(field string) bool {
+	return d != nil && slices.Contains(d.Cut, field)
+}
+
+// PayloadOptions trims message payloads in history responses and spawn
+// contexts, for clients that only need the gist. The zero value keeps
+// payloads whole.
+type PayloadOptions struct {
+	Fields []string // payload fields to keep; empty keeps all
+	Max    int      // bytes of text, code and diff to keep, and the largest data kept; 0 = no limit
+}
+
+// PayloadOptionsFromQuery reads the fields and truncate_payloads query
+// parameters: fields=text,code keeps only those payload fields, and
+// truncate_payloads=4096 cuts each to 4096 bytes.
+func PayloadOptionsFromQuery(q url.Values) (PayloadOptions, error) {
+	var o PayloadOptions
+	for _, f := range strings.Split(q.Get("fields"), ",") {
+		switch f = strings.TrimSpace(f); f {
+		case "":
+		case FieldText, FieldCode, FieldDiff, FieldData:
+			o.Fields = append(o.Fields, f)
+		default:
+			return o, fmt.Errorf("unknown payload field %q: want %s, %s, %s or %s", f, FieldText, FieldCode, FieldDiff, FieldData)
+		}
+	}
+	if v := q.Get("truncate_payloads"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return o, fmt.Errorf("invalid truncate_payloads parameter")
+		}
+		o.Max = n
+	}
+	return o, nil
+}
+
+// AddQuery adds o to q, the query of a history request or WebSocket URL.
+func (o PayloadOptions) AddQuery(q url.Values) {
+	if len(o.Fields) > 0 {
+		q.Set("fields", strings.Join(o.Fields, ","))
+	}
+	if o.Max > 0 {
+		q.Set("truncate_payloads", strconv.Itoa(o.Max))
+	}
+}
+
+// Trim returns env with its payload cut down as o asks, and Digest set if
+// anything was cut. File paths and languages are always kept. Sealed
+// payloads stay whole, since only the whole ciphertext decrypts.
+func (o PayloadOptions) Trim(env Envelope) Envelope {
+	if (len(o.Fields) == 0 && o.Max <= 0) || env.Metadata[MetaSealed] != "" {
+		return env
+	}
+	p := env.Payload
+	var cut []string
+	trimText := func(field string, s *string) {
+		switch {
+		case *s == "":
+		case len(o.Fields) > 0 && !slices.Contains(o.Fields, field):
+			*s = ""
+			cut = append(cut, field)
+		case o.Max > 0 && len(*s) > o.Max:
+			*s = cutString(*s, o.Max)
+			cut = append(cut, field)
+		}
+	}
+	trimText(FieldText, &p.Text)
+	trimText(FieldCode, &p.Code)
+	trimText(FieldDiff, &p.Diff)
+	// Data can't be cut and stay JSON; too much of it is dropped.
+	if len(p.Data) > 0 && ((len(o.Fields) > 0 && !slices.Contains(o.Fields, FieldData)) || (o.Max > 0 && len(p.Data) > o.Max)) {
+		p.Data = nil
+		cut = append(cut, FieldData)
+	}
+	if cut == nil {
+		return env
+	}
+
+	whole, _ := json.Marshal(env.Payload)
+	sum := sha256.Sum256(whole)
+	env.Payload = p
+	env.Digest = &PayloadDigest{SHA256: hex.EncodeToString(sum[:]), Size: len(whole), Cut: cut}
+	return env
+}
+
+// TrimAll trims each of envs in place as Trim does.
+func (o PayloadOptions) TrimAll(envs []Envelope) {
+	for i := range envs {
+		envs[i] = o.Trim(envs[i])
+	}
+}
+
+// cutString cuts s to at most max bytes, on a rune boundary.
+func cutString(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
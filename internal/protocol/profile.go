@@ -0,0 +1,108 @@
+package protocol
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Profile is what a participant advertises about itself when it connects,
+// so Claudes can pick the right collaborator to converse with. Every field
+// is optional.
+type Profile struct {
+	Description  string `json:"description,omitempty"`  // e.g. "backend specialist"
+	WorkDir      string `json:"work_dir,omitempty"`     // the repo or directory its Claude works in
+	Model        string `json:"model,omitempty"`        // the model its Claude runs
+	Availability string `json:"availability,omitempty"` // one of the Availability constants
+}
+
+// Participant availabilities.
+const (
+	AvailabilityAvailable = "available"
+	AvailabilityBusy      = "busy"
+	AvailabilityAway      = "away"
+)
+
+// MaxDescription is the longest profile description the server accepts.
+const MaxDescription = 200
+
+// profileParams are the WebSocket URL query parameters a profile travels in.
+var profileParams = []string{"description", "work_dir", "model", "availability"}
+
+// IsZero reports whether p advertises nothing.
+func (p Profile) IsZero() bool {
+	return p == Profile{}
+}
+
+// Validate checks p's availability and description length.
+func (p Profile) Validate() error {
+	switch p.Availability {
+	case "", AvailabilityAvailable, AvailabilityBusy, AvailabilityAway:
+	default:
+		return fmt.Errorf("availability must be available, busy or away")
+	}
+	if len(p.Description) > MaxDescription {
+		return fmt.Errorf("description is longer than %d bytes", MaxDescription)
+	}
+	return nil
+}
+
+// AddQuery adds p's fields to q, the query of a WebSocket URL.
+func (p Profile) AddQuery(q url.Values) {
+	for i, v := range []string{p.Description, p.WorkDir, p.Model, p.Availability} {
+		if v != "" {
+			q.Set(profileParams[i], v)
+		}
+	}
+}
+
+// ProfileFromQuery reads the profile AddQuery put in q.
+func ProfileFromQuery(q url.Values) Profile {
+	return Profile{
+		Description:  q.Get("description"),
+		WorkDir:      q.Get("work_dir"),
+		Model:        q.Get("model"),
+		Availability: q.Get("availability"),
+	}
+}
+
+// String renders p on one line, e.g. "backend specialist; works in
+// ~/src/api; model opus; busy".
+func (p Profile) String() string {
+	var parts []string
+	if p.Description != "" {
+		parts = append(parts, p.Description)
+	}
+	if p.WorkDir != "" {
+		parts = append(parts, "works in "+p.WorkDir)
+	}
+	if p.Model != "" {
+		parts = append(parts, "model "+p.Model)
+	}
+	if p.Availability != "" {
+		parts = append(parts, p.Availability)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// DescribeProfiles renders the profiles of a spawn request for its prompt,
+// one "  • name: profile" line each in name order, leaving out self. It
+// returns "" when there is nobody to describe.
+func DescribeProfiles(profiles map[string]Profile, self string) string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		if name != self {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	slices.Sort(names)
+	var sb strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&sb, "  • %s: %s\n", name, profiles[name])
+	}
+	return sb.String()
+}
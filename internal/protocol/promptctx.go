@@ -0,0 +1,104 @@
+package protocol
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultPromptBudget is how many bytes of a spawn prompt FormatContext
+// spends on context messages by default, about 8k tokens.
+const DefaultPromptBudget = 32 * 1024
+
+// FormatContext renders a spawn request's context messages for its prompt,
+// one "[15:04:05] sender → to: text" entry each, oldest first, in at most
+// budget bytes (DefaultPromptBudget if budget <= 0). A payload longer than
+// an eighth of the budget is cut short with a marker naming the message, so
+// one pasted log can't crowd out the conversation. If the messages still
+// don't fit, the oldest are left out, bar the newest which always stays,
+// and a line summarizing them takes their place.
+func FormatContext(messages []Envelope, budget int) string {
+	if budget <= 0 {
+		budget = DefaultPromptBudget
+	}
+	entries := make([]string, len(messages))
+	for i, env := range messages {
+		entries[i] = contextEntry(env, budget/8)
+	}
+
+	first, used := len(entries), 0
+	for first > 0 && (first == len(entries) || used+len(entries[first-1]) <= budget) {
+		first--
+		used += len(entries[first])
+	}
+
+	var sb strings.Builder
+	if first > 0 {
+		sb.WriteString(elidedSummary(messages[:first]))
+	}
+	for _, e := range entries[first:] {
+		sb.WriteString(e)
+	}
+	return sb.String()
+}
+
+// contextEntry renders one context message, cutting its payload at max bytes.
+func contextEntry(env Envelope, max int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[%s] %s", env.Timestamp.Format("15:04:05"), env.Sender)
+	if to := env.Metadata["to"]; to != "" {
+		fmt.Fprintf(&sb, " → %s", to)
+	}
+	p := env.Payload
+	label := ""
+	if p.FilePath != "" {
+		label = " (" + p.FilePath + ")"
+	}
+	switch env.Type {
+	case TypeCode:
+		fmt.Fprintf(&sb, " shared code%s:\n```%s\n%s\n```", label, p.Language, truncate(p.Code, max, env, FieldCode))
+	case TypeDiff:
+		fmt.Fprintf(&sb, " shared diff%s:\n```diff\n%s\n```", label, truncate(p.Diff, max, env, FieldDiff))
+	case TypeJSON:
+		fmt.Fprintf(&sb, " shared data%s:\n```json\n%s\n```", label, truncate(p.IndentData(), max, env, FieldData))
+	default:
+		fmt.Fprintf(&sb, ": %s", truncate(p.Text, max, env, FieldText))
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// truncate cuts s, env's payload field, to at most max bytes, on a rune
+// boundary, and marks where to read the rest. A field the server already
+// cut is marked too.
+func truncate(s string, max int, env Envelope, field string) string {
+	if len(s) <= max && !env.Digest.Cuts(field) {
+		return s
+	}
+	return cutString(s, max) + fmt.Sprintf("\n… (truncated, fetch via get_messages seq=%d)", env.SeqNum)
+}
+
+// elidedSummary describes the context messages FormatContext left out.
+func elidedSummary(messages []Envelope) string {
+	var senders []string
+	counts := make(map[string]int)
+	for _, env := range messages {
+		if env.Type == TypeSystem {
+			continue
+		}
+		if counts[env.Sender] == 0 {
+			senders = append(senders, env.Sender)
+		}
+		counts[env.Sender]++
+	}
+	from := make([]string, len(senders))
+	for i, name := range senders {
+		from[i] = fmt.Sprintf("%s (%d)", name, counts[name])
+	}
+	first, last := messages[0], messages[len(messages)-1]
+	s := fmt.Sprintf("… %d earlier messages (#%d–#%d, %s–%s) left out to fit the prompt",
+		len(messages), first.SeqNum, last.SeqNum, first.Timestamp.Format("15:04"), last.Timestamp.Format("15:04"))
+	if len(from) > 0 {
+		s += ", from " + strings.Join(from, ", ")
+	}
+	return s + fmt.Sprintf(". Fetch them with get_messages(after=%d).\n", first.SeqNum-1)
+}
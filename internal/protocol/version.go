@@ -0,0 +1,61 @@
+package protocol
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// ProtocolVersion is the version of the wire protocol this build speaks:
+// the events, fields and endpoints the CLI and daemons rely on. Bump it when
+// the server starts sending something older clients would miss, such as a
+// new event type, and raise MinProtocol too when older clients would go
+// wrong rather than just miss out.
+//
+//	1  versioned handshake; spawn, message and claim events
+const ProtocolVersion = 1
+
+// MinProtocol is the oldest client protocol a server of this build serves.
+// It answers older clients that say so with 426 Upgrade Required; clients
+// that send no version, such as browsers and curl, are served.
+const MinProtocol = 1
+
+// ProtocolHeader carries the sender's ProtocolVersion on requests,
+// WebSocket dials and responses. Responses also carry MinProtocolHeader.
+const (
+	ProtocolHeader    = "X-Claudetalk-Protocol"
+	MinProtocolHeader = "X-Claudetalk-Min-Protocol"
+)
+
+// VersionHeader returns a header carrying this build's ProtocolVersion.
+func VersionHeader() http.Header {
+	h := http.Header{}
+	h.Set(ProtocolHeader, strconv.Itoa(ProtocolVersion))
+	return h
+}
+
+// HeaderProtocol returns the protocol version in h's key, or 0 if it has
+// none.
+func HeaderProtocol(h http.Header, key string) int {
+	n, _ := strconv.Atoi(h.Get(key))
+	return n
+}
+
+// CheckServer compares the protocol a server's response h reports with
+// this build's. It returns an error when the server no longer serves this
+// build, and a warning when either side is newer, so some events or
+// features go unused.
+func CheckServer(h http.Header) (warning string, err error) {
+	server, min := HeaderProtocol(h, ProtocolHeader), HeaderProtocol(h, MinProtocolHeader)
+	switch {
+	case min > ProtocolVersion:
+		return "", fmt.Errorf("the server needs protocol %d or later and this claudetalk speaks %d; run \"claudetalk self-update\"", min, ProtocolVersion)
+	case server == 0:
+		return "the server predates protocol versions; update it, or some features of this claudetalk won't work", nil
+	case server > ProtocolVersion:
+		return fmt.Sprintf("the server speaks protocol %d and this claudetalk %d, so it may send events this one ignores; run \"claudetalk self-update\"", server, ProtocolVersion), nil
+	case server < ProtocolVersion:
+		return fmt.Sprintf("the server speaks protocol %d and this claudetalk %d; some features won't work until the server is updated", server, ProtocolVersion), nil
+	}
+	return "", nil
+}
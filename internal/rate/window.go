@@ -0,0 +1,70 @@
+// Package rate counts outcomes over a sliding window, for the recent error
+// rates health checks report.
+package rate
+
+import (
+	"sync"
+	"time"
+)
+
+// windowBuckets is how many slices a Window's span is counted in; outcomes
+// age out one slice at a time.
+const windowBuckets = 10
+
+// Window counts outcomes, and how many of them failed, over the last Span.
+// A nil Window counts nothing.
+type Window struct {
+	Span time.Duration
+
+	mu      sync.Mutex
+	buckets [windowBuckets]bucket
+}
+
+type bucket struct {
+	start  time.Time
+	total  int
+	failed int
+}
+
+// NewWindow returns a window over the last span.
+func NewWindow(span time.Duration) *Window {
+	return &Window{Span: span}
+}
+
+// Add counts an outcome.
+func (w *Window) Add(failed bool) {
+	if w == nil {
+		return
+	}
+	slice := w.Span / windowBuckets
+	now := time.Now().Truncate(slice)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	b := &w.buckets[now.UnixNano()/int64(slice)%windowBuckets]
+	if !b.start.Equal(now) {
+		*b = bucket{start: now}
+	}
+	b.total++
+	if failed {
+		b.failed++
+	}
+}
+
+// Counts returns how many outcomes were counted within the window and how
+// many of them failed.
+func (w *Window) Counts() (total, failed int) {
+	if w == nil {
+		return 0, 0
+	}
+	slice := w.Span / windowBuckets
+	oldest := time.Now().Truncate(slice).Add(-slice * (windowBuckets - 1))
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, b := range w.buckets {
+		if !b.start.Before(oldest) {
+			total += b.total
+			failed += b.failed
+		}
+	}
+	return total, failed
+}
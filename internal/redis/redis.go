@@ -0,0 +1,282 @@
+// Package redis is a minimal Redis client: commands and pub/sub over RESP2,
+// just enough for server instances to share rooms. It holds one connection
+// for commands, redialled after errors, and one per subscription.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Error is an error reply from the server.
+type Error string
+
+func (e Error) Error() string { return "redis: " + string(e) }
+
+// Client talks to one Redis server.
+type Client struct {
+	addr     string
+	username string
+	password string
+	db       int
+
+	mu   sync.Mutex
+	conn *conn // nil until dialled, and after an I/O error
+}
+
+// New returns a client for a redis://[user:password@]host[:port][/db] URL.
+// It does not connect until the first command.
+func New(rawURL string) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "redis" {
+		return nil, fmt.Errorf("redis URL must start with redis://, got %q", rawURL)
+	}
+	c := &Client{addr: u.Host}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		c.password, _ = u.User.Password()
+		if c.password != "" {
+			c.username = u.User.Username()
+		} else {
+			c.password = u.User.Username() // redis://:password@ or redis://password@
+		}
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("redis URL database %q is not a number", db)
+		}
+	}
+	return c, nil
+}
+
+// Do runs a command and returns its reply: a string for simple and bulk
+// strings, int64 for integers, []any for arrays and nil for nil replies.
+// Error replies are returned as Error.
+func (c *Client) Do(args ...string) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for attempt := 0; ; attempt++ {
+		if c.conn == nil {
+			cn, err := c.dial()
+			if err != nil {
+				return nil, err
+			}
+			c.conn = cn
+		}
+		reply, err := c.conn.do(args...)
+		var rerr Error
+		if err == nil || errors.As(err, &rerr) {
+			return reply, err
+		}
+		// The connection is broken; redial once, for a server restart.
+		c.conn.Close()
+		c.conn = nil
+		if attempt > 0 {
+			return nil, err
+		}
+	}
+}
+
+// Int runs a command that replies with an integer.
+func (c *Client) Int(args ...string) (int64, error) {
+	reply, err := c.Do(args...)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis: %s: want an integer reply, got %T", args[0], reply)
+	}
+	return n, nil
+}
+
+// Strings runs a command that replies with an array of strings.
+func (c *Client) Strings(args ...string) ([]string, error) {
+	reply, err := c.Do(args...)
+	if err != nil || reply == nil {
+		return nil, err
+	}
+	arr, ok := reply.([]any)
+	if !ok {
+		return nil, fmt.Errorf("redis: %s: want an array reply, got %T", args[0], reply)
+	}
+	out := make([]string, 0, len(arr))
+	for _, v := range arr {
+		s, _ := v.(string)
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// Close closes the command connection.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// Subscribe calls fn with every message published on channel until ctx is
+// done, reconnecting with backoff when the connection drops. ready, if not
+// nil, is called each time the subscription is (re)established.
+func (c *Client) Subscribe(ctx context.Context, channel string, ready func(), fn func(payload []byte)) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		err := c.subscribeOnce(ctx, channel, ready, fn)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("redis: subscription to %s lost: %v; retrying in %s", channel, err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+func (c *Client) subscribeOnce(ctx context.Context, channel string, ready func(), fn func([]byte)) error {
+	cn, err := c.dial()
+	if err != nil {
+		return err
+	}
+	defer cn.Close()
+	stop := context.AfterFunc(ctx, func() { cn.Close() })
+	defer stop()
+
+	if err := cn.write("SUBSCRIBE", channel); err != nil {
+		return err
+	}
+	if _, err := cn.read(); err != nil { // the subscribe confirmation
+		return err
+	}
+	if ready != nil {
+		ready()
+	}
+	for {
+		reply, err := cn.read()
+		if err != nil {
+			return err
+		}
+		msg, ok := reply.([]any)
+		if !ok || len(msg) != 3 || msg[0] != "message" {
+			continue
+		}
+		payload, _ := msg[2].(string)
+		fn([]byte(payload))
+	}
+}
+
+// dial connects, authenticates and selects the database.
+func (c *Client) dial() (*conn, error) {
+	nc, err := net.DialTimeout("tcp", c.addr, 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc)}
+	if c.password != "" {
+		args := []string{"AUTH", c.password}
+		if c.username != "" {
+			args = []string{"AUTH", c.username, c.password}
+		}
+		if _, err := cn.do(args...); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	if c.db != 0 {
+		if _, err := cn.do("SELECT", strconv.Itoa(c.db)); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+// conn is one RESP2 connection.
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (cn *conn) do(args ...string) (any, error) {
+	if err := cn.write(args...); err != nil {
+		return nil, err
+	}
+	return cn.read()
+}
+
+func (cn *conn) write(args ...string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	_, err := io.WriteString(cn.Conn, b.String())
+	return err
+}
+
+func (cn *conn) read() (any, error) {
+	line, err := cn.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, rest := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return rest, nil
+	case '-':
+		return nil, Error(rest)
+	case ':':
+		return strconv.ParseInt(rest, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(rest)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(cn.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(rest)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		arr := make([]any, n)
+		for i := range arr {
+			if arr[i], err = cn.read(); err != nil {
+				var rerr Error
+				if !errors.As(err, &rerr) {
+					return nil, err
+				}
+			}
+		}
+		return arr, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
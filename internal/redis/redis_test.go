@@ -0,0 +1,218 @@
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// replyConn is a conn that reads the given server replies.
+func replyConn(replies string) *conn {
+	return &conn{r: bufio.NewReader(strings.NewReader(replies))}
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		want  any
+	}{
+		{"simple string", "+OK\r\n", "OK"},
+		{"empty simple string", "+\r\n", ""},
+		{"integer", ":42\r\n", int64(42)},
+		{"negative integer", ":-7\r\n", int64(-7)},
+		{"bulk string", "$5\r\nhello\r\n", "hello"},
+		{"bulk string with CRLF inside", "$7\r\na\r\nb\r\nc\r\n", "a\r\nb\r\nc"},
+		{"empty bulk string", "$0\r\n\r\n", ""},
+		{"nil bulk string", "$-1\r\n", nil},
+		{"array", "*3\r\n$7\r\nmessage\r\n$2\r\nch\r\n:1\r\n", []any{"message", "ch", int64(1)}},
+		{"empty array", "*0\r\n", []any{}},
+		{"nil array", "*-1\r\n", nil},
+		{"nested array", "*2\r\n*1\r\n+a\r\n$-1\r\n", []any{[]any{"a"}, nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := replyConn(tt.reply).read()
+			if err != nil {
+				t.Fatalf("read(%q): %v", tt.reply, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("read(%q) = %#v, want %#v", tt.reply, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadErrorReply(t *testing.T) {
+	_, err := replyConn("-ERR unknown command\r\n").read()
+	var rerr Error
+	if !errors.As(err, &rerr) || string(rerr) != "ERR unknown command" {
+		t.Fatalf("read = %v, want Error(ERR unknown command)", err)
+	}
+}
+
+func TestReadArrayKeepsErrorElements(t *testing.T) {
+	// An error inside an array, as EXEC replies, leaves a nil element and
+	// the rest of the array readable.
+	cn := replyConn("*2\r\n-WRONGTYPE bad\r\n:3\r\n+next\r\n")
+	got, err := cn.read()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if want := []any{nil, int64(3)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("read = %#v, want %#v", got, want)
+	}
+	if next, err := cn.read(); err != nil || next != "next" {
+		t.Errorf("next read = %v, %v; want the reply after the array", next, err)
+	}
+}
+
+func TestReadMalformed(t *testing.T) {
+	for _, reply := range []string{
+		"OK\n",            // no CRLF
+		"+OK",             // truncated
+		"?what\r\n",       // unknown type
+		":notanumber\r\n", // bad integer
+		"$x\r\n",          // bad length
+		"$5\r\nhi\r\n",    // bulk string shorter than its length
+		"*2\r\n+one\r\n",  // array shorter than its length
+	} {
+		if got, err := replyConn(reply).read(); err == nil {
+			t.Errorf("read(%q) = %#v, want an error", reply, got)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	cn := &conn{Conn: client}
+	go func() {
+		cn.write("SET", "key", "two words")
+		client.Close()
+	}()
+	got, err := io.ReadAll(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$9\r\ntwo words\r\n"
+	if string(got) != want {
+		t.Errorf("write sent %q, want %q", got, want)
+	}
+}
+
+// fakeServer serves one connection, answering each command with the next of
+// replies, and records the commands it read.
+func fakeServer(t *testing.T, replies ...string) (addr string, commands <-chan []string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	ch := make(chan []string, len(replies))
+	go func() {
+		nc, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer nc.Close()
+		cn := &conn{Conn: nc, r: bufio.NewReader(nc)}
+		for _, reply := range replies {
+			cmd, err := cn.read()
+			if err != nil {
+				return
+			}
+			var args []string
+			for _, a := range cmd.([]any) {
+				args = append(args, a.(string))
+			}
+			ch <- args
+			if _, err := io.WriteString(nc, reply); err != nil {
+				return
+			}
+		}
+	}()
+	return ln.Addr().String(), ch
+}
+
+func TestClientAuthSelectAndInt(t *testing.T) {
+	addr, commands := fakeServer(t, "+OK\r\n", "+OK\r\n", ":5\r\n")
+	c, err := New("redis://user:secret@" + addr + "/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	n, err := c.Int("INCR", "seq")
+	if err != nil || n != 5 {
+		t.Fatalf("Int = %d, %v; want 5", n, err)
+	}
+	for _, want := range [][]string{{"AUTH", "user", "secret"}, {"SELECT", "2"}, {"INCR", "seq"}} {
+		if got := <-commands; !reflect.DeepEqual(got, want) {
+			t.Errorf("server read %q, want %q", got, want)
+		}
+	}
+}
+
+func TestClientStrings(t *testing.T) {
+	addr, _ := fakeServer(t, "*2\r\n$1\r\na\r\n$1\r\nb\r\n", "*-1\r\n")
+	c, err := New("redis://" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	got, err := c.Strings("LRANGE", "k", "0", "-1")
+	if err != nil || !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("Strings = %q, %v; want [a b]", got, err)
+	}
+	if got, err := c.Strings("LRANGE", "missing", "0", "-1"); err != nil || got != nil {
+		t.Errorf("Strings of a nil reply = %q, %v; want nil", got, err)
+	}
+}
+
+func TestClientIntWrongType(t *testing.T) {
+	addr, _ := fakeServer(t, "+OK\r\n")
+	c, err := New("redis://" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Int("SET", "k", "v"); err == nil {
+		t.Error("Int of a string reply succeeded")
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		url                  string
+		addr, user, password string
+		db                   int
+	}{
+		{"redis://localhost", "localhost:6379", "", "", 0},
+		{"redis://h:6380/3", "h:6380", "", "", 3},
+		{"redis://:pw@h", "h:6379", "", "pw", 0},
+		{"redis://pw@h", "h:6379", "", "pw", 0},
+		{"redis://u:pw@h", "h:6379", "u", "pw", 0},
+	}
+	for _, tt := range tests {
+		c, err := New(tt.url)
+		if err != nil {
+			t.Errorf("New(%q): %v", tt.url, err)
+			continue
+		}
+		if c.addr != tt.addr || c.username != tt.user || c.password != tt.password || c.db != tt.db {
+			t.Errorf("New(%q) = %s %q %q db %d, want %s %q %q db %d", tt.url, c.addr, c.username, c.password, c.db, tt.addr, tt.user, tt.password, tt.db)
+		}
+	}
+	for _, bad := range []string{"http://h", "redis://h/db"} {
+		if _, err := New(bad); err == nil {
+			t.Errorf("New(%q) succeeded", bad)
+		}
+	}
+}
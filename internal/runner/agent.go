@@ -0,0 +1,110 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+
+	"github.com/corvino/claudetalk/internal/limits"
+)
+
+// AgentBackend runs one agent turn for a spawn. The runner handles queueing,
+// timeouts, session bookkeeping and posting the result; a backend only turns
+// a Job into a reply.
+type AgentBackend interface {
+	// Name identifies the backend in logs and flags.
+	Name() string
+	// Run executes the job, blocking until the agent finishes or ctx is done.
+	Run(ctx context.Context, job Job) (Result, error)
+}
+
+// Checker is implemented by backends that can tell whether they are able
+// to run, for the server's readiness check.
+type Checker interface {
+	// Check returns why the backend can't run agents, or nil.
+	Check() error
+}
+
+// Job is the input to a single agent run.
+type Job struct {
+	Prompt    string
+	WorkDir   string
+	MCPConfig string  // path to an --mcp-config file wiring the agent to the room
+	Options   Options // merged per-spawn and default options
+	ResumeID  string  // session to continue, if the backend supports it
+	Env       []string
+	Progress  func(text string) // reports tool calls and partial output; never nil
+	OnStart   func(pid int)     // called once the agent process is running; never nil
+}
+
+// Result is an agent's final reply.
+type Result struct {
+	Text      string // posted to the owner as a whisper if non-empty
+	SessionID string // recorded for ResumeID on the thread's next spawn
+}
+
+// BackendConfig carries what the built-in backends need to be constructed.
+type BackendConfig struct {
+	ClaudeBin string        // claude: path to the CLI; looked up if empty
+	Command   string        // command: shell command line to run
+	Limits    limits.Limits // claude, command: process resource limits
+
+	// Sandbox selects where claude and command agents run: SandboxNone (on
+	// the host) or SandboxDocker. SandboxImage overrides DefaultSandboxImage.
+	Sandbox      string
+	SandboxImage string
+}
+
+// sandboxFor returns the sandbox cfg selects, or nil to run on the host.
+func (cfg BackendConfig) sandboxFor() (*DockerSandbox, error) {
+	switch cfg.Sandbox {
+	case SandboxNone, "none":
+		return nil, nil
+	case SandboxDocker:
+		if _, err := exec.LookPath("docker"); err != nil {
+			return nil, fmt.Errorf("docker sandbox: %w", err)
+		}
+		return &DockerSandbox{Image: cfg.SandboxImage}, nil
+	}
+	return nil, fmt.Errorf("unknown sandbox %q (want none or docker)", cfg.Sandbox)
+}
+
+// agentCommand builds the process for a local agent: bin with args, on the
+// host under l or inside sandbox if non-nil. env entries are added to the
+// job's environment. The returned cleanup func must be called after exit.
+func agentCommand(ctx context.Context, sandbox *DockerSandbox, l limits.Limits, job Job, env []string, bin string, args []string) (*exec.Cmd, func(), error) {
+	if sandbox != nil {
+		return sandbox.command(ctx, job, l, env, bin, args)
+	}
+	bin, args = limits.Wrap(l, bin, args)
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Dir = job.WorkDir
+	cmd.Env = append(append([]string(nil), job.Env...), env...)
+	return cmd, func() {}, nil
+}
+
+var backends = map[string]func(BackendConfig) (AgentBackend, error){}
+
+func registerBackend(name string, fn func(BackendConfig) (AgentBackend, error)) {
+	backends[name] = fn
+}
+
+// NewBackend constructs the named built-in backend.
+func NewBackend(name string, cfg BackendConfig) (AgentBackend, error) {
+	fn, ok := backends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown agent backend %q (available: %v)", name, Backends())
+	}
+	return fn(cfg)
+}
+
+// Backends returns the names of the built-in backends, sorted.
+func Backends() []string {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
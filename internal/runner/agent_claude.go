@@ -0,0 +1,116 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/corvino/claudetalk/internal/fakeclaude"
+	"github.com/corvino/claudetalk/internal/limits"
+)
+
+func init() {
+	registerBackend("claude", func(cfg BackendConfig) (AgentBackend, error) {
+		sandbox, err := cfg.sandboxFor()
+		if err != nil {
+			return nil, err
+		}
+		c := NewClaudeCLI(cfg.ClaudeBin, cfg.Limits)
+		if sandbox != nil {
+			c.bin = "claude" // the image's copy, not the host's
+			c.sandbox = sandbox
+		}
+		return c, nil
+	})
+}
+
+// ClaudeCLI runs the claude CLI in print mode with MCP tools, streaming its
+// progress. It is the default backend.
+type ClaudeCLI struct {
+	bin     string
+	limits  limits.Limits
+	sandbox *DockerSandbox // nil runs on the host
+}
+
+// NewClaudeCLI creates a claude CLI backend. An empty bin is looked up in
+// PATH and then in common install locations.
+func NewClaudeCLI(bin string, l limits.Limits) *ClaudeCLI {
+	if bin == "" {
+		bin = findClaude()
+		log.Printf("runner: using claude binary: %s", bin)
+	}
+	return &ClaudeCLI{bin: bin, limits: l}
+}
+
+// Name implements AgentBackend.
+func (c *ClaudeCLI) Name() string { return "claude" }
+
+// Check implements Checker: the claude binary must exist, or docker for a
+// sandbox.
+func (c *ClaudeCLI) Check() error {
+	bin, _ := fakeclaude.Command(c.bin, nil)
+	if c.sandbox != nil {
+		bin = "docker"
+	}
+	_, err := exec.LookPath(bin)
+	return err
+}
+
+// Run implements AgentBackend.
+func (c *ClaudeCLI) Run(ctx context.Context, job Job) (Result, error) {
+	args := []string{
+		"--mcp-config", job.MCPConfig,
+		"--print",
+		"--output-format", "stream-json",
+		"--verbose", // required by the CLI for stream-json in --print mode
+	}
+	args = append(args, job.Options.args()...)
+	if job.ResumeID != "" {
+		args = append(args, "--resume", job.ResumeID)
+	}
+
+	bin, args := fakeclaude.Command(c.bin, args)
+	cmd, cleanup, err := agentCommand(ctx, c.sandbox, c.limits, job, nil, bin, args)
+	if err != nil {
+		return Result{}, err
+	}
+	defer cleanup()
+	cmd.Stdin = strings.NewReader(job.Prompt)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return Result{}, fmt.Errorf("claude stdout: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return Result{}, fmt.Errorf("start claude: %w", err)
+	}
+	job.OnStart(cmd.Process.Pid)
+	res := streamProgress(stdout, job.Progress)
+	if err := cmd.Wait(); err != nil {
+		return Result{}, fmt.Errorf("claude exited with error: %w", err)
+	}
+	return res, nil
+}
+
+// findClaude locates the claude CLI, falling back to "claude".
+func findClaude() string {
+	if path, err := exec.LookPath("claude"); err == nil {
+		return path
+	}
+	home, _ := os.UserHomeDir()
+	candidates := []string{
+		filepath.Join(home, ".local", "bin", "claude"),
+		filepath.Join(home, ".local", "bin", "claude.exe"),
+	}
+	for _, c := range candidates {
+		if _, err := os.Stat(c); err == nil {
+			return c
+		}
+	}
+	return "claude"
+}
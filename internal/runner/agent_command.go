@@ -0,0 +1,100 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/corvino/claudetalk/internal/limits"
+)
+
+func init() {
+	registerBackend("command", func(cfg BackendConfig) (AgentBackend, error) {
+		if cfg.Command == "" {
+			return nil, fmt.Errorf("the command backend needs a command line (--agent-cmd)")
+		}
+		sandbox, err := cfg.sandboxFor()
+		if err != nil {
+			return nil, err
+		}
+		c := NewCommand(cfg.Command, cfg.Limits)
+		c.sandbox = sandbox
+		return c, nil
+	})
+}
+
+// Command runs an arbitrary shell command as the agent — an OpenAI-compatible
+// CLI, a local model wrapper, or a custom script. The prompt is written to
+// stdin and stdout is the reply. The command also gets:
+//
+//	CLAUDETALK_MCP_CONFIG  path to an MCP config for the room's tools
+//	CLAUDETALK_MODEL       requested model, if any
+//	CLAUDETALK_MAX_TURNS   requested max turns, if any
+//	CLAUDETALK_SESSION_ID  session to resume, if any
+//
+// A stdout line of the form "CLAUDETALK_SESSION_ID=<id>" is stripped from the
+// reply and recorded for the thread's next spawn.
+type Command struct {
+	cmdline string
+	limits  limits.Limits
+	sandbox *DockerSandbox // nil runs on the host
+}
+
+// NewCommand creates a backend that runs cmdline through the system shell.
+func NewCommand(cmdline string, l limits.Limits) *Command {
+	return &Command{cmdline: cmdline, limits: l}
+}
+
+// Name implements AgentBackend.
+func (c *Command) Name() string { return "command" }
+
+// Run implements AgentBackend.
+func (c *Command) Run(ctx context.Context, job Job) (Result, error) {
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" && c.sandbox == nil {
+		shell, flag = "cmd", "/C"
+	}
+	env := []string{
+		"CLAUDETALK_MCP_CONFIG=" + job.MCPConfig,
+		"CLAUDETALK_MODEL=" + job.Options.Model,
+		"CLAUDETALK_SESSION_ID=" + job.ResumeID,
+	}
+	if job.Options.MaxTurns > 0 {
+		env = append(env, "CLAUDETALK_MAX_TURNS="+strconv.Itoa(job.Options.MaxTurns))
+	}
+
+	cmd, cleanup, err := agentCommand(ctx, c.sandbox, c.limits, job, env, shell, []string{flag, c.cmdline})
+	if err != nil {
+		return Result{}, err
+	}
+	defer cleanup()
+	cmd.Stdin = strings.NewReader(job.Prompt)
+	cmd.Stderr = os.Stderr
+
+	var stdout bytes.Buffer
+	cmd.Stdout = io.MultiWriter(&stdout, os.Stderr)
+	if err := cmd.Start(); err != nil {
+		return Result{}, fmt.Errorf("start agent command: %w", err)
+	}
+	job.OnStart(cmd.Process.Pid)
+	if err := cmd.Wait(); err != nil {
+		return Result{}, fmt.Errorf("agent command exited with error: %w", err)
+	}
+
+	var res Result
+	var lines []string
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		if id, ok := strings.CutPrefix(line, "CLAUDETALK_SESSION_ID="); ok {
+			res.SessionID = strings.TrimSpace(id)
+			continue
+		}
+		lines = append(lines, line)
+	}
+	res.Text = strings.Join(lines, "\n")
+	return res, nil
+}
@@ -0,0 +1,211 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+func init() {
+	registerBackend("anthropic", func(BackendConfig) (AgentBackend, error) {
+		key := os.Getenv("ANTHROPIC_API_KEY")
+		if key == "" {
+			return nil, fmt.Errorf("the anthropic backend needs ANTHROPIC_API_KEY")
+		}
+		return NewAnthropicAPI(key, os.Getenv("ANTHROPIC_BASE_URL")), nil
+	})
+	registerBackend("openai", func(BackendConfig) (AgentBackend, error) {
+		key := os.Getenv("OPENAI_API_KEY")
+		if key == "" {
+			return nil, fmt.Errorf("the openai backend needs OPENAI_API_KEY")
+		}
+		return NewOpenAIAPI(key, os.Getenv("OPENAI_BASE_URL")), nil
+	})
+}
+
+// apiSystemPrompt tells tool-less API agents how their reply is used.
+const apiSystemPrompt = "You are taking part in a ClaudeTalk chat room. You cannot call tools: " +
+	"ignore any instructions about MCP tools and answer directly in text. " +
+	"Your reply is posted to the chat for the user who asked."
+
+// chatMessage is one turn of a chat-style API conversation.
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// chatHistory keeps API conversations in memory so a thread's later spawns
+// can resume them, mirroring claude --resume.
+type chatHistory struct {
+	mu       sync.Mutex
+	sessions map[string][]chatMessage
+}
+
+func (h *chatHistory) load(id string) []chatMessage {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]chatMessage(nil), h.sessions[id]...)
+}
+
+func (h *chatHistory) save(msgs []chatMessage) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.sessions == nil {
+		h.sessions = make(map[string][]chatMessage)
+	}
+	id := uuid.New().String()
+	h.sessions[id] = msgs
+	return id
+}
+
+// AnthropicAPI answers with a single call to the Anthropic Messages API.
+type AnthropicAPI struct {
+	apiKey  string
+	baseURL string
+	history chatHistory
+}
+
+// NewAnthropicAPI creates a Messages API backend. baseURL defaults to
+// https://api.anthropic.com.
+func NewAnthropicAPI(apiKey, baseURL string) *AnthropicAPI {
+	if baseURL == "" {
+		baseURL = "https://api.anthropic.com"
+	}
+	return &AnthropicAPI{apiKey: apiKey, baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// Name implements AgentBackend.
+func (a *AnthropicAPI) Name() string { return "anthropic" }
+
+// anthropicModels maps the claude CLI's model aliases to API model IDs.
+var anthropicModels = map[string]string{
+	"":       "claude-sonnet-4-5",
+	"sonnet": "claude-sonnet-4-5",
+	"haiku":  "claude-haiku-4-5",
+	"opus":   "claude-opus-4-1",
+}
+
+// Run implements AgentBackend.
+func (a *AnthropicAPI) Run(ctx context.Context, job Job) (Result, error) {
+	model := job.Options.Model
+	if id, ok := anthropicModels[model]; ok {
+		model = id
+	}
+	msgs := append(a.history.load(job.ResumeID), chatMessage{Role: "user", Content: job.Prompt})
+
+	var resp struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	err := postJSON(ctx, a.baseURL+"/v1/messages", map[string]string{
+		"x-api-key":         a.apiKey,
+		"anthropic-version": "2023-06-01",
+	}, map[string]any{
+		"model":      model,
+		"max_tokens": 4096,
+		"system":     apiSystemPrompt,
+		"messages":   msgs,
+	}, &resp)
+	if err != nil {
+		return Result{}, fmt.Errorf("anthropic: %w", err)
+	}
+
+	var text strings.Builder
+	for _, c := range resp.Content {
+		if c.Type == "text" {
+			text.WriteString(c.Text)
+		}
+	}
+	msgs = append(msgs, chatMessage{Role: "assistant", Content: text.String()})
+	return Result{Text: text.String(), SessionID: a.history.save(msgs)}, nil
+}
+
+// OpenAIAPI answers with a single call to an OpenAI-compatible chat
+// completions endpoint (OpenAI, or local servers such as Ollama or vLLM).
+type OpenAIAPI struct {
+	apiKey  string
+	baseURL string
+	history chatHistory
+}
+
+// NewOpenAIAPI creates a chat completions backend. baseURL defaults to
+// https://api.openai.com/v1.
+func NewOpenAIAPI(apiKey, baseURL string) *OpenAIAPI {
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+	return &OpenAIAPI{apiKey: apiKey, baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// Name implements AgentBackend.
+func (o *OpenAIAPI) Name() string { return "openai" }
+
+// Run implements AgentBackend.
+func (o *OpenAIAPI) Run(ctx context.Context, job Job) (Result, error) {
+	model := job.Options.Model
+	if model == "" {
+		model = "gpt-4o-mini"
+	}
+	history := append(o.history.load(job.ResumeID), chatMessage{Role: "user", Content: job.Prompt})
+	msgs := append([]chatMessage{{Role: "system", Content: apiSystemPrompt}}, history...)
+
+	var resp struct {
+		Choices []struct {
+			Message chatMessage `json:"message"`
+		} `json:"choices"`
+	}
+	err := postJSON(ctx, o.baseURL+"/chat/completions", map[string]string{
+		"Authorization": "Bearer " + o.apiKey,
+	}, map[string]any{
+		"model":    model,
+		"messages": msgs,
+	}, &resp)
+	if err != nil {
+		return Result{}, fmt.Errorf("openai: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return Result{}, fmt.Errorf("openai: response had no choices")
+	}
+
+	reply := resp.Choices[0].Message.Content
+	history = append(history, chatMessage{Role: "assistant", Content: reply})
+	return Result{Text: reply, SessionID: o.history.save(history)}, nil
+}
+
+// postJSON POSTs body as JSON with the given headers and decodes a 2xx
+// response into out.
+func postJSON(ctx context.Context, url string, headers map[string]string, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
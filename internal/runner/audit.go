@@ -0,0 +1,110 @@
+package runner
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// maxAuditPrompt truncates prompts stored in the audit log; hook prompts
+// carry the whole conversation context.
+const maxAuditPrompt = 4000
+
+// AuditLog records every spawn to an append-only JSON Lines file and keeps
+// the most recent records in memory for listing. A nil *AuditLog discards
+// records.
+type AuditLog struct {
+	mu      sync.Mutex
+	file    *os.File
+	records []protocol.SpawnRecord
+	keep    int
+}
+
+// OpenAuditLog opens (or creates) the audit log at path, loading up to keep
+// of its most recent records.
+func OpenAuditLog(path string, keep int) (*AuditLog, error) {
+	a := &AuditLog{keep: keep}
+
+	if f, err := os.Open(path); err == nil {
+		sc := bufio.NewScanner(f)
+		sc.Buffer(make([]byte, 64*1024), 1024*1024)
+		for sc.Scan() {
+			var rec protocol.SpawnRecord
+			if json.Unmarshal(sc.Bytes(), &rec) == nil {
+				a.add(rec)
+			}
+		}
+		f.Close()
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	a.file = f
+	return a, nil
+}
+
+func (a *AuditLog) add(rec protocol.SpawnRecord) {
+	a.records = append(a.records, rec)
+	if len(a.records) > a.keep {
+		a.records = a.records[len(a.records)-a.keep:]
+	}
+}
+
+// Append records a finished spawn.
+func (a *AuditLog) Append(rec protocol.SpawnRecord) {
+	if a == nil {
+		return
+	}
+	if len(rec.Prompt) > maxAuditPrompt {
+		rec.Prompt = truncate(rec.Prompt, maxAuditPrompt)
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.add(rec)
+	if _, err := a.file.Write(append(data, '\n')); err != nil {
+		log.Printf("runner: write audit log: %v", err)
+	}
+}
+
+// List returns up to limit of the room's most recent spawns, newest first.
+// limit <= 0 means all retained records.
+func (a *AuditLog) List(room string, limit int) []protocol.SpawnRecord {
+	out := []protocol.SpawnRecord{}
+	if a == nil {
+		return out
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i := len(a.records) - 1; i >= 0; i-- {
+		if a.records[i].Room != room {
+			continue
+		}
+		out = append(out, a.records[i])
+		if limit > 0 && len(out) == limit {
+			break
+		}
+	}
+	return out
+}
+
+// Close closes the underlying file.
+func (a *AuditLog) Close() error {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.file.Close()
+}
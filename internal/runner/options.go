@@ -0,0 +1,74 @@
+package runner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Options are Claude CLI settings for a spawn. Zero fields fall back to the
+// runner's defaults, and then to the CLI's own defaults.
+type Options struct {
+	Model          string   `json:"model,omitempty"`           // e.g. "haiku", "opus", or a full model ID
+	MaxTurns       int      `json:"max_turns,omitempty"`       // cap on agentic turns
+	AllowedTools   []string `json:"allowed_tools,omitempty"`   // e.g. ["Read", "Bash(git:*)"]
+	PermissionMode string   `json:"permission_mode,omitempty"` // see PermissionModes; default skips all prompts
+}
+
+// PermissionModes lists the values accepted for Options.PermissionMode.
+var PermissionModes = []string{"acceptEdits", "bypassPermissions", "default", "plan"}
+
+// Validate reports whether the options are acceptable to the Claude CLI.
+func (o Options) Validate() error {
+	if o.MaxTurns < 0 {
+		return fmt.Errorf("max_turns must not be negative")
+	}
+	if o.PermissionMode != "" {
+		for _, m := range PermissionModes {
+			if o.PermissionMode == m {
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown permission_mode %q (want one of %s)", o.PermissionMode, strings.Join(PermissionModes, ", "))
+	}
+	return nil
+}
+
+// merge returns o with any zero fields filled in from defaults.
+func (o Options) merge(defaults Options) Options {
+	if o.Model == "" {
+		o.Model = defaults.Model
+	}
+	if o.MaxTurns == 0 {
+		o.MaxTurns = defaults.MaxTurns
+	}
+	if len(o.AllowedTools) == 0 {
+		o.AllowedTools = defaults.AllowedTools
+	}
+	if o.PermissionMode == "" {
+		o.PermissionMode = defaults.PermissionMode
+	}
+	return o
+}
+
+// args renders the options as claude CLI flags. Without an explicit
+// permission mode, spawns skip permission prompts since nobody is at the
+// terminal to answer them.
+func (o Options) args() []string {
+	var args []string
+	if o.Model != "" {
+		args = append(args, "--model", o.Model)
+	}
+	if o.MaxTurns > 0 {
+		args = append(args, "--max-turns", strconv.Itoa(o.MaxTurns))
+	}
+	if len(o.AllowedTools) > 0 {
+		args = append(args, "--allowedTools", strings.Join(o.AllowedTools, ","))
+	}
+	if o.PermissionMode != "" {
+		args = append(args, "--permission-mode", o.PermissionMode)
+	} else {
+		args = append(args, "--dangerously-skip-permissions")
+	}
+	return args
+}
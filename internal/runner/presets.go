@@ -0,0 +1,112 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultModels are the models offered to choose from when Config.Models is
+// empty.
+var DefaultModels = []string{"haiku", "sonnet", "opus"}
+
+// DefaultPresets are the prompt presets offered when Config.Presets is empty.
+var DefaultPresets = []protocol.SpawnPreset{
+	{
+		Name:        "review",
+		Description: "Review recent changes",
+		Prompt:      "Review the uncommitted changes and the latest commits in the working directory. Point out bugs, risky changes and missing tests, most important first. {input}",
+	},
+	{
+		Name:        "explain",
+		Description: "Explain code",
+		Prompt:      "Explain how this works in the code base, with file and function references: {input}",
+	},
+	{
+		Name:        "fix",
+		Description: "Fix a bug",
+		Prompt:      "Find and fix this bug, then say what the cause was and what you changed: {input}",
+	},
+	{
+		Name:        "summarize",
+		Description: "Summarize the room",
+		Prompt:      "Summarize the conversation in this room so far: decisions made, open questions and who is doing what. {input}",
+		Model:       "haiku",
+	},
+}
+
+// LoadPresets reads a presets file, for example
+//
+//	# presets.yaml
+//	- name: triage
+//	  description: Triage an issue
+//	  prompt: "Triage this issue and suggest an owner: {input}"
+//	  model: haiku   # optional
+func LoadPresets(path string) ([]protocol.SpawnPreset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var presets []protocol.SpawnPreset
+	if err := yaml.Unmarshal(data, &presets); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	seen := make(map[string]bool)
+	for _, p := range presets {
+		if p.Name == "" || p.Prompt == "" {
+			return nil, fmt.Errorf("%s: every preset needs a name and a prompt", path)
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("%s: preset %q listed twice", path, p.Name)
+		}
+		seen[p.Name] = true
+	}
+	return presets, nil
+}
+
+// ExpandPreset is the prompt p makes of input.
+func ExpandPreset(p protocol.SpawnPreset, input string) string {
+	input = strings.TrimSpace(input)
+	if strings.Contains(p.Prompt, "{input}") {
+		return strings.TrimSpace(strings.ReplaceAll(p.Prompt, "{input}", input))
+	}
+	if input == "" {
+		return p.Prompt
+	}
+	return p.Prompt + "\n\n" + input
+}
+
+// Choices is what a spawn may pick from: models, presets and work
+// directories, with defaultWorkDir as the work_dir a spawn gets by default
+// ("" = the runner's).
+func (r *Runner) Choices(defaultWorkDir string) protocol.SpawnChoices {
+	models := r.models
+	if r.defaults.Model != "" && !slices.Contains(models, r.defaults.Model) {
+		models = append([]string{r.defaults.Model}, models...)
+	}
+	workDir := r.workDir
+	if defaultWorkDir != "" {
+		workDir = defaultWorkDir
+	}
+	return protocol.SpawnChoices{
+		DefaultModel: r.defaults.Model,
+		Models:       models,
+		Presets:      r.presets,
+		WorkDir:      workDir,
+		AllowedDirs:  r.allowedDirs,
+	}
+}
+
+// Preset returns the preset called name.
+func (r *Runner) Preset(name string) (protocol.SpawnPreset, bool) {
+	for _, p := range r.presets {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return protocol.SpawnPreset{}, false
+}
@@ -0,0 +1,113 @@
+package runner
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Stats is a snapshot of the runner's spawn queue.
+type Stats struct {
+	Running       int     `json:"running"`
+	Queued        int     `json:"queued"`
+	MaxConcurrent int     `json:"max_concurrent"` // 0 = unlimited
+	Started       int64   `json:"started_total"`
+	QueuedTotal   int64   `json:"queued_total"`
+	AvgWaitSec    float64 `json:"avg_wait_seconds"` // over spawns that had to queue
+	MaxWaitSec    float64 `json:"max_wait_seconds"`
+	Recent        int     `json:"recent"`        // spawns finished within RecentWindow
+	RecentFailed  int     `json:"recent_failed"` // of those, ones that errored or timed out
+}
+
+// limiter caps concurrent Claude processes. Spawns beyond the cap wait in
+// FIFO order; a finishing spawn hands its slot straight to the next waiter.
+type limiter struct {
+	mu      sync.Mutex
+	max     int // 0 = unlimited
+	running int
+	waiters []chan struct{}
+
+	started     int64
+	queuedTotal int64
+	waited      int64 // queued spawns that eventually started
+	totalWait   time.Duration
+	maxWait     time.Duration
+}
+
+// acquire takes a slot, waiting in line if the runner is at capacity.
+// onQueued is called with the 1-based queue position before waiting.
+// Returns ctx.Err() if ctx is cancelled while queued.
+func (l *limiter) acquire(ctx context.Context, onQueued func(pos int)) error {
+	l.mu.Lock()
+	if l.max <= 0 || (l.running < l.max && len(l.waiters) == 0) {
+		l.running++
+		l.started++
+		l.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	l.waiters = append(l.waiters, ready)
+	pos := len(l.waiters)
+	l.queuedTotal++
+	l.mu.Unlock()
+
+	onQueued(pos)
+	start := time.Now()
+
+	select {
+	case <-ready:
+		l.mu.Lock()
+		wait := time.Since(start)
+		l.started++
+		l.waited++
+		l.totalWait += wait
+		if wait > l.maxWait {
+			l.maxWait = wait
+		}
+		l.mu.Unlock()
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		for i, w := range l.waiters {
+			if w == ready {
+				l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+				l.mu.Unlock()
+				return ctx.Err()
+			}
+		}
+		l.mu.Unlock()
+		// We were handed a slot just as ctx was cancelled; pass it on.
+		l.release()
+		return ctx.Err()
+	}
+}
+
+// release frees a slot, handing it to the longest-waiting spawn if any.
+func (l *limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.waiters) > 0 {
+		next := l.waiters[0]
+		l.waiters = l.waiters[1:]
+		close(next) // the slot transfers; running is unchanged
+		return
+	}
+	l.running--
+}
+
+func (l *limiter) stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := Stats{
+		Running:       l.running,
+		Queued:        len(l.waiters),
+		MaxConcurrent: l.max,
+		Started:       l.started,
+		QueuedTotal:   l.queuedTotal,
+		MaxWaitSec:    l.maxWait.Seconds(),
+	}
+	if l.waited > 0 {
+		s.AvgWaitSec = (l.totalWait / time.Duration(l.waited)).Seconds()
+	}
+	return s
+}
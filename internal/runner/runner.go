@@ -2,77 +2,158 @@ package runner
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/corvino/claudetalk/internal/e2e"
+	"github.com/corvino/claudetalk/internal/limits"
+	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/corvino/claudetalk/internal/rate"
 	"github.com/google/uuid"
 )
 
 // Config holds configuration for the runner.
 type Config struct {
-	ClaudeBin string // Path to claude CLI binary (default: "claude")
-	WorkDir   string // Working directory for claude processes
-	ServerURL string // URL of the local server (e.g. http://localhost:8080)
+	ClaudeBin string        // Path to claude CLI binary (default: "claude")
+	WorkDir   string        // Working directory for claude processes
+	ServerURL string        // URL of the local server (e.g. http://localhost:8080)
+	Timeout   time.Duration // Max run time per spawn; 0 means no limit
+	Defaults  Options       // CLI options used when a spawn doesn't set its own
+
+	// MaxConcurrent caps simultaneously running Claude processes; further
+	// spawns queue in FIFO order. 0 means unlimited.
+	MaxConcurrent int
+
+	// Limits constrain CPU, memory and priority of each Claude process.
+	// Used only when Backend is nil.
+	Limits limits.Limits
+
+	// Backend runs each spawn. nil means the claude CLI at ClaudeBin.
+	Backend AgentBackend
+
+	// Audit records every spawn; nil disables the audit log.
+	Audit *AuditLog
+
+	// AllowedDirs are base directories a spawn or room may pick a work_dir
+	// under. WorkDir itself, and anything under it, is always allowed.
+	AllowedDirs []string
+
+	// Models are offered to pick from when spawning; nil means
+	// DefaultModels. A spawn may still name any model.
+	Models []string
+
+	// Presets are the prompt presets a spawn may use; nil means
+	// DefaultPresets.
+	Presets []protocol.SpawnPreset
+
+	// Passphrase turns on end-to-end encryption for the runner's own
+	// messages and is handed to spawned Claudes' MCP servers. Empty = off.
+	Passphrase string
+
+	// RoomToken returns a private room's access token, or "". The runner
+	// sends it with its own messages and hands it to the Claudes it spawns
+	// there, along with any tokens in protocol.TokenEnv and RoomTokenEnv.
+	// Nil means the environment's tokens are all there are.
+	RoomToken func(room string) string
 }
 
+// Errors returned by Spawn when the process is killed before finishing.
+var (
+	ErrTimeout = errors.New("claude timed out")
+	ErrStopped = errors.New("claude was stopped")
+)
+
+// RecentWindow is how far back Stats counts finished spawns.
+const RecentWindow = 5 * time.Minute
+
 // Runner spawns local Claude Code instances with MCP tools.
 type Runner struct {
-	claudeBin string
-	workDir   string
-	serverURL string
-	session   *SessionManager
+	backend     AgentBackend
+	workDir     string
+	serverURL   string
+	timeout     time.Duration
+	defaults    Options
+	allowedDirs []string
+	models      []string
+	presets     []protocol.SpawnPreset
+	limiter     *limiter
+	recent      *rate.Window // finished spawns, for Stats
+	audit       *AuditLog
+	session     *SessionManager
+	passphrase  string
+	keys        *e2e.Keyring // nil unless passphrase is set
+	roomToken   func(room string) string
 }
 
 // New creates a runner that spawns local Claude Code processes.
 func New(cfg Config) *Runner {
-	claudeBin := cfg.ClaudeBin
-	if claudeBin == "" {
-		// Try to find claude in PATH first, then common locations.
-		if path, err := exec.LookPath("claude"); err == nil {
-			claudeBin = path
-		} else {
-			home, _ := os.UserHomeDir()
-			candidates := []string{
-				filepath.Join(home, ".local", "bin", "claude"),
-				filepath.Join(home, ".local", "bin", "claude.exe"),
-			}
-			for _, c := range candidates {
-				if _, err := os.Stat(c); err == nil {
-					claudeBin = c
-					break
-				}
-			}
-			if claudeBin == "" {
-				claudeBin = "claude"
-			}
-		}
-		log.Printf("runner: using claude binary: %s", claudeBin)
+	backend := cfg.Backend
+	if backend == nil {
+		backend = NewClaudeCLI(cfg.ClaudeBin, cfg.Limits)
 	}
 	workDir := cfg.WorkDir
 	if workDir == "" {
 		workDir, _ = os.Getwd()
 	}
+	if resolved := cleanDirs([]string{workDir}); len(resolved) == 1 {
+		workDir = resolved[0]
+	}
 	serverURL := cfg.ServerURL
 	if serverURL == "" {
 		serverURL = "http://localhost:8080"
 	}
+	models := cfg.Models
+	if len(models) == 0 {
+		models = DefaultModels
+	}
+	presets := cfg.Presets
+	if len(presets) == 0 {
+		presets = DefaultPresets
+	}
 
 	return &Runner{
-		claudeBin: claudeBin,
-		workDir:   workDir,
-		serverURL: serverURL,
-		session:   NewSessionManager(),
+		backend:     backend,
+		workDir:     workDir,
+		serverURL:   serverURL,
+		timeout:     cfg.Timeout,
+		defaults:    cfg.Defaults,
+		allowedDirs: cleanDirs(cfg.AllowedDirs),
+		models:      models,
+		presets:     presets,
+		limiter:     &limiter{max: cfg.MaxConcurrent},
+		recent:      rate.NewWindow(RecentWindow),
+		audit:       cfg.Audit,
+		session:     NewSessionManager(),
+		passphrase:  cfg.Passphrase,
+		keys:        e2e.NewKeyring(cfg.Passphrase),
+		roomToken:   cfg.RoomToken,
 	}
 }
 
+// Stats reports running and queued spawns.
+func (r *Runner) Stats() Stats {
+	st := r.limiter.stats()
+	st.Recent, st.RecentFailed = r.recent.Counts()
+	return st
+}
+
+// Check reports whether the backend can run agents: nil, or why not.
+func (r *Runner) Check() error {
+	if c, ok := r.backend.(Checker); ok {
+		return c.Check()
+	}
+	return nil
+}
+
 // Sessions returns the runner's session manager.
 func (r *Runner) Sessions() *SessionManager {
 	return r.session
@@ -82,59 +163,176 @@ func (r *Runner) Sessions() *SessionManager {
 type SpawnParams struct {
 	Room   string
 	Sender string
+	Name   string // the name the Claude speaks as; "" = "{Sender}'s Claude"
 	ConvID string // conversation thread ID; used for concurrent session tracking
 	Prompt string
+	Ctx    context.Context // session context from SessionManager.Start; cancelling it kills Claude
+	// Options are per-spawn CLI settings; zero fields use the runner's defaults.
+	Options Options
+	// WorkDir is where Claude runs; see ResolveWorkDir. Empty uses the default.
+	WorkDir string
+	// Trigger is the message that caused the spawn, for the audit log; nil
+	// for spawns the user started directly.
+	Trigger *protocol.Envelope
+	// Charter is the room's charter, put before everything else. Leave it
+	// empty when Prompt was built by package prompt, which includes it.
+	Charter string
 }
 
 // Spawn launches a local Claude Code process with MCP tools connected to the chatroom.
-// Blocks until Claude exits.
+// Blocks until Claude exits, the timeout elapses, or params.Ctx is cancelled. When
+// the process is killed, a system message is posted to the room and ErrTimeout or
+// ErrStopped is returned. When MaxConcurrent Claudes are already running, Spawn
+// waits its turn and announces its queue position. Spawns in a conversation
+// thread (ConvID set) resume the thread's previous claude CLI session.
 func (r *Runner) Spawn(params SpawnParams) error {
-	claudeName := params.Sender + "'s Claude"
+	claudeName := protocol.ClaudeName(params.Sender, params.Name)
+
+	ctx := params.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	workDir, err := r.ResolveWorkDir(params.WorkDir)
+	if err != nil {
+		return err
+	}
+
+	rec := protocol.SpawnRecord{
+		ID:          uuid.New().String(),
+		Room:        params.Room,
+		Sender:      params.Sender,
+		ConvID:      params.ConvID,
+		Backend:     r.backend.Name(),
+		TriggeredBy: params.Sender,
+		Prompt:      params.Prompt,
+		WorkDir:     workDir,
+	}
+	if t := params.Trigger; t != nil {
+		rec.TriggeredBy = t.Sender
+		rec.TriggerSeq = t.SeqNum
+		rec.Trigger = t.Payload.Text
+	}
+
+	// Wait for a free slot; the timeout only starts once Claude does.
+	err = r.limiter.acquire(ctx, func(pos int) {
+		r.postSystem(params.Room, fmt.Sprintf("%s is queued: position %d in line (limit %d at a time)", claudeName, pos, r.limiter.max))
+	})
+	if err != nil {
+		r.postSystem(params.Room, claudeName+" was stopped while queued")
+		rec.StartedAt = time.Now().UTC()
+		rec.Status = "stopped"
+		rec.Error = "stopped while queued"
+		r.audit.Append(rec)
+		return ErrStopped
+	}
+	defer r.limiter.release()
+
+	r.session.setRunning(params.Room, params.Sender, params.ConvID, 0)
+	rec.StartedAt = time.Now().UTC()
+	res, err := r.run(ctx, params, workDir)
+	rec.DurationSec = time.Since(rec.StartedAt).Seconds()
+	rec.OutputBytes = len(res.Text)
+	switch {
+	case err == nil:
+		rec.Status = "ok"
+	case errors.Is(err, ErrTimeout):
+		rec.Status = "timeout"
+	case errors.Is(err, ErrStopped):
+		rec.Status = "stopped"
+	default:
+		rec.Status = "error"
+		rec.Error = err.Error()
+	}
+	r.recent.Add(rec.Status == "error" || rec.Status == "timeout")
+	r.audit.Append(rec)
+	return err
+}
+
+// Audit returns the spawn audit log, which may be nil.
+func (r *Runner) Audit() *AuditLog {
+	return r.audit
+}
+
+// run executes one Claude process in workDir, holding a limiter slot.
+func (r *Runner) run(parent context.Context, params SpawnParams, workDir string) (Result, error) {
+	claudeName := protocol.ClaudeName(params.Sender, params.Name)
+
+	ctx := parent
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
 
 	// Write temp MCP config pointing at local server.
 	configPath, err := r.writeMCPConfig(params.Room, claudeName)
 	if err != nil {
-		return fmt.Errorf("write mcp config: %w", err)
+		return Result{}, fmt.Errorf("write mcp config: %w", err)
 	}
 	defer os.Remove(configPath)
 
 	// Build the prompt with context.
 	prompt := r.buildPrompt(params)
 
-	log.Printf("spawning local claude for %s in room %s", params.Sender, params.Room)
+	log.Printf("spawning %s agent for %s in room %s (dir %s)", r.backend.Name(), params.Sender, params.Room, workDir)
 
-	args := []string{
-		"--mcp-config", configPath,
-		"--print",
-		"--dangerously-skip-permissions",
+	job := Job{
+		Prompt:    prompt,
+		WorkDir:   workDir,
+		MCPConfig: configPath,
+		Options:   params.Options.merge(r.defaults),
+		// Remove CLAUDECODE env var so nested claude can run.
+		Env: filterEnv(os.Environ(), "CLAUDECODE"),
+		// Relay tool calls and intermediate text to the owner as Claude works.
+		Progress: func(text string) {
+			log.Printf("%s: %s", claudeName, text)
+			r.postProgress(params.Room, claudeName, params.Sender, text)
+		},
+		OnStart: func(pid int) {
+			r.session.setRunning(params.Room, params.Sender, params.ConvID, pid)
+		},
 	}
 
-	var stdoutBuf bytes.Buffer
-
-	cmd := exec.Command(r.claudeBin, args...)
-	cmd.Dir = r.workDir
-	cmd.Stdin = strings.NewReader(prompt)
-	cmd.Stdout = io.MultiWriter(&stdoutBuf, os.Stderr) // capture + log
-	cmd.Stderr = os.Stderr
-
-	// Remove CLAUDECODE env var so nested claude can run.
-	cmd.Env = filterEnv(os.Environ(), "CLAUDECODE")
+	// Continue the thread's previous Claude session so it keeps its full
+	// working memory instead of starting cold from the prompt's context.
+	if params.ConvID != "" {
+		job.ResumeID = r.session.ResumeID(params.Room, params.Sender, params.ConvID)
+	}
 
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("claude exited with error: %w", err)
+	res, err := r.backend.Run(ctx, job)
+	if err != nil {
+		switch {
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			r.postSystem(params.Room, fmt.Sprintf("%s timed out after %s and was stopped", claudeName, r.timeout))
+			return Result{}, ErrTimeout
+		case errors.Is(ctx.Err(), context.Canceled):
+			r.postSystem(params.Room, claudeName+" was stopped")
+			return Result{}, ErrStopped
+		}
+		if job.ResumeID != "" {
+			// The saved session may be gone (e.g. a different working dir);
+			// forget it and retry once from scratch.
+			log.Printf("runner: resume of session %s failed (%v); starting fresh", job.ResumeID, err)
+			r.session.SetResumeID(params.Room, params.Sender, params.ConvID, "")
+			return r.run(parent, params, workDir)
+		}
+		return Result{}, err
+	}
+	if params.ConvID != "" && res.SessionID != "" {
+		r.session.SetResumeID(params.Room, params.Sender, params.ConvID, res.SessionID)
 	}
 
 	// If Claude printed a response instead of using send_message, post it to the
 	// chat as a private whisper to the owner so it appears in the web UI.
-	if output := strings.TrimSpace(stdoutBuf.String()); output != "" {
-		claudeName := params.Sender + "'s Claude"
+	if output := strings.TrimSpace(res.Text); output != "" {
 		if err := r.postMessage(params.Room, claudeName, output, params.Sender); err != nil {
 			log.Printf("runner: failed to post stdout as message: %v", err)
 		}
 	}
 
 	log.Printf("claude completed for %s in room %s", params.Sender, params.Room)
-	return nil
+	return res, nil
 }
 
 // mcpConfig is the JSON structure for Claude Code's --mcp-config.
@@ -143,8 +341,9 @@ type mcpConfig struct {
 }
 
 type mcpServerConfig struct {
-	Command string   `json:"command"`
-	Args    []string `json:"args"`
+	Command string            `json:"command"`
+	Args    []string          `json:"args"`
+	Env     map[string]string `json:"env,omitempty"`
 }
 
 func (r *Runner) writeMCPConfig(room, senderName string) (string, error) {
@@ -164,6 +363,7 @@ func (r *Runner) writeMCPConfig(room, senderName string) (string, error) {
 					"--room", room,
 					"--name", senderName,
 				},
+				Env: r.mcpEnv(room),
 			},
 		},
 	}
@@ -184,9 +384,14 @@ func (r *Runner) writeMCPConfig(room, senderName string) (string, error) {
 func (r *Runner) buildPrompt(params SpawnParams) string {
 	var sb strings.Builder
 
-	claudeName := params.Sender + "'s Claude"
+	claudeName := protocol.ClaudeName(params.Sender, params.Name)
+	if params.Charter != "" {
+		sb.WriteString("━━━ ROOM CHARTER ━━━\n")
+		sb.WriteString(params.Charter)
+		sb.WriteString("\nEveryone in this room follows these ground rules, and so do you.\n\n")
+	}
 	sb.WriteString(fmt.Sprintf("You are %q in the ClaudeTalk room %q.\n\n", claudeName, params.Room))
-	sb.WriteString("MCP tools available: send_message, converse, get_messages, list_files, list_participants.\n\n")
+	sb.WriteString("MCP tools available: send_message, converse, get_messages, list_files, list_participants, get_charter.\n\n")
 	sb.WriteString("Your user's request:\n")
 	sb.WriteString(params.Prompt)
 	sb.WriteString("\n\n━━━ INSTRUCTIONS ━━━\n")
@@ -196,13 +401,35 @@ func (r *Runner) buildPrompt(params SpawnParams) string {
 	sb.WriteString("  After asking, call get_messages to poll for their reply before continuing.\n")
 	sb.WriteString("- To broadcast to the whole room: send_message(text=\"...\", broadcast=true).\n")
 	sb.WriteString("- To start or continue a directed conversation with another Claude, use the `converse` tool.\n")
-	sb.WriteString("- To find other Claudes: call list_participants and look for names ending in \"'s Claude\".\n")
+	sb.WriteString("- To find other Claudes: call list_participants and look for the participants with an owner, or names ending in \"'s Claude\".\n")
 	sb.WriteString("- The `converse` tool sets metadata so the other Claude is automatically notified and spawned to reply.\n")
 	sb.WriteString("- Omit `done` (or set done=false) to keep the conversation going. Set done=true only to end it.\n")
 
 	return sb.String()
 }
 
+// postSystem posts a system notice to the room, logging any failure.
+func (r *Runner) postSystem(room, text string) {
+	if err := r.post(room, "system", "system", text, nil); err != nil {
+		log.Printf("runner: failed to post system message: %v", err)
+	}
+}
+
+// postProgress whispers a live progress line from claudeName to its owner.
+// Progress messages are system-typed and tagged progress=true so clients can
+// style or hide them.
+func (r *Runner) postProgress(room, claudeName, owner, text string) {
+	metadata := map[string]string{
+		"progress": "true",
+		"private":  "true",
+		"to":       owner,
+	}
+	text = truncate(text, maxProgressText)
+	if err := r.post(room, claudeName, "system", claudeName+": "+text, metadata); err != nil {
+		log.Printf("runner: failed to post progress: %v", err)
+	}
+}
+
 // postMessage posts a message to the room via the REST API.
 // If to is non-empty the message is sent as a private whisper.
 func (r *Runner) postMessage(room, sender, text, to string) error {
@@ -211,17 +438,67 @@ func (r *Runner) postMessage(room, sender, text, to string) error {
 		metadata["to"] = to
 		metadata["private"] = "true"
 	}
-	body, err := json.Marshal(map[string]any{
-		"sender":   sender,
-		"type":     "text",
-		"payload":  map[string]string{"text": text},
-		"metadata": metadata,
-	})
+	return r.post(room, sender, "text", text, metadata)
+}
+
+// mcpEnv is the environment a spawned Claude's MCP server needs beyond its
+// own: the passphrase and access tokens. It is explicit so a sandboxed spawn,
+// which inherits nothing, gets it too.
+func (r *Runner) mcpEnv(room string) map[string]string {
+	env := e2e.Env(r.passphrase)
+	set := func(k, v string) {
+		if env == nil {
+			env = make(map[string]string)
+		}
+		env[k] = v
+	}
+	if token := protocol.Token(); token != "" {
+		set(protocol.TokenEnv, token)
+	}
+	if tokens := r.roomTokens(room); len(tokens) > 0 {
+		set(protocol.RoomTokenEnv, strings.Join(tokens, ","))
+	}
+	return env
+}
+
+// roomTokens returns the private room tokens to present in room.
+func (r *Runner) roomTokens(room string) []string {
+	tokens := protocol.RoomTokens()
+	if r.roomToken != nil {
+		if t := r.roomToken(room); t != "" {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+func (r *Runner) post(room, sender, msgType, text string, metadata map[string]string) error {
+	req := protocol.SendRequest{
+		Sender:   sender,
+		Type:     msgType,
+		Payload:  protocol.Payload{Text: text},
+		Metadata: metadata,
+	}
+	if err := r.keys.Seal(room, &req); err != nil {
+		return err
+	}
+	body, err := json.Marshal(req)
 	if err != nil {
 		return err
 	}
 	roomEsc := url.PathEscape(room)
-	resp, err := http.Post(r.serverURL+"/api/rooms/"+roomEsc+"/messages", "application/json", bytes.NewReader(body))
+	hreq, err := http.NewRequest(http.MethodPost, r.serverURL+"/api/rooms/"+roomEsc+"/messages", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	if token := protocol.Token(); token != "" {
+		hreq.Header.Set("Authorization", "Bearer "+token)
+	}
+	if tokens := r.roomTokens(room); len(tokens) > 0 {
+		hreq.Header.Set(protocol.RoomTokenHeader, strings.Join(tokens, ","))
+	}
+	resp, err := http.DefaultClient.Do(hreq)
 	if err != nil {
 		return err
 	}
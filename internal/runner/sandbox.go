@@ -0,0 +1,174 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/corvino/claudetalk/internal/limits"
+	"github.com/google/uuid"
+)
+
+// Sandbox modes accepted by BackendConfig.Sandbox.
+const (
+	SandboxNone   = ""
+	SandboxDocker = "docker"
+)
+
+// DefaultSandboxImage is built from Dockerfile.sandbox and contains the
+// claude and claudetalk CLIs.
+const DefaultSandboxImage = "claudetalk-sandbox"
+
+// Paths inside the sandbox container.
+const (
+	sandboxWorkDir   = "/workspace"
+	sandboxMCPConfig = "/etc/claudetalk/mcp.json"
+	sandboxHome      = "/home/node"
+)
+
+// sandboxEnv lists host environment variables passed into the container so
+// the agent can authenticate. Nothing else from the host is visible.
+var sandboxEnv = []string{"ANTHROPIC_API_KEY", "CLAUDE_CODE_OAUTH_TOKEN", "ANTHROPIC_BASE_URL"}
+
+// DockerSandbox runs agent processes in a throwaway container with only the
+// work dir mounted read-write. The container reaches the ClaudeTalk server on
+// the host through host.docker.internal, and keeps claude's own state (login,
+// resumable sessions) in a named volume rather than the host home directory.
+type DockerSandbox struct {
+	Image string
+}
+
+// command builds an exec.Cmd that runs bin with args inside a new container,
+// plus a cleanup func to call once it has exited. Limits are translated to
+// docker resource flags, and env (KEY=value) is passed through. Cancelling
+// ctx removes the container, not just the docker client.
+func (d *DockerSandbox) command(ctx context.Context, job Job, l limits.Limits, env []string, bin string, args []string) (*exec.Cmd, func(), error) {
+	image := d.Image
+	if image == "" {
+		image = DefaultSandboxImage
+	}
+
+	mcpPath, secrets, err := sandboxMCPConfigFor(job.MCPConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Point any --mcp-config argument at the in-container copy.
+	args = append([]string(nil), args...)
+	for i := range args {
+		if args[i] == job.MCPConfig {
+			args[i] = sandboxMCPConfig
+		}
+	}
+
+	name := "claudetalk-" + uuid.New().String()[:8]
+	run := []string{
+		"run", "--rm", "-i", "--init",
+		"--name", name,
+		"--add-host", "host.docker.internal:host-gateway",
+		"-v", job.WorkDir + ":" + sandboxWorkDir,
+		"-w", sandboxWorkDir,
+		"-v", mcpPath + ":" + sandboxMCPConfig + ":ro",
+		"-v", "claudetalk-sandbox-claude:" + sandboxHome + "/.claude",
+		"-e", "CLAUDETALK_MCP_CONFIG=" + sandboxMCPConfig,
+	}
+	for _, key := range sandboxEnv {
+		if os.Getenv(key) != "" {
+			run = append(run, "-e", key) // value comes from our environment
+		}
+	}
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, "CLAUDETALK_MCP_CONFIG=") {
+			run = append(run, "-e", kv)
+		}
+	}
+	// Secrets go by name, so they're in neither the mounted config nor
+	// docker's command line.
+	for _, key := range slices.Sorted(maps.Keys(secrets)) {
+		run = append(run, "-e", key)
+	}
+	if l.MemoryMB > 0 {
+		run = append(run, "--memory", strconv.Itoa(l.MemoryMB)+"m")
+	}
+	if l.CPUPercent > 0 {
+		run = append(run, "--cpus", strconv.FormatFloat(float64(l.CPUPercent)/100, 'f', 2, 64))
+	}
+	if l.CPUTime > 0 {
+		secs := int(l.CPUTime.Seconds())
+		run = append(run, "--ulimit", fmt.Sprintf("cpu=%d:%d", secs, secs))
+	}
+	run = append(run, image, bin)
+	run = append(run, args...)
+
+	cmd := exec.CommandContext(ctx, "docker", run...)
+	cmd.Env = os.Environ()
+	for key, value := range secrets {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	cmd.Cancel = func() error {
+		exec.Command("docker", "rm", "-f", name).Run()
+		return cmd.Process.Kill()
+	}
+	return cmd, func() { os.Remove(mcpPath) }, nil
+}
+
+// sandboxMCPConfigFor writes a copy of the MCP config at path adjusted for
+// the container: claudetalk comes from the image's PATH, and a server on the
+// host's loopback is reached via host.docker.internal. The servers' env is
+// left out of the copy and returned, for the container's environment, where
+// the MCP servers inherit it.
+func sandboxMCPConfigFor(path string) (string, map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("read mcp config: %w", err)
+	}
+	var cfg mcpConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return "", nil, fmt.Errorf("parse mcp config: %w", err)
+	}
+	secrets := make(map[string]string)
+	for name, srv := range cfg.MCPServers {
+		maps.Copy(secrets, srv.Env)
+		srv.Env = nil
+		srv.Command = "claudetalk"
+		for i, arg := range srv.Args {
+			if i > 0 && srv.Args[i-1] == "--server" {
+				srv.Args[i] = containerURL(arg)
+			}
+		}
+		cfg.MCPServers[name] = srv
+	}
+	data, err = json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return "", nil, err
+	}
+	out := strings.TrimSuffix(path, ".json") + "-sandbox.json"
+	if err := os.WriteFile(out, data, 0600); err != nil {
+		return "", nil, err
+	}
+	return out, secrets, nil
+}
+
+// containerURL rewrites a loopback URL so it resolves to the host from inside
+// a container.
+func containerURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		host := "host.docker.internal"
+		if port := u.Port(); port != "" {
+			host += ":" + port
+		}
+		u.Host = host
+	}
+	return u.String()
+}
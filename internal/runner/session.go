@@ -2,8 +2,13 @@ package runner
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
 )
 
 // sessionKey uniquely identifies a session by room + sender + conv_id.
@@ -17,20 +22,29 @@ type sessionKey struct {
 
 // activeSession tracks a running Claude session.
 type activeSession struct {
-	cancel context.CancelFunc
+	cancel    context.CancelFunc
+	startedAt time.Time
+	running   bool // false while waiting for a runner slot
+	pid       int  // agent process ID, if it runs as a local process
 }
 
+// ErrDraining is returned by Start once Drain has begun.
+var ErrDraining = errors.New("the server is shutting down; not starting Claude")
+
 // SessionManager tracks active Claude spawns, allowing multiple concurrent
 // sessions per user when they are in different conversation threads.
 type SessionManager struct {
 	mu       sync.Mutex
 	sessions map[sessionKey]*activeSession
+	resume   map[sessionKey]string // claude CLI session ID of the last run per thread
+	draining bool                  // set by Drain: no new sessions
 }
 
 // NewSessionManager creates a new session manager.
 func NewSessionManager() *SessionManager {
 	return &SessionManager{
 		sessions: make(map[sessionKey]*activeSession),
+		resume:   make(map[sessionKey]string),
 	}
 }
 
@@ -40,13 +54,16 @@ func (sm *SessionManager) Start(room, sender, convID string) (context.Context, c
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
+	if sm.draining {
+		return nil, nil, ErrDraining
+	}
 	key := sessionKey{Room: room, Sender: sender, ConvID: convID}
 	if _, ok := sm.sessions[key]; ok {
 		return nil, nil, fmt.Errorf("Claude session already active for %s in room %s (conv: %s)", sender, room, convID)
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-	sm.sessions[key] = &activeSession{cancel: cancel}
+	sm.sessions[key] = &activeSession{cancel: cancel, startedAt: time.Now().UTC()}
 	return ctx, cancel, nil
 }
 
@@ -77,3 +94,117 @@ func (sm *SessionManager) Stop(room, sender string) error {
 	}
 	return nil
 }
+
+// StopConv cancels one session, identified by its conv_id ("" is the user's
+// directly started session), leaving the user's other threads running.
+func (sm *SessionManager) StopConv(room, sender, convID string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	key := sessionKey{Room: room, Sender: sender, ConvID: convID}
+	s, ok := sm.sessions[key]
+	if !ok {
+		return fmt.Errorf("no active Claude session for %s in room %s (conv: %s)", sender, room, convID)
+	}
+	s.cancel()
+	delete(sm.sessions, key)
+	return nil
+}
+
+// Active returns how many sessions are running or queued.
+func (sm *SessionManager) Active() int {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return len(sm.sessions)
+}
+
+// Drain stops new sessions from starting, then waits until the active ones
+// end or ctx is done. It returns how many are still active.
+func (sm *SessionManager) Drain(ctx context.Context) int {
+	sm.mu.Lock()
+	sm.draining = true
+	sm.mu.Unlock()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		n := sm.Active()
+		if n == 0 {
+			return 0
+		}
+		select {
+		case <-ctx.Done():
+			return n
+		case <-ticker.C:
+		}
+	}
+}
+
+// StopAll cancels every session. Unlike Stop it leaves them tracked until
+// their spawns End, so a Drain after it waits for the processes to exit.
+func (sm *SessionManager) StopAll() {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	for _, s := range sm.sessions {
+		s.cancel()
+	}
+}
+
+// setRunning marks a session as past the queue, with its agent's process ID
+// (0 if the agent isn't a local process or hasn't started yet).
+func (sm *SessionManager) setRunning(room, sender, convID string, pid int) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if s, ok := sm.sessions[sessionKey{Room: room, Sender: sender, ConvID: convID}]; ok {
+		s.running = true
+		s.pid = pid
+	}
+}
+
+// List returns the active sessions in a room, oldest first.
+func (sm *SessionManager) List(room string) []protocol.SessionInfo {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	out := []protocol.SessionInfo{}
+	for key, s := range sm.sessions {
+		if key.Room != room {
+			continue
+		}
+		status := "running"
+		if !s.running {
+			status = "queued"
+		}
+		out = append(out, protocol.SessionInfo{
+			Sender:    key.Sender,
+			ConvID:    key.ConvID,
+			StartedAt: s.startedAt,
+			PID:       s.pid,
+			Status:    status,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt.Before(out[j].StartedAt) })
+	return out
+}
+
+// ResumeID returns the claude CLI session ID recorded for a conversation
+// thread, or "" if the next spawn should start fresh.
+func (sm *SessionManager) ResumeID(room, sender, convID string) string {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.resume[sessionKey{Room: room, Sender: sender, ConvID: convID}]
+}
+
+// SetResumeID records the claude CLI session ID for a conversation thread.
+// An empty id forgets it.
+func (sm *SessionManager) SetResumeID(room, sender, convID, id string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	key := sessionKey{Room: room, Sender: sender, ConvID: convID}
+	if id == "" {
+		delete(sm.resume, key)
+		return
+	}
+	sm.resume[key] = id
+}
@@ -0,0 +1,146 @@
+package runner
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxStreamLine bounds a single stream-json line; tool results can be large.
+const maxStreamLine = 16 * 1024 * 1024
+
+// maxProgressText truncates progress messages so the room isn't flooded.
+const maxProgressText = 500
+
+// streamEvent is one line of `claude --output-format stream-json` output.
+// Only the fields the runner reports on are decoded.
+type streamEvent struct {
+	Type    string `json:"type"`
+	Subtype string `json:"subtype"`
+	Message *struct {
+		Content []streamContent `json:"content"`
+	} `json:"message"`
+	Result    string `json:"result"`
+	IsError   bool   `json:"is_error"`
+	SessionID string `json:"session_id"`
+}
+
+type streamContent struct {
+	Type  string          `json:"type"`
+	Text  string          `json:"text"`
+	Name  string          `json:"name"`
+	Input json.RawMessage `json:"input"`
+}
+
+// streamProgress reads Claude's stream-json stdout, calling progress for each
+// tool call and intermediate text block as they arrive. It returns the final
+// result text and session ID. Lines that aren't JSON (older CLIs) are
+// collected and returned as the result text instead.
+func streamProgress(stdout io.Reader, progress func(text string)) Result {
+	var (
+		res     Result
+		gotRes  bool
+		plain   strings.Builder
+		pending string // last text block; reported only if Claude keeps working
+	)
+
+	sc := bufio.NewScanner(stdout)
+	sc.Buffer(make([]byte, 64*1024), maxStreamLine)
+	for sc.Scan() {
+		line := sc.Bytes()
+		var ev streamEvent
+		if err := json.Unmarshal(line, &ev); err != nil || ev.Type == "" {
+			plain.Write(line)
+			plain.WriteByte('\n')
+			continue
+		}
+
+		if ev.SessionID != "" {
+			res.SessionID = ev.SessionID
+		}
+
+		switch ev.Type {
+		case "assistant":
+			if ev.Message == nil {
+				continue
+			}
+			for _, c := range ev.Message.Content {
+				switch c.Type {
+				case "text":
+					if t := strings.TrimSpace(c.Text); t != "" {
+						if pending != "" {
+							progress(pending)
+						}
+						pending = t
+					}
+				case "tool_use":
+					if pending != "" {
+						progress(pending)
+						pending = ""
+					}
+					progress(describeToolUse(c.Name, c.Input))
+				}
+			}
+		case "result":
+			gotRes = true
+			res.Text = ev.Result
+			if ev.IsError && res.Text == "" {
+				res.Text = "error: " + ev.Subtype
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		log.Printf("runner: reading claude output: %v", err)
+	}
+	// Drain anything left so the process never blocks on a full pipe.
+	io.Copy(io.Discard, stdout)
+
+	if !gotRes {
+		res.Text = pending
+		if res.Text == "" {
+			res.Text = plain.String()
+		}
+	}
+	return res
+}
+
+// describeToolUse renders a tool call as a short human-readable line, e.g.
+// "using Bash: go test ./...".
+func describeToolUse(name string, input json.RawMessage) string {
+	// MCP tools are named mcp__<server>__<tool>.
+	if strings.HasPrefix(name, "mcp__") {
+		if i := strings.LastIndex(name, "__"); i > len("mcp_") {
+			name = name[i+2:]
+		}
+	}
+
+	var args map[string]any
+	json.Unmarshal(input, &args)
+	for _, key := range []string{"command", "file_path", "path", "pattern", "url", "query", "to", "description"} {
+		if v, ok := args[key].(string); ok && v != "" {
+			return fmt.Sprintf("using %s: %s", name, truncate(firstLine(v), 120))
+		}
+	}
+	return "using " + name
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i] + " …"
+	}
+	return s
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "…"
+}
@@ -0,0 +1,75 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ResolveWorkDir validates a requested working directory and returns its
+// cleaned absolute path. An empty dir means the runner's default. Relative
+// paths are taken relative to the default. The directory must exist and be
+// the default or under it, or inside one of the configured allowed base
+// directories.
+func (r *Runner) ResolveWorkDir(dir string) (string, error) {
+	if dir == "" {
+		return r.workDir, nil
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(r.workDir, dir)
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", fmt.Errorf("work_dir %s: %w", dir, err)
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", fmt.Errorf("work_dir %s: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("work_dir %s is not a directory", dir)
+	}
+
+	if withinDir(r.workDir, resolved) {
+		return resolved, nil
+	}
+	for _, base := range r.allowedDirs {
+		if withinDir(base, resolved) {
+			return resolved, nil
+		}
+	}
+	if len(r.allowedDirs) == 0 {
+		return "", fmt.Errorf("work_dir %s is outside the default %s, and no other directories are allowed", dir, r.workDir)
+	}
+	return "", fmt.Errorf("work_dir %s is outside the default %s and the allowed directories (%s)", dir, r.workDir, strings.Join(r.allowedDirs, ", "))
+}
+
+// withinDir reports whether path is base or a descendant of it.
+func withinDir(base, path string) bool {
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// cleanDirs makes each configured base directory absolute with symlinks
+// resolved, so comparisons against resolved work dirs are reliable. Entries
+// that can't be resolved are kept as cleaned absolute paths.
+func cleanDirs(dirs []string) []string {
+	out := make([]string, 0, len(dirs))
+	for _, d := range dirs {
+		if d == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(d); err == nil {
+			d = abs
+		}
+		if resolved, err := filepath.EvalSymlinks(d); err == nil {
+			d = resolved
+		}
+		out = append(out, filepath.Clean(d))
+	}
+	return out
+}
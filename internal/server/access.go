@@ -0,0 +1,69 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// roomTokens returns the private room tokens a request carries, in
+// RoomTokenHeader or RoomTokenParam.
+func roomTokens(r *http.Request) []string {
+	tokens := r.URL.Query()[protocol.RoomTokenParam]
+	for _, h := range r.Header.Values(protocol.RoomTokenHeader) {
+		for _, t := range strings.Split(h, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tokens = append(tokens, t)
+			}
+		}
+	}
+	return tokens
+}
+
+// Admits reports whether a request carrying tokens may use the room: any
+// request for a room that isn't private, and one with its token for a room
+// that is.
+func (r *Room) Admits(tokens []string) bool {
+	s := r.Settings()
+	if s.Visibility != VisibilityPrivate {
+		return true
+	}
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(s.Token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// errPrivateRoom is the answer to a request for a private room without its
+// token.
+const errPrivateRoom = "this room is private; join it with an invite"
+
+// roomAccess guards a /api/rooms/{room}/... handler: a private room's
+// messages, files, settings and invites need its token. Rooms that don't
+// exist yet pass through to the handler. A request with a share link is a
+// guest's, allowed only what the link grants, whatever the room's
+// visibility.
+func (h *Handlers) roomAccess(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s, ok, err := h.Hub.guest(r); ok {
+			if err == nil {
+				err = s.permits(r, r.PathValue("room"))
+			}
+			if err != nil {
+				writeError(w, http.StatusForbidden, err.Error())
+				return
+			}
+			next(w, r)
+			return
+		}
+		if room := h.Hub.GetRoom(r.PathValue("room")); room != nil && !room.Admits(roomTokens(r)) {
+			writeError(w, http.StatusForbidden, errPrivateRoom)
+			return
+		}
+		next(w, r)
+	}
+}
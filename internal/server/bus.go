@@ -0,0 +1,86 @@
+package server
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// Event kinds published on the hub's Bus.
+const (
+	EventMessage          = "message"            // Message was posted
+	EventMessageExpired   = "message_expired"    // Message (ID, room and seq only) expired
+	EventNotice           = "notice"             // Message was sent to clients but not kept; see Room.Notice
+	EventMessageStamped   = "message_stamped"    // Message, whole, was stamped delivered_at or responded_at
+	EventFileShared       = "file_shared"        // File was uploaded; Message announced it
+	EventFileDeleted      = "file_deleted"       // File was deleted
+	EventParticipantJoin  = "participant_joined" // Participant connected
+	EventParticipantLeave = "participant_left"   // Participant's last connection left
+	EventSpawn            = "spawn"              // Spawn summoned Target
+	EventRoomCreated      = "room_created"       // Room was created explicitly; see Hub.CreateRoom
+	EventRoomUpdated      = "room_updated"       // Room's settings or charter changed
+)
+
+// Event is something that happened in a room. Which fields are set depends
+// on Kind.
+type Event struct {
+	Kind        string
+	Room        string
+	Message     *protocol.Envelope
+	File        *protocol.FileInfo
+	Participant *protocol.ParticipantInfo
+	Spawn       *protocol.SpawnReq
+	Target      string // who a spawn summons
+	Remote      bool   // published by another cluster instance; see Cluster
+}
+
+// Bus is the server's internal pub/sub for room events, the one place
+// integrations (notifiers, webhooks, bridges) hook in. Rooms publish from
+// their dispatcher goroutine, after their WebSocket clients have been sent
+// the event, so each subscriber sees a room's events in order. Subscribers
+// are called on that goroutine and must not block.
+type Bus struct {
+	mu   sync.RWMutex
+	subs []*subscription
+}
+
+type subscription struct {
+	fn    func(Event)
+	kinds []string // empty = every kind
+}
+
+// NewBus creates an empty bus.
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe calls fn for every published event of the given kinds, or of
+// every kind if none are given, until the returned function is called.
+func (b *Bus) Subscribe(fn func(Event), kinds ...string) (unsubscribe func()) {
+	s := &subscription{fn: fn, kinds: kinds}
+	b.mu.Lock()
+	b.subs = append(b.subs, s)
+	b.mu.Unlock()
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		// Copy, so a Publish iterating the old slice isn't disturbed.
+		b.subs = slices.DeleteFunc(slices.Clone(b.subs), func(x *subscription) bool { return x == s })
+	}
+}
+
+// Publish calls the subscribers to ev's kind. A nil bus drops the event.
+func (b *Bus) Publish(ev Event) {
+	if b == nil {
+		return
+	}
+	b.mu.RLock()
+	subs := b.subs
+	b.mu.RUnlock()
+	for _, s := range subs {
+		if len(s.kinds) == 0 || slices.Contains(s.kinds, ev.Kind) {
+			s.fn(ev)
+		}
+	}
+}
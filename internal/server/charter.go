@@ -0,0 +1,69 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// Charter returns the room's charter; its Text is "" when it has none.
+func (r *Room) Charter() protocol.Charter {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.charter
+}
+
+// SetCharter replaces the room's charter.
+func (r *Room) SetCharter(c protocol.Charter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.charter = c
+	r.enqueue(Event{Kind: EventRoomUpdated})
+}
+
+// GetCharter handles GET /api/rooms/{room}/charter. A room that doesn't
+// exist has no charter.
+func (h *Handlers) GetCharter(w http.ResponseWriter, r *http.Request) {
+	var c protocol.Charter
+	if room := h.Hub.GetRoom(r.PathValue("room")); room != nil {
+		c = room.Charter()
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// UpdateCharter handles PUT /api/rooms/{room}/charter. It tells the room, so
+// everyone knows the ground rules changed.
+func (h *Handlers) UpdateCharter(w http.ResponseWriter, r *http.Request) {
+	var req protocol.Charter
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %v", err))
+		return
+	}
+	req.Text = strings.TrimSpace(req.Text)
+	if len(req.Text) > protocol.MaxCharter {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("charter is longer than %d bytes", protocol.MaxCharter))
+		return
+	}
+
+	room := h.openRoom(w, r.PathValue("room"))
+	if room == nil {
+		return
+	}
+	req.UpdatedAt = time.Now().UTC()
+	room.SetCharter(req)
+
+	who := req.UpdatedBy
+	if who == "" {
+		who = "Someone"
+	}
+	text := who + " updated the room charter; spawned Claudes follow it from now on. Read it with get_charter."
+	if req.Text == "" {
+		text = who + " cleared the room charter."
+	}
+	room.AddMessage("system", protocol.TypeSystem, protocol.Payload{Text: text}, nil)
+	writeJSON(w, http.StatusOK, req)
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// A participant's Claude speaks as "alice's Claude" unless alice picks
+// another name for it: in .claudetalk, the spawn API or daemon config. The
+// hub remembers picked names, so that each stays with one owner, a private
+// message from "Robo" is whispered to alice, Robo may use alice's direct
+// message rooms, and a message to "alice's Claude" reaches Robo.
+//
+// A daemon may also run several named agents for its owner, such as
+// "alice-reviewer" and "alice-builder", each a Claude of alice's in its own
+// right; see NameAgent.
+
+// ErrClaudeNameTaken is returned by NameClaude for a name that belongs to
+// someone else.
+var ErrClaudeNameTaken = errors.New("name is taken")
+
+// NameClaude records name as the name owner's Claude speaks as and returns
+// it, or "owner's Claude" when name is "". A name picked by another owner,
+// or that a person goes by, is taken.
+func (h *Hub) NameClaude(owner, name string) (string, error) {
+	if name == "" || name == protocol.ClaudeName(owner, "") || name == owner {
+		return protocol.ClaudeName(owner, name), nil
+	}
+	if err := h.checkName(owner, name); err != nil {
+		return "", err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.claimableLocked(owner, name); err != nil {
+		return "", err
+	}
+	maps.DeleteFunc(h.claudes, func(_, o string) bool { return o == owner })
+	delete(h.agents, name)
+	h.claudes[name] = owner
+	return name, nil
+}
+
+// NameAgent records name as one of the named agents owner's daemon runs.
+// Unlike NameClaude it adds to owner's names rather than replacing them.
+// Names are taken just as for NameClaude.
+func (h *Hub) NameAgent(owner, name string) error {
+	if err := h.checkName(owner, name); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.claimableLocked(owner, name); err != nil {
+		return err
+	}
+	h.agents[name] = owner
+	return nil
+}
+
+// checkName returns why owner may not name a Claude name, or nil.
+func (h *Hub) checkName(owner, name string) error {
+	if err := protocol.ValidateClaudeName(name); err != nil {
+		return err
+	}
+	h.mu.RLock()
+	o, ok := h.claudeOwnerLocked(name)
+	h.mu.RUnlock()
+	if ok && o == owner {
+		return nil
+	}
+	if h.personNamed(name) {
+		return fmt.Errorf("claude name %q: %w by a participant", name, ErrClaudeNameTaken)
+	}
+	return nil
+}
+
+// claimableLocked is checkName's last word, with h.mu held: name isn't
+// another owner's Claude, or an owner's own name.
+func (h *Hub) claimableLocked(owner, name string) error {
+	if o, ok := h.claudeOwnerLocked(name); ok && o != owner {
+		return fmt.Errorf("claude name %q: %w by %s's Claude", name, ErrClaudeNameTaken, o)
+	}
+	if slices.Contains(slices.Collect(maps.Values(h.claudes)), name) ||
+		slices.Contains(slices.Collect(maps.Values(h.agents)), name) {
+		return fmt.Errorf("claude name %q: %w by a participant", name, ErrClaudeNameTaken)
+	}
+	return nil
+}
+
+// claudeOwnerLocked returns who picked name, for a Claude or an agent. The
+// caller holds h.mu.
+func (h *Hub) claudeOwnerLocked(name string) (string, bool) {
+	if o, ok := h.claudes[name]; ok {
+		return o, true
+	}
+	o, ok := h.agents[name]
+	return o, ok
+}
+
+// personNamed reports whether name is a participant who isn't a Claude in
+// any room.
+func (h *Hub) personNamed(name string) bool {
+	h.mu.RLock()
+	rooms := slices.Collect(maps.Values(h.rooms))
+	h.mu.RUnlock()
+	for _, r := range rooms {
+		r.mu.RLock()
+		ps := r.participants[name]
+		person := ps != nil && ps.Role == "user"
+		r.mu.RUnlock()
+		if person {
+			return true
+		}
+	}
+	return false
+}
+
+// ClaudeFor returns the name owner's Claude speaks as.
+func (h *Hub) ClaudeFor(owner string) string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for name, o := range h.claudes {
+		if o == owner {
+			return name
+		}
+	}
+	return protocol.ClaudeName(owner, "")
+}
+
+// ClaudeOwner returns whose Claude name is: the owner who picked it, for a
+// Claude or an agent, or X for "X's Claude". ok is false for names that
+// aren't a Claude's.
+func (h *Hub) ClaudeOwner(name string) (owner string, ok bool) {
+	h.mu.RLock()
+	owner, ok = h.claudeOwnerLocked(name)
+	h.mu.RUnlock()
+	if ok {
+		return owner, true
+	}
+	return strings.CutSuffix(name, protocol.ClaudeSuffix)
+}
+
+// ResolveClaude returns the name a message to "to" is for: the picked name
+// of X's Claude when to is "X's Claude", else to.
+func (h *Hub) ResolveClaude(to string) string {
+	if owner, ok := strings.CutSuffix(to, protocol.ClaudeSuffix); ok {
+		return h.ClaudeFor(owner)
+	}
+	return to
+}
+
+// dmPeer is protocol.DMPeer, with Claudes that have a picked name speaking
+// for their owners too. h may be nil.
+func (h *Hub) dmPeer(room, sender string) (string, bool) {
+	if h != nil {
+		if owner, ok := h.ClaudeOwner(sender); ok {
+			sender = owner
+		}
+	}
+	return protocol.DMPeer(room, sender)
+}
+
+// claudeNameStatus is the HTTP status for a NameClaude error.
+func claudeNameStatus(err error) int {
+	if errors.Is(err, ErrClaudeNameTaken) {
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
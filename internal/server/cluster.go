@@ -0,0 +1,121 @@
+package server
+
+import (
+	"log"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// Cluster shares rooms between server instances behind a load balancer.
+// Each instance publishes the events that happen on it and applies those of
+// the others with Hub.ApplyRemote, so every instance holds every room's
+// messages, numbered from one shared sequence, and spawns for the daemons
+// connected to it. RedisCluster implements it.
+type Cluster interface {
+	// NextSeq allocates the next message sequence number in room.
+	NextSeq(room string) (int64, error)
+	// Load returns room's latest sequence number and up to n of its most
+	// recent messages, for a room this instance hasn't held before.
+	Load(room string, n int) (seq int64, history []protocol.Envelope, err error)
+}
+
+// clusterKinds are the events instances share. Spawns aren't among them:
+// each instance dispatches spawns to its own daemons. Room creations are, so
+// that under RoomCreationExplicit a room created on one instance can be
+// joined on the others that are running; invites stay on the instance that
+// made them.
+var clusterKinds = []string{
+	EventMessage, EventMessageExpired, EventFileShared,
+	EventParticipantJoin, EventParticipantLeave, EventRoomCreated,
+}
+
+// SetCluster makes the hub share its rooms through c. Call it before any
+// room is created.
+func (h *Hub) SetCluster(c Cluster) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.cluster = c
+}
+
+// ApplyRemote applies an event another cluster instance published. Its room,
+// created here if need be, passes it on to this instance's clients and bus
+// subscribers with Remote set.
+func (h *Hub) ApplyRemote(ev Event) {
+	h.GetOrCreateRoom(ev.Room).applyRemote(ev)
+}
+
+// restore loads the room's shared state from its cluster.
+func (r *Room) restore(n int) {
+	seq, history, err := r.cluster.Load(r.name, n)
+	if err != nil {
+		log.Printf("room=%s: loading from cluster: %v", r.name, err)
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, env := range history {
+		if exp, err := time.Parse(time.RFC3339Nano, env.Metadata[protocol.MetaExpiresAt]); err == nil && !exp.After(now) {
+			continue
+		}
+		if r.indexLocked(env.ID) < 0 {
+			r.storeLocked(env)
+			r.expireLater(env)
+		}
+	}
+	r.seq = max(r.seq, seq)
+}
+
+// leftRemotelyLocked keeps a participant that left another instance in this
+// room's registry, as if it had been here. The caller holds r.mu.
+func (r *Room) leftRemotelyLocked(p protocol.ParticipantInfo) {
+	ps, ok := r.participants[p.Name]
+	if ok && ps.Connected {
+		return
+	}
+	if !ok {
+		ps = &participantState{Name: p.Name, Role: p.Role, JoinedAt: p.JoinedAt}
+		r.participants[p.Name] = ps
+	}
+	ps.LastSeen = time.Now().UTC()
+	if p.Profile != nil {
+		ps.Profile = *p.Profile
+	}
+}
+
+func (r *Room) applyRemote(ev Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch ev.Kind {
+	case EventMessage:
+		if ev.Message == nil || r.indexLocked(ev.Message.ID) >= 0 {
+			return
+		}
+		r.seq = max(r.seq, ev.Message.SeqNum)
+		r.storeLocked(*ev.Message)
+		r.expireLater(*ev.Message)
+	case EventMessageExpired:
+		if ev.Message == nil {
+			return
+		}
+		if _, ok := r.removeLocked(ev.Message.ID); !ok {
+			return
+		}
+	case EventParticipantJoin, EventParticipantLeave:
+		if ev.Participant == nil {
+			return
+		}
+		if ev.Kind == EventParticipantJoin {
+			r.remote[ev.Participant.Name] = *ev.Participant
+		} else {
+			delete(r.remote, ev.Participant.Name)
+			r.leftRemotelyLocked(*ev.Participant)
+		}
+	case EventFileShared, EventRoomCreated:
+	default:
+		return
+	}
+	ev.Remote = true
+	r.enqueue(ev)
+}
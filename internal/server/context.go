@@ -0,0 +1,135 @@
+package server
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/corvino/claudetalk/internal/e2e"
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// spawnContextSize is how many messages a spawn request's context holds.
+const spawnContextSize = 30
+
+// summaryWindow is how many recent messages outside the thread the summary
+// of the rest of the room covers, and summaryThreads how many of their
+// threads it names.
+const (
+	summaryWindow  = 50
+	summaryThreads = 3
+)
+
+// SpawnContext returns the context for a spawn triggered by env, and a short
+// summary of the rest of the room. When env belongs to a thread, its conv_id
+// thread or the reply thread it is part of, the context is that thread's
+// latest messages, so a Claude replying in it doesn't read unrelated
+// conversations as its own; the summary says what else is going on. A
+// message outside any thread gets the room's latest messages and no summary.
+func (r *Room) SpawnContext(env protocol.Envelope) (context []protocol.Envelope, summary string) {
+	thread, _ := r.Thread(env.ID)
+	convID := env.Metadata["conv_id"]
+
+	r.mu.RLock()
+	in := make(map[string]bool, len(thread))
+	for _, m := range thread {
+		in[m.ID] = true
+	}
+	var scoped, others []protocol.Envelope
+	for _, m := range r.messages {
+		if in[m.ID] || (convID != "" && m.Metadata["conv_id"] == convID) {
+			scoped = append(scoped, m)
+		} else if m.Type != protocol.TypeSystem {
+			others = append(others, m)
+		}
+	}
+	r.mu.RUnlock()
+
+	if len(scoped) < 2 {
+		return r.LatestMessages(spawnContextSize), ""
+	}
+	if len(scoped) > spawnContextSize {
+		scoped = scoped[len(scoped)-spawnContextSize:]
+	}
+	if len(others) > summaryWindow {
+		others = others[len(others)-summaryWindow:]
+	}
+	return scoped, summarize(others)
+}
+
+// summarize describes messages from outside a thread in a few lines: who
+// sent them and what the busiest other threads are about.
+func summarize(messages []protocol.Envelope) string {
+	if len(messages) == 0 {
+		return ""
+	}
+	type thread struct {
+		members []string
+		count   int
+		last    protocol.Envelope
+	}
+	var senders []string
+	var order []string
+	threads := make(map[string]*thread)
+	for _, m := range messages {
+		if !slices.Contains(senders, m.Sender) {
+			senders = append(senders, m.Sender)
+		}
+		key := m.Metadata["conv_id"]
+		t := threads[key]
+		if t == nil {
+			t = &thread{}
+			threads[key] = t
+			order = append(order, key)
+		}
+		for _, name := range []string{m.Sender, m.Metadata["to"]} {
+			if name != "" && !slices.Contains(t.members, name) {
+				t.members = append(t.members, name)
+			}
+		}
+		t.count++
+		t.last = m
+		e2e.Redact(&t.last) // the server never has the room key
+	}
+	// Busiest first.
+	slices.SortStableFunc(order, func(a, b string) int {
+		return threads[b].count - threads[a].count
+	})
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s from %s", countMessages(len(messages), "other recent message"), strings.Join(senders, ", "))
+	if len(order) > 1 || order[0] != "" {
+		fmt.Fprintf(&sb, ", in %d threads", len(order))
+	}
+	sb.WriteString(":\n")
+	for i, key := range order {
+		if i == summaryThreads {
+			fmt.Fprintf(&sb, "  • and %d more\n", len(order)-i)
+			break
+		}
+		t := threads[key]
+		label := strings.Join(t.members, ", ")
+		if key == "" {
+			label = "general chat"
+		}
+		fmt.Fprintf(&sb, "  • %s (%s), latest: %q\n", label, countMessages(t.count, "message"), snippet(t.last.Payload.Text))
+	}
+	return sb.String()
+}
+
+// countMessages returns "1 message" or "n messages", for noun "message".
+func countMessages(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// snippet shortens text to one line of at most 60 characters.
+func snippet(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if r := []rune(text); len(r) > 60 {
+		return string(r[:57]) + "..."
+	}
+	return text
+}
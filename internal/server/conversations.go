@@ -0,0 +1,100 @@
+package server
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// Conversations summarizes the conv_id threads in the room's history, most
+// recently active first. With participant set, only threads it is in are
+// listed; with open, only those whose latest message expects a reply.
+// Threads whose messages have all aged out of the history are not listed.
+func (r *Room) Conversations(participant string, open bool) []protocol.Conversation {
+	r.mu.RLock()
+	byID := make(map[string]*protocol.Conversation)
+	var order []string
+	for _, m := range r.messages {
+		convID := m.Metadata["conv_id"]
+		if convID == "" || m.Type == protocol.TypeSystem {
+			continue
+		}
+		c := byID[convID]
+		if c == nil {
+			c = &protocol.Conversation{ConvID: convID, Topic: topic(m), Started: m.Timestamp}
+			byID[convID] = c
+			order = append(order, convID)
+		}
+		for _, name := range []string{m.Sender, m.Metadata["to"]} {
+			if name != "" && !slices.Contains(c.Participants, name) {
+				c.Participants = append(c.Participants, name)
+			}
+		}
+		c.Messages++
+		c.Last = m
+	}
+	r.mu.RUnlock()
+
+	convs := make([]protocol.Conversation, 0, len(order))
+	for _, convID := range order {
+		c := byID[convID]
+		c.Open = c.Last.Metadata["expecting_reply"] == "true"
+		if c.Open {
+			c.WaitingOn = c.Last.Metadata["to"]
+		}
+		if (participant != "" && !slices.Contains(c.Participants, participant)) || (open && !c.Open) {
+			continue
+		}
+		convs = append(convs, *c)
+	}
+	slices.SortFunc(convs, func(a, b protocol.Conversation) int {
+		return cmp.Compare(b.Last.SeqNum, a.Last.SeqNum)
+	})
+	return convs
+}
+
+// maxTopic is how many runes of a conversation's first message its topic
+// keeps.
+const maxTopic = 80
+
+// topic is what a conversation starting with m is about: the first line of
+// m's text, shortened. Encrypted text is left out, as the server can't read
+// it.
+func topic(m protocol.Envelope) string {
+	if m.Metadata["e2e"] != "" {
+		return ""
+	}
+	text, _, _ := strings.Cut(strings.TrimSpace(m.Payload.Text), "\n")
+	if r := []rune(text); len(r) > maxTopic {
+		text = string(r[:maxTopic-1]) + "…"
+	}
+	return text
+}
+
+// ListConversations handles GET /api/rooms/{room}/conversations
+// ?participant={name}&open=true&limit={n}.
+func (h *Handlers) ListConversations(w http.ResponseWriter, r *http.Request) {
+	roomName := r.PathValue("room")
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "invalid limit parameter")
+			return
+		}
+		limit = n
+	}
+
+	convs := []protocol.Conversation{}
+	if room := h.Hub.GetRoom(roomName); room != nil {
+		convs = room.Conversations(r.URL.Query().Get("participant"), r.URL.Query().Get("open") == "true")
+	}
+	if len(convs) > limit {
+		convs = convs[:limit]
+	}
+	writeJSON(w, http.StatusOK, protocol.ConversationList{Room: roomName, Conversations: convs, Count: len(convs)})
+}
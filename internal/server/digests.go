@@ -0,0 +1,139 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/cron"
+	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/corvino/claudetalk/internal/synopsis"
+)
+
+// DigestConfig configures scheduled per-room digests.
+type DigestConfig struct {
+	Schedule *cron.Schedule
+	Format   string // synopsis format (default markdown)
+	Webhook  string // if set, digests are POSTed here instead of posted to the room
+}
+
+// DigestScheduler periodically builds a digest for every room with new activity.
+type DigestScheduler struct {
+	hub    *Hub
+	cfg    DigestConfig
+	client *http.Client
+
+	mu      sync.Mutex
+	lastSeq map[string]int64 // room → last seq included in a digest
+}
+
+// NewDigestScheduler creates a scheduler for the given hub.
+func NewDigestScheduler(hub *Hub, cfg DigestConfig) *DigestScheduler {
+	return &DigestScheduler{
+		hub:     hub,
+		cfg:     cfg,
+		client:  &http.Client{Timeout: 15 * time.Second},
+		lastSeq: make(map[string]int64),
+	}
+}
+
+// Run fires digests on schedule until ctx is cancelled.
+func (d *DigestScheduler) Run(ctx context.Context) {
+	for {
+		next := d.cfg.Schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Printf("digest scheduler: schedule never fires, stopping")
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+			d.RunOnce()
+		}
+	}
+}
+
+// RunOnce builds and delivers a digest for each room that has messages
+// newer than its previous digest.
+func (d *DigestScheduler) RunOnce() {
+	for _, snap := range d.hub.ListRooms() {
+		d.mu.Lock()
+		after := d.lastSeq[snap.Name]
+		d.mu.Unlock()
+		if snap.LastSeq <= after {
+			continue
+		}
+		room := d.hub.GetRoom(snap.Name)
+		if room == nil {
+			continue
+		}
+		msgs := room.MessagesAfter(after, 0)
+		if len(msgs) == 0 {
+			continue
+		}
+		seq := msgs[len(msgs)-1].SeqNum
+		// Whispers are for their sender and recipient, not everyone the
+		// digest reaches.
+		msgs = slices.DeleteFunc(msgs, func(env protocol.Envelope) bool { return !visibleTo(env, "") })
+		if onlyEphemeral(msgs) {
+			// Nothing that may appear in a digest; don't post an empty one.
+			d.mu.Lock()
+			d.lastSeq[snap.Name] = seq
+			d.mu.Unlock()
+			continue
+		}
+		if err := d.deliver(room, msgs); err != nil {
+			log.Printf("digest scheduler: room=%s: %v", snap.Name, err)
+			continue
+		}
+		// When the digest was posted into the room, skip past it so it
+		// doesn't count as new activity for the next run.
+		if d.cfg.Webhook == "" {
+			seq = room.Snapshot().LastSeq
+		}
+		d.mu.Lock()
+		d.lastSeq[snap.Name] = seq
+		d.mu.Unlock()
+	}
+}
+
+// onlyEphemeral reports whether every message, if any, will expire;
+// synopsis.Render leaves those out.
+func onlyEphemeral(msgs []protocol.Envelope) bool {
+	for _, env := range msgs {
+		if !protocol.Ephemeral(env.Metadata) {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *DigestScheduler) deliver(room *Room, msgs []protocol.Envelope) error {
+	content, err := synopsis.Render(d.cfg.Format, room.name, msgs)
+	if err != nil {
+		return err
+	}
+
+	if d.cfg.Webhook == "" {
+		room.AddMessage("system", protocol.TypeSystem, protocol.Payload{Text: content}, map[string]string{
+			"digest": "true",
+		})
+		return nil
+	}
+
+	resp, err := d.client.Post(d.cfg.Webhook, synopsis.ContentType(d.cfg.Format), bytes.NewReader([]byte(content)))
+	if err != nil {
+		return fmt.Errorf("POST webhook: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+func TestDigestLeavesOutWhispers(t *testing.T) {
+	hub := NewHub(100)
+	room := hub.GetOrCreateRoom("r")
+	room.AddMessage("alice", protocol.TypeText, protocol.Payload{Text: "for everyone"}, map[string]string{"to": "bob", "conv_id": "c"})
+	room.AddMessage("alice", protocol.TypeText, protocol.Payload{Text: "just between us"}, map[string]string{"to": "bob", "private": "true"})
+
+	ds := NewDigestScheduler(hub, DigestConfig{})
+	ds.RunOnce()
+	msgs, _ := room.History()
+	digest := msgs[len(msgs)-1]
+	if digest.Metadata["digest"] != "true" {
+		t.Fatalf("no digest posted; last message is %+v", digest)
+	}
+	if !strings.Contains(digest.Payload.Text, "for everyone") || strings.Contains(digest.Payload.Text, "just between us") {
+		t.Errorf("digest =\n%s\nwant the room message and not the whisper", digest.Payload.Text)
+	}
+
+	// A run with nothing new but whispers posts no digest.
+	room.AddMessage("bob", protocol.TypeText, protocol.Payload{Text: "ok"}, map[string]string{"to": "alice", "private": "true"})
+	ds.RunOnce()
+	if msgs, _ := room.History(); msgs[len(msgs)-1].Metadata["digest"] == "true" {
+		t.Error("posted a digest of only a whisper")
+	}
+}
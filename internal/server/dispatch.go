@@ -0,0 +1,189 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// enqueue hands ev to the room's dispatcher. Callers that change room state
+// hold r.mu, so events leave in the order they happened; the queue is
+// unbounded so that never blocks.
+func (r *Room) enqueue(ev Event) {
+	ev.Room = r.name
+	r.outMu.Lock()
+	r.out = append(r.out, ev)
+	r.outMu.Unlock()
+	select {
+	case r.outReady <- struct{}{}:
+	default: // the dispatcher is already due to wake
+	}
+}
+
+// dispatch is the room's dispatcher goroutine. Each time it wakes it takes
+// every queued event and fans them out to a single snapshot of the clients,
+// encoding each message once per client mode. Delivery never waits on a
+// client: a full send queue drops the frame for that client alone. Spawn
+// events are dispatched here too, once per message, and every event is then
+// published on the hub's bus.
+func (r *Room) dispatch() {
+	for range r.outReady {
+		r.outMu.Lock()
+		batch := r.out
+		r.out = nil
+		r.outMu.Unlock()
+
+		r.mu.RLock()
+		clients := make([]*Client, 0, len(r.clients))
+		for c := range r.clients {
+			clients = append(clients, c)
+		}
+		r.mu.RUnlock()
+
+		for _, ev := range batch {
+			switch ev.Kind {
+			case EventMessage:
+				received := r.fanout(clients, *ev.Message)
+				r.dispatchSpawns(received, *ev.Message, ev.Remote)
+			case EventNotice:
+				r.fanout(clients, *ev.Message)
+			case EventMessageExpired:
+				r.fanoutEvent(clients, protocol.ServerEvent{Event: ev.Kind, Message: ev.Message}, false)
+			case EventFileShared:
+				// Legacy clients learn of files from the announcing message.
+				r.fanoutEvent(clients, protocol.ServerEvent{Event: ev.Kind, File: ev.File}, true)
+			}
+			r.events.Publish(ev)
+		}
+	}
+}
+
+// fanout delivers env to the clients allowed to see it and returns them.
+// Private messages (metadata.private=true) are only delivered to the sender
+// and the intended recipient; daemon clients always receive everything.
+func (r *Room) fanout(clients []*Client, env protocol.Envelope) []*Client {
+	// Legacy clients receive bare envelopes, daemon clients ServerEvent wrappers.
+	legacy, err := json.Marshal(env)
+	if err != nil {
+		log.Printf("room=%s: encode message #%d: %v", r.name, env.SeqNum, err)
+		return nil
+	}
+	daemon, err := json.Marshal(protocol.ServerEvent{Event: "message", Message: &env})
+	if err != nil {
+		log.Printf("room=%s: encode message #%d: %v", r.name, env.SeqNum, err)
+		return nil
+	}
+
+	received := clients[:0:0]
+	for _, c := range clients {
+		if env.Metadata["private"] == "true" && c.mode != "daemon" {
+			if c.sender != env.Sender && c.sender != env.Metadata["to"] {
+				continue
+			}
+		}
+		if !c.filter.Matches(env) {
+			continue
+		}
+		if c.mode == "daemon" {
+			c.queue(daemon)
+		} else {
+			c.queue(legacy)
+		}
+		received = append(received, c)
+	}
+	return received
+}
+
+// fanoutEvent sends event to every client, or only to daemon clients.
+func (r *Room) fanoutEvent(clients []*Client, event protocol.ServerEvent, daemonsOnly bool) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	for _, c := range clients {
+		if !daemonsOnly || c.mode == "daemon" {
+			c.queueRaw(data)
+		}
+	}
+}
+
+// dispatchSpawns sends the spawn events env triggers: to every daemon in its
+// conv_id thread, to the spawn hooks of non-daemon participants, and to
+// daemons among received that opted in to env's @-mentions. A message
+// posted on this instance, not remote, is queued for an offline recipient.
+func (r *Room) dispatchSpawns(received []*Client, env protocol.Envelope, remote bool) {
+	// Every spawn for env carries its thread, a summary of the rest of the
+	// room, and who's around and what they do, so a spawned Claude can pick
+	// whom to bring in.
+	threadContext := sync.OnceValues(func() ([]protocol.Envelope, string) { return r.SpawnContext(env) })
+	profiles := sync.OnceValue(r.Profiles)
+	templates := sync.OnceValue(r.PromptTemplates)
+	charter := sync.OnceValue(func() string { return r.Charter().Text })
+	newSpawn := func(reason string, participants []string) *protocol.SpawnReq {
+		ctx, summary := threadContext()
+		return &protocol.SpawnReq{
+			Reason:          reason,
+			Trigger:         &env,
+			Context:         ctx,
+			Summary:         summary,
+			Participants:    participants,
+			Profiles:        profiles(),
+			PromptTemplates: templates(),
+			Charter:         charter(),
+		}
+	}
+
+	// For group conv_id threads, this notifies every thread participant except the sender.
+	if targets, allParticipants := r.GetConvSpawnTargets(env); len(targets) > 0 {
+		daemonClients := r.GetDaemonClients(targets)
+		log.Printf("spawn dispatch: targets=%v daemonClients=%d sender=%s", targets, len(daemonClients), env.Sender)
+		for name, dc := range daemonClients {
+			log.Printf("spawn dispatch: sending spawn event to %s", name)
+			spawn := newSpawn("directed_message", allParticipants)
+			if name != dc.sender {
+				spawn.Agent = name // one of dc's named agents
+			}
+			dc.sendSpawn(spawn)
+			r.events.Publish(Event{Kind: EventSpawn, Room: r.name, Spawn: spawn, Target: name})
+		}
+	}
+
+	// A direct message summons the other member's daemon wherever it holds
+	// its name, since daemons join their own rooms, not every DM room.
+	if to := env.Metadata["to"]; to != "" && env.Metadata["expecting_reply"] == "true" {
+		if dc := r.dmElsewhere(to); dc != nil {
+			log.Printf("spawn dispatch: direct message to %s, daemon in another room", to)
+			spawn := newSpawn("directed_message", nil)
+			dc.sendSpawn(spawn)
+			r.events.Publish(Event{Kind: EventSpawn, Room: r.name, Spawn: spawn, Target: to})
+		}
+	}
+
+	// A directed message to a daemon that is offline waits for it.
+	if to := env.Metadata["to"]; to != "" && env.Metadata["expecting_reply"] == "true" && !remote && r.offlineDaemon(to) {
+		r.queueSpawn(to, newSpawn("directed_message", nil))
+	}
+
+	// Fire server-side hooks for non-daemon participants (e.g. host-mode spawned Claudes).
+	if hookTargets, hookParticipants := r.GetHookSpawnTargets(env); len(hookTargets) > 0 {
+		for name, hook := range hookTargets {
+			log.Printf("spawn dispatch: hook for %s", name)
+			spawn := newSpawn("directed_message", hookParticipants)
+			go hook(spawn)
+			r.events.Publish(Event{Kind: EventSpawn, Room: r.name, Spawn: spawn, Target: name})
+		}
+	}
+
+	// Daemons that opted in to mentions are summoned by broadcasts that
+	// @-mention them, if they hold their name's claim.
+	for _, c := range received {
+		if c.mentionedIn(env) && r.HoldsClaim(c) {
+			log.Printf("spawn dispatch: mention of %s by %s", c.sender, env.Sender)
+			spawn := newSpawn("mention", nil)
+			c.sendSpawn(spawn)
+			r.events.Publish(Event{Kind: EventSpawn, Room: r.name, Spawn: spawn, Target: c.sender})
+		}
+	}
+}
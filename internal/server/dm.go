@@ -0,0 +1,110 @@
+package server
+
+import (
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// Direct message rooms (protocol.DMRoom) are ordinary rooms with a few
+// rules: they are created on first use under any room creation policy, left
+// out of room listings, and only their two members, or the members' Claudes,
+// may join or post. Senders aren't authenticated, so that keeps strangers
+// out of a DM by accident rather than by force; use a private room for
+// secrets. A message that names no recipient is addressed to the other
+// member, and a spawn for a member whose daemon hasn't joined the DM room
+// goes to that daemon in whichever room it holds its name.
+
+// ErrNotDMMember is returned for a sender who isn't in a direct message room.
+var ErrNotDMMember = errors.New("only the two members of a direct message room, and their Claudes, may use it")
+
+// dmAddress checks that sender may post in the room, if it is a direct
+// message room, and returns metadata addressed to the other member unless it
+// names a recipient already.
+func (r *Room) dmAddress(sender string, metadata map[string]string) (map[string]string, error) {
+	if _, _, ok := protocol.DMMembers(r.name); !ok {
+		return metadata, nil
+	}
+	peer, ok := r.hub.dmPeer(r.name, sender)
+	if !ok {
+		return nil, ErrNotDMMember
+	}
+	if metadata["to"] != "" {
+		return metadata, nil
+	}
+	md := make(map[string]string, len(metadata)+1)
+	for k, v := range metadata {
+		md[k] = v
+	}
+	md["to"] = peer
+	return md, nil
+}
+
+// dmElsewhere returns the daemon connection that answers for to, a member
+// of this direct message room who hasn't joined it, or nil.
+func (r *Room) dmElsewhere(to string) *Client {
+	if r.hub == nil {
+		return nil
+	}
+	if _, ok := protocol.DMPeer(r.name, to); !ok {
+		return nil
+	}
+	if _, claude := r.hub.ClaudeOwner(to); claude {
+		return nil
+	}
+	r.mu.RLock()
+	ps := r.participants[to]
+	here := ps != nil && ps.Connected && ps.Role == "daemon"
+	r.mu.RUnlock()
+	if here {
+		return nil
+	}
+	return r.hub.daemonFor(to)
+}
+
+// daemonFor returns the daemon connection holding name's claim in any room,
+// or nil.
+func (h *Hub) daemonFor(name string) *Client {
+	h.mu.RLock()
+	rooms := slices.Collect(maps.Values(h.rooms))
+	h.mu.RUnlock()
+	for _, r := range rooms {
+		r.mu.RLock()
+		ps := r.participants[name]
+		var c *Client
+		if ps != nil && ps.Connected && ps.Role == "daemon" {
+			c = ps.Client
+		}
+		r.mu.RUnlock()
+		if c != nil {
+			return c
+		}
+	}
+	return nil
+}
+
+// ListDMs handles GET /api/dms?sender={name}: the direct message rooms
+// sender is in, which room listings leave out.
+func (h *Handlers) ListDMs(w http.ResponseWriter, r *http.Request) {
+	sender := r.URL.Query().Get("sender")
+	if sender == "" {
+		writeError(w, http.StatusBadRequest, "sender required")
+		return
+	}
+	rooms := []protocol.RoomInfo{}
+	for _, s := range h.Hub.ListRooms() {
+		if _, ok := h.Hub.dmPeer(s.Name, sender); !ok {
+			continue
+		}
+		rooms = append(rooms, protocol.RoomInfo{
+			Name:         s.Name,
+			Clients:      s.Clients,
+			MessageCount: s.MessageCount,
+			LastSeq:      s.LastSeq,
+		})
+	}
+	writeJSON(w, http.StatusOK, protocol.RoomList{Rooms: rooms})
+}
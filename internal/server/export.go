@@ -0,0 +1,232 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// History returns a copy of the room's messages and its last assigned seq.
+func (r *Room) History() ([]protocol.Envelope, int64) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]protocol.Envelope, len(r.messages))
+	copy(out, r.messages)
+	return out, r.seq
+}
+
+// Import loads exported messages into a room that has never had any. They
+// keep their IDs, seqs, timestamps and metadata, and new messages continue
+// from lastSeq or the highest imported seq, whichever is later. Imported
+// messages aren't broadcast or passed to observers; they are history.
+func (r *Room) Import(msgs []protocol.Envelope, lastSeq int64) error {
+	sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].SeqNum < msgs[j].SeqNum })
+	for i, m := range msgs {
+		if m.SeqNum <= 0 || (i > 0 && m.SeqNum == msgs[i-1].SeqNum) {
+			return fmt.Errorf("import: message %s has missing or duplicate seq %d", m.ID, m.SeqNum)
+		}
+	}
+
+	r.mu.Lock()
+	if r.seq > 0 {
+		r.mu.Unlock()
+		return ErrRoomNotEmpty
+	}
+	if len(msgs) > r.maxHistory {
+		msgs = msgs[len(msgs)-r.maxHistory:]
+	}
+	for i := range msgs {
+		msgs[i].Room = r.name
+		msgs[i].Warning = ""
+		r.messages = append(r.messages, msgs[i])
+		if msgs[i].ReplyTo != "" {
+			r.replies[msgs[i].ReplyTo] = append(r.replies[msgs[i].ReplyTo], msgs[i].ID)
+		}
+		r.trackConvLocked(msgs[i])
+	}
+	r.seq = lastSeq
+	if n := len(msgs); n > 0 && msgs[n-1].SeqNum > r.seq {
+		r.seq = msgs[n-1].SeqNum
+	}
+	r.mu.Unlock()
+
+	for _, m := range msgs {
+		r.expireLater(m)
+	}
+	return nil
+}
+
+// Import stores an exported file in room. It keeps info's ID unless another
+// file already has it, in which case the file gets a new one; callers remap
+// file_id references to the returned ID.
+func (fs *FileStore) Import(room string, info protocol.FileInfo, data []byte) (*protocol.FileInfo, error) {
+	fs.mu.RLock()
+	_, taken := fs.files[info.ID]
+	fs.mu.RUnlock()
+	if info.ID == "" || taken {
+		stored, err := fs.Store(room, info.Sender, info.Filename, info.ContentType, info.Description, int64(len(data)), bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		fs.mu.Lock()
+		stored.Timestamp = info.Timestamp
+		fs.mu.Unlock()
+		return stored, nil
+	}
+	if int64(len(data)) > fs.maxFileSize {
+		return nil, fmt.Errorf("file too large: %d bytes (max %d)", len(data), fs.maxFileSize)
+	}
+
+	roomDir := filepath.Join(fs.baseDir, room)
+	if err := os.MkdirAll(roomDir, 0755); err != nil {
+		return nil, fmt.Errorf("create room dir: %w", err)
+	}
+	info.Room = room
+	info.Size = int64(len(data))
+	info.URL = fmt.Sprintf("/api/rooms/%s/files/%s", room, info.ID)
+	if err := os.WriteFile(fs.diskPath(&info), data, 0644); err != nil {
+		return nil, fmt.Errorf("write file: %w", err)
+	}
+
+	fs.mu.Lock()
+	fs.files[info.ID] = &info
+	fs.rooms[room] = append(fs.rooms[room], info.ID)
+	fs.mu.Unlock()
+	return &info, nil
+}
+
+// ExportRoom handles GET /api/rooms/{room}/export: the room's history and
+// shared files as newline-delimited protocol.ExportRecord JSON.
+func (h *Handlers) ExportRoom(w http.ResponseWriter, r *http.Request) {
+	roomName := r.PathValue("room")
+	room := h.Hub.GetRoom(roomName)
+	if room == nil {
+		writeError(w, http.StatusNotFound, "room not found")
+		return
+	}
+	msgs, lastSeq := room.History()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", roomName+".ndjson"))
+	enc := json.NewEncoder(w)
+	enc.Encode(protocol.ExportRecord{Kind: protocol.ExportHeader, Header: &protocol.RoomExport{
+		Version:  protocol.ExportVersion,
+		Name:     roomName,
+		Exported: time.Now().UTC(),
+		LastSeq:  lastSeq,
+	}})
+	if h.FileStore != nil {
+		for _, info := range h.FileStore.List(roomName) {
+			path, err := h.FileStore.FilePath(info.ID)
+			if err != nil {
+				continue
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				log.Printf("export %s: skipping file %s: %v", roomName, info.Filename, err)
+				continue
+			}
+			info := info
+			if err := enc.Encode(protocol.ExportRecord{Kind: protocol.ExportFile, File: &info, Data: data}); err != nil {
+				return
+			}
+		}
+	}
+	for i := range msgs {
+		if err := enc.Encode(protocol.ExportRecord{Kind: protocol.ExportMessage, Message: &msgs[i]}); err != nil {
+			return
+		}
+	}
+}
+
+// ImportRoom handles POST /api/rooms/{room}/import with a body in the format
+// ExportRoom writes. The room must not have any history yet; it is created
+// under either room creation policy, since naming it here is deliberate.
+func (h *Handlers) ImportRoom(w http.ResponseWriter, r *http.Request) {
+	roomName := r.PathValue("room")
+	if roomName == "" {
+		writeError(w, http.StatusBadRequest, "room name required")
+		return
+	}
+	room := h.Hub.GetOrCreateRoom(roomName)
+	if room.Snapshot().LastSeq > 0 {
+		writeError(w, http.StatusConflict, ErrRoomNotEmpty.Error())
+		return
+	}
+
+	dec := json.NewDecoder(r.Body)
+	var header protocol.ExportRecord
+	if err := dec.Decode(&header); err != nil || header.Kind != protocol.ExportHeader || header.Header == nil {
+		writeError(w, http.StatusBadRequest, "import must start with a room header record")
+		return
+	}
+	if header.Header.Version != protocol.ExportVersion {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unsupported export version %d", header.Header.Version))
+		return
+	}
+
+	var msgs []protocol.Envelope
+	fileIDs := make(map[string]string) // exported file ID → ID on this server
+	for {
+		var rec protocol.ExportRecord
+		err := dec.Decode(&rec)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid record: %v", err))
+			return
+		}
+		switch {
+		case rec.Kind == protocol.ExportMessage && rec.Message != nil:
+			msgs = append(msgs, *rec.Message)
+		case rec.Kind == protocol.ExportFile && rec.File != nil:
+			if h.FileStore == nil {
+				writeError(w, http.StatusServiceUnavailable, "file storage not configured")
+				return
+			}
+			info, err := h.FileStore.Import(roomName, *rec.File, rec.Data)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("file %s: %v", rec.File.Filename, err))
+				return
+			}
+			fileIDs[rec.File.ID] = info.ID
+		default:
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown record kind %q", rec.Kind))
+			return
+		}
+	}
+
+	for i, m := range msgs {
+		if id, ok := fileIDs[m.Metadata["file_id"]]; ok && id != m.Metadata["file_id"] {
+			md := make(map[string]string, len(m.Metadata))
+			for k, v := range m.Metadata {
+				md[k] = v
+			}
+			md["file_id"] = id
+			msgs[i].Metadata = md
+		}
+	}
+	if err := room.Import(msgs, header.Header.LastSeq); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrRoomNotEmpty) {
+			status = http.StatusConflict
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+
+	lastSeq := room.Snapshot().LastSeq
+	log.Printf("room=%s: imported %d messages and %d files from %q", roomName, len(msgs), len(fileIDs), header.Header.Name)
+	writeJSON(w, http.StatusCreated, protocol.ImportResult{Room: roomName, Messages: len(msgs), Files: len(fileIDs), LastSeq: lastSeq})
+}
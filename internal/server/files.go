@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
 	"time"
 
@@ -116,6 +117,25 @@ func (fs *FileStore) List(room string) []protocol.FileInfo {
 	return out
 }
 
+// Delete removes a file from room, its contents and its metadata. It errors
+// if there is no such file in room.
+func (fs *FileStore) Delete(room, id string) (*protocol.FileInfo, error) {
+	fs.mu.Lock()
+	info, ok := fs.files[id]
+	if !ok || info.Room != room {
+		fs.mu.Unlock()
+		return nil, fmt.Errorf("file not found: %s", id)
+	}
+	delete(fs.files, id)
+	fs.rooms[room] = slices.DeleteFunc(fs.rooms[room], func(fid string) bool { return fid == id })
+	fs.mu.Unlock()
+
+	if err := os.Remove(fs.diskPath(info)); err != nil && !os.IsNotExist(err) {
+		return info, fmt.Errorf("remove file: %w", err)
+	}
+	return info, nil
+}
+
 // FilePath returns the on-disk path for a file by ID.
 func (fs *FileStore) FilePath(id string) (string, error) {
 	fs.mu.RLock()
@@ -125,6 +145,25 @@ func (fs *FileStore) FilePath(id string) (string, error) {
 		return "", fmt.Errorf("file not found: %s", id)
 	}
 
-	diskName := id + "-" + filepath.Base(info.Filename)
-	return filepath.Join(fs.baseDir, info.Room, diskName), nil
+	return fs.diskPath(info), nil
+}
+
+// diskPath is where a file's contents live: <base>/<room>/<id>-<name>.
+func (fs *FileStore) diskPath(info *protocol.FileInfo) string {
+	return filepath.Join(fs.baseDir, info.Room, info.ID+"-"+filepath.Base(info.Filename))
+}
+
+// Adopt records a file another cluster instance stored, if it is on disk here
+// because the instances share a file directory. It reports whether it was.
+func (fs *FileStore) Adopt(info protocol.FileInfo) bool {
+	if _, err := os.Stat(fs.diskPath(&info)); err != nil {
+		return false
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if _, ok := fs.files[info.ID]; !ok {
+		fs.files[info.ID] = &info
+		fs.rooms[info.Room] = append(fs.rooms[info.Room], info.ID)
+	}
+	return true
 }
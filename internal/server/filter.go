@@ -0,0 +1,84 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// MessageFilter restricts which messages are returned or streamed to a client.
+// Empty fields match everything.
+type MessageFilter struct {
+	Sender  string
+	Type    string
+	ConvID  string
+	Match   *regexp.Regexp // applied to the message text, code, diff, or data
+	Mention string         // only messages that @-mention this name
+	To      string         // only messages directed at this name (metadata.to)
+}
+
+// ParseFilter builds a filter from the from, type, conv_id, match, mentions
+// and to query parameters. Returns nil if none are set. ("from" rather than
+// "sender" because the WebSocket endpoint already uses sender for the
+// client's own name; mentions=me and to=me mean that name.)
+func ParseFilter(q url.Values) (*MessageFilter, error) {
+	f := &MessageFilter{
+		Sender:  q.Get("from"),
+		Type:    q.Get("type"),
+		ConvID:  q.Get("conv_id"),
+		Mention: q.Get("mentions"),
+		To:      q.Get("to"),
+	}
+	if f.Mention == "me" {
+		if f.Mention = q.Get("sender"); f.Mention == "" {
+			return nil, fmt.Errorf("mentions=me needs a sender parameter")
+		}
+	}
+	if f.To == "me" {
+		if f.To = q.Get("sender"); f.To == "" {
+			return nil, fmt.Errorf("to=me needs a sender parameter")
+		}
+	}
+	if m := q.Get("match"); m != "" {
+		re, err := regexp.Compile(m)
+		if err != nil {
+			return nil, fmt.Errorf("invalid match regex: %w", err)
+		}
+		f.Match = re
+	}
+	if f.Sender == "" && f.Type == "" && f.ConvID == "" && f.Match == nil && f.Mention == "" && f.To == "" {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// Matches reports whether env passes the filter. A nil filter matches everything.
+func (f *MessageFilter) Matches(env protocol.Envelope) bool {
+	if f == nil {
+		return true
+	}
+	if f.Sender != "" && env.Sender != f.Sender {
+		return false
+	}
+	if f.Type != "" && env.Type != f.Type {
+		return false
+	}
+	if f.ConvID != "" && env.Metadata["conv_id"] != f.ConvID {
+		return false
+	}
+	if f.Mention != "" && !protocol.Mentioned(env.Metadata, f.Mention) {
+		return false
+	}
+	if f.To != "" && env.Metadata["to"] != f.To {
+		return false
+	}
+	if f.Match != nil {
+		return f.Match.MatchString(env.Payload.Text) ||
+			f.Match.MatchString(env.Payload.Code) ||
+			f.Match.MatchString(env.Payload.Diff) ||
+			f.Match.Match(env.Payload.Data)
+	}
+	return true
+}
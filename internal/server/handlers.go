@@ -2,6 +2,7 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,7 +11,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/corvino/claudetalk/internal/buildinfo"
+	"github.com/corvino/claudetalk/internal/e2e"
+	"github.com/corvino/claudetalk/internal/prompt"
 	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/corvino/claudetalk/internal/rate"
 	"github.com/corvino/claudetalk/internal/runner"
 	"github.com/corvino/claudetalk/internal/synopsis"
 )
@@ -21,7 +26,8 @@ type Handlers struct {
 	FileStore  *FileStore
 	Runner     *runner.Runner
 	StartTime  time.Time
-	hookStates sync.Map // key: claudeName (string), value: *hostHookState
+	requests   *rate.Window // requests and 5xx responses; see Ready
+	hookStates sync.Map     // key: claudeName (string), value: *hostHookState
 }
 
 // hostHookState maintains pending spawn queues for a host-mode Claude so that
@@ -31,6 +37,7 @@ type hostHookState struct {
 	pendingSpawns map[string]*protocol.SpawnReq
 	rnr           *runner.Runner
 	room          string
+	settings      func() RoomSettings
 	sender        string
 	claudeName    string
 }
@@ -41,7 +48,11 @@ func (s *hostHookState) trySpawn(req *protocol.SpawnReq) {
 		convID = req.Trigger.Metadata["conv_id"]
 	}
 
-	_, cancel, err := s.rnr.Sessions().Start(s.room, s.sender, convID)
+	ctx, cancel, err := s.rnr.Sessions().Start(s.room, s.sender, convID)
+	if errors.Is(err, runner.ErrDraining) {
+		log.Printf("host hook: dropped spawn for %s conv=%s (shutting down)", s.sender, convID)
+		return
+	}
 	if err != nil {
 		// Session already active — queue the latest request.
 		s.mu.Lock()
@@ -67,10 +78,14 @@ func (s *hostHookState) trySpawn(req *protocol.SpawnReq) {
 		}()
 
 		params := runner.SpawnParams{
-			Room:   s.room,
-			Sender: s.sender,
-			ConvID: convID,
-			Prompt: buildHostHookPrompt(s.claudeName, s.room, req),
+			Room:    s.room,
+			Sender:  s.sender,
+			Name:    s.claudeName,
+			ConvID:  convID,
+			Prompt:  prompt.Build(prompt.NewData(s.claudeName, s.room, req, 0), req.PromptTemplates...),
+			Ctx:     ctx,
+			WorkDir: s.settings().WorkDir,
+			Trigger: req.Trigger,
 		}
 		if err := s.rnr.Spawn(params); err != nil {
 			log.Printf("host hook: spawn error for %s: %v", s.claudeName, err)
@@ -78,82 +93,89 @@ func (s *hostHookState) trySpawn(req *protocol.SpawnReq) {
 	}()
 }
 
-// buildHostHookPrompt builds a reply prompt for a host-mode Claude responding to a directed message.
-func buildHostHookPrompt(claudeName, room string, req *protocol.SpawnReq) string {
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("You are %q in the ClaudeTalk room %q.\n\n", claudeName, room))
-
-	isGroup := len(req.Participants) > 1
-	if isGroup {
-		sb.WriteString("This is a GROUP conversation thread. All participants:\n")
-		for _, p := range req.Participants {
-			sb.WriteString("  • " + p + "\n")
-		}
-		sb.WriteString("When you reply, ALL participants in this thread are automatically notified.\n\n")
-	}
-
-	if len(req.Context) > 0 {
-		sb.WriteString("Recent conversation context (newest at bottom):\n")
-		for _, env := range req.Context {
-			ts := env.Timestamp.Format("15:04:05")
-			fmt.Fprintf(&sb, "[%s] %s", ts, env.Sender)
-			if to := env.Metadata["to"]; to != "" {
-				fmt.Fprintf(&sb, " → %s", to)
-			}
-			fmt.Fprintf(&sb, ": %s\n", env.Payload.Text)
-		}
-		sb.WriteString("\n")
-	}
-
-	if req.Trigger != nil {
-		replyTo := req.Trigger.Sender
-		convID := req.Trigger.Metadata["conv_id"]
-		sb.WriteString("━━━ INCOMING MESSAGE ━━━\n")
-		fmt.Fprintf(&sb, "From:            %s\n", replyTo)
-		fmt.Fprintf(&sb, "Conversation ID: %s\n", convID)
-		fmt.Fprintf(&sb, "Message:         %s\n", req.Trigger.Payload.Text)
-		sb.WriteString("\n━━━ REPLY INSTRUCTIONS ━━━\n")
-		sb.WriteString("1. You MUST reply using the `converse` tool — NEVER `send_message` for directed replies.\n")
-		fmt.Fprintf(&sb, "2. Use: converse(to=%q, conv_id=%q, message=\"your reply\")\n", replyTo, convID)
-		if isGroup {
-			sb.WriteString("   In a group thread you may also change `to` to address a specific participant.\n")
-		}
-		sb.WriteString("3. The context above is current — no need to call get_messages first.\n")
-		sb.WriteString("4. To CONTINUE: omit `done`. All participants are notified automatically.\n")
-		sb.WriteString("5. To END: set done=true only when the topic is genuinely exhausted.\n")
-		sb.WriteString("6. Be concise and substantive.\n")
-	}
-
-	return sb.String()
-}
-
 // Health handles GET /api/health.
 func (h *Handlers) Health(w http.ResponseWriter, r *http.Request) {
 	uptime := time.Since(h.StartTime)
 	resp := protocol.HealthResponse{
-		Status:    "ok",
-		Uptime:    uptime.Round(time.Second).String(),
-		UptimeSec: uptime.Seconds(),
-		Rooms:     h.Hub.RoomCount(),
+		Status:       "ok",
+		Uptime:       uptime.Round(time.Second).String(),
+		UptimeSec:    uptime.Seconds(),
+		Rooms:        h.Hub.RoomCount(),
+		RoomCreation: h.Hub.RoomCreation(),
 	}
 	writeJSON(w, http.StatusOK, resp)
 }
 
+// Version handles GET /api/version.
+func (h *Handlers) Version(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, buildinfo.Get())
+}
+
 // ListRooms handles GET /api/rooms.
 func (h *Handlers) ListRooms(w http.ResponseWriter, r *http.Request) {
 	snapshots := h.Hub.ListRooms()
-	rooms := make([]protocol.RoomInfo, len(snapshots))
-	for i, s := range snapshots {
-		rooms[i] = protocol.RoomInfo{
+	rooms := make([]protocol.RoomInfo, 0, len(snapshots))
+	for _, s := range snapshots {
+		if s.Visibility == VisibilityUnlisted || s.Visibility == VisibilityPrivate {
+			continue
+		}
+		if _, _, dm := protocol.DMMembers(s.Name); dm {
+			continue
+		}
+		rooms = append(rooms, protocol.RoomInfo{
 			Name:         s.Name,
 			Clients:      s.Clients,
 			MessageCount: s.MessageCount,
 			LastSeq:      s.LastSeq,
-		}
+		})
 	}
 	writeJSON(w, http.StatusOK, protocol.RoomList{Rooms: rooms})
 }
 
+// CreateRoom handles POST /api/rooms, the only way rooms come to exist under
+// RoomCreationExplicit.
+func (h *Handlers) CreateRoom(w http.ResponseWriter, r *http.Request) {
+	var req protocol.CreateRoomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %v", err))
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || strings.ContainsAny(req.Name, "/,") {
+		writeError(w, http.StatusBadRequest, "room name required, without / or ,")
+		return
+	}
+	if err := validVisibility(req.Visibility); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	room, err := h.Hub.CreateRoom(req.Name, RoomSettings{Visibility: req.Visibility})
+	if err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	s := room.Snapshot()
+	writeJSON(w, http.StatusCreated, protocol.RoomInfo{
+		Name:         s.Name,
+		Clients:      s.Clients,
+		MessageCount: s.MessageCount,
+		LastSeq:      s.LastSeq,
+		Visibility:   s.Visibility,
+		Token:        room.Settings().Token,
+	})
+}
+
+// openRoom returns the room for a request, or writes 404 under
+// RoomCreationExplicit if it doesn't exist.
+func (h *Handlers) openRoom(w http.ResponseWriter, name string) *Room {
+	room, err := h.Hub.OpenRoom(name)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return nil
+	}
+	return room
+}
+
 // SendMessage handles POST /api/rooms/{room}/messages.
 func (h *Handlers) SendMessage(w http.ResponseWriter, r *http.Request) {
 	roomName := r.PathValue("room")
@@ -171,27 +193,79 @@ func (h *Handlers) SendMessage(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "sender required")
 		return
 	}
+	if s, ok, _ := h.Hub.guest(r); ok && req.Sender != s.sender() {
+		writeError(w, http.StatusForbidden, fmt.Sprintf("this guest link sends as %q", s.sender()))
+		return
+	}
 	if req.Type == "" {
 		req.Type = protocol.TypeText
 	}
+	if !protocol.ValidPriority(req.Metadata["priority"]) {
+		writeError(w, http.StatusBadRequest, "priority must be low, normal or urgent")
+		return
+	}
+	if req.Type == protocol.TypeJSON && len(req.Payload.Data) == 0 && req.Metadata[e2e.Meta] == "" {
+		writeError(w, http.StatusBadRequest, "json messages need payload.data")
+		return
+	}
+	if req.TTL < 0 {
+		writeError(w, http.StatusBadRequest, "ttl must be a positive number of seconds")
+		return
+	}
 
-	// Auto-whisper: if sender is "X's Claude" and the message is marked private
+	// Auto-whisper: if sender is X's Claude and the message is marked private
 	// but has no explicit `to`, auto-route it to owner "X".
-	if req.Metadata["private"] == "true" && req.Metadata["to"] == "" &&
-		strings.HasSuffix(req.Sender, "'s Claude") {
-		owner := strings.TrimSuffix(req.Sender, "'s Claude")
+	if owner, ok := h.Hub.ClaudeOwner(req.Sender); ok && req.Metadata["private"] == "true" && req.Metadata["to"] == "" {
 		if req.Metadata == nil {
 			req.Metadata = map[string]string{}
 		}
 		req.Metadata["to"] = owner
 	}
+	// A message to "X's Claude" is for the name X picked for it, if any.
+	if to := req.Metadata["to"]; to != "" {
+		req.Metadata["to"] = h.Hub.ResolveClaude(to)
+	}
 
-	room := h.Hub.GetOrCreateRoom(roomName)
-	env := room.AddMessage(req.Sender, req.Type, req.Payload, req.Metadata)
+	room := h.openRoom(w, roomName)
+	if room == nil {
+		return
+	}
+	metadata, err := room.dmAddress(req.Sender, withExpiry(req.Metadata, req.TTL))
+	if err != nil {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	env, err := room.AddReply(req.Sender, req.Type, req.Payload, metadata, req.ReplyTo)
+	if errors.Is(err, ErrNoSeq) {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	env.Warning = room.WarnUndeliverable(env)
 	writeJSON(w, http.StatusCreated, env)
 }
 
+// withExpiry returns a copy of metadata stamped with MetaExpiresAt ttl seconds
+// from now, or metadata unchanged when ttl isn't positive.
+func withExpiry(metadata map[string]string, ttl int) map[string]string {
+	if ttl <= 0 {
+		return metadata
+	}
+	md := make(map[string]string, len(metadata)+1)
+	for k, v := range metadata {
+		md[k] = v
+	}
+	md[protocol.MetaExpiresAt] = time.Now().UTC().Add(time.Duration(ttl) * time.Second).Format(time.RFC3339Nano)
+	return md
+}
+
 // GetMessages handles GET /api/rooms/{room}/messages?after={seq}&limit={n}.
+// Optional filters: from, type, conv_id, match (regex), mentions (a name, or
+// "me" with sender). fields and truncate_payloads trim the payloads, leaving
+// a digest in their place; see protocol.PayloadOptions.
 func (h *Handlers) GetMessages(w http.ResponseWriter, r *http.Request) {
 	roomName := r.PathValue("room")
 	if roomName == "" {
@@ -225,14 +299,28 @@ func (h *Handlers) GetMessages(w http.ResponseWriter, r *http.Request) {
 		limit = n
 	}
 
-	msgs := room.MessagesAfter(after, limit)
+	filter, err := ParseFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	opts, err := protocol.PayloadOptionsFromQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	msgs := room.MessagesAfterMatching(after, limit, filter)
 	if msgs == nil {
 		msgs = []protocol.Envelope{}
 	}
+	opts.TrimAll(msgs)
 	writeJSON(w, http.StatusOK, protocol.MessageList{Room: roomName, Messages: msgs, Count: len(msgs)})
 }
 
 // LatestMessages handles GET /api/rooms/{room}/messages/latest?n={count}.
+// Accepts the same filters and payload options as GetMessages.
 func (h *Handlers) LatestMessages(w http.ResponseWriter, r *http.Request) {
 	roomName := r.PathValue("room")
 	if roomName == "" {
@@ -256,13 +344,77 @@ func (h *Handlers) LatestMessages(w http.ResponseWriter, r *http.Request) {
 		n = parsed
 	}
 
-	msgs := room.LatestMessages(n)
+	filter, err := ParseFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	opts, err := protocol.PayloadOptionsFromQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	msgs := room.LatestMatching(n, filter)
 	if msgs == nil {
 		msgs = []protocol.Envelope{}
 	}
+	opts.TrimAll(msgs)
 	writeJSON(w, http.StatusOK, protocol.MessageList{Room: roomName, Messages: msgs, Count: len(msgs)})
 }
 
+// GetThread handles GET /api/rooms/{room}/messages/{id}/thread: the thread
+// message id belongs to, root first, then every reply under it in seq order.
+// Accepts the payload options of GetMessages.
+func (h *Handlers) GetThread(w http.ResponseWriter, r *http.Request) {
+	roomName := r.PathValue("room")
+	if roomName == "" {
+		writeError(w, http.StatusBadRequest, "room name required")
+		return
+	}
+
+	opts, err := protocol.PayloadOptionsFromQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var msgs []protocol.Envelope
+	ok := false
+	if room := h.Hub.GetRoom(roomName); room != nil {
+		msgs, ok = room.Thread(r.PathValue("id"))
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "message not found")
+		return
+	}
+	opts.TrimAll(msgs)
+	writeJSON(w, http.StatusOK, protocol.MessageList{Room: roomName, Messages: msgs, Count: len(msgs)})
+}
+
+// GetMessage handles GET /api/rooms/{room}/messages/{id}: one message, by
+// ID or seq number, with its whole payload, for clients that read history
+// with its payloads trimmed.
+func (h *Handlers) GetMessage(w http.ResponseWriter, r *http.Request) {
+	roomName := r.PathValue("room")
+	if roomName == "" {
+		writeError(w, http.StatusBadRequest, "room name required")
+		return
+	}
+
+	var env protocol.Envelope
+	ok := false
+	if room := h.Hub.GetRoom(roomName); room != nil {
+		env, ok = room.Message(r.PathValue("id"))
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "message not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, env)
+}
+
 // HandleWS handles WS /ws/{room}?sender={name}.
 func (h *Handlers) HandleWS(w http.ResponseWriter, r *http.Request) {
 	roomName := r.PathValue("room")
@@ -274,7 +426,27 @@ func (h *Handlers) HandleWS(w http.ResponseWriter, r *http.Request) {
 	if sender == "" {
 		sender = "anonymous"
 	}
-	ServeWS(h.Hub, w, r, roomName, sender)
+	ServeWS(h.Hub, w, r, []string{roomName}, sender)
+}
+
+// HandleMultiWS handles WS /ws?rooms={a,b,c}&sender={name}: one connection
+// carrying events for several rooms.
+func (h *Handlers) HandleMultiWS(w http.ResponseWriter, r *http.Request) {
+	var rooms []string
+	for _, name := range strings.Split(r.URL.Query().Get("rooms"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			rooms = append(rooms, name)
+		}
+	}
+	if len(rooms) == 0 {
+		writeError(w, http.StatusBadRequest, "rooms parameter required")
+		return
+	}
+	sender := r.URL.Query().Get("sender")
+	if sender == "" {
+		sender = "anonymous"
+	}
+	ServeWS(h.Hub, w, r, rooms, sender)
 }
 
 // UploadFile handles POST /api/rooms/{room}/files (multipart form).
@@ -290,6 +462,11 @@ func (h *Handlers) UploadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	room := h.openRoom(w, roomName)
+	if room == nil {
+		return
+	}
+
 	// Parse multipart form (limit to FileStore's max + 1MB overhead).
 	if err := r.ParseMultipartForm(h.FileStore.maxFileSize + 1024*1024); err != nil {
 		writeError(w, http.StatusBadRequest, fmt.Sprintf("parse form: %v", err))
@@ -320,14 +497,19 @@ func (h *Handlers) UploadFile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Broadcast a file notification message to the room.
-	room := h.Hub.GetOrCreateRoom(roomName)
 	text := fmt.Sprintf("shared file: %s", info.Filename)
 	if description != "" {
 		text += " — " + description
 	}
-	room.AddMessage(sender, protocol.TypeFile, protocol.Payload{Text: text, FilePath: info.Filename}, map[string]string{
+	env, err := room.AddReply(sender, protocol.TypeFile, protocol.Payload{Text: text, FilePath: info.Filename}, map[string]string{
 		"file_id": info.ID,
-	})
+	}, "")
+	if err != nil {
+		h.FileStore.Delete(roomName, info.ID)
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	room.enqueue(Event{Kind: EventFileShared, File: info, Message: &env})
 
 	writeJSON(w, http.StatusCreated, info)
 }
@@ -345,7 +527,12 @@ func (h *Handlers) DownloadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Files are looked up by ID alone, so check the file is the room's: the
+	// URL's room is the one whose access was checked.
 	info, err := h.FileStore.Get(fileID)
+	if err == nil && info.Room != r.PathValue("room") {
+		err = fmt.Errorf("file not found: %s", fileID)
+	}
 	if err != nil {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
@@ -382,6 +569,35 @@ func (h *Handlers) ListFiles(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, protocol.FileList{Room: roomName, Files: files, Count: len(files)})
 }
 
+// DeleteFile handles DELETE /api/rooms/{room}/files/{id}.
+func (h *Handlers) DeleteFile(w http.ResponseWriter, r *http.Request) {
+	if h.FileStore == nil {
+		writeError(w, http.StatusServiceUnavailable, "file storage not configured")
+		return
+	}
+
+	roomName := r.PathValue("room")
+	fileID := r.PathValue("id")
+	if roomName == "" || fileID == "" {
+		writeError(w, http.StatusBadRequest, "room name and file id required")
+		return
+	}
+
+	info, err := h.FileStore.Delete(roomName, fileID)
+	if info == nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if room := h.Hub.GetRoom(roomName); room != nil {
+		room.enqueue(Event{Kind: EventFileDeleted, File: info})
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
 // ListParticipants handles GET /api/rooms/{room}/participants.
 func (h *Handlers) ListParticipants(w http.ResponseWriter, r *http.Request) {
 	roomName := r.PathValue("room")
@@ -397,6 +613,11 @@ func (h *Handlers) ListParticipants(w http.ResponseWriter, r *http.Request) {
 	}
 
 	participants := room.ListParticipants()
+	for i, p := range participants {
+		if owner, ok := h.Hub.ClaudeOwner(p.Name); ok {
+			participants[i].Owner = owner
+		}
+	}
 	writeJSON(w, http.StatusOK, protocol.ParticipantList{Room: roomName, Participants: participants})
 }
 
@@ -410,6 +631,64 @@ func writeError(w http.ResponseWriter, status int, msg string) {
 	writeJSON(w, status, map[string]string{"error": msg})
 }
 
+// GetSettings handles GET /api/rooms/{room}/settings.
+func (h *Handlers) GetSettings(w http.ResponseWriter, r *http.Request) {
+	room := h.Hub.GetRoom(r.PathValue("room"))
+	if room == nil {
+		writeError(w, http.StatusNotFound, "room not found")
+		return
+	}
+	settings := room.Settings()
+	if _, guest, _ := h.Hub.guest(r); guest {
+		settings.Token = "" // a guest link grants less than the token would
+	}
+	writeJSON(w, http.StatusOK, settings)
+}
+
+// UpdateSettings handles PUT /api/rooms/{room}/settings.
+func (h *Handlers) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	roomName := r.PathValue("room")
+	if roomName == "" {
+		writeError(w, http.StatusBadRequest, "room name required")
+		return
+	}
+
+	var req RoomSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %v", err))
+		return
+	}
+	if err := validVisibility(req.Visibility); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err := prompt.Parse(req.Prompt); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.WorkDir != "" {
+		if h.Runner == nil {
+			writeError(w, http.StatusBadRequest, "work_dir requires the Claude runner")
+			return
+		}
+		dir, err := h.Runner.ResolveWorkDir(req.WorkDir)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		req.WorkDir = dir
+	}
+
+	room := h.openRoom(w, roomName)
+	if room == nil {
+		return
+	}
+	if req.Visibility == "" {
+		req.Visibility = room.Settings().Visibility // unchanged unless given
+	}
+	writeJSON(w, http.StatusOK, room.SetSettings(req))
+}
+
 // SpawnClaude handles POST /api/rooms/{room}/spawn.
 func (h *Handlers) SpawnClaude(w http.ResponseWriter, r *http.Request) {
 	if h.Runner == nil {
@@ -424,26 +703,61 @@ func (h *Handlers) SpawnClaude(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Sender string `json:"sender"`
-		Prompt string `json:"prompt"`
+		Sender  string `json:"sender"`
+		Name    string `json:"name"` // the Claude's name; "" = "{sender}'s Claude"
+		Prompt  string `json:"prompt"`
+		Preset  string `json:"preset"`   // a preset from spawn/options; Prompt is its {input}
+		WorkDir string `json:"work_dir"` // overrides the room's work_dir setting
+		runner.Options
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %v", err))
 		return
 	}
+	if req.Preset != "" {
+		preset, ok := h.Runner.Preset(req.Preset)
+		if !ok {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown preset %q", req.Preset))
+			return
+		}
+		req.Prompt = runner.ExpandPreset(preset, req.Prompt)
+		if req.Model == "" {
+			req.Model = preset.Model
+		}
+	}
 	if req.Sender == "" || req.Prompt == "" {
 		writeError(w, http.StatusBadRequest, "sender and prompt required")
 		return
 	}
+	if err := req.Options.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	room := h.openRoom(w, roomName)
+	if room == nil {
+		return
+	}
+	claudeName, err := h.Hub.NameClaude(req.Sender, req.Name)
+	if err != nil {
+		writeError(w, claudeNameStatus(err), err.Error())
+		return
+	}
 
-	room := h.Hub.GetOrCreateRoom(roomName)
-	claudeName := req.Sender + "'s Claude"
+	if req.WorkDir == "" {
+		req.WorkDir = room.Settings().WorkDir
+	}
+	if _, err := h.Runner.ResolveWorkDir(req.WorkDir); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	// Ensure a spawn hook is registered so directed replies from other Claudes
 	// trigger automatic re-spawns (mirrors watcher daemon behavior in web mode).
 	hookVal, _ := h.hookStates.LoadOrStore(claudeName, &hostHookState{
 		rnr:           h.Runner,
 		room:          roomName,
+		settings:      room.Settings,
 		sender:        req.Sender,
 		claudeName:    claudeName,
 		pendingSpawns: make(map[string]*protocol.SpawnReq),
@@ -451,7 +765,11 @@ func (h *Handlers) SpawnClaude(w http.ResponseWriter, r *http.Request) {
 	room.RegisterSpawnHook(claudeName, hookVal.(*hostHookState).trySpawn)
 
 	// Try to start a session (no conv_id for user-initiated spawns).
-	_, cancel, err := h.Runner.Sessions().Start(roomName, req.Sender, "")
+	ctx, cancel, err := h.Runner.Sessions().Start(roomName, req.Sender, "")
+	if errors.Is(err, runner.ErrDraining) {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusConflict, err.Error())
 		return
@@ -469,16 +787,24 @@ func (h *Handlers) SpawnClaude(w http.ResponseWriter, r *http.Request) {
 	go func() {
 		defer cancel()
 		defer h.Runner.Sessions().End(roomName, req.Sender, "")
-		defer room.UntrackParticipant(claudeName)
+		defer room.UntrackParticipant(claudeName, nil)
 
 		params := runner.SpawnParams{
-			Room:   roomName,
-			Sender: req.Sender,
-			Prompt: req.Prompt,
+			Room:    roomName,
+			Sender:  req.Sender,
+			Name:    claudeName,
+			Prompt:  req.Prompt,
+			Ctx:     ctx,
+			Options: req.Options,
+			WorkDir: req.WorkDir,
+			Charter: room.Charter().Text,
 		}
 
 		if err := h.Runner.Spawn(params); err != nil {
 			log.Printf("spawn error room=%s sender=%s: %v", roomName, req.Sender, err)
+			if errors.Is(err, runner.ErrTimeout) || errors.Is(err, runner.ErrStopped) {
+				return // the runner already announced it
+			}
 			room.AddMessage("system", protocol.TypeSystem, protocol.Payload{
 				Text: claudeName + " encountered an error: " + err.Error(),
 			}, nil)
@@ -488,7 +814,66 @@ func (h *Handlers) SpawnClaude(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusAccepted, map[string]string{"status": "spawning", "claude": claudeName})
 }
 
-// StopClaude handles POST /api/rooms/{room}/stop.
+// ListSpawns handles GET /api/rooms/{room}/spawns?limit={n}.
+func (h *Handlers) ListSpawns(w http.ResponseWriter, r *http.Request) {
+	if h.Runner == nil {
+		writeError(w, http.StatusServiceUnavailable, "Claude runner not configured")
+		return
+	}
+	roomName := r.PathValue("room")
+	if roomName == "" {
+		writeError(w, http.StatusBadRequest, "room name required")
+		return
+	}
+
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			limit = n
+		}
+	}
+
+	spawns := h.Runner.Audit().List(roomName, limit)
+	writeJSON(w, http.StatusOK, protocol.SpawnList{Room: roomName, Spawns: spawns, Count: len(spawns)})
+}
+
+// RunnerStats handles GET /api/runner/stats.
+func (h *Handlers) RunnerStats(w http.ResponseWriter, r *http.Request) {
+	if h.Runner == nil {
+		writeError(w, http.StatusServiceUnavailable, "Claude runner not configured")
+		return
+	}
+	writeJSON(w, http.StatusOK, h.Runner.Stats())
+}
+
+// SpawnOptions handles GET /api/rooms/{room}/spawn/options: the models,
+// prompt presets and work directories a spawn in the room may pick.
+func (h *Handlers) SpawnOptions(w http.ResponseWriter, r *http.Request) {
+	if h.Runner == nil {
+		writeError(w, http.StatusServiceUnavailable, "Claude runner not configured")
+		return
+	}
+	workDir := ""
+	if room := h.Hub.GetRoom(r.PathValue("room")); room != nil {
+		if dir, err := h.Runner.ResolveWorkDir(room.Settings().WorkDir); err == nil {
+			workDir = dir
+		}
+	}
+	writeJSON(w, http.StatusOK, h.Runner.Choices(workDir))
+}
+
+// ListSessions handles GET /api/rooms/{room}/sessions.
+func (h *Handlers) ListSessions(w http.ResponseWriter, r *http.Request) {
+	if h.Runner == nil {
+		writeError(w, http.StatusServiceUnavailable, "Claude runner not configured")
+		return
+	}
+	roomName := r.PathValue("room")
+	writeJSON(w, http.StatusOK, protocol.SessionList{Room: roomName, Sessions: h.Runner.Sessions().List(roomName)})
+}
+
+// StopClaude handles POST /api/rooms/{room}/stop. With conv_id set, only that
+// thread's session is stopped; otherwise all of the sender's sessions are.
 func (h *Handlers) StopClaude(w http.ResponseWriter, r *http.Request) {
 	if h.Runner == nil {
 		writeError(w, http.StatusServiceUnavailable, "Claude runner not configured")
@@ -502,7 +887,8 @@ func (h *Handlers) StopClaude(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Sender string `json:"sender"`
+		Sender string  `json:"sender"`
+		ConvID *string `json:"conv_id"` // stop only this thread ("" = the directly started session)
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %v", err))
@@ -513,25 +899,31 @@ func (h *Handlers) StopClaude(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.ConvID != nil {
+		if err := h.Runner.Sessions().StopConv(roomName, req.Sender, *req.ConvID); err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "stopped", "conv_id": *req.ConvID})
+		return
+	}
+
 	if err := h.Runner.Sessions().Stop(roomName, req.Sender); err != nil {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
 
-	claudeName := req.Sender + "'s Claude"
-	room := h.Hub.GetRoom(roomName)
-	if room != nil {
+	// The runner posts the "was stopped" notice as each process is killed.
+	claudeName := h.Hub.ClaudeFor(req.Sender)
+	if room := h.Hub.GetRoom(roomName); room != nil {
 		room.UnregisterSpawnHook(claudeName)
-		room.AddMessage("system", protocol.TypeSystem, protocol.Payload{
-			Text: claudeName + " was stopped",
-		}, nil)
 	}
 	h.hookStates.Delete(claudeName)
 
 	writeJSON(w, http.StatusOK, map[string]string{"status": "stopped"})
 }
 
-// GenerateSynopsis handles POST /api/rooms/{room}/synopsis.
+// GenerateSynopsis handles POST /api/rooms/{room}/synopsis?format={markdown|json|html|slack}.
 func (h *Handlers) GenerateSynopsis(w http.ResponseWriter, r *http.Request) {
 	roomName := r.PathValue("room")
 	if roomName == "" {
@@ -551,10 +943,15 @@ func (h *Handlers) GenerateSynopsis(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	content := synopsis.Build(roomName, msgs)
+	format := r.URL.Query().Get("format")
+	content, err := synopsis.Render(format, roomName, msgs)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", roomName+"-synopsis.md"))
+	w.Header().Set("Content-Type", synopsis.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", roomName+"-synopsis"+synopsis.Extension(format)))
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(content))
 }
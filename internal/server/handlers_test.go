@@ -0,0 +1,54 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+func TestDownloadFileScopedToRoom(t *testing.T) {
+	files, err := NewFileStore(filepath.Join(t.TempDir(), "files"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := NewHub(100)
+	mux := apiRoutes(&Handlers{Hub: hub, FileStore: files})
+
+	token := hub.GetOrCreateRoom("secret").SetSettings(RoomSettings{Visibility: VisibilityPrivate}).Token
+	hub.GetOrCreateRoom("pub")
+	info := shareFile(t, hub.GetRoom("secret"), files, "plans.txt")
+	link, err := hub.NewShare("pub", ShareRead, "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(path string, header map[string]string) int {
+		r := httptest.NewRequest("GET", path, nil)
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w.Code
+	}
+	tests := []struct {
+		name   string
+		path   string
+		header map[string]string
+		want   int
+	}{
+		{"through a public room", "/api/rooms/pub/files/" + info.ID, nil, http.StatusNotFound},
+		{"through another room's share link", "/api/rooms/pub/files/" + info.ID, map[string]string{protocol.ShareHeader: link.Token}, http.StatusNotFound},
+		{"without the token", "/api/rooms/secret/files/" + info.ID, nil, http.StatusForbidden},
+		{"with the token", "/api/rooms/secret/files/" + info.ID, map[string]string{protocol.RoomTokenHeader: token}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if got := get(tt.path, tt.header); got != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/corvino/claudetalk/internal/rate"
+	"github.com/corvino/claudetalk/internal/runner"
+)
+
+// AddHealthCheck adds a check GET /api/health/ready runs and reports under
+// name, such as the host's tunnel. A failing check makes the server
+// degraded, not unready.
+func (h *Hub) AddHealthCheck(name string, check func() error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.checks == nil {
+		h.checks = make(map[string]func() error)
+	}
+	h.checks[name] = check
+}
+
+// healthChecks returns the checks AddHealthCheck added.
+func (h *Hub) healthChecks() map[string]func() error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	checks := make(map[string]func() error, len(h.checks))
+	for name, check := range h.checks {
+		checks[name] = check
+	}
+	return checks
+}
+
+// CheckWritable returns why files can't be stored, or nil.
+func (fs *FileStore) CheckWritable() error {
+	f, err := os.CreateTemp(fs.baseDir, ".health-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// Live handles GET /api/health/live: the process is up and serving.
+func (h *Handlers) Live(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Ready handles GET /api/health/ready: whether the server should get
+// traffic, with the state of what it depends on. It answers 503 while
+// storage isn't writable or the server is shutting down.
+func (h *Handlers) Ready(w http.ResponseWriter, r *http.Request) {
+	uptime := time.Since(h.StartTime)
+	resp := protocol.ReadinessResponse{
+		Status:    protocol.ReadinessReady,
+		Uptime:    uptime.Round(time.Second).String(),
+		UptimeSec: uptime.Seconds(),
+		Draining:  h.Hub.draining.Load(),
+		Checks:    make(map[string]protocol.HealthCheck),
+		Rooms:     h.Hub.RoomCount(),
+		Window:    runner.RecentWindow.String(),
+		Requests:  protocol.NewErrorRate(h.requests.Counts()),
+	}
+	check := func(name string, err error) {
+		c := protocol.HealthCheck{OK: err == nil}
+		if err != nil {
+			c.Error = err.Error()
+			resp.Status = protocol.ReadinessDegraded
+		}
+		resp.Checks[name] = c
+	}
+
+	for name, fn := range h.Hub.healthChecks() {
+		check(name, fn())
+	}
+	if h.Runner != nil {
+		check("agent", h.Runner.Check())
+		st := h.Runner.Stats()
+		resp.Sessions = h.Runner.Sessions().Active()
+		resp.Running, resp.Queued = st.Running, st.Queued
+		resp.Spawns = protocol.NewErrorRate(st.Recent, st.RecentFailed)
+	}
+	var storage error
+	if h.FileStore != nil {
+		storage = h.FileStore.CheckWritable()
+		check("storage", storage)
+	}
+
+	status := http.StatusOK
+	if storage != nil || resp.Draining {
+		resp.Status = protocol.ReadinessNotReady
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, resp)
+}
+
+// statusRecorder records the status of a response for the request error
+// rate. It passes hijacking through for WebSockets, and Unwrap lets
+// http.ResponseController reach the rest.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(rec.ResponseWriter).Hijack()
+}
+
+func (rec *statusRecorder) Unwrap() http.ResponseWriter { return rec.ResponseWriter }
+
+// countErrors counts every request in requests, failed if it was answered
+// with a 5xx.
+func countErrors(requests *rate.Window, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		requests.Add(rec.status >= 500)
+	})
+}
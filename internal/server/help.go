@@ -0,0 +1,101 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/google/uuid"
+)
+
+var (
+	errNoWatcher  = errors.New("has no daemon or watcher to spawn a Claude")
+	errHelpDenied = errors.New("does not accept help requests")
+)
+
+// helpTarget finds how to spawn name's Claude: its daemon connection, or a
+// spawn hook for host-mode participants.
+func (r *Room) helpTarget(name string) (*Client, func(*protocol.SpawnReq), error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if ps, ok := r.participants[name]; ok && ps.Connected && ps.Role == "daemon" && ps.Client != nil {
+		if ps.Client.helpPolicy == protocol.HelpDeny {
+			return nil, nil, errHelpDenied
+		}
+		return ps.Client, nil, nil
+	}
+	if hook, ok := r.spawnHooks[name]; ok {
+		return nil, hook, nil
+	}
+	return nil, nil, errNoWatcher
+}
+
+// RequestHelp handles POST /api/rooms/{room}/help. It records the task as a
+// message from the requester and spawns the target's Claude for it, subject
+// to the policy the target's watcher advertised.
+func (h *Handlers) RequestHelp(w http.ResponseWriter, r *http.Request) {
+	roomName := r.PathValue("room")
+	var req protocol.HelpRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %v", err))
+		return
+	}
+	if req.Sender == "" || req.To == "" || req.Task == "" {
+		writeError(w, http.StatusBadRequest, "sender, to and task are required")
+		return
+	}
+	if req.To == req.Sender {
+		writeError(w, http.StatusBadRequest, "cannot request help from yourself")
+		return
+	}
+
+	room := h.Hub.GetRoom(roomName)
+	if room == nil {
+		writeError(w, http.StatusNotFound, "room not found")
+		return
+	}
+	dc, hook, err := room.helpTarget(req.To)
+	if err != nil {
+		status := http.StatusConflict
+		if errors.Is(err, errHelpDenied) {
+			status = http.StatusForbidden
+		}
+		writeError(w, status, req.To+" "+err.Error())
+		return
+	}
+
+	if req.ConvID == "" {
+		req.ConvID = uuid.New().String()
+	}
+	env, err := room.AddReply(req.Sender, protocol.TypeText, protocol.Payload{Text: req.Task}, map[string]string{
+		"to":           req.To,
+		"conv_id":      req.ConvID,
+		"help_request": "true",
+	}, "")
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	ctx, summary := room.SpawnContext(env)
+	spawn := &protocol.SpawnReq{
+		Reason:          "help_request",
+		Trigger:         &env,
+		Context:         ctx,
+		Summary:         summary,
+		Profiles:        room.Profiles(),
+		PromptTemplates: room.PromptTemplates(),
+		Charter:         room.Charter().Text,
+	}
+	log.Printf("help request: %s asked %s in %s", req.Sender, req.To, roomName)
+	if dc != nil {
+		dc.sendSpawn(spawn)
+	} else {
+		go hook(spawn)
+	}
+	h.Hub.Events().Publish(Event{Kind: EventSpawn, Room: roomName, Spawn: spawn, Target: req.To})
+
+	writeJSON(w, http.StatusAccepted, protocol.HelpResponse{ConvID: req.ConvID, Seq: env.SeqNum})
+}
@@ -1,14 +1,50 @@
 package server
 
-import "sync"
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
 
 // Hub manages all active rooms.
 type Hub struct {
 	mu         sync.RWMutex
 	rooms      map[string]*Room
 	maxHistory int
+	moderated  bool              // enforce round-robin turns in group threads
+	explicit   bool              // rooms exist only once created; see SetRoomCreation
+	offlineTTL time.Duration     // how long spawns for offline daemons wait; see SetOfflineTTL
+	promptTmpl string            // server-wide prompt overrides; see SetPromptTemplate
+	claudes    map[string]string // picked Claude name → owner; see NameClaude
+	agents     map[string]string // agent name → owner; see NameAgent
+	shareKey   []byte            // signs share links; see SetShareSecret
+	events     *Bus
+	cluster    Cluster                 // nil for a single instance
+	checks     map[string]func() error // see AddHealthCheck
+	draining   atomic.Bool             // set by Shutdown; see Handlers.Ready
+	drained    chan struct{}           // closed by Shutdown, ending event streams
+
+	invitesMu sync.Mutex
+	invites   map[string]protocol.Invite // by code
 }
 
+// Room creation policies for SetRoomCreation.
+const (
+	RoomCreationAuto     = "auto"     // sending to or joining a room creates it
+	RoomCreationExplicit = "explicit" // only CreateRoom (POST /api/rooms) does
+)
+
+// ErrNoSuchRoom is returned by OpenRoom for a room that hasn't been created
+// under RoomCreationExplicit.
+var ErrNoSuchRoom = errors.New("no such room; create it first (POST /api/rooms or claudetalk rooms create)")
+
+// ErrRoomExists is returned by CreateRoom for a room that already exists.
+var ErrRoomExists = errors.New("room already exists")
+
 // NewHub creates a new Hub with the given max history per room.
 func NewHub(maxHistory int) *Hub {
 	if maxHistory <= 0 {
@@ -17,10 +53,65 @@ func NewHub(maxHistory int) *Hub {
 	return &Hub{
 		rooms:      make(map[string]*Room),
 		maxHistory: maxHistory,
+		offlineTTL: DefaultOfflineTTL,
+		claudes:    make(map[string]string),
+		agents:     make(map[string]string),
+		shareKey:   newShareKey(),
+		events:     NewBus(),
+		drained:    make(chan struct{}),
+		invites:    make(map[string]protocol.Invite),
+	}
+}
+
+// SetModerated enables or disables turn-taking for rooms created after the call.
+func (h *Hub) SetModerated(on bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.moderated = on
+}
+
+// SetRoomCreation sets how rooms come to exist: RoomCreationAuto or
+// RoomCreationExplicit.
+func (h *Hub) SetRoomCreation(policy string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch policy {
+	case RoomCreationAuto, RoomCreationExplicit:
+		h.explicit = policy == RoomCreationExplicit
+		return nil
+	}
+	return fmt.Errorf("room creation policy must be %s or %s, got %q", RoomCreationAuto, RoomCreationExplicit, policy)
+}
+
+// RoomCreation returns the hub's room creation policy.
+func (h *Hub) RoomCreation() string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.explicit {
+		return RoomCreationExplicit
 	}
+	return RoomCreationAuto
 }
 
-// GetOrCreateRoom returns the room with the given name, creating it if needed.
+// Events returns the bus every room publishes its events on.
+func (h *Hub) Events() *Bus {
+	return h.events
+}
+
+// Observe registers fn to be called for every message added to any room on
+// this instance, so in a cluster each message is observed once. It is
+// shorthand for subscribing to EventMessage; fn must not block.
+func (h *Hub) Observe(fn func(protocol.Envelope)) {
+	h.events.Subscribe(func(ev Event) {
+		if !ev.Remote {
+			fn(*ev.Message)
+		}
+	}, EventMessage)
+}
+
+// GetOrCreateRoom returns the room with the given name, creating it if
+// needed whatever the room creation policy. Requests from participants go
+// through OpenRoom instead.
 func (h *Hub) GetOrCreateRoom(name string) *Room {
 	h.mu.RLock()
 	r, ok := h.rooms[name]
@@ -35,7 +126,54 @@ func (h *Hub) GetOrCreateRoom(name string) *Room {
 	if r, ok = h.rooms[name]; ok {
 		return r
 	}
-	r = NewRoom(name, h.maxHistory)
+	return h.createLocked(name)
+}
+
+// OpenRoom returns the room for a participant sending to, joining or
+// configuring it. Under RoomCreationExplicit it returns ErrNoSuchRoom rather
+// than creating a room, so a mistyped name fails instead of starting a room
+// nobody else is in; direct message rooms are created all the same.
+func (h *Hub) OpenRoom(name string) (*Room, error) {
+	h.mu.RLock()
+	r, explicit := h.rooms[name], h.explicit
+	h.mu.RUnlock()
+	if r != nil {
+		return r, nil
+	}
+	if _, _, dm := protocol.DMMembers(name); explicit && !dm {
+		return nil, fmt.Errorf("room %q: %w", name, ErrNoSuchRoom)
+	}
+	return h.GetOrCreateRoom(name), nil
+}
+
+// CreateRoom creates a room with settings s, or returns ErrRoomExists. The
+// room is private, say, before anyone can find it.
+func (h *Hub) CreateRoom(name string, s RoomSettings) (*Room, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.rooms[name]; ok {
+		return nil, fmt.Errorf("room %q: %w", name, ErrRoomExists)
+	}
+	r := h.createLocked(name)
+	r.SetSettings(s)
+	r.mu.Lock()
+	r.enqueue(Event{Kind: EventRoomCreated})
+	r.mu.Unlock()
+	return r, nil
+}
+
+// createLocked creates and registers a room. h.mu must be held.
+func (h *Hub) createLocked(name string) *Room {
+	r := NewRoom(name, h.maxHistory)
+	r.moderated = h.moderated
+	r.offlineTTL = h.offlineTTL
+	r.promptTmpl = h.promptTmpl
+	r.events = h.events
+	r.hub = h
+	if h.cluster != nil {
+		r.cluster = h.cluster
+		r.restore(h.maxHistory)
+	}
 	h.rooms[name] = r
 	return r
 }
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// DefaultInviteTTL is how long an invite lasts when its creator doesn't say.
+const DefaultInviteTTL = 7 * 24 * time.Hour
+
+// maxInviteTTL caps how long an invite may last.
+const maxInviteTTL = 90 * 24 * time.Hour
+
+// NewInvite creates an invite to an existing room, valid for ttl.
+func (h *Hub) NewInvite(room, createdBy string, ttl time.Duration) (protocol.Invite, error) {
+	if h.GetRoom(room) == nil {
+		return protocol.Invite{}, fmt.Errorf("room %q: %w", room, ErrNoSuchRoom)
+	}
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return protocol.Invite{}, err
+	}
+	inv := protocol.Invite{
+		Code:      hex.EncodeToString(b),
+		Room:      room,
+		CreatedBy: createdBy,
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	}
+
+	h.invitesMu.Lock()
+	defer h.invitesMu.Unlock()
+	now := time.Now()
+	for code, old := range h.invites {
+		if !old.ExpiresAt.After(now) {
+			delete(h.invites, code)
+		}
+	}
+	h.invites[inv.Code] = inv
+	return inv, nil
+}
+
+// Invite returns the unexpired invite with the given code.
+func (h *Hub) Invite(code string) (protocol.Invite, bool) {
+	h.invitesMu.Lock()
+	defer h.invitesMu.Unlock()
+	inv, ok := h.invites[code]
+	if !ok || !inv.ExpiresAt.After(time.Now()) {
+		return protocol.Invite{}, false
+	}
+	return inv, true
+}
+
+// CreateInvite handles POST /api/rooms/{room}/invites with an optional body
+// {"sender": ..., "ttl": seconds}.
+func (h *Handlers) CreateInvite(w http.ResponseWriter, r *http.Request) {
+	roomName := r.PathValue("room")
+	if roomName == "" {
+		writeError(w, http.StatusBadRequest, "room name required")
+		return
+	}
+	var req struct {
+		Sender string `json:"sender"`
+		TTL    int    `json:"ttl"` // seconds; 0 = DefaultInviteTTL
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %v", err))
+			return
+		}
+	}
+	ttl := DefaultInviteTTL
+	if req.TTL > 0 {
+		ttl = time.Duration(req.TTL) * time.Second
+	}
+	if req.TTL < 0 || ttl > maxInviteTTL {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("ttl must be between 1 second and %s", maxInviteTTL))
+		return
+	}
+
+	inv, err := h.Hub.NewInvite(roomName, req.Sender, ttl)
+	if errors.Is(err, ErrNoSuchRoom) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, inv)
+}
+
+// GetInvite handles GET /api/invites/{code}. The code is the credential, so
+// for a private room the answer includes the room's token.
+func (h *Handlers) GetInvite(w http.ResponseWriter, r *http.Request) {
+	inv, ok := h.Hub.Invite(r.PathValue("code"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "no such invite, or it has expired")
+		return
+	}
+	if room := h.Hub.GetRoom(inv.Room); room != nil {
+		inv.Token = room.Settings().Token
+	}
+	writeJSON(w, http.StatusOK, inv)
+}
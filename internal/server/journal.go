@@ -0,0 +1,471 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// journalSync is how often the journal is flushed and fsynced: a crash
+// loses at most this much.
+const journalSync = time.Second
+
+// journalExt ends each room's journal file name.
+const journalExt = ".ndjson"
+
+// journalQueue is how many events may wait for the journal's writer.
+const journalQueue = 4096
+
+// journalRecord is one line of a room's journal: the room's settings and
+// charter, with its last seq when the file was compacted, a message, or a
+// message again once stamped, a shared file, or the ID of a message that
+// expired or of a file that was deleted.
+type journalRecord struct {
+	Settings    *RoomSettings      `json:"settings,omitempty"`
+	Charter     *protocol.Charter  `json:"charter,omitempty"`
+	LastSeq     int64              `json:"last_seq,omitempty"`
+	Message     *protocol.Envelope `json:"message,omitempty"`
+	File        *protocol.FileInfo `json:"file,omitempty"`
+	Expired     string             `json:"expired,omitempty"`
+	FileDeleted string             `json:"file_deleted,omitempty"`
+}
+
+// Journal is an append-only log of what happens in each room, one NDJSON
+// file per room in a directory, fsynced every journalSync. A server replays
+// it at startup, so a crash loses a second of messages rather than
+// everything since the last SaveState. A room's file is rewritten from the
+// room's state at startup and whenever it grows past twice the room's
+// history, so it stays about as big as what it restores. Like SaveState, it
+// is for a single instance without -redis.
+//
+// Events are queued for a writer goroutine, which does all the file I/O, so
+// rooms' dispatchers never wait on the disk. If the queue overflows, the
+// room is compacted at the next sync instead, which writes what was dropped.
+type Journal struct {
+	dir   string
+	hub   *Hub
+	files *FileStore
+
+	events chan Event
+
+	lostMu sync.Mutex
+	lost   map[string]bool // rooms whose events were dropped
+
+	mu    sync.Mutex // held by the writer; guards rooms
+	rooms map[string]*journalFile
+
+	unsubscribe func()
+	done        chan struct{}
+	stopped     chan struct{}
+}
+
+// journalFile is one room's open journal.
+type journalFile struct {
+	f       *os.File
+	w       *bufio.Writer
+	records int
+	dirty   bool // written since the last sync
+}
+
+// OpenJournal replays the journal in dir into hub, with shared files'
+// metadata into files if it is non-nil, and then journals hub's rooms there
+// until Close. Call it after LoadState: replayed messages continue a
+// restored room's history. It returns how many rooms it replayed.
+func OpenJournal(dir string, hub *Hub, files *FileStore) (*Journal, int, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, 0, fmt.Errorf("journal: %w", err)
+	}
+	j := &Journal{
+		dir:     dir,
+		hub:     hub,
+		files:   files,
+		events:  make(chan Event, journalQueue),
+		lost:    make(map[string]bool),
+		rooms:   make(map[string]*journalFile),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	n, err := j.replay()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Start each room's file afresh from the state just restored.
+	j.mu.Lock()
+	for _, snap := range hub.ListRooms() {
+		if err := j.compactLocked(snap.Name); err != nil {
+			j.closeLocked()
+			j.mu.Unlock()
+			return nil, 0, err
+		}
+	}
+	j.mu.Unlock()
+
+	j.unsubscribe = hub.Events().Subscribe(j.record, EventMessage, EventMessageStamped, EventMessageExpired, EventFileShared, EventFileDeleted, EventRoomCreated, EventRoomUpdated)
+	go j.run()
+	return j, n, nil
+}
+
+// Close stops journaling, writes what is queued and syncs it.
+func (j *Journal) Close() error {
+	j.unsubscribe()
+	close(j.done)
+	<-j.stopped
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.closeLocked()
+}
+
+// path is room's journal file.
+func (j *Journal) path(room string) string {
+	return filepath.Join(j.dir, url.QueryEscape(room)+journalExt)
+}
+
+// record queues ev for the writer. It is a bus subscriber.
+func (j *Journal) record(ev Event) {
+	if ev.Remote {
+		return
+	}
+	select {
+	case j.events <- ev:
+	default:
+		j.lostMu.Lock()
+		if !j.lost[ev.Room] {
+			log.Printf("journal: queue full; room=%s will be rewritten at the next sync", ev.Room)
+		}
+		j.lost[ev.Room] = true
+		j.lostMu.Unlock()
+	}
+}
+
+// writeLocked appends ev to its room's journal. The caller holds j.mu.
+func (j *Journal) writeLocked(ev Event) {
+	var rec journalRecord
+	switch ev.Kind {
+	case EventMessage, EventMessageStamped:
+		rec.Message = ev.Message
+	case EventMessageExpired:
+		rec.Expired = ev.Message.ID
+	case EventFileShared:
+		rec.File = ev.File
+	case EventFileDeleted:
+		rec.FileDeleted = ev.File.ID
+	case EventRoomCreated, EventRoomUpdated:
+		// Read by the writer, so the last record written is the latest
+		// state even when a compaction gets in between.
+		r := j.hub.GetRoom(ev.Room)
+		if r == nil {
+			return
+		}
+		settings, charter := r.Settings(), r.Charter()
+		rec.Settings, rec.Charter = &settings, &charter
+	}
+	if err := j.appendLocked(ev.Room, rec); err != nil {
+		log.Printf("journal: room=%s: %v", ev.Room, err)
+	}
+}
+
+// appendLocked writes rec to room's journal, opening it if need be. The
+// caller holds j.mu.
+func (j *Journal) appendLocked(room string, rec journalRecord) error {
+	jf := j.rooms[room]
+	if jf == nil {
+		f, err := os.OpenFile(j.path(room), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return err
+		}
+		jf = &journalFile{f: f, w: bufio.NewWriter(f)}
+		j.rooms[room] = jf
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	jf.w.Write(data)
+	if err := jf.w.WriteByte('\n'); err != nil {
+		return err
+	}
+	jf.records++
+	jf.dirty = true
+	return nil
+}
+
+// run is the writer: it writes queued events and syncs the journal every
+// journalSync until Close, then writes what is still queued.
+func (j *Journal) run() {
+	defer close(j.stopped)
+	ticker := time.NewTicker(journalSync)
+	defer ticker.Stop()
+	for {
+		select {
+		case ev := <-j.events:
+			j.mu.Lock()
+			j.writeLocked(ev)
+			j.mu.Unlock()
+		case <-ticker.C:
+			j.mu.Lock()
+			j.syncLocked()
+			j.mu.Unlock()
+		case <-j.done:
+			j.mu.Lock()
+			defer j.mu.Unlock()
+			for {
+				select {
+				case ev := <-j.events:
+					j.writeLocked(ev)
+				default:
+					j.compactLostLocked()
+					return
+				}
+			}
+		}
+	}
+}
+
+// syncLocked syncs every room's journal, rewriting the files that have grown
+// too long or missed events. The caller holds j.mu.
+func (j *Journal) syncLocked() {
+	j.compactLostLocked()
+	for room, jf := range j.rooms {
+		if jf.records > 2*j.hub.maxHistory {
+			if err := j.compactLocked(room); err != nil {
+				log.Printf("journal: room=%s: compact: %v", room, err)
+			}
+			continue
+		}
+		if err := jf.sync(); err != nil {
+			log.Printf("journal: room=%s: %v", room, err)
+		}
+	}
+}
+
+// compactLostLocked rewrites the journals of rooms whose events were
+// dropped, from their state. The caller holds j.mu.
+func (j *Journal) compactLostLocked() {
+	j.lostMu.Lock()
+	lost := j.lost
+	j.lost = make(map[string]bool)
+	j.lostMu.Unlock()
+	for room := range lost {
+		if err := j.compactLocked(room); err != nil {
+			log.Printf("journal: room=%s: compact: %v", room, err)
+		}
+	}
+}
+
+// sync flushes and fsyncs the file if it was written since the last sync.
+func (jf *journalFile) sync() error {
+	if !jf.dirty {
+		return nil
+	}
+	if err := jf.w.Flush(); err != nil {
+		return err
+	}
+	jf.dirty = false
+	return jf.f.Sync()
+}
+
+// compactLocked rewrites room's journal as its settings, charter, files and
+// history, replacing the file whole. The caller holds j.mu.
+func (j *Journal) compactLocked(room string) error {
+	r := j.hub.GetRoom(room)
+	if r == nil {
+		return nil
+	}
+	settings, charter := r.Settings(), r.Charter()
+	msgs, lastSeq := r.History()
+	recs := []journalRecord{{Settings: &settings, Charter: &charter, LastSeq: lastSeq}}
+	if j.files != nil {
+		for _, info := range j.files.List(room) {
+			recs = append(recs, journalRecord{File: &info})
+		}
+	}
+	for i := range msgs {
+		recs = append(recs, journalRecord{Message: &msgs[i]})
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, rec := range recs {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	// Write and rename, so a crash mid-write leaves the old journal whole.
+	path := j.path(room)
+	tmp, err := os.CreateTemp(j.dir, filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if jf := j.rooms[room]; jf != nil {
+		jf.f.Close() // what it held is in the new file
+		delete(j.rooms, room)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	j.rooms[room] = &journalFile{f: f, w: bufio.NewWriter(f), records: len(recs)}
+	return nil
+}
+
+// closeLocked syncs and closes every file. The caller holds j.mu.
+func (j *Journal) closeLocked() error {
+	var errs []error
+	for room, jf := range j.rooms {
+		errs = append(errs, jf.sync(), jf.f.Close())
+		delete(j.rooms, room)
+	}
+	return errors.Join(errs...)
+}
+
+// replay restores every room journaled in j.dir and returns how many.
+func (j *Journal) replay() (int, error) {
+	paths, err := filepath.Glob(filepath.Join(j.dir, "*"+journalExt))
+	if err != nil {
+		return 0, err
+	}
+	for _, path := range paths {
+		room, err := url.QueryUnescape(strings.TrimSuffix(filepath.Base(path), journalExt))
+		if err != nil {
+			return 0, fmt.Errorf("journal %s: not a room's", path)
+		}
+		if err := j.replayRoom(room, path); err != nil {
+			return 0, fmt.Errorf("journal %s: %w", path, err)
+		}
+	}
+	return len(paths), nil
+}
+
+// replayRoom applies a room's journal to it: its latest settings and
+// charter, its shared files less those deleted, and the messages past the
+// room's last seq, less those that expired. Messages the room already holds
+// take their latest stamps, and the room's seq continues past every message
+// journaled, expired ones too. A torn last line, from a crash mid-write, is
+// ignored.
+func (j *Journal) replayRoom(room, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var (
+		settings *RoomSettings
+		charter  *protocol.Charter
+		files    []protocol.FileInfo
+		msgs     = make(map[string]protocol.Envelope)
+		expired  = make(map[string]bool)
+		deleted  = make(map[string]bool)
+		lastSeq  int64
+	)
+	rd := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		data, err := rd.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(bytes.TrimSpace(data)) > 0 {
+				log.Printf("journal %s: ignoring the torn line %d", path, line)
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+		var rec journalRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		switch {
+		case rec.Settings != nil:
+			settings, charter = rec.Settings, rec.Charter
+			lastSeq = max(lastSeq, rec.LastSeq)
+		case rec.Message != nil:
+			msgs[rec.Message.ID] = *rec.Message
+			lastSeq = max(lastSeq, rec.Message.SeqNum)
+		case rec.File != nil:
+			files = append(files, *rec.File)
+		case rec.Expired != "":
+			expired[rec.Expired] = true
+		case rec.FileDeleted != "":
+			deleted[rec.FileDeleted] = true
+		}
+	}
+
+	sorted := make([]protocol.Envelope, 0, len(msgs))
+	for id, m := range msgs {
+		if exp, err := time.Parse(time.RFC3339Nano, m.Metadata[protocol.MetaExpiresAt]); err == nil && time.Now().After(exp) {
+			expired[id] = true
+		}
+		if !expired[id] {
+			sorted = append(sorted, m)
+		}
+	}
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a].SeqNum < sorted[b].SeqNum })
+
+	r := j.hub.GetOrCreateRoom(room)
+	var added []protocol.Envelope
+	r.mu.Lock()
+	if settings != nil {
+		r.settings = *settings
+	}
+	if charter != nil {
+		r.charter = *charter
+	}
+	for id := range expired {
+		r.removeLocked(id)
+	}
+	for _, m := range sorted {
+		if m.SeqNum <= r.seq {
+			if i := r.indexLocked(m.ID); i >= 0 {
+				r.messages[i].Metadata = m.Metadata
+			}
+			continue
+		}
+		m.Room = room
+		r.seq = m.SeqNum
+		r.storeLocked(m)
+		added = append(added, m)
+	}
+	r.seq = max(r.seq, lastSeq)
+	r.mu.Unlock()
+
+	for _, m := range added {
+		r.expireLater(m)
+	}
+	if j.files != nil {
+		for _, info := range files {
+			if !deleted[info.ID] {
+				j.files.Adopt(info)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// waitRecords waits until room's journal holds n records, as the room's
+// dispatcher publishes its events after the calls that make them return.
+func waitRecords(t *testing.T, j *Journal, room string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		j.mu.Lock()
+		got := 0
+		if jf := j.rooms[room]; jf != nil {
+			got = jf.records
+		}
+		j.mu.Unlock()
+		if got >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("journal for %s has %d records, want %d", room, got, n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// openJournal opens the journal in dir into a new hub, with a file store
+// in dir too.
+func openJournal(t *testing.T, dir string) (*Hub, *FileStore, *Journal, int) {
+	t.Helper()
+	files, err := NewFileStore(filepath.Join(dir, "files"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := NewHub(100)
+	j, n, err := OpenJournal(filepath.Join(dir, "journal"), hub, files)
+	if err != nil {
+		t.Fatalf("OpenJournal: %v", err)
+	}
+	return hub, files, j, n
+}
+
+func shareFile(t *testing.T, room *Room, files *FileStore, name string) *protocol.FileInfo {
+	t.Helper()
+	info, err := files.Store(room.name, "alice", name, "text/plain", "", 4, strings.NewReader("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	room.enqueue(Event{Kind: EventFileShared, File: info})
+	return info
+}
+
+func TestJournalReplay(t *testing.T) {
+	dir := t.TempDir()
+	hub, files, j, n := openJournal(t, dir)
+	if n != 0 {
+		t.Fatalf("a new journal replayed %d rooms", n)
+	}
+
+	room := hub.GetOrCreateRoom("r")
+	asked := room.AddMessage("alice", protocol.TypeText, protocol.Payload{Text: "one"}, map[string]string{"to": "bob", "conv_id": "c"})
+	gone := room.AddMessage("alice", protocol.TypeText, protocol.Payload{Text: "two"}, nil)
+	if !room.Expire(gone.ID) {
+		t.Fatal("Expire found nothing")
+	}
+	if !room.MarkDelivered(asked.ID, "bob") {
+		t.Fatal("MarkDelivered stamped nothing")
+	}
+	token := room.SetSettings(RoomSettings{Visibility: VisibilityPrivate}).Token
+	room.SetCharter(protocol.Charter{Text: "ship it", UpdatedBy: "alice"})
+	kept := shareFile(t, room, files, "kept.txt")
+	deleted := shareFile(t, room, files, "deleted.txt")
+	if _, err := files.Delete("r", deleted.ID); err != nil {
+		t.Fatal(err)
+	}
+	room.enqueue(Event{Kind: EventFileDeleted, File: deleted})
+	// Two messages, an expiry, a stamp, two room updates, two files and a
+	// deletion.
+	waitRecords(t, j, "r", 9)
+	if err := j.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Twice: once from the journal as written, once as compacted on open.
+	for pass := 1; pass <= 2; pass++ {
+		hub, files, j, n := openJournal(t, dir)
+		if n != 1 {
+			t.Errorf("pass %d: replayed %d rooms, want 1", pass, n)
+		}
+		room := hub.GetRoom("r")
+		if room == nil {
+			t.Fatalf("pass %d: room not replayed", pass)
+		}
+		msgs, seq := room.History()
+		if len(msgs) != 1 || msgs[0].ID != asked.ID {
+			t.Fatalf("pass %d: history = %v, want only the unexpired message", pass, msgs)
+		}
+		if msgs[0].Metadata[protocol.MetaDeliveredAt] == "" {
+			t.Errorf("pass %d: delivered_at stamp lost", pass)
+		}
+		if seq != gone.SeqNum {
+			t.Errorf("pass %d: seq = %d, want %d, past the expired message", pass, seq, gone.SeqNum)
+		}
+		if s := room.Settings(); s.Visibility != VisibilityPrivate || s.Token != token {
+			t.Errorf("pass %d: settings = %+v, want private with token %s", pass, s, token)
+		}
+		if c := room.Charter(); c.Text != "ship it" {
+			t.Errorf("pass %d: charter = %q", pass, c.Text)
+		}
+		list := files.List("r")
+		if len(list) != 1 || list[0].ID != kept.ID {
+			t.Errorf("pass %d: files = %v, want only %s", pass, list, kept.Filename)
+		}
+		if err := j.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestJournalReplaySkipsExpiredAndTornLine(t *testing.T) {
+	dir := t.TempDir()
+	jdir := filepath.Join(dir, "journal")
+	if err := os.MkdirAll(jdir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339Nano)
+	journal := `{"settings":{},"charter":{"text":"","updated_at":"0001-01-01T00:00:00Z"},"last_seq":4}
+{"message":{"id":"a","room":"r","sender":"alice","timestamp":"2026-01-01T00:00:00Z","type":"text","payload":{"text":"gone by now"},"seq":5,"metadata":{"expires_at":"` + past + `"}}}
+{"message":{"id":"b","room":"r","sender":"bob","timestamp":"2026-01-01T00:00:01Z","type":"text","payload":{"text":"kept"},"seq":6}}
+{"message":{"id":"c","room":"r","sender":"bob","timest`
+	if err := os.WriteFile(filepath.Join(jdir, "r"+journalExt), []byte(journal), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	hub, _, j, _ := openJournal(t, dir)
+	defer j.Close()
+	msgs, seq := hub.GetRoom("r").History()
+	if len(msgs) != 1 || msgs[0].ID != "b" {
+		t.Fatalf("history = %v, want only message b", msgs)
+	}
+	if seq != 6 {
+		t.Errorf("seq = %d, want 6", seq)
+	}
+}
+
+func TestJournalRoomNames(t *testing.T) {
+	dir := t.TempDir()
+	hub, _, j, _ := openJournal(t, dir)
+	name := "team/α #1"
+	hub.GetOrCreateRoom(name).AddMessage("alice", protocol.TypeText, protocol.Payload{Text: "hi"}, nil)
+	waitRecords(t, j, name, 1)
+	j.Close()
+
+	hub, _, j, _ = openJournal(t, dir)
+	defer j.Close()
+	if hub.GetRoom(name) == nil {
+		t.Fatalf("room %q not replayed", name)
+	}
+}
+
+func TestJournalReplayPromptTemplate(t *testing.T) {
+	dir := t.TempDir()
+	hub, _, j, _ := openJournal(t, dir)
+	hub.GetOrCreateRoom("r").AddMessage("alice", protocol.TypeText, protocol.Payload{Text: "hi"}, nil)
+	waitRecords(t, j, "r", 1)
+	j.Close()
+
+	const tmpl = `{{define "intro"}}Be brief.{{end}}`
+	// The server's overrides reach replayed rooms whether they're set
+	// before the journal is opened, as the server does, or after.
+	for _, before := range []bool{true, false} {
+		files, err := NewFileStore(filepath.Join(dir, "files"), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hub := NewHub(100)
+		if before {
+			hub.SetPromptTemplate(tmpl)
+		}
+		j, _, err := OpenJournal(filepath.Join(dir, "journal"), hub, files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !before {
+			hub.SetPromptTemplate(tmpl)
+		}
+		if got := hub.GetRoom("r").PromptTemplates(); len(got) != 1 || got[0] != tmpl {
+			t.Errorf("set before replay %t: prompt templates = %q, want the server's", before, got)
+		}
+		j.Close()
+	}
+}
+
+func TestJournalQueueOverflow(t *testing.T) {
+	dir := t.TempDir()
+	hub, _, j, _ := openJournal(t, dir)
+	room := hub.GetOrCreateRoom("r")
+
+	// Subscribed after the journal, so it sees each event once the
+	// journal has.
+	published := make(chan struct{}, journalQueue*2)
+	unsubscribe := hub.Events().Subscribe(func(Event) { published <- struct{}{} }, EventMessage)
+	defer unsubscribe()
+
+	// With the writer stuck, the dispatcher must still get through every
+	// message.
+	n := journalQueue + 50
+	j.mu.Lock()
+	for i := 0; i < n; i++ {
+		room.AddMessage("alice", protocol.TypeText, protocol.Payload{Text: "hi"}, nil)
+	}
+	for i := 0; i < n; i++ {
+		select {
+		case <-published:
+		case <-time.After(5 * time.Second):
+			j.mu.Unlock()
+			t.Fatalf("the dispatcher stalled after %d of %d messages", i, n)
+		}
+	}
+	j.mu.Unlock()
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// What was dropped is in the journal anyway, from the room's state.
+	hub, _, j, _ = openJournal(t, dir)
+	defer j.Close()
+	msgs, seq := hub.GetRoom("r").History()
+	if seq != int64(n) || len(msgs) != 100 || msgs[len(msgs)-1].SeqNum != int64(n) {
+		t.Errorf("replayed %d messages up to #%d, want the last 100 up to #%d", len(msgs), seq, n)
+	}
+}
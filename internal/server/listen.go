@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// sdListenFDStart is the first file descriptor systemd passes a
+// socket-activated service.
+const sdListenFDStart = 3
+
+// Listen opens the server's listener. Under systemd socket activation it
+// takes the socket systemd passed, which stays open across restarts, so
+// connections wait in its backlog rather than being refused. Otherwise it
+// listens on addr, with SO_REUSEPORT if reusePort is set, so a new server
+// can listen on the same port before the old one stops and drains.
+func Listen(addr string, reusePort bool) (net.Listener, error) {
+	if ln, err := systemdListener(); ln != nil || err != nil {
+		return ln, err
+	}
+	if !reusePort {
+		return net.Listen("tcp", addr)
+	}
+	lc := net.ListenConfig{Control: reusePortControl}
+	return lc.Listen(context.Background(), "tcp", addr)
+}
+
+// systemdListener returns the socket systemd passed, or nil if it passed
+// none. The variables it reads are unset so children don't see them.
+func systemdListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, nil
+	}
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	if n > 1 {
+		return nil, fmt.Errorf("systemd passed %d sockets; the server takes one", n)
+	}
+	f := os.NewFile(sdListenFDStart, "systemd-socket")
+	defer f.Close()
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("systemd socket: %w", err)
+	}
+	return ln, nil
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// mentionToken matches a bare @handle: a word character followed by word
+// characters, dots or dashes. The @ must not follow a word character, so
+// email addresses aren't mentions.
+var mentionToken = regexp.MustCompile(`(?:^|[^\w@])@(\w[\w.-]*)`)
+
+// parseMentions returns the names text @-mentions, in order of appearance and
+// without duplicates. Known names are matched whole first, so "@bob's Claude"
+// mentions that participant rather than "bob"; anything else is a bare
+// @handle.
+func parseMentions(text string, known []string) []string {
+	if !strings.Contains(text, "@") {
+		return nil
+	}
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		lower = text // case folding moved bytes; match case-sensitively
+	}
+
+	type hit struct {
+		at   int
+		name string
+	}
+	var hits []hit
+	taken := make([]bool, len(text))
+
+	names := append([]string(nil), known...)
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	for _, name := range names {
+		needle := "@" + strings.ToLower(name)
+		for i := 0; ; {
+			j := strings.Index(lower[i:], needle)
+			if j < 0 {
+				break
+			}
+			start, end := i+j, i+j+len(needle)
+			i = end
+			if taken[start] || (start > 0 && isWordByte(lower[start-1])) || (end < len(lower) && isWordByte(lower[end])) {
+				continue
+			}
+			for k := start; k < end; k++ {
+				taken[k] = true
+			}
+			hits = append(hits, hit{start, name})
+		}
+	}
+	for _, m := range mentionToken.FindAllStringSubmatchIndex(text, -1) {
+		at := m[2] - 1
+		if taken[at] {
+			continue
+		}
+		if name := strings.TrimRight(text[m[2]:m[3]], ".-"); name != "" {
+			hits = append(hits, hit{at, name})
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool { return hits[i].at < hits[j].at })
+	var out []string
+	seen := make(map[string]bool)
+	for _, h := range hits {
+		if key := strings.ToLower(h.name); !seen[key] {
+			seen[key] = true
+			out = append(out, h.name)
+		}
+	}
+	return out
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+// knownNamesLocked returns everyone who could be mentioned: participants,
+// hooked Claudes and past senders. Caller holds r.mu.
+func (r *Room) knownNamesLocked() []string {
+	set := make(map[string]struct{}, len(r.participants)+len(r.spawnHooks))
+	for name := range r.participants {
+		set[name] = struct{}{}
+	}
+	for name := range r.spawnHooks {
+		set[name] = struct{}{}
+	}
+	for name := range r.remote {
+		set[name] = struct{}{}
+	}
+	for _, m := range r.messages {
+		if m.Type != protocol.TypeSystem {
+			set[m.Sender] = struct{}{}
+		}
+	}
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	return names
+}
+
+// withMentions returns a copy of metadata whose MetaMentions field lists
+// names after any the sender already gave.
+func withMentions(metadata map[string]string, names []string) map[string]string {
+	all := protocol.Mentions(metadata)
+	for _, n := range names {
+		if !protocol.Mentioned(metadata, n) {
+			all = append(all, n)
+		}
+	}
+	md := make(map[string]string, len(metadata)+1)
+	for k, v := range metadata {
+		md[k] = v
+	}
+	md[protocol.MetaMentions] = strings.Join(all, ",")
+	return md
+}
@@ -0,0 +1,52 @@
+package server
+
+import "github.com/corvino/claudetalk/internal/protocol"
+
+// Moderator mode enforces round-robin turn order in group conv threads.
+// Participants take turns in the order they first appeared in the thread;
+// when a message arrives, only one participant is spawned: the one it is
+// addressed to, who was asked directly and so takes the turn, or, for a
+// message the sender addressed to themself, the participant after the
+// sender.
+
+// trackTurnOrder records name in the thread's turn order if not already present.
+// Caller must hold r.mu.
+func (r *Room) trackTurnOrder(convID, name string) {
+	for _, n := range r.turnOrder[convID] {
+		if n == name {
+			return
+		}
+	}
+	r.turnOrder[convID] = append(r.turnOrder[convID], name)
+}
+
+// nextTurn returns the participant whose turn follows sender in a thread,
+// or "" if the thread has no other participants. Caller must hold r.mu.
+func (r *Room) nextTurn(convID, sender string) string {
+	order := r.turnOrder[convID]
+	if len(order) < 2 {
+		return ""
+	}
+	for i, name := range order {
+		if name == sender {
+			return order[(i+1)%len(order)]
+		}
+	}
+	// Sender isn't in the thread yet; the first participant goes next.
+	return order[0]
+}
+
+// isTurn reports whether name may be spawned for env. Always true when
+// moderation is off or the message isn't part of a thread. Caller must hold
+// r.mu.
+func (r *Room) isTurn(env protocol.Envelope, name string) bool {
+	convID := env.Metadata["conv_id"]
+	if !r.moderated || convID == "" {
+		return true
+	}
+	// An explicit addressee replaces the turn order for this message.
+	if to := env.Metadata["to"]; to != "" && to != env.Sender {
+		return to == name
+	}
+	return r.nextTurn(convID, env.Sender) == name
+}
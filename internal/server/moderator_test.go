@@ -0,0 +1,71 @@
+package server
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// moderatedThread returns a moderated room whose thread c has the turn order
+// alice, bob, carol, dave, each a connected daemon.
+func moderatedThread(t *testing.T) *Room {
+	t.Helper()
+	room := NewHub(100).GetOrCreateRoom("r")
+	room.moderated = true
+	for _, name := range []string{"alice", "bob", "carol", "dave"} {
+		room.participants[name] = &participantState{Name: name, Role: "daemon", Connected: true}
+	}
+	for _, m := range [][2]string{{"alice", "bob"}, {"bob", "carol"}, {"carol", "dave"}} {
+		room.AddMessage(m[0], protocol.TypeText, protocol.Payload{Text: "hi"}, map[string]string{"conv_id": "c", "to": m[1]})
+	}
+	return room
+}
+
+func ask(sender, to string) protocol.Envelope {
+	return protocol.Envelope{Sender: sender, Type: protocol.TypeText, Metadata: map[string]string{
+		"conv_id": "c", "to": to, "expecting_reply": "true",
+	}}
+}
+
+func TestModeratedSpawnsOneParticipant(t *testing.T) {
+	tests := []struct {
+		name string
+		env  protocol.Envelope
+		want string
+	}{
+		{"addressed to the next speaker", ask("alice", "bob"), "bob"},
+		// Bob is next after alice, but carol was asked: only she answers.
+		{"addressed out of turn", ask("alice", "carol"), "carol"},
+		{"addressed to the sender", ask("carol", "carol"), "dave"},
+	}
+	for _, tt := range tests {
+		room := moderatedThread(t)
+		targets, _ := room.GetConvSpawnTargets(tt.env)
+		if !slices.Equal(targets, []string{tt.want}) {
+			t.Errorf("%s: spawn targets = %v, want [%s]", tt.name, targets, tt.want)
+		}
+	}
+}
+
+func TestModeratedSpawnHooks(t *testing.T) {
+	room := moderatedThread(t)
+	for _, name := range []string{"bob", "carol"} {
+		delete(room.participants, name)
+		room.RegisterSpawnHook(name, func(*protocol.SpawnReq) {})
+	}
+	hooks, _ := room.GetHookSpawnTargets(ask("alice", "carol"))
+	if len(hooks) != 1 || hooks["carol"] == nil {
+		t.Errorf("hooks for %v, want only carol's", hooks)
+	}
+}
+
+func TestUnmoderatedSpawnsThread(t *testing.T) {
+	room := moderatedThread(t)
+	room.moderated = false
+	targets, _ := room.GetConvSpawnTargets(ask("alice", "carol"))
+	slices.Sort(targets)
+	if want := []string{"bob", "carol", "dave"}; !slices.Equal(targets, want) {
+		t.Errorf("spawn targets = %v, want %v", targets, want)
+	}
+}
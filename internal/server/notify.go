@@ -0,0 +1,183 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/e2e"
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// SMTPConfig holds outgoing mail settings.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// EmailNotifier emails a participant when a directed message to them goes
+// unanswered for longer than Delay. Urgent directed messages are emailed
+// right away, whether or not they expect a reply.
+type EmailNotifier struct {
+	smtp      SMTPConfig
+	addresses map[string]string // participant name → email address
+	delay     time.Duration
+	publicURL string
+
+	mu      sync.Mutex
+	pending map[string][]*pendingNotice // room + "\x00" + recipient → waiting notices
+}
+
+// pendingNotice is a directed message awaiting a reply.
+type pendingNotice struct {
+	env   protocol.Envelope
+	timer *time.Timer
+}
+
+// NewEmailNotifier creates a notifier. addresses maps participant names to
+// email addresses; participants not in the map are never emailed.
+func NewEmailNotifier(cfg SMTPConfig, addresses map[string]string, delay time.Duration, publicURL string) *EmailNotifier {
+	if cfg.Port == 0 {
+		cfg.Port = 587
+	}
+	return &EmailNotifier{
+		smtp:      cfg,
+		addresses: addresses,
+		delay:     delay,
+		publicURL: strings.TrimRight(publicURL, "/"),
+		pending:   make(map[string][]*pendingNotice),
+	}
+}
+
+// ParseAddresses parses "alice=alice@example.com,bob=bob@example.com".
+func ParseAddresses(s string) (map[string]string, error) {
+	out := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, addr, ok := strings.Cut(pair, "=")
+		if !ok || name == "" || addr == "" {
+			return nil, fmt.Errorf("invalid address mapping %q (want name=email)", pair)
+		}
+		out[name] = addr
+	}
+	return out, nil
+}
+
+// Observe is registered with Hub.Observe.
+func (n *EmailNotifier) Observe(env protocol.Envelope) {
+	n.cancelAnswered(env)
+
+	to := env.Metadata["to"]
+	urgent := protocol.MessagePriority(env.Metadata) == protocol.PriorityUrgent
+	if to == "" || (env.Metadata["expecting_reply"] != "true" && !urgent) {
+		return
+	}
+	if _, ok := n.addresses[to]; !ok {
+		return
+	}
+
+	delay := n.delay
+	if urgent {
+		delay = 0
+	}
+	key := env.Room + "\x00" + to
+	notice := &pendingNotice{env: env}
+	notice.timer = time.AfterFunc(delay, func() {
+		if n.remove(key, notice) {
+			n.send(to, env)
+		}
+	})
+
+	n.mu.Lock()
+	n.pending[key] = append(n.pending[key], notice)
+	n.mu.Unlock()
+}
+
+// cancelAnswered drops pending notices that env replies to: a message from the
+// recipient in the same conversation, or addressed back to the original sender.
+func (n *EmailNotifier) cancelAnswered(env protocol.Envelope) {
+	key := env.Room + "\x00" + env.Sender
+	convID := env.Metadata["conv_id"]
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	kept := n.pending[key][:0]
+	for _, p := range n.pending[key] {
+		pConv := p.env.Metadata["conv_id"]
+		if (pConv != "" && pConv == convID) || env.Metadata["to"] == p.env.Sender {
+			p.timer.Stop()
+			continue
+		}
+		kept = append(kept, p)
+	}
+	if len(kept) == 0 {
+		delete(n.pending, key)
+	} else {
+		n.pending[key] = kept
+	}
+}
+
+// remove deletes notice from the pending set, reporting whether it was still there.
+func (n *EmailNotifier) remove(key string, notice *pendingNotice) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	list := n.pending[key]
+	for i, p := range list {
+		if p == notice {
+			n.pending[key] = append(list[:i], list[i+1:]...)
+			if len(n.pending[key]) == 0 {
+				delete(n.pending, key)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func (n *EmailNotifier) send(to string, env protocol.Envelope) {
+	addr := n.addresses[to]
+	subject := fmt.Sprintf("[ClaudeTalk] %s is waiting on your reply in #%s", env.Sender, env.Room)
+	waiting, kind := " and is waiting for a reply", "unanswered"
+	if protocol.MessagePriority(env.Metadata) == protocol.PriorityUrgent {
+		subject = fmt.Sprintf("[ClaudeTalk] URGENT from %s in #%s", env.Sender, env.Room)
+		waiting, kind = ", marked urgent", "urgent"
+	}
+
+	e2e.Redact(&env) // the server never has the room key
+	var body strings.Builder
+	fmt.Fprintf(&body, "%s sent you a message in room %q at %s%s:\r\n\r\n",
+		env.Sender, env.Room, env.Timestamp.Local().Format("2006-01-02 15:04"), waiting)
+	for _, line := range strings.Split(env.Payload.Text, "\n") {
+		body.WriteString("> " + line + "\r\n")
+	}
+	if n.publicURL != "" {
+		fmt.Fprintf(&body, "\r\nOpen the room: %s/?room=%s\r\n", n.publicURL, url.QueryEscape(env.Room))
+	}
+
+	msg := "From: " + n.smtp.From + "\r\n" +
+		"To: " + addr + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" + body.String()
+
+	var auth smtp.Auth
+	if n.smtp.Username != "" {
+		auth = smtp.PlainAuth("", n.smtp.Username, n.smtp.Password, n.smtp.Host)
+	}
+	serverAddr := fmt.Sprintf("%s:%d", n.smtp.Host, n.smtp.Port)
+	if err := smtp.SendMail(serverAddr, auth, n.smtp.From, []string{addr}, []byte(msg)); err != nil {
+		log.Printf("email notify: send to %s failed: %v", to, err)
+		return
+	}
+	log.Printf("email notify: emailed %s about %s message from %s in room %s", to, kind, env.Sender, env.Room)
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// A directed message to a daemon that is offline doesn't summon anyone. The
+// room queues its spawn request instead and sends it when the daemon
+// reconnects, unless it has gone stale by then: a Claude answering a day-old
+// question is more confusing than useful. Queues live in memory on the
+// instance the message was posted on; under a Cluster, a daemon that
+// reconnects to another instance doesn't get them.
+
+// DefaultOfflineTTL is how long a queued spawn waits for its daemon.
+const DefaultOfflineTTL = time.Hour
+
+// maxQueuedSpawns caps each participant's queue; the oldest go first.
+const maxQueuedSpawns = 100
+
+// queuedSpawn is a spawn request waiting for its daemon to reconnect.
+type queuedSpawn struct {
+	spawn    *protocol.SpawnReq
+	queuedAt time.Time
+}
+
+// SetOfflineTTL sets how long spawns for offline daemons are queued in
+// rooms created after the call. 0 turns queueing off.
+func (h *Hub) SetOfflineTTL(ttl time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.offlineTTL = ttl
+}
+
+// knownDaemon reports whether name has connected as a daemon in any room.
+func (h *Hub) knownDaemon(name string) bool {
+	h.mu.RLock()
+	rooms := slices.Collect(maps.Values(h.rooms))
+	h.mu.RUnlock()
+	for _, r := range rooms {
+		r.mu.RLock()
+		ps := r.participants[name]
+		daemon := ps != nil && ps.Role == "daemon"
+		r.mu.RUnlock()
+		if daemon {
+			return true
+		}
+	}
+	return false
+}
+
+// offlineDaemon reports whether to is a daemon that is offline, so spawns
+// for it are queued: a participant of this room whose last connection was
+// a daemon's, or the other member of this direct message room if that
+// member's daemon has connected anywhere. It is false when queueing is off,
+// and when a spawn hook or another cluster instance answers for to.
+func (r *Room) offlineDaemon(to string) bool {
+	r.mu.RLock()
+	ps := r.participants[to]
+	_, hasHook := r.spawnHooks[to]
+	_, elsewhere := r.remote[to]
+	off := r.offlineTTL > 0 && !hasHook && !elsewhere && (ps == nil || !ps.Connected)
+	daemon := ps != nil && ps.Role == "daemon"
+	r.mu.RUnlock()
+	if !off {
+		return false
+	}
+	if daemon {
+		return true
+	}
+	_, dm := protocol.DMPeer(r.name, to)
+	return dm && r.hub != nil && r.hub.daemonFor(to) == nil && r.hub.knownDaemon(to)
+}
+
+// queueSpawn holds spawn for to until its daemon reconnects.
+func (r *Room) queueSpawn(to string, spawn *protocol.SpawnReq) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	q := append(r.queued[to], queuedSpawn{spawn: spawn, queuedAt: time.Now()})
+	if len(q) > maxQueuedSpawns {
+		q = q[len(q)-maxQueuedSpawns:]
+	}
+	r.queued[to] = q
+	log.Printf("spawn queue: %s is offline; queued %d spawn(s) in %s", to, len(q), r.name)
+}
+
+// takeQueued removes and returns name's queued spawns that aren't stale,
+// oldest first, with their context and charter brought up to date.
+func (r *Room) takeQueued(name string) []*protocol.SpawnReq {
+	r.mu.Lock()
+	q := r.queued[name]
+	delete(r.queued, name)
+	ttl := r.offlineTTL
+	r.mu.Unlock()
+
+	var out []*protocol.SpawnReq
+	for _, qs := range q {
+		if time.Since(qs.queuedAt) > ttl {
+			log.Printf("spawn queue: dropping a spawn for %s in %s queued %s ago", name, r.name, time.Since(qs.queuedAt).Round(time.Second))
+			continue
+		}
+		qs.spawn.Context, qs.spawn.Summary = r.SpawnContext(*qs.spawn.Trigger)
+		qs.spawn.Charter = r.Charter().Text
+		out = append(out, qs.spawn)
+	}
+	return out
+}
+
+// deliverQueued sends c, a daemon that just connected, the spawns queued for
+// its name while it was offline: those in the rooms it joined and holds the
+// claim in, and those in its direct message rooms.
+func (h *Hub) deliverQueued(c *Client) {
+	h.mu.RLock()
+	rooms := slices.Collect(maps.Values(h.rooms))
+	h.mu.RUnlock()
+	for _, r := range rooms {
+		if slices.Contains(c.rooms, r) {
+			if !r.HoldsClaim(c) {
+				continue
+			}
+		} else if _, dm := protocol.DMPeer(r.name, c.sender); !dm || h.daemonFor(c.sender) != c {
+			continue
+		}
+		for _, spawn := range r.takeQueued(c.sender) {
+			log.Printf("spawn queue: delivering a queued spawn to %s in %s", c.sender, r.name)
+			c.sendSpawn(spawn)
+			r.events.Publish(Event{Kind: EventSpawn, Room: r.name, Spawn: spawn, Target: c.sender})
+		}
+	}
+}
+
+// queuedWarning is WarnUndeliverable's warning for a message queued for to.
+func (r *Room) queuedWarning(to string, seq int64) string {
+	r.mu.RLock()
+	ttl := r.offlineTTL
+	r.mu.RUnlock()
+	// "1h0m0s" reads better as "1h".
+	within := ttl.Round(time.Second).String()
+	if strings.HasSuffix(within, "m0s") {
+		within = within[:len(within)-2]
+	}
+	if strings.HasSuffix(within, "h0m") {
+		within = within[:len(within)-2]
+	}
+	return fmt.Sprintf("%s's daemon is offline, so message #%d is queued; it will summon their Claude if the daemon reconnects within %s", to, seq, within)
+}
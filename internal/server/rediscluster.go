@@ -0,0 +1,192 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/corvino/claudetalk/internal/redis"
+	"github.com/google/uuid"
+)
+
+// RedisCluster is a Cluster on Redis. Under its key prefix it keeps
+//
+//	<prefix>:seq:<room>      the room's message sequence counter
+//	<prefix>:history:<room>  the room's recent messages, as JSON
+//	<prefix>:events          the channel every instance publishes its events on
+//
+// Shared: messages and their numbering, expiries, who is connected where,
+// and file announcements. Files themselves are only downloadable from every
+// instance if they share -file-dir. Per instance: the room list, room
+// settings (visibility and private room tokens among them), delivery stamps,
+// spawn claims and scheduled digests.
+type RedisCluster struct {
+	rc         *redis.Client
+	hub        *Hub
+	files      *FileStore // adopts files other instances store; may be nil
+	origin     string     // this instance, to skip its own events
+	prefix     string
+	maxHistory int
+	out        chan Event
+}
+
+// clusterEvent is an Event on the wire.
+type clusterEvent struct {
+	Origin      string                    `json:"origin"`
+	Kind        string                    `json:"kind"`
+	Room        string                    `json:"room"`
+	Message     *protocol.Envelope        `json:"message,omitempty"`
+	File        *protocol.FileInfo        `json:"file,omitempty"`
+	Participant *protocol.ParticipantInfo `json:"participant,omitempty"`
+}
+
+// NewRedisCluster connects hub to the Redis server at url, for example
+// redis://:password@redis:6379/0, and makes it the hub's cluster. Instances
+// with the same prefix share rooms; give each deployment its own. Call Run
+// to start exchanging events.
+func NewRedisCluster(url, prefix string, hub *Hub, files *FileStore) (*RedisCluster, error) {
+	rc, err := redis.New(url)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := rc.Do("PING"); err != nil {
+		return nil, fmt.Errorf("connect to redis: %w", err)
+	}
+	c := &RedisCluster{
+		rc:         rc,
+		hub:        hub,
+		files:      files,
+		origin:     uuid.New().String(),
+		prefix:     prefix,
+		maxHistory: hub.maxHistory,
+		out:        make(chan Event, 1024),
+	}
+	hub.SetCluster(c)
+	return c, nil
+}
+
+// NextSeq implements Cluster.
+func (c *RedisCluster) NextSeq(room string) (int64, error) {
+	return c.rc.Int("INCR", c.key("seq", room))
+}
+
+// Load implements Cluster.
+func (c *RedisCluster) Load(room string, n int) (int64, []protocol.Envelope, error) {
+	reply, err := c.rc.Do("GET", c.key("seq", room))
+	if err != nil {
+		return 0, nil, err
+	}
+	var seq int64
+	if s, ok := reply.(string); ok {
+		seq, _ = strconv.ParseInt(s, 10, 64)
+	}
+	items, err := c.rc.Strings("LRANGE", c.key("history", room), strconv.Itoa(-n), "-1")
+	if err != nil {
+		return 0, nil, err
+	}
+	history := make([]protocol.Envelope, 0, len(items))
+	for _, item := range items {
+		var env protocol.Envelope
+		if json.Unmarshal([]byte(item), &env) == nil {
+			history = append(history, env)
+		}
+	}
+	return seq, history, nil
+}
+
+// Run publishes this instance's events and applies the other instances'
+// until ctx is done.
+func (c *RedisCluster) Run(ctx context.Context) {
+	unsubscribe := c.hub.Events().Subscribe(func(ev Event) {
+		if ev.Remote {
+			return
+		}
+		select {
+		case c.out <- ev:
+		default:
+			log.Printf("cluster: publish queue full; dropping %s in %s", ev.Kind, ev.Room)
+		}
+	}, clusterKinds...)
+	defer unsubscribe()
+
+	go c.rc.Subscribe(ctx, c.key("events"), func() {
+		log.Printf("cluster: subscribed to %s", c.key("events"))
+	}, c.apply)
+
+	for {
+		select {
+		case ev := <-c.out:
+			if err := c.publish(ev); err != nil {
+				log.Printf("cluster: publish %s in %s: %v", ev.Kind, ev.Room, err)
+			}
+		case <-ctx.Done():
+			c.rc.Close()
+			return
+		}
+	}
+}
+
+// publish records a message in its room's shared history and sends ev to
+// the other instances.
+func (c *RedisCluster) publish(ev Event) error {
+	if ev.Kind == EventMessage {
+		data, err := json.Marshal(ev.Message)
+		if err != nil {
+			return err
+		}
+		key := c.key("history", ev.Room)
+		if _, err := c.rc.Do("RPUSH", key, string(data)); err != nil {
+			return err
+		}
+		if _, err := c.rc.Do("LTRIM", key, strconv.Itoa(-c.maxHistory), "-1"); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(clusterEvent{
+		Origin:      c.origin,
+		Kind:        ev.Kind,
+		Room:        ev.Room,
+		Message:     ev.Message,
+		File:        ev.File,
+		Participant: ev.Participant,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.rc.Do("PUBLISH", c.key("events"), string(data))
+	return err
+}
+
+// apply applies an event from the events channel, unless this instance
+// published it.
+func (c *RedisCluster) apply(payload []byte) {
+	var ce clusterEvent
+	if err := json.Unmarshal(payload, &ce); err != nil {
+		log.Printf("cluster: bad event: %v", err)
+		return
+	}
+	if ce.Origin == c.origin || ce.Room == "" {
+		return
+	}
+	if ce.Kind == EventFileShared && ce.File != nil && c.files != nil {
+		c.files.Adopt(*ce.File)
+	}
+	c.hub.ApplyRemote(Event{
+		Kind:        ce.Kind,
+		Room:        ce.Room,
+		Message:     ce.Message,
+		File:        ce.File,
+		Participant: ce.Participant,
+	})
+}
+
+func (c *RedisCluster) key(parts ...string) string {
+	k := c.prefix
+	for _, p := range parts {
+		k += ":" + p
+	}
+	return k
+}
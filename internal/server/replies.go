@@ -0,0 +1,231 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/e2e"
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// replyWait is a directed message that expects a reply, and when its
+// recipient answered; zero while it waits.
+type replyWait struct {
+	env      protocol.Envelope
+	answered time.Time
+}
+
+// replyWaits returns the directed messages in the room's history that
+// expect a reply in a conv_id thread, in seq order. A message is answered by
+// the recipient's next message in the thread, as MetaRespondedAt records;
+// working it out from the history keeps it right for rooms restored from
+// SaveState or a Journal.
+func (r *Room) replyWaits() []replyWait {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var waits []replyWait
+	pending := make(map[string][]int) // conv_id → indexes of waits still waiting
+	for _, m := range r.messages {
+		convID := m.Metadata["conv_id"]
+		if convID == "" || m.Type == protocol.TypeSystem {
+			continue
+		}
+		pending[convID] = slices.DeleteFunc(pending[convID], func(i int) bool {
+			w := &waits[i]
+			if w.env.Metadata["to"] != m.Sender || w.env.Sender == m.Sender {
+				return false
+			}
+			w.answered = m.Timestamp
+			return true
+		})
+		if m.Metadata["to"] != "" && m.Metadata["expecting_reply"] == "true" {
+			pending[convID] = append(pending[convID], len(waits))
+			waits = append(waits, replyWait{env: m})
+		}
+	}
+	return waits
+}
+
+// ReplyStats returns, per participant that has been asked for a reply, how
+// long its replies took and what it still owes as of now, by name.
+func (r *Room) ReplyStats(now time.Time) []protocol.ReplyLatency {
+	byName := make(map[string]*protocol.ReplyLatency)
+	took := make(map[string][]float64)
+	var names []string
+	for _, w := range r.replyWaits() {
+		to := w.env.Metadata["to"]
+		s := byName[to]
+		if s == nil {
+			s = &protocol.ReplyLatency{Name: to}
+			byName[to] = s
+			names = append(names, to)
+		}
+		if w.answered.IsZero() {
+			s.Waiting++
+			s.LongestWaitSec = max(s.LongestWaitSec, now.Sub(w.env.Timestamp).Seconds())
+			continue
+		}
+		s.Answered++
+		took[to] = append(took[to], w.answered.Sub(w.env.Timestamp).Seconds())
+	}
+
+	slices.Sort(names)
+	stats := make([]protocol.ReplyLatency, len(names))
+	for i, name := range names {
+		s := byName[name]
+		slices.Sort(took[name])
+		s.P50Sec = percentile(took[name], 0.50)
+		s.P95Sec = percentile(took[name], 0.95)
+		stats[i] = *s
+	}
+	return stats
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted, or 0 for
+// none.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)]
+}
+
+// RoomStats handles GET /api/rooms/{room}/stats: how quickly each
+// participant answers directed messages. A room that doesn't exist has none.
+func (h *Handlers) RoomStats(w http.ResponseWriter, r *http.Request) {
+	roomName := r.PathValue("room")
+	stats := protocol.RoomStats{Room: roomName, Replies: []protocol.ReplyLatency{}}
+	if room := h.Hub.GetRoom(roomName); room != nil {
+		stats.Replies = room.ReplyStats(time.Now())
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// ReplyAlertConfig configures alerts about directed messages left waiting
+// for a reply.
+type ReplyAlertConfig struct {
+	After   time.Duration // how long a message may wait before the alert
+	Webhook string        // if set, alerts are POSTed here as protocol.ReplyAlert instead of posted to the room
+}
+
+// ReplyAlerter alerts once per thread and recipient when the oldest message
+// there expecting a reply has waited longer than After.
+type ReplyAlerter struct {
+	hub    *Hub
+	cfg    ReplyAlertConfig
+	client *http.Client
+
+	mu      sync.Mutex
+	alerted map[string]bool // IDs of waiting messages already alerted on
+}
+
+// NewReplyAlerter creates an alerter for the given hub.
+func NewReplyAlerter(hub *Hub, cfg ReplyAlertConfig) *ReplyAlerter {
+	return &ReplyAlerter{
+		hub:     hub,
+		cfg:     cfg,
+		client:  &http.Client{Timeout: 15 * time.Second},
+		alerted: make(map[string]bool),
+	}
+}
+
+// Run checks for overdue replies until ctx is cancelled, a few times per
+// After and at least every minute.
+func (a *ReplyAlerter) Run(ctx context.Context) {
+	ticker := time.NewTicker(min(max(a.cfg.After/4, time.Second), time.Minute))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.RunOnce()
+		}
+	}
+}
+
+// RunOnce alerts about every thread whose oldest message waiting on a
+// recipient has waited longer than After, unless it did already.
+func (a *ReplyAlerter) RunOnce() {
+	now := time.Now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	waiting := make(map[string]bool)
+	for _, snap := range a.hub.ListRooms() {
+		room := a.hub.GetRoom(snap.Name)
+		if room == nil {
+			continue
+		}
+		oldest := make(map[string]bool) // conv_id + recipient already seen
+		for _, w := range room.replyWaits() {
+			key := w.env.Metadata["conv_id"] + "\x00" + w.env.Metadata["to"]
+			if !w.answered.IsZero() || oldest[key] {
+				continue
+			}
+			oldest[key] = true
+			if now.Sub(w.env.Timestamp) < a.cfg.After {
+				continue
+			}
+			waiting[w.env.ID] = true
+			if a.alerted[w.env.ID] {
+				continue
+			}
+			if err := a.alert(room, w.env, now.Sub(w.env.Timestamp)); err != nil {
+				log.Printf("reply alert: room=%s: %v", snap.Name, err)
+				continue
+			}
+			a.alerted[w.env.ID] = true
+		}
+	}
+	// Forget messages that have been answered or left the history.
+	for id := range a.alerted {
+		if !waiting[id] {
+			delete(a.alerted, id)
+		}
+	}
+}
+
+func (a *ReplyAlerter) alert(room *Room, env protocol.Envelope, waited time.Duration) error {
+	to := env.Metadata["to"]
+	if a.cfg.Webhook == "" {
+		text := fmt.Sprintf("%s has waited %s for %s to reply to #%d (conv %s).", env.Sender, waited.Round(time.Second), to, env.SeqNum, env.Metadata["conv_id"])
+		room.AddMessage("system", protocol.TypeSystem, protocol.Payload{Text: text}, map[string]string{
+			"reply_alert": "true",
+		})
+		return nil
+	}
+
+	e2e.Redact(&env) // the server never has the room key
+	body, err := json.Marshal(protocol.ReplyAlert{
+		Room:       room.name,
+		ConvID:     env.Metadata["conv_id"],
+		From:       env.Sender,
+		To:         to,
+		Seq:        env.SeqNum,
+		WaitingSec: waited.Seconds(),
+		Text:       env.Payload.Text,
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := a.client.Post(a.cfg.Webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("POST webhook: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
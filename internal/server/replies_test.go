@@ -0,0 +1,72 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+func TestPercentile(t *testing.T) {
+	ten := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	tests := []struct {
+		sorted []float64
+		p      float64
+		want   float64
+	}{
+		{nil, 0.50, 0},
+		{nil, 0.95, 0},
+		{[]float64{7}, 0, 7},
+		{[]float64{7}, 0.50, 7},
+		{[]float64{7}, 0.95, 7},
+		{[]float64{7}, 1, 7},
+		{[]float64{1, 9}, 0.50, 1},
+		{[]float64{1, 9}, 0.95, 9},
+		{ten, 0, 1},
+		{ten, 0.50, 5},
+		{ten, 0.95, 10},
+		{ten, 0.90, 9},
+		{ten, 1, 10},
+	}
+	for _, tt := range tests {
+		if got := percentile(tt.sorted, tt.p); got != tt.want {
+			t.Errorf("percentile(%v, %v) = %v, want %v", tt.sorted, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestReplyStats(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	msg := func(sec int, sender, to, conv string) protocol.Envelope {
+		meta := map[string]string{"conv_id": conv}
+		if to != "" {
+			meta["to"] = to
+			meta["expecting_reply"] = "true"
+		}
+		return protocol.Envelope{Sender: sender, Type: protocol.TypeText, Timestamp: t0.Add(time.Duration(sec) * time.Second), Metadata: meta}
+	}
+	room := NewHub(100).GetOrCreateRoom("r")
+	room.messages = []protocol.Envelope{
+		msg(0, "alice", "bob", "c1"),
+		msg(0, "alice", "bob", "c2"),
+		msg(4, "bob", "", "c1"),   // answers c1 after 4s
+		msg(5, "carol", "", "c2"), // not bob: c2 still waits
+		msg(10, "alice", "carol", "c3"),
+		msg(12, "carol", "alice", "c3"), // answers alice in c3 after 2s
+	}
+
+	got := room.ReplyStats(t0.Add(60 * time.Second))
+	want := []protocol.ReplyLatency{
+		{Name: "alice", Waiting: 1, LongestWaitSec: 48},
+		{Name: "bob", Answered: 1, P50Sec: 4, P95Sec: 4, Waiting: 1, LongestWaitSec: 60},
+		{Name: "carol", Answered: 1, P50Sec: 2, P95Sec: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReplyStats =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if got := NewHub(100).GetOrCreateRoom("empty").ReplyStats(t0); len(got) != 0 {
+		t.Errorf("ReplyStats of an empty room = %+v", got)
+	}
+}
@@ -1,6 +1,11 @@
 package server
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
@@ -14,34 +19,71 @@ type RoomSnapshot struct {
 	Clients      int
 	MessageCount int
 	LastSeq      int64
+	Visibility   string
 }
 
-// participantState tracks a connected participant's daemon state.
+// participantState tracks a participant's connection and daemon state. The
+// room keeps it after the participant disconnects, as its registry of who
+// has been there, and creates it for senders that post without connecting.
 type participantState struct {
 	Name      string
 	Role      string
 	JoinedAt  time.Time
+	LastSeen  time.Time // last connect, disconnect or message
+	Messages  int       // messages posted in the room
 	Connected bool
-	Client    *Client // The daemon client, if any
+	Client    *Client                // The daemon client holding the name's claim, if any
+	Standby   []*Client              // other daemon connections under this name
+	Health    *protocol.DaemonHealth // last status report from the daemon, if any
+	Profile   protocol.Profile       // what the latest connection to advertise one advertised
 }
 
 // Room holds messages and connected WebSocket clients.
 type Room struct {
 	name       string
 	maxHistory int
+	moderated  bool    // only the participant whose turn it is gets spawned
+	events     *Bus    // the hub's event bus; may be nil
+	cluster    Cluster // shares the room with other server instances; may be nil
+	hub        *Hub    // the hub the room belongs to; may be nil
 
 	mu               sync.RWMutex
 	messages         []protocol.Envelope
 	seq              int64
 	clients          map[*Client]struct{}
 	participants     map[string]*participantState
-	convParticipants map[string]map[string]struct{}            // conv_id → participant names
+	convParticipants map[string]map[string]struct{}      // conv_id → participant names
 	spawnHooks       map[string]func(*protocol.SpawnReq) // name → hook for non-daemon participants
+	turnOrder        map[string][]string                 // conv_id → participants in order of first appearance
+	replies          map[string][]string                 // message ID → IDs of its direct replies
+	remote           map[string]protocol.ParticipantInfo // participants connected to other cluster instances
+	queued           map[string][]queuedSpawn            // spawns waiting for offline daemons; see offline.go
+	offlineTTL       time.Duration                       // how long queued spawns wait; 0 = don't queue
+	promptTmpl       string                              // the hub's prompt overrides
+	settings         RoomSettings
+	charter          protocol.Charter
+
+	// Events queued for the dispatcher goroutine; see dispatch.
+	outMu    sync.Mutex
+	out      []Event
+	outReady chan struct{}
 }
 
-// NewRoom creates a room with the given name and history limit.
+// ErrNoParent is returned by AddReply when the message being replied to
+// isn't in the room's history.
+var ErrNoParent = errors.New("reply_to: no such message in this room")
+
+// ErrNoSeq is returned by AddReply when the cluster can't number the
+// message; it isn't posted.
+var ErrNoSeq = errors.New("cluster unavailable: cannot number the message")
+
+// ErrRoomNotEmpty is returned by Import for a room that already has history.
+var ErrRoomNotEmpty = errors.New("room already has messages; import into a new room")
+
+// NewRoom creates a room with the given name and history limit and starts
+// its dispatcher.
 func NewRoom(name string, maxHistory int) *Room {
-	return &Room{
+	r := &Room{
 		name:             name,
 		maxHistory:       maxHistory,
 		messages:         make([]protocol.Envelope, 0, 64),
@@ -49,13 +91,46 @@ func NewRoom(name string, maxHistory int) *Room {
 		participants:     make(map[string]*participantState),
 		convParticipants: make(map[string]map[string]struct{}),
 		spawnHooks:       make(map[string]func(*protocol.SpawnReq)),
+		turnOrder:        make(map[string][]string),
+		replies:          make(map[string][]string),
+		remote:           make(map[string]protocol.ParticipantInfo),
+		queued:           make(map[string][]queuedSpawn),
+		outReady:         make(chan struct{}, 1),
 	}
+	go r.dispatch()
+	return r
 }
 
-// AddMessage stores a message, assigns server-side fields, broadcasts to WS clients, and returns the envelope.
+// AddMessage stores a message, assigns server-side fields, queues it for broadcast to WS clients, and returns the envelope.
+// The room's dispatcher also sends the spawn events the message triggers, so
+// REST, WebSocket and MCP posts summon daemons alike, with or without anyone
+// watching the room. A message the cluster can't number is dropped, and
+// logged; use AddReply to handle that.
 func (r *Room) AddMessage(sender, msgType string, payload protocol.Payload, metadata map[string]string) protocol.Envelope {
+	env, err := r.AddReply(sender, msgType, payload, metadata, "")
+	if err != nil {
+		log.Printf("room=%s: message from %s dropped: %v", r.name, sender, err)
+	}
+	return env
+}
+
+// AddReply is AddMessage for a message that replies to the message with ID
+// replyTo, threading it under that message. An empty replyTo is a plain
+// message. It fails with ErrNoParent if replyTo isn't in the room's history,
+// and with ErrNoSeq if the cluster can't number the message.
+func (r *Room) AddReply(sender, msgType string, payload protocol.Payload, metadata map[string]string, replyTo string) (protocol.Envelope, error) {
+	seq, err := r.clusterSeq()
+	if err != nil {
+		return protocol.Envelope{}, err
+	}
 	r.mu.Lock()
-	r.seq++
+	if replyTo != "" && r.indexLocked(replyTo) < 0 {
+		r.mu.Unlock()
+		return protocol.Envelope{}, ErrNoParent
+	}
+	if names := parseMentions(payload.Text, r.knownNamesLocked()); len(names) > 0 {
+		metadata = withMentions(metadata, names)
+	}
 	env := protocol.Envelope{
 		ID:        uuid.New().String(),
 		Room:      r.name,
@@ -63,90 +138,274 @@ func (r *Room) AddMessage(sender, msgType string, payload protocol.Payload, meta
 		Timestamp: time.Now().UTC(),
 		Type:      msgType,
 		Payload:   payload,
-		SeqNum:    r.seq,
+		SeqNum:    r.nextSeqLocked(seq),
 		Metadata:  metadata,
+		ReplyTo:   replyTo,
+	}
+	r.storeLocked(env)
+	r.enqueue(Event{Kind: EventMessage, Message: &env})
+	r.mu.Unlock()
+
+	r.expireLater(env)
+	return env, nil
+}
+
+// expireLater schedules env's removal at its protocol.MetaExpiresAt, if it
+// has one.
+func (r *Room) expireLater(env protocol.Envelope) {
+	if exp, err := time.Parse(time.RFC3339Nano, env.Metadata[protocol.MetaExpiresAt]); err == nil {
+		time.AfterFunc(time.Until(exp), func() { r.Expire(env.ID) })
+	}
+}
+
+// clusterSeq allocates the next message sequence number from the cluster,
+// so numbers are shared by every instance, or returns 0 without one. It is
+// called before r.mu is taken, so a slow cluster holds up only the message
+// being numbered. Numbering locally instead would give different messages
+// the same seq on different instances, so an error is ErrNoSeq.
+func (r *Room) clusterSeq() (int64, error) {
+	if r.cluster == nil {
+		return 0, nil
+	}
+	seq, err := r.cluster.NextSeq(r.name)
+	if err != nil {
+		log.Printf("room=%s: cluster seq: %v", r.name, err)
+		return 0, ErrNoSeq
+	}
+	return seq, nil
+}
+
+// nextSeqLocked returns seq, from clusterSeq, or without a cluster the next
+// local sequence number. The caller holds r.mu.
+func (r *Room) nextSeqLocked(seq int64) int64 {
+	if seq > 0 {
+		r.seq = max(r.seq, seq)
+		return seq
 	}
+	r.seq++
+	return r.seq
+}
+
+// storeLocked adds env to the history in seq order, trims the history and
+// updates reply and conversation tracking. The caller holds r.mu.
+func (r *Room) storeLocked(env protocol.Envelope) {
 	r.messages = append(r.messages, env)
+	// Messages from other cluster instances can arrive out of order.
+	for i := len(r.messages) - 1; i > 0 && r.messages[i-1].SeqNum > env.SeqNum; i-- {
+		r.messages[i-1], r.messages[i] = r.messages[i], r.messages[i-1]
+	}
+	if env.ReplyTo != "" {
+		r.replies[env.ReplyTo] = append(r.replies[env.ReplyTo], env.ID)
+	}
 	// Trim if over max history.
 	if len(r.messages) > r.maxHistory {
 		excess := len(r.messages) - r.maxHistory
+		for _, m := range r.messages[:excess] {
+			delete(r.replies, m.ID)
+		}
 		r.messages = r.messages[excess:]
 	}
-	// Track conv_id participants for group thread broadcasting.
 	if convID := env.Metadata["conv_id"]; convID != "" {
-		if _, ok := r.convParticipants[convID]; !ok {
-			r.convParticipants[convID] = make(map[string]struct{})
-		}
-		r.convParticipants[convID][env.Sender] = struct{}{}
-		if to := env.Metadata["to"]; to != "" {
-			r.convParticipants[convID][to] = struct{}{}
-		}
+		r.markRespondedLocked(convID, env.Sender, env.Timestamp)
 	}
-	// Copy client set for broadcast outside lock.
-	clients := make([]*Client, 0, len(r.clients))
-	for c := range r.clients {
-		clients = append(clients, c)
+	r.trackConvLocked(env)
+	if env.Type != protocol.TypeSystem && env.Sender != "system" {
+		r.postedLocked(env.Sender, env.Timestamp)
 	}
-	r.mu.Unlock()
+}
 
-	// Broadcast to WebSocket clients.
-	// Private messages (metadata.private=true) are only delivered to the sender
-	// and the intended recipient; daemon clients always receive everything.
-	for _, c := range clients {
-		if env.Metadata["private"] == "true" && c.mode != "daemon" {
-			if c.sender != env.Sender && c.sender != env.Metadata["to"] {
-				continue
+// postedLocked counts a message from name, registering name if it has never
+// connected, e.g. a sender that only posts over HTTP. The caller holds r.mu.
+func (r *Room) postedLocked(name string, at time.Time) {
+	ps, ok := r.participants[name]
+	if !ok {
+		ps = &participantState{Name: name, Role: "user", JoinedAt: at}
+		r.participants[name] = ps
+	}
+	ps.Messages++
+	if at.After(ps.LastSeen) {
+		ps.LastSeen = at
+	}
+}
+
+// trackConvLocked records env's sender and recipient as members of its
+// conv_id thread, for group thread broadcasting. The caller holds r.mu.
+func (r *Room) trackConvLocked(env protocol.Envelope) {
+	convID := env.Metadata["conv_id"]
+	if convID == "" {
+		return
+	}
+	if _, ok := r.convParticipants[convID]; !ok {
+		r.convParticipants[convID] = make(map[string]struct{})
+	}
+	r.convParticipants[convID][env.Sender] = struct{}{}
+	r.trackTurnOrder(convID, env.Sender)
+	if to := env.Metadata["to"]; to != "" {
+		r.convParticipants[convID][to] = struct{}{}
+		r.trackTurnOrder(convID, to)
+	}
+}
+
+// indexLocked returns the position of message id in r.messages, or -1.
+// The caller holds r.mu.
+func (r *Room) indexLocked(id string) int {
+	for i := len(r.messages) - 1; i >= 0; i-- {
+		if r.messages[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Message returns the message in the room's history with the given ID, or
+// with seq number id.
+func (r *Room) Message(id string) (protocol.Envelope, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if i := r.indexLocked(id); i >= 0 {
+		return r.messages[i], true
+	}
+	if seq, err := strconv.ParseInt(id, 10, 64); err == nil {
+		for _, m := range r.messages {
+			if m.SeqNum == seq {
+				return m, true
 			}
 		}
-		c.Send(env)
 	}
-	return env
+	return protocol.Envelope{}, false
 }
 
-// MessagesAfter returns messages with SeqNum > after, up to limit.
-func (r *Room) MessagesAfter(after int64, limit int) []protocol.Envelope {
+// Thread returns the thread message id belongs to: its root, the earliest
+// ancestor still in history, followed by every reply under that root, in
+// seq order. ok is false if id isn't in the room's history.
+func (r *Room) Thread(id string) (thread []protocol.Envelope, ok bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	// Find start index using the fact that seq numbers are monotonic.
-	start := 0
+	byID := make(map[string]int, len(r.messages))
 	for i, m := range r.messages {
-		if m.SeqNum > after {
-			start = i
+		byID[m.ID] = i
+	}
+	i, ok := byID[id]
+	if !ok {
+		return nil, false
+	}
+	for {
+		parent, held := byID[r.messages[i].ReplyTo]
+		if !held {
 			break
 		}
-		if i == len(r.messages)-1 {
-			return nil // All messages are <= after.
+		i = parent
+	}
+
+	queue := []string{r.messages[i].ID}
+	for len(queue) > 0 {
+		idx, held := byID[queue[0]]
+		if held {
+			thread = append(thread, r.messages[idx])
 		}
+		queue = append(queue[1:], r.replies[queue[0]]...)
 	}
+	sort.Slice(thread, func(a, b int) bool { return thread[a].SeqNum < thread[b].SeqNum })
+	return thread, true
+}
 
-	if len(r.messages) == 0 {
-		return nil
+// Expire drops the message with the given ID from history and tells every
+// client so it can redact it. Reports whether the message was still held.
+func (r *Room) Expire(id string) bool {
+	r.mu.Lock()
+	gone, ok := r.removeLocked(id)
+	if !ok {
+		r.mu.Unlock()
+		return false
+	}
+	// Only identifying fields go out; the content is what's being purged.
+	r.enqueue(Event{Kind: EventMessageExpired, Message: &protocol.Envelope{ID: gone.ID, Room: gone.Room, SeqNum: gone.SeqNum}})
+	r.mu.Unlock()
+
+	log.Printf("room=%s: message #%d expired", r.name, gone.SeqNum)
+	return true
+}
+
+// removeLocked drops the message with the given ID from history. Its replies
+// stay, as the roots of their own threads. The caller holds r.mu.
+func (r *Room) removeLocked(id string) (protocol.Envelope, bool) {
+	idx := r.indexLocked(id)
+	if idx < 0 {
+		return protocol.Envelope{}, false
+	}
+	gone := r.messages[idx]
+	r.messages = append(r.messages[:idx], r.messages[idx+1:]...)
+	delete(r.replies, id)
+	if siblings := r.replies[gone.ReplyTo]; len(siblings) > 0 {
+		kept := siblings[:0]
+		for _, s := range siblings {
+			if s != id {
+				kept = append(kept, s)
+			}
+		}
+		r.replies[gone.ReplyTo] = kept
 	}
+	return gone, true
+}
+
+// MessagesAfter returns messages with SeqNum > after, up to limit.
+func (r *Room) MessagesAfter(after int64, limit int) []protocol.Envelope {
+	return r.MessagesAfterMatching(after, limit, nil)
+}
 
-	result := r.messages[start:]
-	if limit > 0 && len(result) > limit {
-		result = result[:limit]
+// MessagesAfterMatching returns messages with SeqNum > after that pass f, up to limit.
+func (r *Room) MessagesAfterMatching(after int64, limit int, f *MessageFilter) []protocol.Envelope {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var out []protocol.Envelope
+	for _, m := range r.messages {
+		if m.SeqNum <= after || !f.Matches(m) {
+			continue
+		}
+		out = append(out, m)
+		if limit > 0 && len(out) >= limit {
+			break
+		}
 	}
-	out := make([]protocol.Envelope, len(result))
-	copy(out, result)
 	return out
 }
 
 // LatestMessages returns the last n messages.
 func (r *Room) LatestMessages(n int) []protocol.Envelope {
+	return r.LatestMatching(n, nil)
+}
+
+// LatestMatching returns the last n messages that pass f.
+func (r *Room) LatestMatching(n int, f *MessageFilter) []protocol.Envelope {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	if n <= 0 || len(r.messages) == 0 {
 		return nil
 	}
-	start := len(r.messages) - n
-	if start < 0 {
-		start = 0
+	if f == nil {
+		start := len(r.messages) - n
+		if start < 0 {
+			start = 0
+		}
+		out := make([]protocol.Envelope, len(r.messages[start:]))
+		copy(out, r.messages[start:])
+		return out
+	}
+
+	// Walk backwards collecting matches, then restore chronological order.
+	var out []protocol.Envelope
+	for i := len(r.messages) - 1; i >= 0 && len(out) < n; i-- {
+		if f.Matches(r.messages[i]) {
+			out = append(out, r.messages[i])
+		}
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
 	}
-	out := make([]protocol.Envelope, len(r.messages[start:]))
-	copy(out, r.messages[start:])
 	return out
 }
 
@@ -173,57 +432,207 @@ func (r *Room) Snapshot() RoomSnapshot {
 		Clients:      len(r.clients),
 		MessageCount: len(r.messages),
 		LastSeq:      r.seq,
+		Visibility:   r.settings.Visibility,
 	}
 }
 
 // TrackParticipant registers or updates a participant. If client is a daemon
-// client, it stores the reference for spawn event delivery.
+// client, it claims the name for spawn event delivery; see claim. A client
+// that advertises a profile replaces the participant's; one that doesn't
+// leaves it be.
 func (r *Room) TrackParticipant(name, role string, c *Client) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	if ps, ok := r.participants[name]; ok {
+	ps, ok := r.participants[name]
+	if ok {
+		rejoined := !ps.Connected
 		ps.Connected = true
 		ps.Role = role
+		ps.LastSeen = time.Now().UTC()
+		if c != nil && !c.profile.IsZero() {
+			ps.Profile = c.profile
+		}
 		if role == "daemon" {
-			ps.Client = c
+			r.claim(ps, c)
+		}
+		if !rejoined {
+			return
 		}
 	} else {
-		r.participants[name] = &participantState{
+		now := time.Now().UTC()
+		ps = &participantState{
 			Name:      name,
 			Role:      role,
-			JoinedAt:  time.Now().UTC(),
+			JoinedAt:  now,
+			LastSeen:  now,
 			Connected: true,
 			Client:    c,
 		}
+		if c != nil {
+			ps.Profile = c.profile
+		}
+		r.participants[name] = ps
+		if role == "daemon" && c != nil {
+			c.sendClaim(r.name, protocol.ClaimActive, "")
+		}
+	}
+	info := ps.info()
+	r.enqueue(Event{Kind: EventParticipantJoin, Participant: &info})
+}
+
+// claim settles which daemon connection receives spawn events for ps when c
+// connects under the same name (e.g. "claudetalk web" and "claudetalk daemon"
+// both watching as "bob's Claude"). c takes over if it asked to or has a
+// higher priority than the current holder; otherwise it waits on standby and
+// is promoted when the holder disconnects. Callers hold r.mu.
+func (r *Room) claim(ps *participantState, c *Client) {
+	holder := ps.Client
+	if holder == nil || holder == c {
+		ps.Client = c
+		c.sendClaim(r.name, protocol.ClaimActive, "")
+		return
+	}
+	if c.takeover || c.priority > holder.priority {
+		ps.Client = c
+		ps.Standby = append(ps.Standby, holder)
+		holder.sendClaim(r.name, protocol.ClaimStandby, "taken over by a newer connection")
+		c.sendClaim(r.name, protocol.ClaimActive, "")
+		log.Printf("claim: %s in %s taken over (priority %d > %d or takeover)", ps.Name, r.name, c.priority, holder.priority)
+		return
 	}
+	ps.Standby = append(ps.Standby, c)
+	c.sendClaim(r.name, protocol.ClaimStandby, "another connection already answers as "+ps.Name)
+	log.Printf("claim: %s in %s already held; new connection on standby", ps.Name, r.name)
 }
 
-// UntrackParticipant marks a participant as disconnected.
-func (r *Room) UntrackParticipant(name string) {
+// HoldsClaim reports whether c is the connection that receives spawn events
+// for its name.
+func (r *Room) HoldsClaim(c *Client) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ps, ok := r.participants[c.sender]
+	return ok && ps.Client == c
+}
+
+// UntrackParticipant drops c from a participant. If c held the name's
+// claim, the highest-priority standby connection takes over; the participant
+// is marked disconnected only when none is left. c may be nil for
+// participants without a connection.
+func (r *Room) UntrackParticipant(name string, c *Client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ps, ok := r.participants[name]
+	if !ok {
+		return
+	}
+	if c != nil && ps.Client != nil && ps.Client != c {
+		for i, sc := range ps.Standby {
+			if sc == c {
+				ps.Standby = append(ps.Standby[:i], ps.Standby[i+1:]...)
+				break
+			}
+		}
+		return
+	}
+	ps.Client = nil
+	if len(ps.Standby) > 0 {
+		best := 0
+		for i, sc := range ps.Standby {
+			if sc.priority > ps.Standby[best].priority {
+				best = i
+			}
+		}
+		next := ps.Standby[best]
+		ps.Standby = append(ps.Standby[:best], ps.Standby[best+1:]...)
+		ps.Client = next
+		next.sendClaim(r.name, protocol.ClaimActive, "previous connection left")
+		log.Printf("claim: %s in %s handed to a standby connection", name, r.name)
+		return
+	}
+	if !ps.Connected {
+		return
+	}
+	ps.Connected = false
+	ps.LastSeen = time.Now().UTC()
+	info := ps.info()
+	r.enqueue(Event{Kind: EventParticipantLeave, Participant: &info})
+}
+
+// SetHealth stores a daemon's status report. The server stamps the report
+// time so clock skew on the daemon doesn't matter.
+func (r *Room) SetHealth(name string, h protocol.DaemonHealth) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	if ps, ok := r.participants[name]; ok {
-		ps.Connected = false
-		ps.Client = nil
+		h.ReportedAt = time.Now().UTC()
+		ps.Health = &h
 	}
 }
 
-// ListParticipants returns info about all known participants.
+// ListParticipants returns info about everyone the room knows, connected or
+// not. A participant connected to another cluster instance is reported as
+// that instance sees it.
 func (r *Room) ListParticipants() []protocol.ParticipantInfo {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	out := make([]protocol.ParticipantInfo, 0, len(r.participants))
-	for _, ps := range r.participants {
-		out = append(out, protocol.ParticipantInfo{
-			Name:      ps.Name,
-			Role:      ps.Role,
-			JoinedAt:  ps.JoinedAt,
-			Connected: ps.Connected,
-		})
+	for name, ps := range r.participants {
+		if info, ok := r.remote[name]; ok && !ps.Connected {
+			out = append(out, info)
+			continue
+		}
+		out = append(out, ps.info())
+	}
+	for name, info := range r.remote {
+		if _, ok := r.participants[name]; !ok {
+			out = append(out, info)
+		}
+	}
+	return out
+}
+
+// Profiles returns the profiles of the room's connected participants that
+// advertise one, by name, for spawn requests; nil if none do.
+func (r *Room) Profiles() map[string]protocol.Profile {
+	var out map[string]protocol.Profile
+	for _, p := range r.ListParticipants() {
+		if p.Connected && p.Profile != nil {
+			if out == nil {
+				out = make(map[string]protocol.Profile)
+			}
+			out[p.Name] = *p.Profile
+		}
 	}
 	return out
 }
 
+// info returns the public view of ps. The caller holds the room's lock.
+func (ps *participantState) info() protocol.ParticipantInfo {
+	info := protocol.ParticipantInfo{
+		Name:      ps.Name,
+		Role:      ps.Role,
+		JoinedAt:  ps.JoinedAt,
+		LastSeen:  ps.LastSeen,
+		Messages:  ps.Messages,
+		Connected: ps.Connected,
+		Health:    ps.Health,
+	}
+	if ps.Connected {
+		info.LastSeen = time.Now().UTC()
+		if c := ps.Client; c != nil && ps.Role == "daemon" {
+			if c.claudeName != "" && c.claudeName != ps.Name {
+				info.SpeaksFor = append(info.SpeaksFor, c.claudeName)
+			}
+			info.SpeaksFor = append(info.SpeaksFor, c.agents...)
+		}
+	}
+	if !ps.Profile.IsZero() {
+		p := ps.Profile
+		info.Profile = &p
+	}
+	return info
+}
+
 // RegisterSpawnHook registers a function to call when a directed spawn event should
 // be delivered to a participant who has no daemon WebSocket connection (e.g., host-mode).
 func (r *Room) RegisterSpawnHook(name string, hook func(*protocol.SpawnReq)) {
@@ -239,6 +648,125 @@ func (r *Room) UnregisterSpawnHook(name string) {
 	delete(r.spawnHooks, name)
 }
 
+// MarkDelivered stamps protocol.MetaDeliveredAt on message id when by, the
+// daemon acking it, is its recipient or a member of its thread. It reports
+// whether the message was stamped.
+func (r *Room) MarkDelivered(id, by string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := len(r.messages) - 1; i >= 0; i-- {
+		env := r.messages[i]
+		if env.ID != id {
+			continue
+		}
+		convID := env.Metadata["conv_id"]
+		_, member := r.convParticipants[convID][by]
+		if env.Metadata["to"] != by && !(convID != "" && member) {
+			return false
+		}
+		return r.stampLocked(i, protocol.MetaDeliveredAt, time.Now().UTC())
+	}
+	return false
+}
+
+// markRespondedLocked stamps protocol.MetaRespondedAt on earlier messages in
+// convID addressed to sender, now that sender has posted in it.
+func (r *Room) markRespondedLocked(convID, sender string, at time.Time) {
+	for i := range r.messages {
+		md := r.messages[i].Metadata
+		if md["conv_id"] == convID && md["to"] == sender && r.messages[i].Sender != sender {
+			r.stampLocked(i, protocol.MetaRespondedAt, at)
+		}
+	}
+}
+
+// stampLocked sets key to t on message i unless it is already set, and
+// publishes the stamped message. Metadata maps are shared with envelopes
+// already sent to clients, so it replaces the map rather than writing to
+// it. The caller holds r.mu.
+func (r *Room) stampLocked(i int, key string, t time.Time) bool {
+	old := r.messages[i].Metadata
+	if old[key] != "" {
+		return false
+	}
+	md := make(map[string]string, len(old)+1)
+	for k, v := range old {
+		md[k] = v
+	}
+	md[key] = t.Format(time.RFC3339Nano)
+	r.messages[i].Metadata = md
+	stamped := r.messages[i]
+	r.enqueue(Event{Kind: EventMessageStamped, Message: &stamped})
+	return true
+}
+
+// WarnUndeliverable checks a directed message that expects a reply. If its
+// recipient has no connected daemon, spawn hook or watcher, nobody will see
+// it, so it whispers a system warning to the sender, as a Notice, and
+// returns the warning text. It returns "" when someone will receive the message.
+func (r *Room) WarnUndeliverable(env protocol.Envelope) string {
+	to := env.Metadata["to"]
+	if to == "" || env.Metadata["expecting_reply"] != "true" {
+		return ""
+	}
+
+	if r.dmElsewhere(to) != nil {
+		return ""
+	}
+	_, dm := protocol.DMPeer(r.name, to)
+
+	r.mu.RLock()
+	ps, known := r.participants[to]
+	if d, ok := r.daemonClaudesLocked()[to]; ok {
+		ps, known = r.participants[d], true // a daemon whose Claudes speak as to
+	} else if d, ok := r.agentsLocked()[to]; ok {
+		ps, known = r.participants[d], true // a daemon running to as an agent
+	}
+	known = known || dm // senders that only post over HTTP are registered too
+	_, hasHook := r.spawnHooks[to]
+	_, elsewhere := r.remote[to] // connected to another cluster instance
+	r.mu.RUnlock()
+	if hasHook || elsewhere || (ps != nil && ps.Connected) {
+		return ""
+	}
+	if r.offlineDaemon(to) {
+		warning := r.queuedWarning(to, env.SeqNum)
+		r.Notice(env.Sender, "Queued: "+warning, nil)
+		return warning
+	}
+
+	warning := fmt.Sprintf("%s is not connected and has no daemon to spawn a Claude, so message #%d will not be seen until they come back", to, env.SeqNum)
+	if !known {
+		warning = fmt.Sprintf("nobody named %q has been in this room, so message #%d will not be delivered; check the name", to, env.SeqNum)
+	}
+	r.Notice(env.Sender, "Undeliverable: "+warning, map[string]string{"undeliverable": to})
+	return warning
+}
+
+// Notice sends a system message to the room's clients without numbering it
+// or keeping it in history, so it reaches those connected now and no one
+// else: a warning for one sender, or news of the server going away. With to
+// set it is private to to, and the daemons.
+func (r *Room) Notice(to, text string, metadata map[string]string) {
+	md := make(map[string]string, len(metadata)+2)
+	for k, v := range metadata {
+		md[k] = v
+	}
+	if to != "" {
+		md["to"] = to
+		md["private"] = "true"
+	}
+	r.enqueue(Event{Kind: EventNotice, Message: &protocol.Envelope{
+		ID:        uuid.New().String(),
+		Room:      r.name,
+		Sender:    "system",
+		Timestamp: time.Now().UTC(),
+		Type:      protocol.TypeSystem,
+		Payload:   protocol.Payload{Text: text},
+		Metadata:  md,
+	}})
+}
+
 // GetHookSpawnTargets returns hooks for participants who should receive spawn events
 // but don't have a daemon WS client. Complements GetConvSpawnTargets for non-daemon participants.
 func (r *Room) GetHookSpawnTargets(env protocol.Envelope) (hooks map[string]func(*protocol.SpawnReq), allParticipants []string) {
@@ -256,6 +784,9 @@ func (r *Room) GetHookSpawnTargets(env protocol.Envelope) (hooks map[string]func
 		if name == env.Sender {
 			return
 		}
+		if !r.isTurn(env, name) {
+			return
+		}
 		hook, hasHook := r.spawnHooks[name]
 		if !hasHook {
 			return
@@ -295,22 +826,40 @@ func (r *Room) GetConvSpawnTargets(env protocol.Envelope) (targets []string, all
 	convID := env.Metadata["conv_id"]
 	targetSet := make(map[string]struct{})
 
-	// Always include the primary `to` recipient if they're a connected daemon.
-	if ps, ok := r.participants[env.Metadata["to"]]; ok && ps.Connected && ps.Role == "daemon" {
-		targetSet[env.Metadata["to"]] = struct{}{}
+	// A daemon answers for the name its Claudes speak as, too. Its named
+	// agents are targets of their own, so each is spawned as itself.
+	speaksAs := r.daemonClaudesLocked()
+	daemonFor := func(name string) string {
+		if d, ok := speaksAs[name]; ok {
+			return d
+		}
+		return name
+	}
+	agents := r.agentsLocked()
+	spawnable := func(name string) bool {
+		if d, ok := agents[name]; ok {
+			name = d
+		}
+		ps, ok := r.participants[name]
+		return ok && ps.Connected && ps.Role == "daemon"
+	}
+	sender := daemonFor(env.Sender)
+
+	// Always include the primary `to` recipient if they're a connected
+	// daemon (and, in moderated rooms, it's their turn).
+	to := daemonFor(env.Metadata["to"])
+	if spawnable(to) && to != sender && r.isTurn(env, env.Metadata["to"]) {
+		targetSet[to] = struct{}{}
 	}
 
 	// For conv_id threads, also notify every other thread participant.
 	if convID != "" {
 		for name := range r.convParticipants[convID] {
-			if name == env.Sender {
-				continue
-			}
-			ps, ok := r.participants[name]
-			if !ok || !ps.Connected || ps.Role != "daemon" {
+			d := daemonFor(name)
+			if d == sender || !r.isTurn(env, name) || !spawnable(d) {
 				continue
 			}
-			targetSet[name] = struct{}{}
+			targetSet[d] = struct{}{}
 		}
 		// Build full participant list for the prompt.
 		for name := range r.convParticipants[convID] {
@@ -324,13 +873,45 @@ func (r *Room) GetConvSpawnTargets(env protocol.Envelope) (targets []string, all
 	return targets, allParticipants
 }
 
-// GetDaemonClients returns the daemon *Client for each of the given participant names.
+// daemonClaudesLocked maps the names connected daemons' Claudes speak as,
+// when picked, to the daemons. The caller holds r.mu.
+func (r *Room) daemonClaudesLocked() map[string]string {
+	m := make(map[string]string)
+	for name, ps := range r.participants {
+		if ps.Connected && ps.Client != nil && ps.Client.claudeName != "" && ps.Client.claudeName != name {
+			m[ps.Client.claudeName] = name
+		}
+	}
+	return m
+}
+
+// agentsLocked maps the named agents of connected daemons to the daemons.
+// The caller holds r.mu.
+func (r *Room) agentsLocked() map[string]string {
+	m := make(map[string]string)
+	for name, ps := range r.participants {
+		if ps.Connected && ps.Client != nil {
+			for _, a := range ps.Client.agents {
+				m[a] = name
+			}
+		}
+	}
+	return m
+}
+
+// GetDaemonClients returns the daemon *Client for each of the given
+// participant names; for a named agent, its daemon's.
 func (r *Room) GetDaemonClients(names []string) map[string]*Client {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
+	agents := r.agentsLocked()
 	result := make(map[string]*Client, len(names))
 	for _, name := range names {
-		if ps, ok := r.participants[name]; ok && ps.Client != nil {
+		d := name
+		if a, ok := agents[name]; ok {
+			d = a
+		}
+		if ps, ok := r.participants[d]; ok && ps.Client != nil {
 			result[name] = ps.Client
 		}
 	}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// fakeCluster numbers messages from seq, or fails with err.
+type fakeCluster struct {
+	seq int64
+	err error
+}
+
+func (c *fakeCluster) NextSeq(room string) (int64, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	c.seq++
+	return c.seq, nil
+}
+
+func (c *fakeCluster) Load(room string, n int) (int64, []protocol.Envelope, error) {
+	return 0, nil, nil
+}
+
+func TestAddReplyClusterSeq(t *testing.T) {
+	cluster := &fakeCluster{seq: 40}
+	hub := NewHub(100)
+	hub.SetCluster(cluster)
+	room := hub.GetOrCreateRoom("r")
+
+	env, err := room.AddReply("alice", protocol.TypeText, protocol.Payload{Text: "hi"}, nil, "")
+	if err != nil {
+		t.Fatalf("AddReply: %v", err)
+	}
+	if env.SeqNum != 41 {
+		t.Errorf("seq = %d, want the cluster's 41", env.SeqNum)
+	}
+
+	// With the cluster down the message is refused, not numbered locally,
+	// where another instance could give the same seq to another message.
+	cluster.err = errors.New("connection refused")
+	if _, err := room.AddReply("alice", protocol.TypeText, protocol.Payload{Text: "lost"}, nil, ""); !errors.Is(err, ErrNoSeq) {
+		t.Fatalf("AddReply with the cluster down = %v, want ErrNoSeq", err)
+	}
+	if msgs, seq := room.History(); len(msgs) != 1 || seq != 41 {
+		t.Errorf("history = %d messages up to #%d, want 1 up to #41", len(msgs), seq)
+	}
+
+	cluster.err = nil
+	if env, err := room.AddReply("alice", protocol.TypeText, protocol.Payload{Text: "back"}, nil, ""); err != nil || env.SeqNum != 42 {
+		t.Errorf("AddReply after recovery = #%d, %v; want #42", env.SeqNum, err)
+	}
+}
+
+func TestAddReplyLocalSeq(t *testing.T) {
+	room := NewHub(100).GetOrCreateRoom("r")
+	for want := int64(1); want <= 3; want++ {
+		env, err := room.AddReply("alice", protocol.TypeText, protocol.Payload{Text: "hi"}, nil, "")
+		if err != nil || env.SeqNum != want {
+			t.Fatalf("AddReply = #%d, %v; want #%d", env.SeqNum, err, want)
+		}
+	}
+	if _, err := room.AddReply("alice", protocol.TypeText, protocol.Payload{Text: "re"}, nil, "no-such-id"); !errors.Is(err, ErrNoParent) {
+		t.Errorf("AddReply to a missing parent = %v, want ErrNoParent", err)
+	}
+}
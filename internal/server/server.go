@@ -1,11 +1,16 @@
 package server
 
 import (
+	"fmt"
 	"io/fs"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/corvino/claudetalk/internal/protocol"
+	"github.com/corvino/claudetalk/internal/rate"
 	"github.com/corvino/claudetalk/internal/runner"
 	"github.com/corvino/claudetalk/internal/web"
 )
@@ -13,37 +18,37 @@ import (
 // New creates a configured HTTP server with all routes registered.
 // fileStore may be nil to disable file storage.
 // runner may be nil to disable Claude spawning.
-func New(hub *Hub, addr string, fileStore *FileStore, r *runner.Runner) *http.Server {
-	mux := http.NewServeMux()
+// workspaces are served under /api/workspaces/{name}/ beside the default,
+// open workspace that hub and fileStore make up.
+func New(hub *Hub, addr string, fileStore *FileStore, r *runner.Runner, workspaces ...*Workspace) *http.Server {
 	h := &Handlers{
 		Hub:       hub,
 		FileStore: fileStore,
 		Runner:    r,
 		StartTime: time.Now(),
+		requests:  rate.NewWindow(runner.RecentWindow),
 	}
+	mux := apiRoutes(h)
 
-	// REST API routes.
-	mux.HandleFunc("GET /api/health", h.Health)
-	mux.HandleFunc("GET /api/rooms", h.ListRooms)
-	mux.HandleFunc("POST /api/rooms/{room}/messages", h.SendMessage)
-	mux.HandleFunc("GET /api/rooms/{room}/messages/latest", h.LatestMessages)
-	mux.HandleFunc("GET /api/rooms/{room}/messages", h.GetMessages)
-
-	// File routes.
-	mux.HandleFunc("POST /api/rooms/{room}/files", h.UploadFile)
-	mux.HandleFunc("GET /api/rooms/{room}/files/{id}", h.DownloadFile)
-	mux.HandleFunc("GET /api/rooms/{room}/files", h.ListFiles)
-
-	// Participant route.
-	mux.HandleFunc("GET /api/rooms/{room}/participants", h.ListParticipants)
-
-	// Claude runner routes.
-	mux.HandleFunc("POST /api/rooms/{room}/spawn", h.SpawnClaude)
-	mux.HandleFunc("POST /api/rooms/{room}/stop", h.StopClaude)
-	mux.HandleFunc("POST /api/rooms/{room}/synopsis", h.GenerateSynopsis)
-
-	// WebSocket route.
-	mux.HandleFunc("GET /ws/{room}", h.HandleWS)
+	if len(workspaces) > 0 {
+		router := make(workspaceRouter, len(workspaces))
+		for _, ws := range workspaces {
+			ws.handler = apiRoutes(&Handlers{Hub: ws.Hub, FileStore: ws.Files, StartTime: h.StartTime})
+			router[ws.Name] = ws
+		}
+		// One pattern per method; a method-less one would conflict with "GET /".
+		for _, method := range []string{"GET", "POST", "PUT", "DELETE"} {
+			mux.Handle(method+" /api/workspaces/", router)
+		}
+		// The web UI for a workspace; it asks for the token itself.
+		mux.HandleFunc("GET /w/{ws}/", func(w http.ResponseWriter, r *http.Request) {
+			if router[r.PathValue("ws")] == nil || r.URL.Path != "/w/"+r.PathValue("ws")+"/" {
+				http.NotFound(w, r)
+				return
+			}
+			serveIndex(w)
+		})
+	}
 
 	// Serve embedded web UI (must be after API routes).
 	staticFS, err := fs.Sub(web.StaticFS, "static")
@@ -51,22 +56,28 @@ func New(hub *Hub, addr string, fileStore *FileStore, r *runner.Runner) *http.Se
 		log.Fatalf("embedded static fs: %v", err)
 	}
 	mux.Handle("GET /static/", http.StripPrefix("/static/", noCacheHandler(http.FileServer(http.FS(staticFS)))))
-	mux.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/" {
+	// The mobile view; it reads the room and name from the query.
+	mux.HandleFunc("GET /m/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/m/" {
 			http.NotFound(w, r)
 			return
 		}
-		data, err := web.StaticFS.ReadFile("static/index.html")
-		if err != nil {
-			http.Error(w, "not found", http.StatusNotFound)
+		servePage(w, "mobile.html")
+	})
+	// A guest's share link; the web UI reads the token from the path.
+	mux.HandleFunc("GET /join/{token}", func(w http.ResponseWriter, r *http.Request) {
+		serveIndex(w)
+	})
+	mux.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
 			return
 		}
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		w.Write(data)
+		serveIndex(w)
 	})
 
 	// Wrap with logging middleware.
-	handler := loggingMiddleware(corsMiddleware(mux))
+	handler := loggingMiddleware(countErrors(h.requests, corsMiddleware(protocolMiddleware(mux))))
 
 	return &http.Server{
 		Addr:         addr,
@@ -77,6 +88,78 @@ func New(hub *Hub, addr string, fileStore *FileStore, r *runner.Runner) *http.Se
 	}
 }
 
+// apiRoutes returns a mux with the REST API and WebSocket routes served by h.
+func apiRoutes(h *Handlers) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// REST API routes. A private room's routes need its token; see roomAccess.
+	mux.HandleFunc("GET /api/health", h.Health)
+	mux.HandleFunc("GET /api/health/live", h.Live)
+	mux.HandleFunc("GET /api/health/ready", h.Ready)
+	mux.HandleFunc("GET /api/version", h.Version)
+	mux.HandleFunc("GET /api/rooms", h.ListRooms)
+	mux.HandleFunc("POST /api/rooms", h.CreateRoom)
+	mux.HandleFunc("GET /api/invites/{code}", h.GetInvite)
+	mux.HandleFunc("GET /api/share/{token}", h.GetShare)
+	mux.HandleFunc("GET /api/dms", h.ListDMs)
+	mux.HandleFunc("POST /api/rooms/{room}/messages", h.roomAccess(h.SendMessage))
+	mux.HandleFunc("GET /api/rooms/{room}/messages/latest", h.roomAccess(gzipped(h.LatestMessages)))
+	mux.HandleFunc("GET /api/rooms/{room}/messages", h.roomAccess(gzipped(h.GetMessages)))
+	mux.HandleFunc("GET /api/rooms/{room}/messages/{id}", h.roomAccess(gzipped(h.GetMessage)))
+	mux.HandleFunc("GET /api/rooms/{room}/messages/{id}/thread", h.roomAccess(gzipped(h.GetThread)))
+	mux.HandleFunc("GET /api/rooms/{room}/events", h.roomAccess(h.StreamEvents))
+	mux.HandleFunc("GET /api/rooms/{room}/conversations", h.roomAccess(h.ListConversations))
+	mux.HandleFunc("GET /api/rooms/{room}/stats", h.roomAccess(h.RoomStats))
+	mux.HandleFunc("GET /api/rooms/{room}/settings", h.roomAccess(h.GetSettings))
+	mux.HandleFunc("PUT /api/rooms/{room}/settings", h.roomAccess(h.UpdateSettings))
+	mux.HandleFunc("GET /api/rooms/{room}/charter", h.roomAccess(h.GetCharter))
+	mux.HandleFunc("PUT /api/rooms/{room}/charter", h.roomAccess(h.UpdateCharter))
+	mux.HandleFunc("POST /api/rooms/{room}/invites", h.roomAccess(h.CreateInvite))
+	mux.HandleFunc("POST /api/rooms/{room}/share", h.roomAccess(h.CreateShare))
+	mux.HandleFunc("GET /api/rooms/{room}/export", h.roomAccess(h.ExportRoom))
+	mux.HandleFunc("POST /api/rooms/{room}/import", h.roomAccess(h.ImportRoom))
+
+	// File routes.
+	mux.HandleFunc("POST /api/rooms/{room}/files", h.roomAccess(h.UploadFile))
+	mux.HandleFunc("GET /api/rooms/{room}/files/{id}", h.roomAccess(h.DownloadFile))
+	mux.HandleFunc("DELETE /api/rooms/{room}/files/{id}", h.roomAccess(h.DeleteFile))
+	mux.HandleFunc("GET /api/rooms/{room}/files", h.roomAccess(gzipped(h.ListFiles)))
+
+	// Participant route.
+	mux.HandleFunc("GET /api/rooms/{room}/participants", h.roomAccess(h.ListParticipants))
+
+	// Claude runner routes.
+	mux.HandleFunc("POST /api/rooms/{room}/spawn", h.roomAccess(h.SpawnClaude))
+	mux.HandleFunc("GET /api/rooms/{room}/spawn/options", h.roomAccess(h.SpawnOptions))
+	mux.HandleFunc("POST /api/rooms/{room}/stop", h.roomAccess(h.StopClaude))
+	mux.HandleFunc("POST /api/rooms/{room}/help", h.roomAccess(h.RequestHelp))
+	mux.HandleFunc("POST /api/rooms/{room}/synopsis", h.roomAccess(gzipped(h.GenerateSynopsis)))
+	mux.HandleFunc("GET /api/rooms/{room}/spawns", h.roomAccess(h.ListSpawns))
+	mux.HandleFunc("GET /api/rooms/{room}/sessions", h.roomAccess(h.ListSessions))
+	mux.HandleFunc("GET /api/runner/stats", h.RunnerStats)
+
+	// WebSocket routes.
+	mux.HandleFunc("GET /ws/{room}", h.HandleWS)
+	mux.HandleFunc("GET /ws", h.HandleMultiWS)
+
+	return mux
+}
+
+func serveIndex(w http.ResponseWriter) {
+	servePage(w, "index.html")
+}
+
+// servePage serves an HTML page of the embedded web UI.
+func servePage(w http.ResponseWriter, name string) {
+	data, err := web.StaticFS.ReadFile("static/" + name)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(data)
+}
+
 func noCacheHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
@@ -92,11 +175,44 @@ func loggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// versionHeader is the protocol version a response reports; see
+// protocol.CheckServer.
+func versionHeader() http.Header {
+	h := protocol.VersionHeader()
+	h.Set(protocol.MinProtocolHeader, strconv.Itoa(protocol.MinProtocol))
+	return h
+}
+
+// protocolMiddleware reports the server's protocol version on every
+// response and refuses clients older than protocol.MinProtocol. Clients that
+// don't send a version are served.
+func protocolMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for key, values := range versionHeader() {
+			w.Header()[key] = values
+		}
+		if v := protocol.HeaderProtocol(r.Header, protocol.ProtocolHeader); v != 0 && v < protocol.MinProtocol {
+			writeError(w, http.StatusUpgradeRequired, fmt.Sprintf("this server needs protocol %d or later and the client speaks %d; run \"claudetalk self-update\"", protocol.MinProtocol, v))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// corsHeaders are the request headers browsers may send cross-origin: the
+// workspace, room and share tokens and the protocol version.
+var corsHeaders = strings.Join([]string{
+	"Content-Type", "Authorization",
+	protocol.RoomTokenHeader, protocol.ShareHeader,
+	protocol.ProtocolHeader, protocol.MinProtocolHeader,
+}, ", ")
+
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", corsHeaders)
+		w.Header().Set("Access-Control-Expose-Headers", protocol.ProtocolHeader+", "+protocol.MinProtocolHeader)
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
 			return
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// Room visibilities for RoomSettings.Visibility.
+const (
+	VisibilityPublic   = "public"   // listed and open to anyone (the default)
+	VisibilityUnlisted = "unlisted" // open to anyone who knows its name, but not listed
+	VisibilityPrivate  = "private"  // not listed, and closed without its access token
+)
+
+// RoomSettings are per-room options configured through the settings API.
+type RoomSettings struct {
+	WorkDir    string `json:"work_dir,omitempty"`   // working directory for Claudes spawned in this room
+	Visibility string `json:"visibility,omitempty"` // public (default), unlisted or private
+	Token      string `json:"token,omitempty"`      // a private room's access token; set by the server
+	Prompt     string `json:"prompt,omitempty"`     // spawn prompt overrides for this room; see package prompt
+}
+
+// validVisibility reports whether v is a visibility; "" means public.
+func validVisibility(v string) error {
+	switch v {
+	case "", VisibilityPublic, VisibilityUnlisted, VisibilityPrivate:
+		return nil
+	}
+	return fmt.Errorf("visibility must be %s, %s or %s, got %q", VisibilityPublic, VisibilityUnlisted, VisibilityPrivate, v)
+}
+
+// Settings returns the room's current settings.
+func (r *Room) Settings() RoomSettings {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.settings
+}
+
+// SetSettings replaces the room's settings and returns them as stored. The
+// access token is the server's: a room keeps its token while it stays
+// private, gets a new one when it becomes private and loses it otherwise.
+func (r *Room) SetSettings(s RoomSettings) RoomSettings {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s.Token = ""
+	if s.Visibility == VisibilityPrivate {
+		if s.Token = r.settings.Token; s.Token == "" {
+			b := make([]byte, 16)
+			rand.Read(b)
+			s.Token = hex.EncodeToString(b)
+		}
+	}
+	r.settings = s
+	r.enqueue(Event{Kind: EventRoomUpdated})
+	return s
+}
+
+// SetPromptTemplate sets the prompt overrides, a text/template redefining
+// sections of the built-in spawn prompt, for every room, including those
+// already restored from -state or a journal. Each room's prompt setting is
+// applied on top of them.
+func (h *Hub) SetPromptTemplate(src string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.promptTmpl = src
+	for _, r := range h.rooms {
+		r.mu.Lock()
+		r.promptTmpl = src
+		r.mu.Unlock()
+	}
+}
+
+// PromptTemplates returns the prompt overrides for spawns in the room, the
+// server's and then the room's, for SpawnReq.PromptTemplates.
+func (r *Room) PromptTemplates() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []string
+	for _, src := range []string{r.promptTmpl, r.settings.Prompt} {
+		if src != "" {
+			out = append(out, src)
+		}
+	}
+	return out
+}
+
+// RoomToken returns the access token of the named room if it is private, or
+// "". It is the runner's view of private rooms; see runner.Config.RoomToken.
+func (h *Hub) RoomToken(room string) string {
+	if r := h.GetRoom(room); r != nil {
+		return r.Settings().Token
+	}
+	return ""
+}
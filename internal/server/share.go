@@ -0,0 +1,250 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// Share link access levels.
+const (
+	ShareRead = "read" // read the room
+	SharePost = "post" // read the room and send messages as the link's name
+)
+
+// DefaultShareTTL is how long a share link lasts when its creator doesn't say.
+const DefaultShareTTL = 24 * time.Hour
+
+// maxShareTTL caps how long a share link may last.
+const maxShareTTL = 30 * 24 * time.Hour
+
+// guestName is who a guest with an unnamed read-only link is in the room.
+const guestName = "guest"
+
+// Share link errors.
+var (
+	errBadShare     = errors.New("this share link is invalid")
+	errExpiredShare = errors.New("this share link has expired")
+)
+
+// share is what a share link token grants; the token is these claims as
+// JSON, signed with the hub's share key. Nothing is stored, so links work
+// until they expire, on every instance with the same key.
+type share struct {
+	Room    string `json:"r"`
+	Access  string `json:"a"`
+	Name    string `json:"n,omitempty"`
+	Expires int64  `json:"e"` // Unix seconds
+}
+
+// sender is the name the guest takes part as.
+func (s share) sender() string {
+	if s.Name == "" {
+		return guestName
+	}
+	return s.Name
+}
+
+func (s share) link(token string) protocol.ShareLink {
+	return protocol.ShareLink{
+		Token:     token,
+		Path:      "/join/" + token,
+		Room:      s.Room,
+		Access:    s.Access,
+		Name:      s.Name,
+		ExpiresAt: time.Unix(s.Expires, 0).UTC(),
+	}
+}
+
+// guestRoutes are the routes a share link opens, by pattern, and the access
+// each needs. The rest of the room's routes stay closed to guests: spawns,
+// sessions, spawn options and exports show spawn prompts and working
+// directories, and settings carry the room's token.
+var guestRoutes = map[string]string{
+	"GET /api/rooms/{room}/messages":             ShareRead,
+	"GET /api/rooms/{room}/messages/latest":      ShareRead,
+	"GET /api/rooms/{room}/messages/{id}":        ShareRead,
+	"GET /api/rooms/{room}/messages/{id}/thread": ShareRead,
+	"GET /api/rooms/{room}/events":               ShareRead,
+	"GET /api/rooms/{room}/files":                ShareRead,
+	"GET /api/rooms/{room}/files/{id}":           ShareRead,
+	"GET /api/rooms/{room}/participants":         ShareRead,
+	"POST /api/rooms/{room}/messages":            SharePost,
+}
+
+// permits reports why a guest's request to room is refused, or nil: guests
+// may read their link's room's messages, files and participants, and with
+// post access send messages to it. r.Pattern is the route that matched.
+func (s share) permits(r *http.Request, room string) error {
+	if room != s.Room {
+		return fmt.Errorf("this share link is for room %q", s.Room)
+	}
+	switch guestRoutes[r.Pattern] {
+	case ShareRead:
+		return nil
+	case SharePost:
+		if s.Access == SharePost {
+			return nil
+		}
+		return errors.New("this guest link is read-only")
+	}
+	return errors.New("a guest link can only read the room's messages and files, and send messages if it allows")
+}
+
+// newShareKey returns a random share key. Links signed with it stop working
+// when the server restarts unless SetShareSecret is used.
+func newShareKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("share key: %v", err))
+	}
+	return key
+}
+
+// SetShareSecret sets the secret share links are signed with, so they keep
+// working across restarts and on every instance given the same secret.
+// Changing it revokes every link made before.
+func (h *Hub) SetShareSecret(secret string) {
+	key := sha256.Sum256([]byte(secret))
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.shareKey = key[:]
+}
+
+func (h *Hub) shareSig(payload string) string {
+	h.mu.RLock()
+	key := h.shareKey
+	h.mu.RUnlock()
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// NewShare signs a share link to an existing room, valid for ttl.
+func (h *Hub) NewShare(room, access, name string, ttl time.Duration) (protocol.ShareLink, error) {
+	if h.GetRoom(room) == nil {
+		return protocol.ShareLink{}, fmt.Errorf("room %q: %w", room, ErrNoSuchRoom)
+	}
+	s := share{Room: room, Access: access, Name: name, Expires: time.Now().Add(ttl).Unix()}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return protocol.ShareLink{}, err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return s.link(payload + "." + h.shareSig(payload)), nil
+}
+
+// verifyShare checks a share link token's signature and expiry.
+func (h *Hub) verifyShare(token string) (share, error) {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(h.shareSig(payload))) {
+		return share{}, errBadShare
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return share{}, errBadShare
+	}
+	var s share
+	if err := json.Unmarshal(data, &s); err != nil {
+		return share{}, errBadShare
+	}
+	if time.Now().Unix() >= s.Expires {
+		return share{}, errExpiredShare
+	}
+	return s, nil
+}
+
+// guest returns the share link a request carries in protocol.ShareHeader or
+// ShareParam. ok is false for a request without one; err is set for one
+// that is forged or expired.
+func (h *Hub) guest(r *http.Request) (s share, ok bool, err error) {
+	token := r.Header.Get(protocol.ShareHeader)
+	if token == "" {
+		token = r.URL.Query().Get(protocol.ShareParam)
+	}
+	if token == "" {
+		return share{}, false, nil
+	}
+	s, err = h.verifyShare(token)
+	return s, true, err
+}
+
+// CreateShare handles POST /api/rooms/{room}/share with an optional body
+// {"sender": ..., "access": "read" or "post", "name": ..., "ttl": seconds}.
+// A post link needs the name its guest sends as.
+func (h *Handlers) CreateShare(w http.ResponseWriter, r *http.Request) {
+	roomName := r.PathValue("room")
+	if roomName == "" {
+		writeError(w, http.StatusBadRequest, "room name required")
+		return
+	}
+	var req struct {
+		Sender string `json:"sender"`
+		Access string `json:"access"` // read (default) or post
+		Name   string `json:"name"`
+		TTL    int    `json:"ttl"` // seconds; 0 = DefaultShareTTL
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %v", err))
+			return
+		}
+	}
+	switch req.Access {
+	case "":
+		req.Access = ShareRead
+	case ShareRead, SharePost:
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("access must be %s or %s", ShareRead, SharePost))
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Access == SharePost && req.Name == "" {
+		writeError(w, http.StatusBadRequest, "a post link needs the name its guest sends as")
+		return
+	}
+	if _, ok := h.Hub.ClaudeOwner(req.Name); ok {
+		writeError(w, http.StatusConflict, fmt.Sprintf("%q is a Claude's name", req.Name))
+		return
+	}
+	ttl := DefaultShareTTL
+	if req.TTL > 0 {
+		ttl = time.Duration(req.TTL) * time.Second
+	}
+	if req.TTL < 0 || ttl > maxShareTTL {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("ttl must be between 1 second and %s", maxShareTTL))
+		return
+	}
+
+	link, err := h.Hub.NewShare(roomName, req.Access, req.Name, ttl)
+	if errors.Is(err, ErrNoSuchRoom) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, link)
+}
+
+// GetShare handles GET /api/share/{token}: what the link grants, if it is
+// valid and unexpired.
+func (h *Handlers) GetShare(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+	s, err := h.Hub.verifyShare(token)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, s.link(token))
+}
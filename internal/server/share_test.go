@@ -0,0 +1,154 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// routed returns a request as apiRoutes routes it, with its Pattern set.
+func routed(t *testing.T, mux *http.ServeMux, method, path string) *http.Request {
+	t.Helper()
+	r := httptest.NewRequest(method, path, nil)
+	_, r.Pattern = mux.Handler(r)
+	if r.Pattern == "" {
+		t.Fatalf("%s %s matches no route", method, path)
+	}
+	return r
+}
+
+func TestSharePermits(t *testing.T) {
+	mux := apiRoutes(&Handlers{Hub: NewHub(100)})
+	read := share{Room: "r", Access: ShareRead}
+	post := share{Room: "r", Access: SharePost, Name: "gus"}
+
+	tests := []struct {
+		method, path string
+		read, post   bool // whether each link allows it
+	}{
+		{"GET", "/api/rooms/r/messages", true, true},
+		{"HEAD", "/api/rooms/r/messages", true, true},
+		{"GET", "/api/rooms/r/messages/latest", true, true},
+		{"GET", "/api/rooms/r/messages/7", true, true},
+		{"GET", "/api/rooms/r/messages/7/thread", true, true},
+		{"GET", "/api/rooms/r/events", true, true},
+		{"GET", "/api/rooms/r/files", true, true},
+		{"GET", "/api/rooms/r/files/f1", true, true},
+		{"GET", "/api/rooms/r/participants", true, true},
+		{"POST", "/api/rooms/r/messages", false, true},
+
+		// Spawn prompts, working directories and the room token stay hidden.
+		{"GET", "/api/rooms/r/spawns", false, false},
+		{"GET", "/api/rooms/r/sessions", false, false},
+		{"GET", "/api/rooms/r/spawn/options", false, false},
+		{"GET", "/api/rooms/r/export", false, false},
+		{"GET", "/api/rooms/r/settings", false, false},
+		{"GET", "/api/rooms/r/charter", false, false},
+		{"GET", "/api/rooms/r/conversations", false, false},
+		{"GET", "/api/rooms/r/stats", false, false},
+		{"POST", "/api/rooms/r/spawn", false, false},
+		{"POST", "/api/rooms/r/stop", false, false},
+		{"POST", "/api/rooms/r/share", false, false},
+		{"POST", "/api/rooms/r/files", false, false},
+		{"PUT", "/api/rooms/r/settings", false, false},
+		{"DELETE", "/api/rooms/r/files/f1", false, false},
+	}
+	for _, tt := range tests {
+		r := routed(t, mux, tt.method, tt.path)
+		if err := read.permits(r, "r"); (err == nil) != tt.read {
+			t.Errorf("read link, %s %s: %v, want allowed=%t", tt.method, tt.path, err, tt.read)
+		}
+		if err := post.permits(r, "r"); (err == nil) != tt.post {
+			t.Errorf("post link, %s %s: %v, want allowed=%t", tt.method, tt.path, err, tt.post)
+		}
+	}
+
+	// Another room is refused whatever the route.
+	if err := post.permits(routed(t, mux, "GET", "/api/rooms/other/messages"), "other"); err == nil {
+		t.Error("a link for r reads room other")
+	}
+}
+
+func TestGuestRoutesExist(t *testing.T) {
+	mux := apiRoutes(&Handlers{Hub: NewHub(100)})
+	for pattern := range guestRoutes {
+		method, path, _ := strings.Cut(pattern, " ")
+		path = strings.NewReplacer("{room}", "r", "{id}", "x").Replace(path)
+		if got := routed(t, mux, method, path).Pattern; got != pattern {
+			t.Errorf("guest route %q is served as %q", pattern, got)
+		}
+	}
+}
+
+func TestShareTokens(t *testing.T) {
+	hub := NewHub(100)
+	hub.GetOrCreateRoom("r")
+
+	link, err := hub.NewShare("r", SharePost, "gus", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := hub.verifyShare(link.Token)
+	if err != nil {
+		t.Fatalf("verifyShare: %v", err)
+	}
+	if s.Room != "r" || s.Access != SharePost || s.sender() != "gus" {
+		t.Errorf("share = %+v", s)
+	}
+
+	// Claims can't be changed without the key.
+	payload, sig, _ := strings.Cut(link.Token, ".")
+	forged := strings.TrimSuffix(payload, payload[len(payload)-2:]) + "xx." + sig
+	if _, err := hub.verifyShare(forged); err != errBadShare {
+		t.Errorf("forged token: %v, want errBadShare", err)
+	}
+	for _, bad := range []string{"", "nodot", link.Token + "x"} {
+		if _, err := hub.verifyShare(bad); err != errBadShare {
+			t.Errorf("verifyShare(%q) = %v, want errBadShare", bad, err)
+		}
+	}
+
+	expired, err := hub.NewShare("r", ShareRead, "", -time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hub.verifyShare(expired.Token); err != errExpiredShare {
+		t.Errorf("expired token: %v, want errExpiredShare", err)
+	}
+
+	// A new secret revokes every link signed before it.
+	hub.SetShareSecret("rotated")
+	if _, err := hub.verifyShare(link.Token); err != errBadShare {
+		t.Errorf("token after the secret changed: %v, want errBadShare", err)
+	}
+
+	if _, err := hub.NewShare("missing", ShareRead, "", time.Hour); err == nil {
+		t.Error("NewShare for a room that doesn't exist succeeded")
+	}
+}
+
+func TestGuestFromRequest(t *testing.T) {
+	hub := NewHub(100)
+	hub.GetOrCreateRoom("r")
+	link, err := hub.NewShare("r", ShareRead, "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", "/api/rooms/r/messages", nil)
+	if _, ok, _ := hub.guest(r); ok {
+		t.Error("a request without a link is a guest's")
+	}
+	r.Header.Set(protocol.ShareHeader, link.Token)
+	if s, ok, err := hub.guest(r); !ok || err != nil || s.sender() != guestName {
+		t.Errorf("guest = %+v, %t, %v; want the unnamed guest", s, ok, err)
+	}
+	r = httptest.NewRequest("GET", "/api/rooms/r/messages?"+protocol.ShareParam+"=bogus", nil)
+	if _, ok, err := hub.guest(r); !ok || err == nil {
+		t.Errorf("guest with a bogus link = %t, %v; want a guest with an error", ok, err)
+	}
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/runner"
+	"github.com/gorilla/websocket"
+)
+
+// shutdownGrace is the least time requests in flight, such as uploads, get
+// to finish when the server shuts down, however long the drain took.
+const shutdownGrace = 5 * time.Second
+
+// Announce tells everyone connected to every room text, as a Notice: news
+// of a restart has no place in the rooms' history.
+func (h *Hub) Announce(text string) {
+	for _, snap := range h.ListRooms() {
+		if room := h.GetRoom(snap.Name); room != nil {
+			room.Notice("", text, nil)
+		}
+	}
+}
+
+// drain marks the hub as draining, so it reports itself not ready, and ends
+// its event streams, whose clients reconnect to the next server.
+func (h *Hub) drain() {
+	if h.draining.CompareAndSwap(false, true) {
+		close(h.drained)
+	}
+}
+
+// CloseClients tells every WebSocket client the server is restarting and
+// disconnects it, spacing the disconnects evenly over spread so the clients
+// don't all reconnect to the next server at once.
+func (h *Hub) CloseClients(spread time.Duration) {
+	seen := make(map[*Client]bool)
+	var clients []*Client
+	for _, snap := range h.ListRooms() {
+		room := h.GetRoom(snap.Name)
+		if room == nil {
+			continue
+		}
+		room.mu.RLock()
+		for c := range room.clients {
+			if !seen[c] {
+				seen[c] = true
+				clients = append(clients, c)
+			}
+		}
+		room.mu.RUnlock()
+	}
+	if len(clients) == 0 {
+		return
+	}
+	log.Printf("disconnecting %d WebSocket clients over %s", len(clients), spread)
+	gap := spread / time.Duration(len(clients))
+	msg := websocket.FormatCloseMessage(websocket.CloseServiceRestart, "server restarting")
+	for i, c := range clients {
+		if i > 0 {
+			time.Sleep(gap)
+		}
+		c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait))
+		c.conn.Close()
+	}
+}
+
+// Shutdown stops srv gently. The server reports itself not ready, event
+// streams end, no new Claudes are spawned, the rooms are told, and running
+// sessions get up to drain to finish before they are stopped, and
+// shutdownGrace more to exit. Then requests in flight get shutdownGrace to
+// complete, and WebSocket clients are disconnected over spread; see
+// CloseClients. rnr may be nil, for a server that doesn't run Claude. The
+// event streams and WebSocket clients of srv's workspaces end too.
+func Shutdown(srv *http.Server, hub *Hub, rnr *runner.Runner, drain, spread time.Duration, workspaces ...*Workspace) error {
+	hub.drain()
+	for _, ws := range workspaces {
+		ws.Hub.drain()
+	}
+	active := 0
+	if rnr != nil {
+		active = rnr.Sessions().Active()
+	}
+	if active == 0 {
+		hub.Announce("The server is shutting down.")
+	} else {
+		log.Printf("waiting up to %s for %d Claude sessions to finish", drain, active)
+		hub.Announce(fmt.Sprintf("The server is shutting down. Running Claudes have %s to finish; no new ones start.", drain))
+	}
+	if rnr != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), drain)
+		left := rnr.Sessions().Drain(ctx)
+		cancel()
+		if left > 0 {
+			log.Printf("stopping %d Claude sessions still running", left)
+			rnr.Sessions().StopAll()
+			ctx, cancel := context.WithTimeout(context.Background(), shutdownGrace)
+			rnr.Sessions().Drain(ctx)
+			cancel()
+			hub.Announce(fmt.Sprintf("Stopped %d Claude sessions that didn't finish in time.", left))
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownGrace)
+	defer cancel()
+	err := srv.Shutdown(ctx)
+	hub.CloseClients(spread)
+	for _, ws := range workspaces {
+		ws.Hub.CloseClients(spread)
+	}
+	return err
+}
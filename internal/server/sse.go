@@ -0,0 +1,151 @@
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// sseKeepalive is how often an idle event stream gets a comment, so proxies
+// and phones on flaky networks don't drop it.
+const sseKeepalive = 25 * time.Second
+
+// StreamEvents handles GET /api/rooms/{room}/events: the room's messages as
+// server-sent events, for clients where a WebSocket is too much, such as the
+// mobile view. A "message" event is an Envelope with its seq as the event
+// ID, so a reconnecting EventSource resumes where it left off; a
+// "message_expired" event carries the ID and seq of a purged one, and a
+// Room.Notice comes as a "message" event without an ID. Without
+// Last-Event-ID or ?after=seq, the latest ?n messages (default 20) come
+// first. Private messages only reach the ?sender they are from or to.
+func (h *Handlers) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	roomName := r.PathValue("room")
+	room := h.Hub.GetRoom(roomName)
+	if room == nil {
+		writeError(w, http.StatusNotFound, "room not found")
+		return
+	}
+	q := r.URL.Query()
+	sender := q.Get("sender")
+	after := int64(-1)
+	if v := cmp.Or(r.Header.Get("Last-Event-ID"), q.Get("after")); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "invalid after parameter")
+			return
+		}
+		after = n
+	}
+	latest := 20
+	if v := q.Get("n"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "invalid n parameter")
+			return
+		}
+		latest = n
+	}
+
+	// Subscribe before reading the backlog, so nothing falls in between;
+	// what arrives twice is skipped by seq. A client too slow to keep up is
+	// cut off, and catches up when its EventSource reconnects.
+	events := make(chan Event, 256)
+	overflow := make(chan struct{})
+	var once sync.Once
+	unsubscribe := h.Hub.Events().Subscribe(func(ev Event) {
+		if ev.Room != roomName {
+			return
+		}
+		select {
+		case events <- ev:
+		default:
+			once.Do(func() { close(overflow) })
+		}
+	}, EventMessage, EventMessageExpired, EventNotice)
+	defer unsubscribe()
+
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{}) // the stream outlives the server's write timeout
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	var backlog []protocol.Envelope
+	if after >= 0 {
+		backlog = room.MessagesAfter(after, h.Hub.maxHistory)
+	} else {
+		backlog = room.LatestMessages(latest)
+	}
+	last := after
+	for _, env := range backlog {
+		if visibleTo(env, sender) {
+			writeSSE(w, "message", env.SeqNum, env)
+		}
+		last = max(last, env.SeqNum)
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	keepalive := time.NewTicker(sseKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-overflow:
+			return
+		case <-h.Hub.drained:
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		case ev := <-events:
+			env := ev.Message
+			switch {
+			case ev.Kind == EventMessageExpired:
+				writeSSE(w, ev.Kind, 0, protocol.Envelope{ID: env.ID, Room: env.Room, SeqNum: env.SeqNum})
+			case ev.Kind == EventNotice:
+				if visibleTo(*env, sender) {
+					writeSSE(w, "message", 0, *env)
+				}
+			case env.SeqNum <= last || !visibleTo(*env, sender):
+				continue
+			default:
+				writeSSE(w, "message", env.SeqNum, env)
+				last = env.SeqNum
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// visibleTo reports whether sender may see env: a private message only its
+// sender and recipient may, as for WebSocket clients; see Room.fanout.
+func visibleTo(env protocol.Envelope, sender string) bool {
+	if env.Metadata["private"] != "true" {
+		return true
+	}
+	return sender != "" && (sender == env.Sender || sender == env.Metadata["to"])
+}
+
+// writeSSE writes one server-sent event; id 0 leaves the last event ID as it
+// was.
+func writeSSE(w http.ResponseWriter, event string, id int64, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	if id > 0 {
+		fmt.Fprintf(w, "id: %d\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// roomState is a room as SaveState writes it.
+type roomState struct {
+	Name     string              `json:"name"`
+	Settings RoomSettings        `json:"settings"`
+	Charter  protocol.Charter    `json:"charter"`
+	LastSeq  int64               `json:"last_seq"`
+	Messages []protocol.Envelope `json:"messages"`
+	Files    []protocol.FileInfo `json:"files,omitempty"`
+}
+
+// SaveState writes every room to path as JSON: its settings, private token
+// included, charter, history and shared files' metadata. The files' contents
+// stay where files keeps them. It is for a single instance without -redis,
+// which keeps rooms itself; see LoadState.
+func (h *Hub) SaveState(path string, files *FileStore) error {
+	var rooms []roomState
+	for _, snap := range h.ListRooms() {
+		r := h.GetRoom(snap.Name)
+		if r == nil {
+			continue
+		}
+		msgs, lastSeq := r.History()
+		st := roomState{Name: snap.Name, Settings: r.Settings(), Charter: r.Charter(), LastSeq: lastSeq, Messages: msgs}
+		if files != nil {
+			st.Files = files.List(snap.Name)
+		}
+		rooms = append(rooms, st)
+	}
+	data, err := json.Marshal(rooms)
+	if err != nil {
+		return err
+	}
+
+	// Write and rename, so a crash mid-write leaves the last state whole.
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadState restores the rooms SaveState wrote to path into a hub that has
+// none of them yet, with their files if still in files' directory. It
+// returns how many rooms it restored; no file at path is no rooms.
+func (h *Hub) LoadState(path string, files *FileStore) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var rooms []roomState
+	if err := json.Unmarshal(data, &rooms); err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, st := range rooms {
+		r, err := h.CreateRoom(st.Name, st.Settings)
+		if err != nil {
+			return 0, err
+		}
+		// Keep the token invites and members already have.
+		r.mu.Lock()
+		r.settings.Token = st.Settings.Token
+		r.mu.Unlock()
+		r.SetCharter(st.Charter)
+		if err := r.Import(st.Messages, st.LastSeq); err != nil {
+			return 0, fmt.Errorf("room %q: %w", st.Name, err)
+		}
+		if files != nil {
+			for _, info := range st.Files {
+				files.Adopt(info)
+			}
+		}
+	}
+	return len(rooms), nil
+}
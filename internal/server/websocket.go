@@ -2,8 +2,12 @@ package server
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/corvino/claudetalk/internal/protocol"
@@ -23,21 +27,35 @@ var upgrader = websocket.Upgrader{
 	CheckOrigin:     func(r *http.Request) bool { return true },
 }
 
-// Client represents a WebSocket connection in a room.
+// Client represents a WebSocket connection subscribed to one or more rooms.
 type Client struct {
-	room    *Room
-	conn    *websocket.Conn
-	send    chan protocol.Envelope
-	rawSend chan []byte // raw JSON frames; all writes go through writePump
-	sender  string
-	mode    string // "legacy" or "daemon"
-	role    string // "daemon", "user", etc.
+	rooms      []*Room // subscribed rooms; rooms[0] is the default for outgoing messages
+	conn       *websocket.Conn
+	send       chan []byte // encoded room messages, from the room dispatchers
+	rawSend    chan []byte // other events; all writes go through writePump
+	sender     string
+	mode       string           // "legacy" or "daemon"
+	role       string           // "daemon", "user", etc.
+	filter     *MessageFilter   // optional server-side filter (from, type, conv_id, match)
+	mentions   []string         // @handles that summon this daemon from broadcasts; empty = opted out
+	priority   int              // daemon claim priority; higher wins the name, see Room.claim
+	takeover   bool             // take the name's claim from the current holder regardless of priority
+	helpPolicy string           // owner's policy for help requests; see protocol.HelpAllow
+	profile    protocol.Profile // what the participant advertised in its URL
+	claudeName string           // the name this daemon's Claudes speak as, when picked; see Hub.NameClaude
+	agents     []string         // named agents this daemon also spawns for; see Hub.NameAgent
+	guest      *share           // the share link of a guest's connection; nil for members
+	hub        *Hub
+
+	// payloads is how the client asked for its spawn contexts' payloads
+	// trimmed; see sendSpawn.
+	payloads protocol.PayloadOptions
 }
 
-// Send queues an envelope for delivery to this client.
-func (c *Client) Send(env protocol.Envelope) {
+// queue queues an encoded room message for delivery to this client.
+func (c *Client) queue(frame []byte) {
 	select {
-	case c.send <- env:
+	case c.send <- frame:
 	default:
 		// Client too slow; drop message.
 	}
@@ -51,6 +69,18 @@ func (c *Client) SendEvent(event protocol.ServerEvent) {
 	c.sendRaw(event)
 }
 
+// sendSpawn sends a spawn event, its context's payloads trimmed as the
+// client asked. The trigger always goes whole.
+func (c *Client) sendSpawn(spawn *protocol.SpawnReq) {
+	if len(c.payloads.Fields) > 0 || c.payloads.Max > 0 {
+		trimmed := *spawn
+		trimmed.Context = slices.Clone(spawn.Context)
+		c.payloads.TrimAll(trimmed.Context)
+		spawn = &trimmed
+	}
+	c.sendRaw(protocol.ServerEvent{Event: "spawn", Spawn: spawn})
+}
+
 // sendRaw queues an arbitrary JSON value for delivery via writePump.
 // Safe to call from any goroutine.
 func (c *Client) sendRaw(v any) {
@@ -58,6 +88,11 @@ func (c *Client) sendRaw(v any) {
 	if err != nil {
 		return
 	}
+	c.queueRaw(data)
+}
+
+// queueRaw queues an encoded event for delivery via writePump.
+func (c *Client) queueRaw(data []byte) {
 	select {
 	case c.rawSend <- data:
 	default:
@@ -65,11 +100,57 @@ func (c *Client) sendRaw(v any) {
 	}
 }
 
+// sendClaim tells a daemon client whether it receives spawn events for its
+// name in room.
+func (c *Client) sendClaim(room, state, reason string) {
+	c.SendEvent(protocol.ServerEvent{
+		Event: "claim",
+		Claim: &protocol.ClaimStatus{Room: room, State: state, Reason: reason},
+	})
+}
+
+// mentionedIn reports whether env is a broadcast that @-mentions one of this
+// daemon's handles.
+func (c *Client) mentionedIn(env protocol.Envelope) bool {
+	if c.mode != "daemon" || len(c.mentions) == 0 || env.Sender == c.sender || env.Metadata["to"] != "" {
+		return false
+	}
+	text := strings.ToLower(env.Payload.Text)
+	for _, h := range c.mentions {
+		if strings.Contains(text, "@"+strings.ToLower(h)) || protocol.Mentioned(env.Metadata, h) {
+			return true
+		}
+	}
+	return false
+}
+
+// roomFor returns the subscribed room with the given name, or nil.
+func (c *Client) roomFor(name string) *Room {
+	for _, r := range c.rooms {
+		if r.name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// roomNames returns the names of all subscribed rooms, for logging.
+func (c *Client) roomNames() string {
+	names := make([]string, len(c.rooms))
+	for i, r := range c.rooms {
+		names[i] = r.name
+	}
+	return strings.Join(names, ",")
+}
+
 // readPump reads messages from the WebSocket and posts them to the room.
+// Multi-room clients pick the target room with the request's room field.
 func (c *Client) readPump() {
 	defer func() {
-		c.room.UnregisterClient(c)
-		c.room.UntrackParticipant(c.sender)
+		for _, r := range c.rooms {
+			r.UnregisterClient(c)
+			r.UntrackParticipant(c.sender, c)
+		}
 		c.conn.Close()
 	}()
 	c.conn.SetReadLimit(maxMsgSize)
@@ -83,12 +164,49 @@ func (c *Client) readPump() {
 		err := c.conn.ReadJSON(&req)
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
-				log.Printf("ws read error room=%s sender=%s: %v", c.room.name, c.sender, err)
+				log.Printf("ws read error room=%s sender=%s: %v", c.roomNames(), c.sender, err)
 			}
 			return
 		}
+		if req.Health != nil {
+			if c.mode == "daemon" {
+				for _, r := range c.rooms {
+					if r.HoldsClaim(c) {
+						r.SetHealth(c.sender, *req.Health)
+					}
+				}
+			}
+			continue
+		}
+		if req.Ack != nil {
+			if c.mode == "daemon" {
+				room := c.rooms[0]
+				if req.Room != "" {
+					room = c.roomFor(req.Room)
+				}
+				if _, dm := c.hub.dmPeer(req.Room, c.sender); room == nil && dm {
+					room = c.hub.GetRoom(req.Room) // a DM spawn routed here; see dmElsewhere
+				}
+				if room != nil {
+					// The message may be to a name this daemon spawns as.
+					for _, as := range append([]string{c.sender, c.claudeName}, c.agents...) {
+						if as != "" && room.MarkDelivered(req.Ack.MessageID, as) {
+							break
+						}
+					}
+				}
+			}
+			continue
+		}
+		// A guest sends as the link says, if at all.
+		if c.guest != nil {
+			if c.guest.Access != SharePost {
+				continue
+			}
+			req.Sender = c.sender
+		}
 		// Drop empty/ping-only frames.
-		if req.Payload.Text == "" && req.Payload.Code == "" && req.Payload.Diff == "" && req.Type != protocol.TypeFile {
+		if req.Payload.Text == "" && req.Payload.Code == "" && req.Payload.Diff == "" && len(req.Payload.Data) == 0 && req.Type != protocol.TypeFile {
 			continue
 		}
 		sender := req.Sender
@@ -99,11 +217,30 @@ func (c *Client) readPump() {
 		if msgType == "" {
 			msgType = protocol.TypeText
 		}
-		c.room.AddMessage(sender, msgType, req.Payload, req.Metadata)
+		room := c.rooms[0]
+		if req.Room != "" {
+			if room = c.roomFor(req.Room); room == nil {
+				log.Printf("ws: %s sent to unsubscribed room %q; dropping", c.sender, req.Room)
+				continue
+			}
+		}
+		metadata, err := room.dmAddress(sender, withExpiry(req.Metadata, req.TTL))
+		if err != nil {
+			log.Printf("ws: %s in %s: %v; dropping", sender, room.name, err)
+			continue
+		}
+		env, err := room.AddReply(sender, msgType, req.Payload, metadata, req.ReplyTo)
+		if err != nil {
+			log.Printf("ws: %s in %s: %v; dropping", c.sender, room.name, err)
+			continue
+		}
+		room.WarnUndeliverable(env)
 	}
 }
 
-// writePump sends messages from the send channel to the WebSocket.
+// writePump writes queued messages and events to the WebSocket. Each wakeup
+// writes everything already queued under one deadline, so a burst costs one
+// trip through the loop rather than one per message.
 func (c *Client) writePump() {
 	ticker := time.NewTicker(pingPeriod)
 	defer func() {
@@ -111,88 +248,141 @@ func (c *Client) writePump() {
 		c.conn.Close()
 	}()
 	for {
+		var first []byte
 		select {
-		case env, ok := <-c.send:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+		case data, ok := <-c.send:
 			if !ok {
+				c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
 			}
-			if c.mode == "daemon" {
-				// Daemon clients receive ServerEvent wrappers.
-				event := protocol.ServerEvent{
-					Event:   "message",
-					Message: &env,
-				}
-				if err := c.conn.WriteJSON(event); err != nil {
-					return
-				}
-			} else {
-				// Legacy clients receive bare envelopes.
-				if err := c.conn.WriteJSON(env); err != nil {
-					return
-				}
-			}
-
-			// After sending the message, trigger spawn events for all relevant daemon clients.
-			// For group conv_id threads, this notifies every thread participant except the sender.
-			if targets, allParticipants := c.room.GetConvSpawnTargets(env); len(targets) > 0 {
-				ctx := c.room.LatestMessages(30)
-				daemonClients := c.room.GetDaemonClients(targets)
-				log.Printf("spawn dispatch: targets=%v daemonClients=%d sender=%s", targets, len(daemonClients), env.Sender)
-				for name, dc := range daemonClients {
-					if dc == c {
-						log.Printf("spawn dispatch: skipping %s (self)", name)
-						continue
-					}
-					log.Printf("spawn dispatch: sending spawn event to %s", name)
-					spawnEvent := protocol.ServerEvent{
-						Event: "spawn",
-						Spawn: &protocol.SpawnReq{
-							Reason:       "directed_message",
-							Trigger:      &env,
-							Context:      ctx,
-							Participants: allParticipants,
-						},
-					}
-					dc.sendRaw(spawnEvent)
-				}
-			}
-
-			// Fire server-side hooks for non-daemon participants (e.g. host-mode spawned Claudes).
-			if hookTargets, hookParticipants := c.room.GetHookSpawnTargets(env); len(hookTargets) > 0 {
-				hookCtx := c.room.LatestMessages(30)
-				for name, hook := range hookTargets {
-					name, hook := name, hook // capture loop vars
-					log.Printf("spawn dispatch: hook for %s", name)
-					go hook(&protocol.SpawnReq{
-						Reason:       "directed_message",
-						Trigger:      &env,
-						Context:      hookCtx,
-						Participants: hookParticipants,
-					})
-				}
-			}
+			first = data
 		case data, ok := <-c.rawSend:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
 				return
 			}
-			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
-				return
-			}
+			first = data
 		case <-ticker.C:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
+			continue
+		}
+
+		c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+		if err := c.conn.WriteMessage(websocket.TextMessage, first); err != nil {
+			return
+		}
+		for n := len(c.send) + len(c.rawSend); n > 0; n-- {
+			var data []byte
+			select {
+			case data = <-c.send:
+			case data = <-c.rawSend:
+			default:
+			}
+			if data == nil {
+				break
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
 		}
 	}
 }
 
-// ServeWS upgrades an HTTP connection to WebSocket and registers the client.
-func ServeWS(hub *Hub, w http.ResponseWriter, r *http.Request, roomName, sender string) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+// ServeWS upgrades an HTTP connection to WebSocket and registers the client
+// in each of the named rooms.
+func ServeWS(hub *Hub, w http.ResponseWriter, r *http.Request, roomNames []string, sender string) {
+	filter, err := ParseFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	payloads, err := protocol.PayloadOptionsFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	profile := protocol.ProfileFromQuery(r.URL.Query())
+	if err := profile.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// A guest connects to their link's room only, as its name, and can't
+	// speak for Claudes or run a daemon.
+	guest, isGuest, err := hub.guest(r)
+	if isGuest {
+		q := r.URL.Query()
+		switch {
+		case err != nil:
+		case len(roomNames) != 1 || roomNames[0] != guest.Room:
+			err = fmt.Errorf("this share link is for room %q", guest.Room)
+		case sender != guest.sender():
+			err = fmt.Errorf("this guest link connects as %q", guest.sender())
+		case q.Get("owner") != "" || q.Get("claude_name") != "" || q.Has("agent") || q.Get("mode") == "daemon":
+			err = fmt.Errorf("a guest link can't connect Claudes or daemons")
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+	// Check the room tokens before claiming any names, so a caller without
+	// access can't take Claude names.
+	rooms := make([]*Room, 0, len(roomNames))
+	for _, name := range roomNames {
+		room, err := hub.OpenRoom(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if !isGuest && !room.Admits(roomTokens(r)) {
+			http.Error(w, fmt.Sprintf("room %q: %s", name, errPrivateRoom), http.StatusForbidden)
+			return
+		}
+		rooms = append(rooms, room)
+	}
+
+	// A Claude connecting for its owner, or a daemon whose Claudes speak
+	// as a picked name, claims that name.
+	claudeName := ""
+	if owner := r.URL.Query().Get("owner"); owner != "" {
+		if _, err := hub.NameClaude(owner, sender); err != nil {
+			http.Error(w, err.Error(), claudeNameStatus(err))
+			return
+		}
+	}
+	if name := r.URL.Query().Get("claude_name"); name != "" {
+		var err error
+		if claudeName, err = hub.NameClaude(sender, name); err != nil {
+			http.Error(w, err.Error(), claudeNameStatus(err))
+			return
+		}
+	}
+	agents := r.URL.Query()["agent"]
+	for _, name := range agents {
+		if name == sender || name == claudeName {
+			http.Error(w, fmt.Sprintf("agent %q: is the daemon's own name", name), http.StatusBadRequest)
+			return
+		}
+		if err := hub.NameAgent(sender, name); err != nil {
+			http.Error(w, err.Error(), claudeNameStatus(err))
+			return
+		}
+	}
+	// A DM's members may be Claudes, named just now.
+	for _, name := range roomNames {
+		if _, _, dm := protocol.DMMembers(name); dm {
+			if _, ok := hub.dmPeer(name, sender); !ok {
+				http.Error(w, fmt.Sprintf("room %q: %v", name, ErrNotDMMember), http.StatusForbidden)
+				return
+			}
+		}
+	}
+
+	// The hijacked connection writes its own response headers.
+	conn, err := upgrader.Upgrade(w, r, versionHeader())
 	if err != nil {
 		log.Printf("ws upgrade error: %v", err)
 		return
@@ -206,27 +396,50 @@ func ServeWS(hub *Hub, w http.ResponseWriter, r *http.Request, roomName, sender
 	if role == "" {
 		role = "user"
 	}
+	if isGuest {
+		role = "guest"
+	}
 
-	room := hub.GetOrCreateRoom(roomName)
 	client := &Client{
-		room:    room,
-		conn:    conn,
-		send:    make(chan protocol.Envelope, 256),
-		rawSend: make(chan []byte, 64),
-		sender:  sender,
-		mode:    mode,
-		role:    role,
+		conn:       conn,
+		send:       make(chan []byte, 256),
+		rawSend:    make(chan []byte, 64),
+		sender:     sender,
+		mode:       mode,
+		role:       role,
+		filter:     filter,
+		payloads:   payloads,
+		mentions:   r.URL.Query()["mention"],
+		takeover:   r.URL.Query().Get("takeover") == "1",
+		helpPolicy: r.URL.Query().Get("help"),
+		profile:    profile,
+		claudeName: claudeName,
+		agents:     agents,
+		rooms:      rooms,
+		hub:        hub,
+	}
+	if isGuest {
+		client.guest = &guest
+	}
+	if p := r.URL.Query().Get("priority"); p != "" {
+		client.priority, _ = strconv.Atoi(p)
 	}
-	room.RegisterClient(client)
+	for _, room := range client.rooms {
+		room.RegisterClient(client)
 
-	// Track all clients as participants.
-	room.TrackParticipant(sender, role, client)
+		// Track all clients as participants.
+		room.TrackParticipant(sender, role, client)
 
-	// Announce join.
-	room.AddMessage("system", protocol.TypeSystem, protocol.Payload{
-		Text: sender + " joined the room",
-	}, nil)
+		// Announce join.
+		room.AddMessage("system", protocol.TypeSystem, protocol.Payload{
+			Text: sender + " joined the room",
+		}, nil)
+	}
 
 	go client.writePump()
 	go client.readPump()
+
+	if mode == "daemon" {
+		hub.deliverQueued(client)
+	}
 }
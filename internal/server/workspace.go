@@ -0,0 +1,136 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+	"gopkg.in/yaml.v3"
+)
+
+// Workspace is an isolated tenant of a hosted server: its own rooms, files
+// and participants, so unrelated teams can use the same room names. Its API
+// is the server's API under /api/workspaces/{name}/, behind its token.
+// Workspaces have no Claude runner; participants spawn through daemons.
+type Workspace struct {
+	Name  string
+	Token string
+	Hub   *Hub
+	Files *FileStore
+
+	handler http.Handler
+}
+
+// workspaceName is what a workspace may be called; it is a URL segment and
+// a directory name.
+var workspaceName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// workspaceFile is the -workspaces file: a list of workspaces.
+type workspaceFile []struct {
+	Name     string `yaml:"name"`
+	Token    string `yaml:"token"`
+	TokenEnv string `yaml:"token_env"` // read the token from this variable instead
+}
+
+// LoadWorkspaces reads a workspace file, for example
+//
+//	# workspaces.yaml
+//	- name: acme
+//	  token: 6c1f...      # or token_env: ACME_TOKEN
+//	- name: globex
+//	  token_env: GLOBEX_TOKEN
+//
+// and creates each workspace with its files under dir/<name>.
+func LoadWorkspaces(path, dir string, maxHistory int, maxFileSize int64) ([]*Workspace, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file workspaceFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	seen := make(map[string]bool)
+	var out []*Workspace
+	for _, e := range file {
+		if !workspaceName.MatchString(e.Name) {
+			return nil, fmt.Errorf("%s: invalid workspace name %q (lowercase letters, digits, - and _)", path, e.Name)
+		}
+		if seen[e.Name] {
+			return nil, fmt.Errorf("%s: workspace %q listed twice", path, e.Name)
+		}
+		seen[e.Name] = true
+		token := e.Token
+		if e.TokenEnv != "" {
+			token = os.Getenv(e.TokenEnv)
+		}
+		if token == "" {
+			return nil, fmt.Errorf("%s: workspace %q has no token", path, e.Name)
+		}
+		files, err := NewFileStore(filepath.Join(dir, e.Name), maxFileSize)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, &Workspace{Name: e.Name, Token: token, Hub: NewHub(maxHistory), Files: files})
+	}
+	return out, nil
+}
+
+// authorized reports whether r carries the workspace's token: as a bearer
+// token, a basic-auth password, or, for WebSockets, the token query
+// parameter.
+func (ws *Workspace) authorized(r *http.Request) bool {
+	token := r.URL.Query().Get(protocol.TokenParam)
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	} else if _, pass, ok := r.BasicAuth(); ok {
+		token = pass
+	}
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(ws.Token)) == 1
+}
+
+// workspaceRouter serves /api/workspaces/{ws}/{rest...} from the named
+// workspace's own routes. rest may be rooms/... (the API's /api/rooms/...),
+// api/... or ws..., so a client pointed at /api/workspaces/{ws} as its
+// server URL works unchanged.
+type workspaceRouter map[string]*Workspace
+
+func (wr workspaceRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Split /api/workspaces/{ws}/{rest...} on the escaped path, so escaped
+	// room names survive.
+	name, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.EscapedPath(), "/api/workspaces/"), "/")
+	ws := wr[name]
+	if ws == nil {
+		writeError(w, http.StatusNotFound, "no such workspace")
+		return
+	}
+	if !ws.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="claudetalk `+ws.Name+`"`)
+		writeError(w, http.StatusUnauthorized, "this workspace needs its access token")
+		return
+	}
+
+	rest = "/" + rest
+	switch {
+	case rest == "/rooms" || strings.HasPrefix(rest, "/rooms/"):
+		rest = "/api" + rest
+	case strings.HasPrefix(rest, "/api/"), rest == "/ws", strings.HasPrefix(rest, "/ws/"):
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	path, err := url.PathUnescape(rest)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	r2 := r.Clone(r.Context())
+	r2.URL.Path, r2.URL.RawPath = path, rest
+	ws.handler.ServeHTTP(w, r2)
+}
@@ -0,0 +1,62 @@
+// Package service registers the ClaudeTalk daemon with the operating system's
+// service manager (systemd on Linux, launchd on macOS, Task Scheduler on
+// Windows) so it starts at login and survives reboots.
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Spec describes a command to run as a background service.
+type Spec struct {
+	Name    string   // service name, e.g. "claudetalk-daemon-myroom"
+	Exec    string   // absolute path to the executable
+	Args    []string // arguments to Exec
+	WorkDir string   // working directory for the process
+}
+
+// Name builds a service name for a room, keeping only characters every
+// service manager accepts.
+func Name(room string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(room) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune('-')
+		}
+	}
+	return "claudetalk-daemon-" + sb.String()
+}
+
+// run executes a service manager command, passing its output through.
+func run(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s %s: %w", name, strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+// quote quotes s for a command line if it contains spaces or quotes.
+func quote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// commandLine joins the executable and arguments into one quoted string.
+func (s Spec) commandLine() string {
+	parts := []string{quote(s.Exec)}
+	for _, a := range s.Args {
+		parts = append(parts, quote(a))
+	}
+	return strings.Join(parts, " ")
+}
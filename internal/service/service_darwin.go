@@ -0,0 +1,101 @@
+//go:build darwin
+
+package service
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// label is the launchd label for name.
+func label(name string) string { return "com.claudetalk." + name }
+
+// plistPath returns the LaunchAgent file for name.
+func plistPath(name string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", label(name)+".plist"), nil
+}
+
+// logPath is where launchd writes the daemon's output.
+func logPath(name string) string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, "Library", "Logs", name+".log")
+}
+
+// Install writes a LaunchAgent for spec and loads it.
+func Install(spec Spec) (string, error) {
+	path, err := plistPath(spec.Name)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+
+	esc := func(s string) string {
+		var sb strings.Builder
+		xml.EscapeText(&sb, []byte(s))
+		return sb.String()
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+`)
+	fmt.Fprintf(&sb, "\t<key>Label</key>\n\t<string>%s</string>\n", esc(label(spec.Name)))
+	sb.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+	for _, a := range append([]string{spec.Exec}, spec.Args...) {
+		fmt.Fprintf(&sb, "\t\t<string>%s</string>\n", esc(a))
+	}
+	sb.WriteString("\t</array>\n")
+	fmt.Fprintf(&sb, "\t<key>WorkingDirectory</key>\n\t<string>%s</string>\n", esc(spec.WorkDir))
+	sb.WriteString("\t<key>RunAtLoad</key>\n\t<true/>\n")
+	sb.WriteString("\t<key>KeepAlive</key>\n\t<dict>\n\t\t<key>SuccessfulExit</key>\n\t\t<false/>\n\t</dict>\n")
+	fmt.Fprintf(&sb, "\t<key>StandardOutPath</key>\n\t<string>%s</string>\n", esc(logPath(spec.Name)))
+	fmt.Fprintf(&sb, "\t<key>StandardErrorPath</key>\n\t<string>%s</string>\n", esc(logPath(spec.Name)))
+	sb.WriteString("</dict>\n</plist>\n")
+
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		return "", fmt.Errorf("write plist: %w", err)
+	}
+	if err := run("launchctl", "load", "-w", path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Uninstall unloads the LaunchAgent and removes its file.
+func Uninstall(name string) error {
+	path, err := plistPath(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("%s is not installed", name)
+	}
+	_ = run("launchctl", "unload", "-w", path)
+	return os.Remove(path)
+}
+
+// Start starts the installed service.
+func Start(name string) error { return run("launchctl", "start", label(name)) }
+
+// Stop stops the service without uninstalling it. launchd restarts it at
+// the next login.
+func Stop(name string) error { return run("launchctl", "stop", label(name)) }
+
+// Status prints the service manager's view of the service.
+func Status(name string) error { return run("launchctl", "list", label(name)) }
+
+// Notes returns hints shown after a successful install.
+func Notes(name string) string {
+	return "logs: tail -f " + logPath(name)
+}
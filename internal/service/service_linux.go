@@ -0,0 +1,87 @@
+//go:build linux
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// unitPath returns the systemd user unit file for name.
+func unitPath(name string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "systemd", "user", name+".service"), nil
+}
+
+// Install writes a systemd user unit for spec, then enables and starts it.
+func Install(spec Spec) (string, error) {
+	path, err := unitPath(spec.Name)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("[Unit]\n")
+	sb.WriteString("Description=ClaudeTalk daemon (" + spec.Name + ")\n")
+	sb.WriteString("After=network-online.target\n")
+	sb.WriteString("Wants=network-online.target\n\n")
+	sb.WriteString("[Service]\n")
+	sb.WriteString("ExecStart=" + spec.commandLine() + "\n")
+	sb.WriteString("WorkingDirectory=" + quote(spec.WorkDir) + "\n")
+	sb.WriteString("Restart=on-failure\n")
+	sb.WriteString("RestartSec=10\n\n")
+	sb.WriteString("[Install]\n")
+	sb.WriteString("WantedBy=default.target\n")
+
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		return "", fmt.Errorf("write unit: %w", err)
+	}
+	if err := run("systemctl", "--user", "daemon-reload"); err != nil {
+		return "", err
+	}
+	if err := run("systemctl", "--user", "enable", "--now", spec.Name); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Uninstall stops and disables the unit and removes its file.
+func Uninstall(name string) error {
+	path, err := unitPath(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("%s is not installed", name)
+	}
+	_ = run("systemctl", "--user", "disable", "--now", name)
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	return run("systemctl", "--user", "daemon-reload")
+}
+
+// Start starts the installed service.
+func Start(name string) error { return run("systemctl", "--user", "start", name) }
+
+// Stop stops the service without uninstalling it.
+func Stop(name string) error { return run("systemctl", "--user", "stop", name) }
+
+// Status prints the service manager's view of the service.
+func Status(name string) error {
+	return run("systemctl", "--user", "status", "--no-pager", name)
+}
+
+// Notes returns hints shown after a successful install.
+func Notes(name string) string {
+	return "logs: journalctl --user -u " + name + " -f\n" +
+		"user services stop at logout unless lingering is on: loginctl enable-linger $USER"
+}
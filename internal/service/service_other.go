@@ -0,0 +1,28 @@
+//go:build !linux && !darwin && !windows
+
+package service
+
+import (
+	"fmt"
+	"runtime"
+)
+
+var errUnsupported = fmt.Errorf("installing the daemon as a service is not supported on %s", runtime.GOOS)
+
+// Install is not supported on this platform.
+func Install(spec Spec) (string, error) { return "", errUnsupported }
+
+// Uninstall is not supported on this platform.
+func Uninstall(name string) error { return errUnsupported }
+
+// Start is not supported on this platform.
+func Start(name string) error { return errUnsupported }
+
+// Stop is not supported on this platform.
+func Stop(name string) error { return errUnsupported }
+
+// Status is not supported on this platform.
+func Status(name string) error { return errUnsupported }
+
+// Notes returns hints shown after a successful install.
+func Notes(name string) string { return "" }
@@ -0,0 +1,55 @@
+//go:build windows
+
+package service
+
+import "strings"
+
+// The daemon runs as a Task Scheduler task started at logon rather than a
+// Windows service: services must speak the service control protocol, which
+// the daemon doesn't.
+
+// winQuote quotes s for a cmd.exe command line. Backslashes are path
+// separators here, not escapes.
+func winQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"&|<>^") {
+		return s
+	}
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// Install registers a logon task for spec and starts it now.
+func Install(spec Spec) (string, error) {
+	// schtasks has no working-directory option, so change into it first.
+	parts := []string{winQuote(spec.Exec)}
+	for _, a := range spec.Args {
+		parts = append(parts, winQuote(a))
+	}
+	tr := `cmd /c cd /d ` + winQuote(spec.WorkDir) + ` && ` + strings.Join(parts, " ")
+	if err := run("schtasks", "/Create", "/F", "/SC", "ONLOGON", "/RL", "LIMITED", "/TN", spec.Name, "/TR", tr); err != nil {
+		return "", err
+	}
+	if err := run("schtasks", "/Run", "/TN", spec.Name); err != nil {
+		return "", err
+	}
+	return `Task Scheduler\` + spec.Name, nil
+}
+
+// Uninstall stops the task and deletes it.
+func Uninstall(name string) error {
+	_ = run("schtasks", "/End", "/TN", name)
+	return run("schtasks", "/Delete", "/F", "/TN", name)
+}
+
+// Start starts the installed task.
+func Start(name string) error { return run("schtasks", "/Run", "/TN", name) }
+
+// Stop stops the task without uninstalling it.
+func Stop(name string) error { return run("schtasks", "/End", "/TN", name) }
+
+// Status prints the service manager's view of the task.
+func Status(name string) error { return run("schtasks", "/Query", "/V", "/FO", "LIST", "/TN", name) }
+
+// Notes returns hints shown after a successful install.
+func Notes(name string) string {
+	return "the task starts at every logon; manage it in Task Scheduler as " + name
+}
@@ -0,0 +1,188 @@
+package sharedir
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// rule is one line of a .gitignore, or an exclude or include pattern, which
+// use the same syntax.
+type rule struct {
+	pattern  string
+	negate   bool // "!": un-ignore what an earlier rule ignored
+	dirOnly  bool // trailing "/": matches directories only
+	anchored bool // contains "/": matches the path from base, not just the name
+}
+
+// parseRule parses a .gitignore line. ok is false for blanks and comments.
+func parseRule(line string) (r rule, ok bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule{}, false
+	}
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	}
+	line = strings.TrimPrefix(line, `\`)
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		r.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return rule{}, false
+	}
+	r.pattern = line
+	return r, true
+}
+
+// matches reports whether rel, a slash-separated path, matches r.
+func (r rule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if !r.anchored {
+		ok, _ := path.Match(r.pattern, path.Base(rel))
+		return ok
+	}
+	return matchSegments(strings.Split(r.pattern, "/"), strings.Split(rel, "/"))
+}
+
+// matchSegments matches path segments against pattern segments, where "**"
+// matches any number of segments, including none.
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// ruleSet is the rules of one .gitignore, relative to the directory it is
+// in.
+type ruleSet struct {
+	base  string // slash-separated, relative to the directory walked; "" for itself
+	rules []rule
+}
+
+// ignored reports whether rel is ignored by s, or left as undecided: the
+// last matching rule decides.
+func (s ruleSet) ignored(rel string, isDir bool) (ignored, decided bool) {
+	if s.base != "" {
+		if !strings.HasPrefix(rel, s.base+"/") {
+			return false, false
+		}
+		rel = strings.TrimPrefix(rel, s.base+"/")
+	}
+	for i := len(s.rules) - 1; i >= 0; i-- {
+		if s.rules[i].matches(rel, isDir) {
+			return !s.rules[i].negate, true
+		}
+	}
+	return false, false
+}
+
+// readGitignore reads the .gitignore in dir, if there is one.
+func readGitignore(dir, base string) (ruleSet, bool) {
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return ruleSet{}, false
+	}
+	defer f.Close()
+	set := ruleSet{base: base}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if r, ok := parseRule(sc.Text()); ok {
+			set.rules = append(set.rules, r)
+		}
+	}
+	return set, len(set.rules) > 0
+}
+
+// parentGitignores returns the rules of the .gitignore files above dir, up
+// to the root of the git work tree it is in, outermost first. Their patterns
+// are rewritten relative to dir where that can be done; rules that only
+// apply elsewhere are dropped. Outside a work tree there are none.
+func parentGitignores(dir string) []ruleSet {
+	abs, err := filepath.Abs(dir)
+	if err != nil || isWorkTree(abs) {
+		return nil
+	}
+	var sets []ruleSet
+	for d := filepath.Dir(abs); ; d = filepath.Dir(d) {
+		if set, ok := readGitignore(d, ""); ok {
+			rel, _ := filepath.Rel(d, abs)
+			sets = append([]ruleSet{rebase(set, filepath.ToSlash(rel))}, sets...)
+		}
+		if isWorkTree(d) {
+			return sets
+		}
+		if filepath.Dir(d) == d {
+			return nil
+		}
+	}
+}
+
+// isWorkTree reports whether dir is the root of a git work tree.
+func isWorkTree(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// rebase turns rules written for a parent directory into rules for its
+// subdirectory sub. Unanchored rules apply at any depth, so they carry over;
+// anchored ones carry over if they start with sub's path or "**".
+func rebase(set ruleSet, sub string) ruleSet {
+	out := ruleSet{}
+	prefix := strings.Split(sub, "/")
+	for _, r := range set.rules {
+		if !r.anchored {
+			out.rules = append(out.rules, r)
+			continue
+		}
+		segs := strings.Split(r.pattern, "/")
+		if rest, ok := trimSegments(segs, prefix); ok && len(rest) > 0 {
+			r.pattern = strings.Join(rest, "/")
+			out.rules = append(out.rules, r)
+		}
+	}
+	return out
+}
+
+// trimSegments strips the path prefix from pattern segments, returning what
+// is left to match below it.
+func trimSegments(pattern, prefix []string) ([]string, bool) {
+	for len(prefix) > 0 {
+		if len(pattern) == 0 {
+			return nil, false
+		}
+		if pattern[0] == "**" {
+			return pattern, true
+		}
+		if ok, _ := path.Match(pattern[0], prefix[0]); !ok {
+			return nil, false
+		}
+		pattern, prefix = pattern[1:], prefix[1:]
+	}
+	return pattern, true
+}
@@ -0,0 +1,181 @@
+// Package sharedir picks the files of a directory to share with a room:
+// what .gitignore and the include and exclude patterns leave, without
+// version control internals, dependencies or likely secrets, and within
+// limits on how many files and bytes go at once.
+package sharedir
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Default limits on what one share may upload.
+const (
+	DefaultMaxFiles = 100
+	DefaultMaxBytes = 100 << 20
+)
+
+// DefaultExcludes are skipped unless Options.NoIgnore: version control
+// internals, installed dependencies, and files that usually hold secrets,
+// among them claudetalk's own config with its tokens.
+var DefaultExcludes = []string{
+	".git/", ".hg/", ".svn/",
+	"node_modules/", "vendor/", ".venv/", "__pycache__/",
+	".claudetalk", ".claudetalk-seq",
+	".env", ".env.*", ".envrc", ".netrc", ".npmrc", ".pypirc",
+	"*.pem", "*.key", "*.p12", "*.pfx", "*.keystore",
+	"id_rsa*", "id_dsa*", "id_ecdsa*", "id_ed25519*",
+	".ssh/", ".aws/", ".gnupg/",
+}
+
+// Options selects the files of a directory.
+type Options struct {
+	Recursive bool     // descend into subdirectories
+	Include   []string // if any, only files matching one of these
+	Exclude   []string // skip what matches these, as if in .gitignore
+	NoIgnore  bool     // don't skip what .gitignore or DefaultExcludes do
+	MaxFiles  int      // at most this many files; 0 = DefaultMaxFiles
+	MaxBytes  int64    // at most this many bytes in all; 0 = DefaultMaxBytes
+}
+
+// File is a file picked to share.
+type File struct {
+	Path string // as it is opened: the directory joined with Rel
+	Rel  string // slash-separated, relative to the directory
+	Size int64
+}
+
+// Select returns the files in dir opts picks, in lexical order. Patterns
+// follow .gitignore syntax: a pattern with a "/" matches the path from dir,
+// one without matches a name at any depth, "**" matches any number of
+// directories and a trailing "/" only directories.
+func Select(dir string, opts Options) ([]File, error) {
+	st, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !st.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	var ignore []ruleSet
+	if !opts.NoIgnore {
+		ignore = append(parentGitignores(dir), patterns(DefaultExcludes))
+	} else {
+		ignore = []ruleSet{patterns([]string{".git/"})}
+	}
+	exclude := patterns(opts.Exclude)
+	include := patterns(opts.Include)
+
+	var files []File
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == dir {
+			if !opts.NoIgnore {
+				if set, ok := readGitignore(p, ""); ok {
+					ignore = append(ignore, set)
+				}
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if !opts.Recursive || skipped(ignore, rel, true) || skipped([]ruleSet{exclude}, rel, true) {
+				return filepath.SkipDir
+			}
+			if !opts.NoIgnore {
+				if set, ok := readGitignore(p, rel); ok {
+					ignore = append(ignore, set)
+				}
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || skipped(ignore, rel, false) || skipped([]ruleSet{exclude}, rel, false) {
+			return nil
+		}
+		if len(include.rules) > 0 {
+			if in, _ := include.ignored(rel, false); !in {
+				return nil
+			}
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, File{Path: p, Rel: rel, Size: info.Size()})
+		return nil
+	})
+	return files, err
+}
+
+// patterns is a rule set of patterns given for the directory itself.
+func patterns(list []string) ruleSet {
+	var set ruleSet
+	for _, p := range list {
+		if r, ok := parseRule(p); ok {
+			set.rules = append(set.rules, r)
+		}
+	}
+	return set
+}
+
+// skipped reports whether rel is ignored by the rule sets, where a later,
+// more deeply nested set overrides an earlier one.
+func skipped(sets []ruleSet, rel string, isDir bool) bool {
+	ignored := false
+	for _, s := range sets {
+		if ig, ok := s.ignored(rel, isDir); ok {
+			ignored = ig
+		}
+	}
+	return ignored
+}
+
+// Check returns an error if files are more, or larger in all, than opts
+// allows.
+func Check(files []File, opts Options) error {
+	maxFiles, maxBytes := opts.MaxFiles, opts.MaxBytes
+	if maxFiles <= 0 {
+		maxFiles = DefaultMaxFiles
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	if len(files) > maxFiles {
+		return fmt.Errorf("%d files is over the limit of %d; narrow it down with include or exclude patterns, or raise the limit", len(files), maxFiles)
+	}
+	if total := TotalSize(files); total > maxBytes {
+		return fmt.Errorf("%d bytes in all is over the limit of %d; narrow it down with include or exclude patterns, or raise the limit", total, maxBytes)
+	}
+	return nil
+}
+
+// TotalSize is the size of files in all.
+func TotalSize(files []File) int64 {
+	var total int64
+	for _, f := range files {
+		total += f.Size
+	}
+	return total
+}
+
+// Describe lists files one per line with their sizes, then a total; it is
+// what a dry run shows.
+func Describe(files []File) string {
+	var sb strings.Builder
+	for _, f := range files {
+		fmt.Fprintf(&sb, "%s (%d bytes)\n", f.Rel, f.Size)
+	}
+	fmt.Fprintf(&sb, "%d files, %d bytes in all\n", len(files), TotalSize(files))
+	return sb.String()
+}
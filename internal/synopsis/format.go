@@ -0,0 +1,353 @@
+package synopsis
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/corvino/claudetalk/internal/e2e"
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+// Output formats supported by Render.
+const (
+	FormatMarkdown = "markdown"
+	FormatJSON     = "json"
+	FormatHTML     = "html"
+	FormatSlack    = "slack"
+)
+
+// Data is the view of a room handed to templates and structured formats.
+type Data struct {
+	Room         string              `json:"room"`
+	Generated    time.Time           `json:"generated"`
+	Participants []string            `json:"participants"`
+	First        time.Time           `json:"first"`
+	Last         time.Time           `json:"last"`
+	Count        int                 `json:"count"`
+	Messages     []protocol.Envelope `json:"messages"`
+}
+
+// NewData collects the summary fields for a set of messages.
+func NewData(room string, messages []protocol.Envelope) Data {
+	d := Data{
+		Room:      room,
+		Generated: time.Now(),
+		Count:     len(messages),
+		Messages:  messages,
+	}
+	senders := map[string]bool{}
+	for _, env := range messages {
+		if env.Type != protocol.TypeSystem && !senders[env.Sender] {
+			senders[env.Sender] = true
+			d.Participants = append(d.Participants, env.Sender)
+		}
+	}
+	sort.Strings(d.Participants)
+	d.First, d.Last = timeRange(messages)
+	return d
+}
+
+// timeRange returns the earliest and latest timestamps in messages, which
+// threading may have moved away from the ends.
+func timeRange(messages []protocol.Envelope) (first, last time.Time) {
+	for i, env := range messages {
+		if i == 0 || env.Timestamp.Before(first) {
+			first = env.Timestamp
+		}
+		if env.Timestamp.After(last) {
+			last = env.Timestamp
+		}
+	}
+	return first, last
+}
+
+// Render builds a digest in one of the built-in formats. Ephemeral messages
+// are left out, still-encrypted ones redacted and replies grouped under the
+// message that started their thread, here and in RenderTemplate.
+func Render(format, room string, messages []protocol.Envelope) (string, error) {
+	messages = threaded(digestable(messages))
+	switch format {
+	case "", FormatMarkdown, "md":
+		return Build(room, messages), nil
+	case FormatJSON:
+		data, err := json.MarshalIndent(NewData(room, messages), "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data) + "\n", nil
+	case FormatHTML:
+		return renderHTML(NewData(room, messages))
+	case FormatSlack:
+		return renderSlack(NewData(room, messages))
+	default:
+		return "", CheckFormat(format)
+	}
+}
+
+// CheckFormat returns an error if format isn't one Render builds.
+func CheckFormat(format string) error {
+	switch format {
+	case "", FormatMarkdown, "md", FormatJSON, FormatHTML, FormatSlack:
+		return nil
+	}
+	return fmt.Errorf("unknown format %q (want markdown, json, html, or slack)", format)
+}
+
+// RenderTemplateFile renders a user-supplied Go text/template file against Data.
+func RenderTemplateFile(path, room string, messages []protocol.Envelope) (string, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return RenderTemplate(string(src), room, messages)
+}
+
+// RenderTemplate renders a Go text/template against Data.
+func RenderTemplate(src, room string, messages []protocol.Envelope) (string, error) {
+	tmpl, err := template.New("synopsis").Funcs(templateFuncs).Parse(src)
+	if err != nil {
+		return "", fmt.Errorf("parse template: %w", err)
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, NewData(room, threaded(digestable(messages)))); err != nil {
+		return "", fmt.Errorf("execute template: %w", err)
+	}
+	return b.String(), nil
+}
+
+// digestable drops messages that were sent with a TTL and redacts the ones
+// whose end-to-end encryption the caller couldn't open.
+func digestable(messages []protocol.Envelope) []protocol.Envelope {
+	out := make([]protocol.Envelope, 0, len(messages))
+	for _, env := range messages {
+		if !protocol.Ephemeral(env.Metadata) {
+			e2e.Redact(&env)
+			out = append(out, env)
+		}
+	}
+	return out
+}
+
+// threaded reorders messages so each thread is contiguous: threads in the
+// order they started, each root followed by its replies in seq order. A reply
+// whose parent isn't in messages starts a thread of its own.
+func threaded(messages []protocol.Envelope) []protocol.Envelope {
+	rootOf := make(map[string]string, len(messages))
+	groups := make(map[string][]protocol.Envelope)
+	var roots []string
+	for _, env := range messages {
+		root, ok := rootOf[env.ReplyTo]
+		if env.ReplyTo == "" || !ok {
+			root = env.ID
+			roots = append(roots, root)
+		}
+		rootOf[env.ID] = root
+		groups[root] = append(groups[root], env)
+	}
+	out := make([]protocol.Envelope, 0, len(messages))
+	for _, root := range roots {
+		out = append(out, groups[root]...)
+	}
+	return out
+}
+
+// ContentType returns the MIME type for a format.
+func ContentType(format string) string {
+	switch format {
+	case FormatJSON, FormatSlack:
+		return "application/json"
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	default:
+		return "text/markdown; charset=utf-8"
+	}
+}
+
+// Extension returns the conventional file extension for a format.
+func Extension(format string) string {
+	switch format {
+	case FormatJSON, FormatSlack:
+		return ".json"
+	case FormatHTML:
+		return ".html"
+	default:
+		return ".md"
+	}
+}
+
+// replyMark prefixes a reply's line in the text formats.
+func replyMark(env protocol.Envelope) string {
+	if env.ReplyTo != "" {
+		return "↳ "
+	}
+	return ""
+}
+
+// templateFuncs are available to user templates.
+var templateFuncs = template.FuncMap{
+	"clock": func(t time.Time) string { return t.Local().Format("15:04:05") },
+	"date":  func(t time.Time) string { return t.Local().Format("2006-01-02 15:04") },
+	"join":  strings.Join,
+	"body":  body,
+}
+
+// body returns the primary content of a message regardless of type.
+func body(env protocol.Envelope) string {
+	switch env.Type {
+	case protocol.TypeCode:
+		return env.Payload.Code
+	case protocol.TypeDiff:
+		return env.Payload.Diff
+	case protocol.TypeJSON:
+		return env.Payload.IndentData()
+	default:
+		return env.Payload.Text
+	}
+}
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Funcs(htmltemplate.FuncMap{
+	"clock": templateFuncs["clock"],
+	"date":  templateFuncs["date"],
+	"join":  strings.Join,
+	"body":  body,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<title>ClaudeTalk Digest — {{.Room}}</title>
+<style>
+body { font-family: sans-serif; max-width: 960px; margin: 2em auto; }
+.msg { margin: 0.5em 0; }
+.sys { color: #888; font-style: italic; }
+.reply { margin-left: 2em; }
+pre { background: #f4f4f4; padding: 0.5em; overflow-x: auto; }
+</style>
+</head>
+<body>
+<h1>ClaudeTalk Digest — {{date .Generated}}</h1>
+<p><strong>Room</strong>: {{.Room}}<br>
+<strong>Participants</strong>: {{join .Participants ", "}}<br>
+{{if .Count}}<strong>Time range</strong>: {{clock .First}} — {{clock .Last}}<br>{{end}}
+<strong>Messages</strong>: {{.Count}}</p>
+<h2>Transcript</h2>
+{{range .Messages}}{{if eq .Type "system"}}<div class="msg sys">[{{clock .Timestamp}}] {{.Payload.Text}}</div>
+{{else}}<div class="msg{{if .ReplyTo}} reply{{end}}">{{if .ReplyTo}}↳ {{end}}[{{clock .Timestamp}}] <strong>{{.Sender}}</strong>{{with index .Metadata "to"}} → <strong>{{.}}</strong>{{end}}
+{{if or (eq .Type "code") (eq .Type "diff") (eq .Type "json")}}{{with .Payload.FilePath}} ({{.}}){{end}}:<pre>{{body .}}</pre>{{else}}: {{body .}}{{end}}</div>
+{{end}}{{end}}
+</body>
+</html>
+`))
+
+func renderHTML(d Data) (string, error) {
+	var b bytes.Buffer
+	if err := htmlTemplate.Execute(&b, d); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Slack's limits: the maximum text length of a section block, and the
+// maximum number of blocks in a message.
+const (
+	slackSectionLimit = 3000
+	slackBlockLimit   = 50
+)
+
+// renderSlack builds a Slack Block Kit payload suitable for chat.postMessage
+// or an incoming webhook. A digest too long for Slack's block limit ends with
+// a count of the messages left out.
+func renderSlack(d Data) (string, error) {
+	type text struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	type block struct {
+		Type     string `json:"type"`
+		Text     *text  `json:"text,omitempty"`
+		Elements []text `json:"elements,omitempty"`
+	}
+
+	blocks := []block{
+		{Type: "header", Text: &text{Type: "plain_text", Text: "ClaudeTalk Digest — " + d.Room}},
+		{Type: "context", Elements: []text{{
+			Type: "mrkdwn",
+			Text: fmt.Sprintf("*Participants*: %s · *Messages*: %d", strings.Join(d.Participants, ", "), d.Count),
+		}}},
+		{Type: "divider"},
+	}
+
+	var (
+		section   strings.Builder
+		inSection int // messages in section
+		left      = len(d.Messages)
+		full      bool // no room for another section but the last
+	)
+	flush := func() {
+		if section.Len() == 0 {
+			return
+		}
+		blocks = append(blocks, block{Type: "section", Text: &text{Type: "mrkdwn", Text: section.String()}})
+		section.Reset()
+		left -= inSection
+		inSection = 0
+		full = len(blocks) == slackBlockLimit-1
+	}
+	for _, env := range d.Messages {
+		var line string
+		if env.Type == protocol.TypeSystem {
+			line = fmt.Sprintf("_[%s] %s_\n", env.Timestamp.Local().Format("15:04:05"), env.Payload.Text)
+		} else {
+			content := body(env)
+			if env.Type == protocol.TypeCode || env.Type == protocol.TypeDiff || env.Type == protocol.TypeJSON {
+				content = "```" + content + "```"
+			}
+			line = fmt.Sprintf("%s[%s] *%s*: %s\n", replyMark(env), env.Timestamp.Local().Format("15:04:05"), env.Sender, content)
+		}
+		line = truncate(strings.TrimSuffix(line, "\n"), slackSectionLimit-1) + "\n"
+		if section.Len()+len(line) > slackSectionLimit {
+			// The last block is kept for the count of what's left out,
+			// unless this section is the last of the messages.
+			if full {
+				break
+			}
+			flush()
+		}
+		section.WriteString(line)
+		inSection++
+	}
+	if full && left > inSection {
+		blocks = append(blocks, block{Type: "context", Elements: []text{{
+			Type: "mrkdwn",
+			Text: fmt.Sprintf("… %d more", left),
+		}}})
+	} else {
+		flush()
+	}
+
+	data, err := json.MarshalIndent(map[string]any{"blocks": blocks}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// truncate shortens s to at most n bytes without splitting a rune, marking
+// the cut with "…".
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	n -= len("…")
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "…"
+}
@@ -0,0 +1,91 @@
+package synopsis
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/corvino/claudetalk/internal/protocol"
+)
+
+type slackPayload struct {
+	Blocks []struct {
+		Type string `json:"type"`
+		Text *struct {
+			Text string `json:"text"`
+		} `json:"text"`
+		Elements []struct {
+			Text string `json:"text"`
+		} `json:"elements"`
+	} `json:"blocks"`
+}
+
+func renderSlackOf(t *testing.T, texts ...string) slackPayload {
+	t.Helper()
+	var msgs []protocol.Envelope
+	for i, text := range texts {
+		msgs = append(msgs, protocol.Envelope{
+			ID: strconv.Itoa(i), Sender: "alice", Type: protocol.TypeText,
+			Timestamp: time.Unix(int64(i), 0), Payload: protocol.Payload{Text: text},
+		})
+	}
+	out, err := Render(FormatSlack, "r", msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p slackPayload
+	if err := json.Unmarshal([]byte(out), &p); err != nil {
+		t.Fatalf("not JSON: %v", err)
+	}
+	return p
+}
+
+func TestSlackLongMessage(t *testing.T) {
+	// Three-byte runes, so a byte cut would likely split one.
+	p := renderSlackOf(t, strings.Repeat("€", 2000))
+	section := p.Blocks[len(p.Blocks)-1].Text.Text
+	if !utf8.ValidString(section) {
+		t.Error("section isn't valid UTF-8")
+	}
+	if len(section) > slackSectionLimit || !strings.HasSuffix(section, "…\n") {
+		t.Errorf("section of %d bytes, ending %q; want at most %d, cut with …", len(section), section[len(section)-8:], slackSectionLimit)
+	}
+}
+
+func TestSlackBlockLimit(t *testing.T) {
+	// Each message fills most of a section, so each takes a block of its own.
+	long := strings.Repeat("x", 2000)
+	var texts []string
+	for range 60 {
+		texts = append(texts, long)
+	}
+	p := renderSlackOf(t, texts...)
+	if len(p.Blocks) != slackBlockLimit {
+		t.Fatalf("%d blocks, want %d", len(p.Blocks), slackBlockLimit)
+	}
+	// Three header blocks and 46 sections leave 14 messages out.
+	last := p.Blocks[len(p.Blocks)-1]
+	if last.Type != "context" || len(last.Elements) != 1 || last.Elements[0].Text != "… 14 more" {
+		t.Errorf("last block = %+v, want the count of 14 left out", last)
+	}
+
+	// A digest that just fits has no count.
+	p = renderSlackOf(t, texts[:47]...)
+	if len(p.Blocks) != slackBlockLimit || p.Blocks[len(p.Blocks)-1].Type != "section" {
+		t.Errorf("47 messages: %d blocks, the last a %s; want %d, the last a section", len(p.Blocks), p.Blocks[len(p.Blocks)-1].Type, slackBlockLimit)
+	}
+}
+
+func TestCheckFormat(t *testing.T) {
+	for _, f := range []string{"", "markdown", "md", "json", "html", "slack"} {
+		if err := CheckFormat(f); err != nil {
+			t.Errorf("CheckFormat(%q) = %v", f, err)
+		}
+	}
+	if err := CheckFormat("mardown"); err == nil {
+		t.Error("CheckFormat accepted a typo")
+	}
+}
@@ -31,9 +31,8 @@ func Build(room string, messages []protocol.Envelope) string {
 	fmt.Fprintf(&b, "**Participants**: %s\n", strings.Join(names, ", "))
 
 	if len(messages) > 0 {
-		first := messages[0].Timestamp.Local().Format("15:04:05")
-		last := messages[len(messages)-1].Timestamp.Local().Format("15:04:05")
-		fmt.Fprintf(&b, "**Time range**: %s — %s\n", first, last)
+		first, last := timeRange(messages)
+		fmt.Fprintf(&b, "**Time range**: %s — %s\n", first.Local().Format("15:04:05"), last.Local().Format("15:04:05"))
 	}
 	fmt.Fprintf(&b, "**Messages**: %d\n", len(messages))
 	fmt.Fprintf(&b, "\n---\n\n## Transcript\n\n")
@@ -53,6 +52,7 @@ func Build(room string, messages []protocol.Envelope) string {
 			sender += fmt.Sprintf(" → **%s**", to)
 		}
 
+		b.WriteString(replyMark(env))
 		switch env.Type {
 		case protocol.TypeText:
 			fmt.Fprintf(&b, "[%s] %s: %s", ts, sender, env.Payload.Text)
@@ -68,6 +68,12 @@ func Build(room string, messages []protocol.Envelope) string {
 				fmt.Fprintf(&b, " (%s)", env.Payload.FilePath)
 			}
 			fmt.Fprintf(&b, ":\n```diff\n%s\n```", env.Payload.Diff)
+		case protocol.TypeJSON:
+			fmt.Fprintf(&b, "[%s] %s shared data", ts, sender)
+			if env.Payload.Text != "" {
+				fmt.Fprintf(&b, " (%s)", env.Payload.Text)
+			}
+			fmt.Fprintf(&b, ":\n```json\n%s\n```", env.Payload.IndentData())
 		default:
 			fmt.Fprintf(&b, "[%s] %s: %s", ts, sender, env.Payload.Text)
 		}
@@ -0,0 +1,182 @@
+package tunnel
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// urlTimeout bounds how long a provider may take to print its public URL.
+const urlTimeout = 30 * time.Second
+
+// execProvider runs an external tunnel binary and scrapes its public URL from output.
+type execProvider struct {
+	name    string
+	bin     string
+	hint    string // install hint shown when bin isn't found
+	args    func(port int) []string
+	urlExpr *regexp.Regexp
+}
+
+// execSubdomainProvider is an execProvider whose binary can ask for a
+// subdomain.
+type execSubdomainProvider struct {
+	*execProvider
+	subdomainArgs func(port int, subdomain string) []string
+	subdomainOf   func(tunnelURL string) string
+}
+
+func init() {
+	register(&execSubdomainProvider{
+		execProvider: &execProvider{
+			name:    "localtunnel-npx",
+			bin:     "npx",
+			hint:    "install Node.js from https://nodejs.org",
+			args:    func(port int) []string { return []string{"localtunnel", "--port", fmt.Sprint(port)} },
+			urlExpr: regexp.MustCompile(`https://\S+`),
+		},
+		subdomainArgs: func(port int, subdomain string) []string {
+			return []string{"localtunnel", "--port", fmt.Sprint(port), "--subdomain", subdomain}
+		},
+		subdomainOf: firstLabel,
+	})
+	register(&execProvider{
+		name:    "cloudflared",
+		bin:     "cloudflared",
+		hint:    "see https://developers.cloudflare.com/cloudflare-one/connections/connect-networks/downloads/",
+		args:    func(port int) []string { return []string{"tunnel", "--url", fmt.Sprintf("http://localhost:%d", port)} },
+		urlExpr: regexp.MustCompile(`https://[a-z0-9-]+\.trycloudflare\.com`),
+	})
+	// ngrok's "subdomain" is a whole domain reserved in the ngrok account,
+	// such as myteam.ngrok.app.
+	register(&execSubdomainProvider{
+		execProvider: &execProvider{
+			name: "ngrok",
+			bin:  "ngrok",
+			hint: "see https://ngrok.com/download",
+			args: func(port int) []string {
+				return []string{"http", fmt.Sprint(port), "--log", "stdout", "--log-format", "logfmt"}
+			},
+			urlExpr: regexp.MustCompile(`url=(https://\S+)`),
+		},
+		subdomainArgs: func(port int, domain string) []string {
+			return []string{"http", fmt.Sprint(port), "--domain", domain, "--log", "stdout", "--log-format", "logfmt"}
+		},
+		subdomainOf: func(tunnelURL string) string {
+			u, err := url.Parse(tunnelURL)
+			if err != nil {
+				return ""
+			}
+			return u.Hostname()
+		},
+	})
+	register(&execProvider{
+		name:    "tailscale",
+		bin:     "tailscale",
+		hint:    "see https://tailscale.com/download and enable Funnel for your tailnet",
+		args:    func(port int) []string { return []string{"funnel", fmt.Sprint(port)} },
+		urlExpr: regexp.MustCompile(`https://[^\s/]+\.ts\.net/?`),
+	})
+}
+
+func (p *execProvider) Name() string { return p.name }
+
+func (p *execProvider) Start(ctx context.Context, port int) (Tunnel, error) {
+	return p.start(ctx, p.args(port))
+}
+
+func (p *execSubdomainProvider) StartSubdomain(ctx context.Context, port int, subdomain string) (Tunnel, error) {
+	if subdomain == "" {
+		return p.Start(ctx, port)
+	}
+	return p.start(ctx, p.subdomainArgs(port, subdomain))
+}
+
+func (p *execSubdomainProvider) SubdomainOf(tunnelURL string) string { return p.subdomainOf(tunnelURL) }
+
+// start runs the binary with args and waits for its public URL.
+func (p *execProvider) start(ctx context.Context, args []string) (Tunnel, error) {
+	path, err := exec.LookPath(p.bin)
+	if err != nil {
+		return nil, fmt.Errorf("%s not found — %s", p.bin, p.hint)
+	}
+
+	cmd := exec.Command(path, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("pipe stdout: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("pipe stderr: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start %s: %w", p.name, err)
+	}
+
+	t := &execTunnel{cmd: cmd, done: make(chan struct{})}
+	urlCh := make(chan string, 1)
+
+	// Some providers log the URL to stdout, others to stderr; scan both.
+	var scanners sync.WaitGroup
+	scan := func(r io.Reader) {
+		defer scanners.Done()
+		s := bufio.NewScanner(r)
+		for s.Scan() {
+			m := p.urlExpr.FindStringSubmatch(s.Text())
+			if m == nil {
+				continue
+			}
+			u := m[len(m)-1]
+			select {
+			case urlCh <- u:
+			default:
+			}
+		}
+	}
+	scanners.Add(2)
+	go scan(stdout)
+	go scan(stderr)
+	go func() {
+		scanners.Wait()
+		cmd.Wait()
+		close(t.done)
+	}()
+
+	select {
+	case u := <-urlCh:
+		t.url = u
+		return t, nil
+	case <-t.done:
+		return nil, fmt.Errorf("%s exited without providing a URL", p.name)
+	case <-time.After(urlTimeout):
+		t.Close()
+		return nil, fmt.Errorf("timed out waiting for %s URL", p.name)
+	case <-ctx.Done():
+		t.Close()
+		return nil, ctx.Err()
+	}
+}
+
+// execTunnel is a tunnel backed by a child process.
+type execTunnel struct {
+	cmd  *exec.Cmd
+	url  string
+	done chan struct{}
+}
+
+func (t *execTunnel) URL() string           { return t.url }
+func (t *execTunnel) Done() <-chan struct{} { return t.done }
+
+func (t *execTunnel) Close() error {
+	if t.cmd.Process != nil {
+		return t.cmd.Process.Kill()
+	}
+	return nil
+}
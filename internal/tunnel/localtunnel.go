@@ -0,0 +1,214 @@
+package tunnel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultLocaltunnelHost is the public localtunnel server. Override with
+// CLAUDETALK_TUNNEL_HOST to use a self-hosted one.
+const defaultLocaltunnelHost = "https://localtunnel.me"
+
+// maxDialFailures is how many consecutive failed reconnects to the tunnel
+// server are tolerated before the tunnel is considered dead.
+const maxDialFailures = 5
+
+// localtunnelProvider speaks the localtunnel protocol natively, so hosting
+// needs nothing but the claudetalk binary.
+type localtunnelProvider struct{}
+
+func init() {
+	register(localtunnelProvider{})
+}
+
+func (localtunnelProvider) Name() string { return "localtunnel" }
+
+// ltInfo is the localtunnel server's response to a new-tunnel request.
+type ltInfo struct {
+	ID           string `json:"id"`
+	IP           string `json:"ip"`
+	Port         int    `json:"port"`
+	MaxConnCount int    `json:"max_conn_count"`
+	URL          string `json:"url"`
+	Message      string `json:"message"`
+}
+
+func (p localtunnelProvider) Start(ctx context.Context, port int) (Tunnel, error) {
+	return p.StartSubdomain(ctx, port, "")
+}
+
+func (localtunnelProvider) SubdomainOf(tunnelURL string) string { return firstLabel(tunnelURL) }
+
+// StartSubdomain asks the localtunnel server for subdomain, or any
+// subdomain for "".
+func (localtunnelProvider) StartSubdomain(ctx context.Context, port int, subdomain string) (Tunnel, error) {
+	host := os.Getenv("CLAUDETALK_TUNNEL_HOST")
+	if host == "" {
+		host = defaultLocaltunnelHost
+	}
+	hostURL, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("parse tunnel host: %w", err)
+	}
+
+	path := "/?new"
+	if subdomain != "" {
+		path = "/" + url.PathEscape(subdomain)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(host, "/")+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: urlTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request tunnel: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("tunnel server returned %d: %s", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	var info ltInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("decode tunnel info: %w", err)
+	}
+	if info.URL == "" || info.Port == 0 {
+		return nil, fmt.Errorf("tunnel server refused: %s", info.Message)
+	}
+
+	remoteHost := info.IP
+	if remoteHost == "" {
+		remoteHost = hostURL.Hostname()
+	}
+	conns := info.MaxConnCount
+	if conns <= 0 {
+		conns = 1
+	}
+
+	t := &ltTunnel{
+		url:    info.URL,
+		remote: net.JoinHostPort(remoteHost, fmt.Sprint(info.Port)),
+		local:  fmt.Sprintf("localhost:%d", port),
+		done:   make(chan struct{}),
+		stop:   make(chan struct{}),
+	}
+	for i := 0; i < conns; i++ {
+		go t.worker()
+	}
+	return t, nil
+}
+
+// ltTunnel keeps a pool of TCP connections open to the tunnel server, each
+// proxying one request at a time to the local port.
+type ltTunnel struct {
+	url    string
+	remote string
+	local  string
+
+	done     chan struct{} // closed when the tunnel dies
+	stop     chan struct{} // closed by Close
+	doneOnce sync.Once
+	stopOnce sync.Once
+}
+
+func (t *ltTunnel) URL() string           { return t.url }
+func (t *ltTunnel) Done() <-chan struct{} { return t.done }
+
+func (t *ltTunnel) Close() error {
+	t.stopOnce.Do(func() { close(t.stop) })
+	t.doneOnce.Do(func() { close(t.done) })
+	return nil
+}
+
+// worker repeatedly opens a connection to the tunnel server and pipes it to
+// the local server. Too many consecutive dial failures mark the tunnel dead.
+func (t *ltTunnel) worker() {
+	failures := 0
+	backoff := time.Second
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-t.done:
+			return
+		default:
+		}
+
+		remote, err := net.DialTimeout("tcp", t.remote, 10*time.Second)
+		if err != nil {
+			failures++
+			if failures >= maxDialFailures {
+				log.Printf("tunnel: lost connection to %s: %v", t.remote, err)
+				t.doneOnce.Do(func() { close(t.done) })
+				return
+			}
+			select {
+			case <-time.After(backoff):
+			case <-t.stop:
+				return
+			}
+			backoff *= 2
+			continue
+		}
+		failures = 0
+		backoff = time.Second
+		t.proxy(remote)
+	}
+}
+
+// proxy waits for a request on remote, then connects to the local server and
+// copies in both directions until either side closes. The local dial is
+// deferred so idle pool connections don't trip the server's read timeout.
+func (t *ltTunnel) proxy(remote net.Conn) {
+	defer remote.Close()
+
+	// Unblock reads when the tunnel is closed.
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-t.stop:
+			remote.Close()
+		case <-finished:
+		}
+	}()
+
+	buf := make([]byte, 32*1024)
+	n, err := remote.Read(buf)
+	if err != nil {
+		return
+	}
+
+	local, err := net.DialTimeout("tcp", t.local, 5*time.Second)
+	if err != nil {
+		log.Printf("tunnel: dial local %s: %v", t.local, err)
+		return
+	}
+	defer local.Close()
+	if _, err := local.Write(buf[:n]); err != nil {
+		return
+	}
+
+	copied := make(chan struct{}, 2)
+	go func() {
+		io.Copy(local, remote)
+		copied <- struct{}{}
+	}()
+	go func() {
+		io.Copy(remote, local)
+		copied <- struct{}{}
+	}()
+
+	<-copied
+}
@@ -0,0 +1,138 @@
+// Package tunnel exposes a local port on a public URL through one of several
+// tunnel providers (localtunnel, cloudflared, ngrok, tailscale funnel).
+package tunnel
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Tunnel is a running public tunnel.
+type Tunnel interface {
+	// URL is the public URL that forwards to the local port.
+	URL() string
+	// Done is closed when the tunnel stops working.
+	Done() <-chan struct{}
+	// Close tears the tunnel down.
+	Close() error
+}
+
+// Provider starts tunnels of one kind.
+type Provider interface {
+	Name() string
+	Start(ctx context.Context, port int) (Tunnel, error)
+}
+
+// Subdomainer is a Provider that can ask for a particular subdomain, so a
+// tunnel started again later keeps its URL while nobody else took it.
+type Subdomainer interface {
+	Provider
+	StartSubdomain(ctx context.Context, port int, subdomain string) (Tunnel, error)
+	// SubdomainOf is what to ask StartSubdomain for to get tunnelURL again.
+	SubdomainOf(tunnelURL string) string
+}
+
+// StartStable starts a tunnel with p, asking for subdomain first if p is a
+// Subdomainer. When that fails, the tunnel gets whatever URL p gives.
+func StartStable(ctx context.Context, p Provider, port int, subdomain string) (Tunnel, error) {
+	if s, ok := p.(Subdomainer); ok && subdomain != "" {
+		tun, err := s.StartSubdomain(ctx, port, subdomain)
+		if err == nil {
+			return tun, nil
+		}
+		log.Printf("tunnel: subdomain %q unavailable: %v", subdomain, err)
+	}
+	return p.Start(ctx, port)
+}
+
+// SubdomainOf is what to ask p for to get tunnelURL again, or "" if p can't
+// ask for one.
+func SubdomainOf(p Provider, tunnelURL string) string {
+	if s, ok := p.(Subdomainer); ok {
+		return s.SubdomainOf(tunnelURL)
+	}
+	return ""
+}
+
+// firstLabel is the first label of a URL's host: "myteam" for
+// https://myteam.loca.lt.
+func firstLabel(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	label, _, _ := strings.Cut(u.Hostname(), ".")
+	return label
+}
+
+var providers = map[string]Provider{}
+
+func register(p Provider) {
+	providers[p.Name()] = p
+}
+
+// Get returns the named provider.
+func Get(name string) (Provider, error) {
+	p, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown tunnel provider %q (available: %s)", name, strings.Join(Names(), ", "))
+	}
+	return p, nil
+}
+
+// Names lists the registered providers.
+func Names() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LANAddress returns an http URL for port on the first non-loopback IPv4
+// address, falling back to localhost.
+func LANAddress(port int) string {
+	addrs, err := net.InterfaceAddrs()
+	if err == nil {
+		for _, a := range addrs {
+			ipNet, ok := a.(*net.IPNet)
+			if !ok || ipNet.IP.IsLoopback() {
+				continue
+			}
+			if ip4 := ipNet.IP.To4(); ip4 != nil {
+				return fmt.Sprintf("http://%s:%d", ip4, port)
+			}
+		}
+	}
+	return fmt.Sprintf("http://localhost:%d", port)
+}
+
+// Probe checks that the public URL reaches a ClaudeTalk server by fetching
+// its health endpoint through the tunnel.
+func Probe(publicURL string) error {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(publicURL, "/")+"/api/health", nil)
+	if err != nil {
+		return err
+	}
+	// Skip localtunnel's click-through reminder page.
+	req.Header.Set("Bypass-Tunnel-Reminder", "true")
+	resp, err := probeClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+var probeClient = &http.Client{Timeout: 10 * time.Second}
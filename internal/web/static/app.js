@@ -7,7 +7,13 @@
     let room = '';
     let sender = '';
     let seenSeqs = new Set();
-    let claudeActive = false;
+    let spawnChoices = null; // models, presets and work dirs from spawn/options; null = no runner
+    let roomKey = null; // AES-GCM key when the room has a passphrase; see e2e section
+    let inbox = Promise.resolve(); // keeps WebSocket messages in order while they decrypt
+    let replyTo = null; // {id, seq} of the message the next send replies to
+    const threadRoots = new Map(); // message id → id of the message that started its thread
+    const filters = { sender: '', type: '', conv: '' }; // what the message list shows; '' = all
+    const senders = new Set(); // everyone who has sent a message shown, for the sender filter
 
     // --- Color palette (matches Go CLI) ---
     const senderColorPalette = [
@@ -35,34 +41,245 @@
     const joinForm = document.getElementById('join-form');
     const roomInput = document.getElementById('room-input');
     const nameInput = document.getElementById('name-input');
+    const passphraseInput = document.getElementById('passphrase-input');
     const roomTitle = document.getElementById('room-title');
     const messagesDiv = document.getElementById('messages');
     const msgInput = document.getElementById('msg-input');
     const sendBtn = document.getElementById('send-btn');
     const claudeInput = document.getElementById('claude-input');
     const claudeBtn = document.getElementById('claude-btn');
-    const stopBtn = document.getElementById('stop-btn');
+    const claudeSessions = document.getElementById('claude-sessions');
+    const claudeOptions = document.getElementById('claude-options');
+    const claudePreset = document.getElementById('claude-preset');
+    const claudeModel = document.getElementById('claude-model');
+    const claudeWorkDir = document.getElementById('claude-workdir');
+    const claudeWorkDirs = document.getElementById('claude-workdirs');
     const synopsisBtn = document.getElementById('synopsis-btn');
     const leaveBtn = document.getElementById('leave-btn');
+    const shareBtn = document.getElementById('share-btn');
     const participantList = document.getElementById('participant-list');
     const fileList = document.getElementById('file-list');
+    const filesBtn = document.getElementById('files-btn');
+    const filesPanel = document.getElementById('files-panel');
+    const filesClose = document.getElementById('files-close');
+    const dropZone = document.getElementById('drop-zone');
+    const fileInput = document.getElementById('file-input');
+    const uploadList = document.getElementById('upload-list');
+    const replyBar = document.getElementById('reply-bar');
+    const replyTarget = document.getElementById('reply-target');
+    const replyCancel = document.getElementById('reply-cancel');
+    const threadList = document.getElementById('thread-list');
+    const filterSender = document.getElementById('filter-sender');
+    const filterType = document.getElementById('filter-type');
+    const collapseWhispers = document.getElementById('collapse-whispers');
+    const threadFilter = document.getElementById('thread-filter');
+    const threadFilterName = document.getElementById('thread-filter-name');
+    const threadFilterClear = document.getElementById('thread-filter-clear');
+
+    // --- Workspaces ---
+    // /w/<name>/ is a token-protected workspace: its API is under
+    // /api/workspaces/<name>. The token comes from ?token=, is remembered per
+    // workspace, and is asked for when missing or rejected.
+    const workspaceMatch = window.location.pathname.match(/^\/w\/([^/]+)\//);
+    const workspace = workspaceMatch ? decodeURIComponent(workspaceMatch[1]) : '';
+    let workspaceToken = '';
+    if (workspace) {
+        const tokenKey = 'claudetalk-token:' + workspace;
+        const params = new URLSearchParams(window.location.search);
+        workspaceToken = params.get('token') || localStorage.getItem(tokenKey) || '';
+        if (!workspaceToken) {
+            workspaceToken = (prompt('Access token for workspace "' + workspace + '":') || '').trim();
+        }
+        localStorage.setItem(tokenKey, workspaceToken);
+        if (params.has('token')) {
+            // Keep the token out of the address bar and history.
+            params.delete('token');
+            const query = params.toString();
+            history.replaceState(null, '', window.location.pathname + (query ? '?' + query : ''));
+        }
+        document.title = workspace + ' — ' + document.title;
+    }
+
+    // --- Guest links ---
+    // /join/<token> is a share link: a guest sees one room, read-only or
+    // posting as the link's name, with the token standing in for any other.
+    const shareMatch = window.location.pathname.match(/^\/join\/([^/]+)$/);
+    const shareToken = shareMatch ? decodeURIComponent(shareMatch[1]) : '';
 
     // --- API helpers ---
     function apiBase() {
+        if (workspace) {
+            return window.location.origin + '/api/workspaces/' + encodeURIComponent(workspace);
+        }
         return window.location.origin;
     }
 
+    // Private rooms' access tokens come from invites and are remembered per
+    // room (and workspace).
+    function roomTokenKey(r) {
+        return 'claudetalk-room-token:' + workspace + ':' + r;
+    }
+    function roomToken() {
+        return room ? localStorage.getItem(roomTokenKey(room)) || '' : '';
+    }
+
+    // authHeaders are the headers carrying the workspace and room tokens.
+    function authHeaders() {
+        const headers = {};
+        if (workspaceToken) headers['Authorization'] = 'Bearer ' + workspaceToken;
+        if (roomToken()) headers['X-Claudetalk-Room-Token'] = roomToken();
+        if (shareToken) headers['X-Claudetalk-Share'] = shareToken;
+        return headers;
+    }
+
     async function apiFetch(path, opts) {
+        opts = opts || {};
+        opts.headers = Object.assign({}, opts.headers, authHeaders());
         const resp = await fetch(apiBase() + path, opts);
+        if (resp.status === 401 && workspace) {
+            // Wrong or revoked token: forget it and ask again.
+            localStorage.removeItem('claudetalk-token:' + workspace);
+            window.location.reload();
+        }
         return resp;
     }
 
+    // tokenQuery carries the workspace and room tokens on URLs the browser
+    // fetches itself (WebSockets, download links), where it can't set a header.
+    function tokenQuery(sep) {
+        const params = new URLSearchParams();
+        if (workspaceToken) params.set('token', workspaceToken);
+        if (roomToken()) params.set('room_token', roomToken());
+        if (shareToken) params.set('share', shareToken);
+        const query = params.toString();
+        return query ? sep + query : '';
+    }
+
+    // Prefill the room from a deep link (?room=name) or an invite
+    // (?invite=code, from "claudetalk rooms invite").
+    const linkParams = new URLSearchParams(window.location.search);
+    const linkedRoom = linkParams.get('room');
+    if (linkedRoom) {
+        roomInput.value = linkedRoom;
+        nameInput.focus();
+    } else if (linkParams.get('invite')) {
+        apiFetch('/api/invites/' + encodeURIComponent(linkParams.get('invite'))).then(async function (resp) {
+            if (!resp.ok) {
+                alert('This invite is unknown or has expired.');
+                return;
+            }
+            const inv = await resp.json();
+            if (inv.token) localStorage.setItem(roomTokenKey(inv.room), inv.token);
+            roomInput.value = inv.room;
+            nameInput.focus();
+        });
+    }
+
+    if (shareToken) {
+        fetch(apiBase() + '/api/share/' + encodeURIComponent(shareToken)).then(async function (resp) {
+            const data = await resp.json().catch(() => ({}));
+            if (!resp.ok) {
+                joinForm.classList.add('hidden');
+                const msg = document.createElement('p');
+                msg.className = 'join-error';
+                msg.textContent = (data.error || 'This link doesn\'t work') + '. Ask whoever sent it for a new one.';
+                joinForm.after(msg);
+                return;
+            }
+            document.body.classList.add('guest');
+            if (data.access !== 'post') document.body.classList.add('read-only');
+            document.title = '#' + data.room + ' — ' + document.title;
+            joinRoom(data.room, data.name || 'guest');
+        });
+    }
+
+    // ensureRoom checks that the user may enter room: that it isn't private
+    // without its token, and that it exists on a server that only has rooms
+    // created explicitly, offering to create it, so a typo doesn't land the
+    // user in an empty room of their own. room is already set.
+    async function ensureRoom(name) {
+        if (shareToken) return true; // the share link was checked already
+        const check = await apiFetch('/api/rooms/' + encodeURIComponent(name) + '/settings');
+        if (check.status === 403) {
+            alert('Room "' + name + '" is private. Ask a member for an invite link.');
+            return false;
+        }
+        if (check.status !== 404) return true;
+        const health = await (await apiFetch('/api/health')).json();
+        if (health.room_creation !== 'explicit') return true;
+        if (!confirm('Room "' + name + '" doesn\'t exist on this server. Create it?')) return false;
+        const resp = await apiFetch('/api/rooms', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ name: name }),
+        });
+        if (!resp.ok) {
+            alert('Could not create room: ' + ((await resp.json()).error || resp.status));
+            return false;
+        }
+        return true;
+    }
+
+    // --- End-to-end encryption ---
+    // Mirrors internal/e2e: PBKDF2-SHA256 room key, AES-256-GCM, payload JSON
+    // sealed as base64(iv || ciphertext) in payload.text with metadata e2e=v1.
+    const e2eIterations = 100000;
+    const e2ePlaceholder = '[encrypted message \u2014 set the room passphrase to read it]';
+
+    async function deriveRoomKey(passphrase, roomName) {
+        const enc = new TextEncoder();
+        const base = await crypto.subtle.importKey('raw', enc.encode(passphrase), 'PBKDF2', false, ['deriveKey']);
+        return crypto.subtle.deriveKey(
+            { name: 'PBKDF2', salt: enc.encode('claudetalk:' + roomName), iterations: e2eIterations, hash: 'SHA-256' },
+            base, { name: 'AES-GCM', length: 256 }, false, ['encrypt', 'decrypt']);
+    }
+
+    // sealRequest encrypts a send request's payload when the room has a key.
+    async function sealRequest(req) {
+        if (!roomKey) return req;
+        const iv = crypto.getRandomValues(new Uint8Array(12));
+        const plain = new TextEncoder().encode(JSON.stringify(req.payload));
+        const ct = new Uint8Array(await crypto.subtle.encrypt({ name: 'AES-GCM', iv: iv }, roomKey, plain));
+        const sealed = new Uint8Array(iv.length + ct.length);
+        sealed.set(iv);
+        sealed.set(ct, iv.length);
+        req.payload = { text: btoa(String.fromCharCode.apply(null, sealed)) };
+        req.metadata = Object.assign({}, req.metadata, { e2e: 'v1' });
+        return req;
+    }
+
+    // openEnvelope decrypts a sealed envelope in place. Without the right key
+    // the message shows a placeholder rather than ciphertext.
+    async function openEnvelope(env) {
+        if (!env.metadata || !env.metadata.e2e) return env;
+        try {
+            if (!roomKey || env.metadata.e2e !== 'v1') throw new Error('no room key');
+            const sealed = Uint8Array.from(atob(env.payload.text), c => c.charCodeAt(0));
+            const plain = await crypto.subtle.decrypt({ name: 'AES-GCM', iv: sealed.slice(0, 12) }, roomKey, sealed.slice(12));
+            env.payload = JSON.parse(new TextDecoder().decode(plain));
+        } catch (e) {
+            env.type = 'text';
+            env.payload = { text: e2ePlaceholder };
+        }
+        return env;
+    }
+
     // --- Join ---
-    joinForm.addEventListener('submit', function (e) {
+    joinForm.addEventListener('submit', async function (e) {
         e.preventDefault();
         room = roomInput.value.trim();
         sender = nameInput.value.trim();
         if (!room || !sender) return;
+        if (!(await ensureRoom(room))) return;
+        const passphrase = passphraseInput.value;
+        passphraseInput.value = '';
+        if (passphrase) {
+            if (!window.crypto || !crypto.subtle) {
+                alert('End-to-end encryption needs https or localhost.');
+                return;
+            }
+            roomKey = await deriveRoomKey(passphrase, room);
+        }
         joinRoom(room, sender);
     });
 
@@ -76,7 +293,7 @@
             if (resp.ok) {
                 const data = await resp.json();
                 for (const env of data.messages || []) {
-                    renderMessage(env);
+                    renderMessage(await openEnvelope(env));
                 }
             }
         } catch (e) {
@@ -92,17 +309,21 @@
         roomTitle.textContent = '#' + room;
         msgInput.focus();
 
-        // Start polling participants/files
+        // Start polling participants/threads/files/sessions
+        if (!shareToken) loadSpawnOptions();
         refreshParticipants();
+        refreshThreads();
         refreshFiles();
+        refreshSessions();
         setInterval(refreshParticipants, 10000);
+        setInterval(refreshThreads, 10000);
         setInterval(refreshFiles, 15000);
+        setInterval(refreshSessions, 5000);
     }
 
     // --- WebSocket ---
     function connectWS() {
-        const proto = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-        const url = proto + '//' + window.location.host + '/ws/' + encodeURIComponent(room) + '?sender=' + encodeURIComponent(sender);
+        const url = apiBase().replace(/^http/, 'ws') + '/ws/' + encodeURIComponent(room) + '?sender=' + encodeURIComponent(sender) + tokenQuery('&');
         ws = new WebSocket(url);
 
         ws.onopen = function () {
@@ -114,7 +335,11 @@
         ws.onmessage = function (evt) {
             try {
                 const env = JSON.parse(evt.data);
-                onMessage(env);
+                if (env.event === 'message_expired') {
+                    redactMessage(env.message);
+                    return;
+                }
+                inbox = inbox.then(() => openEnvelope(env)).then(onMessage);
             } catch (e) {
                 console.error('Parse error:', e);
             }
@@ -138,7 +363,7 @@
             if (resp.ok) {
                 const data = await resp.json();
                 for (const env of data.messages || []) {
-                    renderMessage(env);
+                    renderMessage(await openEnvelope(env));
                 }
             }
         } catch (e) { /* ignore */ }
@@ -154,6 +379,9 @@
         }
         renderMessage(env);
         refreshParticipants();
+        if (env.metadata && env.metadata.conv_id) refreshThreads();
+        if (env.type === 'file') refreshFiles();
+        if (env.type === 'system') refreshSessions();
     }
 
     // --- Render messages ---
@@ -164,15 +392,21 @@
         const el = document.createElement('div');
         el.className = 'msg';
         if (env.seq) el.dataset.seq = env.seq;
+        if (env.id) el.dataset.id = env.id;
+        el.dataset.sender = env.sender || '';
+        el.dataset.type = env.type || 'text';
+        if (env.metadata && env.metadata.conv_id) el.dataset.conv = env.metadata.conv_id;
 
         if (env.type === 'system') {
             el.className = 'msg msg-system';
             const ts = formatTime(env.timestamp);
             el.textContent = '[' + ts + '] ' + (env.payload && env.payload.text || '');
             messagesDiv.appendChild(el);
+            applyFilters();
             scrollToBottom();
             return;
         }
+        noteSender(env.sender);
 
         const ts = formatTime(env.timestamp);
         const color = senderColor(env.sender);
@@ -185,39 +419,224 @@
         if (isBot) {
             html += '<span class="badge badge-bot">BOT</span>';
         }
+        if (env.metadata && env.metadata.e2e) {
+            html += '<span class="badge badge-e2e" title="end-to-end encrypted">E2E</span>';
+        }
+        if (env.metadata && env.metadata.expires_at) {
+            html += '<span class="badge badge-ephemeral" title="expires ' + escHtml(new Date(env.metadata.expires_at).toLocaleTimeString()) + '">EPHEMERAL</span>';
+        }
+        if (mentionsMe(env)) {
+            el.classList.add('msg-mention');
+        }
+        const priority = env.metadata && env.metadata.priority;
+        if (priority === 'urgent') {
+            el.classList.add('msg-urgent');
+            html += '<span class="badge badge-urgent">URGENT</span>';
+        } else if (priority === 'low') {
+            el.classList.add('msg-low');
+        }
 
         if (env.metadata && env.metadata.private === 'true') {
             el.classList.add('msg-whisper');
-            html += ' <span class="directed whisper-label">&#x1F512; whisper &rarr; ' + escHtml(env.metadata.to) + '</span>';
+            if (collapseWhispers.checked) el.classList.add('collapsed');
+            html += ' <span class="directed whisper-label" title="Show or hide">&#x1F512; whisper &rarr; ' + escHtml(env.metadata.to) + '</span>';
         } else if (env.metadata && env.metadata.to) {
             html += ' <span class="directed">&rarr; ' + escHtml(env.metadata.to) + '</span>';
         }
 
+        html += '<span class="msg-body">';
         switch (env.type) {
             case 'code':
                 el.classList.add('msg-code');
-                html += '<pre><code>' + escHtml(env.payload.code || '') + '</code></pre>';
+                html += payloadHeader(env.payload);
+                html += '<pre><code>' + escHtml(env.payload.code || env.payload.text || '') + '</code></pre>';
                 break;
             case 'diff':
                 el.classList.add('msg-diff');
-                html += '<pre>' + escHtml(env.payload.diff || '') + '</pre>';
+                html += payloadHeader(env.payload);
+                html += '<pre>' + escHtml(env.payload.diff || env.payload.text || '') + '</pre>';
+                break;
+            case 'json':
+                el.classList.add('msg-json');
+                html += payloadHeader(env.payload);
+                html += jsonBlock(env.payload.data);
+                break;
+            case 'file':
+                html += ' ' + escHtml(env.payload && env.payload.text || '');
+                if (env.metadata && env.metadata.file_id) {
+                    html += '<button class="file-open" data-file="' + escHtml(env.metadata.file_id) + '" title="Show in the files panel">open</button>';
+                }
                 break;
             default:
                 html += ' ' + escHtml(env.payload && env.payload.text || '');
                 break;
         }
+        html += '</span>';
+        if (env.id) {
+            html += '<button class="reply-btn" title="Reply in thread">&#x21A9;</button>';
+        }
 
         el.innerHTML = html;
-        messagesDiv.appendChild(el);
+        placeMessage(env, el);
+        applyFilters();
         scrollToBottom();
     }
 
+    // placeMessage appends a message to the list. A reply goes into a
+    // collapsed thread under the message that started it, if that is on screen.
+    function placeMessage(env, el) {
+        const root = env.reply_to ? (threadRoots.get(env.reply_to) || env.reply_to) : env.id;
+        if (env.id) threadRoots.set(env.id, root);
+        const rootEl = env.reply_to && messagesDiv.querySelector('.msg[data-id="' + CSS.escape(root) + '"]');
+        if (!rootEl) {
+            messagesDiv.appendChild(el);
+            return;
+        }
+        let thread = rootEl.querySelector(':scope > details.thread');
+        if (!thread) {
+            thread = document.createElement('details');
+            thread.className = 'thread';
+            thread.appendChild(document.createElement('summary'));
+            rootEl.appendChild(thread);
+        }
+        thread.appendChild(el);
+        if (env.sender === sender) thread.open = true;
+        const n = thread.querySelectorAll(':scope > .msg').length;
+        thread.firstChild.textContent = n + (n === 1 ? ' reply' : ' replies');
+    }
+
+    // --- Filters ---
+    // Messages carry their sender, type and conv_id as data attributes; the
+    // filters hide those that don't match. A thread's first message stays,
+    // dimmed, when replies under it match.
+    function applyFilters() {
+        const all = Array.from(messagesDiv.querySelectorAll('.msg')).reverse(); // replies before their roots
+        for (const el of all) {
+            el.classList.remove('filtered-out', 'filter-context');
+            if (matchesFilters(el)) continue;
+            const thread = el.querySelector(':scope > details.thread');
+            if (thread && thread.querySelector('.msg:not(.filtered-out)')) {
+                el.classList.add('filter-context');
+                thread.open = true;
+            } else {
+                el.classList.add('filtered-out');
+            }
+        }
+    }
+
+    function matchesFilters(el) {
+        return (!filters.sender || el.dataset.sender === filters.sender) &&
+            (!filters.type || el.dataset.type === filters.type) &&
+            (!filters.conv || el.dataset.conv === filters.conv);
+    }
+
+    // noteSender adds a sender to the sender filter's choices, in order.
+    function noteSender(name) {
+        if (!name || senders.has(name)) return;
+        senders.add(name);
+        const opt = document.createElement('option');
+        opt.value = name;
+        opt.textContent = name;
+        const next = Array.from(filterSender.options).slice(1).find(o => o.value.localeCompare(name) > 0);
+        filterSender.insertBefore(opt, next || null);
+    }
+
+    filterSender.addEventListener('change', function () {
+        filters.sender = filterSender.value;
+        applyFilters();
+    });
+    filterType.addEventListener('change', function () {
+        filters.type = filterType.value;
+        applyFilters();
+    });
+    collapseWhispers.addEventListener('change', function () {
+        for (const el of messagesDiv.querySelectorAll('.msg-whisper')) {
+            el.classList.toggle('collapsed', collapseWhispers.checked);
+        }
+    });
+    threadFilterClear.addEventListener('click', function () {
+        setConvFilter('', '');
+    });
+
+    // setConvFilter shows only the conversation convID, or everything for ''.
+    function setConvFilter(convID, title) {
+        filters.conv = convID;
+        threadFilterName.textContent = title;
+        threadFilter.classList.toggle('hidden', !convID);
+        for (const li of threadList.querySelectorAll('.thread-item')) {
+            li.classList.toggle('active', li.dataset.conv === convID);
+        }
+        applyFilters();
+        scrollToBottom();
+    }
+
+    // --- Replies ---
+    messagesDiv.addEventListener('click', function (e) {
+        const label = e.target.closest('.whisper-label');
+        if (label) {
+            label.closest('.msg').classList.toggle('collapsed');
+            return;
+        }
+        if (e.target.classList.contains('file-open')) {
+            showFile(e.target.dataset.file);
+            return;
+        }
+        if (!e.target.classList.contains('reply-btn')) return;
+        const el = e.target.closest('.msg');
+        setReplyTo({ id: el.dataset.id, seq: el.dataset.seq });
+        msgInput.focus();
+    });
+    replyCancel.addEventListener('click', function () {
+        setReplyTo(null);
+    });
+
+    function setReplyTo(target) {
+        replyTo = target;
+        replyTarget.textContent = target ? '#' + target.seq : '';
+        replyBar.classList.toggle('hidden', !target);
+    }
+
+    // mentionsMe reports whether the server found an @-mention of this user.
+    function mentionsMe(env) {
+        const list = env.metadata && env.metadata.mentions;
+        if (!list) return false;
+        return list.split(',').some(n => n.toLowerCase() === sender.toLowerCase());
+    }
+
+    // redactMessage blanks a message the server has purged after its ttl.
+    function redactMessage(m) {
+        if (!m || !m.seq) return;
+        const el = messagesDiv.querySelector('[data-seq="' + m.seq + '"]');
+        if (!el) return;
+        const thread = el.querySelector(':scope > details.thread');
+        el.className = 'msg msg-expired';
+        el.innerHTML = '<span class="seq">#' + m.seq + '</span> message expired';
+        if (thread) el.appendChild(thread); // its replies outlive it
+    }
+
     function formatTime(ts) {
         if (!ts) return '';
         const d = new Date(ts);
         return d.toLocaleTimeString([], { hour: '2-digit', minute: '2-digit', second: '2-digit' });
     }
 
+    // payloadHeader shows a code/diff/json message's file name and note, if any.
+    function payloadHeader(p) {
+        let h = '';
+        if (p.file_path) h += ' <span class="msg-file">' + escHtml(p.file_path) + '</span>';
+        if (p.text && (p.code || p.diff || p.data !== undefined)) h += ' ' + escHtml(p.text);
+        return h;
+    }
+
+    // jsonBlock renders structured data collapsed, with a one-line summary.
+    function jsonBlock(data) {
+        let summary = 'data';
+        if (Array.isArray(data)) summary = 'array, ' + data.length + ' items';
+        else if (data && typeof data === 'object') summary = 'object, ' + Object.keys(data).length + ' keys';
+        return '<details class="json-data"><summary>' + escHtml(summary) + '</summary><pre>' +
+            escHtml(JSON.stringify(data, null, 2)) + '</pre></details>';
+    }
+
     function escHtml(s) {
         const div = document.createElement('div');
         div.textContent = s;
@@ -238,12 +657,15 @@
         const text = msgInput.value.trim();
         if (!text) return;
         msgInput.value = '';
+        const req = { sender: sender, type: 'text', payload: { text: text } };
+        if (replyTo) req.reply_to = replyTo.id;
+        setReplyTo(null);
 
         try {
             await apiFetch('/api/rooms/' + encodeURIComponent(room) + '/messages', {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
-                body: JSON.stringify({ sender: sender, type: 'text', payload: { text: text } }),
+                body: JSON.stringify(await sealRequest(req)),
             });
         } catch (e) {
             console.error('Send failed:', e);
@@ -251,56 +673,189 @@
     }
 
     // --- Ask Claude ---
+    // The options row picks a prompt preset, a model and a work dir for the
+    // spawn; the sessions list above it shows each active Claude with a
+    // button to stop your own.
     claudeBtn.addEventListener('click', askClaude);
     claudeInput.addEventListener('keydown', function (e) {
         if (e.key === 'Enter') askClaude();
     });
+    claudePreset.addEventListener('change', updateClaudePlaceholder);
+
+    async function loadSpawnOptions() {
+        claudePreset.length = 1;
+        claudeModel.length = 1;
+        try {
+            const resp = await apiFetch('/api/rooms/' + encodeURIComponent(room) + '/spawn/options');
+            if (!resp.ok) return; // no runner: free prompt only
+            spawnChoices = await resp.json();
+        } catch (e) {
+            return;
+        }
+        for (const p of spawnChoices.presets || []) {
+            const opt = document.createElement('option');
+            opt.value = p.name;
+            opt.textContent = p.description || p.name;
+            opt.title = p.prompt;
+            claudePreset.appendChild(opt);
+        }
+        if (spawnChoices.default_model) {
+            claudeModel.options[0].textContent = 'Default (' + spawnChoices.default_model + ')';
+        }
+        for (const m of spawnChoices.models || []) {
+            const opt = document.createElement('option');
+            opt.value = m;
+            opt.textContent = m;
+            claudeModel.appendChild(opt);
+        }
+        claudeWorkDir.placeholder = spawnChoices.work_dir || 'Work dir';
+        claudeWorkDirs.innerHTML = '';
+        for (const d of [spawnChoices.work_dir].concat(spawnChoices.allowed_dirs || [])) {
+            if (!d) continue;
+            const opt = document.createElement('option');
+            opt.value = d;
+            claudeWorkDirs.appendChild(opt);
+        }
+        claudeOptions.classList.remove('hidden');
+    }
+
+    function selectedPreset() {
+        if (!spawnChoices || !claudePreset.value) return null;
+        return (spawnChoices.presets || []).find(p => p.name === claudePreset.value) || null;
+    }
+
+    function updateClaudePlaceholder() {
+        const p = selectedPreset();
+        if (!p) claudeInput.placeholder = 'Ask your Claude...';
+        else if (p.prompt.includes('{input}')) claudeInput.placeholder = p.description || p.name;
+        else claudeInput.placeholder = (p.description || p.name) + ' (anything to add?)';
+        if (p && p.model && !claudeModel.value) claudeModel.title = 'Model (preset uses ' + p.model + ')';
+        else claudeModel.title = 'Model';
+    }
 
     async function askClaude() {
         const prompt = claudeInput.value.trim();
-        if (!prompt) return;
-        claudeInput.value = '';
-        claudeBtn.disabled = true;
-        claudeActive = true;
-        stopBtn.classList.remove('hidden');
+        const preset = selectedPreset();
+        if (!prompt && !preset) return;
+        const req = { sender: sender, prompt: prompt };
+        if (preset) req.preset = preset.name;
+        if (claudeModel.value) req.model = claudeModel.value;
+        const workDir = claudeWorkDir.value.trim();
+        if (workDir) req.work_dir = workDir;
 
+        claudeBtn.disabled = true;
         try {
             const resp = await apiFetch('/api/rooms/' + encodeURIComponent(room) + '/spawn', {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
-                body: JSON.stringify({ sender: sender, prompt: prompt }),
+                body: JSON.stringify(req),
             });
-            if (!resp.ok) {
+            if (resp.ok) {
+                claudeInput.value = '';
+                refreshSessions();
+            } else {
                 const data = await resp.json().catch(() => ({}));
-                console.error('Spawn failed:', data.error || resp.statusText);
+                showClaudeError(data.error || resp.statusText);
             }
         } catch (e) {
-            console.error('Spawn request failed:', e);
+            showClaudeError('Spawn request failed: ' + e.message);
+        }
+        claudeBtn.disabled = false;
+    }
+
+    let claudeError = '';
+    function showClaudeError(text) {
+        claudeError = text;
+        refreshSessions();
+    }
+
+    async function refreshSessions() {
+        if (!room || !spawnChoices) {
+            renderSessions([]);
+            return;
+        }
+        try {
+            const resp = await apiFetch('/api/rooms/' + encodeURIComponent(room) + '/sessions');
+            if (!resp.ok) return;
+            const data = await resp.json();
+            renderSessions(data.sessions || []);
+        } catch (e) {
+            // Ignore refresh errors
         }
+    }
 
-        // Re-enable after a short delay (server runs async)
-        setTimeout(function () {
-            claudeBtn.disabled = false;
-            claudeActive = false;
-            stopBtn.classList.add('hidden');
-        }, 2000);
+    function renderSessions(sessions) {
+        claudeSessions.innerHTML = '';
+        if (claudeError) {
+            const err = document.createElement('div');
+            err.className = 'claude-session claude-error';
+            err.textContent = claudeError;
+            const dismiss = document.createElement('button');
+            dismiss.className = 'btn-danger';
+            dismiss.textContent = '×';
+            dismiss.title = 'Dismiss';
+            dismiss.addEventListener('click', function () {
+                claudeError = '';
+                refreshSessions();
+            });
+            err.appendChild(dismiss);
+            claudeSessions.appendChild(err);
+        }
+        for (const sess of sessions) {
+            const row = document.createElement('div');
+            row.className = 'claude-session';
+            const name = document.createElement('span');
+            name.className = 'session-name';
+            name.textContent = sess.sender === sender ? 'Your Claude' : sess.sender + "'s Claude";
+            const status = document.createElement('span');
+            status.className = 'session-status ' + sess.status;
+            status.textContent = sess.status + ' ' + elapsed(sess.started_at);
+            row.append(name, status);
+            if (sess.conv_id) {
+                const conv = document.createElement('span');
+                conv.className = 'session-conv';
+                conv.textContent = 'thread ' + sess.conv_id.slice(0, 8);
+                conv.title = sess.conv_id;
+                row.appendChild(conv);
+            }
+            if (sess.sender === sender) {
+                const stop = document.createElement('button');
+                stop.className = 'btn-danger';
+                stop.textContent = 'Stop';
+                stop.title = 'Stop this Claude';
+                stop.addEventListener('click', function () {
+                    stopClaude(sess.conv_id, stop);
+                });
+                row.appendChild(stop);
+            }
+            claudeSessions.appendChild(row);
+        }
+        claudeSessions.classList.toggle('hidden', claudeSessions.childElementCount === 0);
+    }
+
+    function elapsed(since) {
+        const secs = Math.max(0, Math.round((Date.now() - new Date(since)) / 1000));
+        if (secs < 60) return secs + 's';
+        if (secs < 3600) return Math.floor(secs / 60) + 'm ' + (secs % 60) + 's';
+        return Math.floor(secs / 3600) + 'h ' + Math.floor(secs % 3600 / 60) + 'm';
     }
 
     // --- Stop Claude ---
-    stopBtn.addEventListener('click', async function () {
+    async function stopClaude(convID, btn) {
+        btn.disabled = true;
         try {
+            const req = { sender: sender };
+            if (convID) req.conv_id = convID;
             await apiFetch('/api/rooms/' + encodeURIComponent(room) + '/stop', {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
-                body: JSON.stringify({ sender: sender }),
+                body: JSON.stringify(req),
             });
         } catch (e) {
             console.error('Stop failed:', e);
         }
-        claudeBtn.disabled = false;
-        claudeActive = false;
-        stopBtn.classList.add('hidden');
-    });
+        refreshSessions();
+    }
 
     // --- Synopsis ---
     synopsisBtn.addEventListener('click', async function () {
@@ -324,6 +879,37 @@
         }
     });
 
+    // --- Share ---
+    // A guest link opens the room in a browser for someone without the CLI:
+    // read-only, or posting as the name given.
+    shareBtn.addEventListener('click', async function () {
+        const name = prompt('Guest link to #' + room + ', valid for a day.\n\n' +
+            'Name the guest to let them send messages, or leave this empty for a read-only link:', '');
+        if (name === null) return;
+        const req = { sender: sender, access: name.trim() ? 'post' : 'read', name: name.trim() };
+        try {
+            const resp = await apiFetch('/api/rooms/' + encodeURIComponent(room) + '/share', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify(req),
+            });
+            const data = await resp.json().catch(() => ({}));
+            if (!resp.ok) {
+                alert('Could not make a link: ' + (data.error || resp.status));
+                return;
+            }
+            const link = window.location.origin + data.path;
+            try {
+                await navigator.clipboard.writeText(link);
+                alert('Guest link copied:\n\n' + link);
+            } catch (e) {
+                prompt('Guest link:', link);
+            }
+        } catch (e) {
+            console.error('Share failed:', e);
+        }
+    });
+
     // --- Leave ---
     leaveBtn.addEventListener('click', function () {
         if (ws) {
@@ -332,6 +918,19 @@
             ws = null;
         }
         seenSeqs.clear();
+        threadRoots.clear();
+        senders.clear();
+        filterSender.length = 1;
+        filterSender.value = filterType.value = '';
+        filters.sender = filters.type = '';
+        setConvFilter('', '');
+        threadList.innerHTML = '<li class="muted">No threads yet</li>';
+        filesPanel.classList.add('hidden');
+        fileList.innerHTML = '<li class="muted">No files shared</li>';
+        uploadList.innerHTML = '';
+        filesBtn.textContent = 'Files';
+        setReplyTo(null);
+        roomKey = null;
         messagesDiv.innerHTML = '';
         chatScreen.classList.add('hidden');
         joinScreen.classList.remove('hidden');
@@ -346,6 +945,9 @@
             const data = await resp.json();
             participantList.innerHTML = '';
             const participants = data.participants || [];
+            // Connected first, then whoever was seen most recently.
+            participants.sort((a, b) => (b.connected - a.connected) ||
+                (new Date(b.last_seen) - new Date(a.last_seen)));
             if (participants.length === 0) {
                 participantList.innerHTML = '<li class="muted">No one here yet</li>';
                 return;
@@ -357,6 +959,15 @@
                 if (p.role && p.role !== 'user') {
                     li.textContent += ' (' + p.role + ')';
                 }
+                if (p.profile) {
+                    li.appendChild(profileLine(p.profile));
+                }
+                if (!p.connected) {
+                    li.appendChild(seenLine(p));
+                }
+                if (p.connected && p.health) {
+                    li.appendChild(healthLine(p.health));
+                }
                 participantList.appendChild(li);
             }
         } catch (e) {
@@ -364,31 +975,327 @@
         }
     }
 
+    // profileLine shows what a participant advertised about itself.
+    function profileLine(p) {
+        const div = document.createElement('div');
+        div.className = 'participant-profile';
+        const parts = [];
+        if (p.description) parts.push(p.description);
+        if (p.model) parts.push(p.model);
+        if (p.availability) parts.push(p.availability);
+        div.textContent = parts.join(' · ');
+        if (p.work_dir) div.title = p.work_dir;
+        return div;
+    }
+
+    // seenLine says when an offline participant was last around.
+    function seenLine(p) {
+        const div = document.createElement('div');
+        div.className = 'participant-seen';
+        const mins = Math.round((Date.now() - new Date(p.last_seen).getTime()) / 60000);
+        let ago = 'just now';
+        if (mins >= 60 * 24) ago = Math.round(mins / 60 / 24) + 'd ago';
+        else if (mins >= 60) ago = Math.round(mins / 60) + 'h ago';
+        else if (mins > 0) ago = mins + 'm ago';
+        div.textContent = 'offline · seen ' + ago + ' · ' + p.messages + (p.messages === 1 ? ' message' : ' messages');
+        return div;
+    }
+
+    // healthLine summarizes a daemon's last status report. A report older
+    // than two intervals (60s) means the daemon may not answer.
+    function healthLine(h) {
+        const div = document.createElement('div');
+        div.className = 'participant-health';
+        const age = (Date.now() - new Date(h.reported_at).getTime()) / 1000;
+        const parts = [];
+        if (age > 60) {
+            parts.push('no report for ' + Math.round(age / 60) + 'm');
+            div.classList.add('stale');
+        } else {
+            parts.push(h.active_spawns + '/' + h.max_concurrent + ' busy');
+        }
+        if (h.last_result) {
+            parts.push('last: ' + h.last_result);
+            if (h.last_result !== 'ok') div.classList.add('failing');
+        }
+        if (h.claude_version) parts.push(h.claude_version);
+        div.textContent = parts.join(' · ');
+        if (h.last_error) div.title = h.last_error;
+        return div;
+    }
+
+    // --- Threads ---
+    // The room's conv_id conversations, most recently active first. Clicking
+    // one shows only its messages.
+    async function refreshThreads() {
+        if (!room) return;
+        try {
+            const resp = await apiFetch('/api/rooms/' + encodeURIComponent(room) + '/conversations?limit=30');
+            if (!resp.ok) return;
+            const data = await resp.json();
+            const convs = data.conversations || [];
+            threadList.innerHTML = '';
+            if (convs.length === 0) {
+                threadList.innerHTML = '<li class="muted">No threads yet</li>';
+                return;
+            }
+            for (const c of convs) {
+                const title = c.topic || c.participants.join(', ');
+                const li = document.createElement('li');
+                li.className = 'thread-item';
+                li.dataset.conv = c.conv_id;
+                li.title = c.conv_id;
+                if (c.conv_id === filters.conv) li.classList.add('active');
+                if (c.open) li.classList.add('open');
+                const topic = document.createElement('div');
+                topic.className = 'thread-topic';
+                topic.textContent = title;
+                const meta = document.createElement('div');
+                meta.className = 'thread-meta';
+                const parts = [c.participants.join(' \u2194 '), c.messages + (c.messages === 1 ? ' message' : ' messages')];
+                if (c.open && c.waiting_on) parts.push('waiting on ' + c.waiting_on);
+                meta.textContent = parts.join(' · ');
+                li.append(topic, meta);
+                li.addEventListener('click', function () {
+                    if (filters.conv === c.conv_id) setConvFilter('', '');
+                    else setConvFilter(c.conv_id, title);
+                });
+                threadList.appendChild(li);
+            }
+        } catch (e) {
+            // Ignore refresh errors
+        }
+    }
+
     // --- Files ---
+    // The files panel lists what the room has shared, newest first, with
+    // previews, and takes uploads dropped anywhere on the chat.
+    const previewBytes = 64 * 1024; // how much of a text file a preview shows
+    const imageTypes = /^image\/(png|jpeg|gif|webp|bmp)$/;
+    const textTypes = /^(text\/|application\/(json|xml|javascript|x-yaml|x-sh|toml))/;
+    const textExts = /\.(txt|md|log|json|ya?ml|toml|xml|csv|go|js|ts|py|rb|rs|java|c|h|cpp|sh|sql|diff|patch|html|css)$/i;
+
+    function fileURL(id) {
+        return apiBase() + '/api/rooms/' + encodeURIComponent(room) + '/files/' + encodeURIComponent(id);
+    }
+
+    filesBtn.addEventListener('click', function () {
+        filesPanel.classList.toggle('hidden');
+    });
+    filesClose.addEventListener('click', function () {
+        filesPanel.classList.add('hidden');
+    });
+
     async function refreshFiles() {
         if (!room) return;
         try {
             const resp = await apiFetch('/api/rooms/' + encodeURIComponent(room) + '/files');
             if (!resp.ok) return;
             const data = await resp.json();
+            const files = (data.files || []).slice().sort((a, b) => new Date(b.timestamp) - new Date(a.timestamp));
+            filesBtn.textContent = files.length ? 'Files (' + files.length + ')' : 'Files';
+            // Keep open previews across refreshes.
+            const open = new Set(Array.from(fileList.querySelectorAll('li[data-id]'))
+                .filter(li => li.querySelector('.file-preview')).map(li => li.dataset.id));
             fileList.innerHTML = '';
-            const files = data.files || [];
             if (files.length === 0) {
                 fileList.innerHTML = '<li class="muted">No files shared</li>';
                 return;
             }
             for (const f of files) {
-                const li = document.createElement('li');
-                const a = document.createElement('a');
-                a.href = apiBase() + '/api/rooms/' + encodeURIComponent(room) + '/files/' + f.id;
-                a.textContent = f.filename;
-                a.style.color = 'var(--accent)';
-                a.target = '_blank';
-                li.appendChild(a);
+                const li = fileItem(f);
                 fileList.appendChild(li);
+                if (open.has(f.id)) togglePreview(li, f);
             }
         } catch (e) {
             // Ignore refresh errors
         }
     }
+
+    // fileItem renders one shared file: name, who shared it and when, and
+    // links to preview, download and share it.
+    function fileItem(f) {
+        const li = document.createElement('li');
+        li.dataset.id = f.id;
+        const name = document.createElement('div');
+        name.className = 'file-name';
+        name.textContent = f.filename;
+        const meta = document.createElement('div');
+        meta.className = 'file-meta';
+        meta.textContent = byteSize(f.size) + ' · ' + f.sender + ' · ' + formatTime(f.timestamp);
+        if (f.description) meta.title = f.description;
+
+        const actions = document.createElement('div');
+        actions.className = 'file-actions';
+        if (previewable(f)) {
+            const preview = document.createElement('button');
+            preview.textContent = 'preview';
+            preview.addEventListener('click', () => togglePreview(li, f));
+            actions.appendChild(preview);
+        }
+        const download = document.createElement('a');
+        download.href = fileURL(f.id) + tokenQuery('?');
+        download.textContent = 'download';
+        download.target = '_blank';
+        actions.appendChild(download);
+        const share = document.createElement('button');
+        share.textContent = 'copy link';
+        share.title = 'Copy a download link; whoever opens it needs access to the room';
+        share.addEventListener('click', async function () {
+            try {
+                await navigator.clipboard.writeText(fileURL(f.id));
+                share.textContent = 'copied';
+            } catch (e) {
+                prompt('Download link:', fileURL(f.id));
+            }
+            setTimeout(() => { share.textContent = 'copy link'; }, 1500);
+        });
+        actions.appendChild(share);
+
+        li.append(name, meta, actions);
+        return li;
+    }
+
+    function previewable(f) {
+        return imageTypes.test(f.content_type) || textTypes.test(f.content_type) || textExts.test(f.filename);
+    }
+
+    // togglePreview shows an image inline, or the start of a text file.
+    async function togglePreview(li, f) {
+        const existing = li.querySelector('.file-preview');
+        if (existing) {
+            existing.remove();
+            return;
+        }
+        const div = document.createElement('div');
+        div.className = 'file-preview';
+        li.appendChild(div);
+        if (imageTypes.test(f.content_type)) {
+            const img = document.createElement('img');
+            img.src = fileURL(f.id) + tokenQuery('?');
+            img.alt = f.filename;
+            div.appendChild(img);
+            return;
+        }
+        try {
+            const resp = await apiFetch('/api/rooms/' + encodeURIComponent(room) + '/files/' + encodeURIComponent(f.id), {
+                headers: { 'Range': 'bytes=0-' + (previewBytes - 1) },
+            });
+            if (!resp.ok) throw new Error(resp.status);
+            const pre = document.createElement('pre');
+            pre.textContent = await resp.text();
+            if (f.size > previewBytes) pre.textContent += '\n… (first ' + byteSize(previewBytes) + ' of ' + byteSize(f.size) + ')';
+            div.appendChild(pre);
+        } catch (e) {
+            div.textContent = 'Could not load a preview.';
+        }
+    }
+
+    // showFile opens the files panel at a file, from its message in the chat.
+    async function showFile(id) {
+        filesPanel.classList.remove('hidden');
+        if (!fileList.querySelector('li[data-id="' + CSS.escape(id) + '"]')) await refreshFiles();
+        const li = fileList.querySelector('li[data-id="' + CSS.escape(id) + '"]');
+        if (!li) return;
+        for (const other of fileList.querySelectorAll('li.highlight')) other.classList.remove('highlight');
+        li.classList.add('highlight');
+        li.scrollIntoView({ block: 'nearest' });
+    }
+
+    function byteSize(n) {
+        if (n < 1024) return n + 'B';
+        let v = n / 1024, suffix = 'K';
+        for (const s of ['M', 'G']) {
+            if (v < 1024) break;
+            v /= 1024;
+            suffix = s;
+        }
+        return v.toFixed(1) + suffix;
+    }
+
+    // --- Uploads ---
+    // Files dropped on the chat, or chosen in the panel, are uploaded one at
+    // a time with a progress bar each; the server announces each in the room.
+    fileInput.addEventListener('change', function () {
+        uploadFiles(fileInput.files);
+        fileInput.value = '';
+    });
+
+    let dragDepth = 0; // dragenter/dragleave fire for every child crossed
+    chatScreen.addEventListener('dragenter', function (e) {
+        if (shareToken || !e.dataTransfer.types.includes('Files')) return;
+        e.preventDefault();
+        dragDepth++;
+        filesPanel.classList.remove('hidden');
+        dropZone.classList.add('dragging');
+    });
+    chatScreen.addEventListener('dragover', function (e) {
+        if (e.dataTransfer.types.includes('Files')) e.preventDefault();
+    });
+    chatScreen.addEventListener('dragleave', function () {
+        if (--dragDepth <= 0) {
+            dragDepth = 0;
+            dropZone.classList.remove('dragging');
+        }
+    });
+    chatScreen.addEventListener('drop', function (e) {
+        if (shareToken || !e.dataTransfer.files.length) return;
+        e.preventDefault();
+        dragDepth = 0;
+        dropZone.classList.remove('dragging');
+        uploadFiles(e.dataTransfer.files);
+    });
+
+    async function uploadFiles(files) {
+        for (const file of Array.from(files)) {
+            await uploadFile(file);
+        }
+        refreshFiles();
+    }
+
+    // uploadFile posts one file, showing its progress. XMLHttpRequest, as
+    // fetch can't report upload progress.
+    function uploadFile(file) {
+        const li = document.createElement('li');
+        li.textContent = file.name;
+        const bar = document.createElement('progress');
+        bar.max = file.size || 1;
+        bar.value = 0;
+        li.appendChild(bar);
+        uploadList.appendChild(li);
+
+        return new Promise(function (resolve) {
+            const form = new FormData();
+            form.append('file', file);
+            form.append('sender', sender);
+            const xhr = new XMLHttpRequest();
+            xhr.open('POST', apiBase() + '/api/rooms/' + encodeURIComponent(room) + '/files');
+            for (const [k, v] of Object.entries(authHeaders())) xhr.setRequestHeader(k, v);
+            xhr.upload.onprogress = function (e) {
+                if (e.lengthComputable) bar.value = e.loaded * bar.max / e.total;
+            };
+            xhr.onload = function () {
+                if (xhr.status >= 200 && xhr.status < 300) {
+                    li.remove();
+                } else {
+                    let msg = xhr.statusText;
+                    try { msg = JSON.parse(xhr.responseText).error || msg; } catch (e) { /* not JSON */ }
+                    failUpload(li, file, msg);
+                }
+                resolve();
+            };
+            xhr.onerror = function () {
+                failUpload(li, file, 'network error');
+                resolve();
+            };
+            xhr.send(form);
+        });
+    }
+
+    function failUpload(li, file, msg) {
+        li.className = 'failed';
+        li.textContent = file.name + ': ' + msg;
+        li.title = 'Click to dismiss';
+        li.addEventListener('click', () => li.remove());
+    }
 })();
@@ -0,0 +1,283 @@
+// ClaudeTalk mobile view: one room's live activity over server-sent events,
+// with replies, so owners can answer their Claudes from a phone.
+(function () {
+    'use strict';
+
+    // --- State ---
+    let room = '';
+    let sender = '';
+    let events = null; // EventSource for /api/rooms/{room}/events
+    let replyTo = null; // the envelope the next send answers
+    const seen = new Set(); // seqs already shown
+    const waiting = new Map(); // id → envelope directed at you that expects a reply
+    const storageKey = 'claudetalk-mobile';
+
+    // --- DOM refs ---
+    const joinForm = document.getElementById('join-form');
+    const roomInput = document.getElementById('room-input');
+    const nameInput = document.getElementById('name-input');
+    const live = document.getElementById('live');
+    const statusDot = document.getElementById('status-dot');
+    const roomTitle = document.getElementById('room-title');
+    const waitingBtn = document.getElementById('waiting-btn');
+    const switchBtn = document.getElementById('switch-btn');
+    const messagesDiv = document.getElementById('messages');
+    const replyBar = document.getElementById('reply-bar');
+    const replyTarget = document.getElementById('reply-target');
+    const replyCancel = document.getElementById('reply-cancel');
+    const sendForm = document.getElementById('send-form');
+    const msgInput = document.getElementById('msg-input');
+
+    // --- Tokens ---
+    // A private room's token comes from ?room_token= or the full web UI,
+    // which remembers it under the same key.
+    function roomTokenKey(r) {
+        return 'claudetalk-room-token::' + r;
+    }
+    function roomToken() {
+        return localStorage.getItem(roomTokenKey(room)) || '';
+    }
+
+    // --- Start ---
+    const params = new URLSearchParams(window.location.search);
+    const saved = JSON.parse(localStorage.getItem(storageKey) || '{}');
+    roomInput.value = params.get('room') || saved.room || '';
+    nameInput.value = params.get('name') || saved.name || '';
+    if (params.get('room_token') && roomInput.value) {
+        localStorage.setItem(roomTokenKey(roomInput.value), params.get('room_token'));
+    }
+    if (params.has('room_token')) {
+        // Keep the token out of the address bar and history.
+        params.delete('room_token');
+        const query = params.toString();
+        history.replaceState(null, '', window.location.pathname + (query ? '?' + query : ''));
+    }
+    if (roomInput.value && nameInput.value) {
+        start(roomInput.value, nameInput.value);
+    } else {
+        joinForm.classList.remove('hidden');
+    }
+
+    joinForm.addEventListener('submit', function (e) {
+        e.preventDefault();
+        const r = roomInput.value.trim();
+        const s = nameInput.value.trim();
+        if (r && s) start(r, s);
+    });
+
+    switchBtn.addEventListener('click', function () {
+        if (events) events.close();
+        events = null;
+        seen.clear();
+        waiting.clear();
+        setReplyTo(null);
+        messagesDiv.innerHTML = '';
+        live.classList.add('hidden');
+        joinForm.classList.remove('hidden');
+    });
+
+    function start(r, s) {
+        room = r;
+        sender = s;
+        localStorage.setItem(storageKey, JSON.stringify({ room: room, name: sender }));
+        joinForm.classList.add('hidden');
+        live.classList.remove('hidden');
+        roomTitle.textContent = '#' + room;
+        updateWaiting();
+        connect();
+    }
+
+    // --- Events ---
+    // EventSource reconnects by itself, sending the last seq it saw, so the
+    // server replays only what was missed.
+    function connect() {
+        const q = new URLSearchParams({ sender: sender, n: '50' });
+        if (roomToken()) q.set('room_token', roomToken());
+        events = new EventSource('/api/rooms/' + encodeURIComponent(room) + '/events?' + q);
+        events.onopen = function () {
+            setStatus(true, 'Live');
+        };
+        events.onerror = function () {
+            if (events.readyState === EventSource.CLOSED) {
+                setStatus(false, 'Can\'t open this room (is it private, or misspelled?)');
+            } else {
+                setStatus(false, 'Reconnecting…');
+            }
+        };
+        events.addEventListener('message', function (e) {
+            try {
+                render(JSON.parse(e.data));
+            } catch (err) {
+                console.error('Bad event:', err);
+            }
+        });
+        events.addEventListener('message_expired', function (e) {
+            const gone = JSON.parse(e.data);
+            const el = messagesDiv.querySelector('[data-id="' + CSS.escape(gone.id) + '"]');
+            if (el) el.remove();
+            waiting.delete(gone.id);
+            updateWaiting();
+        });
+    }
+
+    function setStatus(ok, text) {
+        statusDot.classList.toggle('live', ok);
+        statusDot.title = text;
+    }
+
+    // --- Messages ---
+    function forMe(env) {
+        if (env.sender === sender) return false;
+        const meta = env.metadata || {};
+        if (meta.to === sender) return true;
+        return (meta.mentions || '').split(',').some(n => n.toLowerCase() === sender.toLowerCase());
+    }
+
+    function messageText(env) {
+        const p = env.payload || {};
+        if (env.metadata && env.metadata.e2e) return '[encrypted — open it in the full web UI with the passphrase]';
+        return p.text || p.code || p.diff || (p.data ? JSON.stringify(p.data) : '') || '[' + env.type + ']';
+    }
+
+    function render(env) {
+        if (!env.seq || seen.has(env.seq)) return;
+        seen.add(env.seq);
+        const meta = env.metadata || {};
+        const nearBottom = messagesDiv.scrollHeight - messagesDiv.scrollTop - messagesDiv.clientHeight < 80;
+
+        const el = document.createElement('div');
+        el.dataset.id = env.id;
+        if (env.type === 'system') {
+            el.className = 'm-msg system';
+            el.textContent = messageText(env);
+        } else {
+            el.className = 'm-msg';
+            if (env.sender === sender) el.classList.add('mine');
+            if (forMe(env)) el.classList.add('for-me');
+
+            const head = document.createElement('div');
+            head.className = 'm-meta';
+            const who = document.createElement('span');
+            who.className = 'm-sender';
+            if (/claude/i.test(env.sender)) who.classList.add('claude');
+            who.textContent = env.sender;
+            head.appendChild(who);
+            if (meta.to) {
+                const to = document.createElement('span');
+                to.textContent = '→ ' + meta.to;
+                head.appendChild(to);
+            }
+            const time = document.createElement('span');
+            time.textContent = new Date(env.timestamp).toLocaleTimeString([], { hour: '2-digit', minute: '2-digit' });
+            head.appendChild(time);
+
+            const body = document.createElement('div');
+            body.className = 'm-text';
+            if (env.type === 'code' || env.type === 'diff' || env.type === 'json') body.classList.add('code');
+            body.textContent = messageText(env);
+            el.append(head, body);
+            el.addEventListener('click', function () {
+                setReplyTo(replyTo && replyTo.id === env.id ? null : env);
+            });
+        }
+        messagesDiv.appendChild(el);
+
+        if (forMe(env) && meta.expecting_reply !== 'false') {
+            waiting.set(env.id, env);
+            el.classList.add('waiting');
+        }
+        if (env.sender === sender) answered(env);
+        updateWaiting();
+        if (nearBottom || env.sender === sender) messagesDiv.scrollTop = messagesDiv.scrollHeight;
+    }
+
+    // answered drops what mine replies to from the waiting list: the message
+    // it threads under, and anything else in its conversation.
+    function answered(mine) {
+        const conv = mine.metadata && mine.metadata.conv_id;
+        for (const [id, w] of waiting) {
+            if (mine.reply_to === id || (conv && w.metadata && w.metadata.conv_id === conv)) {
+                waiting.delete(id);
+                const el = messagesDiv.querySelector('[data-id="' + CSS.escape(id) + '"]');
+                if (el) el.classList.remove('waiting');
+            }
+        }
+    }
+
+    function updateWaiting() {
+        const n = waiting.size;
+        waitingBtn.textContent = n + ' waiting on you';
+        waitingBtn.classList.toggle('hidden', n === 0);
+        document.title = (n ? '(' + n + ') ' : '') + (room ? '#' + room + ' — ' : '') + 'ClaudeTalk';
+    }
+
+    // The oldest message waiting on you, ready to answer.
+    waitingBtn.addEventListener('click', function () {
+        const first = waiting.values().next().value;
+        if (!first) return;
+        const el = messagesDiv.querySelector('[data-id="' + CSS.escape(first.id) + '"]');
+        if (el) el.scrollIntoView({ behavior: 'smooth', block: 'center' });
+        setReplyTo(first);
+        msgInput.focus();
+    });
+
+    // --- Replies ---
+    function setReplyTo(env) {
+        const old = messagesDiv.querySelector('.m-msg.selected');
+        if (old) old.classList.remove('selected');
+        replyTo = env;
+        replyBar.classList.toggle('hidden', !env);
+        if (!env) {
+            msgInput.placeholder = 'Tap a message to answer it';
+            return;
+        }
+        replyTarget.textContent = 'Answering ' + env.sender;
+        msgInput.placeholder = 'Reply to ' + env.sender + '…';
+        const el = messagesDiv.querySelector('[data-id="' + CSS.escape(env.id) + '"]');
+        if (el) el.classList.add('selected');
+    }
+
+    replyCancel.addEventListener('click', function () {
+        setReplyTo(null);
+    });
+
+    function newConvID() {
+        if (window.crypto && crypto.randomUUID) return crypto.randomUUID();
+        return Array.from(crypto.getRandomValues(new Uint8Array(16)), b => b.toString(16).padStart(2, '0')).join('');
+    }
+
+    // A reply goes to its target's sender in the same conversation and
+    // expects an answer, as "claudetalk reply" does.
+    sendForm.addEventListener('submit', async function (e) {
+        e.preventDefault();
+        const text = msgInput.value.trim();
+        if (!text) return;
+        const req = { sender: sender, type: 'text', payload: { text: text } };
+        if (replyTo) {
+            req.reply_to = replyTo.id;
+            req.metadata = {
+                to: replyTo.sender,
+                conv_id: (replyTo.metadata && replyTo.metadata.conv_id) || newConvID(),
+                expecting_reply: 'true',
+            };
+        }
+        const headers = { 'Content-Type': 'application/json' };
+        if (roomToken()) headers['X-Claudetalk-Room-Token'] = roomToken();
+        try {
+            const resp = await fetch('/api/rooms/' + encodeURIComponent(room) + '/messages', {
+                method: 'POST',
+                headers: headers,
+                body: JSON.stringify(req),
+            });
+            if (!resp.ok) {
+                const data = await resp.json().catch(() => ({}));
+                alert('Not sent: ' + (data.error || resp.status));
+                return;
+            }
+            msgInput.value = '';
+            setReplyTo(null);
+        } catch (err) {
+            alert('Not sent: ' + err.message);
+        }
+    });
+})();